@@ -1,13 +1,16 @@
 package main
 
 import (
+	"encoding/csv"
 	"encoding/hex"
 	"fmt"
 	"math/big"
+	"net/url"
 	"os"
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	"gitlab.com/NebulousLabs/errors"
@@ -68,6 +71,8 @@ Available settings:
      maxephemeralaccountbalance: currency
      maxephemeralaccountrisk:    currency
 
+     maxregistryentries: entries
+
 Currency units can be specified, e.g. 10SCP; run 'spc help wallet' for details.
 
 Durations (maxduration and windowsize) must be specified in either blocks (b),
@@ -94,10 +99,27 @@ To configure the host to accept new contracts, set acceptingcontracts to true:
 Available output types:
      value:  show financial information
      status: show status information
+
+The contracts can be filtered by their status and renter, and paginated with
+the limit and cursor flags.
 `,
 		Run: wrap(hostcontractcmd),
 	}
 
+	hostFinancialsCmd = &cobra.Command{
+		Use:   "financials",
+		Short: "Show the revenue of the host per period",
+		Long: `Show the revenue and losses of the storage obligations that were resolved
+within a range of block heights, split into periods of the given number of
+blocks. The range defaults to all blocks up to the current height.
+
+With the csv flag the periods are printed as CSV with the amounts in hastings.
+The token storage revenue is the part of the storage and bandwidth revenue that
+renters paid to top up tokens, it is already included in those columns and
+must not be added to them.`,
+		Run: wrap(hostfinancialscmd),
+	}
+
 	hostFolderAddCmd = &cobra.Command{
 		Use:   "add [path] [size]",
 		Short: "Add a storage folder to the host",
@@ -107,8 +129,19 @@ Available output types:
 
 	hostFolderCmd = &cobra.Command{
 		Use:   "folder",
-		Short: "Add, remove, or resize a storage folder",
-		Long:  "Add, remove, or resize a storage folder.",
+		Short: "Add, remove, resize, or migrate a storage folder",
+		Long:  "Add, remove, resize, or migrate a storage folder.",
+	}
+
+	hostFolderMigrateCmd = &cobra.Command{
+		Use:   "migrate [from] [to]",
+		Short: "Move the data of a storage folder into another folder",
+		Long: `Move all data of a storage folder into another storage folder. The data
+remains available while it is being moved and the migration continues in the
+background after the command returns. The progress is shown by 'spc host'.
+If the destination runs out of space, the migration stops without losing data.
+Both folders can't be removed or resized until the migration is finished.`,
+		Run: wrap(hostfoldermigratecmd),
 	}
 
 	hostFolderRemoveCmd = &cobra.Command{
@@ -129,6 +162,60 @@ other storage folders.`,
 		Run: wrap(hostfolderresizecmd),
 	}
 
+	hostPricingCmd = &cobra.Command{
+		Use:   "pricing",
+		Short: "View the pricing policy of the host",
+		Long: `View the pricing policy of the host and the prices it would set on the
+next block.`,
+		Run: wrap(hostpricingcmd),
+	}
+
+	hostPricingConfigCmd = &cobra.Command{
+		Use:   "config [setting] [value]",
+		Short: "Modify the pricing policy of the host",
+		Long: `Modify the pricing policy of the host. While the policy is enabled, it
+adjusts the storage price, the bandwidth prices and the collateral of the host
+on every block, based on the storage utilization, the recently formed contracts
+and the median prices of the network. The prices of the network are only known
+if the renter module is running.
+
+Available settings:
+     enabled:             boolean
+     targetutilization:   fraction between 0 and 1
+     utilizationweight:   number
+     formationwindow:     blocks
+     targetformationrate: contracts per formation window
+     formationweight:     number
+     marketweight:        fraction between 0 and 1
+     maxadjustment:       fraction between 0 and 1, per block
+
+     storagepricefloor:             currency / TB / Month
+     storagepriceceiling:           currency / TB / Month
+     downloadbandwidthpricefloor:   currency / TB
+     downloadbandwidthpriceceiling: currency / TB
+     uploadbandwidthpricefloor:     currency / TB
+     uploadbandwidthpriceceiling:   currency / TB
+     collateralfloor:               currency / TB / Month
+     collateralceiling:             currency / TB / Month
+
+A ceiling of 0 means that the price isn't capped. Run 'spc host pricing preview'
+to see the effect of a setting before changing it.
+
+To enable the pricing policy:
+	spc host pricing config enabled true
+`,
+		Run: wrap(hostpricingconfigcmd),
+	}
+
+	hostPricingPreviewCmd = &cobra.Command{
+		Use:   "preview [setting] [value]",
+		Short: "Preview a change of the pricing policy",
+		Long: `Show the prices the pricing policy would set on the next block if the
+setting was changed, without changing the policy. See 'spc host pricing config'
+for the available settings.`,
+		Run: wrap(hostpricingpreviewcmd),
+	}
+
 	hostSectorCmd = &cobra.Command{
 		Use:   "sector",
 		Short: "Add or delete a sector (add not supported)",
@@ -136,6 +223,28 @@ other storage folders.`,
 deleting a sector may impact host revenue.`,
 	}
 
+	hostTokensCmd = &cobra.Command{
+		Use:   "tokens",
+		Short: "Compact or verify the token storage",
+		Long:  "Compact or verify the events log of the token storage.",
+	}
+
+	hostTokensCompactCmd = &cobra.Command{
+		Use:   "compact",
+		Short: "Compact the token storage",
+		Long: `Write a snapshot of the token storage and truncate its events log, so that
+the host doesn't replay the events on startup.`,
+		Run: wrap(hosttokenscompactcmd),
+	}
+
+	hostTokensVerifyCmd = &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the token storage",
+		Long: `Check the checksum of the token storage snapshot and make sure that
+replaying the snapshot and the events log results in the current state.`,
+		Run: wrap(hosttokensverifycmd),
+	}
+
 	hostSectorDeleteCmd = &cobra.Command{
 		Use:   "delete [root]",
 		Short: "Delete a sector",
@@ -243,6 +352,8 @@ Host Internal Settings:
 	maxephemeralaccountbalance: %v
 	maxephemeralaccountrisk:    %v
 
+	maxregistryentries: %v
+
 Host Financials:
 	Contract Count:               %v
 	Transaction Fee Compensation: %v
@@ -296,6 +407,8 @@ RPC Stats:
 			currencyUnits(is.MaxEphemeralAccountBalance),
 			currencyUnits(is.MaxEphemeralAccountRisk),
 
+			is.MaxRegistryEntries,
+
 			fm.ContractCount, currencyUnits(fm.ContractCompensation),
 			currencyUnits(fm.PotentialContractCompensation),
 			currencyUnits(fm.TransactionFeeExpenses),
@@ -364,13 +477,29 @@ RPC Stats:
 		return
 	}
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
-	fmt.Fprintf(w, "\tUsed\tCapacity\t%% Used\tPath\n")
+	fmt.Fprintf(w, "\tUsed\tCapacity\t%% Used\tCorrupt Sectors\tPath\n")
 	for _, folder := range sg.Folders {
 		curSize := int64(folder.Capacity - folder.CapacityRemaining)
 		pctUsed := 100 * (float64(curSize) / float64(folder.Capacity))
-		fmt.Fprintf(w, "\t%s\t%s\t%.2f\t%s\n", modules.FilesizeUnits(uint64(curSize)), modules.FilesizeUnits(folder.Capacity), pctUsed, folder.Path)
+		fmt.Fprintf(w, "\t%s\t%s\t%.2f\t%v\t%s\n", modules.FilesizeUnits(uint64(curSize)), modules.FilesizeUnits(folder.Capacity), pctUsed, folder.CorruptSectors, folder.Path)
 	}
 	w.Flush()
+
+	// display the progress of storage folder migrations
+	for _, folder := range sg.Folders {
+		if !folder.Migrating {
+			continue
+		}
+		var pctMigrated float64
+		if folder.ProgressDenominator > 0 {
+			pctMigrated = 100 * (float64(folder.ProgressNumerator) / float64(folder.ProgressDenominator))
+		}
+		for _, dest := range sg.Folders {
+			if dest.Index == folder.MigrationDestination {
+				fmt.Printf("Migrating %v to %v: %.2f%%\n", folder.Path, dest.Path, pctMigrated)
+			}
+		}
+	}
 }
 
 // hostconfigcmd is the handler for the command `spc host config [setting] [value]`.
@@ -429,7 +558,7 @@ func hostconfigcmd(param, value string) {
 		}
 
 	// other valid settings
-	case "maxdownloadbatchsize", "maxrevisebatchsize", "netaddress":
+	case "maxdownloadbatchsize", "maxrevisebatchsize", "maxregistryentries", "netaddress":
 
 	// invalid settings
 	default:
@@ -455,7 +584,14 @@ func hostconfigcmd(param, value string) {
 
 // hostcontractcmd is the handler for the command `spc host contracts [type]`.
 func hostcontractcmd() {
-	cg, err := httpClient.HostContractInfoGet()
+	values := url.Values{}
+	values.Set("cursor", hostContractCursor)
+	values.Set("renterkey", hostContractRenter)
+	values.Set("status", hostContractStatus)
+	if hostContractLimit > 0 {
+		values.Set("limit", fmt.Sprint(hostContractLimit))
+	}
+	cg, err := httpClient.HostContractInfoQueryGet(values)
 	if err != nil {
 		die("Could not fetch host contract info:", err)
 	}
@@ -479,6 +615,52 @@ func hostcontractcmd() {
 		die("\"" + hostContractOutputType + "\" is not a format")
 	}
 	w.Flush()
+
+	fmt.Printf(`
+Matching Contracts: %v
+Data Size:          %v
+Locked Collateral:  %v
+Risked Collateral:  %v
+Revenue:            %v
+`, cg.Summary.Count, modules.FilesizeUnits(cg.Summary.DataSize), currencyUnits(cg.Summary.LockedCollateral),
+		currencyUnits(cg.Summary.RiskedCollateral), currencyUnits(cg.Summary.Revenue))
+	if cg.NextCursor != "" {
+		fmt.Printf("\nMore contracts are available, use --cursor %v to display them.\n", cg.NextCursor)
+	}
+}
+
+// hostfinancialscmd is the handler for the command `spc host financials`.
+func hostfinancialscmd() {
+	hfg, err := httpClient.HostFinancialsGet(types.BlockHeight(hostFinancialsStartHeight), types.BlockHeight(hostFinancialsEndHeight), types.BlockHeight(hostFinancialsPeriod))
+	if err != nil {
+		die("Could not get host financials:", err)
+	}
+
+	if hostFinancialsCSV {
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"Start Height", "End Height", "Succeeded Obligations", "Failed Obligations", "Contract Compensation", "Storage Revenue",
+			"Upload Revenue", "Download Revenue", "Account Funding", "Token Storage Revenue (Included In Storage And Bandwidth)", "Lost Revenue", "Lost Collateral"})
+		for _, p := range hfg.Periods {
+			w.Write([]string{fmt.Sprint(p.StartHeight), fmt.Sprint(p.EndHeight), fmt.Sprint(p.SucceededObligations), fmt.Sprint(p.FailedObligations),
+				p.ContractCompensation.String(), p.StorageRevenue.String(), p.UploadBandwidthRevenue.String(), p.DownloadBandwidthRevenue.String(),
+				p.AccountFunding.String(), p.TokenStorageRevenue.String(), p.LostRevenue.String(), p.LostStorageCollateral.String()})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			die("Could not write CSV:", err)
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Start Height\tEnd Height\tSucceeded\tFailed\tContract Compensation\tStorage\tUpload\tDownload\tAccount Funding\tIncl. Token Storage\tLost Revenue\tLost Collateral")
+	for _, p := range append(hfg.Periods, hfg.Total) {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", p.StartHeight, p.EndHeight, p.SucceededObligations, p.FailedObligations,
+			currencyUnits(p.ContractCompensation), currencyUnits(p.StorageRevenue), currencyUnits(p.UploadBandwidthRevenue), currencyUnits(p.DownloadBandwidthRevenue),
+			currencyUnits(p.AccountFunding), currencyUnits(p.TokenStorageRevenue), currencyUnits(p.LostRevenue), currencyUnits(p.LostStorageCollateral))
+	}
+	w.Flush()
+	fmt.Println("\nThe last row is the total. Token storage revenue is part of the storage and bandwidth revenue.")
 }
 
 // hostannouncecmd is the handler for the command `spc host announce`.
@@ -557,6 +739,16 @@ func hostfolderremovecmd(path string) {
 	fmt.Println("Removed folder", path)
 }
 
+// hostfoldermigratecmd migrates the data of a folder in the host to another
+// folder.
+func hostfoldermigratecmd(from, to string) {
+	err := httpClient.HostStorageFoldersMigratePost(abs(from), abs(to))
+	if err != nil {
+		die("Could not migrate folder:", err)
+	}
+	fmt.Printf("Started migrating folder %v to %v\n", from, to)
+}
+
 // hostfolderresizecmd resizes a folder in the host.
 func hostfolderresizecmd(path, newsize string) {
 	newsize, err := parseFilesize(newsize)
@@ -589,3 +781,181 @@ func hostsectordeletecmd(root string) {
 	}
 	fmt.Println("Deleted sector", root)
 }
+
+// printTokenStorageSnapshot prints information about the snapshot of the
+// token storage.
+func printTokenStorageSnapshot(snapshot modules.TokenStorageSnapshot) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if snapshot.Time.IsZero() {
+		fmt.Fprintln(w, "  Snapshot:\tnone")
+	} else {
+		fmt.Fprintf(w, "  Snapshot:\t%v\n", snapshot.Time.Format(time.RFC3339))
+		fmt.Fprintf(w, "  Checksum:\t%v\n", snapshot.Checksum)
+	}
+	fmt.Fprintf(w, "  Events in log:\t%v\n", snapshot.LogEvents)
+	fmt.Fprintf(w, "  Tokens:\t%v\n", snapshot.Tokens)
+	fmt.Fprintf(w, "  Sub-tokens:\t%v\n", snapshot.SubTokens)
+	if err := w.Flush(); err != nil {
+		die("failed to flush writer:", err)
+	}
+}
+
+// hosttokenscompactcmd compacts the events log of the token storage.
+func hosttokenscompactcmd() {
+	htg, err := httpClient.HostTokensCompactPost()
+	if err != nil {
+		die("Could not compact token storage:", err)
+	}
+	fmt.Println("Compacted token storage")
+	printTokenStorageSnapshot(htg.Snapshot)
+}
+
+// hosttokensverifycmd verifies the snapshot and the events log of the token
+// storage.
+func hosttokensverifycmd() {
+	htg, err := httpClient.HostTokensVerifyGet()
+	if err != nil {
+		die("Token storage verification failed:", err)
+	}
+	fmt.Println("Token storage is consistent")
+	printTokenStorageSnapshot(htg.Snapshot)
+}
+
+// parsePricingParam converts the value of a pricing policy setting into the
+// unit expected by the API.
+func parsePricingParam(param, value string) string {
+	switch param {
+	// currency/TB (convert to hastings/byte)
+	case "downloadbandwidthpricefloor", "downloadbandwidthpriceceiling", "uploadbandwidthpricefloor", "uploadbandwidthpriceceiling":
+		hastings, err := parseCurrency(value)
+		if err != nil {
+			die("Could not parse "+param+":", err)
+		}
+		i, _ := new(big.Int).SetString(hastings, 10)
+		return types.NewCurrency(i).Div(modules.BytesPerTerabyte).String()
+
+	// currency/TB/month (convert to hastings/byte/block)
+	case "storagepricefloor", "storagepriceceiling", "collateralfloor", "collateralceiling":
+		hastings, err := parseCurrency(value)
+		if err != nil {
+			die("Could not parse "+param+":", err)
+		}
+		i, _ := new(big.Int).SetString(hastings, 10)
+		return types.NewCurrency(i).Div(modules.BlockBytesPerMonthTerabyte).String()
+
+	// bool (allow "yes" and "no")
+	case "enabled":
+		switch strings.ToLower(value) {
+		case "yes":
+			return "true"
+		case "no":
+			return "false"
+		}
+		return value
+
+	// duration (convert to blocks)
+	case "formationwindow":
+		blocks, err := parsePeriod(value)
+		if err != nil {
+			die("Could not parse "+param+":", err)
+		}
+		return blocks
+
+	// other valid settings
+	case "targetutilization", "utilizationweight", "targetformationrate", "formationweight", "marketweight", "maxadjustment":
+		return value
+
+	// invalid settings
+	default:
+		die("\"" + param + "\" is not a pricing policy setting")
+	}
+	return value
+}
+
+// printPricingPrices prints a set of prices adjusted by the pricing policy.
+func printPricingPrices(w *tabwriter.Writer, name string, p modules.HostPricingPrices) {
+	fmt.Fprintf(w, "  %v\t%v / TB / Month\t%v / TB\t%v / TB\t%v / TB / Month\n", name,
+		currencyUnits(p.StoragePrice.Mul(modules.BlockBytesPerMonthTerabyte)),
+		currencyUnits(p.DownloadBandwidthPrice.Mul(modules.BytesPerTerabyte)),
+		currencyUnits(p.UploadBandwidthPrice.Mul(modules.BytesPerTerabyte)),
+		currencyUnits(p.Collateral.Mul(modules.BlockBytesPerMonthTerabyte)))
+}
+
+// printPricingPreview prints a pricing policy and the prices it would set.
+func printPricingPreview(policy modules.HostPricingPolicy, preview modules.HostPricingPreview) {
+	ceiling := func(c types.Currency, unit types.Currency, suffix string) string {
+		if c.IsZero() {
+			return "none"
+		}
+		return currencyUnits(c.Mul(unit)) + suffix
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Pricing Policy:")
+	fmt.Fprintf(w, "  Enabled:\t%v\n", yesNo(policy.Enabled))
+	fmt.Fprintf(w, "  Target Utilization:\t%.2f%% (weight %v)\n", policy.TargetUtilization*100, policy.UtilizationWeight)
+	fmt.Fprintf(w, "  Target Formation Rate:\t%v contracts / %v blocks (weight %v)\n", policy.TargetFormationRate, policy.FormationWindow, policy.FormationWeight)
+	fmt.Fprintf(w, "  Market Weight:\t%v\n", policy.MarketWeight)
+	fmt.Fprintf(w, "  Max Adjustment:\t%.2f%% / block\n", policy.MaxAdjustment*100)
+	fmt.Fprintf(w, "  Storage Price:\t%v - %v\n", currencyUnits(policy.StoragePriceFloor.Mul(modules.BlockBytesPerMonthTerabyte)), ceiling(policy.StoragePriceCeiling, modules.BlockBytesPerMonthTerabyte, " / TB / Month"))
+	fmt.Fprintf(w, "  Download Price:\t%v - %v\n", currencyUnits(policy.DownloadBandwidthPriceFloor.Mul(modules.BytesPerTerabyte)), ceiling(policy.DownloadBandwidthPriceCeiling, modules.BytesPerTerabyte, " / TB"))
+	fmt.Fprintf(w, "  Upload Price:\t%v - %v\n", currencyUnits(policy.UploadBandwidthPriceFloor.Mul(modules.BytesPerTerabyte)), ceiling(policy.UploadBandwidthPriceCeiling, modules.BytesPerTerabyte, " / TB"))
+	fmt.Fprintf(w, "  Collateral:\t%v - %v\n", currencyUnits(policy.CollateralFloor.Mul(modules.BlockBytesPerMonthTerabyte)), ceiling(policy.CollateralCeiling, modules.BlockBytesPerMonthTerabyte, " / TB / Month"))
+	if err := w.Flush(); err != nil {
+		die("failed to flush writer:", err)
+	}
+
+	fmt.Println()
+	fmt.Println("Pricing Inputs:")
+	if preview.Utilization < 0 {
+		fmt.Println("  Storage Utilization: no storage")
+	} else {
+		fmt.Printf("  Storage Utilization: %.2f%%\n", preview.Utilization*100)
+	}
+	fmt.Printf("  Recent Contracts:    %v\n", preview.RecentContracts)
+	fmt.Printf("  Market Samples:      %v hosts\n", preview.MarketSamples)
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  \tStorage\tDownload\tUpload\tCollateral")
+	printPricingPrices(w, "Current", preview.Current)
+	printPricingPrices(w, "Next Block", preview.Proposed)
+	if preview.MarketSamples > 0 {
+		printPricingPrices(w, "Network Median", preview.Market)
+	}
+	if err := w.Flush(); err != nil {
+		die("failed to flush writer:", err)
+	}
+}
+
+// hostpricingcmd is the handler for the command `spc host pricing`.
+func hostpricingcmd() {
+	hppg, err := httpClient.HostPricingPreviewGet(url.Values{})
+	if err != nil {
+		die("Could not get pricing policy:", err)
+	}
+	printPricingPreview(hppg.Policy, hppg.Preview)
+}
+
+// hostpricingconfigcmd is the handler for the command `spc host pricing
+// config [setting] [value]`.
+func hostpricingconfigcmd(param, value string) {
+	values := url.Values{}
+	values.Set(param, parsePricingParam(param, value))
+	err := httpClient.HostPricingPost(values)
+	if err != nil {
+		die("Failed to update pricing policy:", err)
+	}
+	fmt.Println("Pricing policy updated.")
+}
+
+// hostpricingpreviewcmd is the handler for the command `spc host pricing
+// preview [setting] [value]`.
+func hostpricingpreviewcmd(param, value string) {
+	values := url.Values{}
+	values.Set(param, parsePricingParam(param, value))
+	hppg, err := httpClient.HostPricingPreviewGet(values)
+	if err != nil {
+		die("Could not preview pricing policy:", err)
+	}
+	printPricingPreview(hppg.Policy, hppg.Preview)
+}
@@ -27,9 +27,22 @@ var (
 	daemonStackOutputFile string // The file that the stack trace will be written to
 
 	// Host Flags
-	hostContractOutputType string // output type for host contracts
-	hostFolderRemoveForce  bool   // force folder remove
-	hostVerbose            bool   // display additional host info
+	hostContractCursor        string // cursor of the host contracts page
+	hostContractLimit         uint64 // maximum number of host contracts to display
+	hostContractOutputType    string // output type for host contracts
+	hostContractRenter        string // public key of the renter of the host contracts
+	hostContractStatus        string // status of the host contracts
+	hostFinancialsCSV         bool   // print the host financials as CSV
+	hostFinancialsEndHeight   uint64 // end height of the host financials
+	hostFinancialsPeriod      uint64 // number of blocks per period of the host financials
+	hostFinancialsStartHeight uint64 // start height of the host financials
+	hostFolderRemoveForce     bool   // force folder remove
+	hostVerbose               bool   // display additional host info
+
+	// Pool Flags
+	poolBlocksLimit   int // maximum number of found blocks to display
+	poolBlocksOffset  int // number of most recent found blocks to skip
+	poolReconnectWait int // seconds miners wait before reconnecting
 
 	// Renter Flags
 	dataPieces                string // the number of data pieces a file should be uploaded with
@@ -39,6 +52,7 @@ var (
 	renterDownloadAsync       bool   // Downloads files asynchronously
 	renterDownloadRecursive   bool   // Downloads folders recursively.
 	renterFuseMountAllowOther bool   // Mount fuse with 'AllowOther' set to true.
+	renterFuseMountReadOnly   bool   // Mount fuse with 'ReadOnly' set to true.
 	renterListRecursive       bool   // List files of folder recursively.
 	renterListRoot            bool   // List path start from root instead of the UserFolder.
 	renterListVerbose         bool   // Show additional info about uploaded files.
@@ -71,9 +85,12 @@ var (
 	skykeyName            string // Name used to identify a Pubaccesskey.
 	skykeyRenameAs        string // Optional parameter to rename a Pubaccesskey while adding it.
 	skykeyShowPrivateKeys bool   // Set to true to show private key data.
+	skykeyShowPublicKey   bool   // Set to true to show the public key of a Pubaccesskey.
+	skykeySuccessorName   string // Name of the successor of a rotated Pubaccesskey.
 	skykeyType            string // Type used to create a new Pubaccesskey.
 
 	// Pubaccess Flags
+	skynetBlacklistHash  bool   // Treat the arguments as hashes of merkleroots instead of publinks.
 	skynetDownloadPortal string // Portal to use when trying to download a publink.
 	skynetLsRecursive    bool   // List files of folder recursively.
 	skynetLsRoot         bool   // Use root as the base instead of the Public access folder.
@@ -303,11 +320,21 @@ func initCmds() *cobra.Command {
 	gatewayBlocklistCmd.AddCommand(gatewayBlocklistAppendCmd, gatewayBlocklistClearCmd, gatewayBlocklistRemoveCmd, gatewayBlocklistSetCmd)
 
 	root.AddCommand(hostCmd)
-	hostCmd.AddCommand(hostAnnounceCmd, hostConfigCmd, hostContractCmd, hostFolderCmd, hostSectorCmd)
-	hostFolderCmd.AddCommand(hostFolderAddCmd, hostFolderRemoveCmd, hostFolderResizeCmd)
+	hostCmd.AddCommand(hostAnnounceCmd, hostConfigCmd, hostContractCmd, hostFinancialsCmd, hostFolderCmd, hostPricingCmd, hostSectorCmd, hostTokensCmd)
+	hostFolderCmd.AddCommand(hostFolderAddCmd, hostFolderMigrateCmd, hostFolderRemoveCmd, hostFolderResizeCmd)
+	hostPricingCmd.AddCommand(hostPricingConfigCmd, hostPricingPreviewCmd)
 	hostSectorCmd.AddCommand(hostSectorDeleteCmd)
+	hostTokensCmd.AddCommand(hostTokensCompactCmd, hostTokensVerifyCmd)
 	hostCmd.Flags().BoolVarP(&hostVerbose, "verbose", "v", false, "Display detailed host info")
 	hostContractCmd.Flags().StringVarP(&hostContractOutputType, "type", "t", "value", "Select output type")
+	hostContractCmd.Flags().StringVar(&hostContractCursor, "cursor", "", "Display the contracts after the cursor of a previous page")
+	hostContractCmd.Flags().Uint64Var(&hostContractLimit, "limit", 0, "Maximum number of contracts to display, 0 displays all")
+	hostContractCmd.Flags().StringVar(&hostContractRenter, "renter", "", "Only display the contracts of the renter with the public key")
+	hostContractCmd.Flags().StringVar(&hostContractStatus, "status", "", "Only display the contracts with the status (unresolved, rejected, succeeded, failed)")
+	hostFinancialsCmd.Flags().BoolVar(&hostFinancialsCSV, "csv", false, "Print the periods as CSV")
+	hostFinancialsCmd.Flags().Uint64Var(&hostFinancialsEndHeight, "endheight", 0, "Height of the block where the financials end, 0 is the current height")
+	hostFinancialsCmd.Flags().Uint64Var(&hostFinancialsPeriod, "period", 0, "Number of blocks per period, 0 shows the range as a single period")
+	hostFinancialsCmd.Flags().Uint64Var(&hostFinancialsStartHeight, "startheight", 0, "Height of the block where the financials begin")
 	hostFolderRemoveCmd.Flags().BoolVarP(&hostFolderRemoveForce, "force", "f", false, "Force the removal of the folder and its data")
 
 	root.AddCommand(hostdbCmd)
@@ -319,7 +346,10 @@ func initCmds() *cobra.Command {
 	minerCmd.AddCommand(minerStartCmd, minerStopCmd)
 
 	root.AddCommand(poolCmd)
-	poolCmd.AddCommand(poolConfigCmd, poolClientsCmd)
+	poolCmd.AddCommand(poolConfigCmd, poolClientsCmd, poolClientCmd, poolBlocksCmd, poolBlockCmd, poolPayoutsCmd, poolReconnectCmd, poolMessageCmd)
+	poolBlocksCmd.Flags().IntVar(&poolBlocksOffset, "offset", 0, "Number of most recent blocks to skip")
+	poolBlocksCmd.Flags().IntVar(&poolBlocksLimit, "limit", 50, "Maximum number of blocks to display")
+	poolReconnectCmd.Flags().IntVar(&poolReconnectWait, "wait", 0, "Seconds the miners wait before reconnecting")
 
 	root.AddCommand(renterCmd)
 	renterCmd.AddCommand(renterAllowanceCmd, renterBackupCreateCmd, renterBackupListCmd, renterBackupLoadCmd,
@@ -366,6 +396,7 @@ func initCmds() *cobra.Command {
 
 	renterFuseCmd.AddCommand(renterFuseMountCmd, renterFuseUnmountCmd)
 	renterFuseMountCmd.Flags().BoolVarP(&renterFuseMountAllowOther, "allow-other", "", false, "Allow users other than the user that mounted the fuse directory to access and use the fuse directory")
+	renterFuseMountCmd.Flags().BoolVarP(&renterFuseMountReadOnly, "read-only", "", false, "Mount the fuse directory in read-only mode")
 
 	root.AddCommand(skynetCmd)
 	skynetCmd.AddCommand(skynetBlacklistCmd, skynetConvertCmd, skynetDownloadCmd, skynetLsCmd, skynetPinCmd, skynetUnpinCmd, skynetUploadCmd)
@@ -379,17 +410,23 @@ func initCmds() *cobra.Command {
 	skynetLsCmd.Flags().BoolVarP(&skynetLsRecursive, "recursive", "R", false, "Recursively list pubfiles and folders")
 	skynetLsCmd.Flags().BoolVar(&skynetLsRoot, "root", false, "Use the root folder as the base instead of the pubaccess folder")
 	skynetPinCmd.Flags().StringVar(&skynetPinPortal, "portal", "", "Use specified Pubaccess portal to download the publink in order to pin the pubfile")
-	skynetBlacklistCmd.AddCommand(skynetBlacklistAddCmd, skynetBlacklistRemoveCmd)
+	skynetBlacklistCmd.AddCommand(skynetBlacklistAddCmd, skynetBlacklistRemoveCmd, skynetBlacklistSubscribeCmd, skynetBlacklistSubscriptionsCmd, skynetBlacklistUnsubscribeCmd)
+	skynetBlacklistAddCmd.Flags().BoolVar(&skynetBlacklistHash, "hash", false, "Add hashes of merkleroots instead of publinks")
+	skynetBlacklistRemoveCmd.Flags().BoolVar(&skynetBlacklistHash, "hash", false, "Remove hashes of merkleroots instead of publinks")
 
 	root.AddCommand(skykeyCmd)
-	skykeyCmd.AddCommand(skykeyAddCmd, skykeyCreateCmd, skykeyDeleteCmd, skykeyGetCmd, skykeyGetIDCmd, skykeyListCmd)
+	skykeyCmd.AddCommand(skykeyAddCmd, skykeyCreateCmd, skykeyDeleteCmd, skykeyGetCmd, skykeyGetIDCmd, skykeyListCmd, skykeyRotateCmd, skykeyRotationsCmd)
 	skykeyAddCmd.Flags().StringVar(&skykeyRenameAs, "rename-as", "", "The new name for the pubaccesskey being added")
 	skykeyCreateCmd.Flags().StringVar(&skykeyType, "type", "", "The type of the pubaccesskey")
 	skykeyDeleteCmd.Flags().StringVar(&skykeyName, "name", "", "The name of the pubaccesskey")
 	skykeyDeleteCmd.Flags().StringVar(&skykeyID, "id", "", "The base-64 encoded pubaccesskey ID")
 	skykeyGetCmd.Flags().StringVar(&skykeyName, "name", "", "The name of the pubaccesskey")
 	skykeyGetCmd.Flags().StringVar(&skykeyID, "id", "", "The base-64 encoded pubaccesskey ID")
+	skykeyGetCmd.Flags().BoolVar(&skykeyShowPublicKey, "public", false, "Show the public key of a x25519 pubaccesskey.")
 	skykeyListCmd.Flags().BoolVar(&skykeyShowPrivateKeys, "show-priv-keys", false, "Show private key data.")
+	skykeyRotateCmd.Flags().StringVar(&skykeyName, "name", "", "The name of the pubaccesskey")
+	skykeyRotateCmd.Flags().StringVar(&skykeyID, "id", "", "The base-64 encoded pubaccesskey ID")
+	skykeyRotateCmd.Flags().StringVar(&skykeySuccessorName, "successor-name", "", "The name of the successor of the pubaccesskey")
 
 	// Daemon Commands
 	root.AddCommand(alertsCmd, globalRatelimitCmd, stackCmd, stopCmd, updateCmd, versionCmd)
@@ -2,11 +2,16 @@ package main
 
 import (
 	"fmt"
+	"math/big"
+	"os"
 	"sort"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/EvilRedHorse/pubaccess-node/node/api"
+	"github.com/EvilRedHorse/pubaccess-node/types"
 )
 
 var (
@@ -31,6 +36,12 @@ Available settings:
     	operatorpercentage: What percentage of the block reward goes to the pool operator
 	operatorwallet:     Pool operator sia wallet address <required if percentage is not 0>
 	poolwallet:         Operating account for the pool <required>
+	payoutscheme:       "none", "pplns" or "prop"
+	pplnswindow:        PPLNS window as a multiple of the block difficulty
+	minimumpayout:      Balance a client needs before it gets paid
+
+The stratum TLS listener (tlsnetworkport, tlscertfile and tlskeyfile) can only
+be configured in the pool config file and takes effect after a restart.
  `,
 		Run: wrap(poolconfigcmd),
 	}
@@ -41,28 +52,49 @@ Available settings:
 		Run:   wrap(poolclientscmd),
 	}
 
-	/*
-		poolClientCmd = &cobra.Command{
-			Use:   "client <clientname>",
-			Short: "Get client details",
-			Long:  "Get client details by name",
-			Run:   wrap(poolclientcmd),
-		}
+	poolPayoutsCmd = &cobra.Command{
+		Use:   "payouts [clientname]",
+		Short: "List payouts",
+		Long:  "List the payouts of the pool, or of a single client if a client name is given",
+		Run:   poolpayoutscmd,
+	}
 
-		poolBlocksCmd = &cobra.Command{
-			Use:   "blocks",
-			Short: "Get blocks info",
-			Long:  "Get list of found blocks",
-			Run:   wrap(poolblockscmd),
-		}
+	poolClientCmd = &cobra.Command{
+		Use:   "client <clientname>",
+		Short: "Get client details",
+		Long:  "Get client details by name",
+		Run:   wrap(poolclientcmd),
+	}
 
-		poolBlockCmd = &cobra.Command{
-			Use:   "block <blocknum>",
-			Short: "Get block details",
-			Long:  "Get block specific details by block number",
-			Run:   wrap(poolblockcmd),
-		}
-	*/
+	poolBlocksCmd = &cobra.Command{
+		Use:   "blocks",
+		Short: "Get blocks info",
+		Long:  "Get list of found blocks, most recent first",
+		Run:   wrap(poolblockscmd),
+	}
+
+	poolBlockCmd = &cobra.Command{
+		Use:   "block <blocknum>",
+		Short: "Get block details",
+		Long:  "Get how the reward of a found block was split between the clients by block number",
+		Run:   wrap(poolblockcmd),
+	}
+
+	poolReconnectCmd = &cobra.Command{
+		Use:   "reconnect [host] [port]",
+		Short: "Reconnect the connected miners",
+		Long: `Ask the connected miners to reconnect to another pool instance, or to this
+instance if no host is given. Miners that don't support client.reconnect stay
+connected.`,
+		Run: poolreconnectcmd,
+	}
+
+	poolMessageCmd = &cobra.Command{
+		Use:   "message <message>",
+		Short: "Show a message to the connected miners",
+		Long:  "Show a message to the operators of the connected miners.",
+		Run:   wrap(poolmessagecmd),
+	}
 )
 
 // poolcmd is the handler for the command `spc pool`.
@@ -78,11 +110,21 @@ Pool config:
 Pool Name:              %s
 Pool ID:                %d
 Pool Stratum Port       %d
+Pool Stratum TLS Port   %d
+DB Driver               %s
 DB Connection           %s
 Pool Wallet:            %s
+
+Payout config:
+Payout Scheme:          %s
+PPLNS Window:           %v
+Operator Percentage:    %v%%
+Minimum Payout:         %s
 `,
-		config.Name, config.PoolID, config.NetworkPort,
-		config.DBConnection, config.PoolWallet)
+		config.Name, config.PoolID, config.NetworkPort, config.TLSNetworkPort,
+		config.DBDriver, config.DBConnection, config.PoolWallet,
+		config.PayoutScheme, config.PPLNSWindow, config.OperatorPercentage,
+		currencyUnits(config.MinimumPayout))
 }
 
 // poolconfigcmd is the handler for the command `spc pool config [parameter] [value]`
@@ -97,6 +139,13 @@ func poolconfigcmd(param, value string) {
 	case "dbconnection":
 	case "poolid":
 	case "poolwallet":
+	case "payoutscheme":
+	case "pplnswindow":
+	case "minimumpayout":
+		value, err = parseCurrency(value)
+		if err != nil {
+			die("Could not parse minimumpayout:", err)
+		}
 	default:
 		die("Unknown pool config parameter: ", param)
 	}
@@ -106,6 +155,8 @@ func poolconfigcmd(param, value string) {
 	}
 }
 
+// poolclientscmd is the handler for the command `spc pool clients`.
+// Prints the clients that are connected to the pool.
 func poolclientscmd() {
 	clients, err := httpClient.MiningPoolClientsGet()
 	if err != nil {
@@ -113,15 +164,44 @@ func poolclientscmd() {
 	}
 	fmt.Printf("Clients List:\n\n")
 	fmt.Printf("Number of Clients: %d\nNumber of Workers: %d\n\n", clients.NumberOfClients, clients.NumberOfWorkers)
-	fmt.Printf("Client Name                                                                  \n")
-	fmt.Printf("---------------------------------------------------------------------------- \n")
+	fmt.Printf("Client Name                                                                   Workers   Hashrate\n")
+	fmt.Printf("----------------------------------------------------------------------------  -------   ----------\n")
 	sort.Sort(ByClientName(clients.Clients))
 	for _, c := range clients.Clients {
-		fmt.Printf("% 76.76s \n", c.ClientName)
+		fmt.Printf("% -76.76s  % 7d   %s\n", c.ClientName, len(c.Workers), hashrateString(c.Hashrate))
+	}
+}
+
+// poolpayoutscmd is the handler for the command `spc pool payouts [clientname]`.
+// Prints the payouts of the pool.
+func poolpayoutscmd(cmd *cobra.Command, args []string) {
+	var name string
+	switch len(args) {
+	case 0:
+	case 1:
+		name = args[0]
+	default:
+		cmd.UsageFunc()(cmd)
+		os.Exit(exitCodeUsage)
 	}
+	pg, err := httpClient.MiningPoolPayoutsGet(name)
+	if err != nil {
+		die("Could not get pool payouts:", err)
+	}
+	if len(pg.Payouts) == 0 {
+		fmt.Println("No payouts.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Time\tClient\tAmount\tTransaction")
+	for _, p := range pg.Payouts {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", p.Time.Format(time.RFC822), p.ClientName, currencyUnits(p.Amount), p.TransactionID)
+	}
+	w.Flush()
 }
 
-/*
+// poolclientcmd is the handler for the command `spc pool client <clientname>`.
+// Prints the details of a client and its connected workers.
 func poolclientcmd(name string) {
 	client, err := httpClient.MiningPoolClientGet(name)
 	if err != nil {
@@ -130,40 +210,26 @@ func poolclientcmd(name string) {
 	reward := big.NewInt(0)
 	reward.SetString(client.Balance, 10)
 	currency := types.NewCurrency(reward)
-	fmt.Printf("\nClient Name: % 76.76s\nBlocks Mined: % -10d   Balance: %s\n\n", client.ClientName, client.BlocksMined, currencyUnits(currency))
+	fmt.Printf("\nClient Name: % 76.76s\nBlocks Mined: % -10d   Balance: %s   Hashrate: %s\n\n", client.ClientName, client.BlocksMined, currencyUnits(currency), hashrateString(client.Hashrate))
 	fmt.Printf("                    Per Current Block\n")
-	fmt.Printf("Worker Name         Work Diff  Shares   Share*Diff   Stale(%%) Invalid(%%)   Blocks Found   Last Share Time\n")
-	fmt.Printf("----------------    --------   -------   ---------   --------   --------       --------   ----------------\n")
+	fmt.Printf("Worker Name         Work Diff  Shares   Share*Diff   Stale(%%) Invalid(%%)   Blocks Found   Hashrate     Last Share Time\n")
+	fmt.Printf("----------------    --------   -------   ---------   --------   --------       --------   ----------   ----------------\n")
 	sort.Sort(ByWorkerName(client.Workers))
 	for _, w := range client.Workers {
 		var stale, invalid float64
-		if w.SharesThisBlock == 0 {
-			stale = 0.0
-			invalid = 0.0
-		} else {
-			stale = float64(w.StaleSharesThisBlock) / float64(w.SharesThisBlock) * 100.0
-			invalid = float64(w.InvalidSharesThisBlock) / float64(w.SharesThisBlock) * 100.0
+		submitted := w.SharesThisBlock + w.StaleSharesThisBlock + w.InvalidSharesThisBlock
+		if submitted != 0 {
+			stale = float64(w.StaleSharesThisBlock) / float64(submitted) * 100.0
+			invalid = float64(w.InvalidSharesThisBlock) / float64(submitted) * 100.0
 		}
-		fmt.Printf("% -16s    % 8.3f  % 8d    % 8d   % 8.3f   % 8.3f       % 8d  %v\n",
+		fmt.Printf("% -16s    % 8.3f  % 8d    % 8d   % 8.3f   % 8.3f       % 8d   %-10s  %v\n",
 			w.WorkerName, w.CurrentDifficulty, w.SharesThisBlock, uint64(w.CumulativeDifficulty),
-			stale, invalid, w.BlocksFound, shareTime(w.LastShareTime))
-	}
-
-	txs, err := httpClient.MiningPoolTransactionsGet(name)
-	if err != nil {
-		return
-	}
-	fmt.Printf("\nTransactions:\n")
-	fmt.Printf("%-19s    %-10s   %s\n", "Timestamp", "Change", "Memo")
-	fmt.Printf("-------------------    ----------   --------------------------------------------\n")
-	for _, t := range txs {
-		change := big.NewInt(0)
-		change.SetString(t.BalanceChange, 10)
-		currency := types.NewCurrency(change)
-		fmt.Printf("%-19s    %-10s   %s\n", t.TxTime.Format(time.RFC822), currencyUnits(currency), t.Memo)
+			stale, invalid, w.BlocksFound, hashrateString(w.Hashrate), shareTime(w.LastShareTime))
 	}
 }
 
+// shareTime returns a human readable description of the time of the last
+// share.
 func shareTime(t time.Time) string {
 	if t.IsZero() {
 		return " never"
@@ -183,51 +249,51 @@ func shareTime(t time.Time) string {
 	}
 }
 
+// hashrateString returns a hashrate with a unit suffix.
+func hashrateString(hashrate float64) string {
+	units := []string{"H/s", "KH/s", "MH/s", "GH/s", "TH/s", "PH/s"}
+	i := 0
+	for hashrate >= 1000 && i < len(units)-1 {
+		hashrate /= 1000
+		i++
+	}
+	return fmt.Sprintf("%.2f %s", hashrate, units[i])
+}
+
+// poolblockscmd is the handler for the command `spc pool blocks`.
+// Prints a page of the blocks found by the pool.
 func poolblockscmd() {
-	blocks, err := httpClient.MiningPoolBlocksGet()
+	blocks, err := httpClient.MiningPoolBlocksGet(poolBlocksOffset, poolBlocksLimit)
 	if err != nil {
 		die("Could not get pool blocks: ", err)
 	}
 	fmt.Printf("Blocks List:\n")
-	fmt.Printf("%-10s %-10s   %-19s   %-10s   %s\n", "Blocks", "Height", "Timestamp", "Reward", "Status")
-	fmt.Printf("---------- ----------   -------------------   ------   -------------------\n")
+	fmt.Printf("%-10s %-10s   %-19s   %-10s   %-13s   %s\n", "Blocks", "Height", "Timestamp", "Reward", "Confirmations", "Status")
+	fmt.Printf("---------- ----------   -------------------   ----------   -------------   -------------------\n")
 	for _, b := range blocks {
 		reward := big.NewInt(0)
 		reward.SetString(b.BlockReward, 10)
 		currency := types.NewCurrency(reward)
-		fmt.Printf("% 10d % 10d   %19s   %-10s   %s\n", b.BlockNumber, b.BlockHeight,
-			b.BlockTime.Format(time.RFC822), currencyUnits(currency), b.BlockStatus)
+		fmt.Printf("% 10d % 10d   %19s   %-10s   % 13d   %s\n", b.BlockNumber, b.BlockHeight,
+			b.BlockTime.Format(time.RFC822), currencyUnits(currency), b.Confirmations, b.BlockStatus)
 	}
 }
 
+// poolblockcmd is the handler for the command `spc pool block <blocknum>`.
+// Prints how the reward of a found block was split between the clients.
 func poolblockcmd(name string) {
-	blocks, err := httpClient.MiningPoolBlocksGet()
+	var blockNumber uint64
+	_, err := fmt.Sscan(name, &blockNumber)
 	if err != nil {
-		die("Could not get pool blocks: ", err)
-	}
-	var blockID uint64
-	var blocksInfo api.MiningPoolBlocksInfo
-	match := false
-	fmt.Sscanf(name, "%d", &blockID)
-	for _, blocksInfo = range blocks {
-		if blocksInfo.BlockNumber == blockID {
-			match = true
-			break
-		}
+		die("Could not parse block number:", err)
 	}
-	block, err := httpClient.MiningPoolBlockGet(name)
+	block, err := httpClient.MiningPoolBlockGet(blockNumber)
 	if err != nil {
 		die("Could not get pool block:", err)
 	}
-	if match == false {
-		fmt.Printf("Current Block\n\n")
-	} else {
-		reward := big.NewInt(0)
-		reward.SetString(blocksInfo.BlockReward, 10)
-		currency := types.NewCurrency(reward)
-		fmt.Printf("%-10s %-10s   %-19s   %-10s   %s\n", "Blocks", "Height", "Timestamp", "Reward", "Status")
-		fmt.Printf("%-10d %-10d   %-19s   %-10s   %s\n\n", blocksInfo.BlockNumber, blocksInfo.BlockHeight,
-			blocksInfo.BlockTime.Format(time.RFC822), currencyUnits(currency), blocksInfo.BlockStatus)
+	if len(block) == 0 {
+		fmt.Println("The reward of the block hasn't been credited to the clients.")
+		return
 	}
 
 	fmt.Printf("Client Name                                                                   Reward %% Block Reward\n")
@@ -239,7 +305,6 @@ func poolblockcmd(name string) {
 		fmt.Printf("%-76.76s %9.2f %12.12s\n", b.ClientName, b.ClientPercentage, currencyUnits(currency))
 	}
 }
-*/
 
 // ByClientName contains mining pool client info
 type ByClientName []api.MiningPoolClientInfo
@@ -254,3 +319,37 @@ type ByWorkerName []api.PoolWorkerInfo
 func (a ByWorkerName) Len() int           { return len(a) }
 func (a ByWorkerName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a ByWorkerName) Less(i, j int) bool { return a[i].WorkerName < a[j].WorkerName }
+
+// poolreconnectcmd is the handler for the command `spc pool reconnect [host] [port]`.
+// Asks the connected miners to reconnect.
+func poolreconnectcmd(cmd *cobra.Command, args []string) {
+	var host string
+	var port int
+	switch len(args) {
+	case 0:
+	case 2:
+		host = args[0]
+		_, err := fmt.Sscan(args[1], &port)
+		if err != nil {
+			die("Could not parse port:", err)
+		}
+	default:
+		cmd.UsageFunc()(cmd)
+		os.Exit(exitCodeUsage)
+	}
+	err := httpClient.MiningPoolReconnectPost(host, port, poolReconnectWait)
+	if err != nil {
+		die("Could not reconnect miners:", err)
+	}
+	fmt.Println("Asked the connected miners to reconnect.")
+}
+
+// poolmessagecmd is the handler for the command `spc pool message <message>`.
+// Shows a message to the connected miners.
+func poolmessagecmd(message string) {
+	err := httpClient.MiningPoolMessagePost(message)
+	if err != nil {
+		die("Could not send message:", err)
+	}
+	fmt.Println("Sent the message to the connected miners.")
+}
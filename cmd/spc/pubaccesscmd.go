@@ -12,6 +12,7 @@ import (
 	"strings"
 	"sync"
 	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/vbauerster/mpb/v5"
@@ -21,6 +22,7 @@ import (
 	"github.com/EvilRedHorse/pubaccess-node/modules"
 	"github.com/EvilRedHorse/pubaccess-node/modules/renter/filesystem"
 	"github.com/EvilRedHorse/pubaccess-node/pubaccesskey"
+	"github.com/EvilRedHorse/pubaccess-node/types"
 )
 
 var (
@@ -53,6 +55,29 @@ on top of ScPrime.`,
 		Run:   skynetblacklistremovecmd,
 	}
 
+	skynetBlacklistSubscribeCmd = &cobra.Command{
+		Use:   "subscribe [url] [publickey]",
+		Short: "Subscribe to a remote blacklist feed",
+		Long: `Subscribe to the blacklist feed at the given url. The feed is fetched periodically
+and its hashed merkleroots are added to the blacklist if the feed is signed by the
+given ed25519 public key.`,
+		Run: wrap(skynetblacklistsubscribecmd),
+	}
+
+	skynetBlacklistSubscriptionsCmd = &cobra.Command{
+		Use:   "subscriptions",
+		Short: "List the subscribed remote blacklist feeds",
+		Long:  "List the subscribed remote blacklist feeds and the state of their last update.",
+		Run:   wrap(skynetblacklistsubscriptionscmd),
+	}
+
+	skynetBlacklistUnsubscribeCmd = &cobra.Command{
+		Use:   "unsubscribe [url]",
+		Short: "Unsubscribe from a remote blacklist feed",
+		Long:  "Unsubscribe from the blacklist feed at the given url and remove its entries from the blacklist.",
+		Run:   wrap(skynetblacklistunsubscribecmd),
+	}
+
 	skynetConvertCmd = &cobra.Command{
 		Use:   "convert [source siaPath] [destination siaPath]",
 		Short: "Convert a siafile to a pubaccess file with a publink.",
@@ -67,8 +92,10 @@ on top of ScPrime.`,
 		Use:   "download [publink] [destination]",
 		Short: "Download a publink from Pubaccess.",
 		Long: `Download a public access file by using a publink. The download may fail unless this
-node is configured as a pubaccess portal. Use the --portal flag to fetch a publink
-file from a chosen pubaccess portal.`,
+node is configured as a pubaccess portal. If the node can't find the publink or times
+out fetching it, it is fetched from the healthiest known pubaccess portal instead.
+Blacklisted publinks are never fetched from a portal. Use the --portal flag
+to fetch a publink file from a chosen pubaccess portal.`,
 		Run: skynetdownloadcmd,
 	}
 
@@ -130,10 +157,14 @@ func skynetblacklistremovecmd(cmd *cobra.Command, args []string) {
 
 // skynetblacklistUpdate adds/removes trimmed publinks to the blacklist
 func skynetblacklistUpdate(additions, removals []string) {
-	additions = skynetblacklistTrimLinks(additions)
-	removals = skynetblacklistTrimLinks(removals)
-
-	err := httpClient.SkynetBlacklistPost(additions, removals)
+	var err error
+	if skynetBlacklistHash {
+		err = httpClient.SkynetBlacklistHashPost(additions, removals)
+	} else {
+		additions = skynetblacklistTrimLinks(additions)
+		removals = skynetblacklistTrimLinks(removals)
+		err = httpClient.SkynetBlacklistPost(additions, removals)
+	}
 	if err != nil {
 		die("Unable to update pubaccess blacklist:", err)
 	}
@@ -161,10 +192,61 @@ func skynetblacklistgetcmd(cmd *cobra.Command, args []string) {
 		die("Unable to get pubaccess blacklist:", err)
 	}
 
-	fmt.Printf("Listing %d blacklisted publink(s) merkleroots:\n", len(response.Blacklist))
-	for _, hash := range response.Blacklist {
-		fmt.Printf("\t%s\n", hash)
+	fmt.Printf("Listing %d blacklisted publink(s) merkleroots:\n", len(response.Entries))
+	for _, entry := range response.Entries {
+		fmt.Printf("\t%s\t%s\n", entry.Hash, strings.Join(entry.Sources, ", "))
+	}
+}
+
+// skynetblacklistsubscribecmd subscribes to a remote blacklist feed.
+func skynetblacklistsubscribecmd(feedURL, publicKey string) {
+	var spk types.SiaPublicKey
+	err := spk.LoadString(publicKey)
+	if err != nil {
+		die("Invalid public key, expected a key of the form 'ed25519:<hex>':", err)
+	}
+	sub := modules.SkynetBlacklistSubscription{
+		URL:       feedURL,
+		PublicKey: spk,
+	}
+	err = httpClient.SkynetBlacklistSubscriptionsPost([]modules.SkynetBlacklistSubscription{sub}, nil)
+	if err != nil {
+		die("Unable to subscribe to blacklist feed:", err)
+	}
+	fmt.Println("Subscribed to blacklist feed", feedURL)
+}
+
+// skynetblacklistsubscriptionscmd lists the subscribed remote blacklist feeds.
+func skynetblacklistsubscriptionscmd() {
+	response, err := httpClient.SkynetBlacklistSubscriptionsGet()
+	if err != nil {
+		die("Unable to get blacklist subscriptions:", err)
+	}
+	if len(response.Subscriptions) == 0 {
+		fmt.Println("No blacklist subscriptions")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "URL\tRevision\tEntries\tLast Update\tLast Error")
+	for _, sub := range response.Subscriptions {
+		lastUpdate := "never"
+		if !sub.LastUpdate.IsZero() {
+			lastUpdate = sub.LastUpdate.Format(time.RFC822)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", sub.URL, sub.Revision, sub.NumEntries, lastUpdate, sub.LastError)
+	}
+	if err := w.Flush(); err != nil {
+		die("failed to flush writer:", err)
+	}
+}
+
+// skynetblacklistunsubscribecmd unsubscribes from a remote blacklist feed.
+func skynetblacklistunsubscribecmd(feedURL string) {
+	err := httpClient.SkynetBlacklistSubscriptionsPost(nil, []string{feedURL})
+	if err != nil {
+		die("Unable to unsubscribe from blacklist feed:", err)
 	}
+	fmt.Println("Unsubscribed from blacklist feed", feedURL)
 }
 
 // skynetconvertcmd will convert an existing siafile to a pubfile and publink on
@@ -234,8 +316,9 @@ func skynetdownloadcmd(cmd *cobra.Command, args []string) {
 		}
 		reader = resp.Body
 	} else {
-		// Try to perform a download using the client package.
-		reader, err = httpClient.SkynetPublinkReaderGet(publink)
+		// Try to perform a download using the client package. If the node
+		// can't find the publink, fall back to the healthiest known portal.
+		reader, err = httpClient.SkynetPublinkReaderGetWithPortalFallback(publink)
 		if err != nil {
 			die("Unable to fetch publink:", errors.Compose(err, file.Close()))
 		}
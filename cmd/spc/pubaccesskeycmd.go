@@ -5,10 +5,12 @@ import (
 	"os"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"gitlab.com/NebulousLabs/errors"
+	"github.com/EvilRedHorse/pubaccess-node/node/api"
 	"github.com/EvilRedHorse/pubaccess-node/node/api/client"
 	"github.com/EvilRedHorse/pubaccess-node/pubaccesskey"
 )
@@ -41,7 +43,8 @@ var (
 		Use:   "create [name]",
 		Short: "Create a pubaccesskey with the given name.",
 		Long: `Create a pubaccesskey  with the given name. The --type flag can be
-		used to specify the pubaccesskey type. Its default is private-id.`,
+		used to specify the pubaccesskey type. Its default is private-id. The
+		passphrase of a password pubaccesskey is read from stdin.`,
 		Run: wrap(skykeycreatecmd),
 	}
 
@@ -55,8 +58,10 @@ var (
 	skykeyGetCmd = &cobra.Command{
 		Use:   "get",
 		Short: "Get the pubaccesskey by its name or id",
-		Long:  `Get the base64-encoded pubaccesskey using either its name with --name or id with --id`,
-		Run:   wrap(skykeygetcmd),
+		Long: `Get the base64-encoded pubaccesskey using either its name with --name or id with --id.
+Use with --public to get the public key of a x25519 pubaccesskey, which can be
+shared with others to allow them to encrypt pubfiles to you.`,
+		Run: wrap(skykeygetcmd),
 	}
 
 	skykeyGetIDCmd = &cobra.Command{
@@ -72,6 +77,25 @@ var (
 		Long:  "List all pubaccesskeys. Use with --show-priv-keys to show full encoding with private key also.",
 		Run:   wrap(skykeylistcmd),
 	}
+
+	skykeyRotateCmd = &cobra.Command{
+		Use:   "rotate [publink] ...",
+		Short: "Rotate the pubaccesskey by its name or id",
+		Long: `Rotate the pubaccesskey identified by either its name with --name or id
+with --id. The first rotation of a key creates a successor key of the same type,
+its name can be set with --successor-name and defaults to the name of the key
+followed by "-rotated". The pubfiles of the space separated publinks are
+re-encrypted with the successor and uploaded again, which results in new
+publinks. Publinks which were rotated before are skipped.`,
+		Run: skykeyrotatecmd,
+	}
+
+	skykeyRotationsCmd = &cobra.Command{
+		Use:   "rotations",
+		Short: "List all pubaccesskey rotations",
+		Long:  "List all pubaccesskey rotations and the publinks which were rotated.",
+		Run:   wrap(skykeyrotationscmd),
+	}
 )
 
 // skykeycmd displays the usage info for the command.
@@ -82,7 +106,21 @@ func skykeycmd(cmd *cobra.Command, args []string) {
 
 // skykeycreatecmd is a wrapper for skykeyCreate used to handle pubaccesskey creation.
 func skykeycreatecmd(name string) {
-	skykeyStr, err := skykeyCreate(httpClient, name, skykeyType)
+	var skykeyStr string
+	var err error
+	if skykeyType == pubaccesskey.TypePassword.ToString() {
+		var password string
+		password, err = passwordPrompt("Pubaccesskey password: ")
+		if err != nil {
+			die("Reading password failed:", err)
+		}
+		if err = confirmPassword(password); err != nil {
+			die(err)
+		}
+		skykeyStr, err = skykeyCreatePassword(httpClient, name, password)
+	} else {
+		skykeyStr, err = skykeyCreate(httpClient, name, skykeyType)
+	}
 	if err != nil {
 		die(errors.AddContext(err, "Failed to create new pubaccesskey"))
 	}
@@ -108,6 +146,16 @@ func skykeyCreate(c client.Client, name, skykeyTypeString string) (string, error
 	return sk.ToString()
 }
 
+// skykeyCreatePassword creates a new password Pubaccesskey with the given name
+// and password.
+func skykeyCreatePassword(c client.Client, name, password string) (string, error) {
+	sk, err := c.SkykeyCreatePasswordKeyPost(name, password)
+	if err != nil {
+		return "", errors.AddContext(err, "Could not create pubaccesskey")
+	}
+	return sk.ToString()
+}
+
 // skykeyaddcmd is a wrapper for skykeyAdd used to handle the addition of new pubaccesskeys.
 func skykeyaddcmd(skykeyString string) {
 	err := skykeyAdd(httpClient, skykeyString)
@@ -183,6 +231,12 @@ func skykeygetcmd() {
 	if err != nil {
 		die(err)
 	}
+	if skykeyShowPublicKey {
+		skykeyStr, err = skykeyPublicKey(skykeyStr)
+		if err != nil {
+			die(err)
+		}
+	}
 
 	fmt.Printf("Found pubaccesskey: %v\n", skykeyStr)
 }
@@ -213,6 +267,23 @@ func skykeyGet(c client.Client, name, id string) (string, error) {
 	return sk.ToString()
 }
 
+// skykeyPublicKey returns the public key of the encoded x25519 pubaccesskey.
+func skykeyPublicKey(skykeyStr string) (string, error) {
+	var sk pubaccesskey.Pubaccesskey
+	err := sk.FromString(skykeyStr)
+	if err != nil {
+		return "", errors.AddContext(err, "Could not decode pubaccesskey string")
+	}
+	if sk.Type != pubaccesskey.TypeX25519 {
+		return "", fmt.Errorf("only %v pubaccesskeys have a public key", pubaccesskey.TypeX25519.ToString())
+	}
+	pk, err := sk.PublicKey()
+	if err != nil {
+		return "", errors.AddContext(err, "Could not get public key of pubaccesskey")
+	}
+	return pk.ToString()
+}
+
 // skykeygetidcmd retrieves the pubaccesskey id using its name.
 func skykeygetidcmd(skykeyName string) {
 	sk, err := httpClient.SkykeyGetByName(skykeyName)
@@ -279,6 +350,94 @@ func skykeyListKeys(c client.Client, showPrivateKeys bool) (string, error) {
 	return b.String(), nil
 }
 
+// skykeyrotatecmd is a wrapper for skykeyRotate that handles pubaccesskey
+// rotate commands.
+func skykeyrotatecmd(_ *cobra.Command, args []string) {
+	rotation, err := skykeyRotate(httpClient, skykeyName, skykeyID, skykeySuccessorName, skynetblacklistTrimLinks(args))
+	if err != nil {
+		die(err)
+	}
+	fmt.Printf("Rotated pubaccesskey %v to %v (%v)\n", rotation.KeyID, rotation.Successor.ID, rotation.Successor.Name)
+	fmt.Printf("Successor pubaccesskey: %v\n", rotation.Successor.Pubaccesskey)
+	if len(rotation.Publinks) == 0 {
+		return
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Publink\tSuccessor")
+	for _, rp := range rotation.Publinks {
+		fmt.Fprintf(w, "%s\t%s\n", rp.Publink, rp.Successor)
+	}
+	if err := w.Flush(); err != nil {
+		die("failed to flush writer:", err)
+	}
+}
+
+// skykeyRotate rotates the pubaccesskey identified by the name or id flag and
+// re-encrypts the pubfiles of the publinks with its successor.
+func skykeyRotate(c client.Client, name, id, successorName string, publinks []string) (api.SkykeyRotationGET, error) {
+	err := validateNameAndIDUsage(name, id)
+	if err != nil {
+		return api.SkykeyRotationGET{}, errors.AddContext(err, "cannot validate pubaccesskey name and ID usage to rotate pubaccesskey")
+	}
+
+	var sk pubaccesskey.Pubaccesskey
+	if name != "" {
+		sk, err = c.SkykeyGetByName(name)
+	} else {
+		var pubaccesskeyID pubaccesskey.PubaccesskeyID
+		err = pubaccesskeyID.FromString(id)
+		if err != nil {
+			return api.SkykeyRotationGET{}, errors.AddContext(err, "Could not decode pubaccesskey ID")
+		}
+		sk, err = c.SkykeyGetByID(pubaccesskeyID)
+	}
+	if err != nil {
+		return api.SkykeyRotationGET{}, errors.AddContext(err, "Failed to retrieve pubaccesskey")
+	}
+
+	if successorName == "" {
+		successorName = sk.Name + "-rotated"
+	}
+	rotation, err := c.SkykeyRotateByIDPost(sk.ID(), successorName, publinks)
+	return rotation, errors.AddContext(err, "failed to rotate pubaccesskey")
+}
+
+// skykeyrotationscmd is a wrapper for skykeyListRotations that prints a list
+// of all pubaccesskey rotations.
+func skykeyrotationscmd() {
+	rotationsString, err := skykeyListRotations(httpClient)
+	if err != nil {
+		die("Failed to get pubaccesskey rotations:", err)
+	}
+	fmt.Print(rotationsString)
+}
+
+// skykeyListRotations returns a formatted string containing a list of all
+// pubaccesskey rotations and the publinks that were rotated.
+func skykeyListRotations(c client.Client) (string, error) {
+	rotations, err := c.SkykeyRotationsGet()
+	if err != nil {
+		return "", err
+	}
+	if len(rotations.Rotations) == 0 {
+		return "No pubaccesskeys were rotated.\n", nil
+	}
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for _, rotation := range rotations.Rotations {
+		fmt.Fprintf(w, "Pubaccesskey %v rotated to %v (%v) on %v\n", rotation.KeyID, rotation.Successor.ID, rotation.Successor.Name, rotation.CreatedAt.Format(time.RFC1123))
+		for _, rp := range rotation.Publinks {
+			fmt.Fprintf(w, "  %s\t%s\n", rp.Publink, rp.Successor)
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
 // validateNameAndIDUsage validates the usage of name and ID, ensuring that only
 // one is used.
 func validateNameAndIDUsage(name, id string) error {
@@ -37,6 +37,7 @@ func TestSkykeyCommands(t *testing.T) {
 		{name: "TestSkykeyListKeysDoesntShowPrivateKeys", test: testSkykeyListKeysDoesntShowPrivateKeys},
 		{name: "TestSkykeyListKeysAdditionalKeys", test: testSkykeyListKeysAdditionalKeys},
 		{name: "TestSkykeyListKeysAdditionalKeysDoesntShowPrivateKeys", test: testSkykeyListKeysAdditionalKeysDoesntShowPrivateKeys},
+		{name: "TestSkykeyRotate", test: testSkykeyRotate},
 	}
 
 	// Run tests
@@ -447,6 +448,52 @@ func testSkykeyListKeysAdditionalKeysDoesntShowPrivateKeys(t *testing.T, c clien
 }
 
 // initSkykeyData initializes keyStrings, keyNames, keyIDS slices with existing Pubaccesskey data
+// testSkykeyRotate tests that rotating a pubaccesskey creates its successor
+// once and that the rotation is listed.
+func testSkykeyRotate(t *testing.T, c client.Client) {
+	// Using neither or both the name and id should fail.
+	_, err := skykeyRotate(c, "", "", "", nil)
+	if !errors.Contains(err, errNeitherNameNorIDUsed) {
+		t.Fatal("Expected error when using neither name or id params", err)
+	}
+	_, err = skykeyRotate(c, "name", "id", "", nil)
+	if !errors.Contains(err, errBothNameAndIDUsed) {
+		t.Fatal("Expected error when using both name and id", err)
+	}
+
+	// Rotate a key by its name, the successor gets the default name.
+	keyName := "rotatekey"
+	_, err = skykeyCreate(c, keyName, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotation, err := skykeyRotate(c, keyName, "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotation.Successor.Name != keyName+"-rotated" {
+		t.Fatal("Unexpected successor name", rotation.Successor.Name)
+	}
+
+	// Rotating the key by its ID reuses the successor.
+	rotation2, err := skykeyRotate(c, "", rotation.KeyID, "other-successor", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotation2.Successor.ID != rotation.Successor.ID {
+		t.Fatal("Expected successor to be reused", rotation2.Successor.ID, rotation.Successor.ID)
+	}
+
+	// The rotation should be listed.
+	rotationsStr, err := skykeyListRotations(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rotationsStr, rotation.KeyID) || !strings.Contains(rotationsStr, rotation.Successor.ID) {
+		t.Fatal("Rotation not listed", rotationsStr)
+	}
+}
+
 func initSkykeyData(t *testing.T, c client.Client, keyStrings, keyNames, keyIDs []string) {
 	keyName1 := "createkey1"
 	keyName2 := "createkey testSkykeyGet"
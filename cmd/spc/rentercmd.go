@@ -156,10 +156,10 @@ var (
 		Use:   "mount [path] [siapath]",
 		Short: "Mount a folder on ScPrime network to your disk",
 		Long: `Mount a folder on ScPrime network to your disk. Applications will
-be able to see this folder as though it is a normal part of your filesystem.  
-Currently experimental, and read-only. When ScPrime is ready to support read-write 
-fuse mounting, spc will be updated to mount in read-write mode as the default. 
-If you must guarantee that read-only mode is used, you must use the API.`,
+be able to see this folder as though it is a normal part of your filesystem.
+Currently experimental. The folder is mounted in read-write mode by default,
+files written to the mount are staged locally and uploaded when they are
+closed. Use --read-only to mount the folder in read-only mode.`,
 		Run: wrap(renterfusemountcmd),
 	}
 
@@ -2345,10 +2345,6 @@ func renterfusecmd() {
 
 // renterfusemountcmd is the handler for the command `spc renter fuse mount [path] [siapath]`.
 func renterfusemountcmd(path, siaPathStr string) {
-	// TODO: Once read-write is supported on the backend, the 'true' flag can be
-	// set to 'false' - spc will support mounting read-write by default. Need
-	// to update the help string of the command to indicate that mounting will
-	// mount in read-write mode.
 	path = abs(path)
 	var siaPath modules.SiaPath
 	var err error
@@ -2361,7 +2357,7 @@ func renterfusemountcmd(path, siaPathStr string) {
 		}
 	}
 	opts := modules.MountOptions{
-		ReadOnly:   true,
+		ReadOnly:   renterFuseMountReadOnly,
 		AllowOther: renterFuseMountAllowOther,
 	}
 	err = httpClient.RenterFuseMount(path, siaPath, opts)
@@ -217,6 +217,7 @@ func startDaemon(config Config) (err error) {
 	// Add MiningPoolConfig previously read by readFileConfig(globalConfig) in
 	// startDaemonCmd(cmd *cobra.Command, _ []string).
 	nodeParams.PoolConfig = config.MiningPoolConfig
+	nodeParams.IndexConfig = config.IndexConfig
 
 	// Start and run the server.
 	srv, err := server.New(config.Spd.APIaddr, config.Spd.RequiredUserAgent, config.APIPassword, nodeParams, loadStart)
@@ -310,34 +311,38 @@ func readFileConfig(config Config) error {
 		poolViper.SetDefault("operatorpercentage", 0.0)
 		poolViper.SetDefault("operatorwallet", "")
 		poolViper.SetDefault("networkport", 3355)
+		poolViper.SetDefault("tlsnetworkport", 0)
 		poolViper.SetDefault("dbaddress", "127.0.0.1")
 		poolViper.SetDefault("dbname", "miningpool")
 		poolViper.SetDefault("dbport", "3306")
+		poolViper.SetDefault("dbdriver", fileConfig.DBDriverMySQL)
+		poolViper.SetDefault("payoutscheme", fileConfig.PayoutSchemeNone)
+		poolViper.SetDefault("pplnswindow", 2.0)
+		poolViper.SetDefault("minimumpayout", 10.0)
 		if !poolViper.IsSet("poolwallet") {
 			return errors.New("Must specify a poolwallet")
 		}
-		if !poolViper.IsSet("dbuser") {
-			return errors.New("Must specify a dbuser")
-		}
-		if !poolViper.IsSet("dbpass") {
-			return errors.New("Must specify a dbpass")
-		}
-		dbUser := poolViper.GetString("dbuser")
-		dbPass := poolViper.GetString("dbpass")
-		dbAddress := poolViper.GetString("dbaddress")
-		dbPort := poolViper.GetString("dbport")
-		dbName := poolViper.GetString("dbname")
-		dbConnection := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", dbUser, dbPass, dbAddress, dbPort, dbName)
-		if poolViper.IsSet("dbsocket") {
-			dbSocket := poolViper.GetString("dbsocket")
-			dbConnection = fmt.Sprintf("%s:%s@unix(%s)/%s", dbUser, dbPass, dbSocket, dbName)
+		dbDriver := poolViper.GetString("dbdriver")
+		dbConnection, err := readDBConnection(poolViper, dbDriver)
+		if err != nil {
+			return err
 		}
 		poolConfig := fileConfig.MiningPoolConfig{
 			PoolNetworkPort:  int(poolViper.GetInt("networkport")),
 			PoolName:         poolViper.GetString("name"),
 			PoolID:           uint64(poolViper.GetInt("id")),
+			PoolDBDriver:     dbDriver,
 			PoolDBConnection: dbConnection,
 			PoolWallet:       poolViper.GetString("poolwallet"),
+
+			PoolTLSNetworkPort: poolViper.GetInt("tlsnetworkport"),
+			PoolTLSCertFile:    poolViper.GetString("tlscertfile"),
+			PoolTLSKeyFile:     poolViper.GetString("tlskeyfile"),
+
+			PoolPayoutScheme:       poolViper.GetString("payoutscheme"),
+			PoolPPLNSWindow:        poolViper.GetFloat64("pplnswindow"),
+			PoolOperatorPercentage: poolViper.GetFloat64("operatorpercentage"),
+			PoolMinimumPayout:      poolViper.GetFloat64("minimumpayout"),
 		}
 		globalConfig.MiningPoolConfig = poolConfig
 	}
@@ -350,21 +355,44 @@ func readFileConfig(config Config) error {
 		poolViper.SetDefault("dbaddress", "127.0.0.1")
 		poolViper.SetDefault("dbname", "siablocks")
 		poolViper.SetDefault("dbport", "3306")
-		if !poolViper.IsSet("dbuser") {
-			return errors.New("Must specify a dbuser")
-		}
-		if !poolViper.IsSet("dbpass") {
-			return errors.New("Must specify a dbpass")
+		poolViper.SetDefault("dbdriver", fileConfig.DBDriverMySQL)
+		dbDriver := poolViper.GetString("dbdriver")
+		dbConnection, err := readDBConnection(poolViper, dbDriver)
+		if err != nil {
+			return err
 		}
-		dbUser := poolViper.GetString("dbuser")
-		dbPass := poolViper.GetString("dbpass")
-		dbAddress := poolViper.GetString("dbaddress")
-		dbPort := poolViper.GetString("dbport")
-		dbName := poolViper.GetString("dbname")
-		dbConnection := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", dbUser, dbPass, dbAddress, dbPort, dbName)
 		globalConfig.IndexConfig = fileConfig.IndexConfig{
+			PoolDBDriver:     dbDriver,
 			PoolDBConnection: dbConnection,
 		}
 	}
 	return nil
 }
+
+// readDBConnection builds the database connection string from a section of
+// the config file. The embedded bolt database doesn't need a connection.
+func readDBConnection(v *viper.Viper, dbDriver string) (string, error) {
+	switch dbDriver {
+	case fileConfig.DBDriverBolt:
+		return "", nil
+	case fileConfig.DBDriverMySQL:
+	default:
+		return "", fmt.Errorf("Unknown dbdriver %q, must be %q or %q", dbDriver, fileConfig.DBDriverMySQL, fileConfig.DBDriverBolt)
+	}
+	if !v.IsSet("dbuser") {
+		return "", errors.New("Must specify a dbuser")
+	}
+	if !v.IsSet("dbpass") {
+		return "", errors.New("Must specify a dbpass")
+	}
+	dbUser := v.GetString("dbuser")
+	dbPass := v.GetString("dbpass")
+	dbAddress := v.GetString("dbaddress")
+	dbPort := v.GetString("dbport")
+	dbName := v.GetString("dbname")
+	if v.IsSet("dbsocket") {
+		dbSocket := v.GetString("dbsocket")
+		return fmt.Sprintf("%s:%s@unix(%s)/%s", dbUser, dbPass, dbSocket, dbName), nil
+	}
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", dbUser, dbPass, dbAddress, dbPort, dbName), nil
+}
@@ -147,7 +147,16 @@ Stratum Miner (s):
 	for monitoring statistics and controlling the miner.
 	The stratum miner requires no other modules to run.
 	Example:
-		spd -M s`)
+		spd -M s
+Index (i):
+	The index follows the blockchain and imports every output, input and
+	transaction into a database. It can be queried for the balance and the
+	unspent outputs of an address. The database is configured in the 'index'
+	section of the config file, either MySQL or an embedded bolt database
+	can be used.
+	The index requires the gateway and consensus set.
+	Example:
+		spd -M gci`)
 }
 
 // main establishes a set of commands and flags using the cobra package.
@@ -42,6 +42,9 @@ func parseModules(config Config) node.NodeParams {
 	if strings.Contains(config.Spd.Modules, "s") {
 		params.CreateStratumMiner = true
 	}
+	if strings.Contains(config.Spd.Modules, "i") {
+		params.CreateIndex = true
+	}
 	// Parse remaining fields.
 	params.Bootstrap = !config.Spd.NoBootstrap
 	params.HostAddress = config.Spd.HostAddr
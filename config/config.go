@@ -1,15 +1,56 @@
 package config
 
+const (
+	// DBDriverMySQL stores the data of a module in an external MySQL
+	// database. It is the default driver.
+	DBDriverMySQL = "mysql"
+
+	// DBDriverBolt stores the data of a module in an embedded bolt database
+	// within the persist directory of the module.
+	DBDriverBolt = "bolt"
+)
+
+const (
+	// PayoutSchemeNone disables the payouts of the mining pool. The pool
+	// only records shares and found blocks, the payouts are left to external
+	// scripts reading the database. It is the default scheme.
+	PayoutSchemeNone = "none"
+
+	// PayoutSchemePPLNS pays the reward of a block to the last N shares
+	// before the block was found. N is the PPLNS window times the difficulty
+	// of the block.
+	PayoutSchemePPLNS = "pplns"
+
+	// PayoutSchemePROP pays the reward of a block to the shares of the round
+	// that ended with the block, which are all shares since the previous block
+	// of the pool.
+	PayoutSchemePROP = "prop"
+)
+
 // MiningPoolConfig is config for miningpool
 type MiningPoolConfig struct {
 	PoolNetworkPort  int
 	PoolName         string
 	PoolID           uint64
+	PoolDBDriver     string
 	PoolDBConnection string
 	PoolWallet       string
+
+	// Stratum over TLS settings. The TLS listener is disabled if the port is
+	// zero.
+	PoolTLSNetworkPort int
+	PoolTLSCertFile    string
+	PoolTLSKeyFile     string
+
+	// Payout settings.
+	PoolPayoutScheme       string
+	PoolPPLNSWindow        float64
+	PoolOperatorPercentage float64
+	PoolMinimumPayout      float64
 }
 
 // IndexConfig is config for index
 type IndexConfig struct {
+	PoolDBDriver     string
 	PoolDBConnection string
 }
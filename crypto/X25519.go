@@ -17,6 +17,11 @@ type (
 // GenerateX25519KeyPair generates an ephemeral key pair for use in ECDH.
 func GenerateX25519KeyPair() (xsk X25519SecretKey, xpk X25519PublicKey) {
 	fastrand.Read(xsk[:])
+	return xsk, xsk.PublicKey()
+}
+
+// PublicKey returns the public key of the X25519 secret key.
+func (xsk X25519SecretKey) PublicKey() (xpk X25519PublicKey) {
 	curve25519.ScalarBaseMult((*[32]byte)(&xpk), (*[32]byte)(&xsk))
 	return
 }
@@ -53,6 +53,9 @@ const (
 	// registered if the host has insufficient collateral budget left to form or
 	// renew a contract
 	AlertIDHostInsufficientCollateral = "host-insufficient-collateral"
+	// AlertIDHostCorruptSectors is the id of the alert that is registered
+	// when the host finds sectors whose data doesn't match their Merkle root.
+	AlertIDHostCorruptSectors = "host-corrupt-sectors"
 )
 
 // AlertIDSiafileLowRedundancy uses a Siafile's UID to create a unique AlertID
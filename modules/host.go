@@ -163,6 +163,22 @@ var (
 	// data.
 	DefaultUploadBandwidthPrice = types.ScPrimecoinPrecision.Mul64(2).Div(BytesPerTerabyte) // 2 SCP / TB
 
+	// DefaultHostPricingPolicy is the pricing policy of a new host. The policy
+	// is disabled by default, enabling it without any other changes moves the
+	// prices towards 75% storage utilization and the median of the network
+	// by at most 1% per block.
+	DefaultHostPricingPolicy = HostPricingPolicy{
+		TargetUtilization: 0.75,
+		UtilizationWeight: 0.5,
+
+		FormationWindow:     types.BlocksPerDay,
+		TargetFormationRate: 10,
+		FormationWeight:     0.1,
+
+		MarketWeight:  0.1,
+		MaxAdjustment: 0.01,
+	}
+
 	// CompatV1412DefaultEphemeralAccountExpiry defines the default account
 	// expiry used up until v1.4.12. This constant is added to ensure changing
 	// the default does not break legacy checks.
@@ -266,6 +282,39 @@ type (
 		UploadBandwidthRevenue            types.Currency `json:"uploadbandwidthrevenue"`
 	}
 
+	// HostFinancialPeriod contains the revenue and losses of the storage
+	// obligations that were resolved within a range of block heights. The
+	// range is inclusive.
+	HostFinancialPeriod struct {
+		StartHeight types.BlockHeight `json:"startheight"`
+		EndHeight   types.BlockHeight `json:"endheight"`
+
+		SucceededObligations uint64 `json:"succeededobligations"`
+		FailedObligations    uint64 `json:"failedobligations"`
+
+		// Revenue of the storage obligations that succeeded.
+		AccountFunding           types.Currency `json:"accountfunding"`
+		ContractCompensation     types.Currency `json:"contractcompensation"`
+		DownloadBandwidthRevenue types.Currency `json:"downloadbandwidthrevenue"`
+		StorageRevenue           types.Currency `json:"storagerevenue"`
+		UploadBandwidthRevenue   types.Currency `json:"uploadbandwidthrevenue"`
+
+		// TokenStorageRevenue is the part of the storage and bandwidth
+		// revenue that renters paid to top up tokens.
+		TokenStorageRevenue types.Currency `json:"tokenstoragerevenue"`
+
+		// Losses of the storage obligations that failed.
+		LostRevenue           types.Currency `json:"lostrevenue"`
+		LostStorageCollateral types.Currency `json:"loststoragecollateral"`
+	}
+
+	// HostFinancialLedger contains the financial periods of the host within a
+	// range of block heights, and their total.
+	HostFinancialLedger struct {
+		Periods []HostFinancialPeriod `json:"periods"`
+		Total   HostFinancialPeriod   `json:"total"`
+	}
+
 	// HostInternalSettings contains a list of settings that can be changed.
 	HostInternalSettings struct {
 		AcceptingContracts   bool              `json:"acceptingcontracts"`
@@ -292,6 +341,71 @@ type (
 		EphemeralAccountExpiry     time.Duration  `json:"ephemeralaccountexpiry"`
 		MaxEphemeralAccountBalance types.Currency `json:"maxephemeralaccountbalance"`
 		MaxEphemeralAccountRisk    types.Currency `json:"maxephemeralaccountrisk"`
+
+		MaxRegistryEntries uint64 `json:"maxregistryentries"`
+	}
+
+	// HostPricingPolicy configures the pricing engine of the host, which
+	// adjusts the storage price, the bandwidth prices and the collateral of
+	// the host on every block. The adjustment is based on the storage
+	// utilization of the host, the number of contracts formed recently and
+	// the median prices of the other hosts on the network. A zero ceiling
+	// means that the price isn't capped.
+	HostPricingPolicy struct {
+		Enabled bool `json:"enabled"`
+
+		// The storage and collateral prices rise if more than
+		// TargetUtilization of the storage is in use and fall if less is in
+		// use. UtilizationWeight scales the adjustment.
+		TargetUtilization float64 `json:"targetutilization"`
+		UtilizationWeight float64 `json:"utilizationweight"`
+
+		// All prices rise if more than TargetFormationRate contracts were
+		// formed in the last FormationWindow blocks and fall if fewer were
+		// formed. FormationWeight scales the adjustment.
+		FormationWindow     types.BlockHeight `json:"formationwindow"`
+		TargetFormationRate uint64            `json:"targetformationrate"`
+		FormationWeight     float64           `json:"formationweight"`
+
+		// MarketWeight is the share of the network median that is blended
+		// into the prices. MaxAdjustment is the largest relative change of a
+		// price per block.
+		MarketWeight  float64 `json:"marketweight"`
+		MaxAdjustment float64 `json:"maxadjustment"`
+
+		StoragePriceFloor             types.Currency `json:"storagepricefloor"`
+		StoragePriceCeiling           types.Currency `json:"storagepriceceiling"`
+		DownloadBandwidthPriceFloor   types.Currency `json:"downloadbandwidthpricefloor"`
+		DownloadBandwidthPriceCeiling types.Currency `json:"downloadbandwidthpriceceiling"`
+		UploadBandwidthPriceFloor     types.Currency `json:"uploadbandwidthpricefloor"`
+		UploadBandwidthPriceCeiling   types.Currency `json:"uploadbandwidthpriceceiling"`
+		CollateralFloor               types.Currency `json:"collateralfloor"`
+		CollateralCeiling             types.Currency `json:"collateralceiling"`
+	}
+
+	// HostPricingPrices are the prices that are adjusted by the pricing
+	// engine of the host.
+	HostPricingPrices struct {
+		StoragePrice           types.Currency `json:"storageprice"`
+		DownloadBandwidthPrice types.Currency `json:"downloadbandwidthprice"`
+		UploadBandwidthPrice   types.Currency `json:"uploadbandwidthprice"`
+		Collateral             types.Currency `json:"collateral"`
+	}
+
+	// HostPricingPreview contains the inputs of the pricing engine and the
+	// prices that it would set on the next block.
+	HostPricingPreview struct {
+		Utilization     float64 `json:"utilization"`
+		RecentContracts uint64  `json:"recentcontracts"`
+
+		// MarketSamples is the number of hosts the network median was
+		// computed from. The median is only used if there are enough
+		// samples.
+		MarketSamples int               `json:"marketsamples"`
+		Market        HostPricingPrices `json:"market"`
+
+		Current  HostPricingPrices `json:"current"`
+		Proposed HostPricingPrices `json:"proposed"`
 	}
 
 	// HostNetworkMetrics reports the quantity of each type of RPC call that
@@ -318,6 +432,7 @@ type (
 		PotentialDownloadRevenue types.Currency       `json:"potentialdownloadrevenue"`
 		PotentialStorageRevenue  types.Currency       `json:"potentialstoragerevenue"`
 		PotentialUploadRevenue   types.Currency       `json:"potentialuploadrevenue"`
+		RenterPublicKey          types.SiaPublicKey   `json:"renterpublickey"`
 		RiskedCollateral         types.Currency       `json:"riskedcollateral"`
 		SectorRootsCount         uint64               `json:"sectorrootscount"`
 		TransactionFeesAdded     types.Currency       `json:"transactionfeesadded"`
@@ -346,6 +461,72 @@ type (
 		MissedProofOutputs []types.SiacoinOutput `json:"missedproofoutputs"`
 	}
 
+	// StorageObligationQuery filters the storage obligations of the host. The
+	// zero value of a field matches all obligations. The matching obligations
+	// are sorted by their expiration height and returned in pages of up to
+	// Limit obligations, starting after Cursor.
+	StorageObligationQuery struct {
+		// Status matches the obligations with the status, e.g.
+		// "obligationUnresolved".
+		Status string `json:"status"`
+
+		// The expiration height range is inclusive, a max expiration height
+		// of 0 means that the range isn't capped.
+		MinExpirationHeight types.BlockHeight `json:"minexpirationheight"`
+		MaxExpirationHeight types.BlockHeight `json:"maxexpirationheight"`
+
+		RenterPublicKey types.SiaPublicKey `json:"renterpublickey"`
+
+		// The revenue range is inclusive, a max revenue of 0 means that the
+		// range isn't capped. The revenue of an obligation is its contract
+		// cost plus its potential storage, bandwidth and account funding
+		// revenue.
+		MinRevenue types.Currency `json:"minrevenue"`
+		MaxRevenue types.Currency `json:"maxrevenue"`
+
+		// Cursor is the NextCursor of the previous page, or empty for the
+		// first page. A limit of 0 returns all matching obligations.
+		Cursor string `json:"cursor"`
+		Limit  uint64 `json:"limit"`
+	}
+
+	// StorageObligationQueryResult is a page of the storage obligations
+	// matching a query, and the aggregates of all matching obligations.
+	StorageObligationQueryResult struct {
+		Obligations []StorageObligation `json:"obligations"`
+
+		// NextCursor is the cursor of the next page, it is empty if there
+		// are no more matching obligations.
+		NextCursor string `json:"nextcursor"`
+
+		Summary StorageObligationSummary `json:"summary"`
+	}
+
+	// StorageObligationSummary aggregates a set of storage obligations.
+	StorageObligationSummary struct {
+		Count                    uint64         `json:"count"`
+		DataSize                 uint64         `json:"datasize"`
+		ContractCost             types.Currency `json:"contractcost"`
+		LockedCollateral         types.Currency `json:"lockedcollateral"`
+		PotentialAccountFunding  types.Currency `json:"potentialaccountfunding"`
+		PotentialDownloadRevenue types.Currency `json:"potentialdownloadrevenue"`
+		PotentialStorageRevenue  types.Currency `json:"potentialstoragerevenue"`
+		PotentialUploadRevenue   types.Currency `json:"potentialuploadrevenue"`
+		RiskedCollateral         types.Currency `json:"riskedcollateral"`
+		Revenue                  types.Currency `json:"revenue"`
+	}
+
+	// TokenStorageSnapshot describes the snapshot of the token storage. The
+	// events in the log that follow the snapshot are replayed on startup.
+	// Time and Checksum are empty if the token storage was never compacted.
+	TokenStorageSnapshot struct {
+		Time      time.Time   `json:"time"`
+		Checksum  crypto.Hash `json:"checksum"`
+		LogEvents uint64      `json:"logevents"`
+		Tokens    uint64      `json:"tokens"`
+		SubTokens uint64      `json:"subtokens"`
+	}
+
 	// HostWorkingStatus reports the working state of a host. Can be one of
 	// "checking", "working", or "not working".
 	HostWorkingStatus string
@@ -398,6 +579,10 @@ type (
 
 		Announcement() []byte
 
+		// CompactTokenStorage writes a snapshot of the token storage and
+		// truncates its events log.
+		CompactTokenStorage() (TokenStorageSnapshot, error)
+
 		// ConnectabilityStatus returns the connectability status of the host,
 		// that is, if it can connect to itself on the configured NetAddress.
 		ConnectabilityStatus() HostConnectabilityStatus
@@ -420,6 +605,11 @@ type (
 		// FinancialMetrics returns the financial statistics of the host.
 		FinancialMetrics() HostFinancialMetrics
 
+		// FinancialLedger returns the revenue and losses of the storage
+		// obligations that were resolved between the heights, split into
+		// periods of the given number of blocks.
+		FinancialLedger(from, to, period types.BlockHeight) (HostFinancialLedger, error)
+
 		// InternalSettings returns the host's internal settings, including
 		// potentially private or sensitive information.
 		InternalSettings() HostInternalSettings
@@ -433,6 +623,17 @@ type (
 		// PublicKey returns the public key of the host.
 		PublicKey() types.SiaPublicKey
 
+		// PricingPolicy returns the pricing policy of the host.
+		PricingPolicy() HostPricingPolicy
+
+		// PricingPreview returns the prices the given pricing policy would
+		// set on the next block, without changing any settings.
+		PricingPreview(HostPricingPolicy) (HostPricingPreview, error)
+
+		// QueryStorageObligations returns a page of the storage obligations
+		// matching the query, sorted by their expiration height.
+		QueryStorageObligations(StorageObligationQuery) (StorageObligationQueryResult, error)
+
 		// ReadSector will read a sector from the host, returning the bytes that
 		// match the input sector root.
 		ReadSector(sectorRoot crypto.Hash) ([]byte, error)
@@ -463,6 +664,13 @@ type (
 		// storage folder.
 		ResetStorageFolderHealth(index uint16) error
 
+		// MigrateStorageFolder starts moving all sectors of the storage
+		// folder 'from' into the storage folder 'to' in the background. The
+		// sectors remain available for reads during the migration. If the
+		// destination runs out of space, the migration stops without losing
+		// any data.
+		MigrateStorageFolder(from, to uint16) error
+
 		// ResizeStorageFolder will grow or shrink a storage folder on the host.
 		// The host may not check that there is enough space on-disk to support
 		// growing the storage folder, but should gracefully handle running out
@@ -477,6 +685,9 @@ type (
 		// SetInternalSettings sets the hosting parameters of the host.
 		SetInternalSettings(HostInternalSettings) error
 
+		// SetPricingPolicy sets the pricing policy of the host.
+		SetPricingPolicy(HostPricingPolicy) error
+
 		// StorageObligation returns the storage obligation matching the id or
 		// an error if it does not exist
 		StorageObligation(obligationID types.FileContractID) (StorageObligation, error)
@@ -489,6 +700,11 @@ type (
 		// host.
 		StorageFolders() []StorageFolderMetadata
 
+		// VerifyTokenStorage checks the snapshot of the token storage and
+		// makes sure that replaying it with the events log results in the
+		// current state.
+		VerifyTokenStorage() (TokenStorageSnapshot, error)
+
 		// WorkingStatus returns the working state of the host, determined by if
 		// settings calls are increasing.
 		WorkingStatus() HostWorkingStatus
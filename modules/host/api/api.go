@@ -29,6 +29,9 @@ type TokenStorage interface {
 	RemoveSpecificSectors(id types.TokenID, sectorsIDs []crypto.Hash, time time.Time) error
 	AttachSectors(tokensSectors map[types.TokenID][]crypto.Hash, time time.Time) error
 	EnoughStorageResource(id types.TokenID, sectorsNum int64, now time.Time) (bool, error)
+	SetExpiration(id types.TokenID, expirationTime, now time.Time) (tokenstorage.TokenRecord, error)
+	Transfer(from, to types.TokenID, now time.Time) (tokenstorage.TokenRecord, error)
+	MintSubToken(parent types.TokenID, downloadBytes, sectorAccesses int64, expirationTime, now time.Time) (types.TokenID, tokenstorage.TokenRecord, error)
 }
 
 // Host represent host interface.
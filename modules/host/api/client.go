@@ -92,3 +92,30 @@ func (c *Client) AttachSectors(ctx context.Context, req *AttachSectorsRequest) (
 	}
 	return
 }
+
+func (c *Client) SetExpiration(ctx context.Context, req *SetExpirationRequest) (res *SetExpirationResponse, err error) {
+	res = &SetExpirationResponse{}
+	err = c.api2client.Call(ctx, res, req)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (c *Client) TransferToken(ctx context.Context, req *TransferTokenRequest) (res *TransferTokenResponse, err error) {
+	res = &TransferTokenResponse{}
+	err = c.api2client.Call(ctx, res, req)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (c *Client) MintSubToken(ctx context.Context, req *MintSubTokenRequest) (res *MintSubTokenResponse, err error) {
+	res = &MintSubTokenResponse{}
+	err = c.api2client.Call(ctx, res, req)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
 	"log"
 	"math/bits"
@@ -50,13 +51,24 @@ func (a *API) TokenResources(ctx context.Context, req *TokenResourcesRequest) (*
 		return nil, err
 	}
 
-	return &TokenResourcesResponse{
+	resp := &TokenResourcesResponse{
 		UploadBytes:    tr.UploadBytes,
 		DownloadBytes:  tr.DownloadBytes,
 		SectorAccesses: tr.SectorAccesses,
 		Storage:        tr.TokenStorageInfo.Storage,
 		LastChangeTime: tr.TokenStorageInfo.LastChangeTime,
-	}, nil
+		ExpirationTime: tr.ExpirationTime,
+		SubToken:       tr.SubToken,
+	}
+	if tr.RevertedResources != (tokenstorage.RevertedResources{}) {
+		resp.Reverted = &RevertedResources{
+			UploadBytes:    tr.RevertedResources.UploadBytes,
+			DownloadBytes:  tr.RevertedResources.DownloadBytes,
+			SectorAccesses: tr.RevertedResources.SectorAccesses,
+			Storage:        tr.RevertedResources.Storage,
+		}
+	}
+	return resp, nil
 }
 
 // DownloadWithToken handler for /download [POST] request.
@@ -79,6 +91,8 @@ func (a *API) DownloadWithToken(ctx context.Context, req *DownloadWithTokenReque
 			downloadWithTokenErr.NotEnoughBytes = true
 		} else if errors.Is(err, tokenstorage.ErrInsufficientSectorAccesses) {
 			downloadWithTokenErr.NotEnoughSectorAccesses = true
+		} else if errors.Is(err, tokenstorage.ErrTokenExpired) {
+			downloadWithTokenErr.TokenExpired = true
 		} else {
 			downloadWithTokenErr.UnknownError = err.Error()
 		}
@@ -122,6 +136,12 @@ func (a *API) UploadWithToken(ctx context.Context, req *UploadWithTokenRequest)
 	if err != nil {
 		return nil, &UploadWithTokenError{UnknownError: err.Error()}
 	}
+	if tr.SubToken {
+		return nil, &UploadWithTokenError{SubToken: true}
+	}
+	if tr.Expired(time.Now()) {
+		return nil, &UploadWithTokenError{TokenExpired: true, TokenRecord: toTokenRecord(tr)}
+	}
 	var totalBytes int64
 
 	sectorsByIDs := make(map[crypto.Hash][]byte, len(req.Sectors))
@@ -172,6 +192,8 @@ func (a *API) UploadWithToken(ctx context.Context, req *UploadWithTokenRequest)
 			return nil, &UploadWithTokenError{NotEnoughBytes: true, TokenRecord: toTokenRecord(tr)}
 		} else if errors.Is(err, tokenstorage.ErrInsufficientStorage) {
 			return nil, &UploadWithTokenError{NotEnoughStorage: true, TokenRecord: toTokenRecord(tr)}
+		} else if errors.Is(err, tokenstorage.ErrTokenExpired) {
+			return nil, &UploadWithTokenError{TokenExpired: true, TokenRecord: toTokenRecord(tr)}
 		}
 		return nil, &UploadWithTokenError{UnknownError: err.Error(), TokenRecord: toTokenRecord(tr)}
 	}
@@ -179,6 +201,64 @@ func (a *API) UploadWithToken(ctx context.Context, req *UploadWithTokenRequest)
 	return &UploadWithTokenResponse{TokenRecord: toTokenRecord(tr)}, nil
 }
 
+// toTokenError converts an error of token storage to TokenError.
+func toTokenError(err error) *TokenError {
+	switch {
+	case errors.Is(err, tokenstorage.ErrTokenNotFound):
+		return &TokenError{TokenNotFound: true}
+	case errors.Is(err, tokenstorage.ErrTokenExpired):
+		return &TokenError{TokenExpired: true}
+	case errors.Is(err, tokenstorage.ErrExpirationExtended):
+		return &TokenError{ExpirationExtended: true}
+	case errors.Is(err, tokenstorage.ErrSubTokenScope):
+		return &TokenError{SubToken: true}
+	case errors.Is(err, tokenstorage.ErrTransferToSameToken), errors.Is(err, tokenstorage.ErrInvalidSubTokenLimits):
+		return &TokenError{InvalidRequest: err.Error()}
+	}
+	return &TokenError{UnknownError: err.Error()}
+}
+
+// SetExpiration handler for /set-expiration [POST] request.
+func (a *API) SetExpiration(ctx context.Context, req *SetExpirationRequest) (*SetExpirationResponse, error) {
+	id := types.ParseToken(req.Authorization)
+	tr, err := a.ts.SetExpiration(id, req.ExpirationTime, time.Now())
+	if err != nil {
+		return nil, toTokenError(err)
+	}
+	return &SetExpirationResponse{TokenRecord: toTokenRecord(tr)}, nil
+}
+
+// TransferToken handler for /transfer [POST] request.
+func (a *API) TransferToken(ctx context.Context, req *TransferTokenRequest) (*TransferTokenResponse, error) {
+	// Unlike Authorization, the new token is validated strictly, since the
+	// resources would be lost if it was mistyped.
+	newTokenBytes, err := hex.DecodeString(req.NewToken)
+	var newToken types.TokenID
+	if err != nil || len(newTokenBytes) != len(newToken) {
+		return nil, &TokenError{InvalidRequest: "new token must be 16 hex encoded bytes"}
+	}
+	copy(newToken[:], newTokenBytes)
+	id := types.ParseToken(req.Authorization)
+	tr, err := a.ts.Transfer(id, newToken, time.Now())
+	if err != nil {
+		return nil, toTokenError(err)
+	}
+	return &TransferTokenResponse{TokenRecord: toTokenRecord(tr)}, nil
+}
+
+// MintSubToken handler for /mint-sub-token [POST] request.
+func (a *API) MintSubToken(ctx context.Context, req *MintSubTokenRequest) (*MintSubTokenResponse, error) {
+	id := types.ParseToken(req.Authorization)
+	subToken, tr, err := a.ts.MintSubToken(id, req.DownloadBytes, req.SectorAccesses, req.ExpirationTime, time.Now())
+	if err != nil {
+		return nil, toTokenError(err)
+	}
+	return &MintSubTokenResponse{
+		SubToken:    subToken.String(),
+		TokenRecord: toTokenRecord(tr),
+	}, nil
+}
+
 // Health is a handler for /health [GET] request.
 func (a *API) Health(ctx context.Context, req *HealthRequest) (*HealthResponse, error) {
 	return &HealthResponse{
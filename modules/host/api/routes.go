@@ -16,6 +16,9 @@ type HandlerHTTPapi interface {
 	DownloadWithToken(context.Context, *DownloadWithTokenRequest) (*DownloadWithTokenResponse, error)
 	UploadWithToken(context.Context, *UploadWithTokenRequest) (*UploadWithTokenResponse, error)
 	AttachSectors(context.Context, *AttachSectorsRequest) (*AttachSectorsResponse, error)
+	SetExpiration(context.Context, *SetExpirationRequest) (*SetExpirationResponse, error)
+	TransferToken(context.Context, *TransferTokenRequest) (*TransferTokenResponse, error)
+	MintSubToken(context.Context, *MintSubTokenRequest) (*MintSubTokenResponse, error)
 }
 
 // GetRoutes return api routes.
@@ -26,6 +29,7 @@ func GetRoutes(ol HandlerHTTPapi) []api2.Route {
 			"DownloadWithTokenError": &DownloadWithTokenError{},
 			"UploadWithTokenError":   &UploadWithTokenError{},
 			"AttachSectorsError":     &AttachSectorsError{},
+			"TokenError":             &TokenError{},
 		},
 	}
 
@@ -37,5 +41,8 @@ func GetRoutes(ol HandlerHTTPapi) []api2.Route {
 		{Method: http.MethodPost, Path: "/download", Handler: api2.Method(&ol, "DownloadWithToken"), Transport: t},
 		{Method: http.MethodPost, Path: "/upload", Handler: api2.Method(&ol, "UploadWithToken"), Transport: t},
 		{Method: http.MethodPost, Path: "/attach", Handler: api2.Method(&ol, "AttachSectors"), Transport: t},
+		{Method: http.MethodPost, Path: "/set-expiration", Handler: api2.Method(&ol, "SetExpiration"), Transport: t},
+		{Method: http.MethodPost, Path: "/transfer", Handler: api2.Method(&ol, "TransferToken"), Transport: t},
+		{Method: http.MethodPost, Path: "/mint-sub-token", Handler: api2.Method(&ol, "MintSubToken"), Transport: t},
 	}
 }
@@ -25,6 +25,8 @@ type TokenRecord struct {
 	UploadBytes    int64            `json:"upload_bytes"`
 	SectorAccesses int64            `json:"sector_accesses"`
 	TokenInfo      TokenStorageInfo `json:"token_info"`
+	ExpirationTime time.Time        `json:"expiration_time,omitempty"`
+	SubToken       bool             `json:"sub_token,omitempty"`
 }
 
 func toTokenRecord(record tokenstorage.TokenRecord) *TokenRecord {
@@ -37,6 +39,8 @@ func toTokenRecord(record tokenstorage.TokenRecord) *TokenRecord {
 			SectorsNum:     record.TokenStorageInfo.SectorsNum,
 			LastChangeTime: record.TokenStorageInfo.LastChangeTime,
 		},
+		ExpirationTime: record.ExpirationTime,
+		SubToken:       record.SubToken,
 	}
 }
 
@@ -66,13 +70,27 @@ type TokenResourcesRequest struct {
 	Authorization string `header:"Authorization"`
 }
 
+// RevertedResources represents resources that were removed from a token
+// because the contracts that paid for them were reverted.
+type RevertedResources struct {
+	UploadBytes    int64 `json:"upload_bytes,omitempty"`
+	DownloadBytes  int64 `json:"download_bytes,omitempty"`
+	SectorAccesses int64 `json:"sector_accesses,omitempty"`
+	Storage        int64 `json:"storage,omitempty"`
+}
+
 // TokenResourcesResponse represents response.
+// The resources are negative if they were spent before the contracts that
+// paid for them were reverted.
 type TokenResourcesResponse struct {
-	UploadBytes    int64     `json:"upload_bytes,omitempty"`
-	DownloadBytes  int64     `json:"download_bytes,omitempty"`
-	SectorAccesses int64     `json:"sector_accesses,omitempty"`
-	Storage        int64     `json:"storage,omitempty"`
-	LastChangeTime time.Time `json:"last_change_time,omitempty"`
+	UploadBytes    int64              `json:"upload_bytes,omitempty"`
+	DownloadBytes  int64              `json:"download_bytes,omitempty"`
+	SectorAccesses int64              `json:"sector_accesses,omitempty"`
+	Storage        int64              `json:"storage,omitempty"`
+	LastChangeTime time.Time          `json:"last_change_time,omitempty"`
+	Reverted       *RevertedResources `json:"reverted,omitempty"`
+	ExpirationTime time.Time          `json:"expiration_time,omitempty"`
+	SubToken       bool               `json:"sub_token,omitempty"`
 }
 
 // DownloadWithTokenError represent error message.
@@ -80,6 +98,7 @@ type DownloadWithTokenError struct {
 	NotEnoughSectorAccesses bool         `json:"not_enough_sector_accesses,omitempty"`
 	NotEnoughBytes          bool         `json:"not_enough_bytes,omitempty"`
 	NoSuchSector            *crypto.Hash `json:"no_such_sector,omitempty"`
+	TokenExpired            bool         `json:"token_expired,omitempty"`
 	UnknownError            string       `json:"unknown_error,omitempty"`
 }
 
@@ -87,6 +106,7 @@ var downloadWithTokenErrorTemplate = template.Must(template.New("error").Parse(`
 not enough sector accesses: {{.NotEnoughSectorAccesses}}
 not enough bytes: {{.NotEnoughBytes}}
 no such sector: {{.NoSuchSector}}
+token expired: {{.TokenExpired}}
 unknown error: {{.UnknownError}}
 `))
 
@@ -128,6 +148,8 @@ type UploadWithTokenError struct {
 	IncorrectSectorSize bool         `json:"incorrect_sector_size,omitempty"`
 	NotEnoughBytes      bool         `json:"not_enough_bytes,omitempty"`
 	NotEnoughStorage    bool         `json:"not_enough_storage,omitempty"`
+	TokenExpired        bool         `json:"token_expired,omitempty"`
+	SubToken            bool         `json:"sub_token,omitempty"`
 	UnknownError        string       `json:"unknown_error,omitempty"`
 	TokenRecord         *TokenRecord `json:"token_record,omitempty"`
 }
@@ -136,6 +158,8 @@ var uploadWithTokenErrorTemplate = template.Must(template.New("error").Parse(`
 incorrect sector size: {{.IncorrectSectorSize}}
 not enough bytes: {{.NotEnoughBytes}}
 not enough storage: {{.NotEnoughStorage}}
+token expired: {{.TokenExpired}}
+sub-token: {{.SubToken}}
 unknown error: {{.UnknownError}}
 Token Record:
 download bytes: {{.TokenRecord.DownloadBytes}}
@@ -209,6 +233,71 @@ func (e AttachSectorsError) Error() string {
 	return tpl.String()
 }
 
+// SetExpirationRequest represents request.
+type SetExpirationRequest struct {
+	Authorization  string    `header:"Authorization"`
+	ExpirationTime time.Time `json:"expiration_time"`
+}
+
+// SetExpirationResponse represents response.
+type SetExpirationResponse struct {
+	TokenRecord *TokenRecord `json:"token_record,omitempty"`
+}
+
+// TransferTokenRequest represents request.
+// NewToken is the hex encoded token that receives the resources.
+type TransferTokenRequest struct {
+	Authorization string `header:"Authorization"`
+	NewToken      string `json:"new_token"`
+}
+
+// TransferTokenResponse represents response.
+type TransferTokenResponse struct {
+	TokenRecord *TokenRecord `json:"token_record,omitempty"`
+}
+
+// MintSubTokenRequest represents request.
+// The sub-token can download at most DownloadBytes bytes. The number of
+// sector accesses is not limited if SectorAccesses is zero. The sub-token
+// expires with the parent token if ExpirationTime is zero.
+type MintSubTokenRequest struct {
+	Authorization  string    `header:"Authorization"`
+	DownloadBytes  int64     `json:"download_bytes"`
+	SectorAccesses int64     `json:"sector_accesses"`
+	ExpirationTime time.Time `json:"expiration_time"`
+}
+
+// MintSubTokenResponse represents response.
+type MintSubTokenResponse struct {
+	SubToken    string       `json:"sub_token"`
+	TokenRecord *TokenRecord `json:"token_record,omitempty"`
+}
+
+// TokenError represent error message of the requests managing tokens.
+type TokenError struct {
+	TokenNotFound      bool   `json:"token_not_found,omitempty"`
+	TokenExpired       bool   `json:"token_expired,omitempty"`
+	ExpirationExtended bool   `json:"expiration_extended,omitempty"`
+	SubToken           bool   `json:"sub_token,omitempty"`
+	InvalidRequest     string `json:"invalid_request,omitempty"`
+	UnknownError       string `json:"unknown_error,omitempty"`
+}
+
+var tokenErrorTemplate = template.Must(template.New("error").Parse(`
+token not found: {{.TokenNotFound}}
+token expired: {{.TokenExpired}}
+expiration extended: {{.ExpirationExtended}}
+sub-token: {{.SubToken}}
+invalid request: {{.InvalidRequest}}
+unknown error: {{.UnknownError}}
+`))
+
+func (e TokenError) Error() string {
+	var tpl bytes.Buffer
+	_ = tokenErrorTemplate.Execute(&tpl, e)
+	return tpl.String()
+}
+
 // HealthRequest is a request for /health endpoint.
 type HealthRequest struct {
 }
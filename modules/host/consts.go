@@ -74,6 +74,15 @@ var (
 	// prevent the host from having too much money at risk.
 	defaultMaxEphemeralAccountRisk = types.ScPrimecoinPrecision.Div64(100)
 
+	// defaultMaxRegistryEntries is the maximum number of entries the host
+	// stores in its registry. With entries of modules.RegistryEntrySize the
+	// standard default limits the registry to 256 MiB.
+	defaultMaxRegistryEntries = build.Select(build.Var{
+		Dev:      uint64(1 << 16),
+		Standard: uint64(1 << 20),
+		Testing:  uint64(1 << 10),
+	}).(uint64)
+
 	// logAllLimit is the number of errors of each type that the host will log
 	// before switching to probabilistic logging. If there are not many errors,
 	// it is reasonable that all errors get logged. If there are lots of
@@ -119,6 +128,14 @@ var (
 		Testing:  time.Millisecond,
 	}).(time.Duration)
 
+	// tokenStorageCompactionFrequency defines how frequently the host checks
+	// whether the events log of the token storage should be compacted.
+	tokenStorageCompactionFrequency = build.Select(build.Var{
+		Dev:      time.Minute * 10,
+		Standard: time.Hour * 6,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
 	// workingStatusFirstCheck defines how frequently the Host's working status
 	// check runs
 	workingStatusFirstCheck = build.Select(build.Var{
@@ -157,9 +174,45 @@ var (
 	// using the id.
 	bucketActionItems = []byte("BucketActionItems")
 
+	// bucketFinancialLedger maps a blockchain height to the serialized
+	// 'HostFinancialPeriod' of the storage obligations that were resolved at
+	// that height. The height is stored as a big endian uint64, so the
+	// entries are sorted by height.
+	bucketFinancialLedger = []byte("BucketFinancialLedger")
+
+	// bucketRegistry contains the serialized entries of the host's registry
+	// sorted by their entry id.
+	bucketRegistry = []byte("BucketRegistry")
+
+	// bucketRegistryExpiry indexes the registry entries by their expiry
+	// height. The keys are the expiry height as a big endian uint64 followed
+	// by the entry id, the values are empty.
+	bucketRegistryExpiry = []byte("BucketRegistryExpiry")
+
 	// bucketStorageObligations contains a set of serialized
 	// 'storageObligations' sorted by their file contract id.
 	bucketStorageObligations = []byte("BucketStorageObligations")
+
+	// bucketStorageObligationMetadata contains the serialized
+	// 'storageObligationMetadata' of the storage obligations sorted by their
+	// file contract id. The metadata holds the fields of the obligations that
+	// are filtered and aggregated by storage obligation queries.
+	bucketStorageObligationMetadata = []byte("BucketStorageObligationMetadata")
+
+	// bucketStorageObligationsByExpiration indexes the storage obligations by
+	// their expiration height. The keys are the expiration height as a big
+	// endian uint64 followed by the file contract id, the values are empty.
+	bucketStorageObligationsByExpiration = []byte("BucketStorageObligationsByExpiration")
+
+	// bucketStorageObligationsByRenter indexes the storage obligations by the
+	// public key of the renter. The keys are the hash of the public key
+	// followed by the key of the obligation in the expiration index.
+	bucketStorageObligationsByRenter = []byte("BucketStorageObligationsByRenter")
+
+	// bucketStorageObligationsByStatus indexes the storage obligations by
+	// their status. The keys are the status as a big endian uint64 followed by
+	// the key of the obligation in the expiration index.
+	bucketStorageObligationsByStatus = []byte("BucketStorageObligationsByStatus")
 )
 
 // init runs a series of sanity checks to verify that the constants have sane
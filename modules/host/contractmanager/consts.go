@@ -12,6 +12,10 @@ const (
 	// AlertMSGHostDiskTrouble indicates that one or multiple of a host's disks
 	// are encountering problems
 	AlertMSGHostDiskTrouble = "disk problem detected"
+
+	// AlertMSGHostCorruptSectors indicates that the sector scrubber found
+	// sectors whose data doesn't match their Merkle root anymore.
+	AlertMSGHostCorruptSectors = "corrupt sectors detected"
 )
 
 const (
@@ -53,6 +57,10 @@ const (
 	// a storageFolderGrow.
 	folderAllocationStepSize = 1 << 35
 
+	// maxCorruptSectorsInAlert is the maximum number of corrupt sectors that
+	// are listed in the cause of the corrupt sectors alert.
+	maxCorruptSectorsInAlert = 25
+
 	// maxSectorBatchThreads is the maximum number of threads updating
 	// sector counters on disk in AddSectorBatch and RemoveSectorBatch.
 	maxSectorBatchThreads = 64
@@ -143,3 +151,41 @@ var (
 		Testing:  time.Second * 8,
 	}).(time.Duration)
 )
+
+var (
+	// migrationIOBudget is the number of bytes per second that a storage
+	// folder migration is allowed to read and write. Every migrated sector
+	// is read once and written once.
+	migrationIOBudget = build.Select(build.Var{
+		Dev:      uint64(1 << 26), // 64 MiB/s
+		Standard: uint64(1 << 26), // 64 MiB/s
+		Testing:  uint64(1 << 24), // 16 MiB/s
+	}).(uint64)
+)
+
+var (
+	// scrubInitialDelay is the amount of time that the contract manager waits
+	// after startup before it starts scrubbing the storage folders.
+	scrubInitialDelay = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: time.Minute * 30,
+		Testing:  time.Second * 5,
+	}).(time.Duration)
+
+	// scrubInterval is the amount of time that the contract manager waits
+	// after scrubbing all storage folders before it starts the next pass.
+	scrubInterval = build.Select(build.Var{
+		Dev:      time.Minute * 10,
+		Standard: time.Hour * 24,
+		Testing:  time.Second * 10,
+	}).(time.Duration)
+
+	// scrubSectorInterval is the amount of time that the scrubber waits
+	// between verifying two sectors. It limits the disk bandwidth used by the
+	// scrubber to about 40 MiB/s on the production network.
+	scrubSectorInterval = build.Select(build.Var{
+		Dev:      time.Millisecond * 10,
+		Standard: time.Millisecond * 100,
+		Testing:  time.Millisecond,
+	}).(time.Duration)
+)
@@ -85,14 +85,22 @@ type ContractManager struct {
 	// including metadata about which sector slots are currently populated vs.
 	// which sector slots are available. For performance information, see
 	// BenchmarkStorageFolders.
+	//
+	// corruptSectors contains the sectors that the scrubber found to be
+	// corrupt, along with their location at the time they were scrubbed.
 	sectorSalt      crypto.Hash
 	sectorLocations map[sectorID]sectorLocation
 	storageFolders  map[uint16]*storageFolder
+	corruptSectors  map[sectorID]sectorLocation
 
 	// lockedSectors contains a list of sectors that are currently being read
 	// or modified.
 	lockedSectors map[sectorID]*sectorLock
 
+	// migrations contains the unfinished storage folder migrations, indexed
+	// by their source folder.
+	migrations map[uint16]storageFolderMigration
+
 	// Utilities.
 	dependencies  modules.Dependencies
 	staticAlerter *modules.GenericAlerter
@@ -113,8 +121,10 @@ func newContractManager(dependencies modules.Dependencies, persistDir string) (*
 	cm := &ContractManager{
 		storageFolders:  make(map[uint16]*storageFolder),
 		sectorLocations: make(map[sectorID]sectorLocation),
+		corruptSectors:  make(map[sectorID]sectorLocation),
 
 		lockedSectors: make(map[sectorID]*sectorLock),
+		migrations:    make(map[uint16]storageFolderMigration),
 
 		dependencies: dependencies,
 		persistDir:   persistDir,
@@ -211,10 +221,18 @@ func newContractManager(dependencies modules.Dependencies, persistDir string) (*
 		return nil, errors.AddContext(err, "error while spawning contract manager sync loop")
 	}
 
+	// Resume the storage folder migrations that were unfinished when the
+	// contract manager was shut down.
+	cm.resumeStorageFolderMigrations()
+
 	// Spin up the thread that continuously looks for missing storage folders
 	// and adds them if they are discovered.
 	go cm.threadedFolderRecheck()
 
+	// Spin up the thread that periodically verifies the sectors of all
+	// storage folders.
+	go cm.threadedScrubStorageFolders()
+
 	// Simulate an error to make sure the cleanup code is triggered correctly.
 	if cm.dependencies.Disrupt("erroredStartup") {
 		err = errors.New("startup disrupted")
@@ -0,0 +1,211 @@
+package contractmanager
+
+// scrub.go implements the background scrubbing of the storage folders. The
+// scrubber periodically reads every sector of every storage folder and checks
+// that the Merkle root of the data still matches the sector id, which finds
+// bit rot and other silent corruption before a storage proof fails. Only the
+// salted sector ids are known to the contract manager, so corrupt sectors are
+// reported by their id and location.
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+)
+
+var (
+	// errScrubSectorMoved is returned if a sector that is about to be scrubbed
+	// was moved or removed since the scrub pass started.
+	errScrubSectorMoved = errors.New("sector was moved or removed before it was scrubbed")
+)
+
+// scrubSector is a sector that is verified during a scrub pass.
+type scrubSector struct {
+	id    sectorID
+	index uint32
+}
+
+// threadedScrubStorageFolders periodically scrubs all storage folders of the
+// contract manager.
+func (cm *ContractManager) threadedScrubStorageFolders() {
+	// Don't spawn the loop if 'noScrub' disruption is set.
+	if cm.dependencies.Disrupt("noScrub") {
+		return
+	}
+
+	sleepTime := scrubInitialDelay
+	for {
+		// Check for shutdown.
+		select {
+		case <-cm.tg.StopChan():
+			return
+		case <-time.After(sleepTime):
+		}
+		sleepTime = scrubInterval
+
+		// Scrub the storage folders in the order of their indices.
+		cm.wal.mu.Lock()
+		indices := make([]uint16, 0, len(cm.storageFolders))
+		for index := range cm.storageFolders {
+			indices = append(indices, index)
+		}
+		cm.wal.mu.Unlock()
+		sort.Slice(indices, func(i, j int) bool {
+			return indices[i] < indices[j]
+		})
+		for _, index := range indices {
+			err := cm.managedScrubStorageFolder(index)
+			if err != nil {
+				cm.log.Printf("Unable to scrub storage folder %v: %v\n", index, err)
+			}
+		}
+	}
+}
+
+// managedScrubStorageFolder verifies all sectors of the storage folder with the
+// given index, recording any corrupt sectors.
+func (cm *ContractManager) managedScrubStorageFolder(index uint16) error {
+	// Collect the sectors of the storage folder, sorted by their location to
+	// read the sector file sequentially.
+	cm.wal.mu.Lock()
+	sf, exists := cm.storageFolders[index]
+	if !exists {
+		cm.wal.mu.Unlock()
+		return errStorageFolderNotFound
+	}
+	if atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+		cm.wal.mu.Unlock()
+		return errStorageFolderNotFound
+	}
+	var sectors []scrubSector
+	for id, sl := range cm.sectorLocations {
+		if sl.storageFolder == index {
+			sectors = append(sectors, scrubSector{id: id, index: sl.index})
+		}
+	}
+	cm.wal.mu.Unlock()
+	sort.Slice(sectors, func(i, j int) bool {
+		return sectors[i].index < sectors[j].index
+	})
+
+	atomic.StoreUint64(&sf.atomicScrubProgress, 0)
+	atomic.StoreUint64(&sf.atomicScrubTotal, uint64(len(sectors)))
+	corrupt := make(map[sectorID]struct{})
+	for _, s := range sectors {
+		// Rate limit the scrubbing and check for shutdown.
+		select {
+		case <-cm.tg.StopChan():
+			return nil
+		case <-time.After(scrubSectorInterval):
+		}
+
+		isCorrupt, err := cm.managedScrubSector(sf, s)
+		if err == nil && isCorrupt {
+			cm.log.Printf("WARN: sector %x at index %v of storage folder %v is corrupt\n", s.id, s.index, sf.path)
+			corrupt[s.id] = struct{}{}
+			cm.managedAddCorruptSector(s.id, sectorLocation{index: s.index, storageFolder: index})
+		}
+		atomic.AddUint64(&sf.atomicScrubProgress, 1)
+	}
+
+	// The pass is complete, forget about the corrupt sectors of the storage
+	// folder that weren't found again.
+	cm.wal.mu.Lock()
+	for id, cs := range cm.corruptSectors {
+		if _, found := corrupt[id]; cs.storageFolder == index && !found {
+			delete(cm.corruptSectors, id)
+		}
+	}
+	sf.lastScrub = time.Now()
+	cm.wal.mu.Unlock()
+	cm.managedUpdateCorruptSectorsAlert()
+	return nil
+}
+
+// managedScrubSector reads the sector from the storage folder and returns
+// whether its data is corrupt.
+func (cm *ContractManager) managedScrubSector(sf *storageFolder, s scrubSector) (bool, error) {
+	err := cm.tg.Add()
+	if err != nil {
+		return false, err
+	}
+	defer cm.tg.Done()
+	cm.wal.managedLockSector(s.id)
+	defer cm.wal.managedUnlockSector(s.id)
+
+	// Make sure that the sector is still stored at the same location.
+	cm.wal.mu.Lock()
+	sl, exists := cm.sectorLocations[s.id]
+	cm.wal.mu.Unlock()
+	if !exists || sl.storageFolder != sf.index || sl.index != s.index {
+		return false, errScrubSectorMoved
+	}
+
+	// Don't interfere with storage folder operations, the sector will be
+	// scrubbed in the next pass instead.
+	if !sf.mu.TryRLock() {
+		return false, errStorageFolderBusy
+	}
+	defer sf.mu.RUnlock()
+	if atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+		return false, errStorageFolderNotFound
+	}
+
+	sectorData, err := readSector(sf.sectorFile, s.index)
+	if err != nil {
+		atomic.AddUint64(&sf.atomicFailedReads, 1)
+		return false, err
+	}
+	atomic.AddUint64(&sf.atomicSuccessfulReads, 1)
+	return cm.managedSectorID(crypto.MerkleRoot(sectorData)) != s.id, nil
+}
+
+// managedAddCorruptSector records a corrupt sector and updates the alert of
+// the contract manager.
+func (cm *ContractManager) managedAddCorruptSector(id sectorID, location sectorLocation) {
+	cm.wal.mu.Lock()
+	cm.corruptSectors[id] = location
+	cm.wal.mu.Unlock()
+	cm.managedUpdateCorruptSectorsAlert()
+}
+
+// managedUpdateCorruptSectorsAlert registers an alert listing the corrupt
+// sectors, or unregisters it if there are none. Corrupt sectors that were
+// removed or moved since they were scrubbed are forgotten, moved sectors will
+// be verified again in the next pass.
+func (cm *ContractManager) managedUpdateCorruptSectorsAlert() {
+	cm.wal.mu.Lock()
+	var sectors []string
+	for id, cs := range cm.corruptSectors {
+		sl, exists := cm.sectorLocations[id]
+		if !exists || sl.storageFolder != cs.storageFolder || sl.index != cs.index {
+			delete(cm.corruptSectors, id)
+			continue
+		}
+		path := fmt.Sprint(cs.storageFolder)
+		if sf, exists := cm.storageFolders[cs.storageFolder]; exists {
+			path = sf.path
+		}
+		sectors = append(sectors, fmt.Sprintf("sector %x at index %v of storage folder %v", id, cs.index, path))
+	}
+	cm.wal.mu.Unlock()
+
+	if len(sectors) == 0 {
+		cm.staticAlerter.UnregisterAlert(modules.AlertIDHostCorruptSectors)
+		return
+	}
+	sort.Strings(sectors)
+	cause := fmt.Sprintf("%v corrupt sectors: ", len(sectors))
+	if len(sectors) > maxCorruptSectorsInAlert {
+		cause += strings.Join(sectors[:maxCorruptSectorsInAlert], ", ") + fmt.Sprintf(" and %v more", len(sectors)-maxCorruptSectorsInAlert)
+	} else {
+		cause += strings.Join(sectors, ", ")
+	}
+	cm.staticAlerter.RegisterAlert(modules.AlertIDHostCorruptSectors, AlertMSGHostCorruptSectors, cause, modules.SeverityCritical)
+}
@@ -0,0 +1,139 @@
+package contractmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+
+	"gitlab.com/NebulousLabs/fastrand"
+)
+
+// dependencyNoScrub prevents the scrub loop from running in the contract
+// manager.
+type dependencyNoScrub struct {
+	modules.ProductionDependencies
+}
+
+// Disrupt prevents the scrub loop from running in the contract manager.
+func (*dependencyNoScrub) Disrupt(s string) bool {
+	return s == "noScrub"
+}
+
+// corruptSectorsAlert returns the corrupt sectors alert of the contract
+// manager, if it is registered.
+func corruptSectorsAlert(cm *ContractManager) (modules.Alert, bool) {
+	crit, _, _ := cm.Alerts()
+	for _, alert := range crit {
+		if alert.Msg == AlertMSGHostCorruptSectors {
+			return alert, true
+		}
+	}
+	return modules.Alert{}, false
+}
+
+// TestScrubStorageFolder checks that scrubbing a storage folder finds corrupt
+// sectors and reports them through the alerts and the storage folder
+// metadata.
+func TestScrubStorageFolder(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newMockedContractManagerTester(&dependencyNoScrub{}, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add a storage folder with a few sectors.
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*storageFolderGranularity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	numSectors := 5
+	roots := make([]crypto.Hash, numSectors)
+	for i := range roots {
+		var data []byte
+		roots[i], data = randSector()
+		err = cmt.cm.AddSector(roots[i], data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	sfs := cmt.cm.StorageFolders()
+	if len(sfs) != 1 {
+		t.Fatal("expected one storage folder", len(sfs))
+	}
+	index := sfs[0].Index
+
+	// Scrubbing the storage folder shouldn't find any corrupt sectors.
+	err = cmt.cm.managedScrubStorageFolder(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfs = cmt.cm.StorageFolders()
+	if sfs[0].ScrubProgress != uint64(numSectors) || sfs[0].ScrubTotal != uint64(numSectors) {
+		t.Fatal("wrong scrub progress", sfs[0].ScrubProgress, sfs[0].ScrubTotal)
+	}
+	if sfs[0].CorruptSectors != 0 || sfs[0].LastScrubCompleted.IsZero() {
+		t.Fatal("wrong scrub results", sfs[0].CorruptSectors, sfs[0].LastScrubCompleted)
+	}
+	if _, exists := corruptSectorsAlert(cmt.cm); exists {
+		t.Fatal("alert shouldn't be registered")
+	}
+
+	// Corrupt one of the sectors on disk.
+	id := cmt.cm.managedSectorID(roots[0])
+	cmt.cm.wal.mu.Lock()
+	sl := cmt.cm.sectorLocations[id]
+	sf := cmt.cm.storageFolders[sl.storageFolder]
+	cmt.cm.wal.mu.Unlock()
+	_, err = sf.sectorFile.WriteAt(fastrand.Bytes(64), int64(uint64(sl.index)*modules.SectorSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Scrubbing the storage folder should find the corrupt sector.
+	err = cmt.cm.managedScrubStorageFolder(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfs = cmt.cm.StorageFolders()
+	if sfs[0].CorruptSectors != 1 {
+		t.Fatal("expected one corrupt sector", sfs[0].CorruptSectors)
+	}
+	alert, exists := corruptSectorsAlert(cmt.cm)
+	if !exists {
+		t.Fatal("alert should be registered")
+	}
+	if !strings.Contains(alert.Cause, storageFolderDir) || strings.Count(alert.Cause, "sector ") != 1 {
+		t.Fatal("wrong alert cause", alert.Cause)
+	}
+
+	// Removing the corrupt sector should unregister the alert on the next
+	// pass.
+	err = cmt.cm.RemoveSector(roots[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.managedScrubStorageFolder(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfs = cmt.cm.StorageFolders()
+	if sfs[0].CorruptSectors != 0 || sfs[0].ScrubTotal != uint64(numSectors-1) {
+		t.Fatal("wrong scrub results", sfs[0].CorruptSectors, sfs[0].ScrubTotal)
+	}
+	if _, exists := corruptSectorsAlert(cmt.cm); exists {
+		t.Fatal("alert shouldn't be registered")
+	}
+}
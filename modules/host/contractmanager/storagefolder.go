@@ -57,6 +57,10 @@ var (
 	// factor of 8 sectors.
 	errStorageFolderGranularity = fmt.Errorf("storage folder must be a factor of %v sectors", storageFolderGranularity)
 
+	// errStorageFolderBusy is returned if an operation can't be performed on a
+	// storage folder because another operation is modifying the folder.
+	errStorageFolderBusy = errors.New("storage folder is busy with another operation")
+
 	// errStorageFolderNotFolder is returned if a storage folder gets added
 	// that is not a folder.
 	errStorageFolderNotFolder = errors.New("must use an existing folder")
@@ -89,6 +93,10 @@ type storageFolder struct {
 	atomicSuccessfulReads  uint64
 	atomicSuccessfulWrites uint64
 
+	// Progress of the current scrub pass over the storage folder, in sectors.
+	atomicScrubProgress uint64
+	atomicScrubTotal    uint64
+
 	// Atomic bool indicating whether or not the storage folder is available. If
 	// the storage folder is not available, it will still be loaded but return
 	// an error if it is queried.
@@ -109,6 +117,10 @@ type storageFolder struct {
 	availableSectors map[sectorID]uint32
 	sectors          uint64
 
+	// lastScrub is the time at which the last complete scrub pass over the
+	// storage folder finished during this boot cycle.
+	lastScrub time.Time
+
 	// An open file handle is kept so that writes can easily be made to the
 	// storage folder without needing to grab a new file handle. This also
 	// makes it easy to do delayed-syncing.
@@ -377,10 +389,14 @@ func (cm *ContractManager) ResizeStorageFolder(index uint16, newSize uint64, for
 
 	cm.wal.mu.Lock()
 	sf, exists := cm.storageFolders[index]
+	migrating := cm.migrating(index)
 	cm.wal.mu.Unlock()
 	if !exists || atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
 		return errStorageFolderNotFound
 	}
+	if migrating {
+		return errStorageFolderMigrating
+	}
 
 	if newSize/modules.SectorSize < MinimumSectorsPerStorageFolder {
 		return ErrSmallStorageFolder
@@ -412,6 +428,12 @@ func (cm *ContractManager) StorageFolders() []modules.StorageFolderMetadata {
 	cm.wal.mu.Lock()
 	defer cm.wal.mu.Unlock()
 
+	// Count the corrupt sectors of each storage folder.
+	corruptSectors := make(map[uint16]uint64)
+	for _, cs := range cm.corruptSectors {
+		corruptSectors[cs.storageFolder]++
+	}
+
 	// Iterate over the storage folders that are in memory first, and then
 	// suppliment them with the storage folders that are not in memory.
 	var smfs []modules.StorageFolderMetadata
@@ -426,12 +448,23 @@ func (cm *ContractManager) StorageFolders() []modules.StorageFolderMetadata {
 			SuccessfulReads:  atomic.LoadUint64(&sf.atomicSuccessfulReads),
 			SuccessfulWrites: atomic.LoadUint64(&sf.atomicSuccessfulWrites),
 
+			ScrubProgress:      atomic.LoadUint64(&sf.atomicScrubProgress),
+			ScrubTotal:         atomic.LoadUint64(&sf.atomicScrubTotal),
+			CorruptSectors:     corruptSectors[sf.index],
+			LastScrubCompleted: sf.lastScrub,
+
 			Capacity:          modules.SectorSize * 64 * uint64(len(sf.usage)),
 			CapacityRemaining: ((64 * uint64(len(sf.usage))) - sf.sectors) * modules.SectorSize,
 			Index:             sf.index,
 			Path:              sf.path,
 		}
 
+		// Add the destination of an unfinished migration.
+		if m, exists := cm.migrations[sf.index]; exists {
+			sfm.Migrating = true
+			sfm.MigrationDestination = m.To
+		}
+
 		// Set some of the values to extreme numbers if the storage folder is
 		// unavailable, to flag the user's attention.
 		if atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
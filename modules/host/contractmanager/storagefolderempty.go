@@ -40,14 +40,6 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 	}
 	atomic.AddUint64(&oldFolder.atomicSuccessfulReads, 1)
 
-	// Create the sector update that will remove the old sector.
-	oldSU := sectorUpdate{
-		Count:  0,
-		ID:     id,
-		Folder: oldLocation.storageFolder,
-		Index:  oldLocation.index,
-	}
-
 	// Place the sector into its new folder and add the atomic move to the WAL.
 	wal.mu.Lock()
 	storageFolders := wal.cm.availableStorageFolders()
@@ -86,56 +78,7 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 			sf.setUsage(sectorIndex)
 			sf.availableSectors[id] = sectorIndex
 			wal.mu.Unlock()
-
-			// NOTE: The usage has been set, in the event of failure the usage
-			// must be cleared.
-
-			// Try writing the new sector to disk.
-			err = writeSector(sf.sectorFile, sectorIndex, sectorData)
-			if err != nil {
-				wal.cm.log.Printf("ERROR: Unable to write sector for folder %v: %v\n", sf.path, err)
-				atomic.AddUint64(&sf.atomicFailedWrites, 1)
-				wal.mu.Lock()
-				sf.clearUsage(sectorIndex)
-				delete(sf.availableSectors, id)
-				wal.mu.Unlock()
-				return errDiskTrouble
-			}
-
-			// Try writing the sector metadata to disk.
-			su := sectorUpdate{
-				Count:  oldLocation.count,
-				ID:     id,
-				Folder: sf.index,
-				Index:  sectorIndex,
-			}
-			err = wal.writeSectorMetadata(sf, su)
-			if err != nil {
-				wal.cm.log.Printf("ERROR: Unable to write sector metadata for folder %v: %v\n", sf.path, err)
-				atomic.AddUint64(&sf.atomicFailedWrites, 1)
-				wal.mu.Lock()
-				sf.clearUsage(sectorIndex)
-				delete(sf.availableSectors, id)
-				wal.mu.Unlock()
-				return errDiskTrouble
-			}
-
-			// Sector added successfully, update the WAL and the state.
-			sl := sectorLocation{
-				index:         sectorIndex,
-				storageFolder: sf.index,
-				count:         oldLocation.count,
-			}
-			wal.mu.Lock()
-			wal.appendChange(stateChange{
-				SectorUpdates: []sectorUpdate{oldSU, su},
-			})
-			oldFolder.clearUsage(oldLocation.index)
-			delete(wal.cm.sectorLocations, oldSU.ID)
-			delete(sf.availableSectors, id)
-			wal.cm.sectorLocations[id] = sl
-			wal.mu.Unlock()
-			return nil
+			return wal.managedWriteMovedSector(id, sectorData, oldLocation, oldFolder, sf, sectorIndex)
 		}()
 		if err != nil && err.Error() == modules.V1420HostOutOfStorageErrString {
 			return err
@@ -153,6 +96,68 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 	return nil
 }
 
+// managedWriteMovedSector writes a sector that is being moved into the
+// provided sector index of its new storage folder and updates the WAL and the
+// state to point to the new location. The usage of the new location has to be
+// set by the caller, and it will be cleared again if the sector can't be
+// written.
+func (wal *writeAheadLog) managedWriteMovedSector(id sectorID, sectorData []byte, oldLocation sectorLocation, oldFolder, sf *storageFolder, sectorIndex uint32) error {
+	// Create the sector update that will remove the old sector.
+	oldSU := sectorUpdate{
+		Count:  0,
+		ID:     id,
+		Folder: oldLocation.storageFolder,
+		Index:  oldLocation.index,
+	}
+
+	// Try writing the new sector to disk.
+	err := writeSector(sf.sectorFile, sectorIndex, sectorData)
+	if err != nil {
+		wal.cm.log.Printf("ERROR: Unable to write sector for folder %v: %v\n", sf.path, err)
+		atomic.AddUint64(&sf.atomicFailedWrites, 1)
+		wal.mu.Lock()
+		sf.clearUsage(sectorIndex)
+		delete(sf.availableSectors, id)
+		wal.mu.Unlock()
+		return errDiskTrouble
+	}
+
+	// Try writing the sector metadata to disk.
+	su := sectorUpdate{
+		Count:  oldLocation.count,
+		ID:     id,
+		Folder: sf.index,
+		Index:  sectorIndex,
+	}
+	err = wal.writeSectorMetadata(sf, su)
+	if err != nil {
+		wal.cm.log.Printf("ERROR: Unable to write sector metadata for folder %v: %v\n", sf.path, err)
+		atomic.AddUint64(&sf.atomicFailedWrites, 1)
+		wal.mu.Lock()
+		sf.clearUsage(sectorIndex)
+		delete(sf.availableSectors, id)
+		wal.mu.Unlock()
+		return errDiskTrouble
+	}
+
+	// Sector added successfully, update the WAL and the state.
+	sl := sectorLocation{
+		index:         sectorIndex,
+		storageFolder: sf.index,
+		count:         oldLocation.count,
+	}
+	wal.mu.Lock()
+	wal.appendChange(stateChange{
+		SectorUpdates: []sectorUpdate{oldSU, su},
+	})
+	oldFolder.clearUsage(oldLocation.index)
+	delete(wal.cm.sectorLocations, oldSU.ID)
+	delete(sf.availableSectors, id)
+	wal.cm.sectorLocations[id] = sl
+	wal.mu.Unlock()
+	return nil
+}
+
 // managedEmptyStorageFolder will empty out the storage folder with the
 // provided index starting with the 'startingPoint'th sector all the way to the
 // end of the storage folder, allowing the storage folder to be safely
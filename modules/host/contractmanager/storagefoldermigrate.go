@@ -0,0 +1,308 @@
+package contractmanager
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/EvilRedHorse/pubaccess-node/build"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+)
+
+var (
+	// errMigrationInsufficientCapacity is returned if a migration is started
+	// to a storage folder that doesn't have enough free space to hold the
+	// sectors of the source folder.
+	errMigrationInsufficientCapacity = errors.New("destination storage folder doesn't have enough free space for the sectors of the source folder")
+
+	// errMigrationDestinationFull is returned if the destination of a
+	// migration runs out of space while the migration is running.
+	errMigrationDestinationFull = errors.New("destination storage folder is full")
+
+	// errMigrationSameFolder is returned if a storage folder is migrated to
+	// itself.
+	errMigrationSameFolder = errors.New("cannot migrate a storage folder to itself")
+
+	// errStorageFolderMigrating is returned if a storage folder that is the
+	// source or destination of an unfinished migration is migrated, removed
+	// or resized.
+	errStorageFolderMigrating = errors.New("storage folder is part of an unfinished migration")
+)
+
+type (
+	// storageFolderMigration is a migration of all sectors of the storage
+	// folder with the index From into the storage folder with the index To.
+	storageFolderMigration struct {
+		From uint16
+		To   uint16
+	}
+)
+
+// findUnfinishedStorageFolderMigrations will scroll through a set of state
+// changes and figure out which of the storage folder migrations are still
+// unfinished.
+func findUnfinishedStorageFolderMigrations(scs []stateChange) []storageFolderMigration {
+	// Use a map to figure out what unfinished migrations exist and use it to
+	// remove the ones that have terminated.
+	sfmMap := make(map[uint16]storageFolderMigration)
+	for _, sc := range scs {
+		for _, sfm := range sc.StorageFolderMigrations {
+			sfmMap[sfm.From] = sfm
+		}
+		for _, index := range sc.FinishedStorageFolderMigrations {
+			delete(sfmMap, index)
+		}
+		for _, sfr := range sc.StorageFolderRemovals {
+			for from, sfm := range sfmMap {
+				if sfm.From == sfr.Index || sfm.To == sfr.Index {
+					delete(sfmMap, from)
+				}
+			}
+		}
+	}
+
+	// Return the unfinished migrations as a slice.
+	var sfms []storageFolderMigration
+	for _, sfm := range sfmMap {
+		sfms = append(sfms, sfm)
+	}
+	return sfms
+}
+
+// recoverStorageFolderMigrations loads the unfinished storage folder
+// migrations of the recovered WAL into the contract manager, so they can be
+// resumed after startup, and adds them to the new WAL.
+func (wal *writeAheadLog) recoverStorageFolderMigrations(scs []stateChange) {
+	sfms := findUnfinishedStorageFolderMigrations(scs)
+	if len(sfms) == 0 {
+		return
+	}
+	for _, sfm := range sfms {
+		wal.cm.migrations[sfm.From] = sfm
+	}
+	wal.appendChange(stateChange{
+		StorageFolderMigrations: sfms,
+	})
+}
+
+// resumeStorageFolderMigrations spawns threads for the migrations that were
+// recovered from the WAL.
+func (cm *ContractManager) resumeStorageFolderMigrations() {
+	cm.wal.mu.Lock()
+	defer cm.wal.mu.Unlock()
+	for _, sfm := range cm.migrations {
+		sf, exists := cm.storageFolders[sfm.From]
+		if !exists || atomic.LoadUint64(&sf.atomicUnavailable) == 1 || !sf.mu.TryLock() {
+			cm.wal.finishStorageFolderMigration(sfm)
+			cm.log.Printf("Unable to resume the migration of storage folder %v to %v\n", sfm.From, sfm.To)
+			continue
+		}
+		cm.log.Printf("Resuming the migration of storage folder %v to %v\n", sfm.From, sfm.To)
+		go cm.threadedMigrateStorageFolder(sfm, sf)
+	}
+}
+
+// migrating returns true if the storage folder with the provided index is the
+// source or destination of an unfinished migration.
+//
+// NOTE: the caller has to hold the WAL lock.
+func (cm *ContractManager) migrating(index uint16) bool {
+	for _, sfm := range cm.migrations {
+		if sfm.From == index || sfm.To == index {
+			return true
+		}
+	}
+	return false
+}
+
+// finishStorageFolderMigration marks a storage folder migration as finished.
+//
+// NOTE: the caller has to hold the WAL lock.
+func (wal *writeAheadLog) finishStorageFolderMigration(sfm storageFolderMigration) {
+	wal.appendChange(stateChange{
+		FinishedStorageFolderMigrations: []uint16{sfm.From},
+	})
+	delete(wal.cm.migrations, sfm.From)
+}
+
+// threadedMigrateStorageFolder moves all of the sectors of the source folder
+// of the migration into its destination. The source folder needs to be locked
+// by the caller, it will be unlocked when the migration is done. The
+// migration is finished once all sectors have been moved or an error
+// prevents moving the remaining sectors. If the contract manager shuts down
+// first, the migration is resumed at the next startup.
+func (cm *ContractManager) threadedMigrateStorageFolder(sfm storageFolderMigration, sf *storageFolder) {
+	defer sf.mu.Unlock()
+	defer atomic.StoreUint64(&sf.atomicProgressNumerator, 0)
+	defer atomic.StoreUint64(&sf.atomicProgressDenominator, 0)
+
+	// Collect the sectors of the source folder. No new sectors will be added
+	// to the folder while it is locked.
+	cm.wal.mu.Lock()
+	dest, exists := cm.storageFolders[sfm.To]
+	var sectors []sectorID
+	for id, sl := range cm.sectorLocations {
+		if sl.storageFolder == sfm.From {
+			sectors = append(sectors, id)
+		}
+	}
+	cm.wal.mu.Unlock()
+	atomic.StoreUint64(&sf.atomicProgressNumerator, 0)
+	atomic.StoreUint64(&sf.atomicProgressDenominator, uint64(len(sectors))*modules.SectorSize)
+
+	// Every sector is read once and written once, wait long enough between
+	// two sectors to stay within the I/O budget.
+	sectorInterval := time.Duration(2*modules.SectorSize) * time.Second / time.Duration(migrationIOBudget)
+	var err error
+	if !exists {
+		err = errStorageFolderNotFound
+	}
+	for i := 0; i < len(sectors) && err == nil; i++ {
+		select {
+		case <-cm.tg.StopChan():
+			// The migration will be resumed after the next startup.
+			return
+		case <-time.After(sectorInterval):
+		}
+		err = cm.wal.managedMigrateSector(sectors[i], sf, dest)
+		if err == errDiskTrouble {
+			cm.staticAlerter.RegisterAlert(modules.AlertIDHostDiskTrouble, AlertMSGHostDiskTrouble, "", modules.SeverityCritical)
+		}
+		if err == nil {
+			atomic.AddUint64(&sf.atomicProgressNumerator, modules.SectorSize)
+		}
+	}
+	if err != nil {
+		cm.log.Printf("ERROR: migration of storage folder %v to %v stopped: %v\n", sf.path, sfm.To, err)
+	} else {
+		cm.log.Printf("Migration of storage folder %v to %v completed\n", sf.path, sfm.To)
+	}
+
+	// Wait until the moved sectors are synced before marking the migration
+	// as finished.
+	cm.wal.mu.Lock()
+	syncChan := cm.wal.syncChan
+	cm.wal.mu.Unlock()
+	select {
+	case <-cm.tg.StopChan():
+		return
+	case <-syncChan:
+	}
+	cm.wal.mu.Lock()
+	cm.wal.finishStorageFolderMigration(sfm)
+	cm.wal.mu.Unlock()
+}
+
+// managedMigrateSector moves a sector from the source folder of a migration
+// into its destination. Sectors that were removed from the source folder in
+// the meantime are skipped.
+func (wal *writeAheadLog) managedMigrateSector(id sectorID, oldFolder, sf *storageFolder) error {
+	err := wal.cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer wal.cm.tg.Done()
+	wal.managedLockSector(id)
+	defer wal.managedUnlockSector(id)
+
+	// Find the sector to be moved.
+	wal.mu.Lock()
+	oldLocation, exists := wal.cm.sectorLocations[id]
+	wal.mu.Unlock()
+	if !exists || oldLocation.storageFolder != oldFolder.index {
+		return nil
+	}
+	if atomic.LoadUint64(&oldFolder.atomicUnavailable) == 1 {
+		return errStorageFolderNotFound
+	}
+
+	// Read the sector data from disk so that it can be added to the
+	// destination.
+	sectorData, err := readSector(oldFolder.sectorFile, oldLocation.index)
+	if err != nil {
+		atomic.AddUint64(&oldFolder.atomicFailedReads, 1)
+		return build.ExtendErr("unable to read sector selected for migration", err)
+	}
+	atomic.AddUint64(&oldFolder.atomicSuccessfulReads, 1)
+
+	// Grab a sector in the destination. The destination can't be migrated
+	// into while it is being resized or removed.
+	if !sf.mu.TryRLock() {
+		return errStorageFolderBusy
+	}
+	defer sf.mu.RUnlock()
+	if atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+		return errStorageFolderNotFound
+	}
+	wal.mu.Lock()
+	if sf.sectors >= uint64(len(sf.usage))*storageFolderGranularity {
+		wal.mu.Unlock()
+		return errMigrationDestinationFull
+	}
+	sectorIndex, err := randFreeSector(sf.usage)
+	if err != nil {
+		wal.mu.Unlock()
+		return errMigrationDestinationFull
+	}
+	// Set the usage, but mark it as uncommitted.
+	sf.setUsage(sectorIndex)
+	sf.availableSectors[id] = sectorIndex
+	wal.mu.Unlock()
+	return wal.managedWriteMovedSector(id, sectorData, oldLocation, oldFolder, sf, sectorIndex)
+}
+
+// MigrateStorageFolder starts moving all sectors of the storage folder 'from'
+// into the storage folder 'to'. The migration runs in the background, sectors
+// can still be read while they are being moved, and no new sectors are added
+// to the source folder until the migration is done. The migration is recorded
+// in the WAL and resumed after a restart. If the destination runs out of
+// space, the migration is stopped and the remaining sectors stay in the source
+// folder.
+func (cm *ContractManager) MigrateStorageFolder(from, to uint16) error {
+	err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cm.tg.Done()
+	if from == to {
+		return errMigrationSameFolder
+	}
+
+	cm.wal.mu.Lock()
+	sf, exists1 := cm.storageFolders[from]
+	dest, exists2 := cm.storageFolders[to]
+	if !exists1 || !exists2 || atomic.LoadUint64(&sf.atomicUnavailable) == 1 || atomic.LoadUint64(&dest.atomicUnavailable) == 1 {
+		cm.wal.mu.Unlock()
+		return errStorageFolderNotFound
+	}
+	if cm.migrating(from) || cm.migrating(to) {
+		cm.wal.mu.Unlock()
+		return errStorageFolderMigrating
+	}
+	if uint64(len(dest.usage))*storageFolderGranularity-dest.sectors < sf.sectors {
+		cm.wal.mu.Unlock()
+		return errMigrationInsufficientCapacity
+	}
+
+	// Lock the source folder for the duration of the migration, which
+	// prevents new sectors from being added to it.
+	if !sf.mu.TryLock() {
+		cm.wal.mu.Unlock()
+		return errStorageFolderBusy
+	}
+	sfm := storageFolderMigration{
+		From: from,
+		To:   to,
+	}
+	cm.wal.appendChange(stateChange{
+		StorageFolderMigrations: []storageFolderMigration{sfm},
+	})
+	cm.migrations[from] = sfm
+	syncChan := cm.wal.syncChan
+	cm.wal.mu.Unlock()
+
+	// Wait until the migration is recorded in the WAL before starting it.
+	<-syncChan
+	go cm.threadedMigrateStorageFolder(sfm, sf)
+	return nil
+}
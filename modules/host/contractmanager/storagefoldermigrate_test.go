@@ -0,0 +1,288 @@
+package contractmanager
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/EvilRedHorse/pubaccess-node/build"
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+)
+
+// addMigrationTestFolders adds a storage folder to the contract manager
+// tester, fills it with sectors and adds a second, empty storage folder. The
+// indices of the folders are returned along with the sectors.
+func addMigrationTestFolders(cmt *contractManagerTester, numSectors int) (uint16, uint16, map[crypto.Hash][]byte, error) {
+	dirOne := filepath.Join(cmt.persistDir, "storageFolderOne")
+	dirTwo := filepath.Join(cmt.persistDir, "storageFolderTwo")
+	for _, dir := range []string{dirOne, dirTwo} {
+		err := os.MkdirAll(dir, 0700)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+	}
+	err := cmt.cm.AddStorageFolder(dirOne, modules.SectorSize*storageFolderGranularity)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	sectors := make(map[crypto.Hash][]byte)
+	for i := 0; i < numSectors; i++ {
+		root, data := randSector()
+		err = cmt.cm.AddSector(root, data)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		sectors[root] = data
+	}
+	err = cmt.cm.AddStorageFolder(dirTwo, modules.SectorSize*storageFolderGranularity)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	var from, to uint16
+	for _, sf := range cmt.cm.StorageFolders() {
+		if sf.Path == dirOne {
+			from = sf.Index
+		} else {
+			to = sf.Index
+		}
+	}
+	return from, to, sectors, nil
+}
+
+// checkMigrationCompleted checks that all sectors were moved from the source
+// folder to the destination and that they can still be read.
+func checkMigrationCompleted(cm *ContractManager, from, to uint16, sectors map[crypto.Hash][]byte) error {
+	for _, sf := range cm.StorageFolders() {
+		if sf.Migrating {
+			return errors.New("migration is still in progress")
+		}
+		used := (sf.Capacity - sf.CapacityRemaining) / modules.SectorSize
+		if sf.Index == from && used != 0 {
+			return errors.New("source folder isn't empty")
+		}
+		if sf.Index == to && used != uint64(len(sectors)) {
+			return errors.New("destination doesn't contain all sectors")
+		}
+	}
+	for root, data := range sectors {
+		readData, err := cm.ReadSector(root)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(readData, data) {
+			return errors.New("sector data doesn't match after migration")
+		}
+	}
+	return nil
+}
+
+// TestMigrateStorageFolder checks that all sectors of a storage folder are
+// moved to the destination of a migration.
+func TestMigrateStorageFolder(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	from, to, sectors, err := addMigrationTestFolders(cmt, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check the invalid migrations.
+	if err := cmt.cm.MigrateStorageFolder(from, from); err != errMigrationSameFolder {
+		t.Fatal("expected errMigrationSameFolder", err)
+	}
+	if err := cmt.cm.MigrateStorageFolder(from, to+from+1); err != errStorageFolderNotFound {
+		t.Fatal("expected errStorageFolderNotFound", err)
+	}
+
+	// Migrate the sectors.
+	err = cmt.cm.MigrateStorageFolder(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		return checkMigrationCompleted(cmt.cm, from, to, sectors)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The emptied folder can be removed without losing sectors.
+	err = cmt.cm.RemoveStorageFolder(from, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for root := range sectors {
+		if !cmt.cm.HasSector(root) {
+			t.Fatal("sector was lost")
+		}
+	}
+}
+
+// TestMigrateStorageFolderInsufficientCapacity checks that a migration to a
+// storage folder without enough space isn't started.
+func TestMigrateStorageFolderInsufficientCapacity(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	from, to, _, err := addMigrationTestFolders(cmt, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fill the destination.
+	for i := 0; i < int(storageFolderGranularity)-5; i++ {
+		root, data := randSector()
+		err = cmt.cm.AddSector(root, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = cmt.cm.MigrateStorageFolder(from, to)
+	if err != errMigrationInsufficientCapacity {
+		t.Fatal("expected errMigrationInsufficientCapacity", err)
+	}
+}
+
+// TestMigrateStorageFolderResume checks that an unfinished migration that was
+// recorded in the WAL is resumed after a restart.
+func TestMigrateStorageFolderResume(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	from, to, sectors, err := addMigrationTestFolders(cmt, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Record the migration in the WAL without starting it, as if the
+	// contract manager was shut down right after the migration started.
+	sfm := storageFolderMigration{From: from, To: to}
+	cmt.cm.wal.mu.Lock()
+	cmt.cm.wal.appendChange(stateChange{
+		StorageFolderMigrations: []storageFolderMigration{sfm},
+	})
+	cmt.cm.migrations[from] = sfm
+	syncChan := cmt.cm.wal.syncChan
+	cmt.cm.wal.mu.Unlock()
+	<-syncChan
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Restart the contract manager, the migration should be resumed.
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		return checkMigrationCompleted(cmt.cm, from, to, sectors)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The finished migration shouldn't be resumed again.
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm.wal.mu.Lock()
+	migrations := len(cmt.cm.migrations)
+	cmt.cm.wal.mu.Unlock()
+	if migrations != 0 {
+		t.Fatal("finished migration was resumed", migrations)
+	}
+	err = checkMigrationCompleted(cmt.cm, from, to, sectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRemoveStorageFolderMigrating checks that the source and destination of
+// an unfinished migration can't be removed or resized.
+func TestRemoveStorageFolderMigrating(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	from, to, sectors, err := addMigrationTestFolders(cmt, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Record the migration without starting it, so that it stays unfinished
+	// for the duration of the test.
+	sfm := storageFolderMigration{From: from, To: to}
+	cmt.cm.wal.mu.Lock()
+	cmt.cm.migrations[from] = sfm
+	cmt.cm.wal.mu.Unlock()
+
+	for _, index := range []uint16{from, to} {
+		err = cmt.cm.RemoveStorageFolder(index, false)
+		if err != errStorageFolderMigrating {
+			t.Fatal("expected errStorageFolderMigrating", err)
+		}
+		err = cmt.cm.RemoveStorageFolder(index, true)
+		if err != errStorageFolderMigrating {
+			t.Fatal("expected errStorageFolderMigrating", err)
+		}
+		err = cmt.cm.ResizeStorageFolder(index, modules.SectorSize*storageFolderGranularity*2, false)
+		if err != errStorageFolderMigrating {
+			t.Fatal("expected errStorageFolderMigrating", err)
+		}
+	}
+	if len(cmt.cm.StorageFolders()) != 2 {
+		t.Fatal("storage folder was removed during a migration")
+	}
+	for root := range sectors {
+		if !cmt.cm.HasSector(root) {
+			t.Fatal("sector was lost")
+		}
+	}
+
+	// Once the migration is finished the folders can be removed again.
+	cmt.cm.wal.mu.Lock()
+	cmt.cm.wal.finishStorageFolderMigration(sfm)
+	cmt.cm.wal.mu.Unlock()
+	err = cmt.cm.RemoveStorageFolder(to, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -61,6 +61,12 @@ func (cm *ContractManager) RemoveStorageFolder(index uint16, force bool) error {
 		cm.wal.mu.Unlock()
 		return errStorageFolderNotFound
 	}
+	// The source folder of a migration stays locked until the migration is
+	// done, and sectors are still moved into the destination.
+	if cm.migrating(index) {
+		cm.wal.mu.Unlock()
+		return errStorageFolderMigrating
+	}
 	cm.wal.mu.Unlock()
 
 	// Lock the storage folder for the duration of the operation.
@@ -57,6 +57,16 @@ type (
 		UnfinishedStorageFolderAdditions  []savedStorageFolder
 		UnfinishedStorageFolderExtensions []unfinishedStorageFolderExtension
 
+		// StorageFolderMigrations are long running migrations of all sectors
+		// from one storage folder to another. The sectors are moved using
+		// regular sector updates, which means that a migration can be resumed
+		// at any point. A migration is unfinished until the index of its
+		// source folder appears in FinishedStorageFolderMigrations. Unfinished
+		// migrations are carried over to the new WAL on every commit and are
+		// resumed at startup.
+		FinishedStorageFolderMigrations []uint16
+		StorageFolderMigrations         []storageFolderMigration
+
 		// Updates to the sector metadata. Careful ordering of events ensures
 		// that a sector update will not make it into the synced WAL unless the
 		// sector data is already on-disk and synced.
@@ -250,6 +260,7 @@ func (wal *writeAheadLog) recoverWAL(walFile modules.File) error {
 	// completed.
 	wal.cleanupUnfinishedStorageFolderAdditions(scs)
 	wal.cleanupUnfinishedStorageFolderExtensions(scs)
+	wal.recoverStorageFolderMigrations(scs)
 	return nil
 }
 
@@ -212,6 +212,7 @@ func (wal *writeAheadLog) commit() {
 		// Extract any unfinished long-running jobs from the list of WAL items.
 		unfinishedAdditions := findUnfinishedStorageFolderAdditions(wal.uncommittedChanges)
 		unfinishedExtensions := findUnfinishedStorageFolderExtensions(wal.uncommittedChanges)
+		unfinishedMigrations := findUnfinishedStorageFolderMigrations(wal.uncommittedChanges)
 
 		// Recreate the wal file so that it can receive new updates.
 		var err error
@@ -230,6 +231,7 @@ func (wal *writeAheadLog) commit() {
 		wal.appendChange(stateChange{
 			UnfinishedStorageFolderAdditions:  unfinishedAdditions,
 			UnfinishedStorageFolderExtensions: unfinishedExtensions,
+			StorageFolderMigrations:           unfinishedMigrations,
 		})
 
 		// Clear the set of uncommitted changes.
@@ -267,6 +269,17 @@ func (wal *writeAheadLog) spawnSyncLoop() (err error) {
 		// manager should have completed, so the number of uncommitted changes
 		// should be zero.
 		<-syncLoopStopped // Wait for the sync loop to signal proper termination.
+
+		// Keep the WAL if storage folder migrations are unfinished, so that
+		// they are resumed after the next startup.
+		wal.mu.Lock()
+		migrating := len(wal.cm.migrations) > 0
+		wal.mu.Unlock()
+		if migrating {
+			wal.cm.log.Println("Keeping the WAL to resume the unfinished storage folder migrations")
+			return
+		}
+
 		// Allow unclean shutdown to be simulated by disrupting the removal of
 		// the WAL file.
 		if !wal.cm.dependencies.Disrupt("cleanWALFile") {
@@ -0,0 +1,180 @@
+package host
+
+// financials.go keeps the financial ledger of the host. Whenever a storage
+// obligation succeeds or fails, its revenue or losses are added to the ledger
+// entry of the block height at which it was resolved. Unlike the financial
+// metrics, which are a cumulative snapshot, the ledger allows the revenue to
+// be reported for arbitrary ranges of block heights.
+
+import (
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+	"gitlab.com/NebulousLabs/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// maxFinancialPeriods is the maximum number of periods that can be
+	// requested from the financial ledger at once.
+	maxFinancialPeriods = 10e3
+)
+
+var (
+	// errFinancialsInvalidRange is returned if the end height of a financial
+	// ledger request is below its start height.
+	errFinancialsInvalidRange = errors.New("the end height of the financial ledger is below its start height")
+
+	// errFinancialsTooManyPeriods is returned if a financial ledger request
+	// would return more than maxFinancialPeriods periods.
+	errFinancialsTooManyPeriods = errors.New("too many financial periods requested, increase the period length")
+)
+
+// addFinancialPeriod adds the revenue and losses of a financial period to
+// another.
+func addFinancialPeriod(p *modules.HostFinancialPeriod, other modules.HostFinancialPeriod) {
+	p.SucceededObligations += other.SucceededObligations
+	p.FailedObligations += other.FailedObligations
+	p.AccountFunding = p.AccountFunding.Add(other.AccountFunding)
+	p.ContractCompensation = p.ContractCompensation.Add(other.ContractCompensation)
+	p.DownloadBandwidthRevenue = p.DownloadBandwidthRevenue.Add(other.DownloadBandwidthRevenue)
+	p.StorageRevenue = p.StorageRevenue.Add(other.StorageRevenue)
+	p.UploadBandwidthRevenue = p.UploadBandwidthRevenue.Add(other.UploadBandwidthRevenue)
+	p.TokenStorageRevenue = p.TokenStorageRevenue.Add(other.TokenStorageRevenue)
+	p.LostRevenue = p.LostRevenue.Add(other.LostRevenue)
+	p.LostStorageCollateral = p.LostStorageCollateral.Add(other.LostStorageCollateral)
+}
+
+// financialLedgerEntry returns the ledger entry of a storage obligation that
+// was resolved with the status. False is returned for obligations that don't
+// have revenue or losses, which are the rejected ones.
+func financialLedgerEntry(so storageObligation, sos storageObligationStatus) (modules.HostFinancialPeriod, bool) {
+	var entry modules.HostFinancialPeriod
+	switch sos {
+	case obligationSucceeded:
+		entry.SucceededObligations = 1
+		entry.AccountFunding = so.PotentialAccountFunding
+		entry.ContractCompensation = so.ContractCost
+		entry.DownloadBandwidthRevenue = so.PotentialDownloadRevenue
+		entry.StorageRevenue = so.PotentialStorageRevenue
+		entry.UploadBandwidthRevenue = so.PotentialUploadRevenue
+		entry.TokenStorageRevenue = so.PotentialTokenRevenue
+	case obligationFailed:
+		entry.FailedObligations = 1
+		entry.LostRevenue = so.ContractCost.Add(so.PotentialStorageRevenue).Add(so.PotentialDownloadRevenue).Add(so.PotentialUploadRevenue).Add(so.PotentialAccountFunding)
+		entry.LostStorageCollateral = so.RiskedCollateral
+	default:
+		return modules.HostFinancialPeriod{}, false
+	}
+	return entry, true
+}
+
+// addFinancialLedgerEntry adds the entry to the ledger entry of the height.
+func addFinancialLedgerEntry(tx *bolt.Tx, height types.BlockHeight, entry modules.HostFinancialPeriod) error {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(height))
+	b := tx.Bucket(bucketFinancialLedger)
+	var existing modules.HostFinancialPeriod
+	if entryBytes := b.Get(key); entryBytes != nil {
+		err := json.Unmarshal(entryBytes, &existing)
+		if err != nil {
+			return errors.AddContext(err, "unable to unmarshal financial ledger entry")
+		}
+	}
+	existing.StartHeight, existing.EndHeight = height, height
+	addFinancialPeriod(&existing, entry)
+	entryBytes, err := json.Marshal(existing)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, entryBytes)
+}
+
+// buildFinancialLedger adds the storage obligations that were resolved before
+// the host kept a financial ledger to the ledger. Their resolution height is
+// unknown, they are added at their proof deadline instead.
+func buildFinancialLedger(tx *bolt.Tx) error {
+	return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+		var so storageObligation
+		if err := json.Unmarshal(soBytes, &so); err != nil || len(so.OriginTransactionSet) == 0 {
+			return nil
+		}
+		entry, ok := financialLedgerEntry(so, so.ObligationStatus)
+		if !ok {
+			return nil
+		}
+		return addFinancialLedgerEntry(tx, so.proofDeadline(), entry)
+	})
+}
+
+// FinancialLedger returns the revenue and losses of the storage obligations
+// that were resolved between the heights, which are inclusive. The range is
+// split into periods of the given number of blocks, the last period may be
+// shorter. An end height of 0 means the current height, and a period of 0
+// returns the whole range as a single period.
+func (h *Host) FinancialLedger(from, to, period types.BlockHeight) (modules.HostFinancialLedger, error) {
+	err := h.tg.Add()
+	if err != nil {
+		return modules.HostFinancialLedger{}, err
+	}
+	defer h.tg.Done()
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if to == 0 {
+		to = h.blockHeight
+	}
+	if to < from {
+		return modules.HostFinancialLedger{}, errFinancialsInvalidRange
+	}
+	numPeriods := types.BlockHeight(1)
+	if period != 0 && period <= to-from {
+		numPeriods = (to-from)/period + 1
+	}
+	if numPeriods > maxFinancialPeriods {
+		return modules.HostFinancialLedger{}, errFinancialsTooManyPeriods
+	}
+
+	ledger := modules.HostFinancialLedger{
+		Periods: make([]modules.HostFinancialPeriod, numPeriods),
+		Total: modules.HostFinancialPeriod{
+			StartHeight: from,
+			EndHeight:   to,
+		},
+	}
+	for i := range ledger.Periods {
+		ledger.Periods[i].StartHeight = from + types.BlockHeight(i)*period
+		ledger.Periods[i].EndHeight = ledger.Periods[i].StartHeight + period - 1
+	}
+	ledger.Periods[numPeriods-1].EndHeight = to
+
+	err = h.db.View(func(tx *bolt.Tx) error {
+		start := make([]byte, 8)
+		binary.BigEndian.PutUint64(start, uint64(from))
+		c := tx.Bucket(bucketFinancialLedger).Cursor()
+		for k, v := c.Seek(start); k != nil; k, v = c.Next() {
+			height := types.BlockHeight(binary.BigEndian.Uint64(k))
+			if height > to {
+				break
+			}
+			var entry modules.HostFinancialPeriod
+			err := json.Unmarshal(v, &entry)
+			if err != nil {
+				return errors.AddContext(err, "unable to unmarshal financial ledger entry")
+			}
+			i := types.BlockHeight(0)
+			if numPeriods > 1 {
+				i = (height - from) / period
+			}
+			addFinancialPeriod(&ledger.Periods[i], entry)
+			addFinancialPeriod(&ledger.Total, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return modules.HostFinancialLedger{}, err
+	}
+	return ledger, nil
+}
@@ -0,0 +1,124 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+// TestFinancialLedger checks that resolved storage obligations are added to
+// the financial ledger and that the ledger is split into periods.
+func TestFinancialLedger(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := blankHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Add an obligation that succeeds and one that fails.
+	renter := types.Ed25519PublicKey(crypto.PublicKey{1})
+	succeeded := newQueryTestStorageObligation(1000, renter, 100)
+	succeeded.PotentialStorageRevenue = types.NewCurrency64(50)
+	succeeded.PotentialTokenRevenue = types.NewCurrency64(20)
+	failed := newQueryTestStorageObligation(1001, renter, 200)
+	failed.RiskedCollateral = types.NewCurrency64(30)
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		for _, so := range []storageObligation{succeeded, failed} {
+			if err := putStorageObligation(tx, so); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Resolve them 5 blocks apart.
+	ht.host.mu.Lock()
+	height := ht.host.blockHeight
+	ht.host.financialMetrics.ContractCount = 2
+	ht.host.financialMetrics.PotentialContractCompensation = types.NewCurrency64(300)
+	ht.host.financialMetrics.PotentialStorageRevenue = types.NewCurrency64(50)
+	ht.host.financialMetrics.RiskedStorageCollateral = types.NewCurrency64(30)
+	err = ht.host.removeStorageObligation(succeeded, obligationSucceeded)
+	if err == nil {
+		ht.host.blockHeight += 5
+		err = ht.host.removeStorageObligation(failed, obligationFailed)
+	}
+	ht.host.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Split the ledger into periods of 5 blocks.
+	ledger, err := ht.host.FinancialLedger(height, height+11, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ledger.Periods) != 3 || ledger.Periods[1].StartHeight != height+5 || ledger.Periods[2].EndHeight != height+11 {
+		t.Fatal("wrong periods", ledger.Periods)
+	}
+	p := ledger.Periods[0]
+	if p.SucceededObligations != 1 || !p.ContractCompensation.Equals64(100) || !p.StorageRevenue.Equals64(50) || !p.TokenStorageRevenue.Equals64(20) || !p.LostRevenue.IsZero() {
+		t.Fatal("wrong first period", p)
+	}
+	p = ledger.Periods[1]
+	if p.FailedObligations != 1 || !p.LostRevenue.Equals64(200) || !p.LostStorageCollateral.Equals64(30) || !p.ContractCompensation.IsZero() {
+		t.Fatal("wrong second period", p)
+	}
+	if ledger.Periods[2].SucceededObligations != 0 || ledger.Periods[2].FailedObligations != 0 {
+		t.Fatal("third period should be empty", ledger.Periods[2])
+	}
+	total := ledger.Total
+	if total.SucceededObligations != 1 || total.FailedObligations != 1 || !total.ContractCompensation.Equals64(100) || !total.LostRevenue.Equals64(200) {
+		t.Fatal("wrong total", total)
+	}
+
+	// A period of 0 returns a single period up to the current height.
+	ledger, err = ht.host.FinancialLedger(height+1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ledger.Periods) != 1 || ledger.Periods[0].EndHeight != height+5 || ledger.Periods[0].SucceededObligations != 0 || ledger.Periods[0].FailedObligations != 1 {
+		t.Fatal("wrong single period", ledger.Periods)
+	}
+
+	// Invalid ranges should be rejected.
+	if _, err := ht.host.FinancialLedger(10, 5, 0); err != errFinancialsInvalidRange {
+		t.Fatal("expected errFinancialsInvalidRange", err)
+	}
+	if _, err := ht.host.FinancialLedger(0, 100e3, 1); err != errFinancialsTooManyPeriods {
+		t.Fatal("expected errFinancialsTooManyPeriods", err)
+	}
+
+	// Drop the ledger, it should be rebuilt from the resolved obligations at
+	// their proof deadlines when the host is reopened.
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		return tx.DeleteBucket(bucketFinancialLedger)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ht.host.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = reopenHost(ht)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger, err = ht.host.FinancialLedger(succeeded.proofDeadline(), failed.proofDeadline(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ledger.Periods) != 2 || ledger.Periods[0].SucceededObligations != 1 || ledger.Periods[1].FailedObligations != 1 {
+		t.Fatal("ledger wasn't rebuilt", ledger.Periods)
+	}
+}
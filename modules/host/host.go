@@ -145,6 +145,10 @@ type Host struct {
 	atomicSettingsCalls     uint64
 	atomicUnrecognizedCalls uint64
 
+	// atomicPricingUpdate is set while the pricing policy updates the prices
+	// of the host.
+	atomicPricingUpdate uint64
+
 	// Error management. There are a few different types of errors returned by
 	// the host. These errors intentionally not persistent, so that the logging
 	// limits of each error type will be reset each time the host is reset.
@@ -183,9 +187,20 @@ type Host struct {
 	autoAddress          modules.NetAddress // Determined using automatic tooling in network.go
 	financialMetrics     modules.HostFinancialMetrics
 	settings             modules.HostInternalSettings
+	pricingPolicy        modules.HostPricingPolicy
+	pricingHostDB        pricingHostDB
 	revisionNumber       uint64
 	workingStatus        modules.HostWorkingStatus
 	connectabilityStatus modules.HostConnectabilityStatus
+	// recentFormations contains the negotiation heights of the storage
+	// obligations that were formed within the last formationsWindow blocks,
+	// so that the pricing policy doesn't have to load every obligation on
+	// every block. It is loaded from the database on first use.
+	recentFormations []types.BlockHeight
+	formationsWindow types.BlockHeight
+	// registryEntries is the number of entries in the registry. It is
+	// counted when the database is opened.
+	registryEntries uint64
 	// scheduledAuditBlockheight is the blockheight when the next AuditStorageObligations()
 	// should be started
 	scheduledAuditBlockheight uint64
@@ -374,6 +389,11 @@ func (h *Host) managedUpdatePriceTable() {
 		// prices.
 		LatestRevisionCost: modules.DefaultBaseRPCPrice.Add(hes.DownloadBandwidthPrice.Mul64(modules.EstimatedFileContractTransactionSetSize)),
 
+		// Registry costs cover the bandwidth of transferring an entry, and
+		// updates also pay for storing the entry.
+		ReadRegistryCost:   hes.BaseRPCPrice.Add(hes.DownloadBandwidthPrice.Mul64(modules.RegistryEntrySize)),
+		UpdateRegistryCost: hes.BaseRPCPrice.Add(hes.UploadBandwidthPrice.Mul64(modules.RegistryEntrySize)).Add(hes.StoragePrice.Mul64(modules.RegistryEntrySize).Mul64(uint64(modules.RegistryStorageDuration))),
+
 		// Bandwidth related fields.
 		DownloadBandwidthCost: hes.DownloadBandwidthPrice,
 		UploadBandwidthCost:   hes.UploadBandwidthPrice,
@@ -516,16 +536,17 @@ func newHost(dependencies modules.Dependencies, smDeps modules.Dependencies, cs
 		}
 	})
 
-	// Initialize token storage.
-	// TODO: the current version of tokens storage does not support reverting blocks.
-	// If contracts related to `TopUpToken` RPC are reverted, all the tokens resources remain in the storage.
+	// Initialize token storage. It is sent the consensus changes of the host
+	// to remove the resources of reverted top-ups.
 	h.tokenStor, err = tokenstorage.NewTokenStorage(stManager, tokenStorageDir)
 	if err != nil {
 		return nil, fmt.Errorf("error initializing token storage: %w", err)
 	}
 	updateTokenSectorsChan := make(chan bool)
+	compactTokenStorageChan := make(chan bool)
 	h.tg.AfterStop(func() {
 		updateTokenSectorsChan <- true
+		compactTokenStorageChan <- true
 		err = h.tokenStor.Close(context.Background())
 		if err != nil {
 			h.log.Errorf("Error when closing token storage: %v", err)
@@ -533,6 +554,8 @@ func newHost(dependencies modules.Dependencies, smDeps modules.Dependencies, cs
 	})
 	// Remove sectors from token when token storage resource ends.
 	go h.tokenStor.CheckExpiration(checkTokenExpirationFrequency, updateTokenSectorsChan)
+	// Compact the events log of token storage, so that it loads quickly.
+	go h.tokenStor.CompactPeriodically(tokenStorageCompactionFrequency, compactTokenStorageChan)
 
 	// Load the prior persistence structures, and configure the host to save
 	// before shutting down.
@@ -667,6 +690,26 @@ func (h *Host) BandwidthCounters() (uint64, uint64, time.Time, error) {
 	return writeBytes, readBytes, startTime, nil
 }
 
+// CompactTokenStorage writes a snapshot of the token storage and truncates its
+// events log.
+func (h *Host) CompactTokenStorage() (modules.TokenStorageSnapshot, error) {
+	if err := h.tg.Add(); err != nil {
+		return modules.TokenStorageSnapshot{}, err
+	}
+	defer h.tg.Done()
+	return h.tokenStor.Compact()
+}
+
+// VerifyTokenStorage checks the snapshot of the token storage and makes sure
+// that replaying it with the events log results in the current state.
+func (h *Host) VerifyTokenStorage() (modules.TokenStorageSnapshot, error) {
+	if err := h.tg.Add(); err != nil {
+		return modules.TokenStorageSnapshot{}, err
+	}
+	defer h.tg.Done()
+	return h.tokenStor.Verify()
+}
+
 // WorkingStatus returns the working state of the host, where working is
 // defined as having received more than workingStatusThreshold settings calls
 // over the period of workingStatusFrequency.
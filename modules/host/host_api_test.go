@@ -74,7 +74,7 @@ func TestAPI_DownloadWithToken(t *testing.T) {
 	}
 
 	// add DownloadBytes, error not enough sector accesses
-	err = host.host.tokenStor.AddResources(tokenID, modules.DownloadBytes, 5000)
+	err = host.host.tokenStor.AddResources(tokenID, types.FileContractID{}, modules.DownloadBytes, 5000)
 	_, err = hostApi.DownloadWithToken(context.Background(), req)
 	cErr = err.(*api.DownloadWithTokenError)
 	if !cErr.NotEnoughSectorAccesses {
@@ -83,7 +83,7 @@ func TestAPI_DownloadWithToken(t *testing.T) {
 
 	// remove DownloadBytes, add SectorAccesses, error not enough bytes
 	_, _ = host.host.tokenStor.RecordDownload(tokenID, 5000, 0, time.Now())
-	err = host.host.tokenStor.AddResources(tokenID, modules.SectorAccesses, 1)
+	err = host.host.tokenStor.AddResources(tokenID, types.FileContractID{}, modules.SectorAccesses, 1)
 	_, err = hostApi.DownloadWithToken(context.Background(), req)
 	cErr = err.(*api.DownloadWithTokenError)
 	if !cErr.NotEnoughBytes {
@@ -91,7 +91,7 @@ func TestAPI_DownloadWithToken(t *testing.T) {
 	}
 
 	// error no such sector
-	err = host.host.tokenStor.AddResources(tokenID, modules.DownloadBytes, 5000)
+	err = host.host.tokenStor.AddResources(tokenID, types.FileContractID{}, modules.DownloadBytes, 5000)
 	_, err = hostApi.DownloadWithToken(context.Background(), req)
 	cErr = err.(*api.DownloadWithTokenError)
 	if cErr.NoSuchSector == nil {
@@ -99,8 +99,8 @@ func TestAPI_DownloadWithToken(t *testing.T) {
 	}
 
 	// correct case
-	err = host.host.tokenStor.AddResources(tokenID, modules.SectorAccesses, 1)
-	err = host.host.tokenStor.AddResources(tokenID, modules.DownloadBytes, 5000)
+	err = host.host.tokenStor.AddResources(tokenID, types.FileContractID{}, modules.SectorAccesses, 1)
+	err = host.host.tokenStor.AddResources(tokenID, types.FileContractID{}, modules.DownloadBytes, 5000)
 	// create storage folder
 	storageFolderOne := filepath.Join(host.host.persistDir, "hostTesterStorageFolderOne")
 	err = os.Mkdir(storageFolderOne, 0700)
@@ -172,7 +172,7 @@ func TestAPI_UploadWithToken(t *testing.T) {
 		t.Fatal("should be 'not enough bytes' error")
 	}
 
-	err = host.host.tokenStor.AddResources(tokenID, modules.UploadBytes, 41943041)
+	err = host.host.tokenStor.AddResources(tokenID, types.FileContractID{}, modules.UploadBytes, 41943041)
 	_, err = hostApi.UploadWithToken(context.Background(), req)
 	cErr = err.(*api.UploadWithTokenError)
 	if !cErr.NotEnoughStorage {
@@ -181,7 +181,7 @@ func TestAPI_UploadWithToken(t *testing.T) {
 
 	// correct case
 	// add storage resource
-	err = host.host.tokenStor.AddResources(tokenID, modules.Storage, 100)
+	err = host.host.tokenStor.AddResources(tokenID, types.FileContractID{}, modules.Storage, 100)
 	// create storage folder
 	storageFolderOne := filepath.Join(host.host.persistDir, "hostTesterStorageFolderOne")
 	err = os.Mkdir(storageFolderOne, 0700)
@@ -198,6 +198,84 @@ func TestAPI_UploadWithToken(t *testing.T) {
 	}
 }
 
+func TestAPI_SubTokens(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	host, _ := blankMockHostTester(modules.ProdDependencies, t.Name())
+	defer host.Close()
+	hostApi := api.NewAPI(host.host.tokenStor, host.host.secretKey, host.host)
+
+	// generate token
+	b := fastrand.Bytes(16)
+	var tokenID types.TokenID
+	copy(tokenID[:], b)
+	if err := host.host.tokenStor.AddResources(tokenID, types.FileContractID{}, modules.DownloadBytes, 50000); err != nil {
+		t.Fatal(err)
+	}
+	if err := host.host.tokenStor.AddResources(tokenID, types.FileContractID{}, modules.SectorAccesses, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	// mint a sub-token that can download 8000 bytes
+	mintResp, err := hostApi.MintSubToken(context.Background(), &api.MintSubTokenRequest{
+		Authorization: tokenID.String(),
+		DownloadBytes: 8000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mintResp.TokenRecord.SubToken || mintResp.TokenRecord.DownloadBytes != 8000 {
+		t.Fatal("incorrect sub-token record", mintResp.TokenRecord)
+	}
+
+	// the sub-token can't upload
+	_, err = hostApi.UploadWithToken(context.Background(), &api.UploadWithTokenRequest{
+		Authorization: mintResp.SubToken,
+		Sectors:       [][]byte{fastrand.Bytes(int(modules.SectorSize))},
+	})
+	if cErr, ok := err.(*api.UploadWithTokenError); !ok || !cErr.SubToken {
+		t.Fatal("should be 'sub-token' error", err)
+	}
+
+	// the sub-token can't download more than its limit
+	req := &api.DownloadWithTokenRequest{Authorization: mintResp.SubToken}
+	for i := 0; i < 20; i++ {
+		req.Ranges = append(req.Ranges, api.Range{
+			MerkleRoot: crypto.Hash{1},
+			Length:     crypto.SegmentSize,
+		})
+	}
+	_, err = hostApi.DownloadWithToken(context.Background(), req)
+	if cErr, ok := err.(*api.DownloadWithTokenError); !ok || !cErr.NotEnoughBytes {
+		t.Fatal("should be 'not enough bytes' error", err)
+	}
+
+	// the parent token can't be transferred to an invalid token
+	_, err = hostApi.TransferToken(context.Background(), &api.TransferTokenRequest{
+		Authorization: tokenID.String(),
+		NewToken:      "abc",
+	})
+	if cErr, ok := err.(*api.TokenError); !ok || cErr.InvalidRequest == "" {
+		t.Fatal("should be 'invalid request' error", err)
+	}
+
+	// revoke the sub-token
+	_, err = hostApi.SetExpiration(context.Background(), &api.SetExpirationRequest{
+		Authorization:  mintResp.SubToken,
+		ExpirationTime: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Ranges = req.Ranges[:1]
+	_, err = hostApi.DownloadWithToken(context.Background(), req)
+	if cErr, ok := err.(*api.DownloadWithTokenError); !ok || !cErr.TokenExpired {
+		t.Fatal("should be 'token expired' error", err)
+	}
+}
+
 func TestAPI_CircleIntegration(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
@@ -216,22 +294,22 @@ func TestAPI_CircleIntegration(t *testing.T) {
 	copy(tokenID[:], b)
 
 	// add storage resource
-	err = rhp.staticHT.host.tokenStor.AddResources(tokenID, modules.Storage, 1000)
+	err = rhp.staticHT.host.tokenStor.AddResources(tokenID, types.FileContractID{}, modules.Storage, 1000)
 	if err != nil {
 		t.Fatal(err)
 	}
 	// add upload bytes resource
-	err = rhp.staticHT.host.tokenStor.AddResources(tokenID, modules.UploadBytes, 41943041)
+	err = rhp.staticHT.host.tokenStor.AddResources(tokenID, types.FileContractID{}, modules.UploadBytes, 41943041)
 	if err != nil {
 		t.Fatal(err)
 	}
 	// add download bytes resource
-	err = rhp.staticHT.host.tokenStor.AddResources(tokenID, modules.DownloadBytes, 41943041)
+	err = rhp.staticHT.host.tokenStor.AddResources(tokenID, types.FileContractID{}, modules.DownloadBytes, 41943041)
 	if err != nil {
 		t.Fatal(err)
 	}
 	// add sector accesses bytes resource
-	err = rhp.staticHT.host.tokenStor.AddResources(tokenID, modules.SectorAccesses, 10)
+	err = rhp.staticHT.host.tokenStor.AddResources(tokenID, types.FileContractID{}, modules.SectorAccesses, 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -758,6 +758,113 @@ func (p *renterHostPair) managedLatestRevision(payByFC bool, fundAmt types.Curre
 	return lrr.Revision, nil
 }
 
+// managedReadRegistry performs a RPCReadRegistry to read the registry entry
+// with the given id from the host.
+func (p *renterHostPair) managedReadRegistry(fundAmt types.Currency, id crypto.Hash) (modules.RPCReadRegistryResponse, error) {
+	stream := p.managedNewStream()
+	defer stream.Close()
+
+	// Fetch the price table.
+	pt, err := p.managedFetchPriceTable()
+	if err != nil {
+		return modules.RPCReadRegistryResponse{}, err
+	}
+
+	// initiate the RPC
+	err = modules.RPCWrite(stream, modules.RPCReadRegistry)
+	if err != nil {
+		return modules.RPCReadRegistryResponse{}, err
+	}
+
+	// Write the pricetable uid.
+	err = modules.RPCWrite(stream, pt.UID)
+	if err != nil {
+		return modules.RPCReadRegistryResponse{}, err
+	}
+
+	// provide payment
+	err = p.managedPayByContract(stream, fundAmt, p.staticAccountID)
+	if err != nil {
+		return modules.RPCReadRegistryResponse{}, err
+	}
+
+	// send the request.
+	err = modules.RPCWrite(stream, modules.RPCReadRegistryRequest{
+		EntryID: id,
+	})
+	if err != nil {
+		return modules.RPCReadRegistryResponse{}, err
+	}
+
+	// read the response.
+	var rrr modules.RPCReadRegistryResponse
+	err = modules.RPCRead(stream, &rrr)
+	if err != nil {
+		return modules.RPCReadRegistryResponse{}, err
+	}
+
+	// expect clean stream close
+	err = modules.RPCRead(stream, struct{}{})
+	if !errors.Contains(err, io.ErrClosedPipe) {
+		return modules.RPCReadRegistryResponse{}, err
+	}
+	return rrr, nil
+}
+
+// managedUpdateRegistry performs a RPCUpdateRegistry to store a value in the
+// host's registry.
+func (p *renterHostPair) managedUpdateRegistry(fundAmt types.Currency, spk types.SiaPublicKey, srv modules.SignedRegistryValue) error {
+	stream := p.managedNewStream()
+	defer stream.Close()
+
+	// Fetch the price table.
+	pt, err := p.managedFetchPriceTable()
+	if err != nil {
+		return err
+	}
+
+	// initiate the RPC
+	err = modules.RPCWrite(stream, modules.RPCUpdateRegistry)
+	if err != nil {
+		return err
+	}
+
+	// Write the pricetable uid.
+	err = modules.RPCWrite(stream, pt.UID)
+	if err != nil {
+		return err
+	}
+
+	// send the request.
+	err = modules.RPCWrite(stream, modules.RPCUpdateRegistryRequest{
+		PubKey: spk,
+		Value:  srv,
+	})
+	if err != nil {
+		return err
+	}
+
+	// provide payment
+	err = p.managedPayByContract(stream, fundAmt, p.staticAccountID)
+	if err != nil {
+		return err
+	}
+
+	// read the response.
+	var urr modules.RPCUpdateRegistryResponse
+	err = modules.RPCRead(stream, &urr)
+	if err != nil {
+		return err
+	}
+
+	// expect clean stream close
+	err = modules.RPCRead(stream, struct{}{})
+	if !errors.Contains(err, io.ErrClosedPipe) {
+		return err
+	}
+	return nil
+}
+
 // AccountBalance returns the account balance of the renter's EA on the host.
 func (p *renterHostPair) AccountBalance(payByFC bool) (types.Currency, error) {
 	return p.managedAccountBalance(payByFC, p.pt.AccountBalanceCost, p.staticAccountID, p.staticAccountID)
@@ -398,6 +398,10 @@ func (h *Host) threadedHandleStream(stream siamux.Stream) {
 		err = h.managedRPCFundEphemeralAccount(stream)
 	case modules.RPCLatestRevision:
 		err = h.managedRPCLatestRevision(stream)
+	case modules.RPCReadRegistry:
+		err = h.managedRPCReadRegistry(stream)
+	case modules.RPCUpdateRegistry:
+		err = h.managedRPCUpdateRegistry(stream)
 	default:
 		h.log.Debugf("WARN: incoming stream %v requested unknown RPC \"%v\"", stream.RemoteAddr().String(), rpcID)
 		err = errors.New(fmt.Sprintf("Unrecognized RPC id %v", rpcID))
@@ -1183,6 +1183,7 @@ func (h *Host) managedRPCLoopTopUpToken(s *rpcSession) error {
 	case modules.Storage:
 		s.so.PotentialStorageRevenue = s.so.PotentialStorageRevenue.Add(paymentTransfer)
 	}
+	s.so.PotentialTokenRevenue = s.so.PotentialTokenRevenue.Add(paymentTransfer)
 	s.so.RevisionTransactionSet = []types.Transaction{txn}
 	err = h.managedModifyStorageObligation(s.so, nil, nil)
 	if err != nil {
@@ -1192,7 +1193,7 @@ func (h *Host) managedRPCLoopTopUpToken(s *rpcSession) error {
 
 	// Save changes to token storage.
 	id := types.TokenID(req.Token)
-	if err := h.tokenStor.AddResources(id, req.ResourcesType, req.ResourcesAmount); err != nil {
+	if err := h.tokenStor.AddResources(id, s.so.id(), req.ResourcesType, req.ResourcesAmount); err != nil {
 		return err
 	}
 
@@ -1240,8 +1241,8 @@ func (h *Host) managedRPCLoopDownloadWithToken(s *rpcSession) error {
 	availableBandwidth := int64(0)
 	availableSectors := int64(0)
 	tokenResources, err := h.tokenStor.TokenRecord(id)
-	if err == nil {
-		// Token not found = no resources, and 0 is correct.
+	if err == nil && !tokenResources.Expired(time.Now()) {
+		// Token not found or expired = no resources, and 0 is correct.
 		availableBandwidth = tokenResources.DownloadBytes
 		availableSectors = tokenResources.SectorAccesses
 	}
@@ -31,6 +31,9 @@ type persistence struct {
 	SecretKey        crypto.SecretKey             `json:"secretkey"`
 	Settings         modules.HostInternalSettings `json:"settings"`
 	UnlockHash       types.UnlockHash             `json:"unlockhash"`
+
+	// Pricing.
+	PricingPolicy modules.HostPricingPolicy `json:"pricingpolicy"`
 }
 
 // persistData returns the data in the Host that will be saved to disk.
@@ -49,6 +52,9 @@ func (h *Host) persistData() persistence {
 		SecretKey:        h.secretKey,
 		Settings:         h.settings,
 		UnlockHash:       h.unlockHash,
+
+		// Pricing.
+		PricingPolicy: h.pricingPolicy,
 	}
 }
 
@@ -76,8 +82,12 @@ func (h *Host) establishDefaults() error {
 		EphemeralAccountExpiry:     modules.DefaultEphemeralAccountExpiry,
 		MaxEphemeralAccountBalance: modules.DefaultMaxEphemeralAccountBalance,
 		MaxEphemeralAccountRisk:    defaultMaxEphemeralAccountRisk,
+
+		MaxRegistryEntries: defaultMaxRegistryEntries,
 	}
 
+	h.pricingPolicy = modules.DefaultHostPricingPolicy
+
 	// Set the recent consensusChange to current so rescanning consensus can be skipped
 	h.recentChange = modules.ConsensusChangeRecent
 
@@ -125,6 +135,18 @@ func (h *Host) loadPersistObject(p *persistence) {
 		h.settings.NetAddress = ""
 	}
 	h.unlockHash = p.UnlockHash
+
+	// Persist files of older versions don't contain the registry limit.
+	if h.settings.MaxRegistryEntries == 0 {
+		h.settings.MaxRegistryEntries = defaultMaxRegistryEntries
+	}
+
+	// Copy over the pricing policy. Persist files of older versions don't
+	// contain a policy, a valid policy always has a max adjustment.
+	h.pricingPolicy = p.PricingPolicy
+	if h.pricingPolicy.MaxAdjustment == 0 {
+		h.pricingPolicy = modules.DefaultHostPricingPolicy
+	}
 }
 
 // initDB will check that the database has been initialized and if not, will
@@ -145,9 +167,23 @@ func (h *Host) initDB() (err error) {
 	return h.db.Update(func(tx *bolt.Tx) error {
 		// The storage obligation bucket does not exist, which means the
 		// database needs to be initialized. Create the database buckets.
+		//
+		// Databases created before the storage obligation indexes don't have
+		// the metadata bucket, their obligations need to be indexed.
+		buildIndexes := tx.Bucket(bucketStorageObligationMetadata) == nil
+		// The financial ledger of databases created before it is built from
+		// the resolved obligations.
+		buildLedger := tx.Bucket(bucketFinancialLedger) == nil
 		buckets := [][]byte{
 			bucketActionItems,
+			bucketFinancialLedger,
+			bucketRegistry,
+			bucketRegistryExpiry,
 			bucketStorageObligations,
+			bucketStorageObligationMetadata,
+			bucketStorageObligationsByExpiration,
+			bucketStorageObligationsByRenter,
+			bucketStorageObligationsByStatus,
 		}
 		for _, bucket := range buckets {
 			_, err := tx.CreateBucketIfNotExists(bucket)
@@ -155,6 +191,16 @@ func (h *Host) initDB() (err error) {
 				return err
 			}
 		}
+		h.registryEntries = uint64(tx.Bucket(bucketRegistry).Stats().KeyN)
+		if buildIndexes {
+			err := buildStorageObligationIndexes(tx)
+			if err != nil {
+				return err
+			}
+		}
+		if buildLedger {
+			return buildFinancialLedger(tx)
+		}
 		return nil
 	})
 }
@@ -263,11 +309,12 @@ func (h *Host) load() error {
 		h.log.Printf("Pruning %v corrupt storage obligations from database.\n", len(invalidSOkeys))
 		//Try to recover by reading again and pruning invalid entries
 		err = h.db.Update(func(tx *bolt.Tx) error {
-			bucket := tx.Bucket(bucketStorageObligations)
 			var dbErr error
 			for _, invalidKey := range invalidSOkeys {
 				h.log.Printf("Deleting %v from database.\n", invalidKey)
-				dbErr = bucket.Delete(invalidKey)
+				var id types.FileContractID
+				copy(id[:], invalidKey)
+				dbErr = deleteStorageObligation(tx, id)
 				if dbErr != nil {
 					return dbErr
 				}
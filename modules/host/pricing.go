@@ -1,10 +1,427 @@
 package host
 
 import (
+	"encoding/json"
+	"math"
+	"sort"
+	"sync/atomic"
+
+	"github.com/EvilRedHorse/pubaccess-node/build"
 	"github.com/EvilRedHorse/pubaccess-node/modules"
 	"github.com/EvilRedHorse/pubaccess-node/types"
+	"gitlab.com/NebulousLabs/errors"
+	bolt "go.etcd.io/bbolt"
 )
 
+var (
+	// errPricingFloorAboveCeiling is returned if a floor of the pricing policy
+	// is above the corresponding ceiling.
+	errPricingFloorAboveCeiling = errors.New("price floor of the pricing policy is above the ceiling")
+
+	// errPricingInvalidMaxAdjustment is returned if the max adjustment of the
+	// pricing policy isn't in the range (0, 1].
+	errPricingInvalidMaxAdjustment = errors.New("max adjustment of the pricing policy has to be greater than 0 and at most 1")
+
+	// errPricingInvalidTargetUtilization is returned if the target
+	// utilization of the pricing policy isn't in the range [0, 1].
+	errPricingInvalidTargetUtilization = errors.New("target utilization of the pricing policy has to be between 0 and 1")
+
+	// errPricingInvalidWeight is returned if one of the weights of the pricing
+	// policy is negative or the market weight is above 1.
+	errPricingInvalidWeight = errors.New("weights of the pricing policy can't be negative and the market weight can't be above 1")
+
+	// errPricingNoFormationWindow is returned if a target formation rate is
+	// set without a formation window.
+	errPricingNoFormationWindow = errors.New("the pricing policy needs a formation window to target a formation rate")
+)
+
+var (
+	// pricingMinMarketSamples is the number of other hosts whose prices need
+	// to be known before the median of the network is used for pricing.
+	pricingMinMarketSamples = build.Select(build.Var{
+		Standard: 10,
+		Dev:      3,
+		Testing:  1,
+	}).(int)
+)
+
+// pricingHostDB is the source of the prices of the other hosts on the network,
+// usually the hostdb of the renter.
+type pricingHostDB interface {
+	ActiveHosts() ([]modules.HostDBEntry, error)
+}
+
+// validatePricingPolicy returns an error if the pricing policy can't be used
+// by the pricing engine.
+func validatePricingPolicy(p modules.HostPricingPolicy) error {
+	if p.MaxAdjustment <= 0 || p.MaxAdjustment > 1 {
+		return errPricingInvalidMaxAdjustment
+	}
+	if p.TargetUtilization < 0 || p.TargetUtilization > 1 {
+		return errPricingInvalidTargetUtilization
+	}
+	if p.UtilizationWeight < 0 || p.FormationWeight < 0 || p.MarketWeight < 0 || p.MarketWeight > 1 {
+		return errPricingInvalidWeight
+	}
+	if p.TargetFormationRate > 0 && p.FormationWindow == 0 {
+		return errPricingNoFormationWindow
+	}
+	bounds := [][2]types.Currency{
+		{p.StoragePriceFloor, p.StoragePriceCeiling},
+		{p.DownloadBandwidthPriceFloor, p.DownloadBandwidthPriceCeiling},
+		{p.UploadBandwidthPriceFloor, p.UploadBandwidthPriceCeiling},
+		{p.CollateralFloor, p.CollateralCeiling},
+	}
+	for _, b := range bounds {
+		if !b[1].IsZero() && b[0].Cmp(b[1]) > 0 {
+			return errPricingFloorAboveCeiling
+		}
+	}
+	return nil
+}
+
+// medianPrice returns the median of the prices.
+func medianPrice(prices []types.Currency) types.Currency {
+	if len(prices) == 0 {
+		return types.ZeroCurrency
+	}
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].Cmp(prices[j]) < 0
+	})
+	mid := len(prices) / 2
+	if len(prices)%2 == 1 {
+		return prices[mid]
+	}
+	return prices[mid-1].Add(prices[mid]).Div64(2)
+}
+
+// pricesEqual returns whether two sets of prices are equal.
+func pricesEqual(a, b modules.HostPricingPrices) bool {
+	return a.StoragePrice.Equals(b.StoragePrice) &&
+		a.DownloadBandwidthPrice.Equals(b.DownloadBandwidthPrice) &&
+		a.UploadBandwidthPrice.Equals(b.UploadBandwidthPrice) &&
+		a.Collateral.Equals(b.Collateral)
+}
+
+// adjustPrice moves the current price by the demand and towards the market
+// price, limits the change to the max adjustment of the policy and keeps the
+// result between the floor and the ceiling.
+func adjustPrice(current, market, floor, ceiling types.Currency, demand float64, useMarket bool, policy modules.HostPricingPolicy) types.Currency {
+	// The changes are computed as deltas of the current price, which avoids
+	// rounding down prices that aren't changed by a factor.
+	price := current
+	if demand > 0 {
+		price = price.Add(current.MulFloat(demand))
+	} else if demand < 0 {
+		price = price.Sub(current.MulFloat(math.Min(1, -demand)))
+	}
+	if useMarket && market.Cmp(price) > 0 {
+		price = price.Add(market.Sub(price).MulFloat(policy.MarketWeight))
+	} else if useMarket {
+		price = price.Sub(price.Sub(market).MulFloat(policy.MarketWeight))
+	}
+	if !current.IsZero() {
+		delta := current.MulFloat(policy.MaxAdjustment)
+		if maxPrice := current.Add(delta); price.Cmp(maxPrice) > 0 {
+			price = maxPrice
+		} else if minPrice := current.Sub(delta); price.Cmp(minPrice) < 0 {
+			price = minPrice
+		}
+	}
+	if !ceiling.IsZero() && price.Cmp(ceiling) > 0 {
+		price = ceiling
+	}
+	if price.Cmp(floor) < 0 {
+		price = floor
+	}
+	return price
+}
+
+// minDownloadBandwidthPrice returns the lowest download bandwidth price that
+// keeps the base RPC price and the sector access price of the settings within
+// their allowed ratios.
+func minDownloadBandwidthPrice(settings modules.HostInternalSettings) types.Currency {
+	minPrice := types.ZeroCurrency
+	for _, r := range []struct {
+		price types.Currency
+		ratio uint64
+	}{
+		{settings.MinBaseRPCPrice, modules.MaxBaseRPCPriceVsBandwidth},
+		{settings.MinSectorAccessPrice, modules.MaxSectorAccessPriceVsBandwidth},
+	} {
+		p := r.price.Div64(r.ratio)
+		if p.Mul64(r.ratio).Cmp(r.price) < 0 {
+			p = p.Add64(1)
+		}
+		if p.Cmp(minPrice) > 0 {
+			minPrice = p
+		}
+	}
+	return minPrice
+}
+
+// proposePrices sets the current prices of the preview to the prices of the
+// settings, and the proposed prices to the prices the policy would set given
+// the inputs of the preview.
+//
+// The demand for storage is the difference between the storage utilization
+// and the target utilization plus the relative difference between the recent
+// contract formations and the target formation rate, each multiplied by its
+// weight. The storage price rises with demand and the collateral falls with
+// it. The bandwidth prices only follow the contract formations, since they
+// don't depend on the free storage of the host.
+func proposePrices(policy modules.HostPricingPolicy, preview *modules.HostPricingPreview, settings modules.HostInternalSettings) {
+	var utilizationDemand, formationDemand float64
+	if preview.Utilization >= 0 {
+		utilizationDemand = policy.UtilizationWeight * (preview.Utilization - policy.TargetUtilization)
+	}
+	if policy.TargetFormationRate > 0 {
+		target := float64(policy.TargetFormationRate)
+		relative := (float64(preview.RecentContracts) - target) / target
+		formationDemand = policy.FormationWeight * math.Min(1, relative)
+	}
+	demand := utilizationDemand + formationDemand
+	useMarket := preview.MarketSamples >= pricingMinMarketSamples
+
+	preview.Current = modules.HostPricingPrices{
+		StoragePrice:           settings.MinStoragePrice,
+		DownloadBandwidthPrice: settings.MinDownloadBandwidthPrice,
+		UploadBandwidthPrice:   settings.MinUploadBandwidthPrice,
+		Collateral:             settings.Collateral,
+	}
+	cur, market := preview.Current, preview.Market
+	downloadFloor := policy.DownloadBandwidthPriceFloor
+	if minPrice := minDownloadBandwidthPrice(settings); minPrice.Cmp(downloadFloor) > 0 {
+		downloadFloor = minPrice
+	}
+	preview.Proposed = modules.HostPricingPrices{
+		StoragePrice:           adjustPrice(cur.StoragePrice, market.StoragePrice, policy.StoragePriceFloor, policy.StoragePriceCeiling, demand, useMarket, policy),
+		DownloadBandwidthPrice: adjustPrice(cur.DownloadBandwidthPrice, market.DownloadBandwidthPrice, downloadFloor, policy.DownloadBandwidthPriceCeiling, formationDemand, useMarket, policy),
+		UploadBandwidthPrice:   adjustPrice(cur.UploadBandwidthPrice, market.UploadBandwidthPrice, policy.UploadBandwidthPriceFloor, policy.UploadBandwidthPriceCeiling, formationDemand, useMarket, policy),
+		Collateral:             adjustPrice(cur.Collateral, market.Collateral, policy.CollateralFloor, policy.CollateralCeiling, -demand, useMarket, policy),
+	}
+}
+
+// managedPricingInputs collects the storage utilization, the number of
+// recently formed contracts and the median prices of the network. The
+// utilization is negative if the host doesn't have any storage.
+func (h *Host) managedPricingInputs(policy modules.HostPricingPolicy) modules.HostPricingPreview {
+	var preview modules.HostPricingPreview
+
+	// Storage utilization.
+	var total, remaining uint64
+	for _, sf := range h.StorageFolders() {
+		total += sf.Capacity
+		remaining += sf.CapacityRemaining
+	}
+	preview.Utilization = -1
+	if total > 0 {
+		preview.Utilization = float64(total-remaining) / float64(total)
+	}
+
+	// Recent contract formations.
+	if policy.FormationWindow > 0 {
+		preview.RecentContracts = h.managedRecentFormations(policy.FormationWindow)
+	}
+	h.mu.RLock()
+	hdb := h.pricingHostDB
+	pk := h.publicKey
+	h.mu.RUnlock()
+
+	// Median prices of the other hosts.
+	if hdb == nil {
+		return preview
+	}
+	hosts, err := hdb.ActiveHosts()
+	if err != nil {
+		h.log.Println("WARN: unable to sample the prices of the network:", err)
+		return preview
+	}
+	var storage, download, upload, collateral []types.Currency
+	for _, entry := range hosts {
+		if entry.PublicKey.Equals(pk) {
+			continue
+		}
+		storage = append(storage, entry.StoragePrice)
+		download = append(download, entry.DownloadBandwidthPrice)
+		upload = append(upload, entry.UploadBandwidthPrice)
+		collateral = append(collateral, entry.Collateral)
+	}
+	preview.MarketSamples = len(storage)
+	preview.Market = modules.HostPricingPrices{
+		StoragePrice:           medianPrice(storage),
+		DownloadBandwidthPrice: medianPrice(download),
+		UploadBandwidthPrice:   medianPrice(upload),
+		Collateral:             medianPrice(collateral),
+	}
+	return preview
+}
+
+// managedRecentFormations returns the number of storage obligations that were
+// formed within the last window blocks. The formations are loaded from the
+// database the first time and whenever the window grows, afterwards only the
+// formations that fell out of the window are dropped.
+func (h *Host) managedRecentFormations(window types.BlockHeight) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if window > h.formationsWindow {
+		err := h.loadRecentFormations(window)
+		if err != nil {
+			h.log.Println("WARN: unable to load the recent contract formations:", err)
+			return 0
+		}
+	}
+
+	var count uint64
+	height := h.blockHeight
+	recent := h.recentFormations[:0]
+	for _, nh := range h.recentFormations {
+		if nh <= height && height-nh >= h.formationsWindow {
+			continue
+		}
+		recent = append(recent, nh)
+		if nh <= height && height-nh < window {
+			count++
+		}
+	}
+	h.recentFormations = recent
+	return count
+}
+
+// loadRecentFormations loads the negotiation heights of the storage
+// obligations that were formed within the last window blocks.
+//
+// NOTE: the caller has to hold the host lock.
+func (h *Host) loadRecentFormations(window types.BlockHeight) error {
+	var recent []types.BlockHeight
+	err := h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			err := json.Unmarshal(soBytes, &so)
+			if err != nil {
+				return errors.AddContext(err, "unable to unmarshal storage obligation")
+			}
+			if so.NegotiationHeight > h.blockHeight || h.blockHeight-so.NegotiationHeight < window {
+				recent = append(recent, so.NegotiationHeight)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	h.recentFormations = recent
+	h.formationsWindow = window
+	return nil
+}
+
+// threadedUpdatePrices applies the prices proposed by the pricing policy to
+// the settings of the host. It is called on every block while the policy is
+// enabled.
+func (h *Host) threadedUpdatePrices() {
+	if err := h.tg.Add(); err != nil {
+		return
+	}
+	defer h.tg.Done()
+	// Skip the block if the prices of the previous block are still being
+	// updated.
+	if !atomic.CompareAndSwapUint64(&h.atomicPricingUpdate, 0, 1) {
+		return
+	}
+	defer atomic.StoreUint64(&h.atomicPricingUpdate, 0)
+
+	h.mu.RLock()
+	policy := h.pricingPolicy
+	h.mu.RUnlock()
+	if !policy.Enabled {
+		return
+	}
+	preview := h.managedPricingInputs(policy)
+
+	h.mu.Lock()
+	// The settings or the policy might have changed while the inputs were
+	// collected.
+	if !h.pricingPolicy.Enabled {
+		h.mu.Unlock()
+		return
+	}
+	proposePrices(h.pricingPolicy, &preview, h.settings)
+	if pricesEqual(preview.Proposed, preview.Current) {
+		h.mu.Unlock()
+		return
+	}
+	h.settings.MinStoragePrice = preview.Proposed.StoragePrice
+	h.settings.MinDownloadBandwidthPrice = preview.Proposed.DownloadBandwidthPrice
+	h.settings.MinUploadBandwidthPrice = preview.Proposed.UploadBandwidthPrice
+	h.settings.Collateral = preview.Proposed.Collateral
+	h.revisionNumber++
+	err := h.saveSync()
+	h.mu.Unlock()
+	if err != nil {
+		h.log.Println("ERROR: prices updated, but failed saving to disk:", err)
+	}
+	h.managedUpdatePriceTable()
+}
+
+// PricingPolicy returns the pricing policy of the host.
+func (h *Host) PricingPolicy() modules.HostPricingPolicy {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.pricingPolicy
+}
+
+// PricingPreview returns the prices the pricing policy would set on the next
+// block. The settings of the host are not changed, which allows previewing a
+// policy before it is enabled.
+func (h *Host) PricingPreview(policy modules.HostPricingPolicy) (modules.HostPricingPreview, error) {
+	err := h.tg.Add()
+	if err != nil {
+		return modules.HostPricingPreview{}, err
+	}
+	defer h.tg.Done()
+	err = validatePricingPolicy(policy)
+	if err != nil {
+		return modules.HostPricingPreview{}, err
+	}
+	preview := h.managedPricingInputs(policy)
+	h.mu.RLock()
+	proposePrices(policy, &preview, h.settings)
+	h.mu.RUnlock()
+	return preview, nil
+}
+
+// SetPricingHostDB sets the source of the prices of the other hosts on the
+// network. Without it, the pricing policy ignores the prices of the network.
+func (h *Host) SetPricingHostDB(hdb pricingHostDB) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pricingHostDB = hdb
+}
+
+// SetPricingPolicy sets the pricing policy of the host. Once the policy is
+// enabled, it replaces the storage price, the bandwidth prices and the
+// collateral of the internal settings on every block.
+func (h *Host) SetPricingPolicy(policy modules.HostPricingPolicy) error {
+	err := h.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer h.tg.Done()
+	err = validatePricingPolicy(policy)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pricingPolicy = policy
+	err = h.saveSync()
+	if err != nil {
+		return errors.AddContext(err, "pricing policy updated, but failed saving to disk")
+	}
+	return nil
+}
+
 func (h *Host) calculatePriceByResource(resourceType types.Specifier, resourceAmount int64) types.Currency {
 	settings := h.ManagedExternalSettings()
 	var resourceCost types.Currency
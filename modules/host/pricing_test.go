@@ -0,0 +1,313 @@
+package host
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/EvilRedHorse/pubaccess-node/build"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+)
+
+// mockPricingHostDB is a pricingHostDB that returns a fixed set of hosts.
+type mockPricingHostDB struct {
+	hosts []modules.HostDBEntry
+}
+
+// ActiveHosts returns the hosts of the mock.
+func (m *mockPricingHostDB) ActiveHosts() ([]modules.HostDBEntry, error) {
+	return m.hosts, nil
+}
+
+// pricingHostDBEntry returns a host entry with the given prices.
+func pricingHostDBEntry(storage, bandwidth, collateral types.Currency) modules.HostDBEntry {
+	var entry modules.HostDBEntry
+	entry.StoragePrice = storage
+	entry.DownloadBandwidthPrice = bandwidth
+	entry.UploadBandwidthPrice = bandwidth
+	entry.Collateral = collateral
+	return entry
+}
+
+// TestProposePrices checks the prices proposed by the pricing policy for a
+// set of inputs.
+func TestProposePrices(t *testing.T) {
+	settings := modules.HostInternalSettings{
+		MinStoragePrice:           types.NewCurrency64(1000),
+		MinDownloadBandwidthPrice: types.NewCurrency64(1000),
+		MinUploadBandwidthPrice:   types.NewCurrency64(1000),
+		Collateral:                types.NewCurrency64(1000),
+	}
+	policy := modules.DefaultHostPricingPolicy
+	policy.MarketWeight = 0
+
+	// At the target utilization and formation rate the prices don't change.
+	preview := modules.HostPricingPreview{
+		Utilization:     policy.TargetUtilization,
+		RecentContracts: policy.TargetFormationRate,
+	}
+	proposePrices(policy, &preview, settings)
+	if !pricesEqual(preview.Proposed, preview.Current) {
+		t.Fatal("prices shouldn't change", preview.Proposed, preview.Current)
+	}
+
+	// A full host raises the storage price and lowers the collateral, but
+	// by no more than the max adjustment. The bandwidth prices only follow
+	// the contract formations.
+	preview = modules.HostPricingPreview{
+		Utilization:     1,
+		RecentContracts: policy.TargetFormationRate,
+	}
+	proposePrices(policy, &preview, settings)
+	if !preview.Proposed.StoragePrice.Equals64(1010) || !preview.Proposed.Collateral.Equals64(990) {
+		t.Fatal("wrong storage prices", preview.Proposed)
+	}
+	if !preview.Proposed.DownloadBandwidthPrice.Equals64(1000) || !preview.Proposed.UploadBandwidthPrice.Equals64(1000) {
+		t.Fatal("bandwidth prices shouldn't change", preview.Proposed)
+	}
+
+	// Without any contract formations all prices fall.
+	preview = modules.HostPricingPreview{
+		Utilization: policy.TargetUtilization,
+	}
+	proposePrices(policy, &preview, settings)
+	if !preview.Proposed.StoragePrice.Equals64(990) || !preview.Proposed.DownloadBandwidthPrice.Equals64(990) {
+		t.Fatal("prices should fall", preview.Proposed)
+	}
+
+	// The floors and ceilings limit the prices.
+	policy.StoragePriceCeiling = types.NewCurrency64(1005)
+	policy.CollateralFloor = types.NewCurrency64(995)
+	preview = modules.HostPricingPreview{
+		Utilization:     1,
+		RecentContracts: policy.TargetFormationRate,
+	}
+	proposePrices(policy, &preview, settings)
+	if !preview.Proposed.StoragePrice.Equals64(1005) || !preview.Proposed.Collateral.Equals64(995) {
+		t.Fatal("floor and ceiling not applied", preview.Proposed)
+	}
+
+	// The prices move towards the market median, but only if there are
+	// enough samples.
+	policy = modules.DefaultHostPricingPolicy
+	policy.MaxAdjustment = 1
+	policy.MarketWeight = 0.5
+	preview = modules.HostPricingPreview{
+		Utilization:     policy.TargetUtilization,
+		RecentContracts: policy.TargetFormationRate,
+		MarketSamples:   pricingMinMarketSamples,
+		Market: modules.HostPricingPrices{
+			StoragePrice:           types.NewCurrency64(2000),
+			DownloadBandwidthPrice: types.NewCurrency64(2000),
+			UploadBandwidthPrice:   types.NewCurrency64(2000),
+			Collateral:             types.NewCurrency64(2000),
+		},
+	}
+	proposePrices(policy, &preview, settings)
+	if !preview.Proposed.StoragePrice.Equals64(1500) || !preview.Proposed.Collateral.Equals64(1500) {
+		t.Fatal("market price not blended in", preview.Proposed)
+	}
+	preview.MarketSamples = pricingMinMarketSamples - 1
+	proposePrices(policy, &preview, settings)
+	if !preview.Proposed.StoragePrice.Equals64(1000) {
+		t.Fatal("market price used without enough samples", preview.Proposed)
+	}
+
+	// The download price doesn't fall below the ratio to the base RPC price.
+	settings.MinBaseRPCPrice = types.NewCurrency64(1000 * modules.MaxBaseRPCPriceVsBandwidth)
+	preview = modules.HostPricingPreview{
+		Utilization: policy.TargetUtilization,
+	}
+	proposePrices(policy, &preview, settings)
+	if !preview.Proposed.DownloadBandwidthPrice.Equals64(1000) {
+		t.Fatal("download price below the base RPC price ratio", preview.Proposed)
+	}
+}
+
+// TestValidatePricingPolicy checks that invalid pricing policies are
+// rejected.
+func TestValidatePricingPolicy(t *testing.T) {
+	if err := validatePricingPolicy(modules.DefaultHostPricingPolicy); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		modify func(*modules.HostPricingPolicy)
+		err    error
+	}{
+		{func(p *modules.HostPricingPolicy) { p.MaxAdjustment = 0 }, errPricingInvalidMaxAdjustment},
+		{func(p *modules.HostPricingPolicy) { p.MaxAdjustment = 1.5 }, errPricingInvalidMaxAdjustment},
+		{func(p *modules.HostPricingPolicy) { p.TargetUtilization = 2 }, errPricingInvalidTargetUtilization},
+		{func(p *modules.HostPricingPolicy) { p.UtilizationWeight = -1 }, errPricingInvalidWeight},
+		{func(p *modules.HostPricingPolicy) { p.MarketWeight = 2 }, errPricingInvalidWeight},
+		{func(p *modules.HostPricingPolicy) { p.FormationWindow = 0 }, errPricingNoFormationWindow},
+		{func(p *modules.HostPricingPolicy) {
+			p.StoragePriceFloor = types.NewCurrency64(2)
+			p.StoragePriceCeiling = types.NewCurrency64(1)
+		}, errPricingFloorAboveCeiling},
+	}
+	for i, test := range tests {
+		policy := modules.DefaultHostPricingPolicy
+		test.modify(&policy)
+		if err := validatePricingPolicy(policy); err != test.err {
+			t.Fatalf("%v: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+// TestHostPricingPolicy checks that an enabled pricing policy updates the
+// prices of the host on new blocks and that the policy is persisted.
+func TestHostPricingPolicy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// The policy is disabled by default.
+	policy := ht.host.PricingPolicy()
+	if policy.Enabled || policy.MaxAdjustment != modules.DefaultHostPricingPolicy.MaxAdjustment {
+		t.Fatal("wrong default policy", policy)
+	}
+	policy.MaxAdjustment = 0
+	if err := ht.host.SetPricingPolicy(policy); err != errPricingInvalidMaxAdjustment {
+		t.Fatal("expected errPricingInvalidMaxAdjustment", err)
+	}
+
+	// Preview a policy that follows the network, which is more expensive.
+	settings := ht.host.InternalSettings()
+	market := settings.MinStoragePrice.Mul64(2)
+	ht.host.SetPricingHostDB(&mockPricingHostDB{
+		hosts: []modules.HostDBEntry{
+			pricingHostDBEntry(market, settings.MinDownloadBandwidthPrice, settings.Collateral),
+		},
+	})
+	policy = modules.DefaultHostPricingPolicy
+	policy.UtilizationWeight = 0
+	policy.FormationWeight = 0
+	policy.MarketWeight = 1
+	preview, err := ht.host.PricingPreview(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.MarketSamples != 1 || !preview.Market.StoragePrice.Equals(market) {
+		t.Fatal("wrong market inputs", preview.MarketSamples, preview.Market)
+	}
+	expected := settings.MinStoragePrice.MulFloat(1 + policy.MaxAdjustment)
+	if !preview.Proposed.StoragePrice.Equals(expected) {
+		t.Fatal("wrong proposed storage price", preview.Proposed.StoragePrice, expected)
+	}
+	if !ht.host.InternalSettings().MinStoragePrice.Equals(settings.MinStoragePrice) {
+		t.Fatal("preview changed the settings")
+	}
+
+	// Enable the policy, the storage price should rise with every block.
+	policy.Enabled = true
+	err = ht.host.SetPricingPolicy(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ht.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if ht.host.InternalSettings().MinStoragePrice.Cmp(settings.MinStoragePrice) <= 0 {
+			return errors.New("storage price wasn't raised")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The policy should survive a restart.
+	err = ht.host.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = reopenHost(ht)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ht.host.PricingPolicy().Enabled || ht.host.PricingPolicy().MarketWeight != 1 {
+		t.Fatal("pricing policy wasn't persisted", ht.host.PricingPolicy())
+	}
+}
+
+// TestHostRecentFormations checks that the recent contract formations are
+// loaded from the database once, updated when storage obligations are added
+// and dropped once they fall out of the formation window.
+func TestHostRecentFormations(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	addObligation := func() {
+		so, err := ht.newTesterStorageObligation()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ht.host.mu.RLock()
+		so.NegotiationHeight = ht.host.blockHeight
+		ht.host.mu.RUnlock()
+		ht.host.managedLockStorageObligation(so.id())
+		err = ht.host.managedAddStorageObligation(so, false)
+		ht.host.managedUnlockStorageObligation(so.id())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first obligation is added before the formations are tracked, so it
+	// is loaded from the database.
+	addObligation()
+	window := types.BlockHeight(5)
+	if n := ht.host.managedRecentFormations(window); n != 1 {
+		t.Fatal("expected 1 recent formation but got", n)
+	}
+
+	// The second obligation is tracked when it is added.
+	addObligation()
+	ht.host.mu.RLock()
+	tracked := len(ht.host.recentFormations)
+	ht.host.mu.RUnlock()
+	if tracked != 2 {
+		t.Fatal("expected 2 tracked formations but got", tracked)
+	}
+	if n := ht.host.managedRecentFormations(window); n != 2 {
+		t.Fatal("expected 2 recent formations but got", n)
+	}
+
+	// The formations fall out of the window.
+	for i := types.BlockHeight(0); i < window; i++ {
+		_, err = ht.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := ht.host.managedRecentFormations(window); n != 0 {
+		t.Fatal("expected no recent formations but got", n)
+	}
+	ht.host.mu.RLock()
+	tracked = len(ht.host.recentFormations)
+	ht.host.mu.RUnlock()
+	if tracked != 0 {
+		t.Fatal("expected old formations to be dropped but got", tracked)
+	}
+
+	// A larger window loads the formations from the database again.
+	if n := ht.host.managedRecentFormations(window * 10); n != 2 {
+		t.Fatal("expected 2 recent formations but got", n)
+	}
+}
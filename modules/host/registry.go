@@ -0,0 +1,194 @@
+package host
+
+import (
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+
+	"gitlab.com/NebulousLabs/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// registryEntry is an entry of the host's registry as it is stored in the
+// database. The entry is removed from the registry at the expiry height unless
+// it is updated before.
+type registryEntry struct {
+	PubKey types.SiaPublicKey          `json:"pubkey"`
+	Value  modules.SignedRegistryValue `json:"value"`
+	Expiry types.BlockHeight           `json:"expiry"`
+}
+
+// registryExpiryKey returns the key of a registry entry in the expiry index.
+func registryExpiryKey(expiry types.BlockHeight, id crypto.Hash) []byte {
+	key := make([]byte, 8+crypto.HashSize)
+	binary.BigEndian.PutUint64(key, uint64(expiry))
+	copy(key[8:], id[:])
+	return key
+}
+
+// getRegistryEntry fetches a registry entry from the database tx.
+func getRegistryEntry(tx *bolt.Tx, id crypto.Hash) (entry registryEntry, err error) {
+	entryBytes := tx.Bucket(bucketRegistry).Get(id[:])
+	if entryBytes == nil {
+		return registryEntry{}, modules.ErrRegistryEntryNotFound
+	}
+	err = json.Unmarshal(entryBytes, &entry)
+	return entry, err
+}
+
+// putRegistryEntry places a registry entry into the database and indexes it
+// by its expiry. The existing entry is passed in to remove its key from the
+// expiry index, it is nil if there is no existing entry.
+func putRegistryEntry(tx *bolt.Tx, entry registryEntry, existing *registryEntry) error {
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	id := modules.RegistryEntryID(entry.PubKey, entry.Value.Tweak)
+	if existing != nil {
+		err = tx.Bucket(bucketRegistryExpiry).Delete(registryExpiryKey(existing.Expiry, id))
+		if err != nil {
+			return err
+		}
+	}
+	err = tx.Bucket(bucketRegistryExpiry).Put(registryExpiryKey(entry.Expiry, id), []byte{})
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucketRegistry).Put(id[:], entryBytes)
+}
+
+// pruneRegistry removes the registry entries that expired at or before the
+// host's current block height and returns the number of removed entries. The
+// caller needs to hold the host's lock and subtract the removed entries from
+// the entry count once the tx is committed.
+func (h *Host) pruneRegistry(tx *bolt.Tx) (uint64, error) {
+	// Collect the expired keys first, bolt doesn't support deleting keys
+	// while iterating over them.
+	var expired [][]byte
+	c := tx.Bucket(bucketRegistryExpiry).Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		if types.BlockHeight(binary.BigEndian.Uint64(k[:8])) > h.blockHeight {
+			break
+		}
+		expired = append(expired, k)
+	}
+	for _, k := range expired {
+		if err := tx.Bucket(bucketRegistry).Delete(k[8:]); err != nil {
+			return 0, err
+		}
+		if err := tx.Bucket(bucketRegistryExpiry).Delete(k); err != nil {
+			return 0, err
+		}
+	}
+	return uint64(len(expired)), nil
+}
+
+// removeExpiredRegistryEntries prunes the registry in its own database tx and
+// updates the entry count. The caller needs to hold the host's lock.
+func (h *Host) removeExpiredRegistryEntries() error {
+	var pruned uint64
+	err := h.db.Update(func(tx *bolt.Tx) (err error) {
+		pruned, err = h.pruneRegistry(tx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	h.registryEntries -= pruned
+	return nil
+}
+
+// validateRegistryUpdate checks whether the signed value can be stored in the
+// registry given the existing entry, which is nil if there is none. The caller
+// needs to hold the host's lock.
+func (h *Host) validateRegistryUpdate(srv modules.SignedRegistryValue, existing *registryEntry) error {
+	if existing != nil && srv.Revision <= existing.Value.Revision {
+		return modules.ErrRegistryLowerRevNum
+	}
+	if existing == nil && h.registryEntries >= h.settings.MaxRegistryEntries {
+		return modules.ErrRegistryFull
+	}
+	return nil
+}
+
+// getExistingRegistryEntry returns the registry entry with the given id or nil
+// if it doesn't exist.
+func getExistingRegistryEntry(tx *bolt.Tx, id crypto.Hash) (*registryEntry, error) {
+	entry, err := getRegistryEntry(tx, id)
+	if errors.Contains(err, modules.ErrRegistryEntryNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to get existing registry entry")
+	}
+	return &entry, nil
+}
+
+// managedReadRegistry returns the registry entry with the given id.
+func (h *Host) managedReadRegistry(id crypto.Hash) (entry registryEntry, err error) {
+	err = h.db.View(func(tx *bolt.Tx) error {
+		entry, err = getRegistryEntry(tx, id)
+		return err
+	})
+	return entry, err
+}
+
+// managedValidateRegistryUpdate verifies the signed value and checks whether
+// it would be accepted by the registry without updating it. It is used to
+// reject updates before the host charges for them.
+func (h *Host) managedValidateRegistryUpdate(spk types.SiaPublicKey, srv modules.SignedRegistryValue) error {
+	if err := srv.Verify(spk); err != nil {
+		return err
+	}
+	id := modules.RegistryEntryID(spk, srv.Tweak)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.db.View(func(tx *bolt.Tx) error {
+		existing, err := getExistingRegistryEntry(tx, id)
+		if err != nil {
+			return err
+		}
+		return h.validateRegistryUpdate(srv, existing)
+	})
+}
+
+// managedUpdateRegistry verifies the signed value and stores it in the
+// registry if its revision number is higher than the one of the existing
+// entry. New entries are rejected once the registry holds the maximum number
+// of entries. The entry expires RegistryStorageDuration blocks after the
+// update.
+func (h *Host) managedUpdateRegistry(spk types.SiaPublicKey, srv modules.SignedRegistryValue) error {
+	if err := srv.Verify(spk); err != nil {
+		return err
+	}
+	id := modules.RegistryEntryID(spk, srv.Tweak)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var added bool
+	err := h.db.Update(func(tx *bolt.Tx) error {
+		existing, err := getExistingRegistryEntry(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := h.validateRegistryUpdate(srv, existing); err != nil {
+			return err
+		}
+		added = existing == nil
+		return putRegistryEntry(tx, registryEntry{
+			PubKey: spk,
+			Value:  srv,
+			Expiry: h.blockHeight + modules.RegistryStorageDuration,
+		}, existing)
+	})
+	if err != nil {
+		return err
+	}
+	if added {
+		h.registryEntries++
+	}
+	return nil
+}
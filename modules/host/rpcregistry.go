@@ -0,0 +1,122 @@
+package host
+
+import (
+	"gitlab.com/NebulousLabs/errors"
+
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"gitlab.com/NebulousLabs/siamux"
+)
+
+// managedRPCReadRegistry handles the RPC which returns an entry of the host's
+// registry.
+func (h *Host) managedRPCReadRegistry(stream siamux.Stream) error {
+	// read the price table
+	pt, err := h.staticReadPriceTableID(stream)
+	if err != nil {
+		return errors.AddContext(err, "failed to read price table")
+	}
+
+	// Process payment.
+	pd, err := h.ProcessPayment(stream)
+	if err != nil {
+		return errors.AddContext(err, "failed to process payment")
+	}
+
+	// Check payment.
+	if pd.Amount().Cmp(pt.ReadRegistryCost) < 0 {
+		return modules.ErrInsufficientPaymentForRPC
+	}
+
+	// Refund excessive payment.
+	refund := pd.Amount().Sub(pt.ReadRegistryCost)
+	if !refund.IsZero() {
+		err = h.staticAccountManager.callRefund(pd.AccountID(), refund)
+		if err != nil {
+			return errors.AddContext(err, "failed to refund client")
+		}
+	}
+
+	// Read request
+	var rrr modules.RPCReadRegistryRequest
+	err = modules.RPCRead(stream, &rrr)
+	if err != nil {
+		return errors.AddContext(err, "Failed to read ReadRegistryRequest")
+	}
+
+	// Get registry entry.
+	entry, err := h.managedReadRegistry(rrr.EntryID)
+	if err != nil {
+		return errors.AddContext(err, "failed to read registry entry")
+	}
+
+	// Send response.
+	err = modules.RPCWrite(stream, modules.RPCReadRegistryResponse{
+		PubKey: entry.PubKey,
+		Value:  entry.Value,
+	})
+	if err != nil {
+		return errors.AddContext(err, "Failed to send ReadRegistryResponse")
+	}
+	return nil
+}
+
+// managedRPCUpdateRegistry handles the RPC which stores a new value in the
+// host's registry. The request is read and validated before the payment, so
+// that the renter isn't charged for updates the host would reject.
+func (h *Host) managedRPCUpdateRegistry(stream siamux.Stream) error {
+	// read the price table
+	pt, err := h.staticReadPriceTableID(stream)
+	if err != nil {
+		return errors.AddContext(err, "failed to read price table")
+	}
+
+	// Read request
+	var urr modules.RPCUpdateRegistryRequest
+	err = modules.RPCRead(stream, &urr)
+	if err != nil {
+		return errors.AddContext(err, "Failed to read UpdateRegistryRequest")
+	}
+
+	// Validate the update.
+	err = h.managedValidateRegistryUpdate(urr.PubKey, urr.Value)
+	if err != nil {
+		return errors.AddContext(err, "invalid registry update")
+	}
+
+	// Process payment.
+	pd, err := h.ProcessPayment(stream)
+	if err != nil {
+		return errors.AddContext(err, "failed to process payment")
+	}
+
+	// Check payment.
+	if pd.Amount().Cmp(pt.UpdateRegistryCost) < 0 {
+		return modules.ErrInsufficientPaymentForRPC
+	}
+
+	// Update registry entry. The entry might have changed since it was
+	// validated, refund the whole payment if the update fails.
+	refund := pd.Amount().Sub(pt.UpdateRegistryCost)
+	err = h.managedUpdateRegistry(urr.PubKey, urr.Value)
+	if err != nil {
+		refund = pd.Amount()
+	}
+
+	// Refund excessive payment.
+	if !refund.IsZero() {
+		refundErr := h.staticAccountManager.callRefund(pd.AccountID(), refund)
+		if refundErr != nil {
+			return errors.Compose(err, errors.AddContext(refundErr, "failed to refund client"))
+		}
+	}
+	if err != nil {
+		return errors.AddContext(err, "failed to update registry entry")
+	}
+
+	// Send response.
+	err = modules.RPCWrite(stream, modules.RPCUpdateRegistryResponse{})
+	if err != nil {
+		return errors.AddContext(err, "Failed to send UpdateRegistryResponse")
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package host
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	bolt "go.etcd.io/bbolt"
+)
+
+// TestRegistry verifies the ReadRegistry and UpdateRegistry RPCs.
+func TestRegistry(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// create a blank host tester
+	rhp, err := newRenterHostPair(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := rhp.Close()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+	id := modules.RegistryEntryID(spk, tweak)
+	pt := rhp.managedPriceTable()
+
+	// Reading a missing entry fails.
+	_, err = rhp.managedReadRegistry(pt.ReadRegistryCost, id)
+	if err == nil || !strings.Contains(err.Error(), modules.ErrRegistryEntryNotFound.Error()) {
+		t.Fatal("expected ErrRegistryEntryNotFound but got:", err)
+	}
+
+	// Store a value and read it back.
+	srv := modules.NewRegistryValue(tweak, fastrand.Bytes(modules.RegistryDataSize), 1).Sign(sk)
+	err = rhp.managedUpdateRegistry(pt.UpdateRegistryCost, spk, srv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rrr, err := rhp.managedReadRegistry(pt.ReadRegistryCost, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rrr.PubKey.Equals(spk) || rrr.Value.Revision != 1 || string(rrr.Value.Data) != string(srv.Data) {
+		t.Fatal("registry entry doesn't match the stored value")
+	}
+	if err := rrr.Value.Verify(rrr.PubKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// The revision number has to increase.
+	err = rhp.managedUpdateRegistry(pt.UpdateRegistryCost, spk, srv)
+	if err == nil || !strings.Contains(err.Error(), modules.ErrRegistryLowerRevNum.Error()) {
+		t.Fatal("expected ErrRegistryLowerRevNum but got:", err)
+	}
+
+	// A value signed by another key is rejected.
+	otherSK, _ := crypto.GenerateKeyPair()
+	forged := modules.NewRegistryValue(tweak, []byte("forged"), 2).Sign(otherSK)
+	err = rhp.managedUpdateRegistry(pt.UpdateRegistryCost, spk, forged)
+	if err == nil || !strings.Contains(err.Error(), modules.ErrRegistryInvalidSignature.Error()) {
+		t.Fatal("expected ErrRegistryInvalidSignature but got:", err)
+	}
+
+	// Data that is too large is rejected.
+	tooLarge := modules.NewRegistryValue(tweak, fastrand.Bytes(modules.RegistryDataSize+1), 2).Sign(sk)
+	err = rhp.managedUpdateRegistry(pt.UpdateRegistryCost, spk, tooLarge)
+	if err == nil || !strings.Contains(err.Error(), modules.ErrRegistryDataTooLarge.Error()) {
+		t.Fatal("expected ErrRegistryDataTooLarge but got:", err)
+	}
+
+	// Insufficient payment is rejected.
+	update := modules.NewRegistryValue(tweak, []byte("update"), 2).Sign(sk)
+	err = rhp.managedUpdateRegistry(pt.UpdateRegistryCost.Sub64(1), spk, update)
+	if err == nil || !strings.Contains(err.Error(), modules.ErrInsufficientPaymentForRPC.Error()) {
+		t.Fatal("expected ErrInsufficientPaymentForRPC but got:", err)
+	}
+
+	// A higher revision replaces the value.
+	err = rhp.managedUpdateRegistry(pt.UpdateRegistryCost, spk, update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rrr, err = rhp.managedReadRegistry(pt.ReadRegistryCost, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rrr.Value.Revision != 2 || string(rrr.Value.Data) != "update" {
+		t.Fatal("registry entry wasn't updated")
+	}
+}
+
+// TestRegistryRejectBeforePayment verifies that the host rejects invalid
+// registry updates before it takes the payment and that it doesn't store more
+// than the maximum number of entries.
+func TestRegistryRejectBeforePayment(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	rhp, err := newRenterHostPair(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := rhp.Close()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	host := rhp.staticHT.host
+	pt := rhp.managedPriceTable()
+
+	// Limit the registry to a single entry.
+	settings := host.InternalSettings()
+	settings.MaxRegistryEntries = 1
+	err = host.SetInternalSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+	srv := modules.NewRegistryValue(tweak, []byte("first"), 1).Sign(sk)
+	err = rhp.managedUpdateRegistry(pt.UpdateRegistryCost, spk, srv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// assertNotCharged checks that the update fails with the expected error
+	// without revising the contract that pays for it.
+	assertNotCharged := func(srv modules.SignedRegistryValue, expected error) {
+		t.Helper()
+		before, err := rhp.managedRecentHostRevision()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = rhp.managedUpdateRegistry(pt.UpdateRegistryCost, spk, srv)
+		if err == nil || !strings.Contains(err.Error(), expected.Error()) {
+			t.Fatalf("expected %v but got: %v", expected, err)
+		}
+		after, err := rhp.managedRecentHostRevision()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if after.NewRevisionNumber != before.NewRevisionNumber {
+			t.Fatal("host took the payment for a rejected update")
+		}
+	}
+
+	// An outdated revision isn't charged.
+	assertNotCharged(srv, modules.ErrRegistryLowerRevNum)
+
+	// A new entry doesn't fit into the registry.
+	var otherTweak crypto.Hash
+	fastrand.Read(otherTweak[:])
+	assertNotCharged(modules.NewRegistryValue(otherTweak, []byte("other"), 1).Sign(sk), modules.ErrRegistryFull)
+
+	// The existing entry can still be updated.
+	err = rhp.managedUpdateRegistry(pt.UpdateRegistryCost, spk, modules.NewRegistryValue(tweak, []byte("second"), 2).Sign(sk))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRegistryPrune verifies that the host removes expired registry entries
+// when it processes a consensus change.
+func TestRegistryPrune(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := ht.Close()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	h := ht.host
+
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+	err = h.managedUpdateRegistry(spk, modules.NewRegistryValue(tweak, []byte("value"), 1).Sign(sk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := modules.RegistryEntryID(spk, tweak)
+	entry, err := h.managedReadRegistry(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Expiry != h.BlockHeight()+modules.RegistryStorageDuration {
+		t.Fatal("unexpected expiry", entry.Expiry)
+	}
+
+	// Let the entry expire at the next block.
+	h.mu.Lock()
+	err = h.db.Update(func(tx *bolt.Tx) error {
+		expiring := entry
+		expiring.Expiry = h.blockHeight + 1
+		return putRegistryEntry(tx, expiring, &entry)
+	})
+	h.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ht.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.managedReadRegistry(id)
+	if !errors.Contains(err, modules.ErrRegistryEntryNotFound) {
+		t.Fatal("expected ErrRegistryEntryNotFound but got:", err)
+	}
+	h.mu.RLock()
+	entries := h.registryEntries
+	h.mu.RUnlock()
+	if entries != 0 {
+		t.Fatal("expected no registry entries but got", entries)
+	}
+}
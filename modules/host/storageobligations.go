@@ -156,6 +156,10 @@ type storageObligation struct {
 	RiskedCollateral         types.Currency
 	TransactionFeesAdded     types.Currency
 
+	// PotentialTokenRevenue is the part of the potential revenue that the
+	// renter paid to top up tokens.
+	PotentialTokenRevenue types.Currency
+
 	// The negotiation height specifies the block height at which the file
 	// contract was negotiated. If the origin transaction set is not accepted
 	// onto the blockchain quickly enough, the contract is pruned from the
@@ -253,7 +257,11 @@ func putStorageObligation(tx *bolt.Tx, so storageObligation) error {
 		return err
 	}
 	soid := so.id()
-	return tx.Bucket(bucketStorageObligations).Put(soid[:], soBytes)
+	err = tx.Bucket(bucketStorageObligations).Put(soid[:], soBytes)
+	if err != nil {
+		return err
+	}
+	return putStorageObligationIndex(tx, so)
 }
 
 // StorageObligationSnapshot is a snapshot of a StorageObligation. A snapshot is
@@ -343,6 +351,7 @@ func (so *storageObligation) StorageObligation() modules.StorageObligation {
 		PotentialDownloadRevenue: so.PotentialDownloadRevenue,
 		PotentialStorageRevenue:  so.PotentialStorageRevenue,
 		PotentialUploadRevenue:   so.PotentialUploadRevenue,
+		RenterPublicKey:          so.renterPublicKey(),
 		RiskedCollateral:         so.RiskedCollateral,
 		SectorRootsCount:         uint64(len(so.SectorRoots)),
 		TransactionFeesAdded:     so.TransactionFeesAdded,
@@ -481,6 +490,16 @@ func (so storageObligation) revisionNumber() uint64 {
 	return so.OriginTransactionSet[len(so.OriginTransactionSet)-1].FileContracts[0].RevisionNumber
 }
 
+// renterPublicKey returns the public key of the renter of the storage
+// obligation, or an empty key if the obligation has no revision.
+func (so storageObligation) renterPublicKey() types.SiaPublicKey {
+	rev, err := so.recentRevision()
+	if err != nil || len(rev.UnlockConditions.PublicKeys) == 0 {
+		return types.SiaPublicKey{}
+	}
+	return rev.UnlockConditions.PublicKeys[0]
+}
+
 // requiresProof is a helper to determine whether the storage obligation
 // requires a proof.
 func (so storageObligation) requiresProof() bool {
@@ -549,9 +568,8 @@ func (h *Host) deleteStorageObligations(soids []types.FileContractID) error {
 	defer h.mu.RUnlock()
 	err := h.db.Update(func(tx *bolt.Tx) error {
 		// Delete obligations.
-		b := tx.Bucket(bucketStorageObligations)
 		for _, soid := range soids {
-			err := b.Delete([]byte(soid[:]))
+			err := deleteStorageObligation(tx, soid)
 			if err != nil {
 				return build.ExtendErr("unable to delete transaction id:", err)
 			}
@@ -632,7 +650,6 @@ func (h *Host) managedAddStorageObligation(so storageObligation, renewal bool) e
 			// other conditions might cause problems. The check for duplicate file
 			// contract ids should happen during the negotiation phase, and not
 			// during the 'addStorageObligation' phase.
-			bso := tx.Bucket(bucketStorageObligations)
 
 			// If the storage obligation already has sectors, it means that the
 			// file contract is being renewed, and that the sector should be
@@ -646,15 +663,15 @@ func (h *Host) managedAddStorageObligation(so storageObligation, renewal bool) e
 			}
 
 			// Add the storage obligation to the database.
-			soBytes, err := json.Marshal(so)
-			if err == nil {
-				err = bso.Put(soid[:], soBytes)
-			}
+			err := putStorageObligation(tx, so)
 			if renewal && err != nil {
 				_ = h.RemoveSectorBatch(so.SectorRoots)
 			}
 			return err
 		})
+		if err == nil && h.formationsWindow > 0 {
+			h.recentFormations = append(h.recentFormations, so.NegotiationHeight)
+		}
 		return err
 	}()
 	if err != nil {
@@ -929,11 +946,21 @@ func (h *Host) removeStorageObligation(so storageObligation, sos storageObligati
 	// obligation status is updated so that the user can see how the obligation
 	// ended up, and the sector roots are removed because they are large
 	// objects with little purpose once storage proofs are no longer needed.
+	//
+	// The revenue or losses of the obligation are added to the financial
+	// ledger of the current height.
 	h.financialMetrics.ContractCount--
 	so.ObligationStatus = sos
 	so.SectorRoots = nil
 	return h.db.Update(func(tx *bolt.Tx) error {
-		return putStorageObligation(tx, so)
+		err := putStorageObligation(tx, so)
+		if err != nil {
+			return err
+		}
+		if entry, ok := financialLedgerEntry(so, sos); ok {
+			return addFinancialLedgerEntry(tx, h.blockHeight, entry)
+		}
+		return nil
 	})
 }
 
@@ -1253,11 +1280,7 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID) {
 
 	// Save the storage obligation to account for any fee changes.
 	err = h.db.Update(func(tx *bolt.Tx) error {
-		soBytes, err := json.Marshal(so)
-		if err != nil {
-			return err
-		}
-		return tx.Bucket(bucketStorageObligations).Put(soid[:], soBytes)
+		return putStorageObligation(tx, so)
 	})
 	if err != nil {
 		h.log.Println("Error updating the storage obligations", err)
@@ -1531,10 +1554,11 @@ func (h *Host) auditStorageObligations() error {
 			// contents := value.([]byte)
 		}
 		err = h.db.Update(func(tx *bolt.Tx) error {
-			bucket := tx.Bucket(bucketStorageObligations)
 			var dbErr error
+			var soid types.FileContractID
+			copy(soid[:], id)
 			h.log.Printf("Deleting %v from database.\n", id)
-			dbErr = bucket.Delete(id)
+			dbErr = deleteStorageObligation(tx, soid)
 			if dbErr != nil {
 				return dbErr
 			}
@@ -1594,9 +1618,8 @@ func (h *Host) auditStorageObligations() error {
 					}
 				}
 				err = h.db.Update(func(tx *bolt.Tx) error {
-					bucket := tx.Bucket(bucketStorageObligations)
 					h.log.Printf("Deleting %v as stale contract from database.\n", id)
-					e := bucket.Delete([]byte(id[:]))
+					e := deleteStorageObligation(tx, id)
 					return errors.AddContext(e, "Error deleting contract from database")
 				})
 				if err != nil {
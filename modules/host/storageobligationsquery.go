@@ -0,0 +1,327 @@
+package host
+
+// storageobligationsquery.go implements filtered and paginated queries of the
+// storage obligations. The obligations are indexed in secondary buckets of the
+// host database, sorted by their expiration height, and each index key ends
+// with the position of the obligation in the sort order. A metadata bucket
+// holds the fields of the obligations that are needed for filtering and
+// aggregation, which avoids decoding the full obligations with their sector
+// roots for anything but the returned page.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+	"gitlab.com/NebulousLabs/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// obligationPositionSize is the size of the position of an obligation in
+	// the indexes, the expiration height followed by the file contract id.
+	obligationPositionSize = 8 + crypto.HashSize
+)
+
+var (
+	// errInvalidObligationCursor is returned if the cursor of a storage
+	// obligation query can't be decoded.
+	errInvalidObligationCursor = errors.New("invalid storage obligation cursor")
+
+	// errInvalidObligationStatus is returned if the status of a storage
+	// obligation query is unknown.
+	errInvalidObligationStatus = errors.New("invalid storage obligation status")
+)
+
+// storageObligationMetadata are the fields of a storage obligation that are
+// indexed and aggregated by storage obligation queries.
+type storageObligationMetadata struct {
+	ExpirationHeight types.BlockHeight
+	RenterKeyHash    crypto.Hash
+	Status           storageObligationStatus
+
+	DataSize                 uint64
+	ContractCost             types.Currency
+	LockedCollateral         types.Currency
+	PotentialAccountFunding  types.Currency
+	PotentialDownloadRevenue types.Currency
+	PotentialStorageRevenue  types.Currency
+	PotentialUploadRevenue   types.Currency
+	RiskedCollateral         types.Currency
+}
+
+// storageObligationIndexKey is the key of a storage obligation in one of the
+// index buckets.
+type storageObligationIndexKey struct {
+	bucket []byte
+	key    []byte
+}
+
+// newStorageObligationMetadata returns the metadata of the storage obligation.
+func newStorageObligationMetadata(so storageObligation) storageObligationMetadata {
+	md := storageObligationMetadata{
+		ExpirationHeight: so.expiration(),
+		Status:           so.ObligationStatus,
+
+		DataSize:                 so.fileSize(),
+		ContractCost:             so.ContractCost,
+		LockedCollateral:         so.LockedCollateral,
+		PotentialAccountFunding:  so.PotentialAccountFunding,
+		PotentialDownloadRevenue: so.PotentialDownloadRevenue,
+		PotentialStorageRevenue:  so.PotentialStorageRevenue,
+		PotentialUploadRevenue:   so.PotentialUploadRevenue,
+		RiskedCollateral:         so.RiskedCollateral,
+	}
+	if spk := so.renterPublicKey(); len(spk.Key) > 0 {
+		md.RenterKeyHash = crypto.HashObject(spk)
+	}
+	return md
+}
+
+// revenue returns the revenue of the storage obligation if it succeeds.
+func (md storageObligationMetadata) revenue() types.Currency {
+	return md.ContractCost.Add(md.PotentialStorageRevenue).Add(md.PotentialDownloadRevenue).Add(md.PotentialUploadRevenue).Add(md.PotentialAccountFunding)
+}
+
+// position returns the position of the storage obligation in the indexes.
+func (md storageObligationMetadata) position(id types.FileContractID) []byte {
+	pos := make([]byte, obligationPositionSize)
+	binary.BigEndian.PutUint64(pos, uint64(md.ExpirationHeight))
+	copy(pos[8:], id[:])
+	return pos
+}
+
+// indexKeys returns the keys of the storage obligation in the index buckets.
+func (md storageObligationMetadata) indexKeys(id types.FileContractID) []storageObligationIndexKey {
+	pos := md.position(id)
+	status := make([]byte, 8)
+	binary.BigEndian.PutUint64(status, uint64(md.Status))
+	keys := []storageObligationIndexKey{
+		{bucketStorageObligationsByExpiration, pos},
+		{bucketStorageObligationsByStatus, append(status, pos...)},
+	}
+	if md.RenterKeyHash != (crypto.Hash{}) {
+		keys = append(keys, storageObligationIndexKey{bucketStorageObligationsByRenter, append(md.RenterKeyHash[:], pos...)})
+	}
+	return keys
+}
+
+// addToSummary adds the storage obligation to the summary.
+func (md storageObligationMetadata) addToSummary(s *modules.StorageObligationSummary) {
+	s.Count++
+	s.DataSize += md.DataSize
+	s.ContractCost = s.ContractCost.Add(md.ContractCost)
+	s.LockedCollateral = s.LockedCollateral.Add(md.LockedCollateral)
+	s.PotentialAccountFunding = s.PotentialAccountFunding.Add(md.PotentialAccountFunding)
+	s.PotentialDownloadRevenue = s.PotentialDownloadRevenue.Add(md.PotentialDownloadRevenue)
+	s.PotentialStorageRevenue = s.PotentialStorageRevenue.Add(md.PotentialStorageRevenue)
+	s.PotentialUploadRevenue = s.PotentialUploadRevenue.Add(md.PotentialUploadRevenue)
+	s.RiskedCollateral = s.RiskedCollateral.Add(md.RiskedCollateral)
+	s.Revenue = s.Revenue.Add(md.revenue())
+}
+
+// putStorageObligationIndex updates the metadata and the index keys of the
+// storage obligation.
+func putStorageObligationIndex(tx *bolt.Tx, so storageObligation) error {
+	id := so.id()
+	err := deleteStorageObligationIndex(tx, id)
+	if err != nil {
+		return err
+	}
+	md := newStorageObligationMetadata(so)
+	for _, ik := range md.indexKeys(id) {
+		err = tx.Bucket(ik.bucket).Put(ik.key, []byte{})
+		if err != nil {
+			return err
+		}
+	}
+	mdBytes, err := json.Marshal(md)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucketStorageObligationMetadata).Put(id[:], mdBytes)
+}
+
+// deleteStorageObligationIndex removes the metadata and the index keys of the
+// storage obligation.
+func deleteStorageObligationIndex(tx *bolt.Tx, id types.FileContractID) error {
+	bmd := tx.Bucket(bucketStorageObligationMetadata)
+	mdBytes := bmd.Get(id[:])
+	if mdBytes == nil {
+		return nil
+	}
+	var md storageObligationMetadata
+	err := json.Unmarshal(mdBytes, &md)
+	if err != nil {
+		return errors.AddContext(err, "unable to unmarshal storage obligation metadata")
+	}
+	for _, ik := range md.indexKeys(id) {
+		err = tx.Bucket(ik.bucket).Delete(ik.key)
+		if err != nil {
+			return err
+		}
+	}
+	return bmd.Delete(id[:])
+}
+
+// deleteStorageObligation removes a storage obligation from the database.
+func deleteStorageObligation(tx *bolt.Tx, id types.FileContractID) error {
+	err := deleteStorageObligationIndex(tx, id)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucketStorageObligations).Delete(id[:])
+}
+
+// buildStorageObligationIndexes indexes all storage obligations in the
+// database, replacing any existing indexes. Corrupt obligations are skipped,
+// they are pruned when the financial metrics are reset.
+func buildStorageObligationIndexes(tx *bolt.Tx) error {
+	buckets := [][]byte{
+		bucketStorageObligationMetadata,
+		bucketStorageObligationsByExpiration,
+		bucketStorageObligationsByRenter,
+		bucketStorageObligationsByStatus,
+	}
+	for _, bucket := range buckets {
+		err := tx.DeleteBucket(bucket)
+		if err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		_, err = tx.CreateBucket(bucket)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+		var so storageObligation
+		if err := json.Unmarshal(soBytes, &so); err != nil || len(so.OriginTransactionSet) == 0 {
+			return nil
+		}
+		return putStorageObligationIndex(tx, so)
+	})
+}
+
+// parseStorageObligationStatus parses the status of a storage obligation query,
+// with or without the "obligation" prefix.
+func parseStorageObligationStatus(s string) (storageObligationStatus, error) {
+	for sos := obligationUnresolved; sos <= obligationFailed; sos++ {
+		if strings.EqualFold(s, sos.String()) || strings.EqualFold(s, strings.TrimPrefix(sos.String(), "obligation")) {
+			return sos, nil
+		}
+	}
+	return 0, errInvalidObligationStatus
+}
+
+// QueryStorageObligations returns a page of the storage obligations matching
+// the query, sorted by their expiration height, along with the aggregates of
+// all matching obligations. The most selective index of the query is scanned,
+// only the obligations of the returned page are read from the database.
+func (h *Host) QueryStorageObligations(q modules.StorageObligationQuery) (modules.StorageObligationQueryResult, error) {
+	err := h.tg.Add()
+	if err != nil {
+		return modules.StorageObligationQueryResult{}, err
+	}
+	defer h.tg.Done()
+
+	var status storageObligationStatus
+	if q.Status != "" {
+		status, err = parseStorageObligationStatus(q.Status)
+		if err != nil {
+			return modules.StorageObligationQueryResult{}, err
+		}
+	}
+	var cursor []byte
+	if q.Cursor != "" {
+		cursor, err = hex.DecodeString(q.Cursor)
+		if err != nil || len(cursor) != obligationPositionSize {
+			return modules.StorageObligationQueryResult{}, errInvalidObligationCursor
+		}
+	}
+	var renterKeyHash crypto.Hash
+	if len(q.RenterPublicKey.Key) > 0 {
+		renterKeyHash = crypto.HashObject(q.RenterPublicKey)
+	}
+
+	// Scan the index for the renter or the status if the query has one,
+	// otherwise the expiration index.
+	var bucket, prefix []byte
+	switch {
+	case len(q.RenterPublicKey.Key) > 0:
+		bucket, prefix = bucketStorageObligationsByRenter, renterKeyHash[:]
+	case q.Status != "":
+		bucket, prefix = bucketStorageObligationsByStatus, make([]byte, 8)
+		binary.BigEndian.PutUint64(prefix, uint64(status))
+	default:
+		bucket = bucketStorageObligationsByExpiration
+	}
+	start := make([]byte, len(prefix)+8)
+	copy(start, prefix)
+	binary.BigEndian.PutUint64(start[len(prefix):], uint64(q.MinExpirationHeight))
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var result modules.StorageObligationQueryResult
+	result.Obligations = []modules.StorageObligation{}
+	err = h.db.View(func(tx *bolt.Tx) error {
+		bmd := tx.Bucket(bucketStorageObligationMetadata)
+		var page []types.FileContractID
+		var last []byte
+		c := tx.Bucket(bucket).Cursor()
+		for k, _ := c.Seek(start); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			pos := k[len(prefix):]
+			if q.MaxExpirationHeight != 0 && types.BlockHeight(binary.BigEndian.Uint64(pos[:8])) > q.MaxExpirationHeight {
+				break
+			}
+			var id types.FileContractID
+			copy(id[:], pos[8:])
+			var md storageObligationMetadata
+			err := json.Unmarshal(bmd.Get(id[:]), &md)
+			if err != nil {
+				return errors.AddContext(err, "unable to unmarshal storage obligation metadata")
+			}
+
+			// Filter the obligation.
+			if q.Status != "" && md.Status != status {
+				continue
+			}
+			if len(q.RenterPublicKey.Key) > 0 && md.RenterKeyHash != renterKeyHash {
+				continue
+			}
+			revenue := md.revenue()
+			if revenue.Cmp(q.MinRevenue) < 0 || (!q.MaxRevenue.IsZero() && revenue.Cmp(q.MaxRevenue) > 0) {
+				continue
+			}
+			md.addToSummary(&result.Summary)
+
+			// Add the obligation to the page if it's after the cursor.
+			if cursor != nil && bytes.Compare(pos, cursor) <= 0 {
+				continue
+			}
+			if q.Limit == 0 || uint64(len(page)) < q.Limit {
+				page = append(page, id)
+				last = append(last[:0], pos...)
+			} else if result.NextCursor == "" {
+				result.NextCursor = hex.EncodeToString(last)
+			}
+		}
+
+		for _, id := range page {
+			so, err := h.getStorageObligation(tx, id)
+			if err != nil {
+				return errors.AddContext(err, "unable to get storage obligation")
+			}
+			result.Obligations = append(result.Obligations, so.StorageObligation())
+		}
+		return nil
+	})
+	if err != nil {
+		return modules.StorageObligationQueryResult{}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,188 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+	"gitlab.com/NebulousLabs/fastrand"
+	bolt "go.etcd.io/bbolt"
+)
+
+// newQueryTestStorageObligation returns a storage obligation with the
+// expiration height, renter key and contract cost, which is only used to test
+// the storage obligation queries.
+func newQueryTestStorageObligation(expiration types.BlockHeight, renterKey types.SiaPublicKey, cost uint64) storageObligation {
+	return storageObligation{
+		OriginTransactionSet: []types.Transaction{{
+			FileContracts: []types.FileContract{{
+				WindowStart: expiration,
+				WindowEnd:   expiration + 10,
+			}},
+			ArbitraryData: [][]byte{fastrand.Bytes(16)},
+		}},
+		RevisionTransactionSet: []types.Transaction{{
+			FileContractRevisions: []types.FileContractRevision{{
+				NewWindowStart: expiration,
+				NewWindowEnd:   expiration + 10,
+				NewFileSize:    modules.SectorSize,
+				UnlockConditions: types.UnlockConditions{
+					PublicKeys: []types.SiaPublicKey{renterKey},
+				},
+			}},
+		}},
+		ContractCost: types.NewCurrency64(cost),
+	}
+}
+
+// queryAllStorageObligations pages through all storage obligations matching
+// the query and returns their ids.
+func queryAllStorageObligations(h *Host, q modules.StorageObligationQuery) ([]types.FileContractID, error) {
+	var ids []types.FileContractID
+	for {
+		result, err := h.QueryStorageObligations(q)
+		if err != nil {
+			return nil, err
+		}
+		for _, so := range result.Obligations {
+			ids = append(ids, so.ObligationId)
+		}
+		if result.NextCursor == "" {
+			return ids, nil
+		}
+		q.Cursor = result.NextCursor
+	}
+}
+
+// TestQueryStorageObligations checks that the storage obligation queries
+// filter, paginate and aggregate the obligations, and that the indexes follow
+// updates and deletions of the obligations.
+func TestQueryStorageObligations(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := blankHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Add 10 obligations, the first 4 of renter A and the rest of renter B.
+	// Every other obligation succeeded.
+	renterA := types.Ed25519PublicKey(crypto.PublicKey{1})
+	renterB := types.Ed25519PublicKey(crypto.PublicKey{2})
+	var sos []storageObligation
+	for i := 0; i < 10; i++ {
+		renter := renterB
+		if i < 4 {
+			renter = renterA
+		}
+		so := newQueryTestStorageObligation(types.BlockHeight(1000+i), renter, uint64(i*10))
+		so.NegotiationHeight = ht.host.blockHeight
+		if i%2 == 1 {
+			so.ObligationStatus = obligationSucceeded
+		}
+		sos = append(sos, so)
+	}
+	// Insert the obligations in reverse order, the results should be sorted
+	// by the expiration height.
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		for i := len(sos) - 1; i >= 0; i-- {
+			if err := putStorageObligation(tx, sos[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// checkQuery checks that the query matches the obligations with the
+	// indices, both as a single page and with pagination.
+	checkQuery := func(q modules.StorageObligationQuery, indices ...int) {
+		t.Helper()
+		result, err := ht.host.QueryStorageObligations(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.NextCursor != "" || result.Summary.Count != uint64(len(indices)) || len(result.Obligations) != len(indices) {
+			t.Fatalf("expected %v obligations, got %v with summary count %v", len(indices), len(result.Obligations), result.Summary.Count)
+		}
+		var cost uint64
+		for i, so := range result.Obligations {
+			if so.ObligationId != sos[indices[i]].id() {
+				t.Fatalf("wrong obligation at %v", i)
+			}
+			cost += uint64(indices[i] * 10)
+		}
+		if !result.Summary.ContractCost.Equals64(cost) || !result.Summary.Revenue.Equals64(cost) || result.Summary.DataSize != uint64(len(indices))*modules.SectorSize {
+			t.Fatal("wrong summary", result.Summary)
+		}
+		q.Limit = 3
+		ids, err := queryAllStorageObligations(ht.host, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != len(indices) {
+			t.Fatalf("expected %v paginated obligations, got %v", len(indices), len(ids))
+		}
+		for i, id := range ids {
+			if id != sos[indices[i]].id() {
+				t.Fatalf("wrong paginated obligation at %v", i)
+			}
+		}
+	}
+	checkQuery(modules.StorageObligationQuery{}, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+	checkQuery(modules.StorageObligationQuery{Status: "obligationSucceeded"}, 1, 3, 5, 7, 9)
+	checkQuery(modules.StorageObligationQuery{Status: "unresolved"}, 0, 2, 4, 6, 8)
+	checkQuery(modules.StorageObligationQuery{RenterPublicKey: renterA}, 0, 1, 2, 3)
+	checkQuery(modules.StorageObligationQuery{RenterPublicKey: renterB, Status: "succeeded"}, 5, 7, 9)
+	checkQuery(modules.StorageObligationQuery{MinExpirationHeight: 1002, MaxExpirationHeight: 1005}, 2, 3, 4, 5)
+	checkQuery(modules.StorageObligationQuery{Status: "failed"})
+	checkQuery(modules.StorageObligationQuery{MinRevenue: types.NewCurrency64(50)}, 5, 6, 7, 8, 9)
+	checkQuery(modules.StorageObligationQuery{MinRevenue: types.NewCurrency64(20), MaxRevenue: types.NewCurrency64(40), RenterPublicKey: renterA}, 2, 3)
+
+	// Invalid queries should be rejected.
+	if _, err := ht.host.QueryStorageObligations(modules.StorageObligationQuery{Status: "pending"}); err != errInvalidObligationStatus {
+		t.Fatal("expected errInvalidObligationStatus", err)
+	}
+	if _, err := ht.host.QueryStorageObligations(modules.StorageObligationQuery{Cursor: "abcd"}); err != errInvalidObligationCursor {
+		t.Fatal("expected errInvalidObligationCursor", err)
+	}
+
+	// Update and delete obligations, the indexes should follow.
+	sos[0].ObligationStatus = obligationFailed
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		if err := putStorageObligation(tx, sos[0]); err != nil {
+			return err
+		}
+		return deleteStorageObligation(tx, sos[9].id())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkQuery(modules.StorageObligationQuery{Status: "failed"}, 0)
+	checkQuery(modules.StorageObligationQuery{Status: "unresolved"}, 2, 4, 6, 8)
+	checkQuery(modules.StorageObligationQuery{RenterPublicKey: renterB}, 4, 5, 6, 7, 8)
+
+	// Drop the indexes, they should be rebuilt when the host is reopened.
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		return tx.DeleteBucket(bucketStorageObligationMetadata)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ht.host.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = reopenHost(ht)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkQuery(modules.StorageObligationQuery{}, 0, 1, 2, 3, 4, 5, 6, 7, 8)
+	checkQuery(modules.StorageObligationQuery{RenterPublicKey: renterA, Status: "failed"}, 0)
+}
@@ -0,0 +1,355 @@
+package tokenstorage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/modules/host/tokenstorage/tokenstate"
+)
+
+var (
+	// ErrSnapshotChecksum is an error indicating that the snapshot of the token storage is corrupted.
+	ErrSnapshotChecksum = errors.New("checksum of token storage snapshot doesn't match")
+
+	// ErrStateMismatch is an error indicating that replaying the snapshot and the events log doesn't
+	// result in the state of the token storage.
+	ErrStateMismatch = errors.New("token storage state doesn't match its snapshot and events log")
+)
+
+const (
+	snapshotFileName = "snapshot.json"
+	metaFileName     = "metadata.json"
+	snapshotVersion  = 1
+
+	// minCompactionMetaSize is the size the events log needs to reach before
+	// it is compacted periodically.
+	minCompactionMetaSize = 1 << 20
+)
+
+// snapshotFile is the content of the snapshot file. MetaEvents is the number
+// of events at the beginning of the events log that are included in the
+// snapshot, the remaining events are replayed on top of the snapshot.
+// MetaChecksum is the hash of these events, it tells whether the events log
+// still starts with them. Checksum is the hash of State.
+type snapshotFile struct {
+	Version      int             `json:"version"`
+	Time         time.Time       `json:"time"`
+	MetaEvents   int64           `json:"meta_events"`
+	MetaChecksum crypto.Hash     `json:"meta_checksum"`
+	Checksum     crypto.Hash     `json:"checksum"`
+	State        json.RawMessage `json:"state"`
+}
+
+// readSnapshot reads and checks the snapshot in the directory. It returns nil
+// if there is no snapshot.
+func readSnapshot(dir string) (*snapshotFile, *tokenstate.Snapshot, error) {
+	path := filepath.Join(dir, snapshotFileName)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var sf snapshotFile
+	if err := json.Unmarshal(data, &sf); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	if sf.Version != snapshotVersion {
+		return nil, nil, fmt.Errorf("unknown version %d of %s", sf.Version, path)
+	}
+	if crypto.HashBytes(sf.State) != sf.Checksum {
+		return nil, nil, ErrSnapshotChecksum
+	}
+	var snapshot tokenstate.Snapshot
+	if err := json.Unmarshal(sf.State, &snapshot); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode state of %s: %w", path, err)
+	}
+	return &sf, &snapshot, nil
+}
+
+// writeSnapshot atomically replaces the snapshot in the directory.
+func writeSnapshot(dir string, sf *snapshotFile) error {
+	data, err := json.Marshal(sf)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	path := filepath.Join(dir, snapshotFileName)
+	tmpPath := path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s for writing: %w", tmpPath, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", tmpPath, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("flushing %s failed: %w", tmpPath, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing %s failed: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("renaming %s to %s failed: %w", tmpPath, path, err)
+	}
+	return nil
+}
+
+// metaEvents returns the number of events in the events log and the hash of
+// the first prefix events.
+func metaEvents(dir string, prefix int64) (int64, crypto.Hash, error) {
+	h := crypto.NewHash()
+	var checksum crypto.Hash
+	f, err := os.Open(filepath.Join(dir, metaFileName))
+	if os.IsNotExist(err) {
+		h.Sum(checksum[:0])
+		return 0, checksum, nil
+	} else if err != nil {
+		return 0, crypto.Hash{}, err
+	}
+	defer f.Close()
+	var events int64
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			h.Sum(checksum[:0])
+			return events, checksum, nil
+		} else if err != nil {
+			return 0, crypto.Hash{}, err
+		}
+		if events < prefix {
+			h.Write(line)
+		}
+		events++
+	}
+}
+
+// loadState creates a state in stateDir from the snapshot and the events log
+// of the token storage in dir.
+// Mutex metaMu must be locked when this function is called, unless the
+// token storage is being created.
+func loadState(storage storage, dir, stateDir string) (*tokenstate.State, *snapshotFile, error) {
+	state, err := tokenstate.NewState(stateDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	sf, snapshot, err := readSnapshot(dir)
+	if err != nil {
+		state.Close()
+		return nil, nil, err
+	}
+	var offset int64
+	if snapshot != nil {
+		if err := state.LoadSnapshot(snapshot); err != nil {
+			state.Close()
+			return nil, nil, fmt.Errorf("failed to load snapshot: %w", err)
+		}
+		offset = sf.MetaEvents
+		// If compaction was interrupted after truncating the events log, the
+		// snapshot still refers to the events of the old log. All of the
+		// events of the new log are replayed then, even if the new log has
+		// grown beyond the old one.
+		events, checksum, err := metaEvents(dir, offset)
+		if err != nil {
+			state.Close()
+			return nil, nil, fmt.Errorf("failed to count events: %w", err)
+		}
+		if events < offset || checksum != sf.MetaChecksum {
+			offset = 0
+		}
+	}
+	err = storage.LoadMetaFromOffset(context.Background(), offset, func(r io.Reader) error {
+		return state.LoadHistory(r)
+	})
+	if err != nil {
+		state.Close()
+		return nil, nil, fmt.Errorf("failed to load history: %w", err)
+	}
+	return state, sf, nil
+}
+
+// encodeSnapshot encodes the snapshot of a state.
+func encodeSnapshot(state *tokenstate.State) ([]byte, *tokenstate.Snapshot, error) {
+	snapshot, err := state.Snapshot()
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	return data, snapshot, nil
+}
+
+func toSnapshotInfo(sf *snapshotFile, snapshot *tokenstate.Snapshot, logEvents int64) modules.TokenStorageSnapshot {
+	info := modules.TokenStorageSnapshot{
+		LogEvents: uint64(logEvents),
+		Tokens:    uint64(len(snapshot.Tokens)),
+		SubTokens: uint64(len(snapshot.SubTokens)),
+	}
+	if sf != nil {
+		info.Time = sf.Time
+		info.Checksum = sf.Checksum
+	}
+	return info
+}
+
+// Compact writes a snapshot of the state and truncates the events log, so
+// that the events don't have to be replayed on startup.
+func (t *TokenStorage) Compact() (modules.TokenStorageSnapshot, error) {
+	// No events are appended to the log during compaction.
+	t.metaMu.Lock()
+	defer t.metaMu.Unlock()
+
+	events, metaChecksum, err := metaEvents(t.dir, math.MaxInt64)
+	if err != nil {
+		return modules.TokenStorageSnapshot{}, fmt.Errorf("failed to count events: %w", err)
+	}
+	t.stateMu.Lock()
+	if t.closed {
+		t.stateMu.Unlock()
+		return modules.TokenStorageSnapshot{}, fmt.Errorf("token storage closed")
+	}
+	data, snapshot, err := encodeSnapshot(t.state)
+	if err != nil {
+		t.stateMu.Unlock()
+		return modules.TokenStorageSnapshot{}, err
+	}
+	// The events that are not written to the log yet are included in the
+	// snapshot.
+	queue := append([]interface{}(nil), t.eventsQueue...)
+	t.eventsQueue = t.eventsQueue[:0]
+	t.stateMu.Unlock()
+
+	sf := &snapshotFile{
+		Version:      snapshotVersion,
+		Time:         time.Now(),
+		MetaEvents:   events,
+		MetaChecksum: metaChecksum,
+		Checksum:     crypto.HashBytes(data),
+		State:        data,
+	}
+	// The snapshot is written before the log is truncated, so the state can be
+	// recovered if the host crashes during compaction.
+	if err := writeSnapshot(t.dir, sf); err != nil {
+		// The events are appended to the log again.
+		t.stateMu.Lock()
+		t.eventsQueue = append(queue, t.eventsQueue...)
+		t.stateMu.Unlock()
+		return modules.TokenStorageSnapshot{}, err
+	}
+	err = t.storage.ReplaceMeta(context.Background(), func(w io.Writer) error {
+		return nil
+	})
+	if err != nil {
+		return modules.TokenStorageSnapshot{}, fmt.Errorf("failed to truncate events log: %w", err)
+	}
+	sf.MetaEvents = 0
+	_, sf.MetaChecksum, err = metaEvents(t.dir, 0)
+	if err != nil {
+		return modules.TokenStorageSnapshot{}, fmt.Errorf("failed to hash events: %w", err)
+	}
+	// If this fails, the snapshot refers to the events of the old log, which
+	// is detected by the checksum of the events.
+	if err := writeSnapshot(t.dir, sf); err != nil {
+		return modules.TokenStorageSnapshot{}, err
+	}
+	log.Printf("Compacted %d events of token storage into a snapshot", events)
+	return toSnapshotInfo(sf, snapshot, 0), nil
+}
+
+// Verify checks the checksum of the snapshot and makes sure that replaying the
+// snapshot and the events log results in the current state.
+func (t *TokenStorage) Verify() (modules.TokenStorageSnapshot, error) {
+	// No events are appended to the log during verification.
+	t.metaMu.Lock()
+	defer t.metaMu.Unlock()
+
+	t.stateMu.Lock()
+	if t.closed {
+		t.stateMu.Unlock()
+		return modules.TokenStorageSnapshot{}, fmt.Errorf("token storage closed")
+	}
+	want, snapshot, err := encodeSnapshot(t.state)
+	if err != nil {
+		t.stateMu.Unlock()
+		return modules.TokenStorageSnapshot{}, err
+	}
+	// The events that are not written to the log yet are replayed as well.
+	queue := append([]interface{}(nil), t.eventsQueue...)
+	t.stateMu.Unlock()
+
+	stateDir, err := ioutil.TempDir(t.dir, "verify")
+	if err != nil {
+		return modules.TokenStorageSnapshot{}, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(stateDir); err != nil {
+			log.Printf("Failed to remove %s: %v", stateDir, err)
+		}
+	}()
+	state, sf, err := loadState(t.storage, t.dir, stateDir)
+	if err != nil {
+		return modules.TokenStorageSnapshot{}, err
+	}
+	defer state.Close()
+	for _, event := range queue {
+		state.Apply(event.(*tokenstate.Event))
+	}
+	got, _, err := encodeSnapshot(state)
+	if err != nil {
+		return modules.TokenStorageSnapshot{}, err
+	}
+	if !bytes.Equal(got, want) {
+		return modules.TokenStorageSnapshot{}, ErrStateMismatch
+	}
+	var prefix int64
+	if sf != nil {
+		prefix = sf.MetaEvents
+	}
+	events, checksum, err := metaEvents(t.dir, prefix)
+	if err != nil {
+		return modules.TokenStorageSnapshot{}, fmt.Errorf("failed to count events: %w", err)
+	}
+	if sf != nil && events >= sf.MetaEvents && checksum == sf.MetaChecksum {
+		events -= sf.MetaEvents
+	}
+	return toSnapshotInfo(sf, snapshot, events), nil
+}
+
+// CompactPeriodically compacts the events log once it grows large enough.
+func (t *TokenStorage) CompactPeriodically(frequency time.Duration, done chan bool) {
+	ticker := time.NewTicker(frequency)
+	log.Printf("CompactPeriodically started with frequency %s", frequency.String())
+	for {
+		select {
+		case <-done:
+			ticker.Stop()
+			return
+
+		case <-ticker.C:
+			info, err := os.Stat(filepath.Join(t.dir, metaFileName))
+			if err != nil || info.Size() < minCompactionMetaSize {
+				continue
+			}
+			if _, err := t.Compact(); err != nil {
+				log.Printf("Failed to compact token storage: %v", err)
+			}
+		}
+	}
+}
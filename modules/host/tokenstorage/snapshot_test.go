@@ -0,0 +1,158 @@
+package tokenstorage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/modules/host/contractmanager"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/NebulousLabs/fastrand"
+)
+
+func TestTokenStorage_CompactAndVerify(t *testing.T) {
+	stDir, err := ioutil.TempDir(os.TempDir(), "stDir0")
+	require.NoError(t, err)
+	defer os.RemoveAll(stDir)
+	stManager, err := contractmanager.NewCustomContractManager(new(modules.ProductionDependencies), stDir)
+	require.NoError(t, err)
+	defer stManager.Close()
+	dbDir, err := ioutil.TempDir(os.TempDir(), "dbDir0")
+	require.NoError(t, err)
+	defer os.RemoveAll(dbDir)
+
+	stor, err := NewTokenStorage(stManager, dbDir)
+	require.NoError(t, err)
+	var token types.TokenID
+	fastrand.Read(token[:])
+	var contractID types.FileContractID
+	fastrand.Read(contractID[:])
+	assert.NoError(t, stor.AddResources(token, contractID, modules.UploadBytes, int64(modules.SectorSize)))
+	assert.NoError(t, stor.AddResources(token, contractID, modules.Storage, 1000))
+	assert.NoError(t, stor.AddResources(token, contractID, modules.DownloadBytes, 5000))
+	var sectorID crypto.Hash
+	fastrand.Read(sectorID[:])
+	_, err = stor.AddSectors(token, []crypto.Hash{sectorID}, time.Now())
+	assert.NoError(t, err)
+	subToken, _, err := stor.MintSubToken(token, 1000, 0, time.Time{}, time.Now())
+	assert.NoError(t, err)
+
+	// Verify the full events log, then compact it.
+	info, err := stor.Verify()
+	assert.NoError(t, err)
+	assert.True(t, info.Time.IsZero())
+	info, err = stor.Compact()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), info.Tokens)
+	assert.Equal(t, uint64(1), info.SubTokens)
+	assert.Equal(t, uint64(0), info.LogEvents)
+
+	// The events after the snapshot are replayed on top of it.
+	_, err = stor.RecordDownload(subToken, 300, 0, time.Now())
+	assert.NoError(t, err)
+	assert.NoError(t, stor.drainEventsQueue())
+	info, err = stor.Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), info.LogEvents)
+	want, err := stor.TokenRecord(token)
+	assert.NoError(t, err)
+	require.NoError(t, stor.Close(context.Background()))
+
+	stor, err = NewTokenStorage(stManager, dbDir)
+	require.NoError(t, err)
+	got, err := stor.TokenRecord(token)
+	assert.NoError(t, err)
+	assert.Equal(t, want.DownloadBytes, got.DownloadBytes)
+	assert.Equal(t, want.TokenStorageInfo.SectorsNum, got.TokenStorageInfo.SectorsNum)
+	sectorIDs, _, err := stor.ListSectorIDs(token, "", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, []crypto.Hash{sectorID}, sectorIDs)
+	tr, err := stor.TokenRecord(subToken)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(700), tr.DownloadBytes)
+	require.NoError(t, stor.Close(context.Background()))
+
+	// A corrupted snapshot is detected.
+	path := filepath.Join(dbDir, snapshotFileName)
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	data = bytes.Replace(data, []byte(`"download_bytes_limit":1000`), []byte(`"download_bytes_limit":2000`), 1)
+	require.NoError(t, ioutil.WriteFile(path, data, 0600))
+	_, err = NewTokenStorage(stManager, dbDir)
+	assert.ErrorIs(t, err, ErrSnapshotChecksum)
+}
+
+// failingSnapshotStorage makes writing the snapshot fail after the events log
+// was truncated.
+type failingSnapshotStorage struct {
+	storage
+	dir string
+}
+
+func (s failingSnapshotStorage) ReplaceMeta(ctx context.Context, callback func(w io.Writer) error) error {
+	if err := s.storage.ReplaceMeta(ctx, callback); err != nil {
+		return err
+	}
+	// The temporary snapshot file can't be created if a directory is in its
+	// place.
+	return os.Mkdir(filepath.Join(s.dir, snapshotFileName+".tmp"), 0700)
+}
+
+func TestTokenStorage_CompactInterrupted(t *testing.T) {
+	stDir, err := ioutil.TempDir(os.TempDir(), "stDir1")
+	require.NoError(t, err)
+	defer os.RemoveAll(stDir)
+	stManager, err := contractmanager.NewCustomContractManager(new(modules.ProductionDependencies), stDir)
+	require.NoError(t, err)
+	defer stManager.Close()
+	dbDir, err := ioutil.TempDir(os.TempDir(), "dbDir1")
+	require.NoError(t, err)
+	defer os.RemoveAll(dbDir)
+
+	stor, err := NewTokenStorage(stManager, dbDir)
+	require.NoError(t, err)
+	var token types.TokenID
+	fastrand.Read(token[:])
+	var contractID types.FileContractID
+	fastrand.Read(contractID[:])
+	assert.NoError(t, stor.AddResources(token, contractID, modules.DownloadBytes, 5000))
+	assert.NoError(t, stor.AddResources(token, contractID, modules.UploadBytes, 5000))
+	assert.NoError(t, stor.drainEventsQueue())
+
+	// Writing the snapshot fails after the events log was truncated.
+	stor.storage = failingSnapshotStorage{storage: stor.storage, dir: dbDir}
+	_, err = stor.Compact()
+	require.Error(t, err)
+	require.NoError(t, os.Remove(filepath.Join(dbDir, snapshotFileName+".tmp")))
+
+	// Append more events than the snapshot refers to.
+	for i := 0; i < 3; i++ {
+		_, err = stor.RecordDownload(token, 100, 0, time.Now())
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, stor.drainEventsQueue())
+	want, err := stor.TokenRecord(token)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4700), want.DownloadBytes)
+	_, err = stor.Verify()
+	assert.NoError(t, err)
+	require.NoError(t, stor.Close(context.Background()))
+
+	// None of the new events are lost on restart.
+	stor, err = NewTokenStorage(stManager, dbDir)
+	require.NoError(t, err)
+	got, err := stor.TokenRecord(token)
+	assert.NoError(t, err)
+	assert.Equal(t, want.DownloadBytes, got.DownloadBytes)
+	assert.Equal(t, want.UploadBytes, got.UploadBytes)
+	require.NoError(t, stor.Close(context.Background()))
+}
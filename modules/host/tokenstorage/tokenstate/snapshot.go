@@ -0,0 +1,122 @@
+package tokenstate
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+)
+
+// TokenSnapshot include a token record and the sectors of the token.
+type TokenSnapshot struct {
+	TokenID types.TokenID `json:"token_id"`
+	Record  TokenRecord   `json:"record"`
+	Sectors []crypto.Hash `json:"sectors"`
+}
+
+// SubTokenSnapshot include a sub-token record.
+type SubTokenSnapshot struct {
+	SubTokenID types.TokenID  `json:"sub_token_id"`
+	Record     SubTokenRecord `json:"record"`
+}
+
+// TopUpSnapshot include a top-up paid by a contract.
+type TopUpSnapshot struct {
+	ContractID     types.FileContractID `json:"contract_id"`
+	TokenID        types.TokenID        `json:"token_id"`
+	ResourceType   types.Specifier      `json:"resource_type"`
+	ResourceAmount int64                `json:"resource_amount"`
+	Reverted       bool                 `json:"reverted"`
+}
+
+// Snapshot is the full state at some point of the events history. Replaying
+// the events that follow the snapshot results in the same state as replaying
+// the whole history. The entries are sorted, so equal states have equal
+// snapshots.
+type Snapshot struct {
+	Tokens    []TokenSnapshot    `json:"tokens"`
+	SubTokens []SubTokenSnapshot `json:"sub_tokens"`
+	TopUps    []TopUpSnapshot    `json:"top_ups"`
+}
+
+// Snapshot returns a snapshot of the state.
+func (s *State) Snapshot() (*Snapshot, error) {
+	snapshot := &Snapshot{
+		Tokens:    make([]TokenSnapshot, 0, len(s.Tokens)),
+		SubTokens: make([]SubTokenSnapshot, 0, len(s.SubTokens)),
+		TopUps:    []TopUpSnapshot{},
+	}
+	for id, record := range s.Tokens {
+		sectors, err := s.db.Get(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get sectors of token %s: %w", id.String(), err)
+		}
+		snapshot.Tokens = append(snapshot.Tokens, TokenSnapshot{
+			TokenID: id,
+			Record:  record,
+			Sectors: sectors,
+		})
+	}
+	sort.Slice(snapshot.Tokens, func(i, j int) bool {
+		return bytes.Compare(snapshot.Tokens[i].TokenID[:], snapshot.Tokens[j].TokenID[:]) < 0
+	})
+	for id, record := range s.SubTokens {
+		snapshot.SubTokens = append(snapshot.SubTokens, SubTokenSnapshot{
+			SubTokenID: id,
+			Record:     record,
+		})
+	}
+	sort.Slice(snapshot.SubTokens, func(i, j int) bool {
+		return bytes.Compare(snapshot.SubTokens[i].SubTokenID[:], snapshot.SubTokens[j].SubTokenID[:]) < 0
+	})
+	contractIDs := make([]types.FileContractID, 0, len(s.topUps))
+	for contractID := range s.topUps {
+		contractIDs = append(contractIDs, contractID)
+	}
+	sort.Slice(contractIDs, func(i, j int) bool {
+		return bytes.Compare(contractIDs[i][:], contractIDs[j][:]) < 0
+	})
+	// The top-ups of a contract keep their order.
+	for _, contractID := range contractIDs {
+		for _, topUp := range s.topUps[contractID] {
+			snapshot.TopUps = append(snapshot.TopUps, TopUpSnapshot{
+				ContractID:     contractID,
+				TokenID:        topUp.TokenID,
+				ResourceType:   topUp.ResourceType,
+				ResourceAmount: topUp.ResourceAmount,
+				Reverted:       topUp.Reverted,
+			})
+		}
+	}
+	return snapshot, nil
+}
+
+// LoadSnapshot loads a snapshot into an empty state. The events that follow
+// the snapshot are loaded with LoadHistory afterwards.
+func (s *State) LoadSnapshot(snapshot *Snapshot) error {
+	if len(s.Tokens) != 0 || len(s.SubTokens) != 0 || len(s.topUps) != 0 {
+		return fmt.Errorf("snapshot can only be loaded into an empty state")
+	}
+	for _, token := range snapshot.Tokens {
+		s.Tokens[token.TokenID] = token.Record
+		for _, sectorID := range token.Sectors {
+			if err := s.db.Put(token.TokenID, sectorID); err != nil {
+				return fmt.Errorf("failed to put sector of token %s: %w", token.TokenID.String(), err)
+			}
+		}
+	}
+	for _, subToken := range snapshot.SubTokens {
+		s.SubTokens[subToken.SubTokenID] = subToken.Record
+	}
+	for _, topUp := range snapshot.TopUps {
+		s.topUps[topUp.ContractID] = append(s.topUps[topUp.ContractID], &contractTopUp{
+			TokenID:        topUp.TokenID,
+			ResourceType:   topUp.ResourceType,
+			ResourceAmount: topUp.ResourceAmount,
+			Reverted:       topUp.Reverted,
+		})
+	}
+	return nil
+}
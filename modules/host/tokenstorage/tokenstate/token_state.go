@@ -17,12 +17,38 @@ type tokenStorageInfo struct {
 	SectorsNum     uint64    `json:"sectors_num"`
 }
 
+// RevertedResources include the resources that were removed from a token
+// because the contracts that paid for them were reverted.
+type RevertedResources struct {
+	DownloadBytes  int64 `json:"download_bytes"`
+	UploadBytes    int64 `json:"upload_bytes"`
+	SectorAccesses int64 `json:"sector_accesses"`
+	Storage        int64 `json:"storage"`
+}
+
 // TokenRecord include information about token record.
 type TokenRecord struct {
-	DownloadBytes  int64            `json:"download_bytes"`
-	UploadBytes    int64            `json:"upload_bytes"`
-	SectorAccesses int64            `json:"sector_accesses"`
-	TokenInfo      tokenStorageInfo `json:"token_info"`
+	DownloadBytes  int64             `json:"download_bytes"`
+	UploadBytes    int64             `json:"upload_bytes"`
+	SectorAccesses int64             `json:"sector_accesses"`
+	TokenInfo      tokenStorageInfo  `json:"token_info"`
+	Reverted       RevertedResources `json:"reverted"`
+	// ExpirationTime is the time after which the token can't be used. The
+	// token never expires if it is zero.
+	ExpirationTime time.Time `json:"expiration_time"`
+}
+
+// SubTokenRecord include information about a sub-token. A sub-token can only
+// be used for downloads, and it draws its resources from the parent token up
+// to the limits.
+type SubTokenRecord struct {
+	ParentID           types.TokenID `json:"parent_id"`
+	DownloadBytesLimit int64         `json:"download_bytes_limit"`
+	// SectorAccessesLimit is not enforced if it is zero.
+	SectorAccessesLimit int64     `json:"sector_accesses_limit"`
+	DownloadBytesUsed   int64     `json:"download_bytes_used"`
+	SectorAccessesUsed  int64     `json:"sector_accesses_used"`
+	ExpirationTime      time.Time `json:"expiration_time"`
 }
 
 // AttachSectorsData include information about token sector and storing it.
@@ -35,10 +61,51 @@ type AttachSectorsData struct {
 }
 
 // EventTopUp change of state when token replenishment.
+// ContractID is the contract that paid for the resources, it is empty for
+// top-ups that were recorded before contracts were tracked.
 type EventTopUp struct {
-	TokenID        types.TokenID   `json:"token_id"`
-	ResourceType   types.Specifier `json:"resource_type"`
-	ResourceAmount int64           `json:"resource_amount"`
+	TokenID        types.TokenID        `json:"token_id"`
+	ResourceType   types.Specifier      `json:"resource_type"`
+	ResourceAmount int64                `json:"resource_amount"`
+	ContractID     types.FileContractID `json:"contract_id"`
+}
+
+// EventRevertTopUps represent removing the resources paid by a contract
+// because the block that confirmed the contract was reverted.
+type EventRevertTopUps struct {
+	ContractID types.FileContractID `json:"contract_id"`
+	BlockID    types.BlockID        `json:"block_id"`
+}
+
+// EventConfirmTopUps represent restoring the resources of reverted top-ups
+// because the contract that paid for them was confirmed again.
+type EventConfirmTopUps struct {
+	ContractID types.FileContractID `json:"contract_id"`
+	BlockID    types.BlockID        `json:"block_id"`
+}
+
+// EventSetExpiration represent setting the time after which a token or a
+// sub-token can't be used.
+type EventSetExpiration struct {
+	TokenID        types.TokenID `json:"token_id"`
+	ExpirationTime time.Time     `json:"expiration_time"`
+}
+
+// EventTransfer represent moving all resources and sectors of a token to
+// another token. The sub-tokens of the source token are moved as well.
+type EventTransfer struct {
+	FromTokenID types.TokenID `json:"from_token_id"`
+	ToTokenID   types.TokenID `json:"to_token_id"`
+}
+
+// EventMintSubToken represent creating a download-only sub-token that draws
+// its resources from the parent token.
+type EventMintSubToken struct {
+	ParentID            types.TokenID `json:"parent_id"`
+	SubTokenID          types.TokenID `json:"sub_token_id"`
+	DownloadBytesLimit  int64         `json:"download_bytes_limit"`
+	SectorAccessesLimit int64         `json:"sector_accesses_limit"`
+	ExpirationTime      time.Time     `json:"expiration_time"`
 }
 
 // EventTokenDownload change of state when downloading.
@@ -79,9 +146,23 @@ type Event struct {
 	EventRemoveSpecificSectors *EventRemoveSpecificSectors `json:"event_remove_specific_sectors"`
 	EventRemoveAllSectors      *EventRemoveAllSectors      `json:"event_remove_sectors"`
 	EventAttachSectors         *EventAttachSectors         `json:"event_attach_sectors"`
+	EventRevertTopUps          *EventRevertTopUps          `json:"event_revert_top_ups,omitempty"`
+	EventConfirmTopUps         *EventConfirmTopUps         `json:"event_confirm_top_ups,omitempty"`
+	EventSetExpiration         *EventSetExpiration         `json:"event_set_expiration,omitempty"`
+	EventTransfer              *EventTransfer              `json:"event_transfer,omitempty"`
+	EventMintSubToken          *EventMintSubToken          `json:"event_mint_sub_token,omitempty"`
 	Time                       time.Time                   `json:"time"`
 }
 
+// contractTopUp is a top-up that was paid by a contract. Reverted is set while
+// the resources of the top-up are removed from the token.
+type contractTopUp struct {
+	TokenID        types.TokenID
+	ResourceType   types.Specifier
+	ResourceAmount int64
+	Reverted       bool
+}
+
 type sectorsDBer interface {
 	Get(tokenID types.TokenID) ([]crypto.Hash, error)
 	GetLimited(tokenID types.TokenID, pageID string, limit int) ([]crypto.Hash, string, error)
@@ -95,7 +176,10 @@ type sectorsDBer interface {
 
 // State representation of token storage state.
 type State struct {
-	Tokens map[types.TokenID]TokenRecord `json:"tokens"`
+	Tokens    map[types.TokenID]TokenRecord    `json:"tokens"`
+	SubTokens map[types.TokenID]SubTokenRecord `json:"sub_tokens"`
+	// topUps are the top-ups by the contract that paid for them.
+	topUps map[types.FileContractID][]*contractTopUp
 	db     sectorsDBer
 }
 
@@ -106,8 +190,10 @@ func NewState(dir string) (*State, error) {
 		return nil, err
 	}
 	return &State{
-		Tokens: make(map[types.TokenID]TokenRecord),
-		db:     db,
+		Tokens:    make(map[types.TokenID]TokenRecord),
+		SubTokens: make(map[types.TokenID]SubTokenRecord),
+		topUps:    make(map[types.FileContractID][]*contractTopUp),
+		db:        db,
 	}, nil
 }
 
@@ -139,34 +225,211 @@ func (s *State) Apply(e *Event) {
 		s.eventAttachSectors(e.EventAttachSectors, e.Time)
 		applied++
 	}
+	if e.EventRevertTopUps != nil {
+		s.eventRevertTopUps(e.EventRevertTopUps)
+		applied++
+	}
+	if e.EventConfirmTopUps != nil {
+		s.eventConfirmTopUps(e.EventConfirmTopUps)
+		applied++
+	}
+	if e.EventSetExpiration != nil {
+		s.eventSetExpiration(e.EventSetExpiration)
+		applied++
+	}
+	if e.EventTransfer != nil {
+		s.eventTransfer(e.EventTransfer, e.Time)
+		applied++
+	}
+	if e.EventMintSubToken != nil {
+		s.eventMintSubToken(e.EventMintSubToken)
+		applied++
+	}
 	if applied != 1 {
 		panic(fmt.Sprintf("want 1 subevent, got %d", applied))
 	}
 }
 
-func (s *State) eventTopUp(e *EventTopUp) {
-	token := s.Tokens[e.TokenID]
+// addResource adds an amount of a resource to a token. The amount can be
+// negative.
+func (t *TokenRecord) addResource(resourceType types.Specifier, amount int64) {
+	switch resourceType {
+	case modules.DownloadBytes:
+		t.DownloadBytes += amount
+	case modules.UploadBytes:
+		t.UploadBytes += amount
+	case modules.SectorAccesses:
+		t.SectorAccesses += amount
+	case modules.Storage:
+		t.TokenInfo.Storage += amount
+	}
+}
 
-	switch e.ResourceType {
+// addReverted adds an amount of a resource to the reverted resources of a
+// token. The amount can be negative.
+func (r *RevertedResources) addReverted(resourceType types.Specifier, amount int64) {
+	switch resourceType {
 	case modules.DownloadBytes:
-		token.DownloadBytes += e.ResourceAmount
+		r.DownloadBytes += amount
 	case modules.UploadBytes:
-		token.UploadBytes += e.ResourceAmount
+		r.UploadBytes += amount
 	case modules.SectorAccesses:
-		token.SectorAccesses += e.ResourceAmount
+		r.SectorAccesses += amount
 	case modules.Storage:
-		token.TokenInfo.Storage += e.ResourceAmount
+		r.Storage += amount
 	}
+}
+
+func (s *State) eventTopUp(e *EventTopUp) {
+	token := s.Tokens[e.TokenID]
+	token.addResource(e.ResourceType, e.ResourceAmount)
 	s.Tokens[e.TokenID] = token
+
+	if e.ContractID != (types.FileContractID{}) {
+		s.topUps[e.ContractID] = append(s.topUps[e.ContractID], &contractTopUp{
+			TokenID:        e.TokenID,
+			ResourceType:   e.ResourceType,
+			ResourceAmount: e.ResourceAmount,
+		})
+	}
 }
 
+// eventRevertTopUps removes the resources of the top-ups paid by a contract.
+// The resources may have been spent already, so the balances of the tokens can
+// become negative.
+func (s *State) eventRevertTopUps(e *EventRevertTopUps) {
+	for _, topUp := range s.topUps[e.ContractID] {
+		if topUp.Reverted {
+			continue
+		}
+		token := s.Tokens[topUp.TokenID]
+		token.addResource(topUp.ResourceType, -topUp.ResourceAmount)
+		token.Reverted.addReverted(topUp.ResourceType, topUp.ResourceAmount)
+		s.Tokens[topUp.TokenID] = token
+		topUp.Reverted = true
+	}
+}
+
+// eventConfirmTopUps restores the resources of the reverted top-ups paid by a
+// contract.
+func (s *State) eventConfirmTopUps(e *EventConfirmTopUps) {
+	for _, topUp := range s.topUps[e.ContractID] {
+		if !topUp.Reverted {
+			continue
+		}
+		token := s.Tokens[topUp.TokenID]
+		token.addResource(topUp.ResourceType, topUp.ResourceAmount)
+		token.Reverted.addReverted(topUp.ResourceType, -topUp.ResourceAmount)
+		s.Tokens[topUp.TokenID] = token
+		topUp.Reverted = false
+	}
+}
+
+// HasTopUps returns whether a contract paid for top-ups that are in the
+// provided reverted state.
+func (s *State) HasTopUps(contractID types.FileContractID, reverted bool) bool {
+	for _, topUp := range s.topUps[contractID] {
+		if topUp.Reverted == reverted {
+			return true
+		}
+	}
+	return false
+}
+
+// eventTokenDownload spends download resources of a token. The downloads of a
+// sub-token are paid by its parent.
 func (s *State) eventTokenDownload(e *EventTokenDownload) {
-	token := s.Tokens[e.TokenID]
+	tokenID := e.TokenID
+	if subToken, ok := s.SubTokens[e.TokenID]; ok {
+		subToken.DownloadBytesUsed += e.DownloadBytes
+		subToken.SectorAccessesUsed += e.SectorAccesses
+		s.SubTokens[e.TokenID] = subToken
+		tokenID = subToken.ParentID
+	}
+	token := s.Tokens[tokenID]
 	token.DownloadBytes -= e.DownloadBytes
 	token.SectorAccesses -= e.SectorAccesses
+	s.Tokens[tokenID] = token
+}
+
+func (s *State) eventSetExpiration(e *EventSetExpiration) {
+	if subToken, ok := s.SubTokens[e.TokenID]; ok {
+		subToken.ExpirationTime = e.ExpirationTime
+		s.SubTokens[e.TokenID] = subToken
+		return
+	}
+	token := s.Tokens[e.TokenID]
+	token.ExpirationTime = e.ExpirationTime
 	s.Tokens[e.TokenID] = token
 }
 
+// eventTransfer moves a token to another token ID. If the destination token
+// exists, the tokens are merged and the earliest expiration time is kept.
+func (s *State) eventTransfer(e *EventTransfer, t time.Time) {
+	from := s.Tokens[e.FromTokenID]
+	to := s.Tokens[e.ToTokenID]
+	// Charge the storage used so far before merging the storage resources.
+	from.TokenInfo.updateStorageResource(0, t)
+	to.TokenInfo.updateStorageResource(0, t)
+
+	sectors, err := s.db.Get(e.FromTokenID)
+	if err != nil {
+		panic(err)
+	}
+	newSectors, err := s.db.NonexistentSectors(e.ToTokenID, sectors)
+	if err != nil {
+		panic(err)
+	}
+	for _, sec := range newSectors {
+		if err := s.db.Put(e.ToTokenID, sec); err != nil {
+			panic(err)
+		}
+	}
+	if err := s.db.BatchDeleteAll(e.FromTokenID); err != nil {
+		panic(err)
+	}
+
+	to.DownloadBytes += from.DownloadBytes
+	to.UploadBytes += from.UploadBytes
+	to.SectorAccesses += from.SectorAccesses
+	to.TokenInfo.Storage += from.TokenInfo.Storage
+	to.TokenInfo.SectorsNum += uint64(len(newSectors))
+	to.Reverted.DownloadBytes += from.Reverted.DownloadBytes
+	to.Reverted.UploadBytes += from.Reverted.UploadBytes
+	to.Reverted.SectorAccesses += from.Reverted.SectorAccesses
+	to.Reverted.Storage += from.Reverted.Storage
+	if to.ExpirationTime.IsZero() || (!from.ExpirationTime.IsZero() && from.ExpirationTime.Before(to.ExpirationTime)) {
+		to.ExpirationTime = from.ExpirationTime
+	}
+	s.Tokens[e.ToTokenID] = to
+	delete(s.Tokens, e.FromTokenID)
+
+	// Reverts of the contracts that paid for the token must affect the new
+	// token.
+	for _, topUps := range s.topUps {
+		for _, topUp := range topUps {
+			if topUp.TokenID == e.FromTokenID {
+				topUp.TokenID = e.ToTokenID
+			}
+		}
+	}
+	for id, subToken := range s.SubTokens {
+		if subToken.ParentID == e.FromTokenID {
+			subToken.ParentID = e.ToTokenID
+			s.SubTokens[id] = subToken
+		}
+	}
+}
+
+func (s *State) eventMintSubToken(e *EventMintSubToken) {
+	s.SubTokens[e.SubTokenID] = SubTokenRecord{
+		ParentID:            e.ParentID,
+		DownloadBytesLimit:  e.DownloadBytesLimit,
+		SectorAccessesLimit: e.SectorAccessesLimit,
+		ExpirationTime:      e.ExpirationTime,
+	}
+}
+
 func (s *State) eventAddSectors(e *EventAddSectors, t time.Time) {
 	token := s.Tokens[e.TokenID]
 	token.TokenInfo.updateStorageResource(int64(len(e.SectorsIDs)), t)
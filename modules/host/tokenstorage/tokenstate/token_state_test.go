@@ -14,7 +14,8 @@ import (
 )
 
 type stateForCmp struct {
-	Tokens map[string]TokenRecord `json:"tokens"`
+	Tokens    map[string]TokenRecord    `json:"tokens"`
+	SubTokens map[string]SubTokenRecord `json:"sub_tokens,omitempty"`
 }
 
 func encodeState(state *State) (result stateForCmp) {
@@ -23,6 +24,12 @@ func encodeState(state *State) (result stateForCmp) {
 	for tokenID, tokenRecord := range state.Tokens {
 		result.Tokens[tokenID.String()] = tokenRecord
 	}
+	if len(state.SubTokens) != 0 {
+		result.SubTokens = make(map[string]SubTokenRecord, len(state.SubTokens))
+		for tokenID, subTokenRecord := range state.SubTokens {
+			result.SubTokens[tokenID.String()] = subTokenRecord
+		}
+	}
 	return result
 }
 
@@ -17,6 +17,7 @@ import (
 	"github.com/EvilRedHorse/pubaccess-node/modules"
 	"github.com/EvilRedHorse/pubaccess-node/modules/host/tokenstorage/tokenstate"
 	"github.com/EvilRedHorse/pubaccess-node/types"
+	"gitlab.com/NebulousLabs/fastrand"
 	"gitlab.com/zer0main/eventsourcing"
 	"gitlab.com/zer0main/filestorage"
 )
@@ -36,10 +37,25 @@ var (
 
 	// ErrInsufficientStorage is an error indicating lack of Storage resource on the token.
 	ErrInsufficientStorage = errors.New("insufficient Storage for this operation")
-)
 
-// TODO: the current version of tokens storage does not support reverting blocks.
-// If contracts related to `TopUpToken` RPC are reverted, all the tokens resources remain in the storage.
+	// ErrTokenNotFound is an error indicating that the token has no record.
+	ErrTokenNotFound = errors.New("token not found")
+
+	// ErrTokenExpired is an error indicating that the expiration time of the token has passed.
+	ErrTokenExpired = errors.New("token expired")
+
+	// ErrExpirationExtended is an error indicating an attempt to move the expiration time of a token later.
+	ErrExpirationExtended = errors.New("expiration time of a token can't be extended")
+
+	// ErrSubTokenScope is an error indicating an operation that a sub-token is not allowed to perform.
+	ErrSubTokenScope = errors.New("sub-tokens can only be used for downloads")
+
+	// ErrTransferToSameToken is an error indicating an attempt to transfer a token to itself.
+	ErrTransferToSameToken = errors.New("can't transfer a token to itself")
+
+	// ErrInvalidSubTokenLimits is an error indicating non-positive download bytes limit or negative sector accesses limit of a sub-token.
+	ErrInvalidSubTokenLimits = errors.New("download bytes limit of a sub-token must be positive and sector accesses limit must not be negative")
+)
 
 const (
 	persistDelay = 1 * time.Second
@@ -53,18 +69,54 @@ type TokenStorageInfo struct {
 	SectorsNum     uint64
 }
 
+// RevertedResources represent resources that were removed from a token because
+// the contracts that paid for them were reverted.
+type RevertedResources struct {
+	DownloadBytes  int64
+	UploadBytes    int64
+	SectorAccesses int64
+	Storage        int64 // sectors * second
+}
+
 // TokenRecord include information about token record.
+// The resources can be negative if they were spent before the contracts that
+// paid for them were reverted.
+// The record of a sub-token includes the download resources it can still
+// spend, the parent token is not disclosed.
 type TokenRecord struct {
-	DownloadBytes    int64
-	UploadBytes      int64
-	SectorAccesses   int64
-	TokenStorageInfo TokenStorageInfo
+	DownloadBytes     int64
+	UploadBytes       int64
+	SectorAccesses    int64
+	TokenStorageInfo  TokenStorageInfo
+	RevertedResources RevertedResources
+	ExpirationTime    time.Time // Zero if the token never expires.
+	SubToken          bool
+}
+
+// Expired returns whether the token can't be used anymore.
+func (r TokenRecord) Expired(now time.Time) bool {
+	return expired(r.ExpirationTime, now)
+}
+
+func expired(expirationTime, now time.Time) bool {
+	return !expirationTime.IsZero() && !now.Before(expirationTime)
+}
+
+// earliestExpiration returns the expiration time that comes first, zero
+// expiration times are ignored.
+func earliestExpiration(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
 }
 
 type storage interface {
 	Lock(ctx context.Context) error
 	Unlock(ctx context.Context) error
 	AppendMeta(ctx context.Context, callback func(w io.Writer) error) error
+	ReplaceMeta(ctx context.Context, callback func(w io.Writer) error) error
+	LoadMetaFromOffset(ctx context.Context, offset int64, callback func(r io.Reader) error) error
 }
 
 // TokenStorage - storage of tokens for prepaid downloads.
@@ -75,6 +127,7 @@ type TokenStorage struct {
 	metaMu  sync.Mutex // For drainEventsQueue (involving IO).
 
 	logFile *os.File
+	dir     string
 
 	eventsQueue []interface{}
 
@@ -92,17 +145,11 @@ func NewTokenStorage(stManager modules.StorageManager, dir string) (*TokenStorag
 		return nil, fmt.Errorf("failed to lock: %w", err)
 	}
 
-	var state *tokenstate.State
-	state, err := tokenstate.NewState(dir)
+	// Only the events that follow the snapshot are replayed.
+	state, _, err := loadState(storage, dir, dir)
 	if err != nil {
 		return nil, err
 	}
-	err = storage.LoadMeta(ctx, func(r io.Reader) error {
-		return state.LoadHistory(r)
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to load history: %w", err)
-	}
 	logPath := filepath.Join(dir, logFileName)
 	logFile, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
 	if err != nil {
@@ -115,6 +162,7 @@ func NewTokenStorage(stManager modules.StorageManager, dir string) (*TokenStorag
 		state:          state,
 		storageManager: stManager,
 		logFile:        logFile,
+		dir:            dir,
 	}
 	return s, nil
 }
@@ -156,9 +204,43 @@ func toTokenRecord(record tokenstate.TokenRecord) TokenRecord {
 			LastChangeTime: record.TokenInfo.LastChangeTime,
 			SectorsNum:     record.TokenInfo.SectorsNum,
 		},
+		RevertedResources: RevertedResources{
+			DownloadBytes:  record.Reverted.DownloadBytes,
+			UploadBytes:    record.Reverted.UploadBytes,
+			SectorAccesses: record.Reverted.SectorAccesses,
+			Storage:        record.Reverted.Storage,
+		},
+		ExpirationTime: record.ExpirationTime,
 	}
 }
 
+// tokenRecord returns the record of a token or a sub-token. The resources of a
+// sub-token are the resources of the parent token, capped by the limits of the
+// sub-token.
+// Mutex stateMu must be locked when this function is called.
+func (t *TokenStorage) tokenRecord(id types.TokenID) TokenRecord {
+	subToken, isSubToken := t.state.SubTokens[id]
+	if !isSubToken {
+		return toTokenRecord(t.state.Tokens[id])
+	}
+	parent := t.state.Tokens[subToken.ParentID]
+	record := TokenRecord{
+		DownloadBytes:  parent.DownloadBytes,
+		SectorAccesses: parent.SectorAccesses,
+		ExpirationTime: earliestExpiration(subToken.ExpirationTime, parent.ExpirationTime),
+		SubToken:       true,
+	}
+	if left := subToken.DownloadBytesLimit - subToken.DownloadBytesUsed; left < record.DownloadBytes {
+		record.DownloadBytes = left
+	}
+	if subToken.SectorAccessesLimit != 0 {
+		if left := subToken.SectorAccessesLimit - subToken.SectorAccessesUsed; left < record.SectorAccesses {
+			record.SectorAccesses = left
+		}
+	}
+	return record
+}
+
 // TokenRecord return token record by id.
 func (t *TokenStorage) TokenRecord(id types.TokenID) (TokenRecord, error) {
 	t.stateMu.Lock()
@@ -166,11 +248,7 @@ func (t *TokenStorage) TokenRecord(id types.TokenID) (TokenRecord, error) {
 	if t.closed {
 		return TokenRecord{}, fmt.Errorf("token storage closed")
 	}
-	record, exist := t.state.Tokens[id]
-	if !exist {
-		return TokenRecord{}, nil
-	}
-	return toTokenRecord(record), nil
+	return t.tokenRecord(id), nil
 }
 
 // RecordDownload set token record fields.
@@ -180,11 +258,10 @@ func (t *TokenStorage) RecordDownload(id types.TokenID, downloadBytes, sectorAcc
 	if t.closed {
 		return TokenRecord{}, fmt.Errorf("token storage closed")
 	}
-	stateRecord, exist := t.state.Tokens[id]
-	if !exist {
-		stateRecord = tokenstate.TokenRecord{}
+	record := t.tokenRecord(id)
+	if record.Expired(callTime) {
+		return record, ErrTokenExpired
 	}
-	record := toTokenRecord(stateRecord)
 	notEnoughSectorAccesses := record.SectorAccesses < sectorAccesses
 	notEnoughDownlodBytes := record.DownloadBytes < downloadBytes
 	if notEnoughSectorAccesses && notEnoughDownlodBytes {
@@ -201,11 +278,13 @@ func (t *TokenStorage) RecordDownload(id types.TokenID, downloadBytes, sectorAcc
 		DownloadBytes:  downloadBytes,
 		SectorAccesses: sectorAccesses,
 	}, Time: callTime})
-	return toTokenRecord(t.state.Tokens[id]), nil
+	return t.tokenRecord(id), nil
 }
 
-// AddResources - add resource to token.
-func (t *TokenStorage) AddResources(id types.TokenID, resourceType types.Specifier, amount int64) error {
+// AddResources - add resource to token. The contract that paid for the
+// resources is tracked, so that they can be removed if the contract is
+// reverted.
+func (t *TokenStorage) AddResources(id types.TokenID, contractID types.FileContractID, resourceType types.Specifier, amount int64) error {
 	t.stateMu.Lock()
 	defer t.stateMu.Unlock()
 	if t.closed {
@@ -215,10 +294,53 @@ func (t *TokenStorage) AddResources(id types.TokenID, resourceType types.Specifi
 		TokenID:        id,
 		ResourceType:   resourceType,
 		ResourceAmount: amount,
+		ContractID:     contractID,
 	}, Time: time.Now()})
 	return nil
 }
 
+// ProcessConsensusChange removes the resources of top-ups whose contracts
+// were reverted, and restores them if the contracts are confirmed again. The
+// host forwards its consensus changes, which keeps the token storage in sync
+// with the storage obligations.
+func (t *TokenStorage) ProcessConsensusChange(cc modules.ConsensusChange) {
+	t.stateMu.Lock()
+	defer t.stateMu.Unlock()
+	if t.closed {
+		return
+	}
+	for _, block := range cc.RevertedBlocks {
+		for _, txn := range block.Transactions {
+			for i := range txn.FileContracts {
+				fcid := txn.FileContractID(uint64(i))
+				if !t.state.HasTopUps(fcid, false) {
+					continue
+				}
+				log.Printf("Contract %s was reverted in block %s, removing its top-ups", fcid.String(), block.ID().String())
+				t.applyEvent(&tokenstate.Event{EventRevertTopUps: &tokenstate.EventRevertTopUps{
+					ContractID: fcid,
+					BlockID:    block.ID(),
+				}, Time: time.Now()})
+			}
+		}
+	}
+	for _, block := range cc.AppliedBlocks {
+		for _, txn := range block.Transactions {
+			for i := range txn.FileContracts {
+				fcid := txn.FileContractID(uint64(i))
+				if !t.state.HasTopUps(fcid, true) {
+					continue
+				}
+				log.Printf("Contract %s was confirmed again in block %s, restoring its top-ups", fcid.String(), block.ID().String())
+				t.applyEvent(&tokenstate.Event{EventConfirmTopUps: &tokenstate.EventConfirmTopUps{
+					ContractID: fcid,
+					BlockID:    block.ID(),
+				}, Time: time.Now()})
+			}
+		}
+	}
+}
+
 // AddSectors add sectors to token.
 func (t *TokenStorage) AddSectors(id types.TokenID, sectorsIDs []crypto.Hash, time time.Time) (TokenRecord, error) {
 	t.stateMu.Lock()
@@ -226,11 +348,13 @@ func (t *TokenStorage) AddSectors(id types.TokenID, sectorsIDs []crypto.Hash, ti
 	if t.closed {
 		return TokenRecord{}, fmt.Errorf("token storage closed")
 	}
-	stateRecord, exist := t.state.Tokens[id]
-	if !exist {
-		stateRecord = tokenstate.TokenRecord{}
+	record := t.tokenRecord(id)
+	if record.SubToken {
+		return record, ErrSubTokenScope
+	}
+	if record.Expired(time) {
+		return record, ErrTokenExpired
 	}
-	record := toTokenRecord(stateRecord)
 	// Exclude existing sector IDs, remove duplicates.
 	newSectorIDs, err := t.state.NonexistentSectors(id, sectorsIDs)
 	if err != nil {
@@ -257,6 +381,130 @@ func (t *TokenStorage) AddSectors(id types.TokenID, sectorsIDs []crypto.Hash, ti
 	return toTokenRecord(t.state.Tokens[id]), nil
 }
 
+// SetExpiration sets the time after which a token or a sub-token can't be
+// used. The expiration time can only be moved earlier, so the holder of a token
+// can revoke it by setting the expiration time to now.
+func (t *TokenStorage) SetExpiration(id types.TokenID, expirationTime, now time.Time) (TokenRecord, error) {
+	t.stateMu.Lock()
+	defer t.stateMu.Unlock()
+	if t.closed {
+		return TokenRecord{}, fmt.Errorf("token storage closed")
+	}
+	_, exist := t.state.Tokens[id]
+	_, isSubToken := t.state.SubTokens[id]
+	if !exist && !isSubToken {
+		// Don't fill events history with records of nonexistent tokens.
+		return TokenRecord{}, ErrTokenNotFound
+	}
+	record := t.tokenRecord(id)
+	if record.Expired(now) {
+		return record, ErrTokenExpired
+	}
+	if expirationTime.IsZero() || (!record.ExpirationTime.IsZero() && expirationTime.After(record.ExpirationTime)) {
+		return record, ErrExpirationExtended
+	}
+	log.Printf("Setting expiration time of token %s to %s", id.String(), expirationTime.String())
+	t.applyEvent(&tokenstate.Event{EventSetExpiration: &tokenstate.EventSetExpiration{
+		TokenID:        id,
+		ExpirationTime: expirationTime,
+	}, Time: now})
+	return t.tokenRecord(id), nil
+}
+
+// Transfer moves all resources and sectors of a token to another token, so
+// that the old token can't be used anymore. If the new token has resources
+// already, the tokens are merged and the earliest expiration time is kept.
+func (t *TokenStorage) Transfer(from, to types.TokenID, now time.Time) (TokenRecord, error) {
+	t.stateMu.Lock()
+	defer t.stateMu.Unlock()
+	if t.closed {
+		return TokenRecord{}, fmt.Errorf("token storage closed")
+	}
+	if from == to {
+		return TokenRecord{}, ErrTransferToSameToken
+	}
+	_, fromIsSubToken := t.state.SubTokens[from]
+	_, toIsSubToken := t.state.SubTokens[to]
+	if fromIsSubToken || toIsSubToken {
+		return TokenRecord{}, ErrSubTokenScope
+	}
+	fromRecord, exist := t.state.Tokens[from]
+	if !exist {
+		return TokenRecord{}, ErrTokenNotFound
+	}
+	if expired(fromRecord.ExpirationTime, now) || expired(t.state.Tokens[to].ExpirationTime, now) {
+		return TokenRecord{}, ErrTokenExpired
+	}
+	// The sectors stored by both tokens are kept once, so the extra copies
+	// have to be removed from disk.
+	fromSectors, err := t.state.GetSectors(from)
+	if err != nil {
+		return TokenRecord{}, fmt.Errorf("GetSectors failed: %w", err)
+	}
+	duplicateSectors, _, err := t.state.HasSectors(to, fromSectors)
+	if err != nil {
+		return TokenRecord{}, fmt.Errorf("HasSectors failed: %w", err)
+	}
+	log.Printf("Transferring token %s to token %s", from.String(), to.String())
+	t.applyEvent(&tokenstate.Event{EventTransfer: &tokenstate.EventTransfer{
+		FromTokenID: from,
+		ToTokenID:   to,
+	}, Time: now})
+	if len(duplicateSectors) != 0 {
+		go func() {
+			if err := t.storageManager.RemoveSectorBatch(duplicateSectors); err != nil {
+				log.Printf("Failed to remove duplicate sectors of transferred token %s: %v", to.String(), err)
+			}
+		}()
+	}
+	return t.tokenRecord(to), nil
+}
+
+// MintSubToken creates a download-only sub-token that draws its resources
+// from the parent token. The sub-token can download at most downloadBytes
+// bytes and access at most sectorAccesses sectors; the number of sector
+// accesses is not limited if sectorAccesses is zero. The sub-token never
+// outlives the parent token, a zero expiration time means that the sub-token
+// expires with the parent token.
+func (t *TokenStorage) MintSubToken(parent types.TokenID, downloadBytes, sectorAccesses int64, expirationTime, now time.Time) (types.TokenID, TokenRecord, error) {
+	t.stateMu.Lock()
+	defer t.stateMu.Unlock()
+	if t.closed {
+		return types.TokenID{}, TokenRecord{}, fmt.Errorf("token storage closed")
+	}
+	if downloadBytes <= 0 || sectorAccesses < 0 {
+		return types.TokenID{}, TokenRecord{}, ErrInvalidSubTokenLimits
+	}
+	if _, isSubToken := t.state.SubTokens[parent]; isSubToken {
+		return types.TokenID{}, TokenRecord{}, ErrSubTokenScope
+	}
+	parentRecord, exist := t.state.Tokens[parent]
+	if !exist {
+		return types.TokenID{}, TokenRecord{}, ErrTokenNotFound
+	}
+	if expired(parentRecord.ExpirationTime, now) {
+		return types.TokenID{}, TokenRecord{}, ErrTokenExpired
+	}
+	var id types.TokenID
+	for {
+		fastrand.Read(id[:])
+		_, isToken := t.state.Tokens[id]
+		_, isSubToken := t.state.SubTokens[id]
+		if !isToken && !isSubToken {
+			break
+		}
+	}
+	log.Printf("Minting sub-token of token %s", parent.String())
+	t.applyEvent(&tokenstate.Event{EventMintSubToken: &tokenstate.EventMintSubToken{
+		ParentID:            parent,
+		SubTokenID:          id,
+		DownloadBytesLimit:  downloadBytes,
+		SectorAccessesLimit: sectorAccesses,
+		ExpirationTime:      expirationTime,
+	}, Time: now})
+	return id, t.tokenRecord(id), nil
+}
+
 // ListSectorIDs returns list of sector ids.
 func (t *TokenStorage) ListSectorIDs(id types.TokenID, pageID string, limit int) (sectorIDs []crypto.Hash, nextPageID string, err error) {
 	t.stateMu.Lock()
@@ -384,10 +632,18 @@ func (t *TokenStorage) checkExpiration() {
 	}
 
 	log.Println("checkExpiration is called")
-	for token := range t.state.Tokens {
-		if enough := t.state.EnoughStorageResource(token, 0, time.Now()); enough {
+	now := time.Now()
+	for token, record := range t.state.Tokens {
+		if expired(record.ExpirationTime, now) {
+			if record.TokenInfo.SectorsNum == 0 {
+				continue
+			}
+			log.Printf("Token %s expired, removing all its sectors...", token.String())
+		} else if enough := t.state.EnoughStorageResource(token, 0, now); enough {
 			log.Printf("Token %s has enough storage, don't remove", token.String())
 			continue
+		} else {
+			log.Printf("Token %s does not have enough storage resource, removing all its sectors...", token.String())
 		}
 		sectors, err := t.state.GetSectors(token)
 		if err != nil {
@@ -395,8 +651,6 @@ func (t *TokenStorage) checkExpiration() {
 			continue
 		}
 
-		log.Printf("Token %s does not have enough storage resource, removing all its sectors...", token.String())
-
 		t.applyEvent(&tokenstate.Event{EventRemoveAllSectors: &tokenstate.EventRemoveAllSectors{
 			TokenID:    token,
 			SectorsIDs: crypto.ConvertHashesToByteSlices(sectors),
@@ -58,12 +58,12 @@ func TestTokenStorage_AddResources(t *testing.T) {
 	amount := int64(100500)
 	var id types.TokenID
 	fastrand.Read(id[:])
-	err := stor.AddResources(id, modules.DownloadBytes, amount)
+	err := stor.AddResources(id, types.FileContractID{}, modules.DownloadBytes, amount)
 	assert.NoError(t, err, "stor.addResources() failed")
 	newResources, err := stor.TokenRecord(id)
 	assert.NoError(t, err, "tokenRecord() failed")
 	assert.Equal(t, amount, newResources.DownloadBytes)
-	err = stor.AddResources(id, modules.UploadBytes, amount)
+	err = stor.AddResources(id, types.FileContractID{}, modules.UploadBytes, amount)
 	assert.NoError(t, err, "stor.addResources() failed")
 	newResources, err = stor.TokenRecord(id)
 	assert.NoError(t, err, "tokenRecord() failed")
@@ -78,8 +78,8 @@ func TestTokenStorage_AddDuplicateSectors(t *testing.T) {
 	sectorsNum := int64(5)
 	uploadBytesAmount := int64(modules.SectorSize) * sectorsNum
 	storageAmount := sectorsNum * storageDurationSeconds
-	assert.NoError(t, stor.AddResources(token, modules.UploadBytes, uploadBytesAmount))
-	assert.NoError(t, stor.AddResources(token, modules.Storage, storageAmount))
+	assert.NoError(t, stor.AddResources(token, types.FileContractID{}, modules.UploadBytes, uploadBytesAmount))
+	assert.NoError(t, stor.AddResources(token, types.FileContractID{}, modules.Storage, storageAmount))
 
 	// Make sure duplicates are not added.
 	var sectorID0, sectorID1, sectorID2 crypto.Hash
@@ -116,8 +116,8 @@ func TestTokenStorage_AttachSectors(t *testing.T) {
 	storageTimeSeconds := int64(10)
 	uploadBytesAmount := int64(modules.SectorSize) * sectorsAmount
 	storageAmount := storageTimeSeconds * sectorsAmount
-	assert.NoError(t, stor.AddResources(token, modules.UploadBytes, uploadBytesAmount))
-	assert.NoError(t, stor.AddResources(token, modules.Storage, storageAmount))
+	assert.NoError(t, stor.AddResources(token, types.FileContractID{}, modules.UploadBytes, uploadBytesAmount))
+	assert.NoError(t, stor.AddResources(token, types.FileContractID{}, modules.Storage, storageAmount))
 	additionTime := time.Now()
 	tr, err := stor.AddSectors(token, []crypto.Hash{sectorID, sectorID1}, additionTime)
 	assert.NoError(t, err)
@@ -142,8 +142,8 @@ func TestTokenStorage_RemoveUnpaidSectors(t *testing.T) {
 	storageTimeSeconds := int64(5)
 	uploadBytesAmount := int64(modules.SectorSize) * sectorsAmount
 	storageAmount := storageTimeSeconds * sectorsAmount
-	assert.NoError(t, stor.AddResources(token, modules.UploadBytes, uploadBytesAmount))
-	assert.NoError(t, stor.AddResources(token, modules.Storage, storageAmount))
+	assert.NoError(t, stor.AddResources(token, types.FileContractID{}, modules.UploadBytes, uploadBytesAmount))
+	assert.NoError(t, stor.AddResources(token, types.FileContractID{}, modules.Storage, storageAmount))
 	additionTime := time.Now()
 	tr, err := stor.AddSectors(token, []crypto.Hash{sectorID, sectorID1}, additionTime)
 	assert.NoError(t, err)
@@ -181,3 +181,151 @@ func TestTokenStorage_RemoveUnpaidSectors(t *testing.T) {
 	assert.NoError(t, err)
 	assert.False(t, enough)
 }
+
+func TestTokenStorage_RevertTopUps(t *testing.T) {
+	stor := createTokenStorage(t)
+	var token types.TokenID
+	fastrand.Read(token[:])
+	txn := types.Transaction{FileContracts: []types.FileContract{{}}}
+	block := types.Block{Transactions: []types.Transaction{txn}}
+	fcid := txn.FileContractID(0)
+	assert.NoError(t, stor.AddResources(token, fcid, modules.DownloadBytes, 1000))
+	_, err := stor.RecordDownload(token, 600, 0, time.Now())
+	assert.NoError(t, err)
+
+	// Reverting the block that confirmed the contract removes the resources,
+	// even if they were spent already.
+	stor.ProcessConsensusChange(modules.ConsensusChange{RevertedBlocks: []types.Block{block}})
+	tr, err := stor.TokenRecord(token)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-600), tr.DownloadBytes)
+	assert.Equal(t, int64(1000), tr.RevertedResources.DownloadBytes)
+
+	// Reverting the block again doesn't remove the resources twice.
+	stor.ProcessConsensusChange(modules.ConsensusChange{RevertedBlocks: []types.Block{block}})
+	tr, err = stor.TokenRecord(token)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-600), tr.DownloadBytes)
+
+	// Confirming the contract again restores the resources.
+	stor.ProcessConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{block}})
+	tr, err = stor.TokenRecord(token)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(400), tr.DownloadBytes)
+	assert.Equal(t, RevertedResources{}, tr.RevertedResources)
+}
+
+func TestTokenStorage_SubToken(t *testing.T) {
+	stor := createTokenStorage(t)
+	var parent types.TokenID
+	fastrand.Read(parent[:])
+	_, _, err := stor.MintSubToken(parent, 1000, 0, time.Time{}, time.Now())
+	assert.ErrorIs(t, err, ErrTokenNotFound)
+	assert.NoError(t, stor.AddResources(parent, types.FileContractID{}, modules.DownloadBytes, 5000))
+	assert.NoError(t, stor.AddResources(parent, types.FileContractID{}, modules.SectorAccesses, 10))
+	_, _, err = stor.MintSubToken(parent, 0, 0, time.Time{}, time.Now())
+	assert.ErrorIs(t, err, ErrInvalidSubTokenLimits)
+
+	// The sub-token draws from the parent up to its limits.
+	subToken, tr, err := stor.MintSubToken(parent, 1000, 0, time.Time{}, time.Now())
+	assert.NoError(t, err)
+	assert.True(t, tr.SubToken)
+	assert.Equal(t, int64(1000), tr.DownloadBytes)
+	assert.Equal(t, int64(10), tr.SectorAccesses)
+	tr, err = stor.RecordDownload(subToken, 600, 2, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(400), tr.DownloadBytes)
+	assert.Equal(t, int64(8), tr.SectorAccesses)
+	_, err = stor.RecordDownload(subToken, 600, 1, time.Now())
+	assert.ErrorIs(t, err, ErrInsufficientDownloadBytes)
+	tr, err = stor.TokenRecord(parent)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4400), tr.DownloadBytes)
+	assert.Equal(t, int64(8), tr.SectorAccesses)
+
+	// Sub-tokens can't upload or mint other sub-tokens.
+	_, err = stor.AddSectors(subToken, []crypto.Hash{{1}}, time.Now())
+	assert.ErrorIs(t, err, ErrSubTokenScope)
+	_, _, err = stor.MintSubToken(subToken, 100, 0, time.Time{}, time.Now())
+	assert.ErrorIs(t, err, ErrSubTokenScope)
+
+	// The holder of the parent token can revoke the sub-token.
+	_, err = stor.SetExpiration(subToken, time.Now(), time.Now())
+	assert.NoError(t, err)
+	_, err = stor.RecordDownload(subToken, 100, 1, time.Now())
+	assert.ErrorIs(t, err, ErrTokenExpired)
+	_, err = stor.RecordDownload(parent, 100, 1, time.Now())
+	assert.NoError(t, err)
+}
+
+func TestTokenStorage_Expiration(t *testing.T) {
+	stor := createTokenStorage(t)
+	var token types.TokenID
+	fastrand.Read(token[:])
+	now := time.Now()
+	_, err := stor.SetExpiration(token, now.Add(time.Hour), now)
+	assert.ErrorIs(t, err, ErrTokenNotFound)
+	assert.NoError(t, stor.AddResources(token, types.FileContractID{}, modules.DownloadBytes, 5000))
+	assert.NoError(t, stor.AddResources(token, types.FileContractID{}, modules.SectorAccesses, 10))
+
+	tr, err := stor.SetExpiration(token, now.Add(time.Hour), now)
+	assert.NoError(t, err)
+	assert.True(t, tr.ExpirationTime.Equal(now.Add(time.Hour)))
+	// The expiration time can't be extended.
+	_, err = stor.SetExpiration(token, now.Add(2*time.Hour), now)
+	assert.ErrorIs(t, err, ErrExpirationExtended)
+
+	// A sub-token expires with its parent.
+	subToken, tr, err := stor.MintSubToken(token, 1000, 0, now.Add(2*time.Hour), now)
+	assert.NoError(t, err)
+	assert.True(t, tr.ExpirationTime.Equal(now.Add(time.Hour)))
+
+	_, err = stor.RecordDownload(token, 100, 1, now.Add(time.Hour))
+	assert.ErrorIs(t, err, ErrTokenExpired)
+	_, err = stor.RecordDownload(subToken, 100, 1, now.Add(time.Hour))
+	assert.ErrorIs(t, err, ErrTokenExpired)
+}
+
+func TestTokenStorage_Transfer(t *testing.T) {
+	stor := createTokenStorage(t)
+	var from, to types.TokenID
+	fastrand.Read(from[:])
+	fastrand.Read(to[:])
+	sectorsNum := int64(2)
+	assert.NoError(t, stor.AddResources(from, types.FileContractID{}, modules.UploadBytes, int64(modules.SectorSize)*sectorsNum))
+	assert.NoError(t, stor.AddResources(from, types.FileContractID{}, modules.Storage, 1000))
+	assert.NoError(t, stor.AddResources(from, types.FileContractID{}, modules.DownloadBytes, 5000))
+	var sectorID0, sectorID1 crypto.Hash
+	fastrand.Read(sectorID0[:])
+	fastrand.Read(sectorID1[:])
+	_, err := stor.AddSectors(from, []crypto.Hash{sectorID0, sectorID1}, time.Now())
+	assert.NoError(t, err)
+	subToken, _, err := stor.MintSubToken(from, 1000, 0, time.Time{}, time.Now())
+	assert.NoError(t, err)
+
+	_, err = stor.Transfer(from, from, time.Now())
+	assert.ErrorIs(t, err, ErrTransferToSameToken)
+	_, err = stor.Transfer(from, subToken, time.Now())
+	assert.ErrorIs(t, err, ErrSubTokenScope)
+
+	tr, err := stor.Transfer(from, to, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5000), tr.DownloadBytes)
+	assert.Equal(t, uint64(2), tr.TokenStorageInfo.SectorsNum)
+	sectorIDs, _, err := stor.ListSectorIDs(to, "", 100)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []crypto.Hash{sectorID0, sectorID1}, sectorIDs)
+
+	// The old token is empty and the sub-token draws from the new token.
+	tr, err = stor.TokenRecord(from)
+	assert.NoError(t, err)
+	assert.Equal(t, TokenRecord{}, tr)
+	sectorIDs, _, err = stor.ListSectorIDs(from, "", 100)
+	assert.NoError(t, err)
+	assert.Empty(t, sectorIDs)
+	_, err = stor.RecordDownload(subToken, 500, 0, time.Now())
+	assert.NoError(t, err)
+	tr, err = stor.TokenRecord(to)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4500), tr.DownloadBytes)
+}
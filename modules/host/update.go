@@ -120,6 +120,15 @@ func (h *Host) initConsensusSubscription() error {
 // ProcessConsensusChange will be called by the consensus set every time there
 // is a change to the blockchain.
 func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
+	// Token storage removes the resources of top-ups whose contracts were
+	// reverted. It doesn't depend on the state of the host.
+	h.tokenStor.ProcessConsensusChange(cc)
+
+	// Let the pricing policy adjust the prices once the host is synced.
+	if cc.Synced {
+		go h.threadedUpdatePrices()
+	}
+
 	//Skip processing if host is not configured and announced, just update the host.blockHeight
 	hostinitialized := h.wallet.IsWatchedAddress(h.unlockHash)
 	if !hostinitialized && len(h.StorageObligations()) < 1 {
@@ -127,6 +136,9 @@ func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
 		h.recentChange = cc.ID
 		h.blockHeight = cc.NewHeight
 		h.staticAccountManager.callConsensusChanged(cc)
+		if err := h.removeExpiredRegistryEntries(); err != nil {
+			h.log.Println("ERROR: failed to prune the registry:", err)
+		}
 		h.mu.Unlock()
 		return
 	}
@@ -145,6 +157,7 @@ func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
 	// Wrap the whole parsing into a single large database tx to keep things
 	// efficient.
 	var actionItems []types.FileContractID
+	var pruned uint64
 	err := h.db.Update(func(tx *bolt.Tx) error {
 		for _, block := range cc.RevertedBlocks {
 			// Look for transactions relevant to open storage obligations.
@@ -307,10 +320,16 @@ func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
 				}
 			}
 		}
-		return nil
+
+		// Remove the registry entries that expired.
+		var err error
+		pruned, err = h.pruneRegistry(tx)
+		return err
 	})
 	if err != nil {
 		h.log.Println(err)
+	} else {
+		h.registryEntries -= pruned
 	}
 	for i := range actionItems {
 		go h.threadedHandleActionItem(actionItems[i])
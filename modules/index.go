@@ -1,7 +1,7 @@
 package modules
 
 import (
-// "github.com/scpcorp/ScPrime/types"
+	"github.com/EvilRedHorse/pubaccess-node/types"
 )
 
 const (
@@ -9,15 +9,39 @@ const (
 	IndexDir = "index"
 )
 
-var (
-// Whatever variables we need as we go
-)
-
 type (
-	// Index is a module help import info to RDB like Mysql and caculat all address coin info
+	// IndexOutput is a siacoin output that is tracked by the index.
+	IndexOutput struct {
+		ID            types.SiacoinOutputID `json:"id"`
+		Value         types.Currency        `json:"value"`
+		UnlockHash    types.UnlockHash      `json:"unlockhash"`
+		TransactionID types.TransactionID   `json:"transactionid"`
+		Height        types.BlockHeight     `json:"height"`
+		Type          string                `json:"type"`
+	}
+
+	// IndexAddressBalance is the confirmed balance of an address according to
+	// the index.
+	IndexAddressBalance struct {
+		UnlockHash     types.UnlockHash  `json:"unlockhash"`
+		Balance        types.Currency    `json:"balance"`
+		UnspentOutputs uint64            `json:"unspentoutputs"`
+		Height         types.BlockHeight `json:"height"`
+	}
+
+	// Index is a module that imports the blockchain into a relational database
+	// like MySQL and calculates the coin info of all addresses. It follows the
+	// consensus set, which means that outputs and inputs of reverted blocks are
+	// removed from the database again.
 	Index interface {
-		// Scan will go through every block and try to sync every block info
-		Scan() error
+		// AddressBalance returns the confirmed balance of an address.
+		AddressBalance(uh types.UnlockHash) (IndexAddressBalance, error)
+
+		// AddressUnspentOutputs returns the unspent outputs of an address.
+		AddressUnspentOutputs(uh types.UnlockHash) ([]IndexOutput, error)
+
+		// Height returns the height of the most recent block that was indexed.
+		Height() types.BlockHeight
 
 		// Close closes the Index.
 		Close() error
@@ -1,32 +1,35 @@
 package index
 
 import (
-	"database/sql"
-	"errors"
-	// "fmt"
 	"os"
 	"path/filepath"
 	"sync"
-	"time"
 
 	"github.com/EvilRedHorse/pubaccess-node/config"
 	"github.com/EvilRedHorse/pubaccess-node/modules"
 	"github.com/EvilRedHorse/pubaccess-node/persist"
 	siasync "github.com/EvilRedHorse/pubaccess-node/sync"
 	"github.com/EvilRedHorse/pubaccess-node/types"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/threadgroup"
 )
 
 const (
-	// Names of the various persistent files in the pool.
+	// Names of the various persistent files in the index.
 	logFile = modules.IndexDir + ".log"
-
-	// IndexDuration is the interval time of index scan
-	IndexDuration = 10 * time.Minute
 )
 
 // Index is the main type of this module
 type Index struct {
-	currentHeight types.BlockHeight // The current running height
+	currentHeight types.BlockHeight // The height of the most recent indexed block
+
+	// outOfSync is set if a consensus change couldn't be written to the
+	// database. The index stops following the consensus set in that case,
+	// since the following changes would be applied to an inconsistent state.
+	// Restarting the index resumes from the last consensus change that was
+	// written successfully.
+	outOfSync bool
 
 	// Dependencies.
 	cs     modules.ConsensusSet
@@ -35,18 +38,21 @@ type Index struct {
 	gw     modules.Gateway
 
 	// Utilities.
-	sqldb      *sql.DB
-	log        *persist.Logger
-	mu         sync.RWMutex
-	persistDir string
-	tg         siasync.ThreadGroup
+	staticStore store
+	log         *persist.Logger
+	mu          sync.RWMutex
+	persistDir  string
+	tg          threadgroup.ThreadGroup
 }
 
 var (
 	// Nil dependency errors.
-	errNilCS    = errors.New("index cannot use a nil consensus state")
-	errNilTpool = errors.New("index cannot use a nil transaction pool")
-	errNilGW    = errors.New("index cannot use a nil gateway")
+	errNilCS = errors.New("index cannot use a nil consensus state")
+	errNilGW = errors.New("index cannot use a nil gateway")
+
+	// errIndexOutOfSync is returned by queries if the index stopped following
+	// the consensus set.
+	errIndexOutOfSync = errors.New("index failed to process a consensus change and needs to be restarted")
 )
 
 func newIndex(cs modules.ConsensusSet, tpool modules.TransactionPool, gw modules.Gateway, wallet modules.Wallet, persistDir string, initConfig config.IndexConfig) (*Index, error) {
@@ -54,20 +60,16 @@ func newIndex(cs modules.ConsensusSet, tpool modules.TransactionPool, gw modules
 	if cs == nil {
 		return nil, errNilCS
 	}
-	// if tpool == nil {
-	// 	return nil, errNilTpool
-	// }
 	if gw == nil {
 		return nil, errNilGW
 	}
 
 	// Create the index object.
 	index := &Index{
-		cs:            cs,
-		tpool:         tpool,
-		gw:            gw,
-		wallet:        wallet,
-		currentHeight: 0,
+		cs:     cs,
+		tpool:  tpool,
+		gw:     gw,
+		wallet: wallet,
 
 		persistDir: persistDir,
 	}
@@ -82,19 +84,26 @@ func newIndex(cs modules.ConsensusSet, tpool modules.TransactionPool, gw modules
 	if err != nil {
 		return nil, err
 	}
+	index.tg.AfterStop(func() error {
+		return index.log.Close()
+	})
 
-	// get max height from mysql
-	index.sqldb, err = sql.Open("mysql", initConfig.PoolDBConnection)
+	index.staticStore, err = newStore(initConfig, index.persistDir)
 	if err != nil {
-		return nil, errors.New("Failed to open database: " + err.Error())
+		return nil, errors.AddContext(err, "failed to open index store")
 	}
+	index.tg.AfterStop(func() error {
+		return index.staticStore.Close()
+	})
 
-	err = index.sqldb.Ping()
+	index.currentHeight, err = index.staticStore.Height()
 	if err != nil {
-		return nil, errors.New("Failed to ping database: " + err.Error())
+		return nil, errors.AddContext(err, "failed to load indexed height")
 	}
 
-	go index.managedScan()
+	// Catching up with the consensus set can take a long time, so the
+	// subscription happens in the background.
+	go index.threadedSubscribe()
 
 	return index, nil
 }
@@ -104,229 +113,55 @@ func New(cs modules.ConsensusSet, tpool modules.TransactionPool, gw modules.Gate
 	return newIndex(cs, tpool, gw, wallet, persistDir, initConfig)
 }
 
-func (index *Index) managedScan() error {
-	index.tg.Add()
-	defer index.tg.Done()
-
-	err := index.setCurrentHeightFromDB()
-	if err != nil {
-		return err
-	}
-
-	// wait for the consensus to be synced
-	for {
-		if !index.cs.Synced() {
-			select {
-			case <-time.After(10 * time.Second):
-			case <-index.tg.StopChan():
-				return nil
-			}
-		} else {
-			break
-		}
-	}
-	// do the first scan
-	err = index.Scan()
-	if err != nil {
-		index.log.Debugf("index error: %s", err)
-		panic(err)
-	}
-	// then loop and wait to scan roughly once every block
-	for {
-		select {
-		case <-time.After(IndexDuration):
-		case <-index.tg.StopChan():
-			return nil
-		}
-
-		index.log.Debugln("index loop")
-		err := index.Scan()
-		if err != nil {
-			index.log.Debugf("index error: %s", err)
-			panic(err)
-		}
-	}
-}
-
-// Scan will go through every block and try to sync every block info
-func (index *Index) Scan() error {
-	index.log.Printf("Scanning \n")
-	var err error
-
-	// index.currentHeight = 0
-	// max height index.cs.Height()
-	for i := index.currentHeight; i <= index.cs.Height(); i++ {
-		index.log.Printf("start to process block: %d\n", i)
-		err = index.transBlock(i)
-		// if we've shut down, exit cleanly
-		select {
-		case <-index.tg.StopChan():
-			return nil
-		default:
-		}
-		if err != nil {
-			return err
-		}
+// threadedSubscribe subscribes the index to the consensus set, starting at the
+// most recent consensus change that was written to the database.
+func (index *Index) threadedSubscribe() {
+	if err := index.tg.Add(); err != nil {
+		return
 	}
+	defer index.tg.Done()
 
-	//
-	return nil
-}
-
-func (index *Index) setCurrentHeightFromDB() error {
-	stmt, err := index.sqldb.Prepare(`SELECT MAX(height) FROM block_meta ORDER BY height DESC;`)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-	rows, err := stmt.Query()
+	recentChange, err := index.staticStore.RecentChange()
 	if err != nil {
-		return err
+		index.log.Println("ERROR: unable to load recent consensus change:", err)
+		return
 	}
-	defer rows.Close()
-	var value types.BlockHeight
-	for rows.Next() {
-		err = rows.Scan(&value)
+	err = index.cs.ConsensusSetSubscribe(index, recentChange, index.tg.StopChan())
+	if err == modules.ErrInvalidConsensusChangeID {
+		// The change id is unknown to the consensus set, which happens if the
+		// consensus database was replaced. Start over from the beginning.
+		index.log.Println("Unknown consensus change id, rescanning the blockchain.")
+		err = index.staticStore.Reset()
 		if err != nil {
-			value = 0
+			index.log.Println("ERROR: unable to reset index store:", err)
+			return
 		}
-		break
-	}
-	err = rows.Err()
-	if err != nil {
-		return err
-	}
-	index.mu.Lock()
-	index.currentHeight = value
-	if index.currentHeight <= 10 {
+		index.mu.Lock()
 		index.currentHeight = 0
-	} else {
-		index.currentHeight = index.currentHeight - 10
-	}
-
-	index.mu.Unlock()
-	index.log.Printf("setCurrentHeightFromDB, height = %d\n", value)
-	return nil
-}
-
-func (index *Index) transBlock(h types.BlockHeight) error {
-	block, exists := index.cs.BlockAtHeight(h)
-	if !exists {
-		index.log.Printf("no block found at height %d\n", h)
-		return nil
-	}
-	index.log.Printf("fetched info of height %d, ID:%s\n", h, block.ID())
-
-	err := index.insertBlockMeta(h, block)
-	if err != nil {
-		return err
-	}
-
-	for j, payout := range block.MinerPayouts {
-		// scoid := block.MinerPayoutID(uint64(j)).String()
-		err = index.insertOutputs(block.MinerPayoutID(uint64(j)), payout, h, "mined", types.TransactionID{})
-		if err != nil {
-			return err
-		}
-	}
-
-	for _, txn := range block.Transactions {
-		// Add the transaction to the list of active transactions.
-		txid := txn.ID()
-		err = index.insertTx(h, txid)
-		if err != nil {
-			return err
-		}
-
-		for _, sci := range txn.SiacoinInputs {
-			err = index.insertInputs(sci.ParentID, h, txid)
-			if err != nil {
-				return err
-			}
-		}
-
-		for j, sco := range txn.SiacoinOutputs {
-			err = index.insertOutputs(txn.SiacoinOutputID(uint64(j)), sco, h, "normal", txid)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
-}
-
-func (index *Index) insertOutputs(scoid types.SiacoinOutputID, output types.SiacoinOutput, h types.BlockHeight, otype string, txid types.TransactionID) error {
-	stmt, err := index.sqldb.Prepare("INSERT IGNORE INTO outputs(id,amount,unlockhash,txid,height,type) VALUES(?,?,?,?,?,?)")
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-	_, err = stmt.Exec(scoid.String(), output.Value.String(), output.UnlockHash.String(), txid.String(), h, otype)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (index *Index) insertInputs(scoid types.SiacoinOutputID, h types.BlockHeight, txid types.TransactionID) error {
-	stmt, err := index.sqldb.Prepare("INSERT IGNORE INTO inputs(output_id,height,txid) VALUES(?,?,?)")
-	if err != nil {
-		return err
+		index.mu.Unlock()
+		err = index.cs.ConsensusSetSubscribe(index, modules.ConsensusChangeBeginning, index.tg.StopChan())
 	}
-	defer stmt.Close()
-	_, err = stmt.Exec(scoid.String(), h, txid.String())
-	if err != nil {
-		return err
+	if errors.Contains(err, siasync.ErrStopped) {
+		return
 	}
-
-	return index.updateOutputSpent(scoid)
-}
-
-func (index *Index) updateOutputSpent(scoid types.SiacoinOutputID) error {
-	stmt, err := index.sqldb.Prepare("UPDATE outputs SET spent=1 WHERE id=?")
 	if err != nil {
-		return err
+		index.log.Println("ERROR: index subscription failed:", err)
+		return
 	}
-	defer stmt.Close()
-	_, err = stmt.Exec(scoid.String())
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (index *Index) insertTx(h types.BlockHeight, txid types.TransactionID) error {
-	stmt, err := index.sqldb.Prepare("INSERT IGNORE INTO transactions(id,height) VALUES(?,?)")
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-	_, err = stmt.Exec(txid.String(), h)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	index.tg.OnStop(func() error {
+		index.cs.Unsubscribe(index)
+		return nil
+	})
 }
 
-func (index *Index) insertBlockMeta(h types.BlockHeight, block types.Block) error {
-	stmt, err := index.sqldb.Prepare("INSERT IGNORE INTO block_meta(block_id,height) VALUES(?,?)")
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-	_, err = stmt.Exec(block.ID().String(), h)
-	if err != nil {
-		return err
-	}
-	return nil
+// Height returns the height of the most recent block that was indexed.
+func (index *Index) Height() types.BlockHeight {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	return index.currentHeight
 }
 
 // Close shuts down the index.
 func (index *Index) Close() error {
-	index.sqldb.Close()
 	return index.tg.Stop()
 }
@@ -0,0 +1,48 @@
+package index
+
+import (
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+)
+
+// managedCheckSynced returns an error if the index stopped following the
+// consensus set.
+func (index *Index) managedCheckSynced() error {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	if index.outOfSync {
+		return errIndexOutOfSync
+	}
+	return nil
+}
+
+// AddressUnspentOutputs returns the unspent outputs of an address, ordered by
+// the height at which they were created.
+func (index *Index) AddressUnspentOutputs(uh types.UnlockHash) ([]modules.IndexOutput, error) {
+	if err := index.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer index.tg.Done()
+	if err := index.managedCheckSynced(); err != nil {
+		return nil, err
+	}
+	return index.staticStore.UnspentOutputs(uh)
+}
+
+// AddressBalance returns the confirmed balance of an address, which is the sum
+// of its unspent outputs.
+func (index *Index) AddressBalance(uh types.UnlockHash) (modules.IndexAddressBalance, error) {
+	outputs, err := index.AddressUnspentOutputs(uh)
+	if err != nil {
+		return modules.IndexAddressBalance{}, err
+	}
+	balance := modules.IndexAddressBalance{
+		UnlockHash:     uh,
+		UnspentOutputs: uint64(len(outputs)),
+		Height:         index.Height(),
+	}
+	for _, o := range outputs {
+		balance.Balance = balance.Balance.Add(o.Value)
+	}
+	return balance, nil
+}
@@ -0,0 +1,71 @@
+package index
+
+import (
+	"path/filepath"
+
+	"github.com/EvilRedHorse/pubaccess-node/config"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+const (
+	// boltFile is the name of the database file of the bolt store.
+	boltFile = modules.IndexDir + ".db"
+
+	// Types of the indexed outputs.
+	outputTypeMined  = "mined"
+	outputTypeNormal = "normal"
+)
+
+var (
+	// errUnknownDriver is returned if the configured database driver isn't
+	// supported by the index.
+	errUnknownDriver = errors.New("unknown database driver")
+)
+
+// store is the storage backend of the index. Every consensus change needs to be
+// written atomically, together with its id, to make sure that the stored
+// outputs always match a state of the consensus set.
+type store interface {
+	// ApplyConsensusChange removes the reverted blocks of a consensus change
+	// from the store and adds the applied blocks.
+	ApplyConsensusChange(cc modules.ConsensusChange) error
+
+	// Height returns the height of the most recent block within the store.
+	Height() (types.BlockHeight, error)
+
+	// RecentChange returns the id of the most recent consensus change that
+	// was applied to the store.
+	RecentChange() (modules.ConsensusChangeID, error)
+
+	// Reset removes all of the indexed data from the store.
+	Reset() error
+
+	// UnspentOutputs returns the unspent outputs of an address, ordered by
+	// height.
+	UnspentOutputs(uh types.UnlockHash) ([]modules.IndexOutput, error)
+
+	// Close closes the store.
+	Close() error
+}
+
+// newStore opens the store that is selected by the config. MySQL is used if
+// no driver is configured.
+func newStore(initConfig config.IndexConfig, persistDir string) (store, error) {
+	switch initConfig.PoolDBDriver {
+	case config.DBDriverMySQL, "":
+		return newMySQLStore(initConfig.PoolDBConnection)
+	case config.DBDriverBolt:
+		return newBoltStore(filepath.Join(persistDir, boltFile))
+	default:
+		return nil, errors.AddContext(errUnknownDriver, initConfig.PoolDBDriver)
+	}
+}
+
+// appliedBlockHeight returns the height of the i-th applied block of a
+// consensus change.
+func appliedBlockHeight(cc modules.ConsensusChange, i int) types.BlockHeight {
+	return cc.NewHeight - types.BlockHeight(len(cc.AppliedBlocks)-1-i)
+}
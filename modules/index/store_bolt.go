@@ -0,0 +1,343 @@
+package index
+
+import (
+	"sort"
+
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/persist"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+
+	"gitlab.com/NebulousLabs/encoding"
+	"gitlab.com/NebulousLabs/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// boltMetadata is the metadata of the bolt store.
+	boltMetadata = persist.Metadata{
+		Header:  "Index Database",
+		Version: "1.0",
+	}
+
+	// Buckets of the bolt store.
+	bucketBlocks         = []byte("Blocks")
+	bucketInputs         = []byte("Inputs")
+	bucketInternal       = []byte("Internal")
+	bucketOutputs        = []byte("Outputs")
+	bucketTransactions   = []byte("Transactions")
+	bucketUnspentOutputs = []byte("UnspentOutputs")
+
+	// boltBuckets are all of the buckets of the bolt store.
+	boltBuckets = [][]byte{
+		bucketBlocks,
+		bucketInputs,
+		bucketInternal,
+		bucketOutputs,
+		bucketTransactions,
+		bucketUnspentOutputs,
+	}
+
+	// Keys of bucketInternal.
+	keyHeight       = []byte("Height")
+	keyRecentChange = []byte("RecentChange")
+
+	// boltMigrations are the schema migrations of the bolt store.
+	boltMigrations = []persist.BoltMigration{
+		{
+			Version: 1,
+			Migrate: boltCreateBuckets,
+		},
+	}
+)
+
+type (
+	// boltStore stores the index in an embedded bolt database.
+	boltStore struct {
+		db *persist.BoltDatabase
+	}
+
+	// boltOutput is a siacoin output as it is stored in the bolt store.
+	boltOutput struct {
+		Value         types.Currency
+		UnlockHash    types.UnlockHash
+		TransactionID types.TransactionID
+		Height        types.BlockHeight
+		Type          string
+		Spent         bool
+	}
+)
+
+// newBoltStore opens the bolt database at the provided path and migrates its
+// schema.
+func newBoltStore(path string) (*boltStore, error) {
+	db, err := persist.OpenDatabase(boltMetadata, path)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to open database")
+	}
+	err = db.Migrate(boltMigrations)
+	if err != nil {
+		return nil, errors.Compose(errors.AddContext(err, "failed to migrate database"), db.Close())
+	}
+	return &boltStore{db: db}, nil
+}
+
+// boltCreateBuckets creates all of the buckets of the bolt store.
+func boltCreateBuckets(tx *bolt.Tx) error {
+	for _, b := range boltBuckets {
+		if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyConsensusChange implements the store interface.
+func (s *boltStore) ApplyConsensusChange(cc modules.ConsensusChange) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, block := range cc.RevertedBlocks {
+			if err := boltRevertBlock(tx, block); err != nil {
+				return errors.AddContext(err, "unable to revert block "+block.ID().String())
+			}
+		}
+		for i, block := range cc.AppliedBlocks {
+			if err := boltApplyBlock(tx, appliedBlockHeight(cc, i), block); err != nil {
+				return errors.AddContext(err, "unable to apply block "+block.ID().String())
+			}
+		}
+		b := tx.Bucket(bucketInternal)
+		if err := b.Put(keyHeight, encoding.Marshal(cc.NewHeight)); err != nil {
+			return err
+		}
+		return b.Put(keyRecentChange, cc.ID[:])
+	})
+}
+
+// Height implements the store interface.
+func (s *boltStore) Height() (height types.BlockHeight, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketInternal).Get(keyHeight)
+		if b == nil {
+			return nil
+		}
+		return encoding.Unmarshal(b, &height)
+	})
+	return
+}
+
+// RecentChange implements the store interface.
+func (s *boltStore) RecentChange() (ccid modules.ConsensusChangeID, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		copy(ccid[:], tx.Bucket(bucketInternal).Get(keyRecentChange))
+		return nil
+	})
+	return
+}
+
+// Reset implements the store interface.
+func (s *boltStore) Reset() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, b := range boltBuckets {
+			if err := tx.DeleteBucket(b); err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+		}
+		return boltCreateBuckets(tx)
+	})
+}
+
+// UnspentOutputs implements the store interface.
+func (s *boltStore) UnspentOutputs(uh types.UnlockHash) ([]modules.IndexOutput, error) {
+	outputs := []modules.IndexOutput{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketUnspentOutputs).Bucket(uh[:])
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, _ []byte) error {
+			var id types.SiacoinOutputID
+			copy(id[:], k)
+			o, exists, err := boltGetOutput(tx, id)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return errors.New("unspent output is missing " + id.String())
+			}
+			outputs = append(outputs, modules.IndexOutput{
+				ID:            id,
+				Value:         o.Value,
+				UnlockHash:    o.UnlockHash,
+				TransactionID: o.TransactionID,
+				Height:        o.Height,
+				Type:          o.Type,
+			})
+			return nil
+		})
+	})
+	// Keys are ordered by id, the outputs should be ordered by height.
+	sort.SliceStable(outputs, func(i, j int) bool {
+		return outputs[i].Height < outputs[j].Height
+	})
+	return outputs, err
+}
+
+// Close implements the store interface.
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
+
+// boltApplyBlock adds the outputs, inputs and transactions of a block to the
+// database.
+func boltApplyBlock(tx *bolt.Tx, h types.BlockHeight, block types.Block) error {
+	bid := block.ID()
+	err := tx.Bucket(bucketBlocks).Put(bid[:], encoding.Marshal(h))
+	if err != nil {
+		return err
+	}
+
+	for j, payout := range block.MinerPayouts {
+		err = boltInsertOutput(tx, block.MinerPayoutID(uint64(j)), boltOutput{
+			Value:      payout.Value,
+			UnlockHash: payout.UnlockHash,
+			Height:     h,
+			Type:       outputTypeMined,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, txn := range block.Transactions {
+		txid := txn.ID()
+		err = tx.Bucket(bucketTransactions).Put(txid[:], encoding.Marshal(h))
+		if err != nil {
+			return err
+		}
+		for _, sci := range txn.SiacoinInputs {
+			err = tx.Bucket(bucketInputs).Put(boltInputKey(sci.ParentID, txid), encoding.Marshal(h))
+			if err != nil {
+				return err
+			}
+			err = boltSetSpent(tx, sci.ParentID, true)
+			if err != nil {
+				return err
+			}
+		}
+		for j, sco := range txn.SiacoinOutputs {
+			err = boltInsertOutput(tx, txn.SiacoinOutputID(uint64(j)), boltOutput{
+				Value:         sco.Value,
+				UnlockHash:    sco.UnlockHash,
+				TransactionID: txid,
+				Height:        h,
+				Type:          outputTypeNormal,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// boltRevertBlock removes the outputs, inputs and transactions of a block from
+// the database. The changes of boltApplyBlock are undone in reverse order,
+// which marks the outputs spent by the block as unspent again.
+func boltRevertBlock(tx *bolt.Tx, block types.Block) error {
+	for i := len(block.Transactions) - 1; i >= 0; i-- {
+		txn := block.Transactions[i]
+		txid := txn.ID()
+		for j := range txn.SiacoinOutputs {
+			if err := boltDeleteOutput(tx, txn.SiacoinOutputID(uint64(j))); err != nil {
+				return err
+			}
+		}
+		for _, sci := range txn.SiacoinInputs {
+			if err := tx.Bucket(bucketInputs).Delete(boltInputKey(sci.ParentID, txid)); err != nil {
+				return err
+			}
+			if err := boltSetSpent(tx, sci.ParentID, false); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(bucketTransactions).Delete(txid[:]); err != nil {
+			return err
+		}
+	}
+
+	for j := range block.MinerPayouts {
+		if err := boltDeleteOutput(tx, block.MinerPayoutID(uint64(j))); err != nil {
+			return err
+		}
+	}
+	bid := block.ID()
+	return tx.Bucket(bucketBlocks).Delete(bid[:])
+}
+
+// boltInputKey returns the key of an input within bucketInputs.
+func boltInputKey(parentID types.SiacoinOutputID, txid types.TransactionID) []byte {
+	return append(append([]byte{}, parentID[:]...), txid[:]...)
+}
+
+// boltGetOutput loads an output from the database.
+func boltGetOutput(tx *bolt.Tx, id types.SiacoinOutputID) (o boltOutput, exists bool, err error) {
+	b := tx.Bucket(bucketOutputs).Get(id[:])
+	if b == nil {
+		return boltOutput{}, false, nil
+	}
+	err = encoding.Unmarshal(b, &o)
+	return o, err == nil, err
+}
+
+// boltInsertOutput adds an unspent output to the database. Outputs that exist
+// already are left untouched.
+func boltInsertOutput(tx *bolt.Tx, id types.SiacoinOutputID, o boltOutput) error {
+	_, exists, err := boltGetOutput(tx, id)
+	if err != nil || exists {
+		return err
+	}
+	err = tx.Bucket(bucketOutputs).Put(id[:], encoding.Marshal(o))
+	if err != nil {
+		return err
+	}
+	b, err := tx.Bucket(bucketUnspentOutputs).CreateBucketIfNotExists(o.UnlockHash[:])
+	if err != nil {
+		return err
+	}
+	return b.Put(id[:], []byte{})
+}
+
+// boltDeleteOutput removes an output from the database.
+func boltDeleteOutput(tx *bolt.Tx, id types.SiacoinOutputID) error {
+	o, exists, err := boltGetOutput(tx, id)
+	if err != nil || !exists {
+		return err
+	}
+	if b := tx.Bucket(bucketUnspentOutputs).Bucket(o.UnlockHash[:]); b != nil {
+		if err = b.Delete(id[:]); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(bucketOutputs).Delete(id[:])
+}
+
+// boltSetSpent marks an output as spent or unspent. Outputs that are unknown
+// to the index are ignored.
+func boltSetSpent(tx *bolt.Tx, id types.SiacoinOutputID, spent bool) error {
+	o, exists, err := boltGetOutput(tx, id)
+	if err != nil || !exists {
+		return err
+	}
+	o.Spent = spent
+	err = tx.Bucket(bucketOutputs).Put(id[:], encoding.Marshal(o))
+	if err != nil {
+		return err
+	}
+	b, err := tx.Bucket(bucketUnspentOutputs).CreateBucketIfNotExists(o.UnlockHash[:])
+	if err != nil {
+		return err
+	}
+	if spent {
+		return b.Delete(id[:])
+	}
+	return b.Put(id[:], []byte{})
+}
@@ -0,0 +1,120 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EvilRedHorse/pubaccess-node/build"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+
+	"gitlab.com/NebulousLabs/fastrand"
+)
+
+// newTestBoltStore creates a bolt store in a temporary directory.
+func newTestBoltStore(t *testing.T) *boltStore {
+	dir := build.TempDir(modules.IndexDir, t.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	s, err := newBoltStore(filepath.Join(dir, boltFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// TestBoltStoreRevert checks that reverting a block removes its outputs and
+// marks the outputs it spent as unspent again.
+func TestBoltStoreRevert(t *testing.T) {
+	s := newTestBoltStore(t)
+	defer s.Close()
+
+	var addr1, addr2 types.UnlockHash
+	fastrand.Read(addr1[:])
+	fastrand.Read(addr2[:])
+
+	// The first block pays addr1.
+	b1 := types.Block{
+		Timestamp:    1,
+		MinerPayouts: []types.SiacoinOutput{{Value: types.NewCurrency64(10), UnlockHash: addr1}},
+	}
+	// The second block spends the payout and sends it to addr2.
+	b2 := types.Block{
+		ParentID:  b1.ID(),
+		Timestamp: 2,
+		Transactions: []types.Transaction{{
+			SiacoinInputs:  []types.SiacoinInput{{ParentID: b1.MinerPayoutID(0)}},
+			SiacoinOutputs: []types.SiacoinOutput{{Value: types.NewCurrency64(10), UnlockHash: addr2}},
+		}},
+	}
+
+	var cc modules.ConsensusChange
+	fastrand.Read(cc.ID[:])
+	cc.AppliedBlocks = []types.Block{b1, b2}
+	cc.NewHeight = 2
+	if err := s.ApplyConsensusChange(cc); err != nil {
+		t.Fatal(err)
+	}
+	assertOutputs := func(uh types.UnlockHash, n int) []modules.IndexOutput {
+		t.Helper()
+		outputs, err := s.UnspentOutputs(uh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(outputs) != n {
+			t.Fatalf("expected %v outputs, got %v", n, len(outputs))
+		}
+		return outputs
+	}
+	assertOutputs(addr1, 0)
+	outputs := assertOutputs(addr2, 1)
+	if outputs[0].Height != 2 || outputs[0].Type != outputTypeNormal {
+		t.Fatal("wrong output", outputs[0])
+	}
+
+	// Revert the second block.
+	cc2 := modules.ConsensusChange{
+		RevertedBlocks: []types.Block{b2},
+		NewHeight:      1,
+	}
+	fastrand.Read(cc2.ID[:])
+	if err := s.ApplyConsensusChange(cc2); err != nil {
+		t.Fatal(err)
+	}
+	outputs = assertOutputs(addr1, 1)
+	if outputs[0].ID != b1.MinerPayoutID(0) || outputs[0].Height != 1 || outputs[0].Type != outputTypeMined {
+		t.Fatal("wrong output", outputs[0])
+	}
+	assertOutputs(addr2, 0)
+
+	// The height and recent change should be updated.
+	height, err := s.Height()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != 1 {
+		t.Fatal("wrong height", height)
+	}
+	recent, err := s.RecentChange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recent != cc2.ID {
+		t.Fatal("wrong recent change")
+	}
+
+	// Reset should remove everything.
+	if err := s.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	assertOutputs(addr1, 0)
+	recent, err = s.RecentChange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recent != modules.ConsensusChangeBeginning {
+		t.Fatal("recent change wasn't reset")
+	}
+}
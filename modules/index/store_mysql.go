@@ -0,0 +1,383 @@
+package index
+
+import (
+	"database/sql"
+	"math/big"
+	"strings"
+
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/persist"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+
+	// The mysql store uses the MySQL driver.
+	_ "github.com/go-sql-driver/mysql"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+const (
+	// mysqlVersionTable is the table that tracks the schema version of the
+	// index tables.
+	mysqlVersionTable = "index_schema_version"
+
+	// metaRecentChange is the key of the most recent consensus change that
+	// was written to the database.
+	metaRecentChange = "recent_change"
+)
+
+var (
+	// mysqlTables are the tables that contain indexed blockchain data. They
+	// are cleared when the index needs to rescan the blockchain.
+	mysqlTables = []string{"outputs", "inputs", "transactions", "block_meta", "index_meta"}
+
+	// mysqlMigrations are the schema migrations of the index tables. Version 1
+	// creates the tables of new databases. Databases that were set up by hand
+	// before migrations were introduced already contain the tables, version 2
+	// adds the columns and keys that are missing from them.
+	mysqlMigrations = []persist.SQLMigration{
+		{
+			Version: 1,
+			Statements: []string{
+				`CREATE TABLE IF NOT EXISTS outputs (
+					id varchar(64) NOT NULL,
+					amount varchar(128) NOT NULL,
+					unlockhash varchar(76) NOT NULL,
+					txid varchar(64) NOT NULL,
+					height int(11) unsigned NOT NULL,
+					type varchar(16) NOT NULL,
+					spent tinyint(1) NOT NULL DEFAULT '0',
+					PRIMARY KEY (id),
+					KEY unlockhash (unlockhash, spent),
+					KEY height (height)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8`,
+				`CREATE TABLE IF NOT EXISTS inputs (
+					output_id varchar(64) NOT NULL,
+					height int(11) unsigned NOT NULL,
+					txid varchar(64) NOT NULL,
+					PRIMARY KEY (output_id, txid),
+					KEY height (height)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8`,
+				`CREATE TABLE IF NOT EXISTS transactions (
+					id varchar(64) NOT NULL,
+					height int(11) unsigned NOT NULL,
+					PRIMARY KEY (id),
+					KEY height (height)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8`,
+				`CREATE TABLE IF NOT EXISTS block_meta (
+					block_id varchar(64) NOT NULL,
+					height int(11) unsigned NOT NULL,
+					PRIMARY KEY (block_id),
+					KEY height (height)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8`,
+				`CREATE TABLE IF NOT EXISTS index_meta (
+					name varchar(32) NOT NULL,
+					value varchar(255) NOT NULL,
+					PRIMARY KEY (name)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8`,
+			},
+		},
+		{
+			Version: 2,
+			Migrate: mysqlMigrateExistingTables,
+		},
+	}
+
+	// mysqlKeys are the keys of the index tables, mapped to the columns they
+	// cover.
+	mysqlKeys = []struct {
+		table   string
+		name    string
+		columns []string
+	}{
+		{"outputs", "unlockhash", []string{"unlockhash", "spent"}},
+		{"outputs", "height", []string{"height"}},
+		{"inputs", "height", []string{"height"}},
+		{"transactions", "height", []string{"height"}},
+		{"block_meta", "height", []string{"height"}},
+	}
+)
+
+// mysqlStore stores the index in a MySQL database.
+type mysqlStore struct {
+	db *sql.DB
+}
+
+// newMySQLStore connects to a MySQL database and migrates its schema.
+func newMySQLStore(connection string) (*mysqlStore, error) {
+	db, err := sql.Open("mysql", connection)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to open database")
+	}
+	err = db.Ping()
+	if err != nil {
+		return nil, errors.Compose(errors.AddContext(err, "failed to ping database"), db.Close())
+	}
+	err = persist.MigrateSQL(db, mysqlVersionTable, mysqlMigrations)
+	if err != nil {
+		return nil, errors.Compose(errors.AddContext(err, "failed to migrate database"), db.Close())
+	}
+	return &mysqlStore{db: db}, nil
+}
+
+// ApplyConsensusChange implements the store interface.
+func (s *mysqlStore) ApplyConsensusChange(cc modules.ConsensusChange) (err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.AddContext(err, "unable to begin database transaction")
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Compose(err, tx.Rollback())
+		}
+	}()
+
+	for _, block := range cc.RevertedBlocks {
+		err = mysqlRevertBlock(tx, block)
+		if err != nil {
+			return errors.AddContext(err, "unable to revert block "+block.ID().String())
+		}
+	}
+	for i, block := range cc.AppliedBlocks {
+		err = mysqlApplyBlock(tx, appliedBlockHeight(cc, i), block)
+		if err != nil {
+			return errors.AddContext(err, "unable to apply block "+block.ID().String())
+		}
+	}
+	_, err = tx.Exec("INSERT INTO index_meta(name,value) VALUES(?,?) ON DUPLICATE KEY UPDATE value=VALUES(value)", metaRecentChange, crypto.Hash(cc.ID).String())
+	if err != nil {
+		return errors.AddContext(err, "unable to update recent consensus change")
+	}
+	return tx.Commit()
+}
+
+// Height implements the store interface.
+func (s *mysqlStore) Height() (types.BlockHeight, error) {
+	var value sql.NullInt64
+	err := s.db.QueryRow("SELECT MAX(height) FROM block_meta").Scan(&value)
+	if err != nil {
+		return 0, err
+	}
+	return types.BlockHeight(value.Int64), nil
+}
+
+// RecentChange implements the store interface.
+func (s *mysqlStore) RecentChange() (modules.ConsensusChangeID, error) {
+	var value string
+	err := s.db.QueryRow("SELECT value FROM index_meta WHERE name=?", metaRecentChange).Scan(&value)
+	if err == sql.ErrNoRows {
+		return modules.ConsensusChangeBeginning, nil
+	}
+	if err != nil {
+		return modules.ConsensusChangeID{}, err
+	}
+	var h crypto.Hash
+	err = h.LoadString(value)
+	if err != nil {
+		return modules.ConsensusChangeID{}, errors.AddContext(err, "invalid consensus change id")
+	}
+	return modules.ConsensusChangeID(h), nil
+}
+
+// Reset implements the store interface.
+func (s *mysqlStore) Reset() (err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Compose(err, tx.Rollback())
+		}
+	}()
+	for _, table := range mysqlTables {
+		_, err = tx.Exec("DELETE FROM " + table)
+		if err != nil {
+			return errors.AddContext(err, "unable to clear table "+table)
+		}
+	}
+	return tx.Commit()
+}
+
+// UnspentOutputs implements the store interface.
+func (s *mysqlStore) UnspentOutputs(uh types.UnlockHash) ([]modules.IndexOutput, error) {
+	rows, err := s.db.Query("SELECT id,amount,txid,height,type FROM outputs WHERE unlockhash=? AND spent=0 ORDER BY height, id", uh.String())
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to query unspent outputs")
+	}
+	defer rows.Close()
+
+	outputs := []modules.IndexOutput{}
+	for rows.Next() {
+		var id, amount, txid string
+		o := modules.IndexOutput{UnlockHash: uh}
+		err = rows.Scan(&id, &amount, &txid, &o.Height, &o.Type)
+		if err != nil {
+			return nil, errors.AddContext(err, "unable to scan output")
+		}
+		if err = (*crypto.Hash)(&o.ID).LoadString(id); err != nil {
+			return nil, errors.AddContext(err, "invalid output id")
+		}
+		if err = (*crypto.Hash)(&o.TransactionID).LoadString(txid); err != nil {
+			return nil, errors.AddContext(err, "invalid transaction id")
+		}
+		value, ok := new(big.Int).SetString(amount, 10)
+		if !ok {
+			return nil, errors.New("invalid output amount " + amount)
+		}
+		o.Value = types.NewCurrency(value)
+		outputs = append(outputs, o)
+	}
+	return outputs, rows.Err()
+}
+
+// Close implements the store interface.
+func (s *mysqlStore) Close() error {
+	return s.db.Close()
+}
+
+// mysqlMigrateExistingTables adds the spent column and the keys of the index
+// tables to tables that were created without them. Outputs that were spent
+// before the spent column existed are marked using the inputs table.
+func mysqlMigrateExistingTables(tx *sql.Tx) error {
+	var spentColumns int
+	err := tx.QueryRow("SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA=DATABASE() AND TABLE_NAME='outputs' AND COLUMN_NAME='spent'").Scan(&spentColumns)
+	if err != nil {
+		return errors.AddContext(err, "unable to check for spent column")
+	}
+	if spentColumns == 0 {
+		_, err = tx.Exec("ALTER TABLE outputs ADD COLUMN spent tinyint(1) NOT NULL DEFAULT '0'")
+		if err != nil {
+			return errors.AddContext(err, "unable to add spent column")
+		}
+		_, err = tx.Exec("UPDATE outputs JOIN inputs ON inputs.output_id=outputs.id SET outputs.spent=1")
+		if err != nil {
+			return errors.AddContext(err, "unable to mark spent outputs")
+		}
+	}
+
+	for _, key := range mysqlKeys {
+		columns, err := mysqlKeyColumns(tx, key.table, key.name)
+		if err != nil {
+			return errors.AddContext(err, "unable to read key "+key.name+" of table "+key.table)
+		}
+		if strings.Join(columns, ",") == strings.Join(key.columns, ",") {
+			continue
+		}
+		if len(columns) > 0 {
+			_, err = tx.Exec("ALTER TABLE " + key.table + " DROP KEY " + key.name)
+			if err != nil {
+				return errors.AddContext(err, "unable to drop key "+key.name+" of table "+key.table)
+			}
+		}
+		_, err = tx.Exec("ALTER TABLE " + key.table + " ADD KEY " + key.name + " (" + strings.Join(key.columns, ", ") + ")")
+		if err != nil {
+			return errors.AddContext(err, "unable to add key "+key.name+" to table "+key.table)
+		}
+	}
+	return nil
+}
+
+// mysqlKeyColumns returns the columns that are covered by a key of a table, in
+// the order of the key. No columns are returned if the key doesn't exist.
+func mysqlKeyColumns(tx *sql.Tx, table, key string) ([]string, error) {
+	rows, err := tx.Query("SELECT COLUMN_NAME FROM information_schema.STATISTICS WHERE TABLE_SCHEMA=DATABASE() AND TABLE_NAME=? AND INDEX_NAME=? ORDER BY SEQ_IN_INDEX", table, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// mysqlApplyBlock adds the outputs, inputs and transactions of a block to the
+// database.
+func mysqlApplyBlock(tx *sql.Tx, h types.BlockHeight, block types.Block) error {
+	_, err := tx.Exec("INSERT IGNORE INTO block_meta(block_id,height) VALUES(?,?)", block.ID().String(), h)
+	if err != nil {
+		return err
+	}
+
+	for j, payout := range block.MinerPayouts {
+		err = mysqlInsertOutput(tx, block.MinerPayoutID(uint64(j)), payout, h, outputTypeMined, types.TransactionID{})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, txn := range block.Transactions {
+		txid := txn.ID()
+		_, err = tx.Exec("INSERT IGNORE INTO transactions(id,height) VALUES(?,?)", txid.String(), h)
+		if err != nil {
+			return err
+		}
+		for _, sci := range txn.SiacoinInputs {
+			_, err = tx.Exec("INSERT IGNORE INTO inputs(output_id,height,txid) VALUES(?,?,?)", sci.ParentID.String(), h, txid.String())
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec("UPDATE outputs SET spent=1 WHERE id=?", sci.ParentID.String())
+			if err != nil {
+				return err
+			}
+		}
+		for j, sco := range txn.SiacoinOutputs {
+			err = mysqlInsertOutput(tx, txn.SiacoinOutputID(uint64(j)), sco, h, outputTypeNormal, txid)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mysqlRevertBlock removes the outputs, inputs and transactions of a block
+// from the database. The changes of mysqlApplyBlock are undone in reverse
+// order, which marks the outputs spent by the block as unspent again.
+func mysqlRevertBlock(tx *sql.Tx, block types.Block) error {
+	for i := len(block.Transactions) - 1; i >= 0; i-- {
+		txn := block.Transactions[i]
+		txid := txn.ID()
+		for j := range txn.SiacoinOutputs {
+			_, err := tx.Exec("DELETE FROM outputs WHERE id=?", txn.SiacoinOutputID(uint64(j)).String())
+			if err != nil {
+				return err
+			}
+		}
+		for _, sci := range txn.SiacoinInputs {
+			_, err := tx.Exec("DELETE FROM inputs WHERE output_id=? AND txid=?", sci.ParentID.String(), txid.String())
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec("UPDATE outputs SET spent=0 WHERE id=?", sci.ParentID.String())
+			if err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec("DELETE FROM transactions WHERE id=?", txid.String())
+		if err != nil {
+			return err
+		}
+	}
+
+	for j := range block.MinerPayouts {
+		_, err := tx.Exec("DELETE FROM outputs WHERE id=?", block.MinerPayoutID(uint64(j)).String())
+		if err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec("DELETE FROM block_meta WHERE block_id=?", block.ID().String())
+	return err
+}
+
+// mysqlInsertOutput adds a siacoin output to the database.
+func mysqlInsertOutput(tx *sql.Tx, scoid types.SiacoinOutputID, output types.SiacoinOutput, h types.BlockHeight, otype string, txid types.TransactionID) error {
+	_, err := tx.Exec("INSERT IGNORE INTO outputs(id,amount,unlockhash,txid,height,type) VALUES(?,?,?,?,?,?)", scoid.String(), output.Value.String(), output.UnlockHash.String(), txid.String(), h, otype)
+	return err
+}
@@ -0,0 +1,121 @@
+package index
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/EvilRedHorse/pubaccess-node/build"
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+
+	"gitlab.com/NebulousLabs/fastrand"
+)
+
+const (
+	// The MySQL tests use the same test user as the mining pool tests.
+	tdbUser    = "miningpool_test"
+	tdbPass    = "miningpool_test"
+	tdbAddress = "127.0.0.1"
+	tdbPort    = "3306"
+	tdbName    = "index_test"
+)
+
+// TestMySQLStoreMigrateExistingTables checks that tables which were created by
+// hand before migrations were introduced get the spent column and the keys of
+// the current schema.
+func TestMySQLStoreMigrateExistingTables(t *testing.T) {
+	if !build.POOL {
+		t.SkipNow()
+	}
+
+	// Create a database with the tables the index used before migrations.
+	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%s)/", tdbUser, tdbPass, tdbAddress, tdbPort))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	statements := []string{
+		"DROP DATABASE IF EXISTS " + tdbName,
+		"CREATE DATABASE " + tdbName,
+		`CREATE TABLE ` + tdbName + `.outputs (
+			id varchar(64) NOT NULL,
+			amount varchar(128) NOT NULL,
+			unlockhash varchar(76) NOT NULL,
+			txid varchar(64) NOT NULL,
+			height int(11) unsigned NOT NULL,
+			type varchar(16) NOT NULL,
+			PRIMARY KEY (id),
+			KEY unlockhash (unlockhash)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8`,
+		`CREATE TABLE ` + tdbName + `.inputs (
+			output_id varchar(64) NOT NULL,
+			height int(11) unsigned NOT NULL,
+			txid varchar(64) NOT NULL,
+			PRIMARY KEY (output_id, txid)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8`,
+		`CREATE TABLE ` + tdbName + `.transactions (
+			id varchar(64) NOT NULL,
+			height int(11) unsigned NOT NULL,
+			PRIMARY KEY (id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8`,
+		`CREATE TABLE ` + tdbName + `.block_meta (
+			block_id varchar(64) NOT NULL,
+			height int(11) unsigned NOT NULL,
+			PRIMARY KEY (block_id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Add two outputs of the same address, one of them is spent.
+	var addr types.UnlockHash
+	fastrand.Read(addr[:])
+	var spentID, unspentID, txid crypto.Hash
+	fastrand.Read(spentID[:])
+	fastrand.Read(unspentID[:])
+	fastrand.Read(txid[:])
+	for _, id := range []crypto.Hash{spentID, unspentID} {
+		_, err = db.Exec("INSERT INTO "+tdbName+".outputs(id,amount,unlockhash,txid,height,type) VALUES(?,?,?,?,?,?)", id.String(), "10", addr.String(), txid.String(), 1, outputTypeNormal)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = db.Exec("INSERT INTO "+tdbName+".inputs(output_id,height,txid) VALUES(?,?,?)", spentID.String(), 2, txid.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Opening the store migrates the tables.
+	s, err := newMySQLStore(fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", tdbUser, tdbPass, tdbAddress, tdbPort, tdbName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	outputs, err := s.UnspentOutputs(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 1 || crypto.Hash(outputs[0].ID) != unspentID {
+		t.Fatal("expected only the unspent output", outputs)
+	}
+
+	// The keys should cover the columns of the current schema.
+	tx, err := s.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	for _, key := range mysqlKeys {
+		columns, err := mysqlKeyColumns(tx, key.table, key.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(columns) != fmt.Sprint(key.columns) {
+			t.Fatalf("key %v of table %v covers %v, expected %v", key.name, key.table, columns, key.columns)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package index
+
+import (
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+)
+
+// ProcessConsensusChange follows the most recent changes to the consensus set.
+// The reverted blocks are removed from the store and the applied blocks are
+// added atomically, together with the id of the consensus change. This way the
+// store never contains the outputs of blocks that are no longer part of the
+// longest chain.
+func (index *Index) ProcessConsensusChange(cc modules.ConsensusChange) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	if index.outOfSync {
+		return
+	}
+
+	index.log.Debugf("CCID %v (height %v): %v applied blocks, %v reverted blocks", crypto.Hash(cc.ID).String()[:8], cc.NewHeight, len(cc.AppliedBlocks), len(cc.RevertedBlocks))
+	err := index.staticStore.ApplyConsensusChange(cc)
+	if err != nil {
+		index.log.Severe("ERROR: failed to index consensus change, the index stops following the consensus set:", err)
+		index.outOfSync = true
+		return
+	}
+	index.currentHeight = cc.NewHeight
+}
@@ -22,9 +22,23 @@ type (
 		PoolNetworkPort  int              `json:"networkport"`
 		PoolName         string           `json:"name"`
 		PoolID           uint64           `json:"poolid"`
+		PoolDBDriver     string           `json:"dbdriver"`
 		PoolDBConnection string           `json:"dbconnection"`
 		PoolDBName       string           `json:"dbname"`
 		PoolWallet       types.UnlockHash `json:"poolwallet"`
+
+		// Stratum over TLS settings. The TLS listener is disabled if the port
+		// is zero.
+		PoolTLSNetworkPort int    `json:"tlsnetworkport"`
+		PoolTLSCertFile    string `json:"tlscertfile"`
+		PoolTLSKeyFile     string `json:"tlskeyfile"`
+
+		// Payout settings. The minimum payout is the balance an account
+		// needs before it gets paid.
+		PoolPayoutScheme       string         `json:"payoutscheme"`
+		PoolPPLNSWindow        float64        `json:"pplnswindow"`
+		PoolOperatorPercentage float64        `json:"operatorpercentage"`
+		PoolMinimumPayout      types.Currency `json:"minimumpayout"`
 	}
 
 	// PoolClient contains summary info for a mining client
@@ -32,6 +46,7 @@ type (
 		ClientName  string       `json:"clientname"`
 		Balance     string       `json:"balance"`
 		BlocksMined uint64       `json:"blocksminer"`
+		Hashrate    float64      `json:"hashrate"`
 		Workers     []PoolWorker `json:"workers"`
 	}
 
@@ -42,6 +57,14 @@ type (
 		Memo          string    `json:"memo"`
 	}
 
+	// PoolPayout represents a payment of the pool to a mining client
+	PoolPayout struct {
+		ClientName    string              `json:"clientname"`
+		Amount        types.Currency      `json:"amount"`
+		TransactionID types.TransactionID `json:"transactionid"`
+		Time          time.Time           `json:"time"`
+	}
+
 	// PoolWorker represents a mining client worker
 	PoolWorker struct {
 		WorkerName             string    `json:"workername"`
@@ -52,15 +75,18 @@ type (
 		InvalidSharesThisBlock uint64    `json:"invalidsharesthisblock"`
 		StaleSharesThisBlock   uint64    `json:"stalesharesthisblock"`
 		BlocksFound            uint64    `json:"blocksfound"`
+		Hashrate               float64   `json:"hashrate"`
 	}
 
 	// PoolBlock represents a block mined by the pool
 	PoolBlock struct {
-		BlockNumber uint64    `json:"blocknumber"`
-		BlockHeight uint64    `json:"blockheight"`
-		BlockReward string    `json:"blockreward"`
-		BlockTime   time.Time `json:"blocktime"`
-		BlockStatus string    `json:"blockstatus"`
+		BlockNumber   uint64    `json:"blocknumber"`
+		BlockHeight   uint64    `json:"blockheight"`
+		BlockID       string    `json:"blockid"`
+		BlockReward   string    `json:"blockreward"`
+		BlockTime     time.Time `json:"blocktime"`
+		BlockStatus   string    `json:"blockstatus"`
+		Confirmations uint64    `json:"confirmations"`
 	}
 
 	// PoolBlockClient represents a block mined by the pool
@@ -98,5 +124,33 @@ type (
 
 		// Returns the number of open tcp connections the pool has opened since startup
 		NumConnectionsOpened() uint64
+
+		// ReconnectClients asks the connected miners to reconnect to another
+		// pool instance after waiting for the provided duration. The miners
+		// reconnect to the current instance if the host is empty.
+		ReconnectClients(host string, port int, wait time.Duration) error
+
+		// BroadcastMessage shows a message to the operators of the connected
+		// miners.
+		BroadcastMessage(message string) error
+
+		// Payouts returns the payments the pool made to a client, most recent
+		// first. The payments to all clients are returned if the client name
+		// is empty.
+		Payouts(clientName string) ([]PoolPayout, error)
+
+		// Clients returns the clients that are connected to the pool.
+		Clients() ([]PoolClient, error)
+
+		// ClientInfo returns a client of the pool and its connected workers.
+		ClientInfo(name string) (PoolClient, error)
+
+		// Blocks returns the blocks found by the pool, most recent first. At
+		// most limit blocks are returned, starting at offset.
+		Blocks(offset, limit int) ([]PoolBlock, error)
+
+		// BlockClients returns how the reward of a found block was split
+		// between the clients.
+		BlockClients(blockNumber uint64) ([]PoolBlockClient, error)
 	}
 )
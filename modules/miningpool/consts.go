@@ -96,6 +96,15 @@ var (
 		Testing:  uint64(500),
 	}).(uint64)
 
+	// stratumWriteTimeout is the amount of time the pool waits for a miner to
+	// accept a request that is sent outside of the miner's own handler, so
+	// that a stalled miner can't block the other miners.
+	stratumWriteTimeout = build.Select(build.Var{
+		Dev:      time.Second * 10,
+		Standard: time.Second * 10,
+		Testing:  time.Second,
+	}).(time.Duration)
+
 	// rpcRatelimit prevents someone from spamming the pool with connections,
 	// causing it to spin up enough goroutines to crash.
 	rpcRatelimit = build.Select(build.Var{
@@ -3,6 +3,7 @@ package pool
 import (
 	// "fmt"
 
+	"crypto/tls"
 	"net"
 	"time"
 
@@ -16,6 +17,7 @@ import (
 type Dispatcher struct {
 	handlers          map[string]*Handler
 	ln                net.Listener
+	tlsLn             net.Listener
 	mu                deadlock.RWMutex
 	p                 *Pool
 	log               *persist.Logger
@@ -82,19 +84,53 @@ func (d *Dispatcher) ListenHandlers(port string) {
 		// codeblock is reachable.
 		return
 	}
+	defer d.p.tg.Done()
 
-	d.ln, err = net.Listen("tcp", ":"+port)
+	ln, err := net.Listen("tcp", ":"+port)
 	if err != nil {
 		d.log.Println(err)
 		panic(err)
 		// TODO: add error chan to report this
 		//return
 	}
+	d.mu.Lock()
+	d.ln = ln
+	d.mu.Unlock()
 	// fmt.Printf("Listening: %s\n", port)
 
-	defer d.ln.Close()
+	defer ln.Close()
+	d.acceptHandlers(ln, nil)
+}
+
+// ListenTLSHandlers listens on a passed port for stratum connections over
+// TLS. Miners that support it can use it to keep their credentials and shares
+// private.
+func (d *Dispatcher) ListenTLSHandlers(port string, config *tls.Config) {
+	err := d.p.tg.Add()
+	if err != nil {
+		return
+	}
 	defer d.p.tg.Done()
 
+	ln, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		// The plain listener is still serving, so there is no need to take
+		// the pool down.
+		d.log.Println("ERROR: unable to open the stratum TLS listener:", err)
+		return
+	}
+	d.mu.Lock()
+	d.tlsLn = ln
+	d.mu.Unlock()
+
+	defer ln.Close()
+	d.acceptHandlers(ln, config)
+}
+
+// acceptHandlers accepts connections from a listener until the pool stops,
+// and adds a handler for each of them. The connections are wrapped in TLS if
+// a TLS config is provided.
+func (d *Dispatcher) acceptHandlers(ln net.Listener, config *tls.Config) {
 	for {
 		var conn net.Conn
 		var err error
@@ -105,7 +141,7 @@ func (d *Dispatcher) ListenHandlers(port string) {
 			//fmt.Println("Done closing listener")
 			return
 		default:
-			conn, err = d.ln.Accept() // accept connection
+			conn, err = ln.Accept() // accept connection
 			d.IncrementConnectionsOpened()
 			if err != nil {
 				d.log.Println(err)
@@ -121,10 +157,25 @@ func (d *Dispatcher) ListenHandlers(port string) {
 		// maybe this will help with our disconnection problems
 		tcpconn.SetLinger(2)
 
+		if config != nil {
+			conn = tls.Server(conn, config)
+		}
 		go d.AddHandler(conn)
 	}
 }
 
+// closeListeners closes the stratum listeners that are open.
+func (d *Dispatcher) closeListeners() {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.ln != nil {
+		d.ln.Close()
+	}
+	if d.tlsLn != nil {
+		d.tlsLn.Close()
+	}
+}
+
 // NotifyClients tells the dispatcher to notify all clients that the block has
 // changed
 func (d *Dispatcher) NotifyClients() {
@@ -160,3 +211,76 @@ func (d *Dispatcher) ClearJobAndNotifyClients() {
 		h.notify <- true
 	}
 }
+
+// ReconnectClients asks all connected miners to reconnect to another pool
+// instance after waiting for the provided number of seconds.
+func (d *Dispatcher) ReconnectClients(host string, port int, wait int) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	d.log.Printf("Reconnecting %d clients to %s:%d\n", len(d.handlers), host, port)
+	for _, h := range d.handlers {
+		if err := h.sendReconnect(host, port, wait); err != nil {
+			d.log.Println("Unable to reconnect client:", err)
+		}
+	}
+}
+
+// BroadcastMessage shows a message to the operators of all connected miners.
+func (d *Dispatcher) BroadcastMessage(message string) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	d.log.Printf("Sending message to %d clients\n", len(d.handlers))
+	for _, h := range d.handlers {
+		if err := h.sendShowMessage(message); err != nil {
+			d.log.Println("Unable to send message to client:", err)
+		}
+	}
+}
+
+// ResetExtraNonces moves the sessions into the extranonce space of the
+// provided pool id. Miners that subscribed to extranonce changes are sent
+// their new extranonce and a clean job, all other miners are asked to
+// reconnect to get a new session.
+func (d *Dispatcher) ResetExtraNonces(poolID uint64) {
+	// Copy the handlers so that a slow miner doesn't block the dispatcher
+	// while the requests are sent.
+	d.mu.RLock()
+	handlers := make([]*Handler, 0, len(d.handlers))
+	for _, h := range d.handlers {
+		handlers = append(handlers, h)
+	}
+	d.mu.RUnlock()
+
+	for _, h := range handlers {
+		d.resetExtraNonce(h, poolID)
+	}
+}
+
+// resetExtraNonce moves the session of a single miner into the extranonce
+// space of the provided pool id.
+func (d *Dispatcher) resetExtraNonce(h *Handler, poolID uint64) {
+	h.mu.RLock()
+	s := h.s
+	h.mu.RUnlock()
+	if s == nil || s.inExtraNonceSpace(poolID) {
+		return
+	}
+	h.conn.SetWriteDeadline(time.Now().Add(stratumWriteTimeout))
+	defer h.conn.SetWriteDeadline(time.Time{})
+
+	if !s.ExtraNonceSubscribed() {
+		if err := h.sendReconnect("", 0, 0); err != nil {
+			d.log.Println("Unable to reconnect client:", err)
+		}
+		return
+	}
+	s.resetExtraNonce1(poolID)
+	// Jobs that were handed out before are built with the old extranonce and
+	// can't be submitted anymore.
+	s.clearJobs()
+	if err := h.sendSetExtranonce(); err != nil {
+		d.log.Println("Unable to send extranonce to client:", err)
+		return
+	}
+	h.notify <- true
+}
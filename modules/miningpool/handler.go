@@ -13,6 +13,7 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/sasha-s/go-deadlock"
@@ -410,10 +411,11 @@ func (h *Handler) handleStratumAuthorize(m *types.StratumRequest) error {
 	return nil
 }
 
-// handleStratumNonceSubscribe tells the pool that this client can handle the extranonce info
-// TODO: Not sure we have to anything if all our clients support this.
+// handleStratumNonceSubscribe tells the pool that this client can handle the extranonce info,
+// which allows the pool to change the extranonce of the session with mining.set_extranonce.
 func (h *Handler) handleStratumNonceSubscribe(m *types.StratumRequest) error {
 	h.p.log.Debugln("ID = "+strconv.FormatUint(m.ID, 10)+", Method = "+m.Method+", params = ", m.Params)
+	h.s.SetExtraNonceSubscribed(true)
 
 	// not sure why 3 is right, but ccminer expects it to be 3
 	r := types.StratumResponse{ID: 3}
@@ -489,7 +491,7 @@ func (h *Handler) handleStratumSubmit(m *types.StratumRequest) error {
 		r.Result = false
 		r.Error = interfaceify([]string{"22", "Stale - old/unknown job"}) //json.RawMessage(`["21","Stale - old/unknown job"]`)
 		h.s.CurrentWorker.log.Printf("Stale Share rejected - old/unknown job\n")
-		h.s.CurrentWorker.IncrementInvalidShares()
+		h.s.CurrentWorker.IncrementStaleShares()
 		return h.sendResponse(r)
 	}
 
@@ -558,6 +560,9 @@ func (h *Handler) handleStratumSubmit(m *types.StratumRequest) error {
 		h.s.CurrentWorker.Parent().log.Printf("Yay!!! Solved a block!!\n")
 		// h.s.CurrentWorker.log.Printf("Yay!!! Solved a block!!\n")
 		h.s.clearJobs()
+		h.s.CurrentWorker.incrementBlocksFound()
+		// Start counting the shares of the next block.
+		atomic.AddUint64(&h.p.blockEpoch, 1)
 		err = h.s.CurrentWorker.addFoundBlock(&b)
 		if err != nil {
 			h.s.CurrentWorker.log.Printf("Failed to update block in database: %s\n", err)
@@ -579,6 +584,36 @@ func (h *Handler) sendSetDifficulty(d float64) error {
 	return h.sendRequest(r)
 }
 
+// sendSetExtranonce tells the miner to use the current extranonce1 of the
+// session for the following jobs.
+func (h *Handler) sendSetExtranonce() error {
+	var r types.StratumRequest
+	r.Method = "mining.set_extranonce"
+	r.ID = 0
+	r.Params = []interface{}{h.s.printNonce(), extraNonce2Size}
+	return h.sendRequest(r)
+}
+
+// sendReconnect asks the miner to reconnect to another pool instance after
+// waiting for the provided number of seconds. The miner reconnects to the
+// current instance if the host is empty.
+func (h *Handler) sendReconnect(host string, port int, wait int) error {
+	var r types.StratumRequest
+	r.Method = "client.reconnect"
+	r.ID = 0
+	r.Params = []interface{}{host, port, wait}
+	return h.sendRequest(r)
+}
+
+// sendShowMessage shows a message to the operator of the miner.
+func (h *Handler) sendShowMessage(message string) error {
+	var r types.StratumRequest
+	r.Method = "client.show_message"
+	r.ID = 0
+	r.Params = []interface{}{message}
+	return h.sendRequest(r)
+}
+
 func (h *Handler) sendStratumNotify(cleanJobs bool) error {
 	var r types.StratumRequest
 	r.Method = "mining.notify"
@@ -0,0 +1,97 @@
+package pool
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/EvilRedHorse/pubaccess-node/persist"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+)
+
+// TestDispatcherResetExtraNonces checks that miners that subscribed to
+// extranonce changes are sent their new extranonce and a clean job, while all
+// other miners are asked to reconnect. A miner that doesn't read its requests
+// must not block the others.
+func TestDispatcherResetExtraNonces(t *testing.T) {
+	log, err := persist.NewLogger(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &Dispatcher{handlers: make(map[string]*Handler), log: log}
+	newTestHandler := func(addr string, subscribed bool) (*Handler, *bufio.Reader) {
+		server, client := net.Pipe()
+		h := &Handler{
+			conn:   server,
+			notify: make(chan bool, numPendingNotifies),
+			log:    log,
+			s: &Session{
+				ExtraNonce1:          extraNonce1(1, 42),
+				extraNonceSubscribed: subscribed,
+			},
+		}
+		d.handlers[addr] = h
+		return h, bufio.NewReader(client)
+	}
+	subscribed, subscribedConn := newTestHandler("subscribed", true)
+	unsubscribed, unsubscribedConn := newTestHandler("unsubscribed", false)
+	// The connection of the stalled miner is never read.
+	newTestHandler("stalled", false)
+
+	readRequest := func(r *bufio.Reader) types.StratumRequest {
+		// The requests are read in separate goroutines, so t.Fatal can't be
+		// used.
+		var req types.StratumRequest
+		str, err := r.ReadString('\n')
+		if err != nil {
+			t.Error(err)
+			return req
+		}
+		if err := json.Unmarshal([]byte(str), &req); err != nil {
+			t.Error(err)
+		}
+		return req
+	}
+
+	// The pipes are unbuffered, so the requests have to be read while the
+	// dispatcher sends them.
+	r := make(chan types.StratumRequest, 2)
+	go func() { r <- readRequest(subscribedConn) }()
+	go func() { r <- readRequest(unsubscribedConn) }()
+	done := make(chan struct{})
+	go func() {
+		d.ResetExtraNonces(2)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(stratumWriteTimeout * 10):
+		t.Fatal("resetting the extranonces was blocked by a stalled miner")
+	}
+	requests := make(map[string]types.StratumRequest)
+	for i := 0; i < 2; i++ {
+		req := <-r
+		requests[req.Method] = req
+	}
+
+	req, ok := requests["mining.set_extranonce"]
+	if !ok || len(req.Params) != 2 || req.Params[0] != subscribed.s.printNonce() {
+		t.Fatal("subscribed miner wasn't sent its new extranonce", requests)
+	}
+	if subscribed.s.ExtraNonce1 != extraNonce1(2, 42) {
+		t.Fatalf("extranonce wasn't moved into the new space: %x", subscribed.s.ExtraNonce1)
+	}
+	if len(subscribed.notify) != 1 {
+		t.Fatal("subscribed miner wasn't sent a new job")
+	}
+	req, ok = requests["client.reconnect"]
+	if !ok || len(req.Params) != 3 {
+		t.Fatal("unsubscribed miner wasn't asked to reconnect", requests)
+	}
+	if unsubscribed.s.ExtraNonce1 != extraNonce1(1, 42) {
+		t.Fatal("extranonce of an unsubscribed miner shouldn't change")
+	}
+}
@@ -0,0 +1,273 @@
+package pool
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+)
+
+const (
+	// Statuses of found blocks that are reported by the API.
+	blockStatusImmature = "Immature"
+	blockStatusCredited = "Credited"
+	blockStatusOrphaned = "Orphaned"
+)
+
+var (
+	// errInvalidPagination is returned if a negative offset or limit is
+	// requested.
+	errInvalidPagination = errors.New("offset and limit must not be negative")
+)
+
+// managedConnectedWorkers returns the workers of the connected sessions by
+// the name of their client.
+func (p *Pool) managedConnectedWorkers() map[string][]*Worker {
+	workers := make(map[string][]*Worker)
+	p.runningMutex.RLock()
+	defer p.runningMutex.RUnlock()
+	if !p.running {
+		return workers
+	}
+
+	d := p.dispatcher
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, h := range d.handlers {
+		h.mu.RLock()
+		s := h.s
+		h.mu.RUnlock()
+		if s == nil {
+			continue
+		}
+		s.mu.RLock()
+		c, w := s.Client, s.CurrentWorker
+		s.mu.RUnlock()
+		if c == nil || w == nil {
+			continue
+		}
+		name := c.Name()
+		workers[name] = append(workers[name], w)
+	}
+	return workers
+}
+
+// mergeWorkers combines the statistics of the sessions of a client that use
+// the same worker name. The workers are sorted by name.
+func mergeWorkers(workers []*Worker) []modules.PoolWorker {
+	merged := make(map[string]*modules.PoolWorker)
+	sessions := make(map[string]int)
+	for _, w := range workers {
+		info := w.Info()
+		m, exists := merged[info.WorkerName]
+		if !exists {
+			merged[info.WorkerName] = &info
+			sessions[info.WorkerName] = 1
+			continue
+		}
+		sessions[info.WorkerName]++
+		m.CurrentDifficulty += info.CurrentDifficulty
+		m.CumulativeDifficulty += info.CumulativeDifficulty
+		m.SharesThisBlock += info.SharesThisBlock
+		m.InvalidSharesThisBlock += info.InvalidSharesThisBlock
+		m.StaleSharesThisBlock += info.StaleSharesThisBlock
+		m.BlocksFound += info.BlocksFound
+		m.Hashrate += info.Hashrate
+		if info.LastShareTime.After(m.LastShareTime) {
+			m.LastShareTime = info.LastShareTime
+		}
+	}
+
+	infos := make([]modules.PoolWorker, 0, len(merged))
+	for name, m := range merged {
+		// The difficulty of a worker is the average of its sessions.
+		m.CurrentDifficulty /= float64(sessions[name])
+		infos = append(infos, *m)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].WorkerName < infos[j].WorkerName
+	})
+	return infos
+}
+
+// managedClientInfo returns the info of a client, given its connected workers
+// and the data of the pool database.
+func (p *Pool) managedClientInfo(name string, workers []*Worker, blocks []blockRecord, balances map[int64]types.Currency) (modules.PoolClient, error) {
+	cr, err := p.store.FindClient(name)
+	if err != nil {
+		return modules.PoolClient{}, err
+	}
+	client := modules.PoolClient{
+		ClientName: name,
+		Balance:    balances[cr.ID].String(),
+		Workers:    mergeWorkers(workers),
+	}
+	for _, br := range blocks {
+		if br.UserID == cr.ID && br.Category != blockCategoryOrphan {
+			client.BlocksMined++
+		}
+	}
+	for _, w := range client.Workers {
+		client.Hashrate += w.Hashrate
+	}
+	return client, nil
+}
+
+// managedBalances returns the balances of the accounts by id.
+func (p *Pool) managedBalances() (map[int64]types.Currency, error) {
+	records, err := p.store.Balances()
+	if err != nil {
+		return nil, err
+	}
+	balances := make(map[int64]types.Currency)
+	for _, br := range records {
+		balances[br.UserID] = br.Balance
+	}
+	return balances, nil
+}
+
+// Clients returns the clients that are connected to the pool, sorted by name.
+func (p *Pool) Clients() ([]modules.PoolClient, error) {
+	if err := p.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer p.tg.Done()
+
+	blocks, err := p.store.Blocks()
+	if err != nil {
+		return nil, err
+	}
+	balances, err := p.managedBalances()
+	if err != nil {
+		return nil, err
+	}
+	var clients []modules.PoolClient
+	for name, workers := range p.managedConnectedWorkers() {
+		client, err := p.managedClientInfo(name, workers, blocks, balances)
+		if err == ErrNoUsernameInDatabase {
+			// The client is still being added to the database.
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].ClientName < clients[j].ClientName
+	})
+	return clients, nil
+}
+
+// ClientInfo returns a client of the pool and its connected workers.
+func (p *Pool) ClientInfo(name string) (modules.PoolClient, error) {
+	if err := p.tg.Add(); err != nil {
+		return modules.PoolClient{}, err
+	}
+	defer p.tg.Done()
+
+	blocks, err := p.store.Blocks()
+	if err != nil {
+		return modules.PoolClient{}, err
+	}
+	balances, err := p.managedBalances()
+	if err != nil {
+		return modules.PoolClient{}, err
+	}
+	return p.managedClientInfo(name, p.managedConnectedWorkers()[name], blocks, balances)
+}
+
+// Blocks returns the blocks found by the pool, most recent first. At most
+// limit blocks are returned, starting at offset.
+func (p *Pool) Blocks(offset, limit int) ([]modules.PoolBlock, error) {
+	if err := p.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer p.tg.Done()
+	if offset < 0 || limit < 0 {
+		return nil, errInvalidPagination
+	}
+
+	records, err := p.store.Blocks()
+	if err != nil {
+		return nil, err
+	}
+	if offset >= len(records) {
+		return []modules.PoolBlock{}, nil
+	}
+	end := len(records) - offset
+	start := end - limit
+	if start < 0 {
+		start = 0
+	}
+
+	poolWallet := p.InternalSettings().PoolWallet
+	height := p.cs.Height()
+	blocks := make([]modules.PoolBlock, 0, end-start)
+	for i := end - 1; i >= start; i-- {
+		br := records[i]
+		block := modules.PoolBlock{
+			BlockNumber: uint64(br.ID),
+			BlockHeight: br.Height,
+			BlockID:     br.BlockHash,
+			BlockReward: types.ZeroCurrency.String(),
+			BlockTime:   time.Unix(br.Time, 0),
+		}
+
+		// Blocks that aren't part of the longest chain have no reward and no
+		// confirmations.
+		b, exists := p.cs.BlockAtHeight(types.BlockHeight(br.Height))
+		onChain := exists && b.ID().String() == br.BlockHash
+		if onChain {
+			block.BlockReward = blockReward(b, poolWallet).String()
+			block.Confirmations = uint64(height-types.BlockHeight(br.Height)) + 1
+		}
+
+		switch {
+		case br.Category == blockCategoryOrphan:
+			block.BlockStatus = blockStatusOrphaned
+		case br.Category == blockCategoryGenerate:
+			block.BlockStatus = blockStatusCredited
+		case onChain && height >= types.BlockHeight(br.Height)+types.MaturityDelay:
+			block.BlockStatus = confirmedButUnpaid
+		default:
+			block.BlockStatus = blockStatusImmature
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// BlockClients returns how the reward of a found block was split between the
+// clients. The split is only known once the block matured and its reward was
+// credited.
+func (p *Pool) BlockClients(blockNumber uint64) ([]modules.PoolBlockClient, error) {
+	if err := p.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer p.tg.Done()
+
+	earnings, err := p.store.Earnings(int64(blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	total := types.ZeroCurrency
+	for _, e := range earnings {
+		total = total.Add(e.Amount)
+	}
+	clients := make([]modules.PoolBlockClient, 0, len(earnings))
+	for _, e := range earnings {
+		client := modules.PoolBlockClient{
+			ClientName:   e.Name,
+			ClientReward: e.Amount.String(),
+		}
+		if !total.IsZero() {
+			amount, _ := e.Amount.Float64()
+			sum, _ := total.Float64()
+			client.ClientPercentage = amount / sum * 100
+		}
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
@@ -0,0 +1,56 @@
+package pool
+
+import (
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestMergeWorkers checks that the statistics of sessions with the same worker
+// name are combined, and that the share counts are reset once the pool finds
+// a block.
+func TestMergeWorkers(t *testing.T) {
+	p := &Pool{}
+	c := &Client{pool: p}
+	now := time.Now()
+	newTestWorker := func(name string, shares uint64, difficulty float64) *Worker {
+		return &Worker{
+			wr: WorkerRecord{name: name, parent: c},
+			stats: workerStats{
+				shares:        shares,
+				invalidShares: 1,
+				lastShareTime: now,
+				recentShares:  []shareSample{{time: now, difficulty: difficulty}},
+			},
+		}
+	}
+	workers := []*Worker{
+		newTestWorker("rig2", 5, 1),
+		newTestWorker("rig1", 2, 1),
+		newTestWorker("rig1", 3, 2),
+	}
+
+	infos := mergeWorkers(workers)
+	if len(infos) != 2 || infos[0].WorkerName != "rig1" || infos[1].WorkerName != "rig2" {
+		t.Fatal("workers weren't merged by name", infos)
+	}
+	if infos[0].SharesThisBlock != 5 || infos[0].InvalidSharesThisBlock != 2 {
+		t.Fatal("wrong share counts", infos[0])
+	}
+	// Workers without a session are measured over the whole window.
+	expected := 3 * math.Pow(2, 32) / hashrateWindow.Seconds()
+	if math.Abs(infos[0].Hashrate-expected) > 1 {
+		t.Fatalf("expected hashrate %v, got %v", expected, infos[0].Hashrate)
+	}
+
+	// Finding a block resets the share counts but keeps the hashrate.
+	atomic.AddUint64(&p.blockEpoch, 1)
+	infos = mergeWorkers(workers)
+	if infos[0].SharesThisBlock != 0 || infos[0].InvalidSharesThisBlock != 0 {
+		t.Fatal("share counts weren't reset", infos[0])
+	}
+	if infos[0].Hashrate == 0 {
+		t.Fatal("hashrate shouldn't be reset")
+	}
+}
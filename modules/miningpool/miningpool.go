@@ -5,7 +5,7 @@ package pool
 
 import (
 	"bytes"
-	"database/sql"
+	"crypto/tls"
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
@@ -27,9 +27,6 @@ import (
 	"github.com/EvilRedHorse/pubaccess-node/modules"
 	"github.com/EvilRedHorse/pubaccess-node/persist"
 	"github.com/EvilRedHorse/pubaccess-node/types"
-
-	// blank to load the sql driver for mysql
-	_ "github.com/go-sql-driver/mysql"
 )
 
 var (
@@ -53,6 +50,18 @@ var (
 	// Required settings to run pool
 	errNoAddressSet = errors.New("pool operators address must be set")
 
+	// errTLSPortInUse is returned if the stratum TLS listener is configured
+	// to use the port of the plain stratum listener.
+	errTLSPortInUse = errors.New("stratum TLS port must differ from the network port")
+
+	// errTLSSettingsChanged is returned if the stratum TLS settings are
+	// changed at runtime. The TLS listener is only set up on startup.
+	errTLSSettingsChanged = errors.New("stratum TLS settings can only be changed in the config file and take effect after a restart")
+
+	// errInvalidReconnectPort is returned if miners are asked to reconnect to
+	// a host without a valid port.
+	errInvalidReconnectPort = errors.New("a valid port is required to reconnect to another host")
+
 	running  bool  // indicates if the mining pool is actually running
 	hashRate int64 // indicates hashes per second
 	// HeaderMemory is the number of previous calls to 'header'
@@ -90,6 +99,14 @@ var (
 		Dev:      20 * time.Second,
 		Testing:  1 * time.Second,
 	}).(time.Duration)
+
+	// hashrateWindow is the period over which the shares of a worker are
+	// used to estimate its hashrate.
+	hashrateWindow = build.Select(build.Var{
+		Standard: 10 * time.Minute,
+		Dev:      5 * time.Minute,
+		Testing:  10 * time.Second,
+	}).(time.Duration)
 )
 
 // splitSet defines a transaction set that can be added component-wise to a
@@ -151,23 +168,33 @@ type Pool struct {
 	connectabilityStatus modules.PoolConnectabilityStatus
 
 	// Utilities.
-	sqldb          *sql.DB
+	store          poolStore
 	listener       net.Listener
 	log            *persist.Logger
 	yiilog         *persist.Logger
 	mu             deadlock.RWMutex
-	dbConnectionMu deadlock.RWMutex
 	persistDir     string
 	port           string
 	tg             threadgroup.ThreadGroup
 	persist        persistence
 	dispatcher     *Dispatcher
+	tlsConfig      *tls.Config
 	stratumID      uint64
 	shiftID        uint64
 	shiftChan      chan bool
 	shiftTimestamp time.Time
 	clients        map[string]*Client //client name to client pointer mapping
 
+	// payoutChan wakes up the payout thread after consensus changes.
+	// payoutsHalted is set if sent payouts couldn't be recorded, the pool
+	// stops paying until it is restarted in that case.
+	payoutChan    chan struct{}
+	payoutsHalted bool
+
+	// blockEpoch is incremented whenever the pool finds a block. It resets
+	// the share counts of the workers.
+	blockEpoch uint64
+
 	clientSetupMutex deadlock.Mutex
 	runningMutex     deadlock.RWMutex
 	running          bool
@@ -269,14 +296,16 @@ func (p *Pool) startServer() {
 
 			p.log.Printf("      Starting Stratum Server\n")
 
-			port := fmt.Sprintf("%d", p.InternalSettings().PoolNetworkPort)
+			settings := p.InternalSettings()
+			port := fmt.Sprintf("%d", settings.PoolNetworkPort)
 			go p.dispatcher.ListenHandlers(port)
+			if p.tlsConfig != nil {
+				p.log.Printf("      Starting Stratum TLS Server\n")
+				tlsPort := fmt.Sprintf("%d", settings.PoolTLSNetworkPort)
+				go p.dispatcher.ListenTLSHandlers(tlsPort, p.tlsConfig)
+			}
 			p.tg.OnStop(func() error {
-				if p.dispatcher.ln == nil {
-					//panic(errors.New("network not opened yet"))
-				} else {
-					p.dispatcher.ln.Close()
-				}
+				p.dispatcher.closeListeners()
 				return nil
 			})
 			return
@@ -331,6 +360,7 @@ func newPool(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 		persistDir: persistDir,
 		stratumID:  fastrand.Uint64n(math.MaxUint64/2 + 1),
 		clients:    make(map[string]*Client),
+		payoutChan: make(chan struct{}, 1),
 	}
 	var err error
 
@@ -368,6 +398,10 @@ func newPool(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 		return nil, err
 	}
 	p.setPoolSettings(initConfig)
+	p.tlsConfig, err = newTLSConfig(p.InternalSettings())
+	if err != nil {
+		return nil, errors.New("Failed to load stratum TLS certificate: " + err.Error())
+	}
 
 	p.tg.AfterStop(func() error {
 		p.mu.Lock()
@@ -379,7 +413,10 @@ func newPool(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 		return err
 	})
 
-	p.newDbConnection()
+	p.store, err = p.newPoolStore()
+	if err != nil {
+		return nil, errors.New("Failed to open database: " + err.Error())
+	}
 
 	// clean old worker records for this stratum server just in case we didn't
 	// shutdown cleanly
@@ -390,8 +427,7 @@ func newPool(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 
 	p.tg.OnStop(func() error {
 		p.DeleteAllWorkerRecords()
-		p.sqldb.Close()
-		return nil
+		return p.store.Close()
 	})
 
 	// grab our consensus set data
@@ -411,6 +447,9 @@ func newPool(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 	// spin up a go routine to handle shift changes.
 	go p.monitorShifts()
 
+	// spin up a go routine to pay the clients once found blocks matured.
+	go p.threadedMonitorPayouts()
+
 	p.tg.OnStop(func() error {
 		p.cs.Unsubscribe(p)
 		return nil
@@ -462,6 +501,23 @@ func (p *Pool) SetInternalSettings(settings modules.PoolInternalSettings) error
 	if err != nil {
 		return errors.New("internal settings not updated, no operator wallet set: " + err.Error())
 	}
+	switch settings.PoolPayoutScheme {
+	case config.PayoutSchemeNone, config.PayoutSchemePPLNS, config.PayoutSchemePROP:
+	default:
+		return errors.New("internal settings not updated: " + errUnknownPayoutScheme.Error())
+	}
+	current := p.persist.GetSettings()
+	if settings.PoolTLSNetworkPort != current.PoolTLSNetworkPort ||
+		settings.PoolTLSCertFile != current.PoolTLSCertFile ||
+		settings.PoolTLSKeyFile != current.PoolTLSKeyFile {
+		return errors.New("internal settings not updated: " + errTLSSettingsChanged.Error())
+	}
+
+	// Sessions of miners need an extranonce of the new extranonce space if
+	// the pool id changes.
+	if settings.PoolID != current.PoolID {
+		go p.threadedResetExtraNonces(settings.PoolID)
+	}
 
 	p.persist.SetSettings(settings)
 	p.persist.SetRevisionNumber(p.persist.GetRevisionNumber() + 1)
@@ -563,6 +619,75 @@ func (p *Pool) NumConnections() int {
 	return 0
 }
 
+// newTLSConfig returns the TLS config of the stratum TLS listener, or nil if
+// the listener is disabled.
+func newTLSConfig(settings modules.PoolInternalSettings) (*tls.Config, error) {
+	if settings.PoolTLSNetworkPort == 0 {
+		return nil, nil
+	}
+	if settings.PoolTLSNetworkPort == settings.PoolNetworkPort {
+		return nil, errTLSPortInUse
+	}
+	cert, err := tls.LoadX509KeyPair(settings.PoolTLSCertFile, settings.PoolTLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// threadedResetExtraNonces moves the sessions of the connected miners into the
+// extranonce space of the provided pool id.
+func (p *Pool) threadedResetExtraNonces(poolID uint64) {
+	if err := p.tg.Add(); err != nil {
+		return
+	}
+	defer p.tg.Done()
+
+	p.runningMutex.RLock()
+	defer p.runningMutex.RUnlock()
+	if p.running {
+		p.dispatcher.ResetExtraNonces(poolID)
+	}
+}
+
+// ReconnectClients asks the connected miners to reconnect to another pool
+// instance after waiting for the provided duration. The miners reconnect to
+// the current instance if the host is empty.
+func (p *Pool) ReconnectClients(host string, port int, wait time.Duration) error {
+	if err := p.tg.Add(); err != nil {
+		return err
+	}
+	defer p.tg.Done()
+	if host != "" && (port <= 0 || port > math.MaxUint16) {
+		return errInvalidReconnectPort
+	}
+
+	p.runningMutex.RLock()
+	defer p.runningMutex.RUnlock()
+	if p.running {
+		p.dispatcher.ReconnectClients(host, port, int(wait.Seconds()))
+	}
+	return nil
+}
+
+// BroadcastMessage shows a message to the operators of the connected miners.
+func (p *Pool) BroadcastMessage(message string) error {
+	if err := p.tg.Add(); err != nil {
+		return err
+	}
+	defer p.tg.Done()
+
+	p.runningMutex.RLock()
+	defer p.runningMutex.RUnlock()
+	if p.running {
+		p.dispatcher.BroadcastMessage(message)
+	}
+	return nil
+}
+
 // NumConnectionsOpened returns the total number of tcp connections from clients the
 // pool has opened since startup
 func (p *Pool) NumConnectionsOpened() uint64 {
@@ -6,6 +6,7 @@ import (
 	"net"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -325,6 +326,29 @@ func TestCreatingTestPool(t *testing.T) {
 	time.Sleep(time.Millisecond * 2)
 }
 
+// TestSetInternalSettingsTLS checks that the stratum TLS settings can't be
+// changed at runtime.
+func TestSetInternalSettingsTLS(t *testing.T) {
+	if !build.POOL {
+		return
+	}
+	pt, err := newPoolTester(t.Name(), 0)
+	defer pt.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := pt.mpool.InternalSettings()
+	settings.PoolTLSNetworkPort = settings.PoolNetworkPort + 1
+	settings.PoolTLSKeyFile = "key.pem"
+	err = pt.mpool.SetInternalSettings(settings)
+	if err == nil || !strings.Contains(err.Error(), errTLSSettingsChanged.Error()) {
+		t.Fatal("expected TLS settings to be rejected, got", err)
+	}
+	if pt.mpool.InternalSettings().PoolTLSKeyFile != "" {
+		t.Fatal("TLS settings were updated")
+	}
+}
+
 // test starting and stopping a miner rapidly on the pool using a bad address:
 // good for catching race conditions
 func TestStratumStartStopMiningBadAddress(t *testing.T) {
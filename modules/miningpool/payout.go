@@ -0,0 +1,327 @@
+package pool
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/EvilRedHorse/pubaccess-node/build"
+	"github.com/EvilRedHorse/pubaccess-node/config"
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+)
+
+var (
+	// PayoutFrequency is how often the pool settles matured blocks and pays
+	// the balances of the clients if there was no consensus change in the
+	// meantime.
+	PayoutFrequency = build.Select(build.Var{
+		Standard: 10 * time.Minute,
+		Dev:      time.Minute,
+		Testing:  time.Second,
+	}).(time.Duration)
+
+	// defaultPPLNSWindow is the PPLNS window that is used if none is
+	// configured. The last shares worth twice the difficulty of a block are
+	// paid.
+	defaultPPLNSWindow = 2.0
+
+	// errUnknownPayoutScheme is returned if the configured payout scheme isn't
+	// supported by the pool.
+	errUnknownPayoutScheme = errors.New("unknown payout scheme")
+
+	// errPayoutsHalted is returned if the pool stopped paying because sent
+	// payouts couldn't be recorded.
+	errPayoutsHalted = errors.New("payouts are halted because a payout couldn't be recorded")
+)
+
+// blockReward returns the part of the miner payouts of a block that is paid
+// to the pool wallet.
+func blockReward(b types.Block, poolWallet types.UnlockHash) types.Currency {
+	reward := types.ZeroCurrency
+	for _, payout := range b.MinerPayouts {
+		if payout.UnlockHash == poolWallet {
+			reward = reward.Add(payout.Value)
+		}
+	}
+	return reward
+}
+
+// splitReward splits a reward between the accounts proportionally to their
+// weights. The amounts are rounded down, which means that the sum of the
+// amounts never exceeds the reward. The earnings are sorted by account.
+func splitReward(reward types.Currency, weights map[int64]float64) []earningRecord {
+	total := new(big.Rat)
+	userIDs := make([]int64, 0, len(weights))
+	for userID, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+		total.Add(total, new(big.Rat).SetFloat64(weight))
+		userIDs = append(userIDs, userID)
+	}
+	if total.Sign() == 0 {
+		return nil
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+
+	earnings := make([]earningRecord, 0, len(userIDs))
+	for _, userID := range userIDs {
+		ratio := new(big.Rat).SetFloat64(weights[userID])
+		ratio.Quo(ratio, total)
+		amount := reward.MulRat(ratio)
+		if amount.IsZero() {
+			continue
+		}
+		earnings = append(earnings, earningRecord{
+			UserID: userID,
+			Amount: amount,
+		})
+	}
+	return earnings
+}
+
+// pplnsWeights returns the share difficulty per account of the last N shares
+// up to the provided height. N is the window times the difficulty of the
+// block. The share that exceeds the window only counts with the part that
+// fits in.
+func (p *Pool) pplnsWeights(height types.BlockHeight, window float64) (map[int64]float64, error) {
+	weights := make(map[int64]float64)
+	remaining := window
+	err := p.store.IterateShares(int64(height), func(sr shareRecord) bool {
+		difficulty := sr.ShareDifficulty
+		if difficulty > remaining {
+			difficulty = remaining
+		}
+		weights[sr.UserID] += difficulty
+		remaining -= difficulty
+		return remaining > 0
+	})
+	return weights, err
+}
+
+// propWeights returns the share difficulty per account of the shares of the
+// round that ended with the block at the provided height. The round started
+// after the block at roundStart.
+func (p *Pool) propWeights(height, roundStart types.BlockHeight) (map[int64]float64, error) {
+	weights := make(map[int64]float64)
+	err := p.store.IterateShares(int64(height), func(sr shareRecord) bool {
+		if sr.Height <= int64(roundStart) {
+			return false
+		}
+		weights[sr.UserID] += sr.ShareDifficulty
+		return true
+	})
+	return weights, err
+}
+
+// managedSettleBlocks credits the rewards of the found blocks that matured to
+// the accounts of the clients. Blocks that are no longer part of the longest
+// chain once they would have matured are marked as orphans.
+func (p *Pool) managedSettleBlocks() error {
+	settings := p.InternalSettings()
+	blocks, err := p.store.Blocks()
+	if err != nil {
+		return err
+	}
+
+	height := p.cs.Height()
+	var roundStart types.BlockHeight
+	for _, br := range blocks {
+		if br.Category == blockCategoryGenerate {
+			roundStart = types.BlockHeight(br.Height)
+		}
+		if br.Category != blockCategoryNew {
+			continue
+		}
+		// The blocks are ordered by height, so all following blocks are
+		// immature as well.
+		if height < types.BlockHeight(br.Height)+types.MaturityDelay {
+			return nil
+		}
+
+		var id types.BlockID
+		err = id.LoadString(br.BlockHash)
+		if err != nil {
+			return err
+		}
+		b, exists := p.cs.BlockAtHeight(types.BlockHeight(br.Height))
+		if !exists || b.ID() != id {
+			p.log.Printf("Block %v at height %v was orphaned\n", br.BlockHash, br.Height)
+			err = p.store.SettleBlock(br.ID, blockCategoryOrphan, nil)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// The operator keeps the fee by not paying it out.
+		reward := blockReward(b, settings.PoolWallet)
+		reward = reward.MulFloat(1 - settings.PoolOperatorPercentage/100)
+
+		var weights map[int64]float64
+		switch settings.PoolPayoutScheme {
+		case config.PayoutSchemePPLNS:
+			window := settings.PoolPPLNSWindow
+			if window <= 0 {
+				window = defaultPPLNSWindow
+			}
+			weights, err = p.pplnsWeights(types.BlockHeight(br.Height), window*float64(br.Difficulty))
+		case config.PayoutSchemePROP:
+			weights, err = p.propWeights(types.BlockHeight(br.Height), roundStart)
+		default:
+			return errors.New(errUnknownPayoutScheme.Error() + ": " + settings.PoolPayoutScheme)
+		}
+		if err != nil {
+			return err
+		}
+
+		earnings := splitReward(reward, weights)
+		now := time.Now().Unix()
+		for i := range earnings {
+			earnings[i].BlockID = br.ID
+			earnings[i].Time = now
+		}
+		err = p.store.SettleBlock(br.ID, blockCategoryGenerate, earnings)
+		if err != nil {
+			return err
+		}
+		p.log.Printf("Credited %v of block %v at height %v to %v clients\n", reward.HumanString(), br.BlockHash, br.Height, len(earnings))
+		roundStart = types.BlockHeight(br.Height)
+	}
+	return nil
+}
+
+// managedSendPayouts pays the balances that reached the minimum payout in a
+// single transaction.
+func (p *Pool) managedSendPayouts() error {
+	p.mu.RLock()
+	halted := p.payoutsHalted
+	p.mu.RUnlock()
+	if halted {
+		return errPayoutsHalted
+	}
+
+	settings := p.InternalSettings()
+	balances, err := p.store.Balances()
+	if err != nil {
+		return err
+	}
+
+	var outputs []types.SiacoinOutput
+	var payouts []payoutRecord
+	for _, br := range balances {
+		if br.Balance.Cmp(settings.PoolMinimumPayout) < 0 {
+			continue
+		}
+		// The name of an account is the wallet address of the client.
+		var address types.UnlockHash
+		err = address.LoadString(br.Name)
+		if err != nil {
+			p.log.Printf("Not paying client %v, its name isn't an address: %v\n", br.Name, err)
+			continue
+		}
+		outputs = append(outputs, types.SiacoinOutput{
+			Value:      br.Balance,
+			UnlockHash: address,
+		})
+		payouts = append(payouts, payoutRecord{
+			UserID: br.UserID,
+			Name:   br.Name,
+			Amount: br.Balance,
+		})
+	}
+	if len(outputs) == 0 {
+		return nil
+	}
+
+	txns, err := p.wallet.SendSiacoinsMulti(outputs)
+	if err != nil {
+		return err
+	}
+	txid := txns[len(txns)-1].ID().String()
+	now := time.Now().Unix()
+	for i := range payouts {
+		payouts[i].TxID = txid
+		payouts[i].Time = now
+	}
+	err = p.store.AddPayouts(payouts)
+	if err != nil {
+		// The balances weren't reduced, paying again would pay them twice.
+		p.mu.Lock()
+		p.payoutsHalted = true
+		p.mu.Unlock()
+		p.log.Severe("ERROR: payouts were sent in transaction", txid, "but couldn't be recorded, payouts are halted:", err)
+		return err
+	}
+	p.log.Printf("Paid %v clients in transaction %v\n", len(payouts), txid)
+	return nil
+}
+
+// threadedMonitorPayouts settles matured blocks and pays the clients after
+// consensus changes, or periodically if there are none.
+func (p *Pool) threadedMonitorPayouts() {
+	if err := p.tg.Add(); err != nil {
+		return
+	}
+	defer p.tg.Done()
+
+	for {
+		select {
+		case <-p.payoutChan:
+		case <-time.After(PayoutFrequency):
+		case <-p.tg.StopChan():
+			return
+		}
+		if p.InternalSettings().PoolPayoutScheme == config.PayoutSchemeNone || p.wallet == nil || !p.cs.Synced() {
+			continue
+		}
+		err := p.managedSettleBlocks()
+		if err != nil {
+			p.log.Println("ERROR: unable to settle found blocks:", err)
+			continue
+		}
+		err = p.managedSendPayouts()
+		if err != nil {
+			p.log.Println("ERROR: unable to send payouts:", err)
+		}
+	}
+}
+
+// Payouts returns the payments the pool made to a client, most recent first.
+// The payments to all clients are returned if the client name is empty.
+func (p *Pool) Payouts(clientName string) ([]modules.PoolPayout, error) {
+	if err := p.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer p.tg.Done()
+
+	var userID int64
+	if clientName != "" {
+		cr, err := p.store.FindClient(clientName)
+		if err != nil {
+			return nil, err
+		}
+		userID = cr.ID
+	}
+	records, err := p.store.Payouts(userID)
+	if err != nil {
+		return nil, err
+	}
+	payouts := make([]modules.PoolPayout, 0, len(records))
+	for _, pr := range records {
+		payout := modules.PoolPayout{
+			ClientName: pr.Name,
+			Amount:     pr.Amount,
+			Time:       time.Unix(pr.Time, 0),
+		}
+		err = (*crypto.Hash)(&payout.TransactionID).LoadString(pr.TxID)
+		if err != nil {
+			return nil, err
+		}
+		payouts = append(payouts, payout)
+	}
+	return payouts, nil
+}
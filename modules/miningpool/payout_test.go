@@ -0,0 +1,259 @@
+package pool
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/EvilRedHorse/pubaccess-node/build"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/persist"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+)
+
+// newTestPayoutPool returns a pool that only has a bolt store, which is
+// enough to compute payouts.
+func newTestPayoutPool(t *testing.T) *Pool {
+	dir := build.TempDir(modules.PoolDir, t.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	db, err := persist.OpenDatabase(boltMetadata, filepath.Join(dir, dbFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newBoltPoolStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Pool{store: s}
+}
+
+// TestSplitReward checks that rewards are split proportionally and never
+// exceed the reward.
+func TestSplitReward(t *testing.T) {
+	reward := types.NewCurrency64(1000)
+	earnings := splitReward(reward, map[int64]float64{1: 1, 2: 3, 3: 0})
+	if len(earnings) != 2 {
+		t.Fatal("expected 2 earnings, got", len(earnings))
+	}
+	if earnings[0].UserID != 1 || !earnings[0].Amount.Equals64(250) {
+		t.Fatal("wrong earning", earnings[0])
+	}
+	if earnings[1].UserID != 2 || !earnings[1].Amount.Equals64(750) {
+		t.Fatal("wrong earning", earnings[1])
+	}
+
+	// Amounts are rounded down.
+	earnings = splitReward(types.NewCurrency64(100), map[int64]float64{1: 1, 2: 1, 3: 1})
+	total := types.ZeroCurrency
+	for _, e := range earnings {
+		total = total.Add(e.Amount)
+	}
+	if total.Cmp64(100) > 0 {
+		t.Fatal("earnings exceed the reward:", total)
+	}
+
+	// Nothing is paid without shares.
+	if earnings := splitReward(reward, nil); len(earnings) != 0 {
+		t.Fatal("expected no earnings, got", earnings)
+	}
+}
+
+// TestPayoutWeights checks the shares that are paid by the PPLNS and PROP
+// schemes.
+func TestPayoutWeights(t *testing.T) {
+	p := newTestPayoutPool(t)
+	defer p.store.Close()
+
+	// Client 1 mined heights 1 to 5, client 2 mined heights 4 to 10. Each
+	// share has a difficulty of 10.
+	var shares []Share
+	for h := int64(1); h <= 10; h++ {
+		userID := int64(2)
+		if h <= 5 {
+			userID = 1
+		}
+		shares = append(shares, Share{userid: userID, height: h, valid: true, shareDifficulty: 10, time: time.Now()})
+		if h == 4 || h == 5 {
+			shares = append(shares, Share{userid: 2, height: h, valid: true, shareDifficulty: 10, time: time.Now()})
+		}
+	}
+	// Invalid shares are never paid.
+	shares = append(shares, Share{userid: 3, height: 5, valid: false, shareDifficulty: 1000, time: time.Now()})
+	if err := p.store.AddShares(shares); err != nil {
+		t.Fatal(err)
+	}
+
+	// The PPLNS window of a block at height 5 covers the last 35 difficulty:
+	// two shares of client 2, one and a half shares of client 1.
+	weights, err := p.pplnsWeights(5, 35)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(weights) != 2 || weights[1] != 15 || weights[2] != 20 {
+		t.Fatal("wrong PPLNS weights", weights)
+	}
+
+	// The PROP round from height 3 to 5 has three shares of client 1 and two
+	// shares of client 2.
+	weights, err = p.propWeights(5, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(weights) != 2 || weights[1] != 30 || weights[2] != 20 {
+		t.Fatal("wrong PROP weights", weights)
+	}
+}
+
+// TestBoltPoolStorePayouts checks that the earnings of settled blocks and the
+// payouts add up to the balances of the bolt store.
+func TestBoltPoolStorePayouts(t *testing.T) {
+	p := newTestPayoutPool(t)
+	s := p.store
+	defer s.Close()
+
+	alice, err := s.AddClient("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := s.AddClient("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range []uint64{2, 1} {
+		if err := s.AddBlock(blockRecord{Height: h, Category: blockCategoryNew}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blocks, err := s.Blocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 2 || blocks[0].Height != 1 || blocks[1].Height != 2 {
+		t.Fatal("blocks should be ordered by height", blocks)
+	}
+
+	// Settle one block and orphan the other one.
+	err = s.SettleBlock(blocks[0].ID, blockCategoryGenerate, []earningRecord{
+		{UserID: alice, BlockID: blocks[0].ID, Amount: types.NewCurrency64(30)},
+		{UserID: bob, BlockID: blocks[0].ID, Amount: types.NewCurrency64(70)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SettleBlock(blocks[1].ID, blockCategoryOrphan, nil); err != nil {
+		t.Fatal(err)
+	}
+	blocks, err = s.Blocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocks[0].Category != blockCategoryGenerate || blocks[1].Category != blockCategoryOrphan {
+		t.Fatal("blocks weren't settled", blocks)
+	}
+
+	// Pay bob, who should have no balance afterwards.
+	err = s.AddPayouts([]payoutRecord{{UserID: bob, Name: "bob", Amount: types.NewCurrency64(70), TxID: "tx"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddPayouts([]payoutRecord{{UserID: alice, Amount: types.NewCurrency64(31)}}); err != errInsufficientBalance {
+		t.Fatal("expected errInsufficientBalance, got", err)
+	}
+	balances, err := s.Balances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(balances) != 1 || balances[0].Name != "alice" || !balances[0].Balance.Equals64(30) {
+		t.Fatal("wrong balances", balances)
+	}
+	payouts, err := s.Payouts(bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payouts) != 1 || payouts[0].TxID != "tx" || !payouts[0].Amount.Equals64(70) {
+		t.Fatal("wrong payouts", payouts)
+	}
+	if payouts, err := s.Payouts(alice); err != nil || len(payouts) != 0 {
+		t.Fatal("alice shouldn't have payouts", payouts, err)
+	}
+}
+
+// TestMySQLPoolStoreYiimpPayouts checks that the payouts of the pool don't
+// lose precision in a yiimp database, which already contains earnings and
+// payouts tables with amounts stored as doubles.
+func TestMySQLPoolStoreYiimpPayouts(t *testing.T) {
+	if !build.POOL {
+		t.SkipNow()
+	}
+	err := createPoolDatabase(fmt.Sprintf("%s:%s@tcp(%s:%s)/", tdbUser, tdbPass, tdbAddress, tdbPort), tdbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connection := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", tdbUser, tdbPass, tdbAddress, tdbPort, tdbName)
+	db, err := sql.Open("mysql", connection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		"CREATE TABLE earnings (id int(11) unsigned NOT NULL AUTO_INCREMENT, userid int(11) DEFAULT NULL, coinid int(11) DEFAULT NULL, blockid int(11) DEFAULT NULL, create_time int(11) DEFAULT NULL, amount double DEFAULT NULL, PRIMARY KEY (id)) ENGINE=InnoDB DEFAULT CHARSET=utf8",
+		"CREATE TABLE payouts (id int(11) unsigned NOT NULL AUTO_INCREMENT, account_id int(11) DEFAULT NULL, time int(11) DEFAULT NULL, amount double DEFAULT NULL, tx varchar(128) DEFAULT NULL, idcoin int(11) DEFAULT NULL, PRIMARY KEY (id)) ENGINE=InnoDB DEFAULT CHARSET=utf8",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	log, err := persist.NewLogger(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newMySQLPoolStore(connection, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	alice, err := s.AddClient("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddBlock(blockRecord{Height: 1, Category: blockCategoryNew}); err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := s.Blocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An amount that can't be represented by a double.
+	reward := types.SiacoinPrecision.Mul64(300e3).Add(types.NewCurrency64(1))
+	err = s.SettleBlock(blocks[0].ID, blockCategoryGenerate, []earningRecord{
+		{UserID: alice, BlockID: blocks[0].ID, Amount: reward},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.AddPayouts([]payoutRecord{{UserID: alice, Name: "alice", Amount: reward.Sub(types.NewCurrency64(1)), TxID: "tx"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	balances, err := s.Balances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(balances) != 1 || !balances[0].Balance.Equals64(1) {
+		t.Fatal("wrong balances", balances)
+	}
+
+	// The yiimp tables are left untouched.
+	var rows int
+	if err := db.QueryRow("SELECT COUNT(*) FROM earnings").Scan(&rows); err != nil || rows != 0 {
+		t.Fatal("yiimp earnings table was modified", rows, err)
+	}
+}
@@ -182,8 +182,21 @@ func (mp *Pool) setPoolSettings(initConfig config.MiningPoolConfig) error {
 		PoolNetworkPort:  initConfig.PoolNetworkPort,
 		PoolName:         initConfig.PoolName,
 		PoolID:           initConfig.PoolID,
+		PoolDBDriver:     initConfig.PoolDBDriver,
 		PoolDBConnection: initConfig.PoolDBConnection,
 		PoolWallet:       poolWallet,
+
+		PoolTLSNetworkPort: initConfig.PoolTLSNetworkPort,
+		PoolTLSCertFile:    initConfig.PoolTLSCertFile,
+		PoolTLSKeyFile:     initConfig.PoolTLSKeyFile,
+
+		PoolPayoutScheme:       initConfig.PoolPayoutScheme,
+		PoolPPLNSWindow:        initConfig.PoolPPLNSWindow,
+		PoolOperatorPercentage: initConfig.PoolOperatorPercentage,
+		PoolMinimumPayout:      types.SiacoinPrecision.MulFloat(initConfig.PoolMinimumPayout),
+	}
+	if internalSettings.PoolPayoutScheme == "" {
+		internalSettings.PoolPayoutScheme = config.PayoutSchemeNone
 	}
 	mp.persist.SetSettings(internalSettings)
 	mp.newSourceBlock()
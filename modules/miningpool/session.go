@@ -45,6 +45,9 @@ type Session struct {
 	CurrentWorker    *Worker
 	CurrentShift     *Shift
 	ExtraNonce1      uint32
+	// extraNonceSubscribed is set if the miner accepts changes of its
+	// extranonce through mining.set_extranonce.
+	extraNonceSubscribed bool
 	// vardiff
 	currentDifficulty     float64
 	highestDifficulty     float64
@@ -66,7 +69,7 @@ func newSession(p *Pool, ip string) (*Session, error) {
 	id := p.newStratumID()
 	s := &Session{
 		SessionID:            id(),
-		ExtraNonce1:          uint32(id() & 0xffffffff),
+		ExtraNonce1:          extraNonce1(p.InternalSettings().PoolID, id()),
 		currentDifficulty:    initialDifficulty,
 		highestDifficulty:    initialDifficulty,
 		lastVardiffRetarget:  time.Now(),
@@ -172,6 +175,45 @@ func (s *Session) printNonce() string {
 	return hex.EncodeToString(ex1)
 }
 
+// extraNonce1 returns the extranonce1 of a session. The most significant byte
+// is taken from the pool id, which keeps the extranonce space of pool
+// instances apart when miners are moved between them.
+func extraNonce1(poolID, sessionID uint64) uint32 {
+	return uint32(poolID&0xff)<<24 | uint32(sessionID&0xffffff)
+}
+
+// inExtraNonceSpace returns whether the extranonce1 of the session belongs to
+// the extranonce space of the provided pool id.
+func (s *Session) inExtraNonceSpace(poolID uint64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return extraNonce1(poolID, uint64(s.ExtraNonce1)) == s.ExtraNonce1
+}
+
+// resetExtraNonce1 moves the extranonce1 of the session into the extranonce
+// space of the provided pool id.
+func (s *Session) resetExtraNonce1(poolID uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ExtraNonce1 = extraNonce1(poolID, uint64(s.ExtraNonce1))
+}
+
+// ExtraNonceSubscribed returns whether the miner accepts changes of its
+// extranonce.
+func (s *Session) ExtraNonceSubscribed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.extraNonceSubscribed
+}
+
+// SetExtraNonceSubscribed sets whether the miner accepts changes of its
+// extranonce.
+func (s *Session) SetExtraNonceSubscribed(subscribed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.extraNonceSubscribed = subscribed
+}
+
 // SetLastShareTimestamp add a new time stamp
 func (s *Session) SetLastShareTimestamp(t time.Time) {
 	s.mu.Lock()
@@ -0,0 +1,167 @@
+package pool
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/EvilRedHorse/pubaccess-node/config"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+)
+
+const (
+	// Categories of found blocks. A block is new until its reward matured or
+	// it was orphaned. The reward of a generated block has been credited to
+	// the accounts.
+	blockCategoryNew      = "new"
+	blockCategoryGenerate = "generate"
+	blockCategoryOrphan   = "orphan"
+)
+
+var (
+	// errUnknownDriver is returned if the configured database driver isn't
+	// supported by the pool.
+	errUnknownDriver = errors.New("unknown database driver")
+)
+
+type (
+	// poolStore is the storage backend of the pool. It follows the yiimp
+	// schema, which means that accounts, workers, found blocks and shares are
+	// stored.
+	poolStore interface {
+		// AddClient adds an account and returns its id.
+		AddClient(name string) (int64, error)
+
+		// FindClient returns the account with the provided name. It returns
+		// ErrNoUsernameInDatabase if there is no such account.
+		FindClient(name string) (clientRecord, error)
+
+		// AddWorker adds a worker and returns its id.
+		AddWorker(wr workerRecord) (int64, error)
+
+		// DeleteWorker removes a worker.
+		DeleteWorker(id int64) error
+
+		// DeleteWorkers removes all workers of a pool.
+		DeleteWorkers(poolID uint64) error
+
+		// AddBlock adds a block that was found by the pool.
+		AddBlock(br blockRecord) error
+
+		// Blocks returns the found blocks ordered by height.
+		Blocks() ([]blockRecord, error)
+
+		// SettleBlock sets the category of a found block and credits the
+		// earnings of the block to the accounts.
+		SettleBlock(blockID int64, category string, earnings []earningRecord) error
+
+		// Earnings returns the earnings that were credited for a found block.
+		Earnings(blockID int64) ([]earningRecord, error)
+
+		// AddShares adds the shares of a shift.
+		AddShares(shares []Share) error
+
+		// IterateShares calls fn for the valid shares up to the provided
+		// height, starting with the most recent share, until fn returns false.
+		IterateShares(maxHeight int64, fn func(shareRecord) bool) error
+
+		// Balances returns the accounts that have a positive balance.
+		Balances() ([]balanceRecord, error)
+
+		// AddPayouts records payouts and deducts them from the balances of
+		// the accounts.
+		AddPayouts(payouts []payoutRecord) error
+
+		// Payouts returns the payouts of an account, most recent first. The
+		// payouts of all accounts are returned if userID is 0.
+		Payouts(userID int64) ([]payoutRecord, error)
+
+		// Close closes the store.
+		Close() error
+	}
+
+	// clientRecord is an account as it is stored in the database.
+	clientRecord struct {
+		ID     int64
+		Name   string
+		CoinID int
+	}
+
+	// workerRecord is a worker as it is stored in the database.
+	workerRecord struct {
+		UserID  int64
+		Name    string
+		Worker  string
+		Algo    string
+		Time    int64
+		PoolID  uint64
+		Version string
+		IP      string
+	}
+
+	// blockRecord is a found block as it is stored in the database.
+	blockRecord struct {
+		ID         int64
+		Height     uint64
+		BlockHash  string
+		CoinID     int
+		UserID     int64
+		WorkerID   int64
+		Category   string
+		Difficulty uint64
+		Time       int64
+		Algo       string
+	}
+
+	// shareRecord is the part of a stored share that is needed to compute
+	// payouts.
+	shareRecord struct {
+		UserID          int64
+		Height          int64
+		ShareDifficulty float64
+	}
+
+	// earningRecord is the part of the reward of a block that is credited to
+	// an account. The name of the account is only set by queries.
+	earningRecord struct {
+		UserID  int64
+		Name    string
+		BlockID int64
+		Amount  types.Currency
+		Time    int64
+	}
+
+	// balanceRecord is the unpaid balance of an account.
+	balanceRecord struct {
+		UserID  int64
+		Name    string
+		Balance types.Currency
+	}
+
+	// payoutRecord is a payment to an account.
+	payoutRecord struct {
+		ID     int64
+		UserID int64
+		Name   string
+		Amount types.Currency
+		TxID   string
+		Time   int64
+	}
+)
+
+// newPoolStore opens the store that is selected by the settings of the pool.
+// MySQL is used if no driver is configured.
+func (p *Pool) newPoolStore() (poolStore, error) {
+	settings := p.InternalSettings()
+	switch settings.PoolDBDriver {
+	case config.DBDriverMySQL, "":
+		return newMySQLPoolStore(settings.PoolDBConnection, p.log)
+	case config.DBDriverBolt:
+		db, err := p.dependencies.openDatabase(boltMetadata, filepath.Join(p.persistDir, dbFilename))
+		if err != nil {
+			return nil, err
+		}
+		return newBoltPoolStore(db)
+	default:
+		return nil, errors.New(errUnknownDriver.Error() + ": " + settings.PoolDBDriver)
+	}
+}
@@ -0,0 +1,440 @@
+package pool
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/EvilRedHorse/pubaccess-node/persist"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+)
+
+var (
+	// boltMetadata is the metadata of the bolt store.
+	boltMetadata = persist.Metadata{
+		Header:  "ScPrime Pool Database",
+		Version: "1.0",
+	}
+
+	// Buckets of the bolt store.
+	bucketAccounts = []byte("Accounts")
+	bucketBalances = []byte("Balances")
+	bucketBlocks   = []byte("Blocks")
+	bucketEarnings = []byte("Earnings")
+	bucketPayouts  = []byte("Payouts")
+	bucketShares   = []byte("Shares")
+	bucketWorkers  = []byte("Workers")
+
+	// errUnknownBlock is returned if a block that should be settled isn't in
+	// the store.
+	errUnknownBlock = errors.New("block is not in the store")
+
+	// errInsufficientBalance is returned if a payout exceeds the balance of
+	// an account.
+	errInsufficientBalance = errors.New("payout exceeds the balance of the account")
+
+	// boltMigrations are the schema migrations of the bolt store.
+	boltMigrations = []persist.BoltMigration{
+		{
+			Version: 1,
+			Migrate: func(tx *bolt.Tx) error {
+				for _, b := range [][]byte{bucketAccounts, bucketBlocks, bucketShares, bucketWorkers} {
+					if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Version: 2,
+			Migrate: func(tx *bolt.Tx) error {
+				for _, b := range [][]byte{bucketBalances, bucketEarnings, bucketPayouts} {
+					if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+	}
+)
+
+type (
+	// boltPoolStore stores the pool data in an embedded bolt database. It is
+	// meant for small pools that don't want to run a separate database.
+	boltPoolStore struct {
+		db *persist.BoltDatabase
+	}
+
+	// boltShare is a share as it is stored in the bolt store.
+	boltShare struct {
+		UserID          int64
+		WorkerID        int64
+		Height          int64
+		Valid           bool
+		Difficulty      float64
+		Reward          float64
+		BlockDifficulty uint64
+		ShareReward     float64
+		ShareDifficulty float64
+		Time            int64
+	}
+)
+
+// newBoltPoolStore migrates the schema of a bolt database and wraps it in a
+// store.
+func newBoltPoolStore(db *persist.BoltDatabase) (*boltPoolStore, error) {
+	err := db.Migrate(boltMigrations)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltPoolStore{db: db}, nil
+}
+
+// boltID encodes an id as a bolt key. Big endian keeps the keys ordered.
+func boltID(id int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(id))
+	return b
+}
+
+// boltInsert adds a value to a bucket under the next id of the bucket. Values
+// are encoded as JSON since the records contain floats.
+func boltInsert(tx *bolt.Tx, bucket []byte, v interface{}) (int64, error) {
+	b := tx.Bucket(bucket)
+	seq, err := b.NextSequence()
+	if err != nil {
+		return 0, err
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	id := int64(seq)
+	return id, b.Put(boltID(id), value)
+}
+
+// AddClient implements the poolStore interface.
+func (s *boltPoolStore) AddClient(name string) (id int64, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAccounts)
+		if b.Get([]byte(name)) != nil {
+			return ErrCreateClient
+		}
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		id = int64(seq)
+		value, err := json.Marshal(clientRecord{
+			ID:     id,
+			Name:   name,
+			CoinID: SiaCoinID,
+		})
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(name), value)
+	})
+	return
+}
+
+// FindClient implements the poolStore interface.
+func (s *boltPoolStore) FindClient(name string) (cr clientRecord, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAccounts).Get([]byte(name))
+		if b == nil {
+			return ErrNoUsernameInDatabase
+		}
+		return json.Unmarshal(b, &cr)
+	})
+	return
+}
+
+// AddWorker implements the poolStore interface.
+func (s *boltPoolStore) AddWorker(wr workerRecord) (id int64, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		id, err = boltInsert(tx, bucketWorkers, wr)
+		return err
+	})
+	return
+}
+
+// DeleteWorker implements the poolStore interface.
+func (s *boltPoolStore) DeleteWorker(id int64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketWorkers).Delete(boltID(id))
+	})
+}
+
+// DeleteWorkers implements the poolStore interface.
+func (s *boltPoolStore) DeleteWorkers(poolID uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketWorkers)
+		var ids [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var wr workerRecord
+			if err := json.Unmarshal(v, &wr); err != nil {
+				return err
+			}
+			if wr.PoolID == poolID {
+				ids = append(ids, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// Keys can't be deleted while iterating over the bucket.
+		for _, id := range ids {
+			if err := b.Delete(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AddBlock implements the poolStore interface.
+func (s *boltPoolStore) AddBlock(br blockRecord) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		_, err := boltInsert(tx, bucketBlocks, br)
+		return err
+	})
+}
+
+// Blocks implements the poolStore interface.
+func (s *boltPoolStore) Blocks() (blocks []blockRecord, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketBlocks).ForEach(func(k, v []byte) error {
+			var br blockRecord
+			if err := json.Unmarshal(v, &br); err != nil {
+				return err
+			}
+			br.ID = int64(binary.BigEndian.Uint64(k))
+			blocks = append(blocks, br)
+			return nil
+		})
+	})
+	sort.SliceStable(blocks, func(i, j int) bool {
+		return blocks[i].Height < blocks[j].Height
+	})
+	return
+}
+
+// SettleBlock implements the poolStore interface.
+func (s *boltPoolStore) SettleBlock(blockID int64, category string, earnings []earningRecord) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketBlocks)
+		v := b.Get(boltID(blockID))
+		if v == nil {
+			return errUnknownBlock
+		}
+		var br blockRecord
+		if err := json.Unmarshal(v, &br); err != nil {
+			return err
+		}
+		br.Category = category
+		value, err := json.Marshal(br)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(boltID(blockID), value); err != nil {
+			return err
+		}
+
+		for _, e := range earnings {
+			if _, err := boltInsert(tx, bucketEarnings, e); err != nil {
+				return err
+			}
+			balance, err := boltBalance(tx, e.UserID)
+			if err != nil {
+				return err
+			}
+			if err := boltPutBalance(tx, e.UserID, balance.Add(e.Amount)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Earnings implements the poolStore interface.
+func (s *boltPoolStore) Earnings(blockID int64) (earnings []earningRecord, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		names, err := boltAccountNames(tx)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(bucketEarnings).ForEach(func(k, v []byte) error {
+			var e earningRecord
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			if e.BlockID != blockID {
+				return nil
+			}
+			e.Name = names[e.UserID]
+			earnings = append(earnings, e)
+			return nil
+		})
+	})
+	return
+}
+
+// boltAccountNames returns the names of the accounts by id. The accounts are
+// keyed by name, so the bucket has to be scanned.
+func boltAccountNames(tx *bolt.Tx) (map[int64]string, error) {
+	names := make(map[int64]string)
+	err := tx.Bucket(bucketAccounts).ForEach(func(k, v []byte) error {
+		var cr clientRecord
+		if err := json.Unmarshal(v, &cr); err != nil {
+			return err
+		}
+		names[cr.ID] = cr.Name
+		return nil
+	})
+	return names, err
+}
+
+// boltBalance returns the balance of an account.
+func boltBalance(tx *bolt.Tx, userID int64) (balance types.Currency, err error) {
+	v := tx.Bucket(bucketBalances).Get(boltID(userID))
+	if v == nil {
+		return types.ZeroCurrency, nil
+	}
+	err = json.Unmarshal(v, &balance)
+	return
+}
+
+// boltPutBalance sets the balance of an account.
+func boltPutBalance(tx *bolt.Tx, userID int64, balance types.Currency) error {
+	value, err := json.Marshal(balance)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucketBalances).Put(boltID(userID), value)
+}
+
+// AddShares implements the poolStore interface.
+func (s *boltPoolStore) AddShares(shares []Share) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, share := range shares {
+			_, err := boltInsert(tx, bucketShares, boltShare{
+				UserID:          share.userid,
+				WorkerID:        share.workerid,
+				Height:          share.height,
+				Valid:           share.valid,
+				Difficulty:      share.difficulty,
+				Reward:          share.reward,
+				BlockDifficulty: share.blockDifficulty,
+				ShareReward:     share.shareReward,
+				ShareDifficulty: share.shareDifficulty,
+				Time:            share.time.Unix(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// IterateShares implements the poolStore interface.
+func (s *boltPoolStore) IterateShares(maxHeight int64, fn func(shareRecord) bool) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketShares).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var share boltShare
+			if err := json.Unmarshal(v, &share); err != nil {
+				return err
+			}
+			if !share.Valid || share.Height > maxHeight {
+				continue
+			}
+			if !fn(shareRecord{
+				UserID:          share.UserID,
+				Height:          share.Height,
+				ShareDifficulty: share.ShareDifficulty,
+			}) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// Balances implements the poolStore interface.
+func (s *boltPoolStore) Balances() (balances []balanceRecord, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		names, err := boltAccountNames(tx)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(bucketBalances).ForEach(func(k, v []byte) error {
+			br := balanceRecord{UserID: int64(binary.BigEndian.Uint64(k))}
+			if err := json.Unmarshal(v, &br.Balance); err != nil {
+				return err
+			}
+			if br.Balance.IsZero() {
+				return nil
+			}
+			br.Name = names[br.UserID]
+			balances = append(balances, br)
+			return nil
+		})
+	})
+	return
+}
+
+// AddPayouts implements the poolStore interface.
+func (s *boltPoolStore) AddPayouts(payouts []payoutRecord) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, pr := range payouts {
+			balance, err := boltBalance(tx, pr.UserID)
+			if err != nil {
+				return err
+			}
+			if balance.Cmp(pr.Amount) < 0 {
+				return errInsufficientBalance
+			}
+			if err := boltPutBalance(tx, pr.UserID, balance.Sub(pr.Amount)); err != nil {
+				return err
+			}
+			if _, err := boltInsert(tx, bucketPayouts, pr); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Payouts implements the poolStore interface.
+func (s *boltPoolStore) Payouts(userID int64) (payouts []payoutRecord, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketPayouts).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var pr payoutRecord
+			if err := json.Unmarshal(v, &pr); err != nil {
+				return err
+			}
+			if userID != 0 && pr.UserID != userID {
+				continue
+			}
+			pr.ID = int64(binary.BigEndian.Uint64(k))
+			payouts = append(payouts, pr)
+		}
+		return nil
+	})
+	return
+}
+
+// Close implements the poolStore interface.
+func (s *boltPoolStore) Close() error {
+	return s.db.Close()
+}
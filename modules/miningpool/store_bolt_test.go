@@ -0,0 +1,90 @@
+package pool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/EvilRedHorse/pubaccess-node/build"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/persist"
+)
+
+// TestBoltPoolStore checks the accounts and workers of the bolt store.
+func TestBoltPoolStore(t *testing.T) {
+	dir := build.TempDir(modules.PoolDir, t.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	db, err := persist.OpenDatabase(boltMetadata, filepath.Join(dir, dbFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newBoltPoolStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Unknown accounts aren't found.
+	if _, err := s.FindClient("alice"); err != ErrNoUsernameInDatabase {
+		t.Fatal("expected ErrNoUsernameInDatabase, got", err)
+	}
+
+	// Add two accounts.
+	id1, err := s.AddClient("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id2, err := s.AddClient("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id1 == id2 {
+		t.Fatal("accounts should have different ids")
+	}
+	if _, err := s.AddClient("alice"); err == nil {
+		t.Fatal("adding an account twice should fail")
+	}
+	cr, err := s.FindClient("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cr.ID != id2 || cr.Name != "bob" || cr.CoinID != SiaCoinID {
+		t.Fatal("wrong account", cr)
+	}
+
+	// Add workers to two pools and delete the workers of one of them.
+	for i := 0; i < 4; i++ {
+		_, err = s.AddWorker(workerRecord{UserID: id1, Name: "alice", PoolID: uint64(i % 2)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.DeleteWorkers(0); err != nil {
+		t.Fatal(err)
+	}
+	countWorkers := func() (n int) {
+		s.db.View(func(tx *bolt.Tx) error {
+			n = tx.Bucket(bucketWorkers).Stats().KeyN
+			return nil
+		})
+		return
+	}
+	if n := countWorkers(); n != 2 {
+		t.Fatal("expected 2 workers, got", n)
+	}
+
+	// Shares and blocks are stored.
+	err = s.AddShares([]Share{{userid: id1, difficulty: 1.5, time: time.Now()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.AddBlock(blockRecord{Height: 1, UserID: id1})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
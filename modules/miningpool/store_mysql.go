@@ -0,0 +1,543 @@
+package pool
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sasha-s/go-deadlock"
+
+	"github.com/EvilRedHorse/pubaccess-node/persist"
+
+	// blank to load the sql driver for mysql
+	_ "github.com/go-sql-driver/mysql"
+)
+
+const (
+	sqlReconnectRetry = 6
+	sqlRetryDelay     = 10
+
+	// mysqlVersionTable is the table that tracks the schema version of the
+	// pool tables.
+	mysqlVersionTable = "pool_schema_version"
+)
+
+var (
+	// mysqlMigrations are the schema migrations of the pool tables. The yiimp
+	// tables are created only if they don't exist, which keeps existing yiimp
+	// databases working.
+	mysqlMigrations = []persist.SQLMigration{
+		{
+			Version: 1,
+			Statements: []string{
+				`CREATE TABLE IF NOT EXISTS accounts (
+					id int(255) NOT NULL AUTO_INCREMENT,
+					coinid int(11) DEFAULT NULL,
+					last_earning int(10) DEFAULT NULL,
+					is_locked tinyint(1) DEFAULT '0',
+					no_fees tinyint(1) DEFAULT NULL,
+					donation tinyint(3) unsigned NOT NULL DEFAULT '0',
+					logtraffic tinyint(1) DEFAULT NULL,
+					balance double DEFAULT '0',
+					username varchar(128) CHARACTER SET utf8 COLLATE utf8_bin NOT NULL,
+					coinsymbol varchar(16) DEFAULT NULL,
+					swap_time int(10) unsigned DEFAULT NULL,
+					login varchar(45) DEFAULT NULL,
+					hostaddr varchar(39) DEFAULT NULL,
+					PRIMARY KEY (id),
+					UNIQUE KEY username (username),
+					KEY coin (coinid),
+					KEY balance (balance),
+					KEY earning (last_earning)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8`,
+				`CREATE TABLE IF NOT EXISTS workers (
+					id int(11) NOT NULL AUTO_INCREMENT,
+					userid int(11) DEFAULT NULL,
+					time int(11) DEFAULT NULL,
+					pid int(11) DEFAULT NULL,
+					subscribe tinyint(1) DEFAULT NULL,
+					difficulty double DEFAULT NULL,
+					ip varchar(32) DEFAULT NULL,
+					dns varchar(1024) DEFAULT NULL,
+					name varchar(128) DEFAULT NULL,
+					nonce1 varchar(64) DEFAULT NULL,
+					version varchar(64) DEFAULT NULL,
+					password varchar(64) DEFAULT NULL,
+					worker varchar(64) DEFAULT NULL,
+					algo varchar(16) DEFAULT 'scrypt',
+					PRIMARY KEY (id),
+					KEY algo1 (algo),
+					KEY name1 (name),
+					KEY userid (userid),
+					KEY pid (pid)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8`,
+				`CREATE TABLE IF NOT EXISTS blocks (
+					id int(11) unsigned NOT NULL AUTO_INCREMENT,
+					coin_id int(11) DEFAULT NULL,
+					height int(11) unsigned DEFAULT NULL,
+					confirmations int(11) DEFAULT NULL,
+					time int(11) DEFAULT NULL,
+					userid int(11) DEFAULT NULL,
+					workerid int(11) DEFAULT NULL,
+					difficulty_user double DEFAULT NULL,
+					price double DEFAULT NULL,
+					amount double DEFAULT NULL,
+					difficulty double DEFAULT NULL,
+					category varchar(16) DEFAULT NULL,
+					algo varchar(16) DEFAULT 'scrypt',
+					blockhash varchar(128) DEFAULT NULL,
+					txhash varchar(128) DEFAULT NULL,
+					segwit tinyint(1) unsigned NOT NULL DEFAULT '0',
+					PRIMARY KEY (id),
+					KEY time (time),
+					KEY algo1 (algo),
+					KEY coin (coin_id),
+					KEY category (category),
+					KEY user1 (userid),
+					KEY height1 (height)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8`,
+				`CREATE TABLE IF NOT EXISTS shares (
+					id bigint(30) NOT NULL AUTO_INCREMENT,
+					userid int(11) DEFAULT NULL,
+					workerid int(11) DEFAULT NULL,
+					coinid int(11) DEFAULT NULL,
+					jobid int(11) DEFAULT NULL,
+					pid int(11) DEFAULT NULL,
+					time int(11) DEFAULT NULL,
+					error int(11) DEFAULT NULL,
+					valid tinyint(1) DEFAULT NULL,
+					extranonce1 tinyint(1) DEFAULT NULL,
+					difficulty double NOT NULL DEFAULT '0',
+					share_diff double NOT NULL DEFAULT '0',
+					algo varchar(16) DEFAULT 'x11',
+					reward double DEFAULT NULL,
+					block_difficulty double DEFAULT NULL,
+					status int(11) DEFAULT NULL,
+					height int(11) DEFAULT NULL,
+					share_reward double DEFAULT NULL,
+					PRIMARY KEY (id),
+					KEY time (time),
+					KEY algo1 (algo),
+					KEY valid1 (valid),
+					KEY user1 (userid),
+					KEY worker1 (workerid),
+					KEY coin1 (coinid),
+					KEY jobid (jobid)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8`,
+			},
+		},
+		{
+			// The payout tables follow the column names of yiimp, but the
+			// amounts are stored in hastings. Yiimp databases already
+			// contain earnings and payouts tables with amounts in coins, so
+			// the pool uses tables of its own.
+			Version: 2,
+			Statements: []string{
+				`CREATE TABLE IF NOT EXISTS pool_earnings (
+					id int(11) unsigned NOT NULL AUTO_INCREMENT,
+					userid int(11) DEFAULT NULL,
+					coinid int(11) DEFAULT NULL,
+					blockid int(11) DEFAULT NULL,
+					create_time int(11) DEFAULT NULL,
+					amount decimal(65,0) NOT NULL DEFAULT '0',
+					PRIMARY KEY (id),
+					KEY userid (userid),
+					KEY blockid (blockid),
+					KEY coinid (coinid)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8`,
+				`CREATE TABLE IF NOT EXISTS pool_payouts (
+					id int(11) unsigned NOT NULL AUTO_INCREMENT,
+					account_id int(11) DEFAULT NULL,
+					time int(11) DEFAULT NULL,
+					amount decimal(65,0) NOT NULL DEFAULT '0',
+					tx varchar(128) DEFAULT NULL,
+					idcoin int(11) DEFAULT NULL,
+					PRIMARY KEY (id),
+					KEY account_id (account_id),
+					KEY idcoin (idcoin)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8`,
+			},
+		},
+	}
+)
+
+// mysqlPoolStore stores the pool data in a yiimp compatible MySQL database.
+type mysqlPoolStore struct {
+	connection string
+	sqldb      *sql.DB
+	log        *persist.Logger
+	mu         deadlock.RWMutex
+}
+
+// newMySQLPoolStore connects to a MySQL database and migrates its schema.
+func newMySQLPoolStore(connection string, log *persist.Logger) (*mysqlPoolStore, error) {
+	s := &mysqlPoolStore{connection: connection, log: log}
+	err := s.reconnect()
+	if err != nil {
+		return nil, err
+	}
+	err = persist.MigrateSQL(s.db(), mysqlVersionTable, mysqlMigrations)
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("failed to migrate database: %v", err)
+	}
+	return s, nil
+}
+
+// db returns the current database connection.
+func (s *mysqlPoolStore) db() *sql.DB {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sqldb
+}
+
+// reconnect connects to the database unless the current connection is still
+// alive.
+func (s *mysqlPoolStore) reconnect() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+
+	// to prevent other goroutine reconnect
+	if s.sqldb != nil {
+		err = s.sqldb.Ping()
+		if err == nil {
+			return nil
+		}
+	}
+
+	for i := 0; i < sqlReconnectRetry; i++ {
+		s.sqldb, err = sql.Open("mysql", s.connection)
+		if err != nil {
+			s.log.Printf("Unable to open MySQL database (attempt %v of %v): %v\n", i+1, sqlReconnectRetry, err)
+			time.Sleep(sqlRetryDelay * time.Second)
+			continue
+		}
+
+		err = s.sqldb.Ping()
+		if err != nil {
+			s.log.Printf("Unable to connect to MySQL database (attempt %v of %v): %v\n", i+1, sqlReconnectRetry, err)
+			time.Sleep(sqlRetryDelay * time.Second)
+			continue
+		}
+		s.log.Println("Connected to MySQL database")
+		return nil
+	}
+
+	return fmt.Errorf("sql reconnect retry time exceeded: %d", sqlReconnectRetry)
+}
+
+// AddClient implements the poolStore interface.
+func (s *mysqlPoolStore) AddClient(name string) (int64, error) {
+	tx, err := s.db().Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rs, err := tx.Exec(`
+		INSERT INTO accounts (coinid, username, coinsymbol)
+		VALUES (?, ?, ?);
+	`, SiaCoinID, name, SiaCoinSymbol)
+	if err != nil {
+		return 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return rs.LastInsertId()
+}
+
+// FindClient implements the poolStore interface.
+func (s *mysqlPoolStore) FindClient(name string) (clientRecord, error) {
+	var cr clientRecord
+	err := s.db().QueryRow("SELECT id, username, coinid FROM accounts WHERE username = ?", name).Scan(&cr.ID, &cr.Name, &cr.CoinID)
+	if err == sql.ErrNoRows {
+		return clientRecord{}, ErrNoUsernameInDatabase
+	}
+	return cr, err
+}
+
+// AddWorker implements the poolStore interface.
+func (s *mysqlPoolStore) AddWorker(wr workerRecord) (int64, error) {
+	tx, err := s.db().Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rs, err := tx.Exec(`
+		INSERT INTO workers (userid, name, worker, algo, time, pid, version, ip)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+	`, wr.UserID, wr.Name, wr.Worker, wr.Algo, wr.Time, wr.PoolID, wr.Version, wr.IP)
+	if err != nil {
+		return 0, err
+	}
+	id, err := rs.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// DeleteWorker implements the poolStore interface.
+func (s *mysqlPoolStore) DeleteWorker(id int64) error {
+	_, err := s.db().Exec("DELETE FROM workers WHERE id = ?", id)
+	return err
+}
+
+// DeleteWorkers implements the poolStore interface.
+func (s *mysqlPoolStore) DeleteWorkers(poolID uint64) error {
+	_, err := s.db().Exec("DELETE FROM workers WHERE pid = ?", poolID)
+	return err
+}
+
+// AddBlock implements the poolStore interface.
+func (s *mysqlPoolStore) AddBlock(br blockRecord) error {
+	// TODO: maybe add difficulty_user
+	_, err := s.db().Exec(`
+		INSERT INTO blocks
+		(height, blockhash, coin_id, userid, workerid, category, difficulty, time, algo)
+		VALUES
+		(?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, br.Height, br.BlockHash, br.CoinID, br.UserID, br.WorkerID, br.Category, br.Difficulty, br.Time, br.Algo)
+	return err
+}
+
+// Blocks implements the poolStore interface.
+func (s *mysqlPoolStore) Blocks() ([]blockRecord, error) {
+	rows, err := s.db().Query(`
+		SELECT id, height, blockhash, coin_id, userid, workerid, category, difficulty, time, algo
+		FROM blocks WHERE coin_id = ? ORDER BY height, id
+	`, SiaCoinID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blocks []blockRecord
+	for rows.Next() {
+		var br blockRecord
+		var difficulty float64
+		err = rows.Scan(&br.ID, &br.Height, &br.BlockHash, &br.CoinID, &br.UserID, &br.WorkerID, &br.Category, &difficulty, &br.Time, &br.Algo)
+		if err != nil {
+			return nil, err
+		}
+		br.Difficulty = uint64(difficulty)
+		blocks = append(blocks, br)
+	}
+	return blocks, rows.Err()
+}
+
+// SettleBlock implements the poolStore interface.
+func (s *mysqlPoolStore) SettleBlock(blockID int64, category string, earnings []earningRecord) error {
+	tx, err := s.db().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE blocks SET category = ? WHERE id = ?", category, blockID)
+	if err != nil {
+		return err
+	}
+	for _, e := range earnings {
+		_, err = tx.Exec(`
+			INSERT INTO pool_earnings (userid, coinid, blockid, create_time, amount)
+			VALUES (?, ?, ?, ?, ?)
+		`, e.UserID, SiaCoinID, e.BlockID, e.Time, e.Amount.String())
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Earnings implements the poolStore interface.
+func (s *mysqlPoolStore) Earnings(blockID int64) ([]earningRecord, error) {
+	rows, err := s.db().Query(`
+		SELECT e.userid, a.username, e.blockid, CAST(e.amount AS DECIMAL(65,0)), e.create_time
+		FROM pool_earnings e JOIN accounts a ON a.id = e.userid
+		WHERE e.blockid = ? ORDER BY e.userid
+	`, blockID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var earnings []earningRecord
+	for rows.Next() {
+		var e earningRecord
+		var amount string
+		err = rows.Scan(&e.UserID, &e.Name, &e.BlockID, &amount, &e.Time)
+		if err != nil {
+			return nil, err
+		}
+		_, err = fmt.Sscan(amount, &e.Amount)
+		if err != nil {
+			return nil, err
+		}
+		earnings = append(earnings, e)
+	}
+	return earnings, rows.Err()
+}
+
+// AddShares implements the poolStore interface. If the shares can't be saved,
+// the store reconnects to the database and tries again.
+func (s *mysqlPoolStore) AddShares(shares []Share) error {
+	var buffer bytes.Buffer
+	buffer.WriteString("INSERT INTO shares(userid, workerid, coinid, valid, difficulty, time, algo, reward, block_difficulty, status, height, share_reward, share_diff) VALUES ")
+	for i, share := range shares {
+		if i != 0 {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString(fmt.Sprintf("(%d, %d, %d, %t, %f, %d, '%s', %f, %d, %d, %d, %f, %f)",
+			share.userid, share.workerid, SiaCoinID, share.valid, share.difficulty, share.time.Unix(),
+			SiaCoinAlgo, share.reward, share.blockDifficulty, 0, share.height, share.shareReward, share.shareDifficulty))
+	}
+	buffer.WriteString(";")
+
+	_, err := s.db().Exec(buffer.String())
+	if err == nil {
+		return nil
+	}
+	err = s.reconnect()
+	if err != nil {
+		return err
+	}
+	_, err = s.db().Exec(buffer.String())
+	return err
+}
+
+// IterateShares implements the poolStore interface.
+func (s *mysqlPoolStore) IterateShares(maxHeight int64, fn func(shareRecord) bool) error {
+	rows, err := s.db().Query(`
+		SELECT userid, height, share_diff FROM shares
+		WHERE valid = 1 AND coinid = ? AND height <= ?
+		ORDER BY id DESC
+	`, SiaCoinID, maxHeight)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sr shareRecord
+		err = rows.Scan(&sr.UserID, &sr.Height, &sr.ShareDifficulty)
+		if err != nil {
+			return err
+		}
+		if !fn(sr) {
+			break
+		}
+	}
+	return rows.Err()
+}
+
+// Balances implements the poolStore interface. The balance of an account is
+// the sum of its earnings minus the sum of its payouts.
+func (s *mysqlPoolStore) Balances() ([]balanceRecord, error) {
+	rows, err := s.db().Query(`
+		SELECT a.id, a.username, CAST(e.amount - COALESCE(p.amount, 0) AS DECIMAL(65,0))
+		FROM accounts a
+		JOIN (SELECT userid, SUM(amount) AS amount FROM pool_earnings WHERE coinid = ? GROUP BY userid) e
+			ON e.userid = a.id
+		LEFT JOIN (SELECT account_id, SUM(amount) AS amount FROM pool_payouts WHERE idcoin = ? GROUP BY account_id) p
+			ON p.account_id = a.id
+	`, SiaCoinID, SiaCoinID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []balanceRecord
+	for rows.Next() {
+		var br balanceRecord
+		var balance string
+		err = rows.Scan(&br.UserID, &br.Name, &balance)
+		if err != nil {
+			return nil, err
+		}
+		// Balances can't be negative, but a broken database shouldn't stop
+		// the payouts of the other accounts.
+		if strings.HasPrefix(balance, "-") {
+			continue
+		}
+		_, err = fmt.Sscan(balance, &br.Balance)
+		if err != nil {
+			return nil, err
+		}
+		if br.Balance.IsZero() {
+			continue
+		}
+		balances = append(balances, br)
+	}
+	return balances, rows.Err()
+}
+
+// AddPayouts implements the poolStore interface.
+func (s *mysqlPoolStore) AddPayouts(payouts []payoutRecord) error {
+	tx, err := s.db().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, pr := range payouts {
+		_, err = tx.Exec(`
+			INSERT INTO pool_payouts (account_id, time, amount, tx, idcoin)
+			VALUES (?, ?, ?, ?, ?)
+		`, pr.UserID, pr.Time, pr.Amount.String(), pr.TxID, SiaCoinID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Payouts implements the poolStore interface.
+func (s *mysqlPoolStore) Payouts(userID int64) ([]payoutRecord, error) {
+	query := `
+		SELECT p.id, p.account_id, a.username, CAST(p.amount AS DECIMAL(65,0)), p.tx, p.time
+		FROM pool_payouts p JOIN accounts a ON a.id = p.account_id
+		WHERE p.idcoin = ?`
+	args := []interface{}{SiaCoinID}
+	if userID != 0 {
+		query += " AND p.account_id = ?"
+		args = append(args, userID)
+	}
+	query += " ORDER BY p.id DESC"
+	rows, err := s.db().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payouts []payoutRecord
+	for rows.Next() {
+		var pr payoutRecord
+		var amount string
+		err = rows.Scan(&pr.ID, &pr.UserID, &pr.Name, &amount, &pr.TxID, &pr.Time)
+		if err != nil {
+			return nil, err
+		}
+		_, err = fmt.Sscan(amount, &pr.Amount)
+		if err != nil {
+			return nil, err
+		}
+		payouts = append(payouts, pr)
+	}
+	return payouts, rows.Err()
+}
+
+// Close implements the poolStore interface.
+func (s *mysqlPoolStore) Close() error {
+	db := s.db()
+	if db == nil {
+		return nil
+	}
+	return db.Close()
+}
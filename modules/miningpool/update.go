@@ -266,6 +266,12 @@ func (p *Pool) ProcessConsensusChange(cc modules.ConsensusChange) {
 			p.log.Printf("Notifying clients\n")
 			p.dispatcher.ClearJobAndNotifyClients()
 		}
+
+		// Found blocks might have matured.
+		select {
+		case p.payoutChan <- struct{}{}:
+		default:
+		}
 	}
 }
 
@@ -1,11 +1,14 @@
 package pool
 
 import (
+	"math"
 	"path/filepath"
+	"sync/atomic"
 	"time"
 
 	"github.com/sasha-s/go-deadlock"
 
+	"github.com/EvilRedHorse/pubaccess-node/modules"
 	"github.com/EvilRedHorse/pubaccess-node/persist"
 )
 
@@ -17,12 +20,35 @@ type WorkerRecord struct {
 	parent          *Client
 }
 
+// shareSample is an accepted share that is used to estimate the hashrate of a
+// worker.
+type shareSample struct {
+	time       time.Time
+	difficulty float64
+}
+
+// workerStats are the share statistics of a worker. The share counts cover
+// the shares since the pool found its most recent block, which is tracked by
+// the block epoch of the pool.
+type workerStats struct {
+	blockEpoch           uint64
+	shares               uint64
+	invalidShares        uint64
+	staleShares          uint64
+	cumulativeDifficulty float64
+	blocksFound          uint64
+	lastShareTime        time.Time
+	recentShares         []shareSample
+}
+
 // A Worker is an instance of one miner.  A Client often represents a user and the
 // worker represents a single miner.  There is a one to many client worker relationship
 type Worker struct {
 	mu deadlock.RWMutex
 	wr WorkerRecord
 	s  *Session
+	// statistics
+	stats workerStats
 	// utility
 	log *persist.Logger
 }
@@ -135,11 +161,128 @@ func (w *Worker) IncrementShares(sessionDifficulty float64, reward float64) {
 	}
 
 	w.s.Shift().IncrementShares(share)
+
+	w.mu.Lock()
+	w.resetStats(p)
+	w.stats.shares++
+	w.stats.cumulativeDifficulty += sessionDifficulty
+	w.stats.lastShareTime = share.time
+	w.stats.recentShares = append(w.stats.recentShares, shareSample{
+		time:       share.time,
+		difficulty: sessionDifficulty,
+	})
+	w.pruneRecentShares(share.time)
+	w.mu.Unlock()
 }
 
 // IncrementInvalidShares adds a record of an invalid share submission
 func (w *Worker) IncrementInvalidShares() {
 	w.s.Shift().IncrementInvalid()
+
+	p := w.Parent().Pool()
+	w.mu.Lock()
+	w.resetStats(p)
+	w.stats.invalidShares++
+	w.mu.Unlock()
+}
+
+// IncrementStaleShares adds a record of a share submission for a job that is
+// no longer valid. Stale shares are stored as invalid shares.
+func (w *Worker) IncrementStaleShares() {
+	w.s.Shift().IncrementInvalid()
+
+	p := w.Parent().Pool()
+	w.mu.Lock()
+	w.resetStats(p)
+	w.stats.staleShares++
+	w.mu.Unlock()
+}
+
+// incrementBlocksFound records that the worker found a block.
+func (w *Worker) incrementBlocksFound() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stats.blocksFound++
+}
+
+// resetStats resets the share counts of the worker if the pool found a
+// block since the last share. The worker has to be locked.
+func (w *Worker) resetStats(p *Pool) {
+	epoch := atomic.LoadUint64(&p.blockEpoch)
+	if w.stats.blockEpoch == epoch {
+		return
+	}
+	w.stats.blockEpoch = epoch
+	w.stats.shares = 0
+	w.stats.invalidShares = 0
+	w.stats.staleShares = 0
+	w.stats.cumulativeDifficulty = 0
+}
+
+// pruneRecentShares removes the shares that are too old to be part of the
+// hashrate estimate. The worker has to be locked.
+func (w *Worker) pruneRecentShares(now time.Time) {
+	i := 0
+	for i < len(w.stats.recentShares) && now.Sub(w.stats.recentShares[i].time) > hashrateWindow {
+		i++
+	}
+	w.stats.recentShares = w.stats.recentShares[i:]
+}
+
+// Hashrate estimates the hashes per second of the worker from the difficulty
+// of its recent shares. A share of difficulty 1 takes 2^32 hashes on average.
+func (w *Worker) Hashrate() float64 {
+	now := time.Now()
+	w.mu.Lock()
+	w.pruneRecentShares(now)
+	var difficulty float64
+	for _, share := range w.stats.recentShares {
+		difficulty += share.difficulty
+	}
+	s := w.s
+	w.mu.Unlock()
+
+	// Workers that connected recently are measured over a shorter window.
+	window := hashrateWindow
+	if s != nil {
+		s.mu.RLock()
+		if connected := now.Sub(s.sessionStartTimestamp); connected < window {
+			window = connected
+		}
+		s.mu.RUnlock()
+	}
+	if window <= 0 {
+		return 0
+	}
+	return difficulty * math.Pow(2, 32) / window.Seconds()
+}
+
+// Info returns the statistics of the worker.
+func (w *Worker) Info() modules.PoolWorker {
+	hashrate := w.Hashrate()
+	p := w.Parent().Pool()
+
+	w.mu.Lock()
+	w.resetStats(p)
+	info := modules.PoolWorker{
+		WorkerName:             w.wr.name,
+		LastShareTime:          w.stats.lastShareTime,
+		CumulativeDifficulty:   w.stats.cumulativeDifficulty,
+		SharesThisBlock:        w.stats.shares,
+		InvalidSharesThisBlock: w.stats.invalidShares,
+		StaleSharesThisBlock:   w.stats.staleShares,
+		BlocksFound:            w.stats.blocksFound,
+		Hashrate:               hashrate,
+	}
+	s := w.s
+	w.mu.Unlock()
+
+	// The session locks the worker while holding its own lock, so it can't
+	// be accessed while the worker is locked.
+	if s != nil {
+		info.CurrentDifficulty = s.CurrentDifficulty()
+	}
+	return info
 }
 
 // SetLastShareTime specifies the last time a share was submitted during the
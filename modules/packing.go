@@ -3,8 +3,6 @@ package modules
 import (
 	"errors"
 	"sort"
-
-	"github.com/EvilRedHorse/pubaccess-node/build"
 )
 
 var (
@@ -15,17 +13,6 @@ var (
 
 	// errBucketNotFound is returned when no applicable bucket exists.
 	errBucketNotFound = errors.New("no bucket was found")
-
-	// alignmentScaling scales the alignments with respect to SectorSize.
-	//
-	// Without this scaling, we could end up with alignments that don't fit in a
-	// sector, especially in Testing builds where small sectors are used.
-	alignmentScaling = build.Select(build.Var{
-		Dev:      uint64(1 << (alignmentScalingStandard - (SectorSizeScalingStandard - SectorSizeScalingDev))),
-		Standard: uint64(1 << alignmentScalingStandard), // 1 KiB
-		Testing:  uint64(1 << (alignmentScalingStandard - (SectorSizeScalingStandard - SectorSizeScalingTesting))),
-	}).(uint64)
-	alignmentScalingStandard = 10
 )
 
 type (
@@ -72,7 +59,8 @@ type (
 //   based on its size.
 //
 //     i. For a file size up to 32*2^n KiB, the file must align to 4*2^n KiB,
-//     for 0 <= n <= 7.
+//     for 0 <= n <= 7. These are the offset alignments of v1 publinks, so
+//     every placement can be turned into a publink.
 //
 //     ii. Alignment is based on the start of the sector, not the bucket.
 //
@@ -187,16 +175,12 @@ func extendSectors(buckets bucketList, numSectors uint64) (bucketList, uint64) {
 // requiredAlignment returns the byte alignment from the start of a sector that
 // the file must start at, based on the size of the file.
 func requiredAlignment(fileSize uint64) (uint64, error) {
-	// NOTE: We need to scale the required alignments the same way we scale the
-	// SectorSize, so that the alignments actually fit inside sectors in Dev and
-	// Testing builds.
-	for n := 0; n < 8; n++ {
-		if fileSize <= 32*(1<<n)*alignmentScaling {
-			return 4 * (1 << n) * alignmentScaling, nil
-		}
+	// NOTE: The alignments are not scaled in Dev and Testing builds, since the
+	// offset of a v1 publink is always given in bytes.
+	if fileSize > PublinkMaxFetchSize {
+		return 0, ErrSizeTooLarge
 	}
-
-	return 0, ErrSizeTooLarge
+	return PublinkV1OffsetAlignment(fileSize), nil
 }
 
 // alignFileInBucket returns the offset in the bucket that the file aligns to.
@@ -9,6 +9,7 @@ import (
 
 	"gitlab.com/NebulousLabs/fastrand"
 	"github.com/EvilRedHorse/pubaccess-node/build"
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
 )
 
 const (
@@ -22,8 +23,6 @@ const (
 func TestPackFiles(t *testing.T) {
 	// Test using the production sector size.
 	SectorSize = SectorSizeStandard
-	// Change the scaling as well.
-	alignmentScaling = uint64(1 << alignmentScalingStandard)
 
 	tests := []struct {
 		in  map[string]uint64
@@ -139,8 +138,6 @@ func TestPackFiles(t *testing.T) {
 func TestPackFilesRandom(t *testing.T) {
 	// Test using the production sector size.
 	SectorSize = SectorSizeStandard
-	// Change the scaling as well.
-	alignmentScaling = uint64(1 << alignmentScalingStandard)
 
 	numFiles := int(5e3)
 
@@ -169,6 +166,10 @@ func TestPackFilesRandom(t *testing.T) {
 		if j > SectorSize {
 			t.Errorf("placement outside sector: (%v, %v)", i, j)
 		}
+		// Every placement needs to be addressable by a v1 publink.
+		if _, err := NewPublinkV1(crypto.Hash{}, i, size); err != nil {
+			t.Errorf("placement (%v, %v) can't be encoded in a publink: %v", i, j, err)
+		}
 	}
 
 	// Check that there are no overlapping files.
@@ -344,8 +345,6 @@ func TestFindBucket(t *testing.T) {
 func TestRequiredAlignment(t *testing.T) {
 	// Test using the production sector size.
 	SectorSize = SectorSizeStandard
-	// Change the scaling as well.
-	alignmentScaling = uint64(1 << alignmentScalingStandard)
 
 	tests := []struct {
 		fileSize, out uint64
@@ -368,8 +367,6 @@ func TestRequiredAlignment(t *testing.T) {
 func TestAlignFileInBucket(t *testing.T) {
 	// Test using the production sector size.
 	SectorSize = SectorSizeStandard
-	// Change the scaling as well.
-	alignmentScaling = uint64(1 << alignmentScalingStandard)
 
 	tests := []struct {
 		fileSize, sectorOffset, out uint64
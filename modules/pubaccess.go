@@ -8,7 +8,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
 	"github.com/EvilRedHorse/pubaccess-node/pubaccesskey"
+	"github.com/EvilRedHorse/pubaccess-node/types"
 )
 
 const (
@@ -18,6 +20,14 @@ const (
 	// SkyfileDisableDefaultPathParamName specifies the name of the form
 	// parameter that holds the disable-default-path flag.
 	SkyfileDisableDefaultPathParamName = "disabledefaultpath"
+
+	// SkynetBlacklistSourceLocal is the source of blacklist entries which were
+	// added manually to the blacklist of the node.
+	SkynetBlacklistSourceLocal = "local"
+
+	// SkykeyRotationDir is the default directory within the pubaccess folder
+	// for pubfiles that were re-encrypted during a pubaccesskey rotation.
+	SkykeyRotationDir = "rotated"
 )
 
 // PubfileMetadata is all of the metadata that gets placed into the first 4096
@@ -201,6 +211,8 @@ var (
 	SkyfileFormatTarGz = PubfileFormat("targz")
 	// SkyfileFormatZip returns the pubfiles as a .zip.
 	SkyfileFormatZip = PubfileFormat("zip")
+	// SkyfileFormatIndex returns a directory index of the pubfiles.
+	SkyfileFormatIndex = PubfileFormat("index")
 )
 
 // Extension returns the extension for the format
@@ -270,6 +282,67 @@ type PubfileUploadParameters struct {
 	FileSpecificSkykey pubaccesskey.Pubaccesskey
 }
 
+// PubfileBatchUploadParameters defines the parameters for uploading many small
+// pubfiles at once. The files are packed into shared sectors, every file still
+// gets its own publink.
+type PubfileBatchUploadParameters struct {
+	// SiaPath is the directory in which the siafiles tracking the packed
+	// sectors are stored.
+	SiaPath SiaPath `json:"siapath"`
+
+	// DryRun allows to retrieve the publinks without actually uploading the
+	// files to the ScPrime network.
+	DryRun bool `json:"dryrun"`
+
+	// Force determines whether existing siafiles of packed sectors should be
+	// overwritten.
+	Force bool `json:"force"`
+
+	// BaseChunkRedundancy is the redundancy used for every packed sector.
+	BaseChunkRedundancy uint8 `json:"basechunkredundancy"`
+
+	// Files contains the files that should be packed.
+	Files []PubfileBatchFile `json:"files"`
+}
+
+// PubfileBatchFile is a single file of a batch upload.
+type PubfileBatchFile struct {
+	// Metadata is the metadata of the pubfile. The Length is set by the
+	// renter.
+	Metadata PubfileMetadata `json:"metadata"`
+
+	// Data is the content of the file. Together with the metadata it needs to
+	// fit within a single sector.
+	Data []byte `json:"data"`
+}
+
+// PubfileUploadStatus is the progress of a resumable pubfile upload. The data
+// of a resumable upload is sent in chunks and staged by the renter until all
+// of it was received, at which point the pubfile is uploaded.
+type PubfileUploadStatus struct {
+	// UploadID identifies the upload when appending data to it.
+	UploadID string `json:"uploadid"`
+
+	// SiaPath is the siapath that the pubfile is going to be uploaded to.
+	SiaPath SiaPath `json:"siapath"`
+
+	// Filename is the filename of the pubfile.
+	Filename string `json:"filename"`
+
+	// Length is the total size of the pubfile's data and Uploaded is the
+	// number of bytes that were received and committed to disk so far. Data
+	// is appended at the offset Uploaded.
+	Length   uint64 `json:"length"`
+	Uploaded uint64 `json:"uploaded"`
+
+	// Publink is set once all the data was received and the pubfile was
+	// uploaded.
+	Publink string `json:"publink,omitempty"`
+
+	CreatedAt  time.Time `json:"createdat"`
+	LastUpdate time.Time `json:"lastupdate"`
+}
+
 // SkyfileMultipartUploadParameters defines the parameters specific to multipart
 // uploads. See PubfileUploadParameters for a detailed description of the
 // fields.
@@ -294,6 +367,10 @@ type SkyfileMultipartUploadParameters struct {
 
 	// ContentType indicates the media type of the data supplied by the reader.
 	ContentType string
+
+	// ExtractArchive expands the tar, tar.gz or zip archive supplied by the
+	// reader into a multi-file pubfile.
+	ExtractArchive bool
 }
 
 // SkyfilePinParameters defines the parameters specific to pinning a publink.
@@ -309,6 +386,93 @@ type SkyfilePinParameters struct {
 type SkynetPortal struct {
 	Address NetAddress `json:"address"` // the IP or domain name of the portal. Must be a valid network address
 	Public  bool       `json:"public"`  // indicates whether the portal can be accessed publicly or not
+
+	// Health is the result of the latest health checks of the portal. It is
+	// nil if the portal hasn't been checked yet and ignored when adding
+	// portals.
+	Health *SkynetPortalHealth `json:"health,omitempty"`
+}
+
+// SkynetPortalHealth describes the health of a Pubaccess portal as determined
+// by periodically probing it.
+type SkynetPortalHealth struct {
+	Healthy bool          `json:"healthy"` // whether the latest probe succeeded
+	URL     string        `json:"url"`     // the base url the portal responded on
+	Version string        `json:"version"` // the version reported by the portal
+	Latency time.Duration `json:"latency"` // the duration of the latest successful probe
+
+	LastCheck           time.Time `json:"lastcheck"`
+	LastSuccess         time.Time `json:"lastsuccess"`
+	LastError           string    `json:"lasterror"`
+	ConsecutiveFailures uint64    `json:"consecutivefailures"`
+}
+
+// HealthiestSkynetPortal returns the healthy portal with the lowest latency.
+// Portals with the same latency are ordered by address. False is returned if
+// none of the portals is healthy.
+func HealthiestSkynetPortal(portals []SkynetPortal) (SkynetPortal, bool) {
+	var best SkynetPortal
+	found := false
+	for _, portal := range portals {
+		if portal.Health == nil || !portal.Health.Healthy {
+			continue
+		}
+		if found {
+			if portal.Health.Latency > best.Health.Latency {
+				continue
+			}
+			if portal.Health.Latency == best.Health.Latency && portal.Address > best.Address {
+				continue
+			}
+		}
+		best = portal
+		found = true
+	}
+	return best, found
+}
+
+// SkynetBlacklistEntry is a blacklisted hash of a merkleroot together with the
+// sources that blacklisted it. A source is either SkynetBlacklistSourceLocal or
+// the URL of a subscribed blacklist feed.
+type SkynetBlacklistEntry struct {
+	Hash    crypto.Hash `json:"hash"`
+	Sources []string    `json:"sources"`
+}
+
+// SkynetBlacklistSubscription is a subscription to a remote blacklist feed. The
+// feed is fetched from the URL periodically and has to be signed by the public
+// key of the subscription.
+type SkynetBlacklistSubscription struct {
+	URL       string             `json:"url"`
+	PublicKey types.SiaPublicKey `json:"publickey"`
+
+	// The following fields are set by the blacklist and describe the state of
+	// the subscription.
+	Revision   uint64    `json:"revision"`
+	NumEntries uint64    `json:"numentries"`
+	LastUpdate time.Time `json:"lastupdate"`
+	LastError  string    `json:"lasterror"`
+}
+
+// PubaccesskeyRotation records the rotation of a pubaccesskey to its
+// successor. Pubfiles that were encrypted with the rotated key are re-encrypted
+// with the successor, which results in new publinks.
+type PubaccesskeyRotation struct {
+	KeyID       pubaccesskey.PubaccesskeyID `json:"keyid"`
+	SuccessorID pubaccesskey.PubaccesskeyID `json:"successorid"`
+	CreatedAt   time.Time                   `json:"createdat"`
+
+	// Publinks maps the publinks that were re-encrypted to the publinks of
+	// the re-encrypted pubfiles, in the order in which they were rotated.
+	Publinks []RotatedPublink `json:"publinks"`
+}
+
+// RotatedPublink is a publink that was re-encrypted during the rotation of a
+// pubaccesskey.
+type RotatedPublink struct {
+	Publink   string    `json:"publink"`
+	Successor string    `json:"successor"`
+	RotatedAt time.Time `json:"rotatedat"`
 }
 
 // EnsurePrefix checks if `str` starts with `prefix` and adds it if that's not
@@ -2,6 +2,7 @@ package modules
 
 import (
 	"testing"
+	"time"
 )
 
 // TestSkyfileMetadata_ForPath tests the behaviour of the ForPath method.
@@ -125,3 +126,27 @@ func TestSkyfileMetadata_ForPath(t *testing.T) {
 		t.Fatal(`Expected offset to be zero, got`, offset)
 	}
 }
+
+// TestHealthiestSkynetPortal tests selecting the healthiest portal.
+func TestHealthiestSkynetPortal(t *testing.T) {
+	healthy := func(latency time.Duration) *SkynetPortalHealth {
+		return &SkynetPortalHealth{Healthy: true, Latency: latency}
+	}
+	portals := []SkynetPortal{
+		{Address: "unchecked.com:443"},
+		{Address: "unhealthy.com:443", Health: &SkynetPortalHealth{Latency: time.Millisecond}},
+		{Address: "slow.com:443", Health: healthy(time.Second)},
+		{Address: "fast2.com:443", Health: healthy(10 * time.Millisecond)},
+		{Address: "fast1.com:443", Health: healthy(10 * time.Millisecond)},
+	}
+	portal, ok := HealthiestSkynetPortal(portals)
+	if !ok || portal.Address != "fast1.com:443" {
+		t.Fatal("unexpected healthiest portal", portal.Address, ok)
+	}
+
+	// Without healthy portals there is no healthiest portal.
+	_, ok = HealthiestSkynetPortal(portals[:2])
+	if ok {
+		t.Fatal("expected no healthy portal")
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/EvilRedHorse/pubaccess-node/build"
 	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/types"
 
 	"gitlab.com/NebulousLabs/errors"
 )
@@ -35,6 +36,10 @@ const (
 
 	// rawPublinkSize is the raw size of the data that gets put into a link.
 	rawPublinkSize = 34
+
+	// publinkV2Bitfield is the only legal bitfield of a v2 publink. The
+	// version bits are set to '01' and the remaining bits are unused.
+	publinkV2Bitfield = 1
 )
 
 var (
@@ -51,6 +56,10 @@ type (
 	// The first two bits of the bitfield (values 1 and 2 in decimal) determine
 	// the version of the publink. The publink version determines how the
 	// remaining bits are used. Not all values of the bitfield are legal.
+	//
+	// A v1 publink points to data within a sector. A v2 publink points to a
+	// registry entry which contains a v1 publink, instead of a Merkle root it
+	// holds the id of the registry entry and the remaining bits are unused.
 	Publink struct {
 		bitfield   uint16
 		merkleRoot crypto.Hash
@@ -73,6 +82,17 @@ func NewPublinkV1(merkleRoot crypto.Hash, offset, length uint64) (Publink, error
 	return sl, nil
 }
 
+// NewPublinkV2 will return a v2 Publink object which points to the registry
+// entry of the given public key and tweak. The value of the registry entry is
+// expected to be a v1 publink, which allows the owner of the key to update the
+// data the v2 publink resolves to.
+func NewPublinkV2(spk types.SiaPublicKey, tweak crypto.Hash) Publink {
+	return Publink{
+		bitfield:   publinkV2Bitfield,
+		merkleRoot: RegistryEntryID(spk, tweak),
+	}
+}
+
 // validateAndParseV1Bitfield is a helper method which validates that a bitfield
 // is valid and also parses the offset and fetch size from the bitfield. These
 // two actions are performed at once because performing full validation requires
@@ -154,6 +174,14 @@ func (sl Publink) DataSourceID() DataSourceID {
 	return DataSourceID(crypto.HashObject(sl.String()))
 }
 
+// LoadBytes loads the raw bytes of a publink, as returned by Bytes, into sl.
+func (sl *Publink) LoadBytes(data []byte) error {
+	if len(data) != rawPublinkSize {
+		return ErrPublinkIncorrectSize
+	}
+	return sl.loadBytes(data)
+}
+
 // LoadString converts from a string and loads the result into sl.
 func (sl *Publink) LoadString(s string) error {
 	// Trim any parameters that may exist after a question mark. Eventually, it
@@ -182,11 +210,30 @@ func (sl *Publink) LoadString(s string) error {
 	return sl.loadBytes(raw)
 }
 
+// IsPublinkV1 returns true if the publink is a v1 publink.
+func (sl Publink) IsPublinkV1() bool {
+	return sl.Version() == 1
+}
+
+// IsPublinkV2 returns true if the publink is a v2 publink.
+func (sl Publink) IsPublinkV2() bool {
+	return sl.Version() == 2
+}
+
 // MerkleRoot returns the merkle root of the Publink.
 func (sl Publink) MerkleRoot() crypto.Hash {
 	return sl.merkleRoot
 }
 
+// RegistryEntryID returns the id of the registry entry a v2 Publink points
+// to.
+func (sl Publink) RegistryEntryID() (crypto.Hash, error) {
+	if !sl.IsPublinkV2() {
+		return crypto.Hash{}, errors.New("publink is not a v2 publink")
+	}
+	return sl.merkleRoot, nil
+}
+
 // OffsetAndFetchSize returns the offset and fetch size of a file that sits
 // within a publink sector. All publinks point to one sector of data. If the
 // file is large enough that more data is necessary, a "fanout" is used to point
@@ -322,9 +369,15 @@ func (sl *Publink) loadBytes(data []byte) error {
 	// Publink so that the Publink remains unchanged if there is any error
 	// parsing the string.
 	bitfield := binary.LittleEndian.Uint16(data)
-	_, _, err := validateAndParseV1Bitfield(bitfield)
-	if err != nil {
-		return errors.AddContext(err, "publink failed verification")
+	if bitfield&3 == publinkV2Bitfield {
+		if bitfield != publinkV2Bitfield {
+			return errors.New("publink failed verification: v2 publink has unused bits set")
+		}
+	} else {
+		_, _, err := validateAndParseV1Bitfield(bitfield)
+		if err != nil {
+			return errors.AddContext(err, "publink failed verification")
+		}
 	}
 
 	// Load the raw data.
@@ -333,6 +386,22 @@ func (sl *Publink) loadBytes(data []byte) error {
 	return nil
 }
 
+// PublinkV1OffsetAlignment returns the alignment that the offset of a v1
+// publink with the given fetch size needs to have.
+//
+// The largest offset alignment is 512 kib, which is used if the fetch size is
+// 2 MiB or over. Each time the fetch size is halved, the offset alignment is
+// also halved. The smallest offset alignment is 4 kib.
+func PublinkV1OffsetAlignment(fetchSize uint64) uint64 {
+	minFetchSize := uint64(1 << 21)
+	offsetAlign := uint64(1 << 19)
+	for fetchSize <= minFetchSize && offsetAlign > (1<<12) {
+		offsetAlign >>= 1
+		minFetchSize >>= 1
+	}
+	return offsetAlign
+}
+
 // setOffsetAndFetchSize will set the offset and fetch size of the data within
 // the publink. Offset must be aligned correctly. setOffsetAndLen implies that
 // the version is 1, so the version will also be set to 1.
@@ -342,16 +411,7 @@ func (sl *Publink) setOffsetAndFetchSize(offset, fetchSize uint64) error {
 	}
 
 	// Given the fetch size, determine the appropriate offset alignment.
-	//
-	// The largest offset alignment is 512 kib, which is used if the fetch size
-	// is 2 MiB or over. Each time the fetch size is halved, the offset
-	// alignment is also halved. The smallest offset alignment is 4 kib.
-	minFetchSize := uint64(1 << 21)
-	offsetAlign := uint64(1 << 19)
-	for fetchSize <= minFetchSize && offsetAlign > (1<<12) {
-		offsetAlign >>= 1
-		minFetchSize >>= 1
-	}
+	offsetAlign := PublinkV1OffsetAlignment(fetchSize)
 	if offset&(offsetAlign-1) != 0 {
 		return errors.New("offset is not aligned correctly")
 	}
@@ -7,6 +7,7 @@ import (
 	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/fastrand"
 	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/types"
 )
 
 // TestPublinkManualExamples checks a pile of manual examples using table driven
@@ -203,7 +204,7 @@ func TestPublink(t *testing.T) {
 
 	// Try loading a base32 encoded string with invalid bitfield
 	var slInvalidBitfield Publink
-	slInvalidBitfield.bitfield = 1
+	slInvalidBitfield.bitfield = 2
 	b32BadBitfield := slInvalidBitfield.Base32EncodedString()
 	err = slMaxB32Decoded.LoadString(b32BadBitfield)
 	if err == nil {
@@ -382,3 +383,58 @@ func (sl Publink) Base32EncodedString() string {
 	// Encode the raw bytes to base32
 	return base32.HexEncoding.WithPadding(base32.NoPadding).EncodeToString(sl.Bytes())
 }
+
+// TestPublinkV2 checks the encoding and decoding of v2 publinks.
+func TestPublinkV2(t *testing.T) {
+	_, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+
+	sl := NewPublinkV2(spk, tweak)
+	if !sl.IsPublinkV2() || sl.IsPublinkV1() || sl.Version() != 2 {
+		t.Fatal("bad version:", sl.Version())
+	}
+	id, err := sl.RegistryEntryID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != RegistryEntryID(spk, tweak) {
+		t.Fatal("registry entry id mismatch")
+	}
+	_, _, err = sl.OffsetAndFetchSize()
+	if err == nil {
+		t.Fatal("v2 publink shouldn't have an offset and fetch size")
+	}
+
+	// The string encodings can be loaded again.
+	var loaded Publink
+	if err := loaded.LoadString(sl.String()); err != nil {
+		t.Fatal(err)
+	}
+	if loaded != sl {
+		t.Fatal("loaded publink doesn't match")
+	}
+	if err := loaded.LoadString(sl.Base32EncodedString()); err != nil {
+		t.Fatal(err)
+	}
+	if loaded != sl {
+		t.Fatal("loaded base32 publink doesn't match")
+	}
+
+	// A v2 publink with other bits set is invalid.
+	bad := sl
+	bad.bitfield |= 1 << 2
+	if err := loaded.LoadString(bad.String()); err == nil {
+		t.Fatal("expected error when loading v2 publink with unused bits set")
+	}
+
+	// A v1 publink has no registry entry id.
+	v1, err := NewPublinkV1(crypto.HashObject("data"), 0, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v1.RegistryEntryID(); err == nil {
+		t.Fatal("v1 publink shouldn't have a registry entry id")
+	}
+}
@@ -0,0 +1,147 @@
+package modules
+
+// registry.go contains the types of the registry. The registry is a key-value
+// store on hosts. Every entry is keyed by the public key of its owner and a
+// tweak chosen by the owner, and holds a small value which is signed by the
+// owner and revisioned. Hosts only replace a value by a value with a higher
+// revision number, which allows the owner to update the value while nobody
+// else can.
+
+import (
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+const (
+	// RegistryDataSize is the maximum size of the data stored in a registry
+	// value.
+	RegistryDataSize = 113
+
+	// RegistryEntrySize is the size of a registry entry on the host, which
+	// is used to price the storage and the bandwidth of the entry.
+	RegistryEntrySize = 256
+)
+
+var (
+	// RegistryStorageDuration is the period the renter pays storage for when
+	// updating a registry entry.
+	RegistryStorageDuration = types.BlocksPerYear
+)
+
+var (
+	// ErrRegistryDataTooLarge is returned if the data of a registry value
+	// exceeds RegistryDataSize.
+	ErrRegistryDataTooLarge = errors.New("registry data is too large")
+
+	// ErrRegistryEntryNotFound is returned if a registry entry doesn't exist.
+	ErrRegistryEntryNotFound = errors.New("registry entry not found")
+
+	// ErrRegistryInvalidPublicKey is returned if the public key of a registry
+	// entry is not an ed25519 key.
+	ErrRegistryInvalidPublicKey = errors.New("registry public key is not an ed25519 key")
+
+	// ErrRegistryInvalidSignature is returned if the signature of a registry
+	// value doesn't match the public key of the entry.
+	ErrRegistryInvalidSignature = errors.New("registry value has an invalid signature")
+
+	// ErrRegistryFull is returned if the registry of the host holds the
+	// maximum number of entries and can't store a new entry.
+	ErrRegistryFull = errors.New("registry is full")
+
+	// ErrRegistryLowerRevNum is returned if an update of a registry entry
+	// doesn't increase the revision number of the entry.
+	ErrRegistryLowerRevNum = errors.New("registry update has a revision number that is not higher than the existing one")
+)
+
+var (
+	// RPCReadRegistry specifier
+	RPCReadRegistry = types.NewSpecifier("ReadRegistry")
+
+	// RPCUpdateRegistry specifier
+	RPCUpdateRegistry = types.NewSpecifier("UpdateRegistry")
+)
+
+type (
+	// RegistryValue is the value of a registry entry. The tweak distinguishes
+	// the entries of the same public key.
+	RegistryValue struct {
+		Tweak    crypto.Hash
+		Data     []byte
+		Revision uint64
+	}
+
+	// SignedRegistryValue is a registry value signed by the owner of the
+	// entry.
+	SignedRegistryValue struct {
+		RegistryValue
+		Signature crypto.Signature
+	}
+
+	// RPCReadRegistryRequest requests the registry entry with the given ID.
+	RPCReadRegistryRequest struct {
+		EntryID crypto.Hash
+	}
+
+	// RPCReadRegistryResponse contains a registry entry. The public key is
+	// included so the renter can verify the signature of the value.
+	RPCReadRegistryResponse struct {
+		PubKey types.SiaPublicKey
+		Value  SignedRegistryValue
+	}
+
+	// RPCUpdateRegistryRequest contains a new value for a registry entry.
+	RPCUpdateRegistryRequest struct {
+		PubKey types.SiaPublicKey
+		Value  SignedRegistryValue
+	}
+
+	// RPCUpdateRegistryResponse is sent by the host after storing the value.
+	RPCUpdateRegistryResponse struct{}
+)
+
+// NewRegistryValue creates a new unsigned registry value.
+func NewRegistryValue(tweak crypto.Hash, data []byte, revision uint64) RegistryValue {
+	return RegistryValue{
+		Tweak:    tweak,
+		Data:     data,
+		Revision: revision,
+	}
+}
+
+// RegistryEntryID returns the ID of the registry entry with the given public
+// key and tweak. Hosts store entries by their ID.
+func RegistryEntryID(spk types.SiaPublicKey, tweak crypto.Hash) crypto.Hash {
+	return crypto.HashAll(spk, tweak)
+}
+
+// Hash returns the hash of the registry value which is signed by the owner of
+// the entry.
+func (rv RegistryValue) Hash() crypto.Hash {
+	return crypto.HashAll(rv.Tweak, rv.Data, rv.Revision)
+}
+
+// Sign signs the registry value.
+func (rv RegistryValue) Sign(sk crypto.SecretKey) SignedRegistryValue {
+	return SignedRegistryValue{
+		RegistryValue: rv,
+		Signature:     crypto.SignHash(rv.Hash(), sk),
+	}
+}
+
+// Verify checks the size of the data and the signature of the value.
+func (srv SignedRegistryValue) Verify(spk types.SiaPublicKey) error {
+	if len(srv.Data) > RegistryDataSize {
+		return ErrRegistryDataTooLarge
+	}
+	if spk.Algorithm != types.SignatureEd25519 || len(spk.Key) != crypto.PublicKeySize {
+		return ErrRegistryInvalidPublicKey
+	}
+	var pk crypto.PublicKey
+	copy(pk[:], spk.Key)
+	if err := crypto.VerifyHash(srv.Hash(), pk, srv.Signature); err != nil {
+		return errors.Compose(ErrRegistryInvalidSignature, err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+)
+
+// TestSignedRegistryValueVerify checks the signing and verification of
+// registry values.
+func TestSignedRegistryValueVerify(t *testing.T) {
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+
+	srv := NewRegistryValue(tweak, fastrand.Bytes(RegistryDataSize), 1).Sign(sk)
+	if err := srv.Verify(spk); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing the value invalidates the signature.
+	changed := srv
+	changed.Revision++
+	if err := changed.Verify(spk); !errors.Contains(err, ErrRegistryInvalidSignature) {
+		t.Fatal("expected ErrRegistryInvalidSignature but got:", err)
+	}
+
+	// Another key doesn't verify the value.
+	_, otherPK := crypto.GenerateKeyPair()
+	if err := srv.Verify(types.Ed25519PublicKey(otherPK)); !errors.Contains(err, ErrRegistryInvalidSignature) {
+		t.Fatal("expected ErrRegistryInvalidSignature but got:", err)
+	}
+
+	// Too much data is rejected.
+	tooLarge := NewRegistryValue(tweak, fastrand.Bytes(RegistryDataSize+1), 1).Sign(sk)
+	if err := tooLarge.Verify(spk); !errors.Contains(err, ErrRegistryDataTooLarge) {
+		t.Fatal("expected ErrRegistryDataTooLarge but got:", err)
+	}
+
+	// Entries of different tweaks have different ids.
+	var otherTweak crypto.Hash
+	fastrand.Read(otherTweak[:])
+	if RegistryEntryID(spk, tweak) == RegistryEntryID(spk, otherTweak) {
+		t.Fatal("registry entry ids should differ")
+	}
+}
@@ -572,6 +572,10 @@ type RenterSettings struct {
 	MaxUploadSpeed   int64         `json:"maxuploadspeed"`
 	MaxDownloadSpeed int64         `json:"maxdownloadspeed"`
 	UploadsStatus    UploadsStatus `json:"uploadsstatus"`
+
+	// MaxResumableUploadBytes limits the combined length of the unfinished
+	// resumable pubfile uploads, which are staged on disk.
+	MaxResumableUploadBytes uint64 `json:"maxresumableuploadbytes"`
 }
 
 // UploadsStatus contains information about the Renter's Uploads
@@ -1086,14 +1090,26 @@ type Renter interface {
 	// Skykeys returns a slice containing each Pubaccesskey being stored by the renter.
 	Skykeys() ([]pubaccesskey.Pubaccesskey, error)
 
+	// RotateSkykey rotates the Pubaccesskey with the given ID. A successor
+	// with the given name is created on the first rotation of the key and
+	// reused afterwards. The pubfiles of the publinks are re-encrypted with
+	// the successor and uploaded to the given directory. Publinks which were
+	// rotated before are skipped.
+	RotateSkykey(id pubaccesskey.PubaccesskeyID, successorName string, publinks []Publink, dir SiaPath, timeout time.Duration) (PubaccesskeyRotation, error)
+
+	// SkykeyRotations returns the rotations of the renter's Pubaccesskeys.
+	SkykeyRotations() ([]PubaccesskeyRotation, error)
+
 	// CreatePublinkFromSiafile will create a publink from a siafile. This will
 	// result in some uploading - the base sector pubfile needs to be uploaded
 	// separately, and if there is a fanout expansion that needs to be uploaded
 	// separately as well.
 	CreatePublinkFromSiafile(PubfileUploadParameters, SiaPath) (Publink, error)
 
-	// DownloadPublink will fetch a file from the ScPrime network using the publink.
-	DownloadPublink(Publink, time.Duration) (PubfileMetadata, Streamer, error)
+	// DownloadPublink will fetch a file from the ScPrime network using the
+	// publink. The last argument is the number of fanout chunks that are
+	// fetched ahead of the reader.
+	DownloadPublink(Publink, time.Duration, uint64) (PubfileMetadata, Streamer, error)
 
 	// UploadSkyfile will upload data to the ScPrime network from a reader and
 	// create a pubfile, returning the publink that can be used to access the
@@ -1105,16 +1121,75 @@ type Renter interface {
 	// file.
 	UploadSkyfile(PubfileUploadParameters) (Publink, error)
 
+	// UploadSkyfileBatch packs many small files into shared sectors and
+	// returns one publink per file, in the same order as the files of the
+	// batch.
+	UploadSkyfileBatch(PubfileBatchUploadParameters) ([]Publink, error)
+
+	// CreateResumableSkyfileUpload creates a resumable upload of a pubfile
+	// with the given length. The data of the pubfile is appended in chunks
+	// using AppendResumableSkyfileUpload.
+	CreateResumableSkyfileUpload(PubfileUploadParameters, uint64) (PubfileUploadStatus, error)
+
+	// AppendResumableSkyfileUpload appends the data of the reader to the
+	// resumable upload at the given offset, which has to match the number of
+	// bytes that were uploaded. Once all the data was received, the pubfile
+	// is uploaded and the returned status contains its publink.
+	AppendResumableSkyfileUpload(uploadID string, offset uint64, data io.Reader) (PubfileUploadStatus, error)
+
+	// ResumableSkyfileUpload returns the status of a resumable upload.
+	ResumableSkyfileUpload(uploadID string) (PubfileUploadStatus, error)
+
+	// ResumableSkyfileUploads returns the status of all unfinished resumable
+	// uploads.
+	ResumableSkyfileUploads() ([]PubfileUploadStatus, error)
+
+	// AbortResumableSkyfileUpload aborts a resumable upload and removes its
+	// staged data.
+	AbortResumableSkyfileUpload(uploadID string) error
+
+	// SkyfileSpoolDir returns the directory in which pubfile uploads, such as
+	// archives, are spooled before they are uploaded.
+	SkyfileSpoolDir() string
+
 	// Blacklist returns the merkleroots that are blacklisted
 	Blacklist() ([]crypto.Hash, error)
 
 	// UpdateSkynetBlacklist updates the list of publinks that are blacklisted
 	UpdateSkynetBlacklist(additions, removals []Publink) error
 
+	// BlacklistEntries returns the hashes of the merkleroots that are
+	// blacklisted together with the sources that blacklisted them.
+	BlacklistEntries() ([]SkynetBlacklistEntry, error)
+
+	// UpdateSkynetBlacklistHashes updates the list of hashed merkleroots that
+	// are blacklisted.
+	UpdateSkynetBlacklistHashes(additions, removals []crypto.Hash) error
+
+	// BlacklistSubscriptions returns the subscribed remote blacklist feeds.
+	BlacklistSubscriptions() ([]SkynetBlacklistSubscription, error)
+
+	// UpdateSkynetBlacklistSubscriptions subscribes to the given remote
+	// blacklist feeds and unsubscribes from the feeds with the given URLs.
+	UpdateSkynetBlacklistSubscriptions(additions []SkynetBlacklistSubscription, removals []string) error
+
 	// PinPublink re-uploads the data stored at the file under that publink with
 	// the given parameters.
 	PinPublink(Publink, PubfileUploadParameters, time.Duration) error
 
+	// UnpinPublink deletes the siafiles that pin the data of the publink and
+	// returns their siapaths and total size. Publinks that share their base
+	// sector with other pubfiles are only unpinned if forced. v2 publinks are
+	// resolved within the timeout.
+	UnpinPublink(Publink, bool, time.Duration) ([]SiaPath, uint64, error)
+
+	// ReadRegistry reads the registry entry of the given public key and tweak
+	// from the hosts and returns the value with the highest revision number.
+	ReadRegistry(spk types.SiaPublicKey, tweak crypto.Hash, timeout time.Duration) (SignedRegistryValue, error)
+
+	// UpdateRegistry stores a signed value in the registry of the hosts.
+	UpdateRegistry(spk types.SiaPublicKey, srv SignedRegistryValue, timeout time.Duration) error
+
 	// Portals returns the list of known pubaccess portals.
 	Portals() ([]SkynetPortal, error)
 
@@ -1132,6 +1207,16 @@ type Streamer interface {
 	io.Closer
 }
 
+// PrefetchStreamer is a Streamer which can be instructed to fetch data before
+// it is read.
+type PrefetchStreamer interface {
+	Streamer
+
+	// Prefetch starts fetching the data in the range [offset, offset+length)
+	// in the background without moving the read head of the streamer.
+	Prefetch(offset, length uint64)
+}
+
 // RenterDownloadParameters defines the parameters passed to the Renter's
 // Download method.
 type RenterDownloadParameters struct {
@@ -304,6 +304,70 @@ var (
 	}).(time.Duration)
 )
 
+// Pubaccess blacklist feed constants.
+var (
+	// skynetBlacklistFeedsInterval is how often the subscribed blacklist feeds
+	// are fetched.
+	skynetBlacklistFeedsInterval = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: time.Hour,
+		Testing:  3 * time.Second,
+	}).(time.Duration)
+
+	// skynetBlacklistFeedTimeout is the timeout for fetching a single
+	// blacklist feed.
+	skynetBlacklistFeedTimeout = build.Select(build.Var{
+		Dev:      30 * time.Second,
+		Standard: 2 * time.Minute,
+		Testing:  10 * time.Second,
+	}).(time.Duration)
+)
+
+// Pubaccess portal health constants.
+var (
+	// skynetPortalsProbeInterval is how often the known portals are probed.
+	skynetPortalsProbeInterval = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: 10 * time.Minute,
+		Testing:  3 * time.Second,
+	}).(time.Duration)
+
+	// skynetPortalProbeTimeout is the timeout for probing a single portal.
+	skynetPortalProbeTimeout = build.Select(build.Var{
+		Dev:      10 * time.Second,
+		Standard: 30 * time.Second,
+		Testing:  5 * time.Second,
+	}).(time.Duration)
+)
+
+// Resumable pubfile upload constants.
+var (
+	// resumableUploadExpiry is how long a resumable upload can go without
+	// receiving data before its staged data is removed.
+	resumableUploadExpiry = build.Select(build.Var{
+		Dev:      time.Hour,
+		Standard: 7 * 24 * time.Hour,
+		Testing:  time.Hour,
+	}).(time.Duration)
+
+	// resumableUploadPruneInterval is how often the renter removes the
+	// resumable uploads that expired.
+	resumableUploadPruneInterval = build.Select(build.Var{
+		Dev:      10 * time.Minute,
+		Standard: time.Hour,
+		Testing:  3 * time.Second,
+	}).(time.Duration)
+
+	// DefaultMaxResumableUploadBytes is the default limit of the combined
+	// length of the unfinished resumable uploads, the user can set a custom
+	// limit through the API.
+	DefaultMaxResumableUploadBytes = build.Select(build.Var{
+		Dev:      uint64(1 << 32), // 4 GiB
+		Standard: uint64(1 << 36), // 64 GiB
+		Testing:  uint64(1 << 24), // 16 MiB
+	}).(uint64)
+)
+
 // Constants which don't fit into another category very well.
 const (
 	// defaultFilePerm defines the default permissions used for a new file if no
@@ -51,12 +51,20 @@ type fuseDirnode struct {
 //
 // NodeStatfser is necessary to provide information about the filesystem that
 // contains the directory.
+//
+// NodeCreater, NodeMkdirer, NodeUnlinker, NodeRmdirer and NodeRenamer are
+// necessary for read-write mounts.
 var _ = (fs.NodeAccesser)((*fuseDirnode)(nil))
 var _ = (fs.NodeFlusher)((*fuseDirnode)(nil))
 var _ = (fs.NodeGetattrer)((*fuseDirnode)(nil))
 var _ = (fs.NodeLookuper)((*fuseDirnode)(nil))
 var _ = (fs.NodeReaddirer)((*fuseDirnode)(nil))
 var _ = (fs.NodeStatfser)((*fuseDirnode)(nil))
+var _ = (fs.NodeCreater)((*fuseDirnode)(nil))
+var _ = (fs.NodeMkdirer)((*fuseDirnode)(nil))
+var _ = (fs.NodeUnlinker)((*fuseDirnode)(nil))
+var _ = (fs.NodeRmdirer)((*fuseDirnode)(nil))
+var _ = (fs.NodeRenamer)((*fuseDirnode)(nil))
 
 // fuseFilenode is a fuse node for the fs package that covers a siafile.
 //
@@ -67,9 +75,14 @@ type fuseFilenode struct {
 
 	fs.Inode
 	staticFilesystem *fuseFS
-	staticFileNode   *filesystem.FileNode
-	stream           modules.Streamer
-	mu               sync.Mutex
+	staticSiaPath    modules.SiaPath
+
+	// staticFileNode is nil for files that were created through the fuse
+	// filesystem and which are still waiting for their first upload to
+	// complete.
+	staticFileNode *filesystem.FileNode
+	stream         modules.Streamer
+	mu             sync.Mutex
 }
 
 // Ensure the file nodes satisfy the required interfaces.
@@ -88,18 +101,25 @@ type fuseFilenode struct {
 //
 // NodeStatfser is necessary to provide information about the filesystem that
 // contains the file.
+//
+// NodeSetattrer is necessary for truncating files in read-write mounts.
 var _ = (fs.NodeAccesser)((*fuseFilenode)(nil))
 var _ = (fs.NodeFlusher)((*fuseFilenode)(nil))
 var _ = (fs.NodeGetattrer)((*fuseFilenode)(nil))
 var _ = (fs.NodeOpener)((*fuseFilenode)(nil))
 var _ = (fs.NodeReader)((*fuseFilenode)(nil))
 var _ = (fs.NodeStatfser)((*fuseFilenode)(nil))
+var _ = (fs.NodeSetattrer)((*fuseFilenode)(nil))
 
 // fuseRoot is the root directory for a mounted fuse filesystem.
 type fuseFS struct {
 	options modules.MountOptions
 	root    *fuseDirnode
 
+	// staticCacheDir is the local directory that is used to stage writes
+	// before they are uploaded. It is empty for read-only mounts.
+	staticCacheDir string
+
 	renter *Renter
 	server *fuse.Server
 }
@@ -108,8 +128,12 @@ type fuseFS struct {
 func errToStatus(err error) syscall.Errno {
 	if err == nil {
 		return syscall.F_OK
-	} else if errors.IsOSNotExist(err) {
+	} else if errors.IsOSNotExist(err) || errors.Contains(err, filesystem.ErrNotExist) {
 		return syscall.ENOENT
+	} else if errors.Contains(err, filesystem.ErrExists) {
+		return syscall.EEXIST
+	} else if errors.Contains(err, errFuseReadOnly) {
+		return syscall.EROFS
 	}
 	return syscall.EIO
 }
@@ -140,6 +164,15 @@ func (fdn *fuseDirnode) Flush(ctx context.Context, fh fs.FileHandle) syscall.Err
 
 // Flush is called when a file is being closed.
 func (ffn *fuseFilenode) Flush(ctx context.Context, fh fs.FileHandle) syscall.Errno {
+	// Files that were opened for writing are uploaded when they are flushed.
+	if wh, ok := fh.(*fuseWriteHandle); ok {
+		err := wh.managedCommit()
+		if err != nil {
+			ffn.staticFilesystem.renter.log.Printf("error when uploading fuse file %v: %v", ffn.staticSiaPath, err)
+		}
+		return errToStatus(err)
+	}
+
 	swapped := atomic.CompareAndSwapUint32(&ffn.atomicClosed, 0, 1)
 	if !swapped {
 		return errToStatus(nil)
@@ -156,11 +189,13 @@ func (ffn *fuseFilenode) Flush(ctx context.Context, fh fs.FileHandle) syscall.Er
 	}
 
 	// Check all of the errors.
-	closeErr := ffn.staticFileNode.Close()
+	var closeErr error
+	if ffn.staticFileNode != nil {
+		closeErr = ffn.staticFileNode.Close()
+	}
 	err := errors.Compose(streamErr, closeErr)
 	if err != nil {
-		siaPath := ffn.staticFilesystem.renter.staticFileSystem.FileSiaPath(ffn.staticFileNode)
-		ffn.staticFilesystem.renter.log.Printf("error when flushing fuse file %v: %v", siaPath, err)
+		ffn.staticFilesystem.renter.log.Printf("error when flushing fuse file %v: %v", ffn.staticSiaPath, err)
 		return errToStatus(err)
 	}
 	return errToStatus(nil)
@@ -183,6 +218,7 @@ func (fdn *fuseDirnode) Lookup(ctx context.Context, name string, out *fuse.Entry
 		filenode := &fuseFilenode{
 			staticFilesystem: fdn.staticFilesystem,
 			staticFileNode:   fileNode,
+			staticSiaPath:    fdn.staticFilesystem.renter.staticFileSystem.FileSiaPath(fileNode),
 		}
 		attrs := fs.StableAttr{
 			Ino:  fileInfo.UID,
@@ -245,7 +281,19 @@ func (fdn *fuseDirnode) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse
 // Getattr should try to minimize lock contention and should run very quickly if
 // possible.
 func (ffn *fuseFilenode) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	fileInfo, err := ffn.staticFilesystem.renter.staticFileSystem.FileNodeInfo(ffn.staticFileNode)
+	// If the file is open for writing, the staged data is the most recent
+	// version of the file.
+	if wh, ok := fh.(*fuseWriteHandle); ok {
+		return wh.Getattr(ctx, out)
+	}
+
+	var fileInfo modules.FileInfo
+	var err error
+	if ffn.staticFileNode != nil {
+		fileInfo, err = ffn.staticFilesystem.renter.staticFileSystem.FileNodeInfo(ffn.staticFileNode)
+	} else {
+		fileInfo, err = ffn.staticFilesystem.renter.staticFileSystem.CachedFileInfo(ffn.staticSiaPath)
+	}
 	if err != nil {
 		ffn.staticFilesystem.renter.log.Printf("Unable to fetch info from file: %v", err)
 	}
@@ -262,13 +310,23 @@ func (ffn *fuseFilenode) Getattr(ctx context.Context, fh fs.FileHandle, out *fus
 // out from the documentation what the flags are supposed to represent. So far,
 // this has not seemed to cause problems.
 func (ffn *fuseFilenode) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	// Files that are opened for writing get a write handle which stages the
+	// data locally until the file is flushed.
+	if flags&(syscall.O_WRONLY|syscall.O_RDWR|syscall.O_TRUNC|syscall.O_APPEND) != 0 {
+		wh, err := ffn.managedOpenWriteHandle(flags&syscall.O_TRUNC != 0)
+		if err != nil {
+			ffn.staticFilesystem.renter.log.Printf("Unable to open file %v for writing: %v", ffn.staticSiaPath, err)
+			return nil, 0, errToStatus(err)
+		}
+		return wh, 0, errToStatus(nil)
+	}
+
 	ffn.mu.Lock()
 	defer ffn.mu.Unlock()
 
-	stream, err := ffn.staticFilesystem.renter.StreamerByNode(ffn.staticFileNode, false)
+	stream, err := ffn.staticStream()
 	if err != nil {
-		siaPath := ffn.staticFilesystem.renter.staticFileSystem.FileSiaPath(ffn.staticFileNode)
-		ffn.staticFilesystem.renter.log.Printf("Unable to get stream for file %v: %v", siaPath, err)
+		ffn.staticFilesystem.renter.log.Printf("Unable to get stream for file %v: %v", ffn.staticSiaPath, err)
 		return nil, 0, errToStatus(err)
 	}
 	ffn.stream = stream
@@ -277,6 +335,11 @@ func (ffn *fuseFilenode) Open(ctx context.Context, flags uint32) (fs.FileHandle,
 
 // Read will read data from the file and place it in dest.
 func (ffn *fuseFilenode) Read(ctx context.Context, f fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	// Files that are open for writing are read from the staged data.
+	if wh, ok := f.(*fuseWriteHandle); ok {
+		return wh.Read(ctx, dest, offset)
+	}
+
 	// TODO: Right now only one call to Read from a file can be in effect at
 	// once, based on the way the streamer and the read call has been
 	// implemented. As the streamer gets updated to more readily support
@@ -287,8 +350,7 @@ func (ffn *fuseFilenode) Read(ctx context.Context, f fs.FileHandle, dest []byte,
 
 	_, err := ffn.stream.Seek(offset, io.SeekStart)
 	if err != nil {
-		siaPath := ffn.staticFilesystem.renter.staticFileSystem.FileSiaPath(ffn.staticFileNode)
-		ffn.staticFilesystem.renter.log.Printf("Error seeking to offset %v during call to Read in file %s: %v", offset, siaPath.String(), err)
+		ffn.staticFilesystem.renter.log.Printf("Error seeking to offset %v during call to Read in file %s: %v", offset, ffn.staticSiaPath.String(), err)
 		return nil, errToStatus(err)
 	}
 
@@ -298,8 +360,7 @@ func (ffn *fuseFilenode) Read(ctx context.Context, f fs.FileHandle, dest []byte,
 	// often dropping parts of the tail of the file.
 	n, err := io.ReadFull(ffn.stream, dest)
 	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
-		siaPath := ffn.staticFilesystem.renter.staticFileSystem.FileSiaPath(ffn.staticFileNode)
-		ffn.staticFilesystem.renter.log.Printf("Error reading from offset %v during call to Read in file %s: %v", offset, siaPath.String(), err)
+		ffn.staticFilesystem.renter.log.Printf("Error reading from offset %v during call to Read in file %s: %v", offset, ffn.staticSiaPath.String(), err)
 		return nil, errToStatus(err)
 	}
 
@@ -402,8 +463,7 @@ func (fdn *fuseDirnode) Statfs(ctx context.Context, out *fuse.StatfsOut) syscall
 func (ffn *fuseFilenode) Statfs(ctx context.Context, out *fuse.StatfsOut) syscall.Errno {
 	err := ffn.staticFilesystem.setStatfsOut(out)
 	if err != nil {
-		siaPath := ffn.staticFilesystem.renter.staticFileSystem.FileSiaPath(ffn.staticFileNode)
-		ffn.staticFilesystem.renter.log.Printf("Error fetching statfs for fuse file %v: %v", siaPath, err)
+		ffn.staticFilesystem.renter.log.Printf("Error fetching statfs for fuse file %v: %v", ffn.staticSiaPath, err)
 		return errToStatus(err)
 	}
 	return errToStatus(nil)
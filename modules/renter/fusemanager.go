@@ -4,6 +4,8 @@ package renter
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 
 	"github.com/EvilRedHorse/pubaccess-node/modules"
@@ -99,19 +101,25 @@ func (fm *fuseManager) Mount(mountPoint string, sp modules.SiaPath, opts modules
 		}
 	}()
 
-	// Enforce read-only requirements for now.
-	if !opts.ReadOnly {
-		return errors.New("fuse must be mounted as read-only")
-	}
-
 	// Get the mountpoint's root from the filesystem.
 	rootDirNode, err := fm.renter.staticFileSystem.OpenSiaDir(sp)
 	if err != nil {
 		return errors.AddContext(err, "unable to open the mounted siapath in the filesystem")
 	}
+	// Read-write mounts stage their writes in a local cache directory before
+	// handing them to the upload streamer.
+	var cacheDir string
+	if !opts.ReadOnly {
+		cacheDir = filepath.Join(fm.renter.persistDir, fuseCacheDir)
+		err = os.MkdirAll(cacheDir, modules.DefaultDirPerm)
+		if err != nil {
+			return errors.AddContext(err, "unable to create the fuse cache directory")
+		}
+	}
 	// Create the fuse filesystem object.
 	filesystem := &fuseFS{
-		options: opts,
+		options:        opts,
+		staticCacheDir: cacheDir,
 
 		renter: fm.renter,
 	}
@@ -0,0 +1,401 @@
+// +build linux darwin
+
+package renter
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/modules/renter/filesystem"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+)
+
+var (
+	// errFuseReadOnly is returned when a write operation is attempted on a
+	// fuse filesystem that was mounted as read-only.
+	errFuseReadOnly = errors.New("fuse filesystem is mounted as read-only")
+
+	// errFuseUnknownParent is returned if the kernel asks to rename a file
+	// into a directory that doesn't belong to the fuse filesystem.
+	errFuseUnknownParent = errors.New("rename target directory is not a fuse directory")
+)
+
+const (
+	// fuseUploadSuffix is the suffix of the temporary siafiles that staged
+	// files are uploaded to before they replace the files that were written.
+	fuseUploadSuffix = ".fuseupload"
+)
+
+// fuseWriteHandle is the file handle of a fuse file that was opened for
+// writing. All writes are staged in a local file within the cache directory of
+// the mount. When the handle is flushed, the staged file is handed to the
+// upload streamer. The upload goes to a temporary siapath which replaces the
+// previous version of the file once the upload succeeded.
+type fuseWriteHandle struct {
+	dirty bool
+	file  *os.File
+
+	staticNode    *fuseFilenode
+	staticSiaPath modules.SiaPath
+	mu            sync.Mutex
+}
+
+// Ensure the write handle satisfies the required interfaces.
+//
+// FileReleaser is necessary for removing the staged data once the file is
+// closed.
+//
+// FileWriter is necessary for writing to files.
+var _ = (fs.FileReleaser)((*fuseWriteHandle)(nil))
+var _ = (fs.FileWriter)((*fuseWriteHandle)(nil))
+
+// managedCreateWriteHandle creates a new, empty staging file for the provided
+// siapath.
+func (ffs *fuseFS) managedCreateWriteHandle(node *fuseFilenode, siaPath modules.SiaPath) (*fuseWriteHandle, error) {
+	if ffs.options.ReadOnly {
+		return nil, errFuseReadOnly
+	}
+	name := filepath.Join(ffs.staticCacheDir, hex.EncodeToString(fastrand.Bytes(16)))
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, defaultFilePerm)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to create staging file")
+	}
+	// The staging file is only referenced through the open file descriptor.
+	// Removing it right away guarantees that it won't be left behind if the
+	// node crashes.
+	if err := os.Remove(name); err != nil {
+		return nil, errors.Compose(err, f.Close())
+	}
+	return &fuseWriteHandle{
+		file: f,
+
+		staticNode:    node,
+		staticSiaPath: siaPath,
+	}, nil
+}
+
+// managedOpenWriteHandle opens a write handle for an existing file. Unless
+// 'truncate' is set, the current contents of the file are downloaded into the
+// staging file first so that partial writes don't lose any data.
+func (ffn *fuseFilenode) managedOpenWriteHandle(truncate bool) (*fuseWriteHandle, error) {
+	wh, err := ffn.staticFilesystem.managedCreateWriteHandle(ffn, ffn.staticSiaPath)
+	if err != nil {
+		return nil, err
+	}
+	if truncate {
+		wh.dirty = true
+		return wh, nil
+	}
+
+	// Copy the existing file into the staging file.
+	stream, err := ffn.staticStream()
+	if err != nil {
+		return nil, errors.Compose(err, wh.file.Close())
+	}
+	_, err = io.Copy(wh.file, stream)
+	err = errors.Compose(err, stream.Close())
+	if err != nil {
+		return nil, errors.Compose(errors.AddContext(err, "unable to stage existing file"), wh.file.Close())
+	}
+	return wh, nil
+}
+
+// staticStream opens a download streamer for the file.
+func (ffn *fuseFilenode) staticStream() (modules.Streamer, error) {
+	if ffn.staticFileNode != nil {
+		return ffn.staticFilesystem.renter.StreamerByNode(ffn.staticFileNode, false)
+	}
+	_, stream, err := ffn.staticFilesystem.renter.Streamer(ffn.staticSiaPath, false)
+	return stream, err
+}
+
+// Getattr sets the attributes of the staged file.
+func (wh *fuseWriteHandle) Getattr(ctx context.Context, out *fuse.AttrOut) syscall.Errno {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	fi, err := wh.file.Stat()
+	if err != nil {
+		return errToStatus(err)
+	}
+	out.Size = uint64(fi.Size())
+	out.Mode = uint32(defaultFilePerm) | syscall.S_IFREG
+	out.Ino = wh.staticNode.StableAttr().Ino
+	return errToStatus(nil)
+}
+
+// Read reads staged data from the write handle.
+func (wh *fuseWriteHandle) Read(ctx context.Context, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	n, err := wh.file.ReadAt(dest, offset)
+	if err != nil && err != io.EOF {
+		return nil, errToStatus(err)
+	}
+	return fuse.ReadResultData(dest[:n]), errToStatus(nil)
+}
+
+// Release closes the staging file.
+func (wh *fuseWriteHandle) Release(ctx context.Context) syscall.Errno {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	return errToStatus(wh.file.Close())
+}
+
+// Write writes data to the staging file.
+func (wh *fuseWriteHandle) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	n, err := wh.file.WriteAt(data, off)
+	if n > 0 {
+		wh.dirty = true
+	}
+	return uint32(n), errToStatus(err)
+}
+
+// managedTruncate changes the size of the staged file.
+func (wh *fuseWriteHandle) managedTruncate(size uint64) error {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	wh.dirty = true
+	return wh.file.Truncate(int64(size))
+}
+
+// managedCommit uploads the staged file if it was modified since the last
+// commit. The previous version of the file is only replaced after the upload
+// succeeded.
+func (wh *fuseWriteHandle) managedCommit() error {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	if !wh.dirty {
+		return nil
+	}
+	_, err := wh.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return errors.AddContext(err, "unable to seek to the start of the staging file")
+	}
+
+	// Upload the staged file next to the file it replaces.
+	r := wh.staticNode.staticFilesystem.renter
+	dirSiaPath, err := wh.staticSiaPath.Dir()
+	if err != nil {
+		return errors.AddContext(err, "unable to get the directory of the file")
+	}
+	uploadSiaPath, err := dirSiaPath.Join("." + wh.staticSiaPath.Name() + "." + hex.EncodeToString(fastrand.Bytes(8)) + fuseUploadSuffix)
+	if err != nil {
+		return errors.AddContext(err, "unable to create a temporary siapath")
+	}
+	up := modules.FileUploadParams{
+		SiaPath: uploadSiaPath,
+	}
+	err = r.UploadStreamFromReader(up, wh.file)
+	if err != nil {
+		deleteErr := r.DeleteFile(uploadSiaPath)
+		if deleteErr != nil && !errors.Contains(deleteErr, filesystem.ErrNotExist) {
+			err = errors.Compose(err, deleteErr)
+		}
+		return errors.AddContext(err, "unable to upload staged file")
+	}
+
+	// Replace the previous version of the file.
+	err = r.DeleteFile(wh.staticSiaPath)
+	if err != nil && !errors.Contains(err, filesystem.ErrNotExist) {
+		return errors.Compose(errors.AddContext(err, "unable to delete the previous version of the file"), r.DeleteFile(uploadSiaPath))
+	}
+	err = r.RenameFile(uploadSiaPath, wh.staticSiaPath)
+	if err != nil {
+		return errors.AddContext(err, fmt.Sprintf("unable to rename the uploaded file, it is stored at %v", uploadSiaPath))
+	}
+	wh.dirty = false
+	return nil
+}
+
+// Setattr is called to change the attributes of a file. Only size changes are
+// supported, all other attributes are managed by the renter.
+func (ffn *fuseFilenode) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	size, ok := in.GetSize()
+	if !ok {
+		return ffn.Getattr(ctx, fh, out)
+	}
+
+	// If the file is already open for writing the truncate can be applied to
+	// the staged file directly. Otherwise the file is opened, truncated and
+	// uploaded right away.
+	wh, isWriteHandle := fh.(*fuseWriteHandle)
+	if !isWriteHandle {
+		var err error
+		wh, err = ffn.managedOpenWriteHandle(size == 0)
+		if err != nil {
+			ffn.staticFilesystem.renter.log.Printf("Unable to open fuse file %v for truncation: %v", ffn.staticSiaPath, err)
+			return errToStatus(err)
+		}
+		defer wh.Release(ctx)
+	}
+	err := wh.managedTruncate(size)
+	if err == nil && !isWriteHandle {
+		err = wh.managedCommit()
+	}
+	if err != nil {
+		ffn.staticFilesystem.renter.log.Printf("Unable to truncate fuse file %v: %v", ffn.staticSiaPath, err)
+		return errToStatus(err)
+	}
+	return wh.Getattr(ctx, out)
+}
+
+// childSiaPath returns the siapath of the child with the provided name.
+func (fdn *fuseDirnode) childSiaPath(name string) (modules.SiaPath, error) {
+	return fdn.staticFilesystem.renter.staticFileSystem.DirSiaPath(fdn.staticDirNode).Join(name)
+}
+
+// Create creates a new file in the directory and opens it for writing. The
+// file will not be visible to the renter until it is flushed for the first
+// time.
+func (fdn *fuseDirnode) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
+	siaPath, err := fdn.childSiaPath(name)
+	if err != nil {
+		return nil, nil, 0, errToStatus(err)
+	}
+	filenode := &fuseFilenode{
+		staticFilesystem: fdn.staticFilesystem,
+		staticSiaPath:    siaPath,
+	}
+	wh, err := fdn.staticFilesystem.managedCreateWriteHandle(filenode, siaPath)
+	if err != nil {
+		fdn.staticFilesystem.renter.log.Printf("Unable to create fuse file %v: %v", siaPath, err)
+		return nil, nil, 0, errToStatus(err)
+	}
+	// Make sure that an empty file is uploaded even if nothing is written.
+	wh.dirty = true
+
+	// The siafile doesn't exist yet, so the inode number is left to fuse.
+	attrs := fs.StableAttr{
+		Mode: fuse.S_IFREG,
+	}
+	inode := fdn.NewInode(ctx, filenode, attrs)
+	out.Ino = inode.StableAttr().Ino
+	out.Mode = uint32(defaultFilePerm) | syscall.S_IFREG
+	return inode, wh, 0, errToStatus(nil)
+}
+
+// Mkdir creates a new directory.
+func (fdn *fuseDirnode) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if fdn.staticFilesystem.options.ReadOnly {
+		return nil, errToStatus(errFuseReadOnly)
+	}
+	siaPath, err := fdn.childSiaPath(name)
+	if err != nil {
+		return nil, errToStatus(err)
+	}
+	err = fdn.staticFilesystem.renter.CreateDir(siaPath, os.FileMode(mode))
+	if err != nil {
+		fdn.staticFilesystem.renter.log.Printf("Unable to create fuse dir %v: %v", siaPath, err)
+		return nil, errToStatus(err)
+	}
+	childDir, err := fdn.staticDirNode.Dir(name)
+	if err != nil {
+		return nil, errToStatus(err)
+	}
+	dirInfo, err := fdn.staticFilesystem.renter.staticFileSystem.DirNodeInfo(childDir)
+	if err != nil {
+		return nil, errToStatus(errors.Compose(err, childDir.Close()))
+	}
+	dirnode := &fuseDirnode{
+		staticDirNode:    childDir,
+		staticFilesystem: fdn.staticFilesystem,
+	}
+	attrs := fs.StableAttr{
+		Ino:  dirInfo.UID,
+		Mode: fuse.S_IFDIR,
+	}
+	out.Ino = dirInfo.UID
+	out.Mode = uint32(dirInfo.Mode())
+	return fdn.NewInode(ctx, dirnode, attrs), errToStatus(nil)
+}
+
+// Unlink deletes a file from the directory.
+func (fdn *fuseDirnode) Unlink(ctx context.Context, name string) syscall.Errno {
+	if fdn.staticFilesystem.options.ReadOnly {
+		return errToStatus(errFuseReadOnly)
+	}
+	siaPath, err := fdn.childSiaPath(name)
+	if err != nil {
+		return errToStatus(err)
+	}
+	err = fdn.staticFilesystem.renter.DeleteFile(siaPath)
+	if err != nil {
+		fdn.staticFilesystem.renter.log.Printf("Unable to delete fuse file %v: %v", siaPath, err)
+	}
+	return errToStatus(err)
+}
+
+// Rmdir deletes an empty directory.
+func (fdn *fuseDirnode) Rmdir(ctx context.Context, name string) syscall.Errno {
+	if fdn.staticFilesystem.options.ReadOnly {
+		return errToStatus(errFuseReadOnly)
+	}
+	siaPath, err := fdn.childSiaPath(name)
+	if err != nil {
+		return errToStatus(err)
+	}
+	// DeleteDir deletes recursively, rmdir is only allowed to remove empty
+	// directories.
+	fileinfos, dirinfos, err := fdn.staticFilesystem.renter.staticFileSystem.CachedList(siaPath, false)
+	if err != nil {
+		return errToStatus(err)
+	}
+	if len(fileinfos) > 0 || len(dirinfos) > 1 {
+		return syscall.ENOTEMPTY
+	}
+	err = fdn.staticFilesystem.renter.DeleteDir(siaPath)
+	if err != nil {
+		fdn.staticFilesystem.renter.log.Printf("Unable to delete fuse dir %v: %v", siaPath, err)
+	}
+	return errToStatus(err)
+}
+
+// Rename moves a file or directory to a new name, possibly in a different
+// directory. Existing files at the destination are replaced.
+func (fdn *fuseDirnode) Rename(ctx context.Context, name string, newParent fs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	if fdn.staticFilesystem.options.ReadOnly {
+		return errToStatus(errFuseReadOnly)
+	}
+	newDir, ok := newParent.(*fuseDirnode)
+	if !ok {
+		return errToStatus(errFuseUnknownParent)
+	}
+	oldSiaPath, err := fdn.childSiaPath(name)
+	if err != nil {
+		return errToStatus(err)
+	}
+	newSiaPath, err := newDir.childSiaPath(newName)
+	if err != nil {
+		return errToStatus(err)
+	}
+
+	r := fdn.staticFilesystem.renter
+	_, err = r.staticFileSystem.CachedFileInfo(oldSiaPath)
+	if err == nil {
+		// Replace an existing file at the destination, like rename(2) does.
+		err = r.DeleteFile(newSiaPath)
+		if err != nil && !errors.Contains(err, filesystem.ErrNotExist) {
+			return errToStatus(err)
+		}
+		err = r.RenameFile(oldSiaPath, newSiaPath)
+	} else {
+		err = r.RenameDir(oldSiaPath, newSiaPath)
+	}
+	if err != nil {
+		r.log.Printf("Unable to rename fuse path %v to %v: %v", oldSiaPath, newSiaPath, err)
+	}
+	return errToStatus(err)
+}
@@ -25,6 +25,12 @@ const (
 	SiaDirMetadata = ".siadir"
 	// walFile is the filename of the renter's writeaheadlog's file.
 	walFile = modules.RenterDir + ".wal"
+	// fuseCacheDir is the name of the directory in which read-write fuse
+	// mounts stage their writes.
+	fuseCacheDir = "fusecache"
+	// skyfileSpoolDir is the name of the directory in which pubfile uploads
+	// are spooled before they are uploaded.
+	skyfileSpoolDir = "pubaccessspool"
 )
 
 var (
@@ -61,6 +67,8 @@ type (
 		MaxUploadSpeed   int64
 		UploadedBackups  []modules.UploadedBackup
 		SyncedContracts  []types.FileContractID
+
+		MaxResumableUploadBytes uint64
 	}
 )
 
@@ -77,6 +85,7 @@ func (r *Renter) managedLoadSettings() error {
 		// No persistence yet, set the defaults and continue.
 		r.persist.MaxDownloadSpeed = DefaultMaxDownloadSpeed
 		r.persist.MaxUploadSpeed = DefaultMaxUploadSpeed
+		r.persist.MaxResumableUploadBytes = DefaultMaxResumableUploadBytes
 		id := r.mu.Lock()
 		err = r.saveSync()
 		r.mu.Unlock(id)
@@ -109,6 +118,12 @@ func (r *Renter) managedLoadSettings() error {
 		return err
 	}
 
+	// Persistence from before the resumable upload limit was added uses the
+	// default limit.
+	if r.persist.MaxResumableUploadBytes == 0 {
+		r.persist.MaxResumableUploadBytes = DefaultMaxResumableUploadBytes
+	}
+
 	// Set the bandwidth limits on the contractor, which was already initialized
 	// without bandwidth limits.
 	return r.setBandwidthLimits(r.persist.MaxDownloadSpeed, r.persist.MaxUploadSpeed)
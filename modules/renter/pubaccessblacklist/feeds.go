@@ -0,0 +1,330 @@
+package pubaccessblacklist
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/persist"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+)
+
+const (
+	// feedsPersistFile is the name of the file that persists the subscribed
+	// blacklist feeds together with the hashes they last provided.
+	feedsPersistFile string = "pubaccessblacklistfeeds.json"
+
+	// maxFeedSize is the maximum size of a blacklist feed that is downloaded.
+	maxFeedSize = 1 << 26 // 64 MiB
+)
+
+var (
+	// ErrInvalidFeedSignature is returned if the signature of a blacklist feed
+	// does not match the public key of the subscription.
+	ErrInvalidFeedSignature = errors.New("blacklist feed has an invalid signature")
+
+	// ErrStaleFeedRevision is returned if a blacklist feed has a lower
+	// revision than the revision that was last applied.
+	ErrStaleFeedRevision = errors.New("blacklist feed revision is lower than the current revision")
+
+	// ErrSubscriptionExists is returned when subscribing to a feed URL twice.
+	ErrSubscriptionExists = errors.New("already subscribed to blacklist feed")
+
+	// ErrUnknownSubscription is returned when unsubscribing from a feed URL
+	// which is not subscribed to.
+	ErrUnknownSubscription = errors.New("not subscribed to blacklist feed")
+
+	// feedSpecifier is the specifier that is part of the signed hash of a
+	// feed. It prevents signatures of other objects to be used as a feed
+	// signature.
+	feedSpecifier = types.NewSpecifier("BlacklistFeed")
+
+	// feedsMetadata is the metadata of the feeds persist file.
+	feedsMetadata = persist.Metadata{
+		Header:  "Pubaccess Blacklist Feeds",
+		Version: "1.5.0",
+	}
+)
+
+type (
+	// Feed is a signed list of hashed merkleroots that a portal operator
+	// publishes so that other portals can subscribe to it. Only the hashes of
+	// the merkleroots are shared, so a feed doesn't reveal the publinks it
+	// blacklists.
+	Feed struct {
+		Revision  uint64        `json:"revision"`
+		Hashes    []crypto.Hash `json:"hashes"`
+		Signature string        `json:"signature"` // hex encoded ed25519 signature
+	}
+
+	// feed is a subscription to a feed together with the hashes that the
+	// feed provided when it was last applied.
+	feed struct {
+		modules.SkynetBlacklistSubscription
+		hashes map[crypto.Hash]struct{}
+	}
+
+	// feedsPersist is the persisted state of the subscribed feeds.
+	feedsPersist struct {
+		Feeds []persistFeed `json:"feeds"`
+	}
+
+	// persistFeed is the persisted state of a single subscribed feed.
+	persistFeed struct {
+		Subscription modules.SkynetBlacklistSubscription `json:"subscription"`
+		Hashes       []crypto.Hash                       `json:"hashes"`
+	}
+)
+
+// NewFeed creates a feed of the given hashes which is signed with the secret
+// key.
+func NewFeed(revision uint64, hashes []crypto.Hash, sk crypto.SecretKey) Feed {
+	f := Feed{
+		Revision: revision,
+		Hashes:   hashes,
+	}
+	sig := crypto.SignHash(f.sigHash(), sk)
+	f.Signature = hex.EncodeToString(sig[:])
+	return f
+}
+
+// FetchFeed downloads the feed at the given URL.
+func FetchFeed(ctx context.Context, client *http.Client, feedURL string) (Feed, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return Feed{}, errors.AddContext(err, "unable to create blacklist feed request")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Feed{}, errors.AddContext(err, "unable to fetch blacklist feed")
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return Feed{}, fmt.Errorf("unable to fetch blacklist feed: unexpected status %v", resp.Status)
+	}
+	var f Feed
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxFeedSize)).Decode(&f)
+	if err != nil {
+		return Feed{}, errors.AddContext(err, "unable to decode blacklist feed")
+	}
+	return f, nil
+}
+
+// sigHash returns the hash of the feed which is signed.
+func (f Feed) sigHash() crypto.Hash {
+	return crypto.HashAll(feedSpecifier, f.Revision, f.Hashes)
+}
+
+// Verify checks that the feed was signed by the given public key.
+func (f Feed) Verify(spk types.SiaPublicKey) error {
+	if err := validatePublicKey(spk); err != nil {
+		return err
+	}
+	b, err := hex.DecodeString(f.Signature)
+	if err != nil || len(b) != crypto.SignatureSize {
+		return ErrInvalidFeedSignature
+	}
+	var sig crypto.Signature
+	copy(sig[:], b)
+	if crypto.VerifyHash(f.sigHash(), spk.ToPublicKey(), sig) != nil {
+		return ErrInvalidFeedSignature
+	}
+	return nil
+}
+
+// validatePublicKey checks that the public key can be used to verify feeds.
+func validatePublicKey(spk types.SiaPublicKey) error {
+	if spk.Algorithm != types.SignatureEd25519 || len(spk.Key) != crypto.PublicKeySize {
+		return errors.New("blacklist feeds require an ed25519 public key")
+	}
+	return nil
+}
+
+// validateFeedURL checks that the URL of a feed can be fetched.
+func validateFeedURL(feedURL string) error {
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return errors.AddContext(err, "invalid blacklist feed url")
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid blacklist feed url '%v': expected an http or https url", feedURL)
+	}
+	return nil
+}
+
+// loadFeeds loads the subscribed feeds from disk.
+func loadFeeds(persistDir string) (map[string]*feed, error) {
+	var fp feedsPersist
+	err := persist.LoadJSON(feedsMetadata, &fp, filepath.Join(persistDir, feedsPersistFile))
+	if os.IsNotExist(err) {
+		return make(map[string]*feed), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	feeds := make(map[string]*feed, len(fp.Feeds))
+	for _, pf := range fp.Feeds {
+		f := &feed{
+			SkynetBlacklistSubscription: pf.Subscription,
+			hashes:                      make(map[crypto.Hash]struct{}, len(pf.Hashes)),
+		}
+		for _, hash := range pf.Hashes {
+			f.hashes[hash] = struct{}{}
+		}
+		feeds[pf.Subscription.URL] = f
+	}
+	return feeds, nil
+}
+
+// saveFeeds persists the subscribed feeds.
+//
+// NOTE: the caller has to hold the lock of the blacklist.
+func (sb *SkynetBlacklist) saveFeeds() error {
+	fp := feedsPersist{
+		Feeds: make([]persistFeed, 0, len(sb.feeds)),
+	}
+	for _, f := range sb.feeds {
+		pf := persistFeed{
+			Subscription: f.SkynetBlacklistSubscription,
+			Hashes:       make([]crypto.Hash, 0, len(f.hashes)),
+		}
+		for hash := range f.hashes {
+			pf.Hashes = append(pf.Hashes, hash)
+		}
+		fp.Feeds = append(fp.Feeds, pf)
+	}
+	err := persist.SaveJSON(feedsMetadata, fp, filepath.Join(sb.staticPersistDir, feedsPersistFile))
+	return errors.AddContext(err, "unable to persist pubaccess blacklist feeds")
+}
+
+// Subscriptions returns the subscribed blacklist feeds.
+func (sb *SkynetBlacklist) Subscriptions() []modules.SkynetBlacklistSubscription {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	subs := make([]modules.SkynetBlacklistSubscription, 0, len(sb.feeds))
+	for _, f := range sb.feeds {
+		subs = append(subs, f.SkynetBlacklistSubscription)
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].URL < subs[j].URL
+	})
+	return subs
+}
+
+// UpdateSubscriptions subscribes to the feeds of the additions and
+// unsubscribes from the feeds with the URLs of the removals. The entries of
+// removed feeds are removed from the blacklist. Added feeds don't blacklist
+// anything until they are fetched by UpdateFeeds.
+func (sb *SkynetBlacklist) UpdateSubscriptions(additions []modules.SkynetBlacklistSubscription, removals []string) error {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	// Validate the changes before making any.
+	removed := make(map[string]struct{}, len(removals))
+	for _, feedURL := range removals {
+		if _, exists := sb.feeds[feedURL]; !exists {
+			return errors.AddContext(ErrUnknownSubscription, feedURL)
+		}
+		removed[feedURL] = struct{}{}
+	}
+	added := make(map[string]struct{}, len(additions))
+	for _, sub := range additions {
+		if err := validateFeedURL(sub.URL); err != nil {
+			return err
+		}
+		if err := validatePublicKey(sub.PublicKey); err != nil {
+			return err
+		}
+		_, exists := sb.feeds[sub.URL]
+		_, isRemoved := removed[sub.URL]
+		_, isAdded := added[sub.URL]
+		if (exists && !isRemoved) || isAdded {
+			return errors.AddContext(ErrSubscriptionExists, sub.URL)
+		}
+		added[sub.URL] = struct{}{}
+	}
+
+	for feedURL := range removed {
+		delete(sb.feeds, feedURL)
+	}
+	for _, sub := range additions {
+		sb.feeds[sub.URL] = &feed{
+			SkynetBlacklistSubscription: modules.SkynetBlacklistSubscription{
+				URL:       sub.URL,
+				PublicKey: sub.PublicKey,
+			},
+			hashes: make(map[crypto.Hash]struct{}),
+		}
+	}
+	return sb.saveFeeds()
+}
+
+// UpdateFeeds fetches all subscribed feeds using the fetch function and
+// replaces the entries of every feed with a valid signature and a revision
+// that is not lower than the applied one. Errors of individual feeds are recorded in their subscription.
+func (sb *SkynetBlacklist) UpdateFeeds(fetch func(feedURL string) (Feed, error)) error {
+	subs := sb.Subscriptions()
+	if len(subs) == 0 {
+		return nil
+	}
+	for _, sub := range subs {
+		// Fetch the feed without holding the lock.
+		f, err := fetch(sub.URL)
+
+		sb.mu.Lock()
+		sb.applyFeed(sub, f, err)
+		sb.mu.Unlock()
+	}
+
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.saveFeeds()
+}
+
+// applyFeed replaces the entries of the subscription with the hashes of the
+// fetched feed, or records the error why that is not possible.
+//
+// NOTE: the caller has to hold the lock of the blacklist.
+func (sb *SkynetBlacklist) applyFeed(sub modules.SkynetBlacklistSubscription, f Feed, fetchErr error) {
+	current, exists := sb.feeds[sub.URL]
+	if !exists || !current.PublicKey.Equals(sub.PublicKey) {
+		// The subscription changed while the feed was fetched.
+		return
+	}
+	current.LastUpdate = time.Now()
+
+	err := fetchErr
+	if err == nil {
+		err = f.Verify(current.PublicKey)
+	}
+	if err == nil && f.Revision < current.Revision {
+		err = ErrStaleFeedRevision
+	}
+	if err != nil {
+		current.LastError = err.Error()
+		return
+	}
+
+	current.hashes = make(map[crypto.Hash]struct{}, len(f.Hashes))
+	for _, hash := range f.Hashes {
+		current.hashes[hash] = struct{}{}
+	}
+	current.Revision = f.Revision
+	current.NumEntries = uint64(len(current.hashes))
+	current.LastError = ""
+}
@@ -0,0 +1,214 @@
+package pubaccessblacklist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+)
+
+// TestFeedVerify tests the signing and verification of blacklist feeds.
+func TestFeedVerify(t *testing.T) {
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	hashes := []crypto.Hash{crypto.HashObject("a"), crypto.HashObject("b")}
+
+	f := NewFeed(1, hashes, sk)
+	if err := f.Verify(spk); err != nil {
+		t.Fatal(err)
+	}
+
+	// A modified feed is rejected.
+	modified := f
+	modified.Revision++
+	if err := modified.Verify(spk); !errors.Contains(err, ErrInvalidFeedSignature) {
+		t.Fatal("expected modified feed to be rejected but got", err)
+	}
+	modified = f
+	modified.Hashes = hashes[:1]
+	if err := modified.Verify(spk); !errors.Contains(err, ErrInvalidFeedSignature) {
+		t.Fatal("expected modified feed to be rejected but got", err)
+	}
+
+	// A feed signed by another key is rejected.
+	_, pk2 := crypto.GenerateKeyPair()
+	if err := f.Verify(types.Ed25519PublicKey(pk2)); !errors.Contains(err, ErrInvalidFeedSignature) {
+		t.Fatal("expected feed to be rejected but got", err)
+	}
+}
+
+// TestFeeds tests subscribing to blacklist feeds and merging their entries
+// with the local blacklist.
+func TestFeeds(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	testdir := testDir(t.Name())
+	sb, err := New(testdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	url1 := "https://example.com/feed1"
+	url2 := "https://example.com/feed2"
+
+	// Invalid subscriptions are rejected.
+	err = sb.UpdateSubscriptions([]modules.SkynetBlacklistSubscription{{URL: "ftp://example.com", PublicKey: spk}}, nil)
+	if err == nil {
+		t.Fatal("expected invalid url to be rejected")
+	}
+	err = sb.UpdateSubscriptions([]modules.SkynetBlacklistSubscription{{URL: url1}}, nil)
+	if err == nil {
+		t.Fatal("expected invalid public key to be rejected")
+	}
+	err = sb.UpdateSubscriptions(nil, []string{url1})
+	if !errors.Contains(err, ErrUnknownSubscription) {
+		t.Fatal("expected unknown subscription error but got", err)
+	}
+
+	// Subscribe to two feeds.
+	subs := []modules.SkynetBlacklistSubscription{{URL: url1, PublicKey: spk}, {URL: url2, PublicKey: spk}}
+	if err := sb.UpdateSubscriptions(subs, nil); err != nil {
+		t.Fatal(err)
+	}
+	err = sb.UpdateSubscriptions(subs[:1], nil)
+	if !errors.Contains(err, ErrSubscriptionExists) {
+		t.Fatal("expected duplicate subscription error but got", err)
+	}
+
+	// Blacklist a publink locally by its hash.
+	var publink modules.Publink
+	shared := crypto.HashObject(publink.MerkleRoot())
+	if err := sb.UpdateBlacklistHashes([]crypto.Hash{shared}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Update the feeds. The first feed shares a hash with the local blacklist,
+	// the second feed has an invalid signature.
+	remote := crypto.HashObject("remote")
+	feeds := map[string]Feed{
+		url1: NewFeed(2, []crypto.Hash{shared, remote}, sk),
+		url2: {Revision: 1, Hashes: []crypto.Hash{crypto.HashObject("invalid")}},
+	}
+	fetch := func(feedURL string) (Feed, error) {
+		return feeds[feedURL], nil
+	}
+	if err := sb.UpdateFeeds(fetch); err != nil {
+		t.Fatal(err)
+	}
+	expected := []modules.SkynetBlacklistEntry{
+		{Hash: shared, Sources: []string{modules.SkynetBlacklistSourceLocal, url1}},
+		{Hash: remote, Sources: []string{url1}},
+	}
+	if bytesLess(remote, shared) {
+		expected[0], expected[1] = expected[1], expected[0]
+	}
+	if entries := sb.Entries(); !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("unexpected entries\n%v\n%v", entries, expected)
+	}
+	if len(sb.Blacklist()) != 2 {
+		t.Fatal("expected 2 blacklisted hashes but got", len(sb.Blacklist()))
+	}
+	subs = sb.Subscriptions()
+	if subs[0].Revision != 2 || subs[0].NumEntries != 2 || subs[0].LastError != "" {
+		t.Fatalf("unexpected subscription %+v", subs[0])
+	}
+	if subs[1].Revision != 0 || subs[1].LastError != ErrInvalidFeedSignature.Error() {
+		t.Fatalf("unexpected subscription %+v", subs[1])
+	}
+
+	// Removing the hash from the local blacklist keeps it blacklisted by the
+	// feed.
+	if err := sb.UpdateBlacklist(nil, []modules.Publink{publink}); err != nil {
+		t.Fatal(err)
+	}
+	if !sb.IsBlacklisted(publink) {
+		t.Fatal("expected publink to be blacklisted by the feed")
+	}
+
+	// A feed with an older revision is not applied.
+	feeds[url1] = NewFeed(1, nil, sk)
+	if err := sb.UpdateFeeds(fetch); err != nil {
+		t.Fatal(err)
+	}
+	if !sb.IsBlacklisted(publink) {
+		t.Fatal("expected publink to still be blacklisted")
+	}
+	if sb.Subscriptions()[0].LastError != ErrStaleFeedRevision.Error() {
+		t.Fatal("expected stale revision error but got", sb.Subscriptions()[0].LastError)
+	}
+
+	// The feeds are persisted.
+	if err := sb.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sb, err = New(testdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sb.IsBlacklisted(publink) {
+		t.Fatal("expected publink to be blacklisted after reloading")
+	}
+	if len(sb.Subscriptions()) != 2 {
+		t.Fatal("expected 2 subscriptions but got", len(sb.Subscriptions()))
+	}
+
+	// Unsubscribing removes the entries of the feed.
+	if err := sb.UpdateSubscriptions(nil, []string{url1}); err != nil {
+		t.Fatal(err)
+	}
+	if sb.IsBlacklisted(publink) {
+		t.Fatal("expected publink to not be blacklisted after unsubscribing")
+	}
+	if len(sb.Entries()) != 0 {
+		t.Fatal("expected no entries but got", sb.Entries())
+	}
+	if err := sb.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestFetchFeed tests fetching a feed from a server.
+func TestFetchFeed(t *testing.T) {
+	sk, pk := crypto.GenerateKeyPair()
+	f := NewFeed(3, []crypto.Hash{crypto.HashObject("a")}, sk)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/feed" {
+			http.NotFound(w, req)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f)
+	}))
+	defer server.Close()
+
+	fetched, err := FetchFeed(context.Background(), server.Client(), server.URL+"/feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fetched, f) {
+		t.Fatalf("fetched feed doesn't match\n%v\n%v", fetched, f)
+	}
+	if err := fetched.Verify(types.Ed25519PublicKey(pk)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FetchFeed(context.Background(), server.Client(), server.URL+"/missing"); err == nil {
+		t.Fatal("expected fetching a missing feed to fail")
+	}
+}
+
+// bytesLess returns whether the first hash sorts before the second.
+func bytesLess(a, b crypto.Hash) bool {
+	return a.String() < b.String()
+}
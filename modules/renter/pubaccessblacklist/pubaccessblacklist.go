@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"sort"
 	"sync"
 
 	"github.com/EvilRedHorse/pubaccess-node/crypto"
@@ -37,13 +38,19 @@ var (
 
 type (
 	// SkynetBlacklist manages a set of blacklisted publinks by tracking the
-	// merkleroots and persists the list to disk.
+	// merkleroots and persists the list to disk. Besides the merkleroots that
+	// are blacklisted locally, the blacklist contains the merkleroots of the
+	// subscribed remote blacklist feeds.
 	SkynetBlacklist struct {
-		staticAop *persist.AppendOnlyPersist
+		staticAop        *persist.AppendOnlyPersist
+		staticPersistDir string
 
 		// hashes is a set of hashed blacklisted merkleroots.
 		hashes map[crypto.Hash]struct{}
 
+		// feeds are the subscribed blacklist feeds by URL.
+		feeds map[string]*feed
+
 		mu sync.Mutex
 	}
 
@@ -64,7 +71,8 @@ func New(persistDir string) (*SkynetBlacklist, error) {
 	}
 
 	sb := &SkynetBlacklist{
-		staticAop: aop,
+		staticAop:        aop,
+		staticPersistDir: persistDir,
 	}
 	hashes, err := unmarshalObjects(reader)
 	if err != nil {
@@ -73,6 +81,14 @@ func New(persistDir string) (*SkynetBlacklist, error) {
 	}
 	sb.hashes = hashes
 
+	// Load the subscribed feeds.
+	feeds, err := loadFeeds(persistDir)
+	if err != nil {
+		err = errors.Compose(err, aop.Close())
+		return nil, errors.AddContext(err, "unable to load the pubaccess blacklist feeds")
+	}
+	sb.feeds = feeds
+
 	return sb, nil
 }
 
@@ -85,7 +101,46 @@ func (sb *SkynetBlacklist) Blacklist() []crypto.Hash {
 	for hash := range sb.hashes {
 		blacklist = append(blacklist, hash)
 	}
-	return blacklist
+	for _, f := range sb.feeds {
+		for hash := range f.hashes {
+			blacklist = append(blacklist, hash)
+		}
+	}
+	return removeDuplicateHashes(blacklist)
+}
+
+// Entries returns the blacklisted hashes of merkleroots together with the
+// sources that blacklisted them. The local blacklist is listed as the first
+// source, followed by the URLs of the feeds.
+func (sb *SkynetBlacklist) Entries() []modules.SkynetBlacklistEntry {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	sources := make(map[crypto.Hash][]string)
+	for hash := range sb.hashes {
+		sources[hash] = append(sources[hash], modules.SkynetBlacklistSourceLocal)
+	}
+	feedURLs := make([]string, 0, len(sb.feeds))
+	for feedURL := range sb.feeds {
+		feedURLs = append(feedURLs, feedURL)
+	}
+	sort.Strings(feedURLs)
+	for _, feedURL := range feedURLs {
+		for hash := range sb.feeds[feedURL].hashes {
+			sources[hash] = append(sources[hash], feedURL)
+		}
+	}
+	entries := make([]modules.SkynetBlacklistEntry, 0, len(sources))
+	for hash, srcs := range sources {
+		entries = append(entries, modules.SkynetBlacklistEntry{
+			Hash:    hash,
+			Sources: srcs,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].Hash[:], entries[j].Hash[:]) < 0
+	})
+	return entries
 }
 
 // Close closes and frees associated resources.
@@ -98,12 +153,35 @@ func (sb *SkynetBlacklist) IsBlacklisted(publink modules.Publink) bool {
 	sb.mu.Lock()
 	defer sb.mu.Unlock()
 	hash := crypto.HashObject(publink.MerkleRoot())
+	if sb.isLocallyBlacklisted(hash) {
+		return true
+	}
+	for _, f := range sb.feeds {
+		if _, ok := f.hashes[hash]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocallyBlacklisted indicates if a hash was added to the local blacklist.
+//
+// NOTE: the caller has to hold the lock of the blacklist.
+func (sb *SkynetBlacklist) isLocallyBlacklisted(hash crypto.Hash) bool {
 	_, ok := sb.hashes[hash]
 	return ok
 }
 
 // UpdateBlacklist updates the list of publinks that are blacklisted.
 func (sb *SkynetBlacklist) UpdateBlacklist(additions, removals []modules.Publink) error {
+	return sb.UpdateBlacklistHashes(hashPublinks(additions), hashPublinks(removals))
+}
+
+// UpdateBlacklistHashes updates the list of hashed merkleroots that are
+// blacklisted. This allows for blacklisting publinks without knowing them.
+// Removals only affect the local blacklist, hashes of subscribed feeds remain
+// blacklisted.
+func (sb *SkynetBlacklist) UpdateBlacklistHashes(additions, removals []crypto.Hash) error {
 	sb.mu.Lock()
 	defer sb.mu.Unlock()
 
@@ -118,14 +196,13 @@ func (sb *SkynetBlacklist) UpdateBlacklist(additions, removals []modules.Publink
 // marshalObjects marshals the given objects into a byte buffer.
 //
 // NOTE: this method does not check for duplicate additions or removals
-func (sb *SkynetBlacklist) marshalObjects(additions, removals []modules.Publink) (bytes.Buffer, error) {
+func (sb *SkynetBlacklist) marshalObjects(additions, removals []crypto.Hash) (bytes.Buffer, error) {
 	// Create buffer for encoder
 	var buf bytes.Buffer
 	// Create and encode the persist links
 	listed := true
-	for _, publink := range additions {
-		// Add publink merkleroot to map
-		hash := crypto.HashObject(publink.MerkleRoot())
+	for _, hash := range additions {
+		// Add hashed merkleroot to map
 		sb.hashes[hash] = struct{}{}
 
 		// Marshal the update
@@ -134,9 +211,8 @@ func (sb *SkynetBlacklist) marshalObjects(additions, removals []modules.Publink)
 		buf.Write(bytes)
 	}
 	listed = false
-	for _, publink := range removals {
-		// Remove publink merkleroot from map
-		hash := crypto.HashObject(publink.MerkleRoot())
+	for _, hash := range removals {
+		// Remove hashed merkleroot from map
 		delete(sb.hashes, hash)
 
 		// Marshal the update
@@ -148,6 +224,29 @@ func (sb *SkynetBlacklist) marshalObjects(additions, removals []modules.Publink)
 	return buf, nil
 }
 
+// hashPublinks returns the hashes of the merkleroots of the publinks.
+func hashPublinks(publinks []modules.Publink) []crypto.Hash {
+	hashes := make([]crypto.Hash, 0, len(publinks))
+	for _, publink := range publinks {
+		hashes = append(hashes, crypto.HashObject(publink.MerkleRoot()))
+	}
+	return hashes
+}
+
+// removeDuplicateHashes removes duplicate hashes from the slice.
+func removeDuplicateHashes(hashes []crypto.Hash) []crypto.Hash {
+	seen := make(map[crypto.Hash]struct{}, len(hashes))
+	unique := hashes[:0]
+	for _, hash := range hashes {
+		if _, exists := seen[hash]; exists {
+			continue
+		}
+		seen[hash] = struct{}{}
+		unique = append(unique, hash)
+	}
+	return unique
+}
+
 // unmarshalObjects unmarshals the sia encoded objects.
 func unmarshalObjects(reader io.Reader) (map[crypto.Hash]struct{}, error) {
 	blacklist := make(map[crypto.Hash]struct{})
@@ -0,0 +1,272 @@
+package renter
+
+// pubaccesskeyrotation.go implements the rotation of pubaccesskeys. Rotating a
+// key creates a successor key of the same type. The pubfiles that were
+// encrypted with the rotated key are downloaded, decrypted and uploaded again
+// using the successor, which results in new publinks since both the base
+// sector and the fanout are encrypted differently. The mapping of the old
+// publinks to the new ones is persisted, so that a rotation can be continued
+// after it failed and the publinks can be updated wherever they are shared.
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/persist"
+	"github.com/EvilRedHorse/pubaccess-node/pubaccesskey"
+)
+
+const (
+	// skykeyRotationsPersistFile is the name of the file that persists the
+	// rotations of the pubaccesskeys.
+	skykeyRotationsPersistFile = "pubaccesskeyrotations.json"
+)
+
+var (
+	// ErrPublinkNotEncryptedWithSkykey is returned when rotating a publink
+	// which wasn't encrypted with the rotated pubaccesskey.
+	ErrPublinkNotEncryptedWithSkykey = errors.New("publink isn't encrypted with the rotated pubaccesskey")
+
+	// errSuccessorNameRequired is returned if a key is rotated for the first
+	// time without providing a name for its successor.
+	errSuccessorNameRequired = errors.New("the successor of a pubaccesskey needs a name")
+
+	// skykeyRotationsMetadata is the metadata of the rotations persist file.
+	skykeyRotationsMetadata = persist.Metadata{
+		Header:  "Pubaccesskey Rotations",
+		Version: "1.5.0",
+	}
+)
+
+type (
+	// skykeyRotations tracks the rotations of the renter's pubaccesskeys.
+	skykeyRotations struct {
+		rotations         map[pubaccesskey.PubaccesskeyID]*modules.PubaccesskeyRotation
+		staticPersistPath string
+		mu                sync.Mutex
+	}
+
+	// skykeyRotationsPersist is the persisted state of the rotations.
+	skykeyRotationsPersist struct {
+		Rotations []modules.PubaccesskeyRotation `json:"rotations"`
+	}
+)
+
+// newSkykeyRotations loads the rotations persisted in the renter directory.
+func newSkykeyRotations(persistDir string) (*skykeyRotations, error) {
+	sr := &skykeyRotations{
+		rotations:         make(map[pubaccesskey.PubaccesskeyID]*modules.PubaccesskeyRotation),
+		staticPersistPath: filepath.Join(persistDir, skykeyRotationsPersistFile),
+	}
+	var srp skykeyRotationsPersist
+	err := persist.LoadJSON(skykeyRotationsMetadata, &srp, sr.staticPersistPath)
+	if os.IsNotExist(err) {
+		return sr, nil
+	}
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to load pubaccesskey rotations")
+	}
+	for i := range srp.Rotations {
+		sr.rotations[srp.Rotations[i].KeyID] = &srp.Rotations[i]
+	}
+	return sr, nil
+}
+
+// save persists the rotations.
+//
+// NOTE: the caller has to hold the lock of the rotations.
+func (sr *skykeyRotations) save() error {
+	srp := skykeyRotationsPersist{
+		Rotations: sr.sortedRotations(),
+	}
+	err := persist.SaveJSON(skykeyRotationsMetadata, srp, sr.staticPersistPath)
+	return errors.AddContext(err, "unable to persist pubaccesskey rotations")
+}
+
+// sortedRotations returns copies of the rotations sorted by their creation
+// time.
+//
+// NOTE: the caller has to hold the lock of the rotations.
+func (sr *skykeyRotations) sortedRotations() []modules.PubaccesskeyRotation {
+	rotations := make([]modules.PubaccesskeyRotation, 0, len(sr.rotations))
+	for _, rotation := range sr.rotations {
+		rotations = append(rotations, copyRotation(*rotation))
+	}
+	sort.Slice(rotations, func(i, j int) bool {
+		return rotations[i].CreatedAt.Before(rotations[j].CreatedAt)
+	})
+	return rotations
+}
+
+// callRotations returns the rotations sorted by their creation time.
+func (sr *skykeyRotations) callRotations() []modules.PubaccesskeyRotation {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	return sr.sortedRotations()
+}
+
+// managedRotation returns the rotation of the key, creating it using the
+// successor function if the key wasn't rotated before.
+func (sr *skykeyRotations) managedRotation(id pubaccesskey.PubaccesskeyID, successor func() (pubaccesskey.Pubaccesskey, error)) (modules.PubaccesskeyRotation, error) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if rotation, exists := sr.rotations[id]; exists {
+		return copyRotation(*rotation), nil
+	}
+	sk, err := successor()
+	if err != nil {
+		return modules.PubaccesskeyRotation{}, errors.AddContext(err, "unable to create successor of pubaccesskey")
+	}
+	rotation := &modules.PubaccesskeyRotation{
+		KeyID:       id,
+		SuccessorID: sk.ID(),
+		CreatedAt:   time.Now(),
+	}
+	sr.rotations[id] = rotation
+	return copyRotation(*rotation), sr.save()
+}
+
+// callAddPublink records that the publink was rotated to its successor.
+func (sr *skykeyRotations) callAddPublink(id pubaccesskey.PubaccesskeyID, publink, successor modules.Publink) (modules.PubaccesskeyRotation, error) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	rotation, exists := sr.rotations[id]
+	if !exists {
+		return modules.PubaccesskeyRotation{}, errors.New("pubaccesskey wasn't rotated")
+	}
+	rotation.Publinks = append(rotation.Publinks, modules.RotatedPublink{
+		Publink:   publink.String(),
+		Successor: successor.String(),
+		RotatedAt: time.Now(),
+	})
+	return copyRotation(*rotation), sr.save()
+}
+
+// copyRotation returns a deep copy of the rotation.
+func copyRotation(rotation modules.PubaccesskeyRotation) modules.PubaccesskeyRotation {
+	rotation.Publinks = append([]modules.RotatedPublink(nil), rotation.Publinks...)
+	return rotation
+}
+
+// isRotated returns whether the publink was rotated already.
+func isRotated(rotation modules.PubaccesskeyRotation, publink modules.Publink) bool {
+	for _, rp := range rotation.Publinks {
+		if rp.Publink == publink.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// RotateSkykey rotates the Pubaccesskey with the given ID. A successor with the
+// given name is created on the first rotation of the key and reused
+// afterwards. The pubfiles of the publinks are re-encrypted with the successor
+// and uploaded to the given directory. Publinks which were rotated before are
+// skipped. If a publink can't be rotated, the rotation up to that point is
+// returned together with the error.
+func (r *Renter) RotateSkykey(id pubaccesskey.PubaccesskeyID, successorName string, publinks []modules.Publink, dir modules.SiaPath, timeout time.Duration) (modules.PubaccesskeyRotation, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.PubaccesskeyRotation{}, err
+	}
+	defer r.tg.Done()
+
+	sk, err := r.staticSkykeyManager.KeyByID(id)
+	if err != nil {
+		return modules.PubaccesskeyRotation{}, errors.AddContext(err, "unable to get pubaccesskey")
+	}
+	rotation, err := r.staticSkykeyRotations.managedRotation(id, func() (pubaccesskey.Pubaccesskey, error) {
+		if successorName == "" {
+			return pubaccesskey.Pubaccesskey{}, errSuccessorNameRequired
+		}
+		return r.staticSkykeyManager.CreateKey(successorName, sk.Type)
+	})
+	if err != nil {
+		return modules.PubaccesskeyRotation{}, err
+	}
+	successor, err := r.staticSkykeyManager.KeyByID(rotation.SuccessorID)
+	if err != nil {
+		return rotation, errors.AddContext(err, "unable to get successor of pubaccesskey")
+	}
+
+	for _, publink := range publinks {
+		if isRotated(rotation, publink) {
+			continue
+		}
+		rotated, err := r.managedReencryptPublink(publink, sk, successor, dir, timeout)
+		if err != nil {
+			return rotation, errors.AddContext(err, "unable to rotate publink "+publink.String())
+		}
+		rotation, err = r.staticSkykeyRotations.callAddPublink(id, publink, rotated)
+		if err != nil {
+			return rotation, err
+		}
+	}
+	return rotation, nil
+}
+
+// SkykeyRotations returns the rotations of the renter's Pubaccesskeys.
+func (r *Renter) SkykeyRotations() ([]modules.PubaccesskeyRotation, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+	return r.staticSkykeyRotations.callRotations(), nil
+}
+
+// managedReencryptPublink downloads the pubfile of the publink, which has to
+// be encrypted with the rotated key, and uploads it again encrypted with the
+// successor. The new pubfile is stored in the directory using the publink as
+// its name.
+func (r *Renter) managedReencryptPublink(publink modules.Publink, sk, successor pubaccesskey.Pubaccesskey, dir modules.SiaPath, timeout time.Duration) (modules.Publink, error) {
+	// Check the key of the base sector before downloading the whole pubfile.
+	link, err := r.managedResolvePublink(publink, timeout)
+	if err != nil {
+		return modules.Publink{}, errors.AddContext(err, "unable to resolve publink")
+	}
+	offset, fetchSize, err := link.OffsetAndFetchSize()
+	if err != nil {
+		return modules.Publink{}, errors.AddContext(err, "unable to parse publink")
+	}
+	baseSector, err := r.DownloadByRoot(link.MerkleRoot(), offset, fetchSize, timeout)
+	if err != nil {
+		return modules.Publink{}, errors.AddContext(err, "unable to fetch base sector of publink")
+	}
+	if len(baseSector) < SkyfileLayoutSize {
+		return modules.Publink{}, errors.New("download did not fetch enough data, layout cannot be decoded")
+	}
+	encrypted, err := isEncryptedWithSkykey(baseSector, sk)
+	if err != nil {
+		return modules.Publink{}, errors.AddContext(err, "unable to check pubaccesskey of publink")
+	}
+	if !encrypted {
+		return modules.Publink{}, ErrPublinkNotEncryptedWithSkykey
+	}
+
+	// Download the decrypted pubfile and upload it with the successor.
+	metadata, streamer, err := r.DownloadPublink(link, timeout, 0)
+	if err != nil {
+		return modules.Publink{}, errors.AddContext(err, "unable to download pubfile")
+	}
+	defer func() {
+		_ = streamer.Close()
+	}()
+	siaPath, err := dir.Join(publink.String())
+	if err != nil {
+		return modules.Publink{}, errors.AddContext(err, "unable to create siapath of re-encrypted pubfile")
+	}
+	return r.UploadSkyfile(modules.PubfileUploadParameters{
+		SiaPath: siaPath,
+		// The siapath belongs to the rotation, a previous attempt that
+		// failed might have left a file behind.
+		Force:          true,
+		FileMetadata:   metadata,
+		Reader:         streamer,
+		PubaccesskeyID: successor.ID(),
+	})
+}
@@ -0,0 +1,195 @@
+package renter
+
+import (
+	"os"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"github.com/EvilRedHorse/pubaccess-node/build"
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/pubaccesskey"
+)
+
+// TestIsEncryptedWithSkykey tests that the key of encrypted base sectors is
+// identified correctly for both public-id and private-id keys.
+func TestIsEncryptedWithSkykey(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	testdir := build.TempDir("renter", t.Name())
+	sm, err := pubaccesskey.NewSkykeyManager(testdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicIDKey, err := sm.CreateKey("public-id-key", pubaccesskey.TypePublicID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateIDKey, err := sm.CreateKey("private-id-key", pubaccesskey.TypePrivateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := sm.CreateKey("other-key", pubaccesskey.TypePrivateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a base sector.
+	fileBytes := fastrand.Bytes(1000)
+	metadataBytes, err := skyfileMetadataBytes(modules.PubfileMetadata{
+		Mode:     os.FileMode(0777),
+		Filename: "rotation_test_file",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ll := skyfileLayout{
+		version:      SkyfileVersion,
+		filesize:     uint64(len(fileBytes)),
+		metadataSize: uint64(len(metadataBytes)),
+		cipherType:   crypto.TypePlain,
+	}
+	baseSector, _ := skyfileBuildBaseSector(ll.encode(), nil, metadataBytes, fileBytes)
+
+	// An unencrypted base sector isn't encrypted with any key.
+	encrypted, err := isEncryptedWithSkykey(baseSector, publicIDKey)
+	if err != nil || encrypted {
+		t.Fatal("unencrypted base sector shouldn't match", encrypted, err)
+	}
+
+	// Encrypt a copy of the base sector with a file-specific key of every
+	// key and check that only the key used for the encryption matches.
+	keys := []pubaccesskey.Pubaccesskey{publicIDKey, privateIDKey, otherKey}
+	for i, sk := range keys {
+		fsKey, err := sk.GenerateFileSpecificSubkey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs := append([]byte(nil), baseSector...)
+		err = encryptBaseSectorWithSkykey(bs, ll, fsKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, key := range keys {
+			encrypted, err := isEncryptedWithSkykey(bs, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if encrypted != (i == j) {
+				t.Fatalf("key %v matched base sector of key %v: %v", j, i, encrypted)
+			}
+		}
+	}
+}
+
+// TestSkykeyRotations tests creating rotations, recording the rotated
+// publinks and reloading the rotations.
+func TestSkykeyRotations(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	testdir := build.TempDir("renter", t.Name())
+	err := os.MkdirAll(testdir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm, err := pubaccesskey.NewSkykeyManager(testdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr, err := newSkykeyRotations(testdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, err := sm.CreateKey("key", pubaccesskey.TypePrivateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A failing successor function doesn't create a rotation.
+	errSuccessor := errors.New("no successor")
+	_, err = sr.managedRotation(sk.ID(), func() (pubaccesskey.Pubaccesskey, error) {
+		return pubaccesskey.Pubaccesskey{}, errSuccessor
+	})
+	if !errors.Contains(err, errSuccessor) {
+		t.Fatal("expected successor error", err)
+	}
+	if len(sr.callRotations()) != 0 {
+		t.Fatal("rotation shouldn't have been created")
+	}
+
+	// Rotating the key creates the successor once.
+	successors := 0
+	successor := func() (pubaccesskey.Pubaccesskey, error) {
+		successors++
+		return sm.CreateKey("key-rotated", pubaccesskey.TypePrivateID)
+	}
+	rotation, err := sr.managedRotation(sk.ID(), successor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotation2, err := sr.managedRotation(sk.ID(), successor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if successors != 1 || rotation.SuccessorID != rotation2.SuccessorID {
+		t.Fatal("successor should only be created once", successors)
+	}
+	if rotation.KeyID != sk.ID() || rotation.SuccessorID == sk.ID() {
+		t.Fatal("wrong key IDs", rotation.KeyID, rotation.SuccessorID)
+	}
+
+	// Record a rotated publink.
+	publink, err := modules.NewPublinkV1(crypto.Hash{1}, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := modules.NewPublinkV1(crypto.Hash{2}, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isRotated(rotation, publink) {
+		t.Fatal("publink shouldn't be rotated yet")
+	}
+	rotation, err = sr.callAddPublink(sk.ID(), publink, rotated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isRotated(rotation, publink) {
+		t.Fatal("publink should be rotated")
+	}
+
+	// Recording a publink of a key that wasn't rotated fails.
+	_, err = sr.callAddPublink(rotation.SuccessorID, publink, rotated)
+	if err == nil {
+		t.Fatal("expected error for key without rotation")
+	}
+
+	// Reload the rotations.
+	sr, err = newSkykeyRotations(testdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotations := sr.callRotations()
+	if len(rotations) != 1 {
+		t.Fatal("expected one rotation", len(rotations))
+	}
+	rotation = rotations[0]
+	if rotation.KeyID != sk.ID() || rotation.SuccessorID != rotation2.SuccessorID {
+		t.Fatal("wrong key IDs after reload", rotation.KeyID, rotation.SuccessorID)
+	}
+	if len(rotation.Publinks) != 1 {
+		t.Fatal("expected one rotated publink", len(rotation.Publinks))
+	}
+	rp := rotation.Publinks[0]
+	if rp.Publink != publink.String() || rp.Successor != rotated.String() {
+		t.Fatal("wrong rotated publink", rp)
+	}
+}
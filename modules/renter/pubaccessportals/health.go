@@ -0,0 +1,180 @@
+package pubaccessportals
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/persist"
+)
+
+const (
+	// healthPersistFile is the name of the file that persists the results of
+	// the portal health checks.
+	healthPersistFile string = "pubaccessportalshealth.json"
+
+	// maxProbeResponseSize is the maximum size of a probe response that is
+	// read.
+	maxProbeResponseSize = 1 << 16
+)
+
+var (
+	// healthMetadata is the metadata of the health persist file.
+	healthMetadata = persist.Metadata{
+		Header:  "Pubaccess Portals Health",
+		Version: "1.5.0",
+	}
+
+	// probeSchemes are the schemes that are tried in order when probing a
+	// portal.
+	probeSchemes = []string{"https", "http"}
+)
+
+type (
+	// ProbeResult is the result of successfully probing a portal.
+	ProbeResult struct {
+		URL     string
+		Version string
+		Latency time.Duration
+	}
+
+	// healthPersist is the persisted health of the portals.
+	healthPersist struct {
+		Health map[modules.NetAddress]modules.SkynetPortalHealth `json:"health"`
+	}
+)
+
+// ProbePortal checks the health of the portal at the given address by
+// requesting its /pubaccess/stats endpoint. https is tried before http.
+func ProbePortal(ctx context.Context, client *http.Client, address modules.NetAddress) (ProbeResult, error) {
+	var errs error
+	for _, scheme := range probeSchemes {
+		baseURL := fmt.Sprintf("%v://%v", scheme, address)
+		res, err := probeURL(ctx, client, baseURL)
+		if err == nil {
+			return res, nil
+		}
+		errs = errors.Compose(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return ProbeResult{}, errors.AddContext(errs, "unable to probe portal "+string(address))
+}
+
+// probeURL requests the stats of the portal at the base url.
+func probeURL(ctx context.Context, client *http.Client, baseURL string) (ProbeResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/pubaccess/stats", nil)
+	if err != nil {
+		return ProbeResult{}, err
+	}
+	req.Header.Set("User-Agent", "ScPrime-Agent")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return ProbeResult{}, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return ProbeResult{}, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+	var stats struct {
+		VersionInfo struct {
+			Version string `json:"version"`
+		} `json:"versioninfo"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxProbeResponseSize)).Decode(&stats)
+	if err != nil {
+		return ProbeResult{}, errors.AddContext(err, "unable to decode portal stats")
+	}
+	return ProbeResult{
+		URL:     baseURL,
+		Version: stats.VersionInfo.Version,
+		Latency: time.Since(start),
+	}, nil
+}
+
+// loadHealth loads the persisted health of the portals from disk.
+func loadHealth(persistDir string) (map[modules.NetAddress]modules.SkynetPortalHealth, error) {
+	var hp healthPersist
+	err := persist.LoadJSON(healthMetadata, &hp, filepath.Join(persistDir, healthPersistFile))
+	if os.IsNotExist(err) {
+		return make(map[modules.NetAddress]modules.SkynetPortalHealth), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if hp.Health == nil {
+		hp.Health = make(map[modules.NetAddress]modules.SkynetPortalHealth)
+	}
+	return hp.Health, nil
+}
+
+// saveHealth persists the health of the portals.
+//
+// NOTE: the caller has to hold the lock of the portals.
+func (sp *SkynetPortals) saveHealth() error {
+	hp := healthPersist{
+		Health: sp.health,
+	}
+	err := persist.SaveJSON(healthMetadata, hp, filepath.Join(sp.staticPersistDir, healthPersistFile))
+	return errors.AddContext(err, "unable to persist pubaccess portals health")
+}
+
+// ProbePortals probes every known portal using the probe function and records
+// the results.
+func (sp *SkynetPortals) ProbePortals(probe func(modules.NetAddress) (ProbeResult, error)) error {
+	portals := sp.Portals()
+	if len(portals) == 0 {
+		return nil
+	}
+	for _, portal := range portals {
+		// Probe the portal without holding the lock.
+		res, err := probe(portal.Address)
+
+		sp.mu.Lock()
+		sp.updateHealth(portal.Address, res, err)
+		sp.mu.Unlock()
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return sp.saveHealth()
+}
+
+// updateHealth records the result of probing a portal.
+//
+// NOTE: the caller has to hold the lock of the portals.
+func (sp *SkynetPortals) updateHealth(address modules.NetAddress, res ProbeResult, probeErr error) {
+	if _, exists := sp.portals[address]; !exists {
+		// The portal was removed while it was probed.
+		return
+	}
+	health := sp.health[address]
+	health.LastCheck = time.Now()
+	health.Healthy = probeErr == nil
+	if probeErr != nil {
+		health.LastError = probeErr.Error()
+		health.ConsecutiveFailures++
+		sp.health[address] = health
+		return
+	}
+	health.URL = res.URL
+	health.Version = res.Version
+	health.Latency = res.Latency
+	health.LastSuccess = health.LastCheck
+	health.LastError = ""
+	health.ConsecutiveFailures = 0
+	sp.health[address] = health
+}
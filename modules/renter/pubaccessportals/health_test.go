@@ -0,0 +1,142 @@
+package pubaccessportals
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+)
+
+// TestProbePortal tests probing a portal.
+func TestProbePortal(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/pubaccess/stats" || req.UserAgent() != "ScPrime-Agent" {
+			http.NotFound(w, req)
+			return
+		}
+		_, _ = w.Write([]byte(`{"versioninfo":{"version":"1.2.3"}}`))
+	}))
+	defer server.Close()
+
+	// The server doesn't support https, so http is used.
+	address := modules.NetAddress(strings.TrimPrefix(server.URL, "http://"))
+	res, err := ProbePortal(context.Background(), server.Client(), address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.URL != server.URL || res.Version != "1.2.3" || res.Latency == 0 {
+		t.Fatalf("unexpected probe result %+v", res)
+	}
+
+	// Probing a closed portal fails.
+	server.Close()
+	_, err = ProbePortal(context.Background(), server.Client(), address)
+	if err == nil {
+		t.Fatal("expected probing a closed portal to fail")
+	}
+}
+
+// TestProbePortals tests recording and persisting the health of the portals.
+func TestProbePortals(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	testdir := testDir(t.Name())
+	sp, err := New(testdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	healthy := modules.NetAddress("healthy.com:443")
+	unhealthy := modules.NetAddress("unhealthy.com:443")
+	err = sp.UpdatePortals([]modules.SkynetPortal{{Address: healthy, Public: true}, {Address: unhealthy}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Portals without health checks have no health.
+	for _, portal := range sp.Portals() {
+		if portal.Health != nil {
+			t.Fatal("expected portal to have no health", portal)
+		}
+	}
+
+	// Probe the portals twice.
+	probe := func(address modules.NetAddress) (ProbeResult, error) {
+		if address == unhealthy {
+			return ProbeResult{}, errors.New("probe failed")
+		}
+		return ProbeResult{URL: "https://" + string(address), Version: "1.2.3", Latency: time.Millisecond}, nil
+	}
+	for i := 0; i < 2; i++ {
+		if err := sp.ProbePortals(probe); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// checkHealth checks the health of the portals.
+	checkHealth := func(sp *SkynetPortals) {
+		t.Helper()
+		portals := sp.Portals()
+		if len(portals) != 2 {
+			t.Fatal("expected 2 portals but got", len(portals))
+		}
+		for _, portal := range portals {
+			h := portal.Health
+			if h == nil {
+				t.Fatal("expected portal to have health", portal.Address)
+			}
+			switch portal.Address {
+			case healthy:
+				if !h.Healthy || h.Version != "1.2.3" || h.Latency != time.Millisecond || h.URL != "https://healthy.com:443" || h.LastSuccess.IsZero() {
+					t.Fatalf("unexpected health %+v", h)
+				}
+			case unhealthy:
+				if h.Healthy || h.LastError != "probe failed" || h.ConsecutiveFailures != 2 || !h.LastSuccess.IsZero() {
+					t.Fatalf("unexpected health %+v", h)
+				}
+			}
+		}
+	}
+	checkHealth(sp)
+
+	// The health is persisted.
+	if err := sp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sp, err = New(testdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHealth(sp)
+
+	// Removing a portal removes its health.
+	err = sp.UpdatePortals(nil, []modules.NetAddress{unhealthy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := sp.health[unhealthy]; exists {
+		t.Fatal("expected health of removed portal to be removed")
+	}
+
+	// The health is serialized as part of the portal.
+	b, err := json.Marshal(sp.Portals())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"healthy":true`) {
+		t.Fatal("expected health in json", string(b))
+	}
+	if err := sp.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
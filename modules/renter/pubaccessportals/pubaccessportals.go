@@ -38,13 +38,18 @@ var (
 
 type (
 	// SkynetPortals manages a list of known public access portals by persisting the
-	// list to disk.
+	// list to disk. It also keeps track of the health of the portals.
 	SkynetPortals struct {
-		staticAop *persist.AppendOnlyPersist
+		staticAop        *persist.AppendOnlyPersist
+		staticPersistDir string
 
 		// portals is a map of portal addresses to public status.
 		portals map[modules.NetAddress]bool
 
+		// health is a map of portal addresses to the results of their health
+		// checks.
+		health map[modules.NetAddress]modules.SkynetPortalHealth
+
 		mu sync.Mutex
 	}
 
@@ -66,7 +71,8 @@ func New(persistDir string) (*SkynetPortals, error) {
 	}
 
 	sp := &SkynetPortals{
-		staticAop: aop,
+		staticAop:        aop,
+		staticPersistDir: persistDir,
 	}
 	portals, err := unmarshalObjects(reader)
 	if err != nil {
@@ -74,6 +80,14 @@ func New(persistDir string) (*SkynetPortals, error) {
 	}
 	sp.portals = portals
 
+	// Load the health of the portals.
+	health, err := loadHealth(persistDir)
+	if err != nil {
+		err = errors.Compose(err, aop.Close())
+		return nil, errors.AddContext(err, "unable to load the pubaccess portals health")
+	}
+	sp.health = health
+
 	return sp, nil
 }
 
@@ -93,6 +107,9 @@ func (sp *SkynetPortals) Portals() []modules.SkynetPortal {
 			Address: addr,
 			Public:  public,
 		}
+		if health, exists := sp.health[addr]; exists {
+			portal.Health = &health
+		}
 		portals = append(portals, portal)
 	}
 	return portals
@@ -127,7 +144,25 @@ func (sp *SkynetPortals) UpdatePortals(additions []modules.SkynetPortal, removal
 		return errors.AddContext(err, fmt.Sprintf("unable to update pubaccess portal list persistence at '%v'", sp.staticAop.FilePath()))
 	}
 	_, err = sp.staticAop.Write(buf.Bytes())
-	return errors.AddContext(err, fmt.Sprintf("unable to update pubaccess portal list persistence at '%v'", sp.staticAop.FilePath()))
+	if err != nil {
+		return errors.AddContext(err, fmt.Sprintf("unable to update pubaccess portal list persistence at '%v'", sp.staticAop.FilePath()))
+	}
+
+	// Forget the health of removed portals.
+	removedHealth := false
+	for _, address := range removals {
+		if _, exists := sp.portals[address]; exists {
+			continue
+		}
+		if _, exists := sp.health[address]; exists {
+			delete(sp.health, address)
+			removedHealth = true
+		}
+	}
+	if !removedHealth {
+		return nil
+	}
+	return sp.saveHealth()
 }
 
 // marshalObjects marshals the given objects into a byte buffer.
@@ -36,6 +36,8 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"net/http"
+	"path/filepath"
 	"time"
 
 	"github.com/EvilRedHorse/pubaccess-node/build"
@@ -44,6 +46,8 @@ import (
 	"github.com/EvilRedHorse/pubaccess-node/modules"
 	"github.com/EvilRedHorse/pubaccess-node/modules/renter/filesystem"
 	"github.com/EvilRedHorse/pubaccess-node/modules/renter/filesystem/siafile"
+	"github.com/EvilRedHorse/pubaccess-node/modules/renter/pubaccessblacklist"
+	"github.com/EvilRedHorse/pubaccess-node/modules/renter/pubaccessportals"
 	"github.com/EvilRedHorse/pubaccess-node/pubaccesskey"
 	"github.com/EvilRedHorse/pubaccess-node/types"
 
@@ -80,6 +84,15 @@ var (
 	// by Pubaccess
 	ErrRedundancyNotSupported = errors.New("publinks currently only support 1-of-N redundancy, other redundancies will be supported in a later version")
 
+	// ErrPublinkNotPinned is the error returned when unpinning a publink that
+	// isn't pinned by the renter.
+	ErrPublinkNotPinned = errors.New("publink is not pinned by the renter")
+
+	// ErrPublinkSectorShared is the error returned when unpinning a publink
+	// whose base sector is shared with other pubfiles of a batch upload
+	// without forcing it.
+	ErrPublinkSectorShared = errors.New("publink shares its base sector with other pubfiles")
+
 	// ExtendedSuffix is the suffix that is added to a pubfile siapath if it is
 	// a large file upload
 	ExtendedSuffix = "-extended"
@@ -443,6 +456,12 @@ func (r *Renter) managedCreateFileNodeFromReader(up modules.FileUploadParams, re
 	return fileNode, nil
 }
 
+// SkyfileSpoolDir returns the directory in which pubfile uploads are spooled
+// before they are uploaded. The directory is cleared on startup.
+func (r *Renter) SkyfileSpoolDir() string {
+	return filepath.Join(r.persistDir, skyfileSpoolDir)
+}
+
 // Blacklist returns the merkleroots that are blacklisted
 func (r *Renter) Blacklist() ([]crypto.Hash, error) {
 	err := r.tg.Add()
@@ -463,6 +482,87 @@ func (r *Renter) UpdateSkynetBlacklist(additions, removals []modules.Publink) er
 	return r.staticSkynetBlacklist.UpdateBlacklist(additions, removals)
 }
 
+// BlacklistEntries returns the hashes of the merkleroots that are blacklisted
+// together with the sources that blacklisted them.
+func (r *Renter) BlacklistEntries() ([]modules.SkynetBlacklistEntry, error) {
+	err := r.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+	return r.staticSkynetBlacklist.Entries(), nil
+}
+
+// UpdateSkynetBlacklistHashes updates the list of hashed merkleroots that are
+// blacklisted.
+func (r *Renter) UpdateSkynetBlacklistHashes(additions, removals []crypto.Hash) error {
+	err := r.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	return r.staticSkynetBlacklist.UpdateBlacklistHashes(additions, removals)
+}
+
+// BlacklistSubscriptions returns the subscribed remote blacklist feeds.
+func (r *Renter) BlacklistSubscriptions() ([]modules.SkynetBlacklistSubscription, error) {
+	err := r.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+	return r.staticSkynetBlacklist.Subscriptions(), nil
+}
+
+// UpdateSkynetBlacklistSubscriptions subscribes to and unsubscribes from remote
+// blacklist feeds. Added feeds are fetched right away.
+func (r *Renter) UpdateSkynetBlacklistSubscriptions(additions []modules.SkynetBlacklistSubscription, removals []string) error {
+	err := r.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	err = r.staticSkynetBlacklist.UpdateSubscriptions(additions, removals)
+	if err != nil {
+		return err
+	}
+	if len(additions) > 0 {
+		go r.threadedUpdateSkynetBlacklistFeeds()
+	}
+	return nil
+}
+
+// threadedSkynetBlacklistFeedsLoop periodically fetches the subscribed
+// blacklist feeds.
+func (r *Renter) threadedSkynetBlacklistFeedsLoop() {
+	for {
+		r.threadedUpdateSkynetBlacklistFeeds()
+		select {
+		case <-r.tg.StopChan():
+			return
+		case <-time.After(skynetBlacklistFeedsInterval):
+		}
+	}
+}
+
+// threadedUpdateSkynetBlacklistFeeds fetches the subscribed blacklist feeds
+// and merges them into the blacklist.
+func (r *Renter) threadedUpdateSkynetBlacklistFeeds() {
+	err := r.tg.Add()
+	if err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	client := &http.Client{Timeout: skynetBlacklistFeedTimeout}
+	err = r.staticSkynetBlacklist.UpdateFeeds(func(feedURL string) (pubaccessblacklist.Feed, error) {
+		return pubaccessblacklist.FetchFeed(r.tg.StopCtx(), client, feedURL)
+	})
+	if err != nil {
+		r.log.Println("WARN: unable to update the pubaccess blacklist feeds:", err)
+	}
+}
+
 // Portals returns the list of known pubaccess portals.
 func (r *Renter) Portals() ([]modules.SkynetPortal, error) {
 	err := r.tg.Add()
@@ -480,7 +580,45 @@ func (r *Renter) UpdateSkynetPortals(additions []modules.SkynetPortal, removals
 		return err
 	}
 	defer r.tg.Done()
-	return r.staticSkynetPortals.UpdatePortals(additions, removals)
+	err = r.staticSkynetPortals.UpdatePortals(additions, removals)
+	if err != nil {
+		return err
+	}
+	if len(additions) > 0 {
+		go r.threadedProbeSkynetPortals()
+	}
+	return nil
+}
+
+// threadedSkynetPortalsProbeLoop periodically probes the known pubaccess
+// portals to keep track of their health.
+func (r *Renter) threadedSkynetPortalsProbeLoop() {
+	for {
+		r.threadedProbeSkynetPortals()
+		select {
+		case <-r.tg.StopChan():
+			return
+		case <-time.After(skynetPortalsProbeInterval):
+		}
+	}
+}
+
+// threadedProbeSkynetPortals probes the known pubaccess portals and records
+// their health.
+func (r *Renter) threadedProbeSkynetPortals() {
+	err := r.tg.Add()
+	if err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	client := &http.Client{Timeout: skynetPortalProbeTimeout}
+	err = r.staticSkynetPortals.ProbePortals(func(address modules.NetAddress) (pubaccessportals.ProbeResult, error) {
+		return pubaccessportals.ProbePortal(r.tg.StopCtx(), client, address)
+	})
+	if err != nil {
+		r.log.Println("WARN: unable to update the pubaccess portals health:", err)
+	}
 }
 
 // uploadSkyfileReadLeadingChunk will read the leading chunk of a pubfile. If
@@ -744,9 +882,37 @@ func parseSkyfileMetadata(baseSector []byte) (sl skyfileLayout, fanoutBytes []by
 	return sl, fanoutBytes, sm, baseSectorPayload, nil
 }
 
+// managedResolvePublink resolves a v2 publink to the v1 publink stored in its
+// registry entry. v1 publinks are returned unchanged.
+func (r *Renter) managedResolvePublink(link modules.Publink, timeout time.Duration) (modules.Publink, error) {
+	if link.IsPublinkV1() {
+		return link, nil
+	}
+	id, err := link.RegistryEntryID()
+	if err != nil {
+		return modules.Publink{}, err
+	}
+	ctx, cancel := r.registryContext(timeout)
+	defer cancel()
+	_, srv, err := r.managedReadRegistry(ctx, id)
+	if err != nil {
+		return modules.Publink{}, errors.AddContext(err, "unable to read registry entry of publink")
+	}
+	var resolved modules.Publink
+	err = resolved.LoadBytes(srv.Data)
+	if err != nil {
+		return modules.Publink{}, errors.AddContext(err, "registry entry doesn't contain a publink")
+	}
+	if !resolved.IsPublinkV1() {
+		return modules.Publink{}, errors.New("registry entry of a v2 publink has to contain a v1 publink")
+	}
+	return resolved, nil
+}
+
 // DownloadPublink will take a link and turn it into the metadata and data of a
-// download.
-func (r *Renter) DownloadPublink(link modules.Publink, timeout time.Duration) (modules.PubfileMetadata, modules.Streamer, error) {
+// download. The prefetch is the number of fanout chunks that are fetched in
+// parallel ahead of the reader, zero results in the default prefetch.
+func (r *Renter) DownloadPublink(link modules.Publink, timeout time.Duration, prefetch uint64) (modules.PubfileMetadata, modules.Streamer, error) {
 	if r.deps.Disrupt("resolveSkylinkToFixture") {
 		sf, err := fixtures.LoadPublinkFixture(link)
 		if err != nil {
@@ -760,11 +926,24 @@ func (r *Renter) DownloadPublink(link modules.Publink, timeout time.Duration) (m
 		return modules.PubfileMetadata{}, nil, ErrPublinkBlacklisted
 	}
 
+	// Resolve a v2 publink to the v1 publink it currently points to, which is
+	// checked against the blacklist as well.
+	if link.IsPublinkV2() {
+		var err error
+		link, err = r.managedResolvePublink(link, timeout)
+		if err != nil {
+			return modules.PubfileMetadata{}, nil, errors.AddContext(err, "unable to resolve v2 publink")
+		}
+		if r.staticSkynetBlacklist.IsBlacklisted(link) {
+			return modules.PubfileMetadata{}, nil, ErrPublinkBlacklisted
+		}
+	}
+
 	// Check if this publink is already in the stream buffer set. If so, we can
 	// skip the lookup procedure and use any data that other threads have
 	// cached.
 	id := link.DataSourceID()
-	streamer, exists := r.staticStreamBufferSet.callNewStreamFromID(id, 0)
+	streamer, exists := r.staticStreamBufferSet.callNewStreamFromID(id, 0, prefetch)
 	if exists {
 		return streamer.Metadata(), streamer, nil
 	}
@@ -808,7 +987,7 @@ func (r *Renter) DownloadPublink(link modules.Publink, timeout time.Duration) (m
 	}
 
 	// There is a fanout, create a fanout streamer and return that.
-	fs, err := r.newFanoutStreamer(link, layout, metadata, fanoutBytes, timeout, fileSpecificSkykey)
+	fs, err := r.newFanoutStreamer(link, layout, metadata, fanoutBytes, timeout, prefetch, fileSpecificSkykey)
 	if err != nil {
 		return modules.PubfileMetadata{}, nil, errors.AddContext(err, "unable to create fanout fetcher")
 	}
@@ -823,6 +1002,18 @@ func (r *Renter) PinPublink(publink modules.Publink, lup modules.PubfileUploadPa
 		return ErrPublinkBlacklisted
 	}
 
+	// Pin the data a v2 publink currently points to.
+	if publink.IsPublinkV2() {
+		var err error
+		publink, err = r.managedResolvePublink(publink, timeout)
+		if err != nil {
+			return errors.AddContext(err, "unable to resolve v2 publink")
+		}
+		if r.staticSkynetBlacklist.IsBlacklisted(publink) {
+			return ErrPublinkBlacklisted
+		}
+	}
+
 	// Set sane defaults for unspecified values.
 	skyfileEstablishDefaults(&lup)
 
@@ -906,7 +1097,7 @@ func (r *Renter) PinPublink(publink modules.Publink, lup modules.PubfileUploadPa
 	}
 
 	// Create the fanout streamer that will download the file.
-	streamer, err := r.newFanoutStreamer(publink, layout, metadata, fanoutBytes, timeout, fileSpecificSkykey)
+	streamer, err := r.newFanoutStreamer(publink, layout, metadata, fanoutBytes, timeout, 0, fileSpecificSkykey)
 	if err != nil {
 		return errors.AddContext(err, "Failed to create fanout streamer for large pubfile pin")
 	}
@@ -923,6 +1114,118 @@ func (r *Renter) PinPublink(publink modules.Publink, lup modules.PubfileUploadPa
 	return nil
 }
 
+// UnpinPublink deletes every siafile whose base sector matches the publink,
+// together with the extended siafiles of large pubfiles. The files of a batch
+// upload share their base sector, unpinning one of them unpins all of them, so
+// a publink whose sector holds other pubfiles is only unpinned if force is set.
+// A v2 publink is resolved within the timeout and the v1 publink it points to
+// is unpinned. The siapaths of the deleted siafiles are returned along with
+// their total size, also if deleting one of the siafiles fails.
+func (r *Renter) UnpinPublink(publink modules.Publink, force bool, timeout time.Duration) ([]modules.SiaPath, uint64, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, 0, err
+	}
+	defer r.tg.Done()
+
+	// The data of a v2 publink is pinned by the v1 publink it points to.
+	publink, err := r.managedResolvePublink(publink, timeout)
+	if err != nil {
+		return nil, 0, errors.AddContext(err, "unable to resolve publink")
+	}
+
+	// Pubfiles can be pinned outside of the pubaccess folder, so all files are
+	// searched.
+	files, _, err := r.staticFileSystem.CachedList(modules.RootSiaPath(), true)
+	if err != nil {
+		return nil, 0, errors.AddContext(err, "unable to list files")
+	}
+
+	// Find the siafiles with a publink of the same base sector.
+	var baseSiaPaths []modules.SiaPath
+	for _, file := range files {
+		if !containsBaseSector(file.Publinks, publink.MerkleRoot()) {
+			continue
+		}
+		if !force && sharesBaseSector(file.Publinks, publink) {
+			return nil, 0, errors.AddContext(ErrPublinkSectorShared, fmt.Sprintf("siafile %v", file.SiaPath))
+		}
+		baseSiaPaths = append(baseSiaPaths, file.SiaPath)
+	}
+	if len(baseSiaPaths) == 0 {
+		return nil, 0, ErrPublinkNotPinned
+	}
+
+	// Delete the siafiles and their extended siafiles, which might contain the
+	// publink as well. The size is taken from the siafiles right before they
+	// are deleted.
+	var siaPaths []modules.SiaPath
+	var freed uint64
+	deleted := make(map[modules.SiaPath]struct{})
+	deleteFile := func(siaPath modules.SiaPath) error {
+		if _, exists := deleted[siaPath]; exists {
+			return nil
+		}
+		fi, err := r.File(siaPath)
+		if err != nil {
+			return err
+		}
+		err = r.DeleteFile(siaPath)
+		if err != nil {
+			return err
+		}
+		deleted[siaPath] = struct{}{}
+		siaPaths = append(siaPaths, siaPath)
+		freed += fi.Filesize
+		return nil
+	}
+	for _, siaPath := range baseSiaPaths {
+		err = deleteFile(siaPath)
+		if err != nil {
+			return siaPaths, freed, errors.AddContext(err, fmt.Sprintf("unable to delete siafile %v", siaPath))
+		}
+		extendedSiaPath, err := modules.NewSiaPath(siaPath.String() + ExtendedSuffix)
+		if err != nil {
+			return siaPaths, freed, errors.AddContext(err, "unable to create extended SiaPath")
+		}
+		err = deleteFile(extendedSiaPath)
+		if err != nil && !errors.Contains(err, filesystem.ErrNotExist) {
+			return siaPaths, freed, errors.AddContext(err, fmt.Sprintf("unable to delete siafile %v", extendedSiaPath))
+		}
+	}
+	return siaPaths, freed, nil
+}
+
+// containsBaseSector returns true if one of the publinks points to the base
+// sector with the given merkle root.
+func containsBaseSector(publinks []string, root crypto.Hash) bool {
+	for _, str := range publinks {
+		var publink modules.Publink
+		if err := publink.LoadString(str); err != nil {
+			continue
+		}
+		if publink.IsPublinkV1() && publink.MerkleRoot() == root {
+			return true
+		}
+	}
+	return false
+}
+
+// sharesBaseSector returns true if one of the publinks points to another
+// pubfile within the base sector of the given publink, which is the case for
+// the pubfiles of a batch upload.
+func sharesBaseSector(publinks []string, publink modules.Publink) bool {
+	for _, str := range publinks {
+		var other modules.Publink
+		if err := other.LoadString(str); err != nil {
+			continue
+		}
+		if other.IsPublinkV1() && other.MerkleRoot() == publink.MerkleRoot() && other != publink {
+			return true
+		}
+	}
+	return false
+}
+
 // UploadSkyfile will upload the provided data with the provided metadata,
 // returning a publink which can be used by any viewnode to recover the full
 // original file and metadata. The publink will be unique to the combination of
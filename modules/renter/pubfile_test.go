@@ -3,10 +3,13 @@ package renter
 import (
 	"math"
 	"testing"
+	"time"
 
 	"github.com/EvilRedHorse/pubaccess-node/crypto"
 	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/persist"
 
+	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/fastrand"
 )
 
@@ -96,3 +99,131 @@ func TestParseSkyfileMetadata(t *testing.T) {
 		}
 	}
 }
+
+// TestSharesBaseSector checks that publinks of the same base sector are only
+// considered shared if they point to another pubfile in the sector.
+func TestSharesBaseSector(t *testing.T) {
+	var root crypto.Hash
+	fastrand.Read(root[:])
+	publink, err := modules.NewPublinkV1(root, 0, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := modules.NewPublinkV1(root, 4096, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unrelated, err := modules.NewPublinkV1(crypto.HashObject(root), 4096, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		publinks []string
+		shared   bool
+	}{
+		{[]string{publink.String()}, false},
+		{[]string{publink.String(), publink.String()}, false},
+		{[]string{publink.String(), unrelated.String()}, false},
+		{[]string{publink.String(), other.String()}, true},
+		{[]string{other.String()}, true},
+	}
+	for i, test := range tests {
+		if sharesBaseSector(test.publinks, publink) != test.shared {
+			t.Errorf("test %v: expected shared to be %v", i, test.shared)
+		}
+	}
+}
+
+// TestUnpinPublink checks that unpinning a publink deletes the siafiles that
+// pin it, reports their size and refuses to unpin the pubfiles of a batch
+// without force.
+func TestUnpinPublink(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// newFile creates a siafile of the given size which is pinned by the
+	// publinks.
+	_, rsc := testingFileParams()
+	newFile := func(path string, size uint64, publinks ...modules.Publink) modules.SiaPath {
+		siaPath, err := modules.NewSiaPath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = r.staticFileSystem.NewSiaFile(siaPath, "", rsc, crypto.GenerateSiaKey(crypto.TypePlain), size, persist.DefaultDiskPermissionsTest, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node, err := r.staticFileSystem.OpenSiaFile(siaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer node.Close()
+		for _, publink := range publinks {
+			if err := node.AddPublink(publink); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return siaPath
+	}
+	newPublink := func(offset uint64) modules.Publink {
+		var root crypto.Hash
+		fastrand.Read(root[:])
+		publink, err := modules.NewPublinkV1(root, offset, 4096)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return publink
+	}
+
+	// A large pubfile is pinned by its base siafile and the extended siafile,
+	// both count towards the freed bytes.
+	large := newPublink(0)
+	base := newFile("large", 1000, large)
+	extended := newFile("large"+ExtendedSuffix, 3000)
+	siaPaths, freed, err := r.UnpinPublink(large, false, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(siaPaths) != 2 || !siaPaths[0].Equals(base) || !siaPaths[1].Equals(extended) {
+		t.Fatal("unexpected siapaths", siaPaths)
+	}
+	if freed != 4000 {
+		t.Fatal("expected 4000 freed bytes but got", freed)
+	}
+
+	// The pubfiles of a batch share their sector.
+	first := newPublink(0)
+	second, err := modules.NewPublinkV1(first.MerkleRoot(), 4096, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packed := newFile("packed-0", 2000, first, second)
+	_, _, err = r.UnpinPublink(first, false, time.Minute)
+	if !errors.Contains(err, ErrPublinkSectorShared) {
+		t.Fatal("expected ErrPublinkSectorShared but got:", err)
+	}
+	if _, err := r.File(packed); err != nil {
+		t.Fatal("batch siafile was deleted:", err)
+	}
+	siaPaths, freed, err = r.UnpinPublink(second, true, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(siaPaths) != 1 || !siaPaths[0].Equals(packed) || freed != 2000 {
+		t.Fatal("unexpected unpin result", siaPaths, freed)
+	}
+}
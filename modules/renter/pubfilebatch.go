@@ -0,0 +1,186 @@
+package renter
+
+// pubfilebatch.go packs many small pubfiles into shared sectors. Every file is
+// encoded like the base sector of a small pubfile, consisting of the layout,
+// the metadata and the file data. Instead of padding each of them to a full
+// sector, the encoded files are placed next to each other. The publink of a
+// file then points to the offset of the file within the shared sector, so
+// every file is placed at an offset that is aligned according to the offset
+// rules of v1 publinks for the size of the file.
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+var (
+	// errEmptyBatch is returned if a batch upload doesn't contain any files.
+	errEmptyBatch = errors.New("batch upload needs to contain at least one file")
+)
+
+// packedSkyfile is a file within a packed sector.
+type packedSkyfile struct {
+	publink   modules.Publink
+	placement modules.FilePlacement
+}
+
+// skyfileEncodePacked encodes a file of a batch upload into the layout,
+// metadata and data that make up a small pubfile, without padding it to a full
+// sector.
+func skyfileEncodePacked(file modules.PubfileBatchFile) ([]byte, error) {
+	md := file.Metadata
+	md.Length = uint64(len(file.Data))
+	metadataBytes, err := skyfileMetadataBytes(md)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to retrieve pubfile metadata bytes")
+	}
+	ll := skyfileLayout{
+		version:      SkyfileVersion,
+		filesize:     uint64(len(file.Data)),
+		metadataSize: uint64(len(metadataBytes)),
+		cipherType:   crypto.TypePlain,
+	}
+	encoded := make([]byte, 0, SkyfileLayoutSize+len(metadataBytes)+len(file.Data))
+	encoded = append(encoded, ll.encode()...)
+	encoded = append(encoded, metadataBytes...)
+	encoded = append(encoded, file.Data...)
+	return encoded, nil
+}
+
+// skyfileBuildPackedSectors packs the provided files into as few sectors as
+// possible. It returns the sectors and the location of every file, in the same
+// order as the input files.
+func skyfileBuildPackedSectors(files []modules.PubfileBatchFile) ([][]byte, []packedSkyfile, error) {
+	if len(files) == 0 {
+		return nil, nil, errEmptyBatch
+	}
+
+	// Encode all of the files. The files are identified by their index when
+	// they are packed.
+	encoded := make([][]byte, len(files))
+	sizes := make(map[string]uint64, len(files))
+	for i, file := range files {
+		b, err := skyfileEncodePacked(file)
+		if err != nil {
+			return nil, nil, errors.AddContext(err, fmt.Sprintf("unable to encode file %v", i))
+		}
+		encoded[i] = b
+		sizes[strconv.Itoa(i)] = uint64(len(b))
+	}
+
+	// Compute the placements.
+	placements, numSectors, err := modules.PackFiles(sizes)
+	if err != nil {
+		return nil, nil, errors.AddContext(err, "unable to pack files")
+	}
+
+	// Copy the files into the sectors.
+	sectors := make([][]byte, numSectors)
+	for i := range sectors {
+		sectors[i] = make([]byte, modules.SectorSize)
+	}
+	packed := make([]packedSkyfile, len(files))
+	for _, p := range placements {
+		i, err := strconv.Atoi(p.FileID)
+		if err != nil {
+			return nil, nil, errors.AddContext(err, "unable to parse packed file id")
+		}
+		copy(sectors[p.SectorIndex][p.SectorOffset:], encoded[i])
+		packed[i].placement = p
+	}
+
+	// Create the publinks now that the sectors are final.
+	roots := make([]crypto.Hash, numSectors)
+	for i, sector := range sectors {
+		roots[i] = crypto.MerkleRoot(sector)
+	}
+	for i := range packed {
+		p := packed[i].placement
+		packed[i].publink, err = modules.NewPublinkV1(roots[p.SectorIndex], p.SectorOffset, uint64(len(encoded[i])))
+		if err != nil {
+			return nil, nil, errors.AddContext(err, fmt.Sprintf("unable to create publink for file %v", i))
+		}
+	}
+	return sectors, packed, nil
+}
+
+// managedUploadPackedSector uploads a packed sector and adds the publinks of
+// all of the files within the sector to the siafile that tracks it.
+func (r *Renter) managedUploadPackedSector(lup modules.PubfileUploadParameters, sector []byte, publinks []modules.Publink) error {
+	fileUploadParams, err := fileUploadParamsFromLUP(lup)
+	if err != nil {
+		return errors.AddContext(err, "failed to create siafile upload parameters")
+	}
+	fileUploadParams.CipherType = crypto.TypePlain
+
+	fileNode, err := r.callUploadStreamFromReader(fileUploadParams, bytes.NewReader(sector))
+	if err != nil {
+		return errors.AddContext(err, "failed to stream upload packed sector")
+	}
+	defer fileNode.Close()
+
+	for _, publink := range publinks {
+		err = fileNode.AddPublink(publink)
+		if err != nil {
+			return errors.AddContext(err, "unable to add publink to siafile")
+		}
+	}
+	return nil
+}
+
+// UploadSkyfileBatch packs many small files into shared sectors and uploads
+// them, returning one publink per file. Every file, including its metadata,
+// needs to fit within a single sector.
+func (r *Renter) UploadSkyfileBatch(bup modules.PubfileBatchUploadParameters) ([]modules.Publink, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+
+	if bup.BaseChunkRedundancy == 0 {
+		bup.BaseChunkRedundancy = SkyfileDefaultBaseChunkRedundancy
+	}
+
+	sectors, packed, err := skyfileBuildPackedSectors(bup.Files)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to build packed sectors")
+	}
+
+	// Check the publinks against the blacklist and group them by sector.
+	publinks := make([]modules.Publink, len(packed))
+	sectorPublinks := make([][]modules.Publink, len(sectors))
+	for i, pf := range packed {
+		if r.staticSkynetBlacklist.IsBlacklisted(pf.publink) {
+			return nil, errors.AddContext(ErrPublinkBlacklisted, fmt.Sprintf("file %v", i))
+		}
+		publinks[i] = pf.publink
+		sectorPublinks[pf.placement.SectorIndex] = append(sectorPublinks[pf.placement.SectorIndex], pf.publink)
+	}
+	if bup.DryRun {
+		return publinks, nil
+	}
+
+	// Upload the sectors.
+	for i, sector := range sectors {
+		siaPath, err := bup.SiaPath.Join(fmt.Sprintf("packed-%v", i))
+		if err != nil {
+			return nil, errors.AddContext(err, "unable to create siapath for packed sector")
+		}
+		lup := modules.PubfileUploadParameters{
+			SiaPath:             siaPath,
+			Force:               bup.Force,
+			BaseChunkRedundancy: bup.BaseChunkRedundancy,
+		}
+		err = r.managedUploadPackedSector(lup, sector, sectorPublinks[i])
+		if err != nil {
+			return nil, errors.AddContext(err, fmt.Sprintf("unable to upload packed sector %v", i))
+		}
+	}
+	return publinks, nil
+}
@@ -0,0 +1,83 @@
+package renter
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+
+	"gitlab.com/NebulousLabs/fastrand"
+)
+
+// TestSkyfileBuildPackedSectors checks that files packed into shared sectors
+// can be recovered using the offset and fetch size of their publinks.
+func TestSkyfileBuildPackedSectors(t *testing.T) {
+	// An empty batch is not allowed.
+	_, _, err := skyfileBuildPackedSectors(nil)
+	if err != errEmptyBatch {
+		t.Fatal("expected errEmptyBatch, got", err)
+	}
+
+	// Create a few small files.
+	var files []modules.PubfileBatchFile
+	for i := 0; i < 5; i++ {
+		files = append(files, modules.PubfileBatchFile{
+			Metadata: modules.PubfileMetadata{
+				Filename: fmt.Sprintf("file%v", i),
+			},
+			Data: fastrand.Bytes(fastrand.Intn(100) + 1),
+		})
+	}
+	sectors, packed, err := skyfileBuildPackedSectors(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packed) != len(files) {
+		t.Fatalf("expected %v packed files, got %v", len(files), len(packed))
+	}
+	if uint64(len(sectors)) > uint64(len(files)) {
+		t.Fatal("packing should never need more sectors than files", len(sectors))
+	}
+
+	// Every file should be recoverable from its sector.
+	for i, pf := range packed {
+		sector := sectors[pf.placement.SectorIndex]
+		if pf.publink.MerkleRoot() != crypto.MerkleRoot(sector) {
+			t.Fatal("publink doesn't point to the packed sector")
+		}
+		offset, fetchSize, err := pf.publink.OffsetAndFetchSize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offset != pf.placement.SectorOffset {
+			t.Fatal("publink offset doesn't match placement", offset, pf.placement.SectorOffset)
+		}
+		if offset+fetchSize > uint64(len(sector)) {
+			t.Fatal("publink fetches beyond the end of the sector")
+		}
+		_, _, md, payload, err := parseSkyfileMetadata(sector[offset : offset+fetchSize])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if md.Filename != files[i].Metadata.Filename {
+			t.Fatal("wrong filename", md.Filename, files[i].Metadata.Filename)
+		}
+		if md.Length != uint64(len(files[i].Data)) {
+			t.Fatal("wrong length", md.Length, len(files[i].Data))
+		}
+		if !bytes.Equal(payload, files[i].Data) {
+			t.Fatal("recovered data doesn't match")
+		}
+	}
+
+	// A file that doesn't fit into a sector should be rejected.
+	files = append(files, modules.PubfileBatchFile{
+		Data: fastrand.Bytes(int(modules.SectorSize)),
+	})
+	_, _, err = skyfileBuildPackedSectors(files)
+	if err == nil {
+		t.Fatal("expected error for file that exceeds sector size")
+	}
+}
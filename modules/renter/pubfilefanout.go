@@ -45,8 +45,10 @@ type fanoutStreamBufferDataSource struct {
 
 // newFanoutStreamer will create a modules.Streamer from the fanout of a
 // pubfile. The streamer is created by implementing the streamBufferDataSource
-// interface on the pubfile, and then passing that to the stream buffer set.
-func (r *Renter) newFanoutStreamer(link modules.Publink, ll skyfileLayout, metadata modules.PubfileMetadata, fanoutBytes []byte, timeout time.Duration, sk pubaccesskey.Pubaccesskey) (modules.Streamer, error) {
+// interface on the pubfile, and then passing that to the stream buffer set. The
+// prefetch is the number of chunks the streamer fetches in parallel ahead of
+// the chunk that is currently being read.
+func (r *Renter) newFanoutStreamer(link modules.Publink, ll skyfileLayout, metadata modules.PubfileMetadata, fanoutBytes []byte, timeout time.Duration, prefetch uint64, sk pubaccesskey.Pubaccesskey) (modules.Streamer, error) {
 	masterKey, err := r.deriveFanoutKey(&ll, sk)
 	if err != nil {
 		return nil, errors.AddContext(err, "count not recover siafile fanout because cipher key was unavailable")
@@ -75,7 +77,7 @@ func (r *Renter) newFanoutStreamer(link modules.Publink, ll skyfileLayout, metad
 	}
 
 	// Grab and return the stream.
-	stream := r.staticStreamBufferSet.callNewStream(fs, 0)
+	stream := r.staticStreamBufferSet.callNewStream(fs, 0, prefetch)
 	return stream, nil
 }
 
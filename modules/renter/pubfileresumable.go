@@ -0,0 +1,493 @@
+package renter
+
+// pubfileresumable.go implements resumable pubfile uploads. The data of a
+// resumable upload is received in chunks and staged in the renter directory.
+// Every chunk is synced to disk before the progress of the upload is
+// persisted, so an interrupted upload can be continued from the last committed
+// chunk, even after the renter was restarted. Once all the data was received,
+// the staged data is uploaded as a regular pubfile and removed. Uploads that
+// don't receive data for a while expire and are removed periodically, and the
+// combined length of the staged uploads is limited by the renter settings.
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/persist"
+	"github.com/EvilRedHorse/pubaccess-node/pubaccesskey"
+)
+
+const (
+	// resumableUploadsDir is the directory within the renter directory in
+	// which the data of resumable uploads is staged.
+	resumableUploadsDir = "pubaccessuploads"
+
+	// resumableUploadDataExt and resumableUploadPersistExt are the extensions
+	// of the files containing the staged data and the persisted state of a
+	// resumable upload.
+	resumableUploadDataExt    = ".data"
+	resumableUploadPersistExt = ".json"
+)
+
+var (
+	// ErrUnknownResumableUpload is returned if there is no resumable upload
+	// with the given id.
+	ErrUnknownResumableUpload = errors.New("unknown resumable upload")
+
+	// ErrResumableUploadOffset is returned if data is appended to a resumable
+	// upload at an offset which doesn't match the number of uploaded bytes.
+	ErrResumableUploadOffset = errors.New("offset doesn't match the uploaded data of the resumable upload")
+
+	// errResumableUploadBusy is returned if data is appended to a resumable
+	// upload, or the upload is aborted, while it is receiving data.
+	errResumableUploadBusy = errors.New("resumable upload is already receiving data")
+
+	// ErrResumableUploadsLimit is returned if a resumable upload is created
+	// while the combined length of the unfinished uploads would exceed the
+	// limit of the renter settings.
+	ErrResumableUploadsLimit = errors.New("resumable uploads exceed the staging limit")
+
+	// errResumableUploadTooLarge is returned if more data is appended to a
+	// resumable upload than its length allows for.
+	errResumableUploadTooLarge = errors.New("data exceeds the length of the resumable upload")
+
+	// resumableUploadMetadata is the metadata of the persisted state of a
+	// resumable upload.
+	resumableUploadMetadata = persist.Metadata{
+		Header:  "Pubaccess Resumable Upload",
+		Version: "1.5.0",
+	}
+)
+
+type (
+	// resumableUploads manages the resumable pubfile uploads of the renter.
+	resumableUploads struct {
+		uploads   map[string]*resumableUpload
+		staticDir string
+		mu        sync.Mutex
+	}
+
+	// resumableUpload is a single resumable upload. An upload is busy while
+	// it receives data or while its pubfile is uploaded.
+	resumableUpload struct {
+		resumableUploadPersist
+		busy bool
+	}
+
+	// resumableUploadPersist is the persisted state of a resumable upload. It
+	// contains the upload parameters that are needed to upload the pubfile
+	// once all the data was received.
+	resumableUploadPersist struct {
+		Status              modules.PubfileUploadStatus `json:"status"`
+		Force               bool                        `json:"force"`
+		BaseChunkRedundancy uint8                       `json:"basechunkredundancy"`
+		FileMetadata        modules.PubfileMetadata     `json:"filemetadata"`
+		SkykeyName          string                      `json:"skykeyname"`
+		PubaccesskeyID      pubaccesskey.PubaccesskeyID `json:"pubaccesskeyid"`
+	}
+)
+
+// newResumableUploads loads the resumable uploads staged in the given renter
+// directory. Uploads that haven't received data within the
+// resumableUploadExpiry are removed.
+func newResumableUploads(persistDir string) (*resumableUploads, error) {
+	ru := &resumableUploads{
+		uploads:   make(map[string]*resumableUpload),
+		staticDir: filepath.Join(persistDir, resumableUploadsDir),
+	}
+	if err := os.MkdirAll(ru.staticDir, modules.DefaultDirPerm); err != nil {
+		return nil, errors.AddContext(err, "unable to create resumable uploads directory")
+	}
+	fis, err := ioutil.ReadDir(ru.staticDir)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to read resumable uploads directory")
+	}
+	for _, fi := range fis {
+		if !strings.HasSuffix(fi.Name(), resumableUploadPersistExt) {
+			continue
+		}
+		uploadID := strings.TrimSuffix(fi.Name(), resumableUploadPersistExt)
+		var up resumableUploadPersist
+		err := persist.LoadJSON(resumableUploadMetadata, &up, ru.persistPath(uploadID))
+		if err != nil {
+			return nil, errors.AddContext(err, "unable to load resumable upload "+uploadID)
+		}
+		if time.Since(up.Status.LastUpdate) > resumableUploadExpiry {
+			if err := ru.removeUpload(uploadID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// Only the data that was committed is used, anything that was written
+		// after the last commit is overwritten by the next chunk.
+		dataInfo, err := os.Stat(ru.dataPath(uploadID))
+		if err != nil {
+			return nil, errors.AddContext(err, "unable to find data of resumable upload "+uploadID)
+		}
+		if uint64(dataInfo.Size()) < up.Status.Uploaded {
+			return nil, fmt.Errorf("resumable upload %v is missing committed data", uploadID)
+		}
+		ru.uploads[uploadID] = &resumableUpload{resumableUploadPersist: up}
+	}
+	return ru, nil
+}
+
+// dataPath returns the path of the staged data of an upload.
+func (ru *resumableUploads) dataPath(uploadID string) string {
+	return filepath.Join(ru.staticDir, uploadID+resumableUploadDataExt)
+}
+
+// persistPath returns the path of the persisted state of an upload.
+func (ru *resumableUploads) persistPath(uploadID string) string {
+	return filepath.Join(ru.staticDir, uploadID+resumableUploadPersistExt)
+}
+
+// removeUpload removes the staged data and persisted state of an upload from
+// disk.
+func (ru *resumableUploads) removeUpload(uploadID string) error {
+	err1 := os.Remove(ru.dataPath(uploadID))
+	if os.IsNotExist(err1) {
+		err1 = nil
+	}
+	err2 := persist.RemoveFile(ru.persistPath(uploadID))
+	return errors.AddContext(errors.Compose(err1, err2), "unable to remove resumable upload "+uploadID)
+}
+
+// save persists the state of an upload.
+//
+// NOTE: the caller has to hold the lock of the uploads.
+func (ru *resumableUploads) save(u *resumableUpload) error {
+	err := persist.SaveJSON(resumableUploadMetadata, u.resumableUploadPersist, ru.persistPath(u.Status.UploadID))
+	return errors.AddContext(err, "unable to persist resumable upload")
+}
+
+// callCreate creates a new resumable upload of a pubfile with the given
+// length. The full length of every unfinished upload counts towards the
+// maxStaged limit, since that much data will be staged once it is received.
+func (ru *resumableUploads) callCreate(lup modules.PubfileUploadParameters, length, maxStaged uint64) (modules.PubfileUploadStatus, error) {
+	uploadID := hex.EncodeToString(fastrand.Bytes(16))
+	now := time.Now()
+	u := &resumableUpload{
+		resumableUploadPersist: resumableUploadPersist{
+			Status: modules.PubfileUploadStatus{
+				UploadID:   uploadID,
+				SiaPath:    lup.SiaPath,
+				Filename:   lup.FileMetadata.Filename,
+				Length:     length,
+				CreatedAt:  now,
+				LastUpdate: now,
+			},
+			Force:               lup.Force,
+			BaseChunkRedundancy: lup.BaseChunkRedundancy,
+			FileMetadata:        lup.FileMetadata,
+			SkykeyName:          lup.SkykeyName,
+			PubaccesskeyID:      lup.PubaccesskeyID,
+		},
+	}
+
+	// Create the file for the staged data before persisting the upload.
+	f, err := os.OpenFile(ru.dataPath(uploadID), os.O_RDWR|os.O_CREATE|os.O_EXCL, modules.DefaultFilePerm)
+	if err != nil {
+		return modules.PubfileUploadStatus{}, errors.AddContext(err, "unable to create resumable upload data file")
+	}
+	if err := f.Close(); err != nil {
+		return modules.PubfileUploadStatus{}, errors.AddContext(err, "unable to create resumable upload data file")
+	}
+
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+	staged := length
+	for _, u := range ru.uploads {
+		staged += u.Status.Length
+	}
+	if staged > maxStaged || staged < length {
+		err := errors.AddContext(ErrResumableUploadsLimit, fmt.Sprintf("%v bytes are staged, the limit is %v", staged-length, maxStaged))
+		return modules.PubfileUploadStatus{}, errors.Compose(err, ru.removeUpload(uploadID))
+	}
+	if err := ru.save(u); err != nil {
+		return modules.PubfileUploadStatus{}, errors.Compose(err, ru.removeUpload(uploadID))
+	}
+	ru.uploads[uploadID] = u
+	return u.Status, nil
+}
+
+// callStatus returns the status of an upload.
+func (ru *resumableUploads) callStatus(uploadID string) (modules.PubfileUploadStatus, error) {
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+	u, exists := ru.uploads[uploadID]
+	if !exists {
+		return modules.PubfileUploadStatus{}, errors.AddContext(ErrUnknownResumableUpload, uploadID)
+	}
+	return u.Status, nil
+}
+
+// callStatuses returns the status of all uploads, sorted by their creation
+// time.
+func (ru *resumableUploads) callStatuses() []modules.PubfileUploadStatus {
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+	statuses := make([]modules.PubfileUploadStatus, 0, len(ru.uploads))
+	for _, u := range ru.uploads {
+		statuses = append(statuses, u.Status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if !statuses[i].CreatedAt.Equal(statuses[j].CreatedAt) {
+			return statuses[i].CreatedAt.Before(statuses[j].CreatedAt)
+		}
+		return statuses[i].UploadID < statuses[j].UploadID
+	})
+	return statuses
+}
+
+// callAbort aborts an upload and removes its staged data.
+func (ru *resumableUploads) callAbort(uploadID string) error {
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+	u, exists := ru.uploads[uploadID]
+	if !exists {
+		return errors.AddContext(ErrUnknownResumableUpload, uploadID)
+	}
+	if u.busy {
+		return errResumableUploadBusy
+	}
+	delete(ru.uploads, uploadID)
+	return ru.removeUpload(uploadID)
+}
+
+// callPruneExpired removes the uploads that haven't received data within the
+// resumableUploadExpiry. Uploads that are busy aren't removed.
+func (ru *resumableUploads) callPruneExpired() error {
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+	var errs []error
+	for uploadID, u := range ru.uploads {
+		if u.busy || time.Since(u.Status.LastUpdate) <= resumableUploadExpiry {
+			continue
+		}
+		delete(ru.uploads, uploadID)
+		errs = append(errs, ru.removeUpload(uploadID))
+	}
+	return errors.Compose(errs...)
+}
+
+// managedAppend appends the data of the reader to the upload at the given
+// offset. If the reader fails, the data that was read up to that point is
+// still committed. Once all the data of the upload was received, the pubfile
+// is uploaded using the upload function and the upload is removed.
+func (ru *resumableUploads) managedAppend(uploadID string, offset uint64, data io.Reader, upload func(modules.PubfileUploadParameters) (modules.Publink, error)) (modules.PubfileUploadStatus, error) {
+	ru.mu.Lock()
+	u, exists := ru.uploads[uploadID]
+	if !exists {
+		ru.mu.Unlock()
+		return modules.PubfileUploadStatus{}, errors.AddContext(ErrUnknownResumableUpload, uploadID)
+	}
+	if u.busy {
+		ru.mu.Unlock()
+		return modules.PubfileUploadStatus{}, errResumableUploadBusy
+	}
+	if offset != u.Status.Uploaded {
+		status := u.Status
+		ru.mu.Unlock()
+		return status, errors.AddContext(ErrResumableUploadOffset, fmt.Sprintf("expected offset %v but got %v", status.Uploaded, offset))
+	}
+	u.busy = true
+	length := u.Status.Length
+	ru.mu.Unlock()
+
+	defer func() {
+		ru.mu.Lock()
+		u.busy = false
+		ru.mu.Unlock()
+	}()
+
+	// Write the data without holding the lock.
+	n, writeErr := ru.writeData(uploadID, offset, length-offset, data)
+
+	ru.mu.Lock()
+	if n > 0 {
+		u.Status.Uploaded += n
+		u.Status.LastUpdate = time.Now()
+		writeErr = errors.Compose(writeErr, ru.save(u))
+	}
+	status := u.Status
+	ru.mu.Unlock()
+	if writeErr != nil {
+		return status, errors.AddContext(writeErr, "unable to append data to resumable upload")
+	}
+	if status.Uploaded < status.Length {
+		return status, nil
+	}
+
+	// All the data was received, upload the pubfile.
+	f, err := os.Open(ru.dataPath(uploadID))
+	if err != nil {
+		return status, errors.AddContext(err, "unable to open resumable upload data")
+	}
+	publink, err := upload(modules.PubfileUploadParameters{
+		SiaPath:             status.SiaPath,
+		Force:               u.Force,
+		BaseChunkRedundancy: u.BaseChunkRedundancy,
+		FileMetadata:        u.FileMetadata,
+		Reader:              io.LimitReader(f, int64(status.Length)),
+		SkykeyName:          u.SkykeyName,
+		PubaccesskeyID:      u.PubaccesskeyID,
+	})
+	err = errors.Compose(err, f.Close())
+	if err != nil {
+		return status, errors.AddContext(err, "unable to upload pubfile of resumable upload")
+	}
+	status.Publink = publink.String()
+
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+	delete(ru.uploads, uploadID)
+	return status, ru.removeUpload(uploadID)
+}
+
+// writeData writes up to maxLen bytes of the reader to the staged data of an
+// upload at the given offset and syncs them to disk. It returns the number of
+// bytes that were written and synced. If the reader contains more than maxLen
+// bytes, nothing is written.
+func (ru *resumableUploads) writeData(uploadID string, offset, maxLen uint64, data io.Reader) (_ uint64, err error) {
+	f, err := os.OpenFile(ru.dataPath(uploadID), os.O_WRONLY, modules.DefaultFilePerm)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		err = errors.Compose(err, f.Close())
+	}()
+
+	// Drop any data that was written after the last commit.
+	if err := f.Truncate(int64(offset)); err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(int64(offset), io.SeekStart); err != nil {
+		return 0, err
+	}
+	written, readErr := io.Copy(f, io.LimitReader(data, int64(maxLen)))
+	n := uint64(written)
+	if readErr == nil && n == maxLen {
+		// Make sure the reader doesn't contain more data.
+		extra, _ := io.ReadFull(data, make([]byte, 1))
+		if extra > 0 {
+			return 0, errResumableUploadTooLarge
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	return n, readErr
+}
+
+// CreateResumableSkyfileUpload creates a resumable upload of a pubfile with
+// the given length.
+func (r *Renter) CreateResumableSkyfileUpload(lup modules.PubfileUploadParameters, length uint64) (modules.PubfileUploadStatus, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.PubfileUploadStatus{}, err
+	}
+	defer r.tg.Done()
+
+	if lup.DryRun {
+		return modules.PubfileUploadStatus{}, errors.New("resumable uploads can't be dry runs")
+	}
+	if len(lup.FileMetadata.Subfiles) > 0 {
+		return modules.PubfileUploadStatus{}, errors.New("resumable uploads can't contain subfiles")
+	}
+	if length == 0 {
+		return modules.PubfileUploadStatus{}, errors.New("resumable uploads need to contain data")
+	}
+
+	// Check that the pubaccesskey exists before any data is sent.
+	if lup.SkykeyName != "" {
+		if _, err := r.SkykeyByName(lup.SkykeyName); err != nil {
+			return modules.PubfileUploadStatus{}, errors.AddContext(err, "unable to get pubaccesskey")
+		}
+	} else if encryptionEnabled(lup) {
+		if _, err := r.SkykeyByID(lup.PubaccesskeyID); err != nil {
+			return modules.PubfileUploadStatus{}, errors.AddContext(err, "unable to get pubaccesskey")
+		}
+	}
+	id := r.mu.RLock()
+	maxStaged := r.persist.MaxResumableUploadBytes
+	r.mu.RUnlock(id)
+	return r.staticResumableUploads.callCreate(lup, length, maxStaged)
+}
+
+// AppendResumableSkyfileUpload appends data to a resumable upload. Once all
+// the data was received, the pubfile is uploaded and the returned status
+// contains its publink.
+func (r *Renter) AppendResumableSkyfileUpload(uploadID string, offset uint64, data io.Reader) (modules.PubfileUploadStatus, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.PubfileUploadStatus{}, err
+	}
+	defer r.tg.Done()
+	return r.staticResumableUploads.managedAppend(uploadID, offset, data, r.UploadSkyfile)
+}
+
+// ResumableSkyfileUpload returns the status of a resumable upload.
+func (r *Renter) ResumableSkyfileUpload(uploadID string) (modules.PubfileUploadStatus, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.PubfileUploadStatus{}, err
+	}
+	defer r.tg.Done()
+	return r.staticResumableUploads.callStatus(uploadID)
+}
+
+// ResumableSkyfileUploads returns the status of all unfinished resumable
+// uploads.
+func (r *Renter) ResumableSkyfileUploads() ([]modules.PubfileUploadStatus, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+	return r.staticResumableUploads.callStatuses(), nil
+}
+
+// AbortResumableSkyfileUpload aborts a resumable upload and removes its staged
+// data.
+func (r *Renter) AbortResumableSkyfileUpload(uploadID string) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	return r.staticResumableUploads.callAbort(uploadID)
+}
+
+// threadedPruneResumableUploadsLoop periodically removes the resumable uploads
+// that expired.
+func (r *Renter) threadedPruneResumableUploadsLoop() {
+	for {
+		select {
+		case <-r.tg.StopChan():
+			return
+		case <-time.After(resumableUploadPruneInterval):
+		}
+		r.threadedPruneResumableUploads()
+	}
+}
+
+// threadedPruneResumableUploads removes the resumable uploads that expired.
+func (r *Renter) threadedPruneResumableUploads() {
+	err := r.tg.Add()
+	if err != nil {
+		return
+	}
+	defer r.tg.Done()
+	err = r.staticResumableUploads.callPruneExpired()
+	if err != nil {
+		r.log.Println("WARN: unable to remove expired resumable uploads:", err)
+	}
+}
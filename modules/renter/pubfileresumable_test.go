@@ -0,0 +1,266 @@
+package renter
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"github.com/EvilRedHorse/pubaccess-node/build"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/persist"
+)
+
+// TestResumableUploads tests appending data to resumable uploads, resuming
+// them after reloading and uploading the pubfile once all data was received.
+func TestResumableUploads(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	testdir := build.TempDir("renter", t.Name())
+	ru, err := newResumableUploads(testdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create an upload.
+	data := fastrand.Bytes(1000)
+	siaPath := modules.RandomSiaPath()
+	lup := modules.PubfileUploadParameters{
+		SiaPath:             siaPath,
+		BaseChunkRedundancy: 2,
+		FileMetadata:        modules.PubfileMetadata{Filename: "file", Mode: 0640},
+	}
+	status, err := ru.callCreate(lup, uint64(len(data)), DefaultMaxResumableUploadBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadID := status.UploadID
+
+	// The upload function records the parameters and data of the pubfile.
+	var uploaded modules.PubfileUploadParameters
+	var uploadedData []byte
+	upload := func(lup modules.PubfileUploadParameters) (modules.Publink, error) {
+		uploaded = lup
+		uploadedData, err = ioutil.ReadAll(lup.Reader)
+		return modules.Publink{}, err
+	}
+
+	// Append the first chunk.
+	status, err = ru.managedAppend(uploadID, 0, bytes.NewReader(data[:300]), upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Uploaded != 300 || status.Publink != "" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	// Appending at the wrong offset fails.
+	_, err = ru.managedAppend(uploadID, 200, bytes.NewReader(data[200:400]), upload)
+	if !errors.Contains(err, ErrResumableUploadOffset) {
+		t.Fatal("expected offset error but got", err)
+	}
+
+	// Appending too much data fails without committing any of it.
+	_, err = ru.managedAppend(uploadID, 300, bytes.NewReader(append(data[300:], 0)), upload)
+	if !errors.Contains(err, errResumableUploadTooLarge) {
+		t.Fatal("expected too large error but got", err)
+	}
+
+	// An interrupted chunk commits the data that was received.
+	interrupted := io.MultiReader(bytes.NewReader(data[300:500]), &errReader{})
+	status, err = ru.managedAppend(uploadID, 300, interrupted, upload)
+	if err == nil || status.Uploaded != 500 {
+		t.Fatalf("expected interrupted chunk to be partially committed: %+v %v", status, err)
+	}
+
+	// The progress is persisted.
+	ru, err = newResumableUploads(testdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err = ru.callStatus(uploadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Uploaded != 500 || status.Length != uint64(len(data)) || status.SiaPath != siaPath || status.Filename != "file" {
+		t.Fatalf("unexpected status after reloading %+v", status)
+	}
+	if len(ru.callStatuses()) != 1 {
+		t.Fatal("expected 1 upload but got", len(ru.callStatuses()))
+	}
+
+	// If the pubfile upload fails, the data is kept and the upload can be
+	// retried.
+	failingUpload := func(modules.PubfileUploadParameters) (modules.Publink, error) {
+		return modules.Publink{}, errors.New("upload failed")
+	}
+	status, err = ru.managedAppend(uploadID, 500, bytes.NewReader(data[500:]), failingUpload)
+	if err == nil || status.Uploaded != uint64(len(data)) {
+		t.Fatalf("expected failed upload: %+v %v", status, err)
+	}
+	status, err = ru.managedAppend(uploadID, uint64(len(data)), bytes.NewReader(nil), upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Publink == "" {
+		t.Fatal("expected publink")
+	}
+	if !bytes.Equal(uploadedData, data) {
+		t.Fatal("uploaded data doesn't match")
+	}
+	if uploaded.SiaPath != siaPath || uploaded.BaseChunkRedundancy != 2 || uploaded.FileMetadata.Mode != 0640 {
+		t.Fatalf("unexpected upload parameters %+v", uploaded)
+	}
+
+	// The finished upload is removed.
+	if _, err := ru.callStatus(uploadID); !errors.Contains(err, ErrUnknownResumableUpload) {
+		t.Fatal("expected unknown upload error but got", err)
+	}
+	if _, err := os.Stat(ru.dataPath(uploadID)); !os.IsNotExist(err) {
+		t.Fatal("expected staged data to be removed", err)
+	}
+
+	// Aborting an upload removes it.
+	status, err = ru.callCreate(lup, 10, DefaultMaxResumableUploadBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ru.callAbort(status.UploadID); err != nil {
+		t.Fatal(err)
+	}
+	if err := ru.callAbort(status.UploadID); !errors.Contains(err, ErrUnknownResumableUpload) {
+		t.Fatal("expected unknown upload error but got", err)
+	}
+
+	// Expired uploads are removed when loading the uploads.
+	status, err = ru.callCreate(lup, 10, DefaultMaxResumableUploadBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := ru.uploads[status.UploadID]
+	u.Status.LastUpdate = time.Now().Add(-resumableUploadExpiry - time.Minute)
+	err = persist.SaveJSON(resumableUploadMetadata, u.resumableUploadPersist, ru.persistPath(status.UploadID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ru, err = newResumableUploads(testdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ru.callStatuses()) != 0 {
+		t.Fatal("expected expired upload to be removed")
+	}
+
+	// Expired uploads are also removed while the renter is running, unless
+	// they are busy.
+	expired, err := ru.callCreate(lup, 10, DefaultMaxResumableUploadBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	busy, err := ru.callCreate(lup, 10, DefaultMaxResumableUploadBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	active, err := ru.callCreate(lup, 10, DefaultMaxResumableUploadBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ru.uploads[expired.UploadID].Status.LastUpdate = time.Now().Add(-resumableUploadExpiry - time.Minute)
+	ru.uploads[busy.UploadID].Status.LastUpdate = time.Now().Add(-resumableUploadExpiry - time.Minute)
+	ru.uploads[busy.UploadID].busy = true
+	if err := ru.callPruneExpired(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ru.callStatus(expired.UploadID); !errors.Contains(err, ErrUnknownResumableUpload) {
+		t.Fatal("expected expired upload to be removed", err)
+	}
+	if _, err := os.Stat(ru.dataPath(expired.UploadID)); !os.IsNotExist(err) {
+		t.Fatal("expected data of expired upload to be removed", err)
+	}
+	for _, uploadID := range []string{busy.UploadID, active.UploadID} {
+		if _, err := ru.callStatus(uploadID); err != nil {
+			t.Fatal("upload shouldn't be removed", err)
+		}
+	}
+}
+
+// TestResumableUploadsLimit checks that resumable uploads can't be created
+// once the combined length of the unfinished uploads exceeds the limit.
+func TestResumableUploadsLimit(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	testdir := build.TempDir("renter", t.Name())
+	if err := os.RemoveAll(testdir); err != nil {
+		t.Fatal(err)
+	}
+	ru, err := newResumableUploads(testdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lup := modules.PubfileUploadParameters{
+		SiaPath: modules.RandomSiaPath(),
+	}
+
+	// An upload that is larger than the limit is rejected.
+	_, err = ru.callCreate(lup, 101, 100)
+	if !errors.Contains(err, ErrResumableUploadsLimit) {
+		t.Fatal("expected limit error but got", err)
+	}
+
+	// The limit is reached by the length of the unfinished uploads, not by
+	// the data they received.
+	first, err := ru.callCreate(lup, 60, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ru.callCreate(lup, 41, 100)
+	if !errors.Contains(err, ErrResumableUploadsLimit) {
+		t.Fatal("expected limit error but got", err)
+	}
+	if _, err := ru.callCreate(lup, 40, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rejected uploads don't leave any staged data behind.
+	fis, err := ioutil.ReadDir(ru.staticDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var staged int
+	for _, fi := range fis {
+		if strings.HasSuffix(fi.Name(), resumableUploadDataExt) {
+			staged++
+		}
+	}
+	if staged != 2 {
+		t.Fatal("expected the data of 2 uploads but got", staged)
+	}
+
+	// Aborting an upload frees its space.
+	if err := ru.callAbort(first.UploadID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ru.callCreate(lup, 60, 100); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// errReader is a reader that always fails.
+type errReader struct{}
+
+// Read implements io.Reader.
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection lost")
+}
@@ -0,0 +1,188 @@
+package renter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/EvilRedHorse/pubaccess-node/build"
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+var (
+	// minUpdateRegistrySuccesses is the number of hosts that need to store an
+	// updated registry value for the update to be considered successful.
+	minUpdateRegistrySuccesses = build.Select(build.Var{
+		Dev:      1,
+		Standard: 3,
+		Testing:  1,
+	}).(int)
+)
+
+var (
+	// ErrRegistryUpdateNoSuccessfulUpdates is returned if none of the hosts
+	// stored the updated registry value.
+	ErrRegistryUpdateNoSuccessfulUpdates = errors.New("none of the hosts stored the registry value")
+
+	// ErrRegistryUpdateInsufficientRedundancy is returned if fewer than
+	// minUpdateRegistrySuccesses hosts stored the updated registry value.
+	ErrRegistryUpdateInsufficientRedundancy = errors.New("registry value was stored by too few hosts")
+)
+
+// registryContext creates a context for a registry project. If the timeout is
+// greater than zero, the context expires when the timeout triggers.
+func (r *Renter) registryContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(r.tg.StopCtx(), timeout)
+	}
+	return context.WithCancel(r.tg.StopCtx())
+}
+
+// asyncRegistryWorkers returns the workers which support the async protocol
+// and have a valid price table, so they are able to run registry jobs.
+func (r *Renter) asyncRegistryWorkers() []*worker {
+	workers := r.staticWorkerPool.callWorkers()
+	numAsyncWorkers := 0
+	for _, worker := range workers {
+		cache := worker.staticCache()
+		if build.VersionCmp(cache.staticHostVersion, minAsyncVersion) < 0 {
+			continue
+		}
+		if !worker.staticPriceTable().staticValid() {
+			continue
+		}
+		workers[numAsyncWorkers] = worker
+		numAsyncWorkers++
+	}
+	return workers[:numAsyncWorkers]
+}
+
+// ReadRegistry reads the registry entry with the given public key and tweak
+// from the hosts and returns the value with the highest revision number.
+func (r *Renter) ReadRegistry(spk types.SiaPublicKey, tweak crypto.Hash, timeout time.Duration) (modules.SignedRegistryValue, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.SignedRegistryValue{}, err
+	}
+	defer r.tg.Done()
+
+	ctx, cancel := r.registryContext(timeout)
+	defer cancel()
+	_, srv, err := r.managedReadRegistry(ctx, modules.RegistryEntryID(spk, tweak))
+	return srv, err
+}
+
+// UpdateRegistry stores the signed value in the registry of the hosts. The
+// update is successful once enough hosts stored the value.
+func (r *Renter) UpdateRegistry(spk types.SiaPublicKey, srv modules.SignedRegistryValue, timeout time.Duration) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	// Verify the value before paying hosts for storing it.
+	if err := srv.Verify(spk); err != nil {
+		return errors.AddContext(err, "invalid registry value")
+	}
+
+	ctx, cancel := r.registryContext(timeout)
+	defer cancel()
+	return r.managedUpdateRegistry(ctx, spk, srv)
+}
+
+// managedReadRegistry reads the registry entry with the given id from all
+// workers and returns the public key and value of the entry with the highest
+// revision number. The workers verify the signatures of the values.
+func (r *Renter) managedReadRegistry(ctx context.Context, id crypto.Hash) (types.SiaPublicKey, modules.SignedRegistryValue, error) {
+	// The response channel is buffered with one slot per worker, so that the
+	// workers do not have to block when returning the result of the job.
+	workers := r.asyncRegistryWorkers()
+	responseChan := make(chan *jobReadRegistryResponse, len(workers))
+	numJobs := 0
+	for _, worker := range workers {
+		job := worker.newJobReadRegistry(ctx.Done(), responseChan, id)
+		if !worker.staticJobReadRegistryQueue.callAdd(job) {
+			continue
+		}
+		numJobs++
+	}
+	if numJobs == 0 {
+		return types.SiaPublicKey{}, modules.SignedRegistryValue{}, errors.New("cannot perform ReadRegistry, no workers in worker pool")
+	}
+
+	// Collect the responses until every worker responded or the context
+	// expires, and keep the value with the highest revision number.
+	var best *jobReadRegistryResponse
+	var errs error
+LOOP:
+	for responses := 0; responses < numJobs; responses++ {
+		var resp *jobReadRegistryResponse
+		select {
+		case resp = <-responseChan:
+		case <-ctx.Done():
+			errs = errors.Compose(errs, errors.New("ReadRegistry timed out"))
+			break LOOP
+		}
+		if resp.staticErr != nil {
+			if !errors.Contains(resp.staticErr, modules.ErrRegistryEntryNotFound) {
+				errs = errors.Compose(errs, resp.staticErr)
+			}
+			continue
+		}
+		if best == nil || resp.staticValue.Revision > best.staticValue.Revision {
+			best = resp
+		}
+	}
+	if best == nil {
+		return types.SiaPublicKey{}, modules.SignedRegistryValue{}, errors.Compose(modules.ErrRegistryEntryNotFound, errs)
+	}
+	return best.staticPubKey, best.staticValue, nil
+}
+
+// managedUpdateRegistry stores the value in the registry of all workers and
+// waits until every worker responded or the context expires.
+func (r *Renter) managedUpdateRegistry(ctx context.Context, spk types.SiaPublicKey, srv modules.SignedRegistryValue) error {
+	// The response channel is buffered with one slot per worker, so that the
+	// workers do not have to block when returning the result of the job.
+	workers := r.asyncRegistryWorkers()
+	responseChan := make(chan *jobUpdateRegistryResponse, len(workers))
+	numJobs := 0
+	for _, worker := range workers {
+		job := worker.newJobUpdateRegistry(ctx.Done(), responseChan, spk, srv)
+		if !worker.staticJobUpdateRegistryQueue.callAdd(job) {
+			continue
+		}
+		numJobs++
+	}
+	if numJobs == 0 {
+		return errors.New("cannot perform UpdateRegistry, no workers in worker pool")
+	}
+
+	successes := 0
+	var errs error
+LOOP:
+	for responses := 0; responses < numJobs; responses++ {
+		var resp *jobUpdateRegistryResponse
+		select {
+		case resp = <-responseChan:
+		case <-ctx.Done():
+			errs = errors.Compose(errs, errors.New("UpdateRegistry timed out"))
+			break LOOP
+		}
+		if resp.staticErr != nil {
+			errs = errors.Compose(errs, resp.staticErr)
+			continue
+		}
+		successes++
+	}
+	if successes == 0 {
+		return errors.Compose(ErrRegistryUpdateNoSuccessfulUpdates, errs)
+	}
+	if successes < minUpdateRegistrySuccesses {
+		return errors.Compose(errors.AddContext(ErrRegistryUpdateInsufficientRedundancy, fmt.Sprintf("%v of %v hosts", successes, minUpdateRegistrySuccesses)), errs)
+	}
+	return nil
+}
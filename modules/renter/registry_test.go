@@ -0,0 +1,134 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EvilRedHorse/pubaccess-node/build"
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+)
+
+// TestRegistry tests updating and reading registry entries through the worker
+// pool and resolving v2 publinks.
+func TestRegistry(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// create a new worker tester
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := wt.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := wt.rt.renter
+
+	// allow the worker some time to fetch a PT and fund its EA
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if !wt.staticPriceTable().staticValid() {
+			return errors.New("price table not updated yet")
+		}
+		if wt.staticAccount.managedMinExpectedBalance().IsZero() {
+			return errors.New("account not funded yet")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+
+	// Reading a missing entry fails.
+	_, err = r.ReadRegistry(spk, tweak, time.Minute)
+	if !errors.Contains(err, modules.ErrRegistryEntryNotFound) {
+		t.Fatal("expected ErrRegistryEntryNotFound but got:", err)
+	}
+
+	// Point the entry to a v1 publink.
+	link1, err := modules.NewPublinkV1(crypto.HashObject("first"), 0, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := modules.NewRegistryValue(tweak, link1.Bytes(), 1).Sign(sk)
+	err = r.UpdateRegistry(spk, srv, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := r.ReadRegistry(spk, tweak, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Revision != 1 || string(read.Data) != string(link1.Bytes()) {
+		t.Fatal("registry value doesn't match")
+	}
+
+	// The v2 publink resolves to the v1 publink.
+	link2 := modules.NewPublinkV2(spk, tweak)
+	resolved, err := r.managedResolvePublink(link2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved != link1 {
+		t.Fatal("v2 publink resolved to the wrong publink")
+	}
+
+	// An update with the same revision number fails.
+	err = r.UpdateRegistry(spk, srv, time.Minute)
+	if err == nil {
+		t.Fatal("expected update with the same revision number to fail")
+	}
+
+	// An invalid value is rejected before contacting the hosts.
+	forged := srv
+	forged.Revision++
+	err = r.UpdateRegistry(spk, forged, time.Minute)
+	if !errors.Contains(err, modules.ErrRegistryInvalidSignature) {
+		t.Fatal("expected ErrRegistryInvalidSignature but got:", err)
+	}
+
+	// Updating the entry changes the resolved publink.
+	newLink, err := modules.NewPublinkV1(crypto.HashObject("second"), 0, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv = modules.NewRegistryValue(tweak, newLink.Bytes(), 2).Sign(sk)
+	err = r.UpdateRegistry(spk, srv, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, err = r.managedResolvePublink(link2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved != newLink {
+		t.Fatal("v2 publink didn't resolve to the updated publink")
+	}
+
+	// Unpinning a v2 publink unpins the v1 publink it resolves to, which
+	// isn't pinned by this renter.
+	_, _, err = r.UnpinPublink(link2, false, time.Minute)
+	if !errors.Contains(err, ErrPublinkNotPinned) {
+		t.Fatal("expected ErrPublinkNotPinned but got:", err)
+	}
+	// A v2 publink without a registry entry can't be unpinned.
+	fastrand.Read(tweak[:])
+	_, _, err = r.UnpinPublink(modules.NewPublinkV2(spk, tweak), false, time.Minute)
+	if !errors.Contains(err, modules.ErrRegistryEntryNotFound) {
+		t.Fatal("expected ErrRegistryEntryNotFound but got:", err)
+	}
+}
@@ -170,6 +170,12 @@ type Renter struct {
 	staticSkynetBlacklist *pubaccessblacklist.SkynetBlacklist
 	staticSkynetPortals   *pubaccessportals.SkynetPortals
 
+	// Resumable pubfile uploads.
+	staticResumableUploads *resumableUploads
+
+	// Pubaccesskey rotations.
+	staticSkykeyRotations *skykeyRotations
+
 	// Download management. The heap has a separate mutex because it is always
 	// accessed in isolation.
 	downloadHeapMu sync.Mutex         // Used to protect the downloadHeap.
@@ -571,10 +577,16 @@ func (r *Renter) SetSettings(s modules.RenterSettings) error {
 	if err != nil {
 		return err
 	}
-	// Save the changes.
+	// Save the changes. A zero resumable upload limit resets it to the
+	// default.
+	maxResumableUploadBytes := s.MaxResumableUploadBytes
+	if maxResumableUploadBytes == 0 {
+		maxResumableUploadBytes = DefaultMaxResumableUploadBytes
+	}
 	id := r.mu.Lock()
 	r.persist.MaxDownloadSpeed = s.MaxDownloadSpeed
 	r.persist.MaxUploadSpeed = s.MaxUploadSpeed
+	r.persist.MaxResumableUploadBytes = maxResumableUploadBytes
 	err = r.saveSync()
 	r.mu.Unlock(id)
 	if err != nil {
@@ -766,6 +778,9 @@ func (r *Renter) Settings() (modules.RenterSettings, error) {
 		return modules.RenterSettings{}, errors.AddContext(err, "error getting IPViolationsCheck:")
 	}
 	paused, endTime := r.uploadHeap.managedPauseStatus()
+	id := r.mu.RLock()
+	maxResumableUploadBytes := r.persist.MaxResumableUploadBytes
+	r.mu.RUnlock(id)
 	return modules.RenterSettings{
 		Allowance:        r.hostContractor.Allowance(),
 		IPViolationCheck: iprestriction > 0,
@@ -776,6 +791,7 @@ func (r *Renter) Settings() (modules.RenterSettings, error) {
 			Paused:       paused,
 			PauseEndTime: endTime,
 		},
+		MaxResumableUploadBytes: maxResumableUploadBytes,
 	}, nil
 }
 
@@ -998,6 +1014,29 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 	}
 	r.staticSkynetPortals = sp
 
+	// Load the resumable pubfile uploads.
+	ru, err := newResumableUploads(r.persistDir)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to load resumable pubfile uploads")
+	}
+	r.staticResumableUploads = ru
+
+	// Clear the spool directory of pubfile uploads, the uploads that were
+	// spooled before a shutdown can't be resumed.
+	spoolDir := filepath.Join(r.persistDir, skyfileSpoolDir)
+	if err := os.RemoveAll(spoolDir); err != nil {
+		return nil, errors.AddContext(err, "unable to clear pubfile spool directory")
+	}
+	if err := os.MkdirAll(spoolDir, modules.DefaultDirPerm); err != nil {
+		return nil, errors.AddContext(err, "unable to create pubfile spool directory")
+	}
+
+	// Load the pubaccesskey rotations.
+	r.staticSkykeyRotations, err = newSkykeyRotations(r.persistDir)
+	if err != nil {
+		return nil, err
+	}
+
 	// Load all saved data.
 	err = r.managedInitPersist()
 	if err != nil {
@@ -1026,6 +1065,9 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 	if !r.deps.Disrupt("DisableRepairAndHealthLoops") {
 		go r.threadedUpdateRenterHealth()
 	}
+	go r.threadedSkynetBlacklistFeedsLoop()
+	go r.threadedSkynetPortalsProbeLoop()
+	go r.threadedPruneResumableUploadsLoop()
 	// Unsubscribe on shutdown.
 	err = r.tg.OnStop(func() error {
 		cs.Unsubscribe(r)
@@ -4,7 +4,6 @@ package renter
 // skyfiles.
 
 import (
-	"github.com/aead/chacha20/chacha"
 	"gitlab.com/NebulousLabs/errors"
 
 	"github.com/EvilRedHorse/pubaccess-node/build"
@@ -42,10 +41,10 @@ func (r *Renter) deriveFanoutKey(sl *skyfileLayout, fileSkykey pubaccesskey.Puba
 // checkSkyfileEncryptionIDMatch tries to find a Pubaccesskey that can decrypt the
 // identifier and be used for decrypting the associated pubfile. It returns an
 // error if it is not found.
-func (r *Renter) checkSkyfileEncryptionIDMatch(encryptionIdentifer []byte, nonce []byte) (pubaccesskey.Pubaccesskey, error) {
+func (r *Renter) checkSkyfileEncryptionIDMatch(encryptionIdentifer []byte, headerData []byte) (pubaccesskey.Pubaccesskey, error) {
 	allSkykeys := r.staticSkykeyManager.Skykeys()
 	for _, sk := range allSkykeys {
-		matches, err := sk.MatchesSkyfileEncryptionID(encryptionIdentifer, nonce)
+		matches, err := sk.MatchesSkyfileEncryptionID(encryptionIdentifer, headerData)
 		if err != nil {
 			r.log.Debugln("SkykeyEncryptionID match err", err)
 			continue
@@ -75,10 +74,10 @@ func (r *Renter) decryptBaseSector(baseSector []byte) (pubaccesskey.Pubaccesskey
 		build.Critical("Expected layout to be marked as encrypted!")
 	}
 
-	// Get the nonce to be used for getting private-id skykeys, and for deriving the
-	// file-specific pubaccesskey.
-	nonce := make([]byte, chacha.XNonceSize)
-	copy(nonce[:], sl.keyData[pubaccesskey.SkykeyIDLen:pubaccesskey.SkykeyIDLen+chacha.XNonceSize])
+	// Get the header data, starting with the nonce, to be used for getting
+	// private-id skykeys, and for deriving the file-specific pubaccesskey.
+	headerData := make([]byte, len(sl.keyData)-pubaccesskey.SkykeyIDLen)
+	copy(headerData, sl.keyData[pubaccesskey.SkykeyIDLen:])
 
 	// Grab the key ID from the layout.
 	var keyID pubaccesskey.PubaccesskeyID
@@ -89,14 +88,14 @@ func (r *Renter) decryptBaseSector(baseSector []byte) (pubaccesskey.Pubaccesskey
 	// If the ID is unknown, use the key ID as an encryption identifier and try
 	// finding the associated pubaccesskey.
 	if errors.Contains(err, pubaccesskey.ErrNoSkykeysWithThatID) {
-		masterSkykey, err = r.checkSkyfileEncryptionIDMatch(keyID[:], nonce)
+		masterSkykey, err = r.checkSkyfileEncryptionIDMatch(keyID[:], headerData)
 	}
 	if err != nil {
 		return pubaccesskey.Pubaccesskey{}, errors.AddContext(err, "Unable to find associated pubaccesskey")
 	}
 
 	// Derive the file-specific key.
-	fileSkykey, err := masterSkykey.SubkeyWithNonce(nonce)
+	fileSkykey, err := masterSkykey.SubkeyWithHeaderData(headerData)
 	if err != nil {
 		return pubaccesskey.Pubaccesskey{}, errors.AddContext(err, "Unable to derive file-specific subkey")
 	}
@@ -184,15 +183,41 @@ func encryptBaseSectorWithSkykey(baseSector []byte, plaintextLayout skyfileLayou
 		return errors.AddContext(errors.New("No encryption implemented for pubaccesskey type"), string(sk.Type))
 	}
 
-	// Add the nonce to the base sector, in plaintext.
-	nonce := sk.Nonce()
-	copy(encryptedLayout.keyData[pubaccesskey.SkykeyIDLen:pubaccesskey.SkykeyIDLen+len(nonce)], nonce[:])
+	// Add the nonce, and any other data required to derive the file-specific
+	// key, to the base sector, in plaintext.
+	headerData := sk.HeaderData()
+	if len(headerData) > len(encryptedLayout.keyData)-pubaccesskey.SkykeyIDLen {
+		return errors.New("pubaccesskey header data doesn't fit into the layout")
+	}
+	copy(encryptedLayout.keyData[pubaccesskey.SkykeyIDLen:], headerData)
 
 	// Now re-copy the encrypted layout into the baseSector.
 	copy(baseSector[:SkyfileLayoutSize], encryptedLayout.encode())
 	return nil
 }
 
+// isEncryptedWithSkykey returns true if and only if the encrypted baseSector
+// was encrypted with a file-specific key derived from the given Pubaccesskey.
+func isEncryptedWithSkykey(baseSector []byte, sk pubaccesskey.Pubaccesskey) (bool, error) {
+	var sl skyfileLayout
+	sl.decode(baseSector)
+	if !isEncryptedLayout(sl) {
+		return false, nil
+	}
+	var keyID pubaccesskey.PubaccesskeyID
+	copy(keyID[:], sl.keyData[:pubaccesskey.SkykeyIDLen])
+	headerData := sl.keyData[pubaccesskey.SkykeyIDLen:]
+
+	switch sk.Type {
+	case pubaccesskey.TypePublicID:
+		return keyID == sk.ID(), nil
+	case pubaccesskey.TypePrivateID, pubaccesskey.TypePassword, pubaccesskey.TypeX25519:
+		return sk.MatchesSkyfileEncryptionID(keyID[:], headerData)
+	default:
+		return false, errors.AddContext(pubaccesskey.ErrInvalidPubaccesskeyType, sk.Type.ToString())
+	}
+}
+
 // isEncryptedBaseSector returns true if and only if the the baseSector is
 // encrypted.
 func isEncryptedBaseSector(baseSector []byte) bool {
@@ -35,6 +35,96 @@ func TestSkyfileBaseSectorEncryption(t *testing.T) {
 
 	testBaseSectorEncryptionWithType(t, r, pubaccesskey.TypePublicID)
 	testBaseSectorEncryptionWithType(t, r, pubaccesskey.TypePrivateID)
+	testBaseSectorEncryptionWithType(t, r, pubaccesskey.TypeX25519)
+}
+
+// TestBaseSectorEncryptionWithoutSharedKey tests that base sectors encrypted
+// with password pubaccesskeys and with the public key of X25519 pubaccesskeys
+// are decrypted.
+func TestBaseSectorEncryptionWithoutSharedKey(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rt.renter
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Add a password key and create a X25519 key. Only the public key of the
+	// latter is used for encryption.
+	passwordKey, err := pubaccesskey.NewPasswordSkykey(t.Name()+"-password", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.AddSkykey(passwordKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x25519Key, err := r.CreateSkykey(t.Name()+"-x25519", pubaccesskey.TypeX25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := x25519Key.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a base sector.
+	fileBytes := fastrand.Bytes(1000)
+	metadataBytes, err := skyfileMetadataBytes(modules.PubfileMetadata{
+		Mode:     os.FileMode(0777),
+		Filename: "encryption_test_file",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ll := skyfileLayout{
+		version:      SkyfileVersion,
+		filesize:     uint64(len(fileBytes)),
+		metadataSize: uint64(len(metadataBytes)),
+		cipherType:   crypto.TypePlain,
+	}
+	baseSector, _ := skyfileBuildBaseSector(ll.encode(), nil, metadataBytes, fileBytes)
+
+	for _, sk := range []pubaccesskey.Pubaccesskey{passwordKey, publicKey} {
+		fsKey, err := sk.GenerateFileSpecificSubkey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs := append([]byte(nil), baseSector...)
+		err = encryptBaseSectorWithSkykey(bs, ll, fsKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isEncryptedBaseSector(bs) {
+			t.Fatal("base sector should be encrypted", sk.Type)
+		}
+
+		// Decrypt the base sector, the same file-specific key should be
+		// derived.
+		decryptedKey, err := r.decryptBaseSector(bs)
+		if err != nil {
+			t.Fatal(sk.Type, err)
+		}
+		if !bytes.Equal(decryptedKey.Entropy, fsKey.Entropy) {
+			t.Fatal("decrypted with wrong file-specific key", sk.Type)
+		}
+		_, _, _, payload, err := parseSkyfileMetadata(bs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(payload, fileBytes) {
+			t.Fatal("decrypted payload doesn't match", sk.Type)
+		}
+	}
 }
 
 // testBaseSectorEncryptionWithType tests base sector encryption and decryption
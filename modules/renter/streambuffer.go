@@ -157,6 +157,11 @@ type stream struct {
 	lru    *leastRecentlyUsedCache
 	offset uint64
 
+	// staticLookahead is the number of data sections that the stream fetches
+	// ahead of the data section of the current offset. If it is zero, the
+	// lookahead is derived from the minimumLookahead.
+	staticLookahead uint64
+
 	mu                 sync.Mutex
 	staticStreamBuffer *streamBuffer
 }
@@ -215,7 +220,11 @@ func newStreamBufferSet(tg *threadgroup.ThreadGroup) *streamBufferSet {
 // Each stream has a separate LRU for determining what data to buffer. Because
 // the LRU is distinct to the stream, the shared cache feature will not result
 // in one stream evicting data from another stream's LRU.
-func (sbs *streamBufferSet) callNewStream(dataSource streamBufferDataSource, initialOffset uint64) *stream {
+//
+// The 'lookahead' is the number of data sections the stream fetches in parallel
+// ahead of the data section that is currently being read. A lookahead of zero
+// results in the default lookahead.
+func (sbs *streamBufferSet) callNewStream(dataSource streamBufferDataSource, initialOffset, lookahead uint64) *stream {
 	// Grab the streamBuffer for the provided sourceID. If no streamBuffer for
 	// the sourceID exists, create a new one.
 	sourceID := dataSource.ID()
@@ -239,14 +248,14 @@ func (sbs *streamBufferSet) callNewStream(dataSource streamBufferDataSource, ini
 	}
 	streamBuf.externRefCount++
 	sbs.mu.Unlock()
-	return streamBuf.managedPrepareNewStream(initialOffset)
+	return streamBuf.managedPrepareNewStream(initialOffset, lookahead)
 }
 
 // callNewStreamFromID will check the stream buffer set to see if a stream
 // buffer exists for the given data source id. If so, a new stream will be
 // created using the data source, and the bool will be set to 'true'. Otherwise,
 // the stream returned will be nil and the bool will be set to 'false'.
-func (sbs *streamBufferSet) callNewStreamFromID(id modules.DataSourceID, initialOffset uint64) (*stream, bool) {
+func (sbs *streamBufferSet) callNewStreamFromID(id modules.DataSourceID, initialOffset, lookahead uint64) (*stream, bool) {
 	sbs.mu.Lock()
 	streamBuf, exists := sbs.streams[id]
 	if !exists {
@@ -255,7 +264,7 @@ func (sbs *streamBufferSet) callNewStreamFromID(id modules.DataSourceID, initial
 	}
 	streamBuf.externRefCount++
 	sbs.mu.Unlock()
-	return streamBuf.managedPrepareNewStream(initialOffset), true
+	return streamBuf.managedPrepareNewStream(initialOffset, lookahead), true
 }
 
 // managedData will block until the data for a data section is available, and
@@ -394,8 +403,70 @@ func (s *stream) Seek(offset int64, whence int) (int64, error) {
 	return int64(s.offset), nil
 }
 
+// Prefetch will start fetching the data sections which contain the data in
+// the range [offset, offset+length) without moving the read head of the
+// stream. This allows the data of ranges which are read later, like the ranges
+// of a multi-range request, to be fetched in parallel with the current read.
+//
+// Only as many data sections are prefetched as fit into the LRU of the stream
+// next to the data sections which are buffered for the current offset.
+func (s *stream) Prefetch(offset, length uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Convenience variables.
+	dataSize := s.staticStreamBuffer.staticDataSize
+	dataSectionSize := s.staticStreamBuffer.staticDataSectionSize
+
+	// Input checking.
+	if length == 0 || offset >= dataSize {
+		return
+	}
+	if offset+length > dataSize || offset+length < offset {
+		length = dataSize - offset
+	}
+
+	// Determine how many data sections can be prefetched without evicting the
+	// data sections of the current offset.
+	reserved := s.lookaheadSections() + 1
+	if s.lru.staticSize <= reserved {
+		return
+	}
+	available := s.lru.staticSize - reserved
+
+	// Fetch the data sections of the range.
+	first := offset / dataSectionSize
+	last := (offset + length - 1) / dataSectionSize
+	for index := first; index <= last && index-first < available; index++ {
+		s.lru.callUpdate(index)
+	}
+
+	// Move the data sections of the current offset back to the front of the
+	// LRU.
+	s.prepareOffset()
+}
+
+// lookaheadSections returns the number of data sections that the stream
+// buffers ahead of the data section of the current offset.
+func (s *stream) lookaheadSections() uint64 {
+	if s.staticLookahead > 0 {
+		return s.staticLookahead
+	}
+
+	// We always want to buffer at least one more section than the current
+	// section. Keep adding more sections until we have buffered at least
+	// minimumLookahead total data.
+	dataSectionSize := s.staticStreamBuffer.staticDataSectionSize
+	sections := uint64(1)
+	for i := dataSectionSize * 2; i < minimumLookahead; i += dataSectionSize {
+		sections++
+	}
+	return sections
+}
+
 // prepareOffset will ensure that the dataSection containing the offset is made
-// available in the LRU, and that the following dataSection is also available.
+// available in the LRU, and that the following dataSections are also
+// available.
 func (s *stream) prepareOffset() {
 	// Convenience variables.
 	dataSize := s.staticStreamBuffer.staticDataSize
@@ -412,20 +483,12 @@ func (s *stream) prepareOffset() {
 	index := s.offset / dataSectionSize
 	s.lru.callUpdate(index)
 
-	// If there is a following data section, update that as well. This update is
-	// done regardless of the minimumLookahead, we always want to buffer at
-	// least one more piece than the current piece.
-	nextIndex := index + 1
-	if nextIndex*dataSectionSize < dataSize {
-		s.lru.callUpdate(nextIndex)
-	}
-
-	// Keep adding more pieces to the buffer until we have buffered at least
-	// minimumLookahead total data or have reached the end of the stream.
-	nextIndex++
-	for i := dataSectionSize * 2; i < minimumLookahead && nextIndex*dataSectionSize < dataSize; i += dataSectionSize {
+	// Keep adding the following sections to the buffer until the lookahead is
+	// buffered or we have reached the end of the stream. Every section that is
+	// not in the streamBuffer cache yet is fetched in parallel.
+	lookahead := s.lookaheadSections()
+	for nextIndex := index + 1; nextIndex <= index+lookahead && nextIndex*dataSectionSize < dataSize; nextIndex++ {
 		s.lru.callUpdate(nextIndex)
-		nextIndex++
 	}
 }
 
@@ -468,18 +531,24 @@ func (sb *streamBuffer) callRemoveDataSection(index uint64) {
 // managedPrepareNewStream creates a new stream from an existing stream buffer.
 // The ref count for the buffer needs to be incremented under the
 // streamBufferSet lock, before this method is called.
-func (sb *streamBuffer) managedPrepareNewStream(initialOffset uint64) *stream {
-	// Determine how many data sections the stream should cache.
+func (sb *streamBuffer) managedPrepareNewStream(initialOffset, lookahead uint64) *stream {
+	// Determine how many data sections the stream should cache. The cache
+	// needs to be able to hold the current data section and the lookahead.
 	dataSectionsToCache := bytesBufferedPerStream / sb.staticDataSectionSize
 	if dataSectionsToCache < minimumDataSections {
 		dataSectionsToCache = minimumDataSections
 	}
+	if dataSectionsToCache < lookahead+1 {
+		dataSectionsToCache = lookahead + 1
+	}
 
 	// Create a stream that points to the stream buffer.
 	stream := &stream{
 		lru:    newLeastRecentlyUsedCache(dataSectionsToCache, sb),
 		offset: initialOffset,
 
+		staticLookahead: lookahead,
+
 		staticStreamBuffer: sb,
 	}
 	stream.prepareOffset()
@@ -111,7 +111,7 @@ func TestStreamSmoke(t *testing.T) {
 	dataSectionSize := uint64(16)
 	dataSource := newMockDataSource(data, dataSectionSize)
 	sbs := newStreamBufferSet(&tg)
-	stream := sbs.callNewStream(dataSource, 0)
+	stream := sbs.callNewStream(dataSource, 0, 0)
 
 	// Check that there is one reference in the stream buffer.
 	sbs.mu.Lock()
@@ -121,7 +121,7 @@ func TestStreamSmoke(t *testing.T) {
 		t.Fatal("bad")
 	}
 	// Create a new stream from an id, check that the ref count goes up.
-	streamFromID, exists := sbs.callNewStreamFromID(dataSource.ID(), 0)
+	streamFromID, exists := sbs.callNewStreamFromID(dataSource.ID(), 0, 0)
 	if !exists {
 		t.Fatal("bad")
 	}
@@ -134,7 +134,7 @@ func TestStreamSmoke(t *testing.T) {
 	// Create a second, different data source with the same id and try to use
 	// that.
 	dataSource2 := newMockDataSource(data, dataSectionSize)
-	repeatStream := sbs.callNewStream(dataSource2, 0)
+	repeatStream := sbs.callNewStream(dataSource2, 0, 0)
 	sbs.mu.Lock()
 	refs = stream.staticStreamBuffer.externRefCount
 	sbs.mu.Unlock()
@@ -305,7 +305,7 @@ func TestStreamSmoke(t *testing.T) {
 	// the same ID, they are actually separate objects which need to be closed
 	// individually.
 	dataSource3 := newMockDataSource(data, dataSectionSize)
-	stream2 := sbs.callNewStream(dataSource3, 0)
+	stream2 := sbs.callNewStream(dataSource3, 0, 0)
 	bytesRead, err = io.ReadFull(stream2, buf)
 	if err != nil {
 		t.Fatal(err)
@@ -433,7 +433,7 @@ func TestStreamSmoke(t *testing.T) {
 
 	// Check that if the tg is stopped, the stream closes immediately.
 	dataSource4 := newMockDataSource(data, dataSectionSize)
-	stream3 := sbs.callNewStream(dataSource4, 0)
+	stream3 := sbs.callNewStream(dataSource4, 0, 0)
 	bytesRead, err = io.ReadFull(stream3, buf)
 	if err != nil {
 		t.Fatal(err)
@@ -477,3 +477,95 @@ func TestStreamSmoke(t *testing.T) {
 		t.Fatal("bad")
 	}
 }
+
+// TestStreamLookaheadPrefetch checks that a stream buffers the configured
+// lookahead and that prefetching a range fetches the data sections of the
+// range without evicting the data sections of the current offset.
+func TestStreamLookaheadPrefetch(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	var tg threadgroup.ThreadGroup
+	sbs := newStreamBufferSet(&tg)
+	dataSectionSize := uint64(16)
+
+	// exists is a helper that checks which data sections are in the stream
+	// buffer.
+	exists := func(sb *streamBuffer, index uint64) bool {
+		sb.mu.Lock()
+		defer sb.mu.Unlock()
+		_, exists := sb.dataSections[index]
+		return exists
+	}
+
+	// Create a stream with a lookahead of 5 sections.
+	data := fastrand.Bytes(15999)
+	stream := sbs.callNewStream(newMockDataSource(data, dataSectionSize), 0, 5)
+	for i := uint64(0); i <= 5; i++ {
+		if !exists(stream.staticStreamBuffer, i) {
+			t.Fatal("section in lookahead was not fetched", i)
+		}
+	}
+	if exists(stream.staticStreamBuffer, 6) {
+		t.Fatal("section beyond lookahead was fetched")
+	}
+
+	// A lookahead larger than the default cache size grows the LRU.
+	data2 := fastrand.Bytes(15999)
+	stream2 := sbs.callNewStream(newMockDataSource(data2, dataSectionSize), 0, 20)
+	if stream2.lru.staticSize != 21 {
+		t.Fatal("lru too small for the lookahead", stream2.lru.staticSize)
+	}
+	for i := uint64(0); i <= 20; i++ {
+		if !exists(stream2.staticStreamBuffer, i) {
+			t.Fatal("section in lookahead was not fetched", i)
+		}
+	}
+
+	// Prefetch a range on a stream with the default lookahead. The default
+	// lookahead buffers 4 sections, which leaves room for 12 prefetched
+	// sections.
+	data3 := fastrand.Bytes(15999)
+	stream3 := sbs.callNewStream(newMockDataSource(data3, dataSectionSize), 0, 0)
+	stream3.Prefetch(800, 40)
+	for _, i := range []uint64{0, 1, 2, 3, 50, 51, 52} {
+		if !exists(stream3.staticStreamBuffer, i) {
+			t.Fatal("section was not fetched", i)
+		}
+	}
+	if exists(stream3.staticStreamBuffer, 53) {
+		t.Fatal("section beyond the prefetched range was fetched")
+	}
+	stream3.Prefetch(1600, 1600)
+	for i := uint64(100); i < 112; i++ {
+		if !exists(stream3.staticStreamBuffer, i) {
+			t.Fatal("section was not fetched", i)
+		}
+	}
+	if exists(stream3.staticStreamBuffer, 112) {
+		t.Fatal("prefetch exceeded the size of the lru")
+	}
+	for i := uint64(0); i < 4; i++ {
+		if !exists(stream3.staticStreamBuffer, i) {
+			t.Fatal("prefetch evicted the section of the current offset", i)
+		}
+	}
+
+	// Prefetching beyond the end of the data is a no-op.
+	stream3.Prefetch(15999, 100)
+
+	// Reading the prefetched data returns the right data.
+	_, err := stream3.Seek(800, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 40)
+	_, err = io.ReadFull(stream3, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, data3[800:840]) {
+		t.Fatal("prefetched data doesn't match")
+	}
+}
@@ -82,6 +82,8 @@ type (
 		staticJobQueueDownloadByRoot jobQueueDownloadByRoot
 		staticJobHasSectorQueue      *jobHasSectorQueue
 		staticJobReadQueue           *jobReadQueue
+		staticJobReadRegistryQueue   *jobReadRegistryQueue
+		staticJobUpdateRegistryQueue *jobUpdateRegistryQueue
 		staticJobUploadSnapshotQueue *jobUploadSnapshotQueue
 
 		// Upload variables.
@@ -199,6 +201,8 @@ func (r *Renter) newWorker(hostPubKey types.SiaPublicKey) (*worker, error) {
 	w.newPriceTable()
 	w.initJobHasSectorQueue()
 	w.initJobReadQueue()
+	w.initJobReadRegistryQueue()
+	w.initJobUpdateRegistryQueue()
 	w.initJobUploadSnapshotQueue()
 	// Get the worker cache set up before returning the worker. This prevents a
 	// race condition in some tests.
@@ -0,0 +1,197 @@
+package renter
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+type (
+	// jobReadRegistry contains information about a ReadRegistry query.
+	jobReadRegistry struct {
+		staticEntryID crypto.Hash
+
+		staticResponseChan chan *jobReadRegistryResponse // Channel to send a response down
+
+		*jobGeneric
+	}
+
+	// jobReadRegistryQueue is a list of ReadRegistry queries that have been
+	// assigned to the worker.
+	jobReadRegistryQueue struct {
+		*jobGenericQueue
+	}
+
+	// jobReadRegistryResponse contains the result of a ReadRegistry query.
+	jobReadRegistryResponse struct {
+		staticPubKey types.SiaPublicKey
+		staticValue  modules.SignedRegistryValue
+		staticErr    error
+
+		// The worker is included in the response so that the caller can listen
+		// on one channel for a bunch of workers and still know which worker
+		// returned the value.
+		staticWorker *worker
+	}
+)
+
+// newJobReadRegistry is a helper method to create a new ReadRegistry job.
+func (w *worker) newJobReadRegistry(cancel <-chan struct{}, responseChan chan *jobReadRegistryResponse, id crypto.Hash) *jobReadRegistry {
+	return &jobReadRegistry{
+		staticEntryID:      id,
+		staticResponseChan: responseChan,
+		jobGeneric:         newJobGeneric(w.staticJobReadRegistryQueue, cancel),
+	}
+}
+
+// callDiscard will discard a job, sending the provided error.
+func (j *jobReadRegistry) callDiscard(err error) {
+	w := j.staticQueue.staticWorker()
+	w.renter.tg.Launch(func() {
+		response := &jobReadRegistryResponse{
+			staticErr:    errors.Extend(err, ErrJobDiscarded),
+			staticWorker: w,
+		}
+		select {
+		case j.staticResponseChan <- response:
+		case <-j.staticCancelChan:
+		case <-w.renter.tg.StopChan():
+		}
+	})
+}
+
+// callExecute will run the ReadRegistry job.
+func (j *jobReadRegistry) callExecute() {
+	w := j.staticQueue.staticWorker()
+	spk, srv, err := j.managedReadRegistry()
+
+	// Send the response.
+	response := &jobReadRegistryResponse{
+		staticPubKey: spk,
+		staticValue:  srv,
+		staticErr:    err,
+
+		staticWorker: w,
+	}
+	w.renter.tg.Launch(func() {
+		select {
+		case j.staticResponseChan <- response:
+		case <-j.staticCancelChan:
+		case <-w.renter.tg.StopChan():
+		}
+	})
+
+	// Report success or failure to the queue. A missing entry is not a
+	// failure of the worker.
+	if err == nil || errors.Contains(err, modules.ErrRegistryEntryNotFound) {
+		j.staticQueue.callReportSuccess()
+	} else {
+		j.staticQueue.callReportFailure(err)
+	}
+}
+
+// callExpectedBandwidth returns the bandwidth that is expected to be consumed
+// by the job.
+func (j *jobReadRegistry) callExpectedBandwidth() (ul, dl uint64) {
+	return readRegistryJobExpectedBandwidth()
+}
+
+// managedReadRegistry reads the registry entry from the host and verifies the
+// signature of its value.
+func (j *jobReadRegistry) managedReadRegistry() (_ types.SiaPublicKey, _ modules.SignedRegistryValue, err error) {
+	w := j.staticQueue.staticWorker()
+	cache := w.staticCache()
+	pt := w.staticPriceTable().staticPriceTable
+	cost := pt.ReadRegistryCost
+
+	// track the withdrawal, the host also charges for missing entries.
+	w.staticAccount.managedTrackWithdrawal(cost)
+	defer func() {
+		paid := err == nil || errors.Contains(err, modules.ErrRegistryEntryNotFound)
+		w.staticAccount.managedCommitWithdrawal(cost, paid)
+	}()
+
+	// create a new stream
+	stream, err := w.staticNewStream()
+	if err != nil {
+		return types.SiaPublicKey{}, modules.SignedRegistryValue{}, errors.AddContext(err, "Unable to create a new stream")
+	}
+	defer func() {
+		if err := stream.Close(); err != nil {
+			w.renter.log.Println("ERROR: failed to close stream", err)
+		}
+	}()
+
+	// write the specifier, the price table uid, the payment and the request.
+	buffer := bytes.NewBuffer(nil)
+	err = modules.RPCWrite(buffer, modules.RPCReadRegistry)
+	if err != nil {
+		return types.SiaPublicKey{}, modules.SignedRegistryValue{}, err
+	}
+	err = modules.RPCWrite(buffer, pt.UID)
+	if err != nil {
+		return types.SiaPublicKey{}, modules.SignedRegistryValue{}, err
+	}
+	err = w.staticAccount.ProvidePayment(buffer, w.staticHostPubKey, modules.RPCReadRegistry, cost, w.staticAccount.staticID, cache.staticBlockHeight)
+	if err != nil {
+		return types.SiaPublicKey{}, modules.SignedRegistryValue{}, err
+	}
+	err = modules.RPCWrite(buffer, modules.RPCReadRegistryRequest{
+		EntryID: j.staticEntryID,
+	})
+	if err != nil {
+		return types.SiaPublicKey{}, modules.SignedRegistryValue{}, err
+	}
+	_, err = stream.Write(buffer.Bytes())
+	if err != nil {
+		return types.SiaPublicKey{}, modules.SignedRegistryValue{}, err
+	}
+
+	// read the response.
+	var rrr modules.RPCReadRegistryResponse
+	err = modules.RPCRead(stream, &rrr)
+	if err != nil {
+		// The host sends errors as strings, so a missing entry is recognized
+		// by its message.
+		if strings.Contains(err.Error(), modules.ErrRegistryEntryNotFound.Error()) {
+			return types.SiaPublicKey{}, modules.SignedRegistryValue{}, modules.ErrRegistryEntryNotFound
+		}
+		return types.SiaPublicKey{}, modules.SignedRegistryValue{}, err
+	}
+
+	// Make sure the host returned the requested entry with a valid signature.
+	if modules.RegistryEntryID(rrr.PubKey, rrr.Value.Tweak) != j.staticEntryID {
+		return types.SiaPublicKey{}, modules.SignedRegistryValue{}, errors.New("host returned a registry entry with a different id")
+	}
+	err = rrr.Value.Verify(rrr.PubKey)
+	if err != nil {
+		return types.SiaPublicKey{}, modules.SignedRegistryValue{}, errors.AddContext(err, "host returned an invalid registry entry")
+	}
+	return rrr.PubKey, rrr.Value, nil
+}
+
+// initJobReadRegistryQueue will init the queue for the ReadRegistry jobs.
+func (w *worker) initJobReadRegistryQueue() {
+	// Sanity check that there is no existing job queue.
+	if w.staticJobReadRegistryQueue != nil {
+		w.renter.log.Critical("incorret call on initJobReadRegistryQueue")
+		return
+	}
+
+	w.staticJobReadRegistryQueue = &jobReadRegistryQueue{
+		jobGenericQueue: newJobGenericQueue(w),
+	}
+}
+
+// readRegistryJobExpectedBandwidth is a helper function that returns the
+// expected bandwidth consumption of a ReadRegistry job.
+func readRegistryJobExpectedBandwidth() (ul, dl uint64) {
+	ul = 20e3
+	dl = 20e3
+	return
+}
@@ -0,0 +1,183 @@
+package renter
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+type (
+	// jobUpdateRegistry contains information about an UpdateRegistry query.
+	jobUpdateRegistry struct {
+		staticPubKey types.SiaPublicKey
+		staticValue  modules.SignedRegistryValue
+
+		staticResponseChan chan *jobUpdateRegistryResponse // Channel to send a response down
+
+		*jobGeneric
+	}
+
+	// jobUpdateRegistryQueue is a list of UpdateRegistry queries that have
+	// been assigned to the worker.
+	jobUpdateRegistryQueue struct {
+		*jobGenericQueue
+	}
+
+	// jobUpdateRegistryResponse contains the result of an UpdateRegistry
+	// query.
+	jobUpdateRegistryResponse struct {
+		staticErr error
+
+		// The worker is included in the response so that the caller can listen
+		// on one channel for a bunch of workers and still know which worker
+		// stored the value.
+		staticWorker *worker
+	}
+)
+
+// newJobUpdateRegistry is a helper method to create a new UpdateRegistry job.
+func (w *worker) newJobUpdateRegistry(cancel <-chan struct{}, responseChan chan *jobUpdateRegistryResponse, spk types.SiaPublicKey, srv modules.SignedRegistryValue) *jobUpdateRegistry {
+	return &jobUpdateRegistry{
+		staticPubKey:       spk,
+		staticValue:        srv,
+		staticResponseChan: responseChan,
+		jobGeneric:         newJobGeneric(w.staticJobUpdateRegistryQueue, cancel),
+	}
+}
+
+// callDiscard will discard a job, sending the provided error.
+func (j *jobUpdateRegistry) callDiscard(err error) {
+	w := j.staticQueue.staticWorker()
+	w.renter.tg.Launch(func() {
+		response := &jobUpdateRegistryResponse{
+			staticErr:    errors.Extend(err, ErrJobDiscarded),
+			staticWorker: w,
+		}
+		select {
+		case j.staticResponseChan <- response:
+		case <-j.staticCancelChan:
+		case <-w.renter.tg.StopChan():
+		}
+	})
+}
+
+// callExecute will run the UpdateRegistry job.
+func (j *jobUpdateRegistry) callExecute() {
+	w := j.staticQueue.staticWorker()
+	err := j.managedUpdateRegistry()
+
+	// Send the response.
+	response := &jobUpdateRegistryResponse{
+		staticErr: err,
+
+		staticWorker: w,
+	}
+	w.renter.tg.Launch(func() {
+		select {
+		case j.staticResponseChan <- response:
+		case <-j.staticCancelChan:
+		case <-w.renter.tg.StopChan():
+		}
+	})
+
+	// Report success or failure to the queue. An outdated revision number is
+	// not a failure of the worker.
+	if err == nil || errors.Contains(err, modules.ErrRegistryLowerRevNum) {
+		j.staticQueue.callReportSuccess()
+	} else {
+		j.staticQueue.callReportFailure(err)
+	}
+}
+
+// callExpectedBandwidth returns the bandwidth that is expected to be consumed
+// by the job.
+func (j *jobUpdateRegistry) callExpectedBandwidth() (ul, dl uint64) {
+	return updateRegistryJobExpectedBandwidth()
+}
+
+// managedUpdateRegistry stores the value in the registry of the host.
+func (j *jobUpdateRegistry) managedUpdateRegistry() (err error) {
+	w := j.staticQueue.staticWorker()
+	cache := w.staticCache()
+	pt := w.staticPriceTable().staticPriceTable
+	cost := pt.UpdateRegistryCost
+
+	// track the withdrawal, the host rejects invalid updates before the
+	// payment and refunds it if the update fails afterwards.
+	w.staticAccount.managedTrackWithdrawal(cost)
+	defer func() {
+		w.staticAccount.managedCommitWithdrawal(cost, err == nil)
+	}()
+
+	// create a new stream
+	stream, err := w.staticNewStream()
+	if err != nil {
+		return errors.AddContext(err, "Unable to create a new stream")
+	}
+	defer func() {
+		if err := stream.Close(); err != nil {
+			w.renter.log.Println("ERROR: failed to close stream", err)
+		}
+	}()
+
+	// write the specifier, the price table uid, the request and the payment.
+	buffer := bytes.NewBuffer(nil)
+	err = modules.RPCWrite(buffer, modules.RPCUpdateRegistry)
+	if err != nil {
+		return err
+	}
+	err = modules.RPCWrite(buffer, pt.UID)
+	if err != nil {
+		return err
+	}
+	err = modules.RPCWrite(buffer, modules.RPCUpdateRegistryRequest{
+		PubKey: j.staticPubKey,
+		Value:  j.staticValue,
+	})
+	if err != nil {
+		return err
+	}
+	err = w.staticAccount.ProvidePayment(buffer, w.staticHostPubKey, modules.RPCUpdateRegistry, cost, w.staticAccount.staticID, cache.staticBlockHeight)
+	if err != nil {
+		return err
+	}
+	_, err = stream.Write(buffer.Bytes())
+	if err != nil {
+		return err
+	}
+
+	// read the response.
+	var urr modules.RPCUpdateRegistryResponse
+	err = modules.RPCRead(stream, &urr)
+	if err != nil && strings.Contains(err.Error(), modules.ErrRegistryLowerRevNum.Error()) {
+		// The host sends errors as strings, so an outdated revision number is
+		// recognized by its message.
+		return modules.ErrRegistryLowerRevNum
+	}
+	return err
+}
+
+// initJobUpdateRegistryQueue will init the queue for the UpdateRegistry jobs.
+func (w *worker) initJobUpdateRegistryQueue() {
+	// Sanity check that there is no existing job queue.
+	if w.staticJobUpdateRegistryQueue != nil {
+		w.renter.log.Critical("incorret call on initJobUpdateRegistryQueue")
+		return
+	}
+
+	w.staticJobUpdateRegistryQueue = &jobUpdateRegistryQueue{
+		jobGenericQueue: newJobGenericQueue(w),
+	}
+}
+
+// updateRegistryJobExpectedBandwidth is a helper function that returns the
+// expected bandwidth consumption of an UpdateRegistry job.
+func updateRegistryJobExpectedBandwidth() (ul, dl uint64) {
+	ul = 20e3
+	dl = 20e3
+	return
+}
@@ -231,6 +231,16 @@ func (w *worker) externTryLaunchAsyncJob() bool {
 		w.externLaunchAsyncJob(job)
 		return true
 	}
+	job = w.staticJobReadRegistryQueue.callNext()
+	if job != nil {
+		w.externLaunchAsyncJob(job)
+		return true
+	}
+	job = w.staticJobUpdateRegistryQueue.callNext()
+	if job != nil {
+		w.externLaunchAsyncJob(job)
+		return true
+	}
 	return false
 }
 
@@ -258,6 +268,8 @@ func (w *worker) managedBlockUntilReady() bool {
 func (w *worker) managedDiscardAsyncJobs(err error) {
 	w.staticJobHasSectorQueue.callDiscardAll(err)
 	w.staticJobReadQueue.callDiscardAll(err)
+	w.staticJobReadRegistryQueue.callDiscardAll(err)
+	w.staticJobUpdateRegistryQueue.callDiscardAll(err)
 }
 
 // threadedWorkLoop is a perpetual loop run by the worker that accepts new jobs
@@ -279,6 +291,8 @@ func (w *worker) threadedWorkLoop() {
 	defer w.managedKillJobsDownloadByRoot()
 	defer w.staticJobHasSectorQueue.callKill()
 	defer w.staticJobReadQueue.callKill()
+	defer w.staticJobReadRegistryQueue.callKill()
+	defer w.staticJobUpdateRegistryQueue.callKill()
 	defer w.staticJobUploadSnapshotQueue.callKill()
 
 	if build.VersionCmp(w.staticCache().staticHostVersion, minAsyncVersion) >= 0 {
@@ -44,6 +44,15 @@ type RPCPriceTable struct {
 	// TODO: should this be free?
 	LatestRevisionCost types.Currency `json:"latestrevisioncost"`
 
+	// ReadRegistryCost refers to the cost of reading an entry of the host's
+	// registry.
+	ReadRegistryCost types.Currency `json:"readregistrycost"`
+
+	// UpdateRegistryCost refers to the cost of updating an entry of the
+	// host's registry. It includes the storage of the entry for
+	// RegistryStorageDuration.
+	UpdateRegistryCost types.Currency `json:"updateregistrycost"`
+
 	// MDM related costs
 	//
 	// InitBaseCost is the amount of cost that is incurred when an MDM program
@@ -1,6 +1,8 @@
 package modules
 
 import (
+	"time"
+
 	"github.com/EvilRedHorse/pubaccess-node/crypto"
 )
 
@@ -40,6 +42,24 @@ type (
 		// folder. Progress is always reported in bytes.
 		ProgressNumerator   uint64
 		ProgressDenominator uint64
+
+		// The host periodically scrubs its storage folders in the background,
+		// verifying the Merkle root of every sector. ScrubProgress and
+		// ScrubTotal indicate the progress of the current pass in sectors.
+		// CorruptSectors is the number of sectors that were found to be
+		// corrupt, and LastScrubCompleted is the time at which the last pass
+		// over the folder completed since the host was started.
+		ScrubProgress      uint64    `json:"scrubprogress"`
+		ScrubTotal         uint64    `json:"scrubtotal"`
+		CorruptSectors     uint64    `json:"corruptsectors"`
+		LastScrubCompleted time.Time `json:"lastscrubcompleted"`
+
+		// Migrating indicates whether the sectors of the folder are being
+		// migrated to the storage folder with the index MigrationDestination.
+		// The progress of the migration is reported through the progress
+		// fields.
+		Migrating            bool   `json:"migrating"`
+		MigrationDestination uint16 `json:"migrationdestination"`
 	}
 
 	// A StorageManager is responsible for managing storage folders and
@@ -116,6 +136,13 @@ type (
 		// storage folder.
 		ResetStorageFolderHealth(index uint16) error
 
+		// MigrateStorageFolder starts moving all sectors of the storage
+		// folder 'from' into the storage folder 'to' in the background. The
+		// sectors remain available for reads during the migration. If the
+		// destination runs out of space, the migration stops without losing
+		// any data.
+		MigrateStorageFolder(from, to uint16) error
+
 		// ResizeStorageFolder will grow or shrink a storage folder in the
 		// manager. The manager may not check that there is enough space
 		// on-disk to support growing the storage folder, but should gracefully
@@ -150,6 +150,7 @@ type (
 		Wallet          bool `json:"wallet"`
 		Pool            bool `json:"pool"`
 		Stratumminer    bool `json:"stratumminer"`
+		Index           bool `json:"index"`
 	}
 )
 
@@ -161,7 +162,7 @@ func (api *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // SetModules allows for replacing the modules in the API at runtime.
-func (api *API) SetModules(cs modules.ConsensusSet, e modules.Explorer, g modules.Gateway, h modules.Host, m modules.Miner, r modules.Renter, tp modules.TransactionPool, w modules.Wallet, p modules.Pool, sm modules.StratumMiner) {
+func (api *API) SetModules(cs modules.ConsensusSet, e modules.Explorer, g modules.Gateway, h modules.Host, m modules.Miner, r modules.Renter, tp modules.TransactionPool, w modules.Wallet, p modules.Pool, sm modules.StratumMiner, index modules.Index) {
 	if api.modulesSet {
 		build.Critical("can't call SetModules more than once")
 	}
@@ -175,6 +176,7 @@ func (api *API) SetModules(cs modules.ConsensusSet, e modules.Explorer, g module
 	api.wallet = w
 	api.stratumminer = sm
 	api.pool = p
+	api.index = index
 	api.staticConfigModules = configModules{
 		Consensus:       api.cs != nil,
 		Explorer:        api.explorer != nil,
@@ -186,6 +188,7 @@ func (api *API) SetModules(cs modules.ConsensusSet, e modules.Explorer, g module
 		Wallet:          api.wallet != nil,
 		Pool:            api.pool != nil,
 		Stratumminer:    api.stratumminer != nil,
+		Index:           api.index != nil,
 	}
 	api.modulesSet = true
 	api.buildHTTPRoutes()
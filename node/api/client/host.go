@@ -62,6 +62,9 @@ const (
 	// HostParamMaxEphemeralAccountRisk is the maximum ephemeral account risk in
 	// hastings
 	HostParamMaxEphemeralAccountRisk = HostParam("maxephemeralaccountrisk")
+	// HostParamMaxRegistryEntries is the maximum number of entries in the
+	// host's registry.
+	HostParamMaxRegistryEntries = HostParam("maxregistryentries")
 )
 
 // HostAnnouncePost uses the /host/announce endpoint to announce the host to
@@ -92,6 +95,24 @@ func (c *Client) HostContractInfoGet() (cg api.ContractInfoGET, err error) {
 	return
 }
 
+// HostContractInfoQueryGet uses the /host/contracts endpoint to get a page of
+// the contracts on the host matching the query parameters.
+func (c *Client) HostContractInfoQueryGet(values url.Values) (cg api.ContractInfoGET, err error) {
+	err = c.get("/host/contracts?"+values.Encode(), &cg)
+	return
+}
+
+// HostFinancialsGet requests the /host/financials endpoint. An end height
+// of 0 means the current height and a period of 0 returns a single period.
+func (c *Client) HostFinancialsGet(from, to, period types.BlockHeight) (hfg api.HostFinancialsGET, err error) {
+	values := url.Values{}
+	values.Set("from", fmt.Sprint(from))
+	values.Set("to", fmt.Sprint(to))
+	values.Set("period", fmt.Sprint(period))
+	err = c.get("/host/financials?"+values.Encode(), &hfg)
+	return
+}
+
 // HostEstimateScoreGet requests the /host/estimatescore endpoint.
 func (c *Client) HostEstimateScoreGet(param, value string) (eg api.HostEstimateScoreGET, err error) {
 	err = c.get(fmt.Sprintf("/host/estimatescore?%v=%v", param, value), &eg)
@@ -111,12 +132,46 @@ func (c *Client) HostModifySettingPost(param HostParam, value interface{}) (err
 	return
 }
 
+// HostPricingGet requests the /host/pricing endpoint.
+func (c *Client) HostPricingGet() (hpg api.HostPricingGET, err error) {
+	err = c.get("/host/pricing", &hpg)
+	return
+}
+
+// HostPricingPost uses the /host/pricing endpoint to change the parameters of
+// the pricing policy of the host.
+func (c *Client) HostPricingPost(values url.Values) (err error) {
+	err = c.post("/host/pricing", values.Encode(), nil)
+	return
+}
+
+// HostPricingPreviewGet requests the /host/pricing/preview endpoint. The
+// values override the parameters of the pricing policy for the preview.
+func (c *Client) HostPricingPreviewGet(values url.Values) (hppg api.HostPricingPreviewGET, err error) {
+	err = c.get("/host/pricing/preview?"+values.Encode(), &hppg)
+	return
+}
+
 // HostBandwidthGet requests the /host/bandwidth api resource
 func (c *Client) HostBandwidthGet() (gbg api.GatewayBandwidthGET, err error) {
 	err = c.get("/host/bandwidth", &gbg)
 	return
 }
 
+// HostTokensCompactPost uses the /host/tokens/compact endpoint to compact the
+// events log of the token storage.
+func (c *Client) HostTokensCompactPost() (htg api.HostTokensGET, err error) {
+	err = c.post("/host/tokens/compact", "", &htg)
+	return
+}
+
+// HostTokensVerifyGet uses the /host/tokens/verify endpoint to verify the
+// snapshot and the events log of the token storage.
+func (c *Client) HostTokensVerifyGet() (htg api.HostTokensGET, err error) {
+	err = c.get("/host/tokens/verify", &htg)
+	return
+}
+
 // HostStorageFoldersAddPost uses the /host/storage/folders/add api endpoint to
 // add a storage folder to a host
 func (c *Client) HostStorageFoldersAddPost(path string, size uint64) (err error) {
@@ -127,6 +182,16 @@ func (c *Client) HostStorageFoldersAddPost(path string, size uint64) (err error)
 	return
 }
 
+// HostStorageFoldersMigratePost uses the /host/storage/folders/migrate api
+// endpoint to migrate the sectors of a storage folder to another folder.
+func (c *Client) HostStorageFoldersMigratePost(from, to string) (err error) {
+	values := url.Values{}
+	values.Set("from", from)
+	values.Set("to", to)
+	err = c.post("/host/storage/folders/migrate", values.Encode(), nil)
+	return
+}
+
 // HostStorageFoldersRemovePost uses the /host/storage/folders/remove api
 // endpoint to remove a storage folder from a host.
 func (c *Client) HostStorageFoldersRemovePost(path string, force bool) (err error) {
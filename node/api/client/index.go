@@ -0,0 +1,28 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/node/api"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+)
+
+// IndexGet requests the /index api resource
+func (c *Client) IndexGet() (ig api.IndexGET, err error) {
+	err = c.get("/index", &ig)
+	return
+}
+
+// IndexAddressBalanceGet requests the /index/address/:addr/balance api resource
+func (c *Client) IndexAddressBalanceGet(addr types.UnlockHash) (balance modules.IndexAddressBalance, err error) {
+	err = c.get(fmt.Sprintf("/index/address/%s/balance", addr), &balance)
+	return
+}
+
+// IndexAddressOutputsGet requests the /index/address/:addr/outputs api
+// resource
+func (c *Client) IndexAddressOutputsGet(addr types.UnlockHash) (iaog api.IndexAddressOutputsGET, err error) {
+	err = c.get(fmt.Sprintf("/index/address/%s/outputs", addr), &iaog)
+	return
+}
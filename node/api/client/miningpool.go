@@ -2,6 +2,7 @@ package client
 
 import (
 	"net/url"
+	"strconv"
 
 	"github.com/EvilRedHorse/pubaccess-node/node/api"
 )
@@ -33,29 +34,56 @@ func (c *Client) MiningPoolClientsGet() (clientInfos api.MiningPoolClientsInfo,
 	return
 }
 
-/*
+// MiningPoolPayoutsGet requests the /pool/payouts endpoint. The payouts of all
+// clients are returned if name is empty.
+func (c *Client) MiningPoolPayoutsGet(name string) (mpg api.MiningPoolPayoutsGET, err error) {
+	values := url.Values{}
+	values.Set("name", name)
+	err = c.get("/pool/payouts?"+values.Encode(), &mpg)
+	return
+}
+
 // MiningPoolClientGet requests /pool/client?name=bar to retrieve info about one client.
 func (c *Client) MiningPoolClientGet(name string) (clientInfo api.MiningPoolClientInfo, err error) {
-	err = c.get("/pool/client?name="+name, &clientInfo)
+	values := url.Values{}
+	values.Set("name", name)
+	err = c.get("/pool/client?"+values.Encode(), &clientInfo)
 	return
 }
 
-// MiningPoolTransactionsGet requests /pool/clienttx?name=bar to retrieve transaction info about one client.
-func (c *Client) MiningPoolTransactionsGet(name string) (txs []api.MiningPoolClientTransactions, err error) {
-	err = c.get("/pool/clienttx?name="+name, &txs)
+// MiningPoolBlocksGet requests a page of the /pool/blocks block info list.
+func (c *Client) MiningPoolBlocksGet(offset, limit int) (blockInfos []api.MiningPoolBlockInfo, err error) {
+	values := url.Values{}
+	values.Set("offset", strconv.Itoa(offset))
+	values.Set("limit", strconv.Itoa(limit))
+	err = c.get("/pool/blocks?"+values.Encode(), &blockInfos)
 	return
 }
 
-// MiningPoolBlocksGet requests the /pool/blocks block info list.
-func (c *Client) MiningPoolBlocksGet() (blockInfos []api.MiningPoolBlockInfo, err error) {
-	err = c.get("/pool/blocks", &blockInfos)
+// MiningPoolBlockGet requests /pool/block?block=bar to retrieve how the reward
+// of a found block was split between the clients.
+func (c *Client) MiningPoolBlockGet(blockNumber uint64) (blockInfo []api.MiningPoolBlockClientInfo, err error) {
+	err = c.get("/pool/block?block="+strconv.FormatUint(blockNumber, 10), &blockInfo)
 	return
 }
 
-// MiningPoolBlockGet requests the /pool/block?block=bar block info for a given client..
-// TODO this API seems poorly named
-func (c *Client) MiningPoolBlockGet(name string) (blockInfo []api.MiningPoolBlockClientInfo, err error) {
-	err = c.get("/pool/block?block="+name, &blockInfo)
+// MiningPoolReconnectPost uses the /pool/reconnect endpoint to ask the
+// connected miners to reconnect to another pool instance after waiting for
+// the provided number of seconds.
+func (c *Client) MiningPoolReconnectPost(host string, port int, wait int) (err error) {
+	values := url.Values{}
+	values.Set("host", host)
+	values.Set("port", strconv.Itoa(port))
+	values.Set("wait", strconv.Itoa(wait))
+	err = c.post("/pool/reconnect", values.Encode(), nil)
+	return
+}
+
+// MiningPoolMessagePost uses the /pool/message endpoint to show a message to
+// the operators of the connected miners.
+func (c *Client) MiningPoolMessagePost(message string) (err error) {
+	values := url.Values{}
+	values.Set("message", message)
+	err = c.post("/pool/message", values.Encode(), nil)
 	return
 }
-*/
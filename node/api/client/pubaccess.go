@@ -1,13 +1,18 @@
 package client
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"net"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/EvilRedHorse/pubaccess-node/modules"
 	"github.com/EvilRedHorse/pubaccess-node/node/api"
@@ -16,6 +21,18 @@ import (
 	"gitlab.com/NebulousLabs/errors"
 )
 
+// portalHTTPClient is the client used to fetch publinks from pubaccess
+// portals. It limits the time to connect and to receive the response headers,
+// but not the time to stream the data of the response.
+var portalHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 2 * time.Minute,
+	},
+}
+
 // RenterSkyfileGet wraps RenterFileRootGet to query a pubfile.
 func (c *Client) RenterSkyfileGet(siaPath modules.SiaPath, root bool) (rf api.RenterFile, err error) {
 	if !root {
@@ -45,6 +62,38 @@ func (c *Client) SkynetPublinkGetWithTimeout(publink string, timeout int) ([]byt
 	return c.skynetSkylinkGetWithParameters(publink, params)
 }
 
+// SkynetPublinkGetWithPrefetch uses the /pubaccess/publink endpoint to
+// download a publink file, prefetching the given number of fanout chunks ahead
+// of the download.
+func (c *Client) SkynetPublinkGetWithPrefetch(publink string, prefetch uint64) ([]byte, modules.PubfileMetadata, error) {
+	params := map[string]string{
+		"prefetch": fmt.Sprintf("%d", prefetch),
+	}
+	return c.skynetSkylinkGetWithParameters(publink, params)
+}
+
+// SkynetPublinkIndexGet uses the /pubaccess/publink endpoint to fetch the
+// directory index of the given publink, which can contain a path, as JSON.
+func (c *Client) SkynetPublinkIndexGet(publink string) (index api.SkynetDirectoryIndex, err error) {
+	values := url.Values{}
+	values.Set("format", string(modules.SkyfileFormatIndex))
+	req, err := c.NewRequest("GET", fmt.Sprintf("/pubaccess/publink/%s?%s", publink, values.Encode()), nil)
+	if err != nil {
+		return index, errors.AddContext(err, "failed to construct GET request")
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return index, errors.AddContext(err, "GET request failed")
+	}
+	defer drainAndClose(res.Body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return index, errors.AddContext(readAPIError(res.Body), "GET request error")
+	}
+	err = json.NewDecoder(res.Body).Decode(&index)
+	return index, errors.AddContext(err, "unable to decode directory index")
+}
+
 // skynetSkylinkGetWithParameters uses the /pubaccess/publink endpoint to download
 // a publink file, specifying the given parameters.
 // The caller of this function is responsible for validating the parameters!
@@ -221,6 +270,22 @@ func (c *Client) SkynetPublinkPinPostWithTimeout(publink string, params modules.
 	return nil
 }
 
+// SkynetPublinkUnpinPost uses the /pubaccess/unpin endpoint to unpin the file
+// at the given publink.
+func (c *Client) SkynetPublinkUnpinPost(publink string) (sup api.SkynetUnpinPOST, err error) {
+	return c.SkynetPublinkUnpinPostWithForce(publink, false)
+}
+
+// SkynetPublinkUnpinPostWithForce uses the /pubaccess/unpin endpoint to unpin
+// the file at the given publink. If force is set, the pubfiles that share the
+// base sector of the publink are unpinned as well.
+func (c *Client) SkynetPublinkUnpinPostWithForce(publink string, force bool) (sup api.SkynetUnpinPOST, err error) {
+	values := url.Values{}
+	values.Set("force", fmt.Sprintf("%t", force))
+	err = c.post(fmt.Sprintf("/pubaccess/unpin/%s?%s", publink, values.Encode()), "", &sup)
+	return
+}
+
 // SkynetSkyfilePost uses the /pubaccess/pubfile endpoint to upload a pubfile.  The
 // resulting publink is returned along with an error.
 func (c *Client) SkynetSkyfilePost(params modules.PubfileUploadParameters) (string, api.SkynetSkyfileHandlerPOST, error) {
@@ -316,6 +381,9 @@ func (c *Client) SkynetSkyfileMultiPartPost(params modules.SkyfileMultipartUploa
 	values.Set("basechunkredundancy", redundancyStr)
 	rootStr := fmt.Sprintf("%t", params.Root)
 	values.Set("root", rootStr)
+	if params.ExtractArchive {
+		values.Set("extract", "true")
+	}
 
 	// Make the call to upload the file.
 	query := fmt.Sprintf("/pubaccess/pubfile/%s?%s", params.SiaPath.String(), values.Encode())
@@ -335,6 +403,121 @@ func (c *Client) SkynetSkyfileMultiPartPost(params modules.SkyfileMultipartUploa
 	return rshp.Publink, rshp, err
 }
 
+// SkynetSkyfileBatchPost uses the /pubaccess/batch endpoint to pack
+// many small files into shared sectors. The response contains one publink per
+// file, in the same order as the files of the batch.
+func (c *Client) SkynetSkyfileBatchPost(params modules.PubfileBatchUploadParameters, root bool) (api.SkynetSkyfileBatchHandlerPOST, error) {
+	// Set the url values.
+	values := url.Values{}
+	values.Set("dryrun", fmt.Sprintf("%t", params.DryRun))
+	values.Set("force", fmt.Sprintf("%t", params.Force))
+	values.Set("basechunkredundancy", fmt.Sprintf("%v", params.BaseChunkRedundancy))
+	values.Set("root", fmt.Sprintf("%t", root))
+	values.Set("siapath", params.SiaPath.String())
+
+	// Build the multipart body.
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	for _, file := range params.Files {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files[]"; filename="%s"`, file.Metadata.Filename))
+		header.Set("Content-Type", "application/octet-stream")
+		header.Set("Mode", fmt.Sprintf("%o", file.Metadata.Mode))
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return api.SkynetSkyfileBatchHandlerPOST{}, errors.AddContext(err, "unable to create multipart part")
+		}
+		if _, err := part.Write(file.Data); err != nil {
+			return api.SkynetSkyfileBatchHandlerPOST{}, errors.AddContext(err, "unable to write multipart part")
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return api.SkynetSkyfileBatchHandlerPOST{}, errors.AddContext(err, "unable to close multipart writer")
+	}
+
+	// Make the call to upload the files.
+	query := fmt.Sprintf("/pubaccess/batch?%s", values.Encode())
+	headers := map[string]string{"Content-Type": writer.FormDataContentType()}
+	_, resp, err := c.postRawResponseWithHeaders(query, body, headers)
+	if err != nil {
+		return api.SkynetSkyfileBatchHandlerPOST{}, errors.AddContext(err, "post call to "+query+" failed")
+	}
+
+	// Parse the response to get the publinks.
+	var rsbhp api.SkynetSkyfileBatchHandlerPOST
+	err = json.Unmarshal(resp, &rsbhp)
+	if err != nil {
+		return api.SkynetSkyfileBatchHandlerPOST{}, errors.AddContext(err, "unable to parse the batch upload response")
+	}
+	return rsbhp, nil
+}
+
+// SkynetResumableUploadPost uses the /pubaccess/pubfile endpoint to create a
+// resumable upload of a pubfile with the given length. The reader of the
+// params is ignored, the data is appended using SkynetUploadAppendPost.
+func (c *Client) SkynetResumableUploadPost(params modules.PubfileUploadParameters, length uint64) (modules.PubfileUploadStatus, error) {
+	// Set the url values.
+	values := url.Values{}
+	values.Set("filename", params.FileMetadata.Filename)
+	values.Set("force", fmt.Sprintf("%t", params.Force))
+	values.Set("mode", fmt.Sprintf("%o", params.FileMetadata.Mode))
+	values.Set("basechunkredundancy", fmt.Sprintf("%v", params.BaseChunkRedundancy))
+	values.Set("root", fmt.Sprintf("%t", params.Root))
+	values.Set("uploadlength", fmt.Sprint(length))
+
+	// Encode SkykeyName or PubaccesskeyID.
+	if params.SkykeyName != "" {
+		values.Set("pubaccesskeyname", params.SkykeyName)
+	}
+	if params.PubaccesskeyID != (pubaccesskey.PubaccesskeyID{}) {
+		values.Set("pubaccesskeyid", params.PubaccesskeyID.ToString())
+	}
+
+	var status modules.PubfileUploadStatus
+	query := fmt.Sprintf("/pubaccess/pubfile/%s?%s", params.SiaPath.String(), values.Encode())
+	err := c.post(query, "", &status)
+	return status, err
+}
+
+// SkynetUploadAppendPost uses the /pubaccess/uploads/:uploadid endpoint to
+// append the data of the reader to a resumable upload at the given offset.
+// Once all the data was received, the returned status contains the publink of
+// the pubfile.
+func (c *Client) SkynetUploadAppendPost(uploadID string, offset uint64, data io.Reader) (modules.PubfileUploadStatus, error) {
+	query := fmt.Sprintf("/pubaccess/uploads/%s?offset=%v", uploadID, offset)
+	headers := map[string]string{"Content-Type": "application/octet-stream"}
+	_, resp, err := c.postRawResponseWithHeaders(query, data, headers)
+	if err != nil {
+		return modules.PubfileUploadStatus{}, errors.AddContext(err, "post call to "+query+" failed")
+	}
+	var status modules.PubfileUploadStatus
+	err = json.Unmarshal(resp, &status)
+	if err != nil {
+		return modules.PubfileUploadStatus{}, errors.AddContext(err, "unable to parse the resumable upload response")
+	}
+	return status, nil
+}
+
+// SkynetUploadGet requests the /pubaccess/uploads/:uploadid endpoint to get
+// the progress of a resumable upload.
+func (c *Client) SkynetUploadGet(uploadID string) (status modules.PubfileUploadStatus, err error) {
+	err = c.get("/pubaccess/uploads/"+uploadID, &status)
+	return
+}
+
+// SkynetUploadsGet requests the /pubaccess/uploads endpoint to get the
+// progress of all unfinished resumable uploads.
+func (c *Client) SkynetUploadsGet() (uploads api.SkynetUploadsGET, err error) {
+	err = c.get("/pubaccess/uploads", &uploads)
+	return
+}
+
+// SkynetUploadAbortPost uses the /pubaccess/uploads/:uploadid/abort endpoint
+// to abort a resumable upload.
+func (c *Client) SkynetUploadAbortPost(uploadID string) error {
+	return c.post("/pubaccess/uploads/"+uploadID+"/abort", "", nil)
+}
+
 // SkynetConvertSiafileToSkyfilePost uses the /pubaccess/pubfile endpoint to
 // convert an existing siafile to a pubfile. The input SiaPath 'convert' is the
 // siapath of the siafile that should be converted. The siapath provided inside
@@ -388,12 +571,118 @@ func (c *Client) SkynetBlacklistPost(additions, removals []string) (err error) {
 	return
 }
 
+// SkynetBlacklistHashPost requests the /pubaccess/blacklist Post endpoint with
+// hashes of merkleroots instead of publinks.
+func (c *Client) SkynetBlacklistHashPost(additions, removals []string) (err error) {
+	sbp := api.SkynetBlacklistPOST{
+		Add:    additions,
+		Remove: removals,
+		IsHash: true,
+	}
+	data, err := json.Marshal(sbp)
+	if err != nil {
+		return err
+	}
+	err = c.post("/pubaccess/blacklist", string(data), nil)
+	return
+}
+
+// SkynetBlacklistSubscriptionsGet requests the
+// /pubaccess/blacklist/subscriptions Get endpoint.
+func (c *Client) SkynetBlacklistSubscriptionsGet() (subscriptions api.SkynetBlacklistSubscriptionsGET, err error) {
+	err = c.get("/pubaccess/blacklist/subscriptions", &subscriptions)
+	return
+}
+
+// SkynetBlacklistSubscriptionsPost requests the
+// /pubaccess/blacklist/subscriptions Post endpoint.
+func (c *Client) SkynetBlacklistSubscriptionsPost(additions []modules.SkynetBlacklistSubscription, removals []string) (err error) {
+	sbsp := api.SkynetBlacklistSubscriptionsPOST{
+		Add:    additions,
+		Remove: removals,
+	}
+	data, err := json.Marshal(sbsp)
+	if err != nil {
+		return err
+	}
+	err = c.post("/pubaccess/blacklist/subscriptions", string(data), nil)
+	return
+}
+
 // SkynetPortalsGet requests the /pubaccess/portals Get endpoint.
 func (c *Client) SkynetPortalsGet() (portals api.SkynetPortalsGET, err error) {
 	err = c.get("/pubaccess/portals", &portals)
 	return
 }
 
+// SkynetHealthiestPortal requests the /pubaccess/portals Get endpoint and
+// returns the healthy portal with the lowest latency.
+func (c *Client) SkynetHealthiestPortal() (modules.SkynetPortal, error) {
+	spg, err := c.SkynetPortalsGet()
+	if err != nil {
+		return modules.SkynetPortal{}, err
+	}
+	portal, ok := modules.HealthiestSkynetPortal(spg.Portals)
+	if !ok {
+		return modules.SkynetPortal{}, errors.New("no healthy pubaccess portal known")
+	}
+	return portal, nil
+}
+
+// SkynetPublinkReaderGetWithPortalFallback uses the /pubaccess/publink
+// endpoint to fetch a reader of the file data. If the node is unable to find
+// the publink or times out fetching it, the data is fetched from the
+// healthiest known portal instead. Other errors, such as a blacklisted or
+// invalid publink, are returned without falling back.
+func (c *Client) SkynetPublinkReaderGetWithPortalFallback(publink string) (io.ReadCloser, error) {
+	req, err := c.NewRequest("GET", fmt.Sprintf("/pubaccess/publink/%s", publink), nil)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to construct GET request")
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to fetch publink data")
+	}
+	if res.StatusCode >= 200 && res.StatusCode <= 299 {
+		return res.Body, nil
+	}
+	err = errors.AddContext(readAPIError(res.Body), "unable to fetch publink data")
+	drainAndClose(res.Body)
+	if res.StatusCode != http.StatusNotFound && res.StatusCode != http.StatusGatewayTimeout {
+		return nil, err
+	}
+
+	portal, portalErr := c.SkynetHealthiestPortal()
+	if portalErr != nil {
+		return nil, errors.Compose(err, portalErr)
+	}
+	reader, portalErr := skynetPortalPublinkReaderGet(portal, publink)
+	if portalErr != nil {
+		return nil, errors.Compose(err, errors.AddContext(portalErr, "unable to fetch publink from portal "+string(portal.Address)))
+	}
+	return reader, nil
+}
+
+// skynetPortalPublinkReaderGet fetches a reader of the file data of a publink
+// from the /pubaccess/publink endpoint of the portal.
+func skynetPortalPublinkReaderGet(portal modules.SkynetPortal, publink string) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/pubaccess/publink/%s", portal.Health.URL, publink), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "ScPrime-Agent")
+	res, err := portalHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		err := readAPIError(res.Body)
+		drainAndClose(res.Body)
+		return nil, err
+	}
+	return res.Body, nil
+}
+
 // SkynetPortalsPost requests the /pubaccess/portals Post endpoint.
 func (c *Client) SkynetPortalsPost(additions []modules.SkynetPortal, removals []modules.NetAddress) (err error) {
 	spp := api.SkynetPortalsPOST{
@@ -492,6 +781,29 @@ func (c *Client) SkykeyCreateKeyPost(name string, skType pubaccesskey.Pubaccessk
 	return sk, nil
 }
 
+// SkykeyCreatePasswordKeyPost requests the /pubaccess/createpubaccesskey POST
+// endpoint to create a password pubaccesskey.
+func (c *Client) SkykeyCreatePasswordKeyPost(name, password string) (pubaccesskey.Pubaccesskey, error) {
+	// Set the url values.
+	values := url.Values{}
+	values.Set("name", name)
+	values.Set("type", pubaccesskey.TypePassword.ToString())
+	values.Set("password", password)
+
+	var pubaccesskeyGet api.SkykeyGET
+	err := c.post("/pubaccess/createpubaccesskey", values.Encode(), &pubaccesskeyGet)
+	if err != nil {
+		return pubaccesskey.Pubaccesskey{}, errors.AddContext(err, "createpubaccesskey POST request failed")
+	}
+
+	var sk pubaccesskey.Pubaccesskey
+	err = sk.FromString(pubaccesskeyGet.Pubaccesskey)
+	if err != nil {
+		return pubaccesskey.Pubaccesskey{}, errors.AddContext(err, "failed to decode pubaccesskey string")
+	}
+	return sk, nil
+}
+
 // SkykeyAddKeyPost requests the /pubaccess/addpubaccesskey POST endpoint.
 func (c *Client) SkykeyAddKeyPost(sk pubaccesskey.Pubaccesskey) error {
 	values := url.Values{}
@@ -527,6 +839,50 @@ func (c *Client) SkykeySkykeysGet() ([]pubaccesskey.Pubaccesskey, error) {
 	return res, nil
 }
 
+// SkykeyRotateByIDPost requests the /pubaccess/rotatepubaccesskey POST
+// endpoint using the key ID.
+func (c *Client) SkykeyRotateByIDPost(id pubaccesskey.PubaccesskeyID, successorName string, publinks []string) (api.SkykeyRotationGET, error) {
+	values := url.Values{}
+	values.Set("id", id.ToString())
+	return c.skykeyRotatePost(values, successorName, publinks)
+}
+
+// SkykeyRotateByNamePost requests the /pubaccess/rotatepubaccesskey POST
+// endpoint using the key name.
+func (c *Client) SkykeyRotateByNamePost(name, successorName string, publinks []string) (api.SkykeyRotationGET, error) {
+	values := url.Values{}
+	values.Set("name", name)
+	return c.skykeyRotatePost(values, successorName, publinks)
+}
+
+// skykeyRotatePost requests the /pubaccess/rotatepubaccesskey POST endpoint
+// with the values identifying the key.
+func (c *Client) skykeyRotatePost(values url.Values, successorName string, publinks []string) (api.SkykeyRotationGET, error) {
+	if successorName != "" {
+		values.Set("successorname", successorName)
+	}
+	for _, publink := range publinks {
+		values.Add("publink", publink)
+	}
+	var rotation api.SkykeyRotationGET
+	err := c.post("/pubaccess/rotatepubaccesskey", values.Encode(), &rotation)
+	if err != nil {
+		return api.SkykeyRotationGET{}, errors.AddContext(err, "rotatepubaccesskey POST request failed")
+	}
+	return rotation, nil
+}
+
+// SkykeyRotationsGet requests the /pubaccess/pubaccesskeyrotations GET
+// endpoint.
+func (c *Client) SkykeyRotationsGet() (api.SkykeyRotationsGET, error) {
+	var rotations api.SkykeyRotationsGET
+	err := c.get("/pubaccess/pubaccesskeyrotations", &rotations)
+	if err != nil {
+		return api.SkykeyRotationsGET{}, errors.AddContext(err, "pubaccesskeyrotations GET request failed")
+	}
+	return rotations, nil
+}
+
 // SkynetPublinkGetWithRedirect uses the /pubaccess/publink endpoint to download a
 // publink file, specifying whether redirecting is allowed or not.
 func (c *Client) SkynetPublinkGetWithRedirect(publink string, allowRedirect bool) ([]byte, modules.PubfileMetadata, error) {
@@ -487,6 +487,15 @@ func (c *Client) RenterRateLimitPost(readBPS, writeBPS int64) (err error) {
 	return
 }
 
+// RenterMaxResumableUploadBytesPost uses the /renter endpoint to change the
+// limit of the combined length of the unfinished resumable pubfile uploads.
+func (c *Client) RenterMaxResumableUploadBytesPost(maxBytes uint64) (err error) {
+	values := url.Values{}
+	values.Set("maxresumableuploadbytes", strconv.FormatUint(maxBytes, 10))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
 // RenterRenamePost uses the /renter/rename/:siapath endpoint to rename a file.
 func (c *Client) RenterRenamePost(siaPathOld, siaPathNew modules.SiaPath, root bool) (err error) {
 	spo := escapeSiaPath(siaPathOld)
@@ -39,7 +39,9 @@ type (
 	// ContractInfoGET contains the information that is returned after a GET request
 	// to /host/contracts - information for the host about stored obligations.
 	ContractInfoGET struct {
-		Contracts []modules.StorageObligation `json:"contracts"`
+		Contracts  []modules.StorageObligation      `json:"contracts"`
+		NextCursor string                           `json:"nextcursor"`
+		Summary    modules.StorageObligationSummary `json:"summary"`
 	}
 
 	// HostContractGET contains information about the storage contract returned
@@ -48,6 +50,14 @@ type (
 		Contract modules.StorageObligation `json:"contract"`
 	}
 
+	// HostFinancialsGET contains the information that is returned after a GET
+	// request to /host/financials - the revenue and losses of the host per
+	// period.
+	HostFinancialsGET struct {
+		Periods []modules.HostFinancialPeriod `json:"periods"`
+		Total   modules.HostFinancialPeriod   `json:"total"`
+	}
+
 	// HostGET contains the information that is returned after a GET request to
 	// /host - a bunch of information about the status of the host.
 	HostGET struct {
@@ -67,6 +77,27 @@ type (
 		ConversionRate float64        `json:"conversionrate"`
 	}
 
+	// HostPricingGET contains the information that is returned after a GET
+	// request to /host/pricing - the pricing policy of the host.
+	HostPricingGET struct {
+		Policy modules.HostPricingPolicy `json:"policy"`
+	}
+
+	// HostPricingPreviewGET contains the information that is returned after a
+	// GET request to /host/pricing/preview - the inputs of the pricing policy
+	// and the prices it would set on the next block.
+	HostPricingPreviewGET struct {
+		Policy  modules.HostPricingPolicy  `json:"policy"`
+		Preview modules.HostPricingPreview `json:"preview"`
+	}
+
+	// HostTokensGET contains the information that is returned after a request
+	// to /host/tokens/compact or /host/tokens/verify - the snapshot of the
+	// token storage.
+	HostTokensGET struct {
+		Snapshot modules.TokenStorageSnapshot `json:"snapshot"`
+	}
+
 	// StorageGET contains the information that is returned after a GET request
 	// to /host/storage - a bunch of information about the status of storage
 	// management on the host.
@@ -110,11 +141,59 @@ func (api *API) hostContractGetHandler(w http.ResponseWriter, _ *http.Request, p
 	})
 }
 
+// parseStorageObligationQuery parses the storage obligation query parameters
+// of a request.
+func parseStorageObligationQuery(req *http.Request) (modules.StorageObligationQuery, error) {
+	q := modules.StorageObligationQuery{
+		Status: req.FormValue("status"),
+		Cursor: req.FormValue("cursor"),
+	}
+	params := []struct {
+		name  string
+		value interface{}
+	}{
+		{"minexpirationheight", &q.MinExpirationHeight},
+		{"maxexpirationheight", &q.MaxExpirationHeight},
+		{"minrevenue", &q.MinRevenue},
+		{"maxrevenue", &q.MaxRevenue},
+		{"limit", &q.Limit},
+	}
+	for _, param := range params {
+		if req.FormValue(param.name) == "" {
+			continue
+		}
+		_, err := fmt.Sscan(req.FormValue(param.name), param.value)
+		if err != nil {
+			return modules.StorageObligationQuery{}, fmt.Errorf("unable to parse %v: %v", param.name, err)
+		}
+	}
+	if rk := req.FormValue("renterkey"); rk != "" {
+		err := q.RenterPublicKey.LoadString(rk)
+		if err != nil {
+			return modules.StorageObligationQuery{}, fmt.Errorf("unable to parse renterkey: %v", err)
+		}
+	}
+	return q, nil
+}
+
 // hostContractInfoHandler handles the API call to get the contract information of the host.
-// Information is retrieved via the storage obligations from the host database.
-func (api *API) hostContractInfoHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+// Information is retrieved via the storage obligations from the host database,
+// optionally filtered and paginated by the query parameters.
+func (api *API) hostContractInfoHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	q, err := parseStorageObligationQuery(req)
+	if err != nil {
+		WriteError(w, Error{"error parsing storage obligation query: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	result, err := api.host.QueryStorageObligations(q)
+	if err != nil {
+		WriteError(w, Error{"failed to query storage obligations: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
 	cg := ContractInfoGET{
-		Contracts: api.host.StorageObligations(),
+		Contracts:  result.Obligations,
+		NextCursor: result.NextCursor,
+		Summary:    result.Summary,
 	}
 	WriteJSON(w, cg)
 }
@@ -313,6 +392,14 @@ func (api *API) parseHostSettings(req *http.Request) (modules.HostInternalSettin
 		}
 		settings.MaxEphemeralAccountRisk = x
 	}
+	if req.FormValue("maxregistryentries") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("maxregistryentries"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
+		settings.MaxRegistryEntries = x
+	}
 
 	// Validate the RPC, Sector Access, and Download Prices
 	minBaseRPCPrice := settings.MinBaseRPCPrice
@@ -405,6 +492,122 @@ func (api *API) hostHandlerPOST(w http.ResponseWriter, req *http.Request, _ http
 	WriteSuccess(w)
 }
 
+// parsePricingPolicy parses the pricing policy parameters of a request and
+// returns the current pricing policy of the host updated with them.
+func (api *API) parsePricingPolicy(req *http.Request) (modules.HostPricingPolicy, error) {
+	policy := api.host.PricingPolicy()
+	params := []struct {
+		name  string
+		value interface{}
+	}{
+		{"enabled", &policy.Enabled},
+		{"targetutilization", &policy.TargetUtilization},
+		{"utilizationweight", &policy.UtilizationWeight},
+		{"formationwindow", &policy.FormationWindow},
+		{"targetformationrate", &policy.TargetFormationRate},
+		{"formationweight", &policy.FormationWeight},
+		{"marketweight", &policy.MarketWeight},
+		{"maxadjustment", &policy.MaxAdjustment},
+		{"storagepricefloor", &policy.StoragePriceFloor},
+		{"storagepriceceiling", &policy.StoragePriceCeiling},
+		{"downloadbandwidthpricefloor", &policy.DownloadBandwidthPriceFloor},
+		{"downloadbandwidthpriceceiling", &policy.DownloadBandwidthPriceCeiling},
+		{"uploadbandwidthpricefloor", &policy.UploadBandwidthPriceFloor},
+		{"uploadbandwidthpriceceiling", &policy.UploadBandwidthPriceCeiling},
+		{"collateralfloor", &policy.CollateralFloor},
+		{"collateralceiling", &policy.CollateralCeiling},
+	}
+	for _, param := range params {
+		if req.FormValue(param.name) == "" {
+			continue
+		}
+		_, err := fmt.Sscan(req.FormValue(param.name), param.value)
+		if err != nil {
+			return modules.HostPricingPolicy{}, fmt.Errorf("unable to parse %v: %v", param.name, err)
+		}
+	}
+	return policy, nil
+}
+
+// hostPricingHandlerGET handles GET requests to the /host/pricing API
+// endpoint, which returns the pricing policy of the host.
+func (api *API) hostPricingHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostPricingGET{
+		Policy: api.host.PricingPolicy(),
+	})
+}
+
+// hostPricingHandlerPOST handles POST requests to the /host/pricing API
+// endpoint, which updates the pricing policy of the host.
+func (api *API) hostPricingHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	policy, err := api.parsePricingPolicy(req)
+	if err != nil {
+		WriteError(w, Error{"error parsing pricing policy: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.host.SetPricingPolicy(policy)
+	if err != nil {
+		WriteError(w, Error{"failed to set pricing policy: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// hostPricingPreviewHandlerGET handles GET requests to the
+// /host/pricing/preview API endpoint, which returns the prices the pricing
+// policy would set on the next block. The parameters of the policy can be
+// overridden for the preview without changing the policy of the host.
+func (api *API) hostPricingPreviewHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	policy, err := api.parsePricingPolicy(req)
+	if err != nil {
+		WriteError(w, Error{"error parsing pricing policy: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	preview, err := api.host.PricingPreview(policy)
+	if err != nil {
+		WriteError(w, Error{"failed to preview pricing policy: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, HostPricingPreviewGET{
+		Policy:  policy,
+		Preview: preview,
+	})
+}
+
+// hostFinancialsHandlerGET handles GET requests to the /host/financials API
+// endpoint, which returns the revenue and losses of the storage obligations
+// that were resolved within a range of block heights.
+func (api *API) hostFinancialsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var from, to, period types.BlockHeight
+	params := []struct {
+		name  string
+		value *types.BlockHeight
+	}{
+		{"from", &from},
+		{"to", &to},
+		{"period", &period},
+	}
+	for _, param := range params {
+		if req.FormValue(param.name) == "" {
+			continue
+		}
+		_, err := fmt.Sscan(req.FormValue(param.name), param.value)
+		if err != nil {
+			WriteError(w, Error{fmt.Sprintf("unable to parse %v: %v", param.name, err)}, http.StatusBadRequest)
+			return
+		}
+	}
+	ledger, err := api.host.FinancialLedger(from, to, period)
+	if err != nil {
+		WriteError(w, Error{"failed to get host financials: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, HostFinancialsGET{
+		Periods: ledger.Periods,
+		Total:   ledger.Total,
+	})
+}
+
 // hostAnnounceHandler handles the API call to get the host to announce itself
 // to the network.
 func (api *API) hostAnnounceHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
@@ -421,6 +624,28 @@ func (api *API) hostAnnounceHandler(w http.ResponseWriter, req *http.Request, _
 	WriteSuccess(w)
 }
 
+// hostTokensCompactHandlerPOST handles the API call to compact the events log
+// of the token storage.
+func (api *API) hostTokensCompactHandlerPOST(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	snapshot, err := api.host.CompactTokenStorage()
+	if err != nil {
+		WriteError(w, Error{"failed to compact token storage: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, HostTokensGET{Snapshot: snapshot})
+}
+
+// hostTokensVerifyHandlerGET handles the API call to verify the snapshot and
+// the events log of the token storage.
+func (api *API) hostTokensVerifyHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	snapshot, err := api.host.VerifyTokenStorage()
+	if err != nil {
+		WriteError(w, Error{"failed to verify token storage: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, HostTokensGET{Snapshot: snapshot})
+}
+
 // storageHandler returns a bunch of information about storage management on
 // the host.
 func (api *API) storageHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
@@ -446,6 +671,35 @@ func (api *API) storageFoldersAddHandler(w http.ResponseWriter, req *http.Reques
 	WriteSuccess(w)
 }
 
+// storageFoldersMigrateHandler starts migrating the sectors of a storage
+// folder to another storage folder.
+func (api *API) storageFoldersMigrateHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	fromPath := req.FormValue("from")
+	toPath := req.FormValue("to")
+	if fromPath == "" || toPath == "" {
+		WriteError(w, Error{"from and to parameters are required"}, http.StatusBadRequest)
+		return
+	}
+
+	storageFolders := api.host.StorageFolders()
+	fromIndex, err := folderIndex(fromPath, storageFolders)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	toIndex, err := folderIndex(toPath, storageFolders)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.host.MigrateStorageFolder(uint16(fromIndex), uint16(toIndex))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // storageFoldersResizeHandler resizes a storage folder in the storage manager.
 func (api *API) storageFoldersResizeHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	folderPath := req.FormValue("path")
@@ -885,6 +885,63 @@ func TestRemoveEmptyStorageFolder(t *testing.T) {
 	}
 }
 
+// TestMigrateEmptyStorageFolder tests migrating an empty storage folder to
+// another storage folder.
+func TestMigrateEmptyStorageFolder(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	// Set up two storage folders for the host.
+	if err := st.setHostStorage(); err != nil {
+		t.Fatal(err)
+	}
+	destDir := filepath.Join(st.dir, "dest")
+	if err := os.MkdirAll(destDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	addValues := url.Values{}
+	addValues.Set("path", destDir)
+	addValues.Set("size", mediumSizeFolderString)
+	if err = st.stdPostAPI("/host/storage/folders/add", addValues); err != nil {
+		t.Fatal(err)
+	}
+
+	// The call to migrate should fail without a destination.
+	migrateValues := url.Values{}
+	migrateValues.Set("from", st.dir)
+	if err = st.stdPostAPI("/host/storage/folders/migrate", migrateValues); err == nil {
+		t.Fatal("expected migration without destination to fail")
+	}
+
+	// Migrate the storage folder.
+	migrateValues.Set("to", destDir)
+	if err = st.stdPostAPI("/host/storage/folders/migrate", migrateValues); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		var sg StorageGET
+		if err := st.getAPI("/host/storage", &sg); err != nil {
+			return err
+		}
+		for _, sf := range sg.Folders {
+			if sf.Migrating {
+				return errors.New("storage folder is still migrating")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 // TestRemoveStorageFolderError checks that invalid calls to
 // /host/storage/folders/remove fail with the appropriate error.
 func TestRemoveStorageFolderError(t *testing.T) {
@@ -1132,3 +1189,104 @@ func TestDeleteNonexistentSector(t *testing.T) {
 		t.Fatalf("expected error to be %v; got %v", crypto.ErrHashWrongLen, err)
 	}
 }
+
+// TestHostPricing tests the /host/pricing and /host/pricing/preview endpoints.
+func TestHostPricing(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	// The policy is disabled by default.
+	var hpg HostPricingGET
+	if err := st.getAPI("/host/pricing", &hpg); err != nil {
+		t.Fatal(err)
+	}
+	if hpg.Policy.Enabled {
+		t.Fatal("pricing policy shouldn't be enabled by default")
+	}
+
+	// Invalid policies are rejected.
+	values := url.Values{}
+	values.Set("maxadjustment", "2")
+	if err := st.stdPostAPI("/host/pricing", values); err == nil {
+		t.Fatal("expected invalid max adjustment to be rejected")
+	}
+
+	// Preview a policy without changing it.
+	var hppg HostPricingPreviewGET
+	if err := st.getAPI("/host/pricing/preview?maxadjustment=0.5", &hppg); err != nil {
+		t.Fatal(err)
+	}
+	if hppg.Policy.MaxAdjustment != 0.5 {
+		t.Fatal("preview didn't use the given policy", hppg.Policy.MaxAdjustment)
+	}
+	var hg HostGET
+	if err := st.getAPI("/host", &hg); err != nil {
+		t.Fatal(err)
+	}
+	if !hppg.Preview.Current.StoragePrice.Equals(hg.InternalSettings.MinStoragePrice) {
+		t.Fatal("wrong current storage price in preview")
+	}
+	if err := st.getAPI("/host/pricing", &hpg); err != nil {
+		t.Fatal(err)
+	}
+	if hpg.Policy.MaxAdjustment == 0.5 {
+		t.Fatal("preview changed the policy")
+	}
+
+	// Enable the policy.
+	values = url.Values{}
+	values.Set("enabled", "true")
+	values.Set("maxadjustment", "0.05")
+	if err := st.stdPostAPI("/host/pricing", values); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/host/pricing", &hpg); err != nil {
+		t.Fatal(err)
+	}
+	if !hpg.Policy.Enabled || hpg.Policy.MaxAdjustment != 0.05 {
+		t.Fatal("pricing policy wasn't updated", hpg.Policy)
+	}
+}
+
+// TestHostFinancials checks the periods returned by the /host/financials
+// endpoint.
+func TestHostFinancials(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	// The whole range up to the current height is a single period by default.
+	var hfg HostFinancialsGET
+	if err := st.getAPI("/host/financials", &hfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(hfg.Periods) != 1 || hfg.Periods[0].EndHeight != st.cs.Height() || hfg.Total.EndHeight != st.cs.Height() {
+		t.Fatal("wrong default period", hfg.Periods, hfg.Total)
+	}
+
+	// The range is split into periods.
+	if err := st.getAPI("/host/financials?from=1&to=10&period=4", &hfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(hfg.Periods) != 3 || hfg.Periods[1].StartHeight != 5 || hfg.Periods[2].EndHeight != 10 {
+		t.Fatal("wrong periods", hfg.Periods)
+	}
+
+	// Invalid ranges are rejected.
+	if err := st.getAPI("/host/financials?from=10&to=5", &hfg); err == nil {
+		t.Fatal("expected invalid range to be rejected")
+	}
+}
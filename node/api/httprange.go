@@ -0,0 +1,70 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+)
+
+// httpRange is a single byte range of an HTTP Range header.
+type httpRange struct {
+	start  uint64
+	length uint64
+}
+
+// parseRangeHeader parses the byte ranges of an HTTP Range header for content
+// of the given size. Ranges which don't overlap the content are skipped. If the
+// header is malformed, no ranges are returned, http.ServeContent will reject
+// the header when serving the content.
+func parseRangeHeader(header string, size uint64) []httpRange {
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return nil
+	}
+	var ranges []httpRange
+	for _, spec := range strings.Split(header[len(prefix):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		i := strings.Index(spec, "-")
+		if i < 0 {
+			return nil
+		}
+		startStr, endStr := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+
+		// A suffix range like '-500' refers to the final bytes of the content.
+		if startStr == "" {
+			n, err := strconv.ParseUint(endStr, 10, 64)
+			if err != nil {
+				return nil
+			}
+			if n > size {
+				n = size
+			}
+			if n > 0 {
+				ranges = append(ranges, httpRange{start: size - n, length: n})
+			}
+			continue
+		}
+
+		start, err := strconv.ParseUint(startStr, 10, 64)
+		if err != nil {
+			return nil
+		}
+		if start >= size {
+			continue
+		}
+		end := size - 1
+		if endStr != "" {
+			end, err = strconv.ParseUint(endStr, 10, 64)
+			if err != nil || end < start {
+				return nil
+			}
+			if end >= size {
+				end = size - 1
+			}
+		}
+		ranges = append(ranges, httpRange{start: start, length: end - start + 1})
+	}
+	return ranges
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseRangeHeader verifies the parsing of HTTP Range headers.
+func TestParseRangeHeader(t *testing.T) {
+	size := uint64(1000)
+	tests := []struct {
+		header string
+		ranges []httpRange
+	}{
+		{"", nil},
+		{"bytes=", nil},
+		{"items=0-10", nil},
+		{"bytes=0-9", []httpRange{{0, 10}}},
+		{"bytes=990-", []httpRange{{990, 10}}},
+		{"bytes=-10", []httpRange{{990, 10}}},
+		{"bytes=-2000", []httpRange{{0, 1000}}},
+		{"bytes=900-2000", []httpRange{{900, 100}}},
+		{"bytes=0-9, 100-199,-5", []httpRange{{0, 10}, {100, 100}, {995, 5}}},
+		{"bytes=1000-1100,0-0", []httpRange{{0, 1}}},
+		{"bytes=10-5", nil},
+		{"bytes=a-5", nil},
+		{"bytes=0-9,10", nil},
+	}
+	for _, test := range tests {
+		ranges := parseRangeHeader(test.header, size)
+		if !reflect.DeepEqual(ranges, test.ranges) {
+			t.Errorf("header '%v': expected %v but got %v", test.header, test.ranges, ranges)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/types"
+)
+
+type (
+	// IndexGET is the object returned by a GET request to /index.
+	IndexGET struct {
+		Height types.BlockHeight `json:"height"`
+	}
+
+	// IndexAddressOutputsGET is the object returned by a GET request to
+	// /index/address/:addr/outputs.
+	IndexAddressOutputsGET struct {
+		Outputs []modules.IndexOutput `json:"outputs"`
+	}
+)
+
+// indexHandler handles the API call asking for the state of the index.
+func (api *API) indexHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, IndexGET{
+		Height: api.index.Height(),
+	})
+}
+
+// indexAddressBalanceHandler handles the API call asking for the confirmed
+// balance of an address.
+func (api *API) indexAddressBalanceHandler(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	addr, err := scanAddress(ps.ByName("addr"))
+	if err != nil {
+		WriteError(w, Error{"could not parse address: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	balance, err := api.index.AddressBalance(addr)
+	if err != nil {
+		WriteError(w, Error{"unable to get address balance: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, balance)
+}
+
+// indexAddressOutputsHandler handles the API call asking for the unspent
+// outputs of an address.
+func (api *API) indexAddressOutputsHandler(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	addr, err := scanAddress(ps.ByName("addr"))
+	if err != nil {
+		WriteError(w, Error{"could not parse address: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	outputs, err := api.index.AddressUnspentOutputs(addr)
+	if err != nil {
+		WriteError(w, Error{"unable to get unspent outputs: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, IndexAddressOutputsGET{
+		Outputs: outputs,
+	})
+}
@@ -79,6 +79,19 @@ func (ls *limitStreamer) Seek(offset int64, whence int) (int64, error) {
 	return offset - int64(ls.base), nil
 }
 
+// Prefetch implements the modules.PrefetchStreamer interface, the prefetch is
+// forwarded to the wrapped streamer if it supports prefetching.
+func (ls *limitStreamer) Prefetch(offset, length uint64) {
+	ps, ok := ls.stream.(modules.PrefetchStreamer)
+	if !ok || ls.base+offset >= ls.limit {
+		return
+	}
+	if max := ls.limit - ls.base - offset; length > max {
+		length = max
+	}
+	ps.Prefetch(ls.base+offset, length)
+}
+
 // Close implements the io.Closer interface
 func (ls *limitStreamer) Close() error {
 	return ls.stream.Close()
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"time"
 
@@ -10,6 +12,12 @@ import (
 	"github.com/EvilRedHorse/pubaccess-node/types"
 )
 
+const (
+	// defaultPoolBlocksLimit is the number of blocks returned by /pool/blocks
+	// if no limit is specified.
+	defaultPoolBlocksLimit = 50
+)
+
 type (
 	// MiningPoolGET contains the stats that are returned after a GET request
 	// to /pool.
@@ -20,11 +28,25 @@ type (
 	// MiningPoolConfig contains the parameters you can set to config your pool
 	MiningPoolConfig struct {
 		NetworkPort    int              `json:"networkport"`
+		DBDriver       string           `json:"dbdriver"`
 		DBConnection   string           `json:"dbconnection"`
 		Name           string           `json:"name"`
 		PoolID         uint64           `json:"poolid"`
 		PoolWallet     types.UnlockHash `json:"poolwallet"`
 		OperatorWallet types.UnlockHash `json:"operatorwallet"`
+
+		TLSNetworkPort int    `json:"tlsnetworkport"`
+		TLSCertFile    string `json:"tlscertfile"`
+
+		PayoutScheme       string         `json:"payoutscheme"`
+		PPLNSWindow        float64        `json:"pplnswindow"`
+		OperatorPercentage float64        `json:"operatorpercentage"`
+		MinimumPayout      types.Currency `json:"minimumpayout"`
+	}
+	// MiningPoolPayoutsGET contains the payouts that are returned after a GET
+	// request to /pool/payouts
+	MiningPoolPayoutsGET struct {
+		Payouts []modules.PoolPayout `json:"payouts"`
 	}
 	// MiningPoolClientsInfo returns the stats are return after a GET request
 	// to /pool/clients
@@ -38,6 +60,7 @@ type (
 		ClientName  string           `json:"clientname"`
 		BlocksMined uint64           `json:"blocksminer"`
 		Balance     string           `json:"balance"`
+		Hashrate    float64          `json:"hashrate"`
 		Workers     []PoolWorkerInfo `json:"workers"`
 	}
 	// MiningPoolClientTransaction returns info for a single transaction
@@ -56,14 +79,17 @@ type (
 		InvalidSharesThisBlock uint64    `json:"invalidsharesthisblock"`
 		StaleSharesThisBlock   uint64    `json:"stalesharesthisblock"`
 		BlocksFound            uint64    `json:"blocksfound"`
+		Hashrate               float64   `json:"hashrate"`
 	}
 	// MiningPoolBlockInfo returns info about one of the pool's blocks
 	MiningPoolBlockInfo struct {
-		BlockNumber uint64    `json:"blocknumber"`
-		BlockHeight uint64    `json:"blockheight"`
-		BlockReward string    `json:"blockreward"`
-		BlockTime   time.Time `json:"blocktime"`
-		BlockStatus string    `json:"blockstatus"`
+		BlockNumber   uint64    `json:"blocknumber"`
+		BlockHeight   uint64    `json:"blockheight"`
+		BlockID       string    `json:"blockid"`
+		BlockReward   string    `json:"blockreward"`
+		BlockTime     time.Time `json:"blocktime"`
+		BlockStatus   string    `json:"blockstatus"`
+		Confirmations uint64    `json:"confirmations"`
 	}
 	// MiningPoolBlockClientInfo returns info about one of the pool's block's clients
 	MiningPoolBlockClientInfo struct {
@@ -75,13 +101,147 @@ type (
 
 // poolHandler handles the API call that queries the pool's status.
 func (api *API) poolHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	clients, err := api.pool.Clients()
+	if err != nil {
+		WriteError(w, Error{"unable to get clients: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	blocks, err := api.pool.Blocks(0, math.MaxInt32)
+	if err != nil {
+		WriteError(w, Error{"unable to get blocks: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	var hashrate float64
+	for _, c := range clients {
+		hashrate += c.Hashrate
+	}
 	pg := MiningPoolGET{
-		BlocksMined:  0,
-		PoolHashrate: 0,
+		BlocksMined:  len(blocks),
+		PoolHashrate: int(hashrate),
 	}
 	WriteJSON(w, pg)
 }
 
+// poolClientInfo converts a client of the pool to its API representation.
+func poolClientInfo(c modules.PoolClient) MiningPoolClientInfo {
+	info := MiningPoolClientInfo{
+		ClientName:  c.ClientName,
+		BlocksMined: c.BlocksMined,
+		Balance:     c.Balance,
+		Hashrate:    c.Hashrate,
+		Workers:     make([]PoolWorkerInfo, 0, len(c.Workers)),
+	}
+	for _, w := range c.Workers {
+		info.Workers = append(info.Workers, PoolWorkerInfo{
+			WorkerName:             w.WorkerName,
+			LastShareTime:          w.LastShareTime,
+			CurrentDifficulty:      w.CurrentDifficulty,
+			CumulativeDifficulty:   w.CumulativeDifficulty,
+			SharesThisBlock:        w.SharesThisBlock,
+			InvalidSharesThisBlock: w.InvalidSharesThisBlock,
+			StaleSharesThisBlock:   w.StaleSharesThisBlock,
+			BlocksFound:            w.BlocksFound,
+			Hashrate:               w.Hashrate,
+		})
+	}
+	return info
+}
+
+// poolClientsHandler handles the API call that lists the clients that are
+// connected to the pool.
+func (api *API) poolClientsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	clients, err := api.pool.Clients()
+	if err != nil {
+		WriteError(w, Error{"unable to get clients: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	info := MiningPoolClientsInfo{
+		NumberOfClients: uint64(len(clients)),
+		Clients:         make([]MiningPoolClientInfo, 0, len(clients)),
+	}
+	for _, c := range clients {
+		info.NumberOfWorkers += uint64(len(c.Workers))
+		info.Clients = append(info.Clients, poolClientInfo(c))
+	}
+	WriteJSON(w, info)
+}
+
+// poolClientHandler handles the API call that returns the details of a
+// single client.
+func (api *API) poolClientHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	name := req.FormValue("name")
+	if name == "" {
+		WriteError(w, Error{"client name must be specified"}, http.StatusBadRequest)
+		return
+	}
+	client, err := api.pool.ClientInfo(name)
+	if err != nil {
+		WriteError(w, Error{"unable to get client: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, poolClientInfo(client))
+}
+
+// poolBlocksHandler handles the API call that returns a page of the blocks
+// found by the pool, most recent first.
+func (api *API) poolBlocksHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	offset, limit := 0, defaultPoolBlocksLimit
+	if req.FormValue("offset") != "" {
+		if _, err := fmt.Sscan(req.FormValue("offset"), &offset); err != nil {
+			WriteError(w, Error{"unable to parse offset: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.FormValue("limit") != "" {
+		if _, err := fmt.Sscan(req.FormValue("limit"), &limit); err != nil {
+			WriteError(w, Error{"unable to parse limit: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	blocks, err := api.pool.Blocks(offset, limit)
+	if err != nil {
+		WriteError(w, Error{"unable to get blocks: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	infos := make([]MiningPoolBlockInfo, 0, len(blocks))
+	for _, b := range blocks {
+		infos = append(infos, MiningPoolBlockInfo{
+			BlockNumber:   b.BlockNumber,
+			BlockHeight:   b.BlockHeight,
+			BlockID:       b.BlockID,
+			BlockReward:   b.BlockReward,
+			BlockTime:     b.BlockTime,
+			BlockStatus:   b.BlockStatus,
+			Confirmations: b.Confirmations,
+		})
+	}
+	WriteJSON(w, infos)
+}
+
+// poolBlockHandler handles the API call that returns how the reward of a
+// found block was split between the clients.
+func (api *API) poolBlockHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var number uint64
+	if _, err := fmt.Sscan(req.FormValue("block"), &number); err != nil {
+		WriteError(w, Error{"unable to parse block number: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	clients, err := api.pool.BlockClients(number)
+	if err != nil {
+		WriteError(w, Error{"unable to get block: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	infos := make([]MiningPoolBlockClientInfo, 0, len(clients))
+	for _, c := range clients {
+		infos = append(infos, MiningPoolBlockClientInfo{
+			ClientName:       c.ClientName,
+			ClientPercentage: c.ClientPercentage,
+			ClientReward:     c.ClientReward,
+		})
+	}
+	WriteJSON(w, infos)
+}
+
 // poolConfigHandlerPOST handles POST request to the /pool API endpoint, which sets
 // the internal settings of the pool.
 func (api *API) poolConfigHandlerPOST(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
@@ -108,13 +268,77 @@ func (api *API) poolConfigHandler(w http.ResponseWriter, req *http.Request, _ ht
 	pg := MiningPoolConfig{
 		Name:         settings.PoolName,
 		NetworkPort:  settings.PoolNetworkPort,
+		DBDriver:     settings.PoolDBDriver,
 		DBConnection: settings.PoolDBConnection,
 		PoolID:       settings.PoolID,
 		PoolWallet:   settings.PoolWallet,
+
+		TLSNetworkPort: settings.PoolTLSNetworkPort,
+		TLSCertFile:    settings.PoolTLSCertFile,
+
+		PayoutScheme:       settings.PoolPayoutScheme,
+		PPLNSWindow:        settings.PoolPPLNSWindow,
+		OperatorPercentage: settings.PoolOperatorPercentage,
+		MinimumPayout:      settings.PoolMinimumPayout,
 	}
 	WriteJSON(w, pg)
 }
 
+// poolPayoutsHandler handles the API call that returns the payouts of the
+// pool, optionally filtered by the name of a client.
+func (api *API) poolPayoutsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	payouts, err := api.pool.Payouts(req.FormValue("name"))
+	if err != nil {
+		WriteError(w, Error{"unable to get payouts: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, MiningPoolPayoutsGET{Payouts: payouts})
+}
+
+// poolReconnectHandlerPOST handles the API call that asks the connected miners
+// to reconnect to another pool instance.
+func (api *API) poolReconnectHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	host := req.FormValue("host")
+	var port int
+	if req.FormValue("port") != "" {
+		_, err := fmt.Sscan(req.FormValue("port"), &port)
+		if err != nil {
+			WriteError(w, Error{"unable to parse port: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	var wait int
+	if req.FormValue("wait") != "" {
+		_, err := fmt.Sscan(req.FormValue("wait"), &wait)
+		if err != nil || wait < 0 {
+			WriteError(w, Error{"unable to parse wait"}, http.StatusBadRequest)
+			return
+		}
+	}
+	err := api.pool.ReconnectClients(host, port, time.Duration(wait)*time.Second)
+	if err != nil {
+		WriteError(w, Error{"unable to reconnect clients: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// poolMessageHandlerPOST handles the API call that shows a message to the
+// operators of the connected miners.
+func (api *API) poolMessageHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	message := req.FormValue("message")
+	if message == "" {
+		WriteError(w, Error{"message is required"}, http.StatusBadRequest)
+		return
+	}
+	err := api.pool.BroadcastMessage(message)
+	if err != nil {
+		WriteError(w, Error{"unable to send message: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // parsePoolSettings a request's query strings and returns a
 // modules.PoolInternalSettings configured with the request's query string
 // parameters.
@@ -152,6 +376,49 @@ func (api *API) parsePoolSettings(req *http.Request) (modules.PoolInternalSettin
 	if req.FormValue("dbconnection") != "" {
 		settings.PoolDBConnection = req.FormValue("dbconnection")
 	}
+	if req.FormValue("tlsnetworkport") != "" {
+		var x int
+		_, err := fmt.Sscan(req.FormValue("tlsnetworkport"), &x)
+		if err != nil {
+			return modules.PoolInternalSettings{}, err
+		}
+		settings.PoolTLSNetworkPort = x
+	}
+	if req.FormValue("tlscertfile") != "" {
+		settings.PoolTLSCertFile = req.FormValue("tlscertfile")
+	}
+	if req.FormValue("tlskeyfile") != "" {
+		settings.PoolTLSKeyFile = req.FormValue("tlskeyfile")
+	}
+	if req.FormValue("payoutscheme") != "" {
+		settings.PoolPayoutScheme = req.FormValue("payoutscheme")
+	}
+	if req.FormValue("pplnswindow") != "" {
+		var x float64
+		_, err := fmt.Sscan(req.FormValue("pplnswindow"), &x)
+		if err != nil {
+			return modules.PoolInternalSettings{}, err
+		}
+		settings.PoolPPLNSWindow = x
+	}
+	if req.FormValue("operatorpercentage") != "" {
+		var x float64
+		_, err := fmt.Sscan(req.FormValue("operatorpercentage"), &x)
+		if err != nil {
+			return modules.PoolInternalSettings{}, err
+		}
+		if x < 0 || x > 100 {
+			return modules.PoolInternalSettings{}, errors.New("operatorpercentage must be between 0 and 100")
+		}
+		settings.PoolOperatorPercentage = x
+	}
+	if req.FormValue("minimumpayout") != "" {
+		x, ok := scanAmount(req.FormValue("minimumpayout"))
+		if !ok {
+			return modules.PoolInternalSettings{}, errors.New("unable to parse minimumpayout")
+		}
+		settings.PoolMinimumPayout = x
+	}
 	err := api.pool.SetInternalSettings(settings)
 	return settings, err
 }
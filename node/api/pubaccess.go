@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"mime"
 	"net/http"
 	"net/url"
@@ -45,6 +46,11 @@ const (
 	// could cause a go-routine leak by creating a bunch of requests with very
 	// high timeouts.
 	MaxSkynetRequestTimeout = 15 * 60 // in seconds
+
+	// MaxSkynetPrefetch is the maximum number of fanout chunks a user is
+	// allowed to prefetch ahead of the reader. Every prefetched chunk is kept
+	// in memory, so this limits the memory used by a single download.
+	MaxSkynetPrefetch = 16
 )
 
 var (
@@ -62,6 +68,19 @@ type (
 		Bitfield   uint16      `json:"bitfield"`
 	}
 
+	// SkynetSkyfileBatchHandlerPOST is the response that the api returns
+	// after the /pubaccess/pubfile/batch POST endpoint has been used. The
+	// pubfiles are listed in the same order as they were uploaded.
+	SkynetSkyfileBatchHandlerPOST struct {
+		Pubfiles []SkynetSkyfileBatchFile `json:"pubfiles"`
+	}
+
+	// SkynetSkyfileBatchFile describes a single pubfile of a batch upload.
+	SkynetSkyfileBatchFile struct {
+		Filename string `json:"filename"`
+		SkynetSkyfileHandlerPOST
+	}
+
 	// SkynetBlacklistGET contains the information queried for the
 	// /pubaccess/blacklist GET endpoint
 	//
@@ -69,14 +88,30 @@ type (
 	// the []crypto.Hash was a slice of MerkleRoots. Post v1.5.0 the []crypto.Hash
 	// is a slice of the Hashes of the MerkleRoots
 	SkynetBlacklistGET struct {
-		Blacklist []crypto.Hash `json:"blacklist"`
+		Blacklist []crypto.Hash                  `json:"blacklist"`
+		Entries   []modules.SkynetBlacklistEntry `json:"entries"`
 	}
 
 	// SkynetBlacklistPOST contains the information needed for the
-	// /pubaccess/blacklist POST endpoint to be called
+	// /pubaccess/blacklist POST endpoint to be called. If IsHash is set, Add
+	// and Remove contain hashes of merkleroots instead of publinks.
 	SkynetBlacklistPOST struct {
 		Add    []string `json:"add"`
 		Remove []string `json:"remove"`
+		IsHash bool     `json:"ishash"`
+	}
+
+	// SkynetBlacklistSubscriptionsGET contains the information queried for
+	// the /pubaccess/blacklist/subscriptions GET endpoint.
+	SkynetBlacklistSubscriptionsGET struct {
+		Subscriptions []modules.SkynetBlacklistSubscription `json:"subscriptions"`
+	}
+
+	// SkynetBlacklistSubscriptionsPOST contains the information needed for
+	// the /pubaccess/blacklist/subscriptions POST endpoint to be called.
+	SkynetBlacklistSubscriptionsPOST struct {
+		Add    []modules.SkynetBlacklistSubscription `json:"add"`
+		Remove []string                              `json:"remove"`
 	}
 
 	// SkynetPortalsGET contains the information queried for the /pubaccess/portals
@@ -92,6 +127,19 @@ type (
 		Remove []modules.NetAddress   `json:"remove"`
 	}
 
+	// SkynetUploadsGET contains the information queried for the
+	// /pubaccess/uploads GET endpoint.
+	SkynetUploadsGET struct {
+		Uploads []modules.PubfileUploadStatus `json:"uploads"`
+	}
+
+	// SkynetUnpinPOST is the response that the api returns after the
+	// /pubaccess/unpin POST endpoint has been used.
+	SkynetUnpinPOST struct {
+		FreedBytes uint64            `json:"freedbytes"`
+		SiaPaths   []modules.SiaPath `json:"siapaths"`
+	}
+
 	// SkynetStatsGET contains the information queried for the /pubaccess/stats
 	// GET endpoint
 	SkynetStatsGET struct {
@@ -126,6 +174,18 @@ type (
 		Pubaccesskeys []SkykeyGET `json:"pubaccesskeys"`
 	}
 
+	// SkykeyRotationGET contains the rotation of a Pubaccesskey.
+	SkykeyRotationGET struct {
+		KeyID     string                   `json:"keyid"` // base64 encoded ID of the rotated Pubaccesskey
+		Successor SkykeyGET                `json:"successor"`
+		CreatedAt time.Time                `json:"createdat"`
+		Publinks  []modules.RotatedPublink `json:"publinks"`
+	}
+	// SkykeyRotationsGET contains a slice of Pubaccesskey rotations.
+	SkykeyRotationsGET struct {
+		Rotations []SkykeyRotationGET `json:"rotations"`
+	}
+
 	// archiveFunc is a function that serves subfiles from src to dst and
 	// archives them using a certain algorithm.
 	archiveFunc func(dst io.Writer, src io.Reader, files []modules.PubfileSubfileMetadata) error
@@ -140,9 +200,15 @@ func (api *API) skynetBlacklistHandlerGET(w http.ResponseWriter, _ *http.Request
 		WriteError(w, Error{"unable to get the blacklist: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
+	entries, err := api.renter.BlacklistEntries()
+	if err != nil {
+		WriteError(w, Error{"unable to get the blacklist: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
 
 	WriteJSON(w, SkynetBlacklistGET{
 		Blacklist: blacklist,
+		Entries:   entries,
 	})
 }
 
@@ -162,6 +228,27 @@ func (api *API) skynetBlacklistHandlerPOST(w http.ResponseWriter, req *http.Requ
 		return
 	}
 
+	// Hashes are added to the blacklist as they are.
+	if params.IsHash {
+		addHashes, err := parseBlacklistHashes(params.Add)
+		if err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+			return
+		}
+		removeHashes, err := parseBlacklistHashes(params.Remove)
+		if err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+			return
+		}
+		err = api.renter.UpdateSkynetBlacklistHashes(addHashes, removeHashes)
+		if err != nil {
+			WriteError(w, Error{"unable to update the pubaccess blacklist: " + err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		WriteSuccess(w)
+		return
+	}
+
 	// Convert to Publinks
 	addPublinks := make([]modules.Publink, len(params.Add))
 	for i, addStr := range params.Add {
@@ -194,6 +281,59 @@ func (api *API) skynetBlacklistHandlerPOST(w http.ResponseWriter, req *http.Requ
 	WriteSuccess(w)
 }
 
+// parseBlacklistHashes parses the hashes of merkleroots submitted to the
+// /pubaccess/blacklist POST endpoint.
+func parseBlacklistHashes(strs []string) ([]crypto.Hash, error) {
+	hashes := make([]crypto.Hash, len(strs))
+	for i, str := range strs {
+		err := hashes[i].LoadString(str)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing hash: %v", err)
+		}
+	}
+	return hashes, nil
+}
+
+// skynetBlacklistSubscriptionsHandlerGET handles the API call to get the list
+// of subscribed remote blacklist feeds.
+func (api *API) skynetBlacklistSubscriptionsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	subscriptions, err := api.renter.BlacklistSubscriptions()
+	if err != nil {
+		WriteError(w, Error{"unable to get the blacklist subscriptions: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteJSON(w, SkynetBlacklistSubscriptionsGET{
+		Subscriptions: subscriptions,
+	})
+}
+
+// skynetBlacklistSubscriptionsHandlerPOST handles the API call to subscribe to
+// and unsubscribe from remote blacklist feeds.
+func (api *API) skynetBlacklistSubscriptionsHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse parameters
+	var params SkynetBlacklistSubscriptionsPOST
+	err := json.NewDecoder(req.Body).Decode(&params)
+	if err != nil {
+		WriteError(w, Error{"invalid parameters: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Check for nil input
+	if len(params.Add) == 0 && len(params.Remove) == 0 {
+		WriteError(w, Error{"no subscriptions submitted"}, http.StatusBadRequest)
+		return
+	}
+
+	err = api.renter.UpdateSkynetBlacklistSubscriptions(params.Add, params.Remove)
+	if err != nil {
+		WriteError(w, Error{"unable to update the blacklist subscriptions: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteSuccess(w)
+}
+
 // skynetPortalsHandlerGET handles the API call to get the list of known pubaccess
 // portals.
 func (api *API) skynetPortalsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
@@ -279,8 +419,9 @@ func (api *API) skynetPublinkHandlerGET(w http.ResponseWriter, req *http.Request
 	case modules.SkyfileFormatTarGz:
 	case modules.SkyfileFormatConcat:
 	case modules.SkyfileFormatZip:
+	case modules.SkyfileFormatIndex:
 	default:
-		WriteError(w, Error{"unable to parse 'format' parameter, allowed values are: 'concat', 'tar', 'targz' and 'zip'"}, http.StatusBadRequest)
+		WriteError(w, Error{"unable to parse 'format' parameter, allowed values are: 'concat', 'index', 'tar', 'targz' and 'zip'"}, http.StatusBadRequest)
 		return
 	}
 
@@ -301,11 +442,32 @@ func (api *API) skynetPublinkHandlerGET(w http.ResponseWriter, req *http.Request
 		timeout = time.Duration(timeoutInt) * time.Second
 	}
 
+	// Parse the prefetch.
+	var prefetch uint64
+	prefetchStr := queryForm.Get("prefetch")
+	if prefetchStr != "" {
+		prefetch, err = strconv.ParseUint(prefetchStr, 10, 64)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'prefetch' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if prefetch > MaxSkynetPrefetch {
+			WriteError(w, Error{fmt.Sprintf("'prefetch' parameter too high, maximum allowed prefetch is %d", MaxSkynetPrefetch)}, http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Fetch the pubfile's metadata and a streamer to download the file
-	metadata, streamer, err := api.renter.DownloadPublink(publink, timeout)
+	metadata, streamer, err := api.renter.DownloadPublink(publink, timeout, prefetch)
 	if errors.Contains(err, renter.ErrRootNotFound) {
 		WriteError(w, Error{fmt.Sprintf("failed to fetch publink: %v", err)}, http.StatusNotFound)
 		return
+	} else if errors.Contains(err, renter.ErrProjectTimedOut) {
+		WriteError(w, Error{fmt.Sprintf("failed to fetch publink: %v", err)}, http.StatusGatewayTimeout)
+		return
+	} else if errors.Contains(err, renter.ErrPublinkBlacklisted) {
+		WriteError(w, Error{fmt.Sprintf("failed to fetch publink: %v", err)}, http.StatusUnavailableForLegalReasons)
+		return
 	} else if err != nil {
 		WriteError(w, Error{fmt.Sprintf("failed to fetch publink: %v", err)}, http.StatusInternalServerError)
 		return
@@ -340,8 +502,11 @@ func (api *API) skynetPublinkHandlerGET(w http.ResponseWriter, req *http.Request
 		}
 	}
 
+	// servedMetadata is the metadata of the content that is served, relative
+	// to the streamer. It is used to sniff the content type if the metadata
+	// lacks one, the metadata that is returned to the user remains unchanged.
 	var isSubfile bool
-	responseContentType := metadata.ContentType()
+	servedMetadata := metadata
 
 	// Serve the contents of the file at the default path if one is set. Note
 	// that we return the metadata for the entire publink when we serve the
@@ -380,7 +545,7 @@ func (api *API) skynetPublinkHandlerGET(w http.ResponseWriter, req *http.Request
 			return
 		}
 		isSubfile = isFile
-		responseContentType = metaForPath.ContentType()
+		servedMetadata = metaForPath
 	}
 
 	// Serve the contents of the pubfile at path if one is set
@@ -398,6 +563,18 @@ func (api *API) skynetPublinkHandlerGET(w http.ResponseWriter, req *http.Request
 
 		metadata = metadataForPath
 		isSubfile = file
+		servedMetadata = metadataForPath
+	}
+
+	// Sniff the content type of the served file if it lacks one.
+	responseContentType := servedMetadata.ContentType()
+	if format == modules.SkyfileFormatNotSpecified && responseContentType == "" && len(servedMetadata.Subfiles) == 1 {
+		responseContentType = sniffContentTypes(servedMetadata, streamer).ContentType()
+		_, err = streamer.Seek(0, io.SeekStart)
+		if err != nil {
+			WriteError(w, Error{fmt.Sprintf("failed to seek to the start of the pubfile: %v", err)}, http.StatusInternalServerError)
+			return
+		}
 	}
 
 	// If we are serving more than one file, and the format is not
@@ -444,6 +621,17 @@ func (api *API) skynetPublinkHandlerGET(w http.ResponseWriter, req *http.Request
 		skynetPerformanceStats.DownloadLarge.AddRequest(time.Since(startTime))
 	}()
 
+	// Serve the directory index of the path if requested.
+	if format == modules.SkyfileFormatIndex {
+		if isSubfile {
+			WriteError(w, Error{fmt.Sprintf("format 'index' requires a directory, %v is a file", path)}, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Pubaccess-File-Metadata", string(encMetadata))
+		serveDirectoryIndex(w, req, newSkynetDirectoryIndex(publink, path, sniffContentTypes(servedMetadata, streamer)))
+		return
+	}
+
 	// Set an appropriate Content-Disposition header
 	var cdh string
 	filename := filepath.Base(metadata.Filename)
@@ -496,6 +684,25 @@ func (api *API) skynetPublinkHandlerGET(w http.ResponseWriter, req *http.Request
 	}
 	w.Header().Set("Pubaccess-File-Metadata", string(encMetadata))
 
+	// http.ServeContent serves the ranges of a multi-range request one after
+	// another, prefetch all of them so they are fetched in parallel.
+	if ps, ok := streamer.(modules.PrefetchStreamer); ok {
+		size, err := streamer.Seek(0, io.SeekEnd)
+		if err == nil {
+			_, err = streamer.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			WriteError(w, Error{fmt.Sprintf("failed to determine size of the pubfile: %v", err)}, http.StatusInternalServerError)
+			return
+		}
+		ranges := parseRangeHeader(req.Header.Get("Range"), uint64(size))
+		if len(ranges) > 1 {
+			for _, rng := range ranges {
+				ps.Prefetch(rng.start, rng.length)
+			}
+		}
+	}
+
 	http.ServeContent(w, req, metadata.Filename, time.Time{}, streamer)
 }
 
@@ -627,6 +834,53 @@ func (api *API) skynetPublinkPinHandlerPOST(w http.ResponseWriter, req *http.Req
 	WriteSuccess(w)
 }
 
+// skynetPublinkUnpinHandlerPOST handles the API call to unpin a publink by
+// deleting the siafiles which store its data.
+func (api *API) skynetPublinkUnpinHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	strLink := ps.ByName("publink")
+	var publink modules.Publink
+	err := publink.LoadString(strLink)
+	if err != nil {
+		WriteError(w, Error{fmt.Sprintf("error parsing publink: %v", err)}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the timeout, which limits the resolution of v2 publinks.
+	timeout, err := parseTimeout(req.URL.Query())
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the force flag, which allows unpinning the pubfiles of a batch
+	// upload that share the base sector of the publink.
+	var force bool
+	if strForce := req.URL.Query().Get("force"); strForce != "" {
+		force, err = strconv.ParseBool(strForce)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'force' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	siaPaths, freed, err := api.renter.UnpinPublink(publink, force, timeout)
+	if errors.Contains(err, renter.ErrPublinkNotPinned) {
+		WriteError(w, Error{fmt.Sprintf("Failed to unpin publink: %v", err)}, http.StatusNotFound)
+		return
+	} else if errors.Contains(err, renter.ErrPublinkSectorShared) {
+		WriteError(w, Error{fmt.Sprintf("Failed to unpin publink: %v", err)}, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		WriteError(w, Error{fmt.Sprintf("Failed to unpin publink: %v", err)}, http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSON(w, SkynetUnpinPOST{
+		FreedBytes: freed,
+		SiaPaths:   siaPaths,
+	})
+}
+
 // skynetSkyfileHandlerPOST is a dual purpose endpoint. If the 'convertpath'
 // field is set, this endpoint will create a pubfile using an existing siafile.
 // The original siafile and the pubfile will both need to be kept in order for
@@ -727,12 +981,32 @@ func (api *API) skynetSkyfileHandlerPOST(w http.ResponseWriter, req *http.Reques
 	// Parse the filename from the query params.
 	filename := queryForm.Get("filename")
 
+	// Parse the length of a resumable upload. Resumable uploads are created
+	// without any data, the data is appended using /pubaccess/uploads.
+	var uploadLength uint64
+	uploadLengthStr := queryForm.Get("uploadlength")
+	resumable := uploadLengthStr != ""
+	if resumable {
+		uploadLength, err = strconv.ParseUint(uploadLengthStr, 10, 64)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'uploadlength' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if dryRun {
+			WriteError(w, Error{"'dryrun' can not be combined with a resumable upload"}, http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Parse Content-Type from the request headers
-	ct := req.Header.Get("Content-Type")
-	mediaType, _, err := mime.ParseMediaType(ct)
-	if err != nil {
-		WriteError(w, Error{fmt.Sprintf("failed parsing Content-Type header: %v", err)}, http.StatusBadRequest)
-		return
+	var mediaType string
+	if !resumable {
+		ct := req.Header.Get("Content-Type")
+		mediaType, _, err = mime.ParseMediaType(ct)
+		if err != nil {
+			WriteError(w, Error{fmt.Sprintf("failed parsing Content-Type header: %v", err)}, http.StatusBadRequest)
+			return
+		}
 	}
 
 	// Build the upload parameters
@@ -743,13 +1017,46 @@ func (api *API) skynetSkyfileHandlerPOST(w http.ResponseWriter, req *http.Reques
 		BaseChunkRedundancy: redundancy,
 	}
 
-	// Build the Pubfile metadata from the request
-	if isMultipartRequest(mediaType) {
-		subfiles, reader, err := skyfileParseMultiPartRequest(req)
+	// Parse whether an archive should be expanded into a multi-file pubfile.
+	// Archives are uploaded as a single file by default.
+	var extract bool
+	if extractStr := queryForm.Get("extract"); extractStr != "" {
+		extract, err = strconv.ParseBool(extractStr)
 		if err != nil {
-			WriteError(w, Error{fmt.Sprintf("failed parsing multipart request: %v", err)}, http.StatusBadRequest)
+			WriteError(w, Error{"unable to parse 'extract' parameter: " + err.Error()}, http.StatusBadRequest)
 			return
 		}
+	}
+	archiveFormat, isArchive := skyfileArchiveFormat(mediaType)
+	if extract && !isArchive {
+		WriteError(w, Error{"'extract' requires a tar, tar.gz or zip Content-Type"}, http.StatusBadRequest)
+		return
+	}
+
+	// Build the Pubfile metadata from the request. Multipart requests and
+	// extracted archives are uploaded as multi-file pubfiles.
+	if isMultipartRequest(mediaType) || extract {
+		var subfiles modules.SkyfileSubfiles
+		var reader io.Reader
+		if extract {
+			var spool *os.File
+			subfiles, spool, err = skyfileParseArchiveRequest(req.Body, archiveFormat, api.renter.SkyfileSpoolDir())
+			if err != nil {
+				WriteError(w, Error{fmt.Sprintf("failed parsing archive request: %v", err)}, http.StatusBadRequest)
+				return
+			}
+			defer func() {
+				// The upload has already succeeded or failed at this point.
+				_ = removeArchiveSpool(spool)
+			}()
+			reader = spool
+		} else {
+			subfiles, reader, err = skyfileParseMultiPartRequest(req)
+			if err != nil {
+				WriteError(w, Error{fmt.Sprintf("failed parsing multipart request: %v", err)}, http.StatusBadRequest)
+				return
+			}
+		}
 
 		// Use the filename of the first subfile if it's not passed as query
 		// string parameter and there's only one subfile.
@@ -820,6 +1127,10 @@ func (api *API) skynetSkyfileHandlerPOST(w http.ResponseWriter, req *http.Reques
 		WriteError(w, Error{"cannot set both a convertpath and a filename"}, http.StatusBadRequest)
 		return
 	}
+	if convertPathStr != "" && resumable {
+		WriteError(w, Error{"cannot set both a convertpath and an uploadlength"}, http.StatusBadRequest)
+		return
+	}
 
 	// Check whether this is a streaming upload or a siafile conversion. If no
 	// convert path is provided, assume that the req.Body will be used as a
@@ -845,6 +1156,21 @@ func (api *API) skynetSkyfileHandlerPOST(w http.ResponseWriter, req *http.Reques
 			}
 		}
 
+		// Resumable uploads return the status of the created upload.
+		if resumable {
+			status, err := api.renter.CreateResumableSkyfileUpload(lup, uploadLength)
+			if errors.Contains(err, renter.ErrResumableUploadsLimit) {
+				WriteError(w, Error{fmt.Sprintf("failed to create resumable upload: %v", err)}, http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				WriteError(w, Error{fmt.Sprintf("failed to create resumable upload: %v", err)}, http.StatusBadRequest)
+				return
+			}
+			WriteJSON(w, status)
+			return
+		}
+
 		publink, err := api.renter.UploadSkyfile(lup)
 		if err != nil {
 			WriteError(w, Error{fmt.Sprintf("failed to upload file to Pubaccess: %v", err)}, http.StatusBadRequest)
@@ -902,6 +1228,200 @@ func (api *API) skynetSkyfileHandlerPOST(w http.ResponseWriter, req *http.Reques
 	})
 }
 
+// skynetSkyfileBatchHandlerPOST handles the API call to
+// /pubaccess/pubfile/batch. It packs all files of a multipart upload into
+// shared sectors and returns one publink per file.
+func (api *API) skynetSkyfileBatchHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse whether the upload should be performed as a dry-run.
+	var dryRun bool
+	if dryRunStr := queryForm.Get("dryrun"); dryRunStr != "" {
+		dryRun, err = strconv.ParseBool(dryRunStr)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'dryrun' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Parse whether the siapath should be from root or from the pubaccess folder.
+	var root bool
+	if rootStr := queryForm.Get("root"); rootStr != "" {
+		root, err = strconv.ParseBool(rootStr)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'root' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Parse out the siapath of the directory holding the packed sectors.
+	siaPathStr := queryForm.Get("siapath")
+	if siaPathStr == "" || siaPathStr == "/" {
+		WriteError(w, Error{"a siapath needs to be provided for batch uploads"}, http.StatusBadRequest)
+		return
+	}
+	var siaPath modules.SiaPath
+	if root {
+		siaPath, err = modules.NewSiaPath(siaPathStr)
+	} else {
+		siaPath, err = modules.SkynetFolder.Join(siaPathStr)
+	}
+	if err != nil {
+		WriteError(w, Error{"invalid siapath provided: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Check whether existing files should be overwritten.
+	force := false
+	if strForce := queryForm.Get("force"); strForce != "" {
+		force, err = strconv.ParseBool(strForce)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'force' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if force {
+		disableForce, _ := strconv.ParseBool(req.Header.Get("Pubaccess-Disable-Force"))
+		if disableForce {
+			WriteError(w, Error{"'force' has been disabled on this node"}, http.StatusBadRequest)
+			return
+		}
+		if dryRun {
+			WriteError(w, Error{"'dryRun' and 'force' can not be combined"}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Check whether the redundancy has been set.
+	redundancy := uint8(0)
+	if rStr := queryForm.Get("basechunkredundancy"); rStr != "" {
+		if _, err := fmt.Sscan(rStr, &redundancy); err != nil {
+			WriteError(w, Error{"unable to parse basechunkredundancy: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Batch uploads are always multipart uploads.
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		WriteError(w, Error{fmt.Sprintf("failed parsing Content-Type header: %v", err)}, http.StatusBadRequest)
+		return
+	}
+	if !isMultipartRequest(mediaType) {
+		WriteError(w, Error{"batch uploads need to be multipart form uploads"}, http.StatusBadRequest)
+		return
+	}
+	files, err := skyfileParseBatchRequest(req)
+	if err != nil {
+		WriteError(w, Error{fmt.Sprintf("failed parsing multipart request: %v", err)}, http.StatusBadRequest)
+		return
+	}
+
+	bup := modules.PubfileBatchUploadParameters{
+		SiaPath:             siaPath,
+		DryRun:              dryRun,
+		Force:               force,
+		BaseChunkRedundancy: redundancy,
+		Files:               files,
+	}
+	publinks, err := api.renter.UploadSkyfileBatch(bup)
+	if err != nil {
+		WriteError(w, Error{fmt.Sprintf("failed to upload batch to Pubaccess: %v", err)}, http.StatusBadRequest)
+		return
+	}
+
+	var resp SkynetSkyfileBatchHandlerPOST
+	for i, publink := range publinks {
+		resp.Pubfiles = append(resp.Pubfiles, SkynetSkyfileBatchFile{
+			Filename: files[i].Metadata.Filename,
+			SkynetSkyfileHandlerPOST: SkynetSkyfileHandlerPOST{
+				Publink:    publink.String(),
+				MerkleRoot: publink.MerkleRoot(),
+				Bitfield:   publink.Bitfield(),
+			},
+		})
+	}
+	WriteJSON(w, resp)
+}
+
+// skynetUploadsHandlerGET handles the API call to list the unfinished
+// resumable uploads.
+func (api *API) skynetUploadsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	uploads, err := api.renter.ResumableSkyfileUploads()
+	if err != nil {
+		WriteError(w, Error{"unable to get the resumable uploads: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, SkynetUploadsGET{
+		Uploads: uploads,
+	})
+}
+
+// skynetUploadHandlerGET handles the API call to get the progress of a
+// resumable upload.
+func (api *API) skynetUploadHandlerGET(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	status, err := api.renter.ResumableSkyfileUpload(ps.ByName("uploadid"))
+	if errors.Contains(err, renter.ErrUnknownResumableUpload) {
+		WriteError(w, Error{err.Error()}, http.StatusNotFound)
+		return
+	} else if err != nil {
+		WriteError(w, Error{"unable to get the resumable upload: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, status)
+}
+
+// skynetUploadHandlerPOST handles the API call to append the request body to
+// a resumable upload. The 'offset' has to match the number of bytes that were
+// uploaded. Once all the data was received, the pubfile is uploaded and the
+// returned status contains its publink.
+func (api *API) skynetUploadHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	// The offset is read from the query string since the body contains the
+	// data.
+	offsetStr := req.URL.Query().Get("offset")
+	if offsetStr == "" {
+		WriteError(w, Error{"'offset' parameter is required"}, http.StatusBadRequest)
+		return
+	}
+	offset, err := strconv.ParseUint(offsetStr, 10, 64)
+	if err != nil {
+		WriteError(w, Error{"unable to parse 'offset' parameter: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	status, err := api.renter.AppendResumableSkyfileUpload(ps.ByName("uploadid"), offset, req.Body)
+	if errors.Contains(err, renter.ErrUnknownResumableUpload) {
+		WriteError(w, Error{err.Error()}, http.StatusNotFound)
+		return
+	} else if errors.Contains(err, renter.ErrResumableUploadOffset) {
+		WriteError(w, Error{err.Error()}, http.StatusConflict)
+		return
+	} else if err != nil {
+		WriteError(w, Error{"failed to append to resumable upload: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, status)
+}
+
+// skynetUploadAbortHandlerPOST handles the API call to abort a resumable
+// upload.
+func (api *API) skynetUploadAbortHandlerPOST(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	err := api.renter.AbortResumableSkyfileUpload(ps.ByName("uploadid"))
+	if errors.Contains(err, renter.ErrUnknownResumableUpload) {
+		WriteError(w, Error{err.Error()}, http.StatusNotFound)
+		return
+	} else if err != nil {
+		WriteError(w, Error{"unable to abort the resumable upload: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // skynetStatsHandlerGET responds with a JSON with statistical data about
 // pubaccess, e.g. number of files uploaded, total size, etc.
 func (api *API) skynetStatsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
@@ -1005,6 +1525,12 @@ func serveTar(dst io.Writer, src io.Reader, files []modules.PubfileSubfileMetada
 		}
 		// Modify name to match path within pubfile.
 		header.Name = file.Filename
+		// Store the content type so the archive can be uploaded as the same
+		// pubfile.
+		if file.ContentType != "" {
+			header.Format = tar.FormatPAX
+			header.PAXRecords = map[string]string{paxContentTypeKey: file.ContentType}
+		}
 		// Write header.
 		if err := tw.WriteHeader(header); err != nil {
 			return err
@@ -1022,7 +1548,15 @@ func serveTar(dst io.Writer, src io.Reader, files []modules.PubfileSubfileMetada
 func serveZip(dst io.Writer, src io.Reader, files []modules.PubfileSubfileMetadata) error {
 	zw := zip.NewWriter(dst)
 	for _, file := range files {
-		f, err := zw.Create(file.Filename)
+		// The content type is stored in the comment of the file so the archive
+		// can be uploaded as the same pubfile.
+		header := &zip.FileHeader{
+			Name:    file.Filename,
+			Method:  zip.Deflate,
+			Comment: file.ContentType,
+		}
+		header.SetMode(file.Mode())
+		f, err := zw.CreateHeader(header)
 		if err != nil {
 			return errors.AddContext(err, "serveZip: failed to add the file to the zip")
 		}
@@ -1114,6 +1648,58 @@ func skyfileParseMultiPartRequest(req *http.Request) (modules.SkyfileSubfiles, i
 	return subfiles, io.MultiReader(readers...), nil
 }
 
+// skyfileParseBatchRequest parses the files of a multipart batch upload. Every
+// file becomes a separate pubfile.
+func skyfileParseBatchRequest(req *http.Request) ([]modules.PubfileBatchFile, error) {
+	err := req.ParseMultipartForm(32 << 20) // 32MB max memory
+	if err != nil {
+		return nil, errors.AddContext(err, "failed parsing multipart form")
+	}
+	mpfHeaders := append(req.MultipartForm.File["file"], req.MultipartForm.File["files[]"]...)
+	if len(mpfHeaders) == 0 {
+		return nil, errors.New("could not find multipart file")
+	}
+
+	files := make([]modules.PubfileBatchFile, 0, len(mpfHeaders))
+	for _, fh := range mpfHeaders {
+		if fh.Filename == "" {
+			return nil, errors.New("no filename provided")
+		}
+		if err := modules.ValidatePathString(fh.Filename, false); err != nil {
+			return nil, errors.AddContext(err, "invalid filename provided")
+		}
+		if uint64(fh.Size) > modules.SectorSize {
+			return nil, fmt.Errorf("file %v is too large for a batch upload", fh.Filename)
+		}
+
+		var mode os.FileMode
+		if modeStr := fh.Header.Get("Mode"); modeStr != "" {
+			_, err := fmt.Sscanf(modeStr, "%o", &mode)
+			if err != nil {
+				return nil, errors.AddContext(err, "failed to parse file mode")
+			}
+		}
+
+		f, err := fh.Open()
+		if err != nil {
+			return nil, errors.AddContext(err, "could not open multipart file")
+		}
+		data, err := ioutil.ReadAll(f)
+		err = errors.Compose(err, f.Close())
+		if err != nil {
+			return nil, errors.AddContext(err, "could not read multipart file")
+		}
+		files = append(files, modules.PubfileBatchFile{
+			Metadata: modules.PubfileMetadata{
+				Filename: fh.Filename,
+				Mode:     mode,
+			},
+			Data: data,
+		})
+	}
+	return files, nil
+}
+
 // skykeyHandlerGET handles the API call to get a Pubaccesskey and its ID using its
 // name or ID.
 func (api *API) skykeyHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
@@ -1222,7 +1808,19 @@ func (api *API) skykeyCreateKeyHandlerPOST(w http.ResponseWriter, req *http.Requ
 		return
 	}
 
-	sk, err := api.renter.CreateSkykey(name, skykeyType)
+	// Password pubaccesskeys can't be generated, they are derived from the
+	// provided password.
+	var sk pubaccesskey.Pubaccesskey
+	if skykeyType == pubaccesskey.TypePassword {
+		sk, err = pubaccesskey.NewPasswordSkykey(name, req.FormValue("password"))
+		if err != nil {
+			WriteError(w, Error{"invalid password pubaccesskey: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		err = api.renter.AddSkykey(sk)
+	} else {
+		sk, err = api.renter.CreateSkykey(name, skykeyType)
+	}
 	if err != nil {
 		WriteError(w, Error{"failed to create pubaccesskey" + err.Error()}, http.StatusInternalServerError)
 		return
@@ -1295,6 +1893,154 @@ func (api *API) skykeysHandlerGET(w http.ResponseWriter, _ *http.Request, _ http
 	WriteJSON(w, res)
 }
 
+// skykeyRotateHandlerPOST handles the API call to rotate a Pubaccesskey and
+// re-encrypt the given publinks with its successor.
+func (api *API) skykeyRotateHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	err := req.ParseForm()
+	if err != nil {
+		WriteError(w, Error{"failed to parse form: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse Pubaccesskey id and name.
+	name := req.Form.Get("name")
+	idString := req.Form.Get("id")
+	if idString == "" && name == "" {
+		WriteError(w, Error{"you must specify the name or ID of the pubaccesskey"}, http.StatusBadRequest)
+		return
+	}
+	if idString != "" && name != "" {
+		WriteError(w, Error{"you must specify either the name or ID of the pubaccesskey, not both"}, http.StatusBadRequest)
+		return
+	}
+	var id pubaccesskey.PubaccesskeyID
+	if name != "" {
+		sk, err := api.renter.SkykeyByName(name)
+		if err != nil {
+			WriteError(w, Error{"failed to retrieve pubaccesskey: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		id = sk.ID()
+	} else {
+		err = id.FromString(idString)
+		if err != nil {
+			WriteError(w, Error{"Invalid pubaccesskey ID: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Parse the publinks.
+	var publinks []modules.Publink
+	for _, str := range req.Form["publink"] {
+		var publink modules.Publink
+		err = publink.LoadString(str)
+		if err != nil {
+			WriteError(w, Error{fmt.Sprintf("error parsing publink: %v", err)}, http.StatusBadRequest)
+			return
+		}
+		publinks = append(publinks, publink)
+	}
+
+	// Parse whether the siapath should be from root or from the pubaccess folder.
+	var root bool
+	rootStr := req.Form.Get("root")
+	if rootStr != "" {
+		root, err = strconv.ParseBool(rootStr)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'root' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Parse out the directory of the re-encrypted pubfiles.
+	var dir modules.SiaPath
+	siaPathStr := req.Form.Get("siapath")
+	if siaPathStr == "" {
+		dir, err = modules.SkynetFolder.Join(modules.SkykeyRotationDir)
+	} else if root {
+		dir, err = modules.NewSiaPath(siaPathStr)
+	} else {
+		dir, err = modules.SkynetFolder.Join(siaPathStr)
+	}
+	if err != nil {
+		WriteError(w, Error{"invalid siapath provided: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the timeout.
+	timeout, err := parseTimeout(req.Form)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	rotation, err := api.renter.RotateSkykey(id, req.Form.Get("successorname"), publinks, dir, timeout)
+	if errors.Contains(err, renter.ErrPublinkNotEncryptedWithSkykey) {
+		WriteError(w, Error{"failed to rotate pubaccesskey: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		WriteError(w, Error{"failed to rotate pubaccesskey: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	res, err := api.skykeyRotationGET(rotation)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, res)
+}
+
+// skykeyRotationsHandlerGET handles the API call to get the rotations of the
+// renter's pubaccesskeys.
+func (api *API) skykeyRotationsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	rotations, err := api.renter.SkykeyRotations()
+	if err != nil {
+		WriteError(w, Error{"Unable to get pubaccesskey rotations: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	res := SkykeyRotationsGET{
+		Rotations: make([]SkykeyRotationGET, 0, len(rotations)),
+	}
+	for _, rotation := range rotations {
+		rotationGET, err := api.skykeyRotationGET(rotation)
+		if err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		res.Rotations = append(res.Rotations, rotationGET)
+	}
+	WriteJSON(w, res)
+}
+
+// skykeyRotationGET converts a rotation into its API representation, which
+// includes the successor Pubaccesskey.
+func (api *API) skykeyRotationGET(rotation modules.PubaccesskeyRotation) (SkykeyRotationGET, error) {
+	successor, err := api.renter.SkykeyByID(rotation.SuccessorID)
+	if err != nil {
+		return SkykeyRotationGET{}, errors.AddContext(err, "failed to retrieve successor pubaccesskey")
+	}
+	skStr, err := successor.ToString()
+	if err != nil {
+		return SkykeyRotationGET{}, errors.AddContext(err, "failed to write pubaccesskey string")
+	}
+	publinks := rotation.Publinks
+	if publinks == nil {
+		publinks = []modules.RotatedPublink{}
+	}
+	return SkykeyRotationGET{
+		KeyID: rotation.KeyID.ToString(),
+		Successor: SkykeyGET{
+			Pubaccesskey: skStr,
+			Name:         successor.Name,
+			ID:           successor.ID().ToString(),
+			Type:         successor.Type.ToString(),
+		},
+		CreatedAt: rotation.CreatedAt,
+		Publinks:  publinks,
+	}, nil
+}
+
 // defaultPath extracts the defaultPath from the request or returns a default.
 // It will never return a directory because `subfiles` contains only files.
 func defaultPath(queryForm url.Values, subfiles modules.SkyfileSubfiles) (defaultPath string, disableDefaultPath bool, err error) {
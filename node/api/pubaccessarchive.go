@@ -0,0 +1,232 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"github.com/EvilRedHorse/pubaccess-node/build"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+)
+
+// paxContentTypeKey is the key of the PAX record which stores the content type
+// of a subfile in a tar archive.
+const paxContentTypeKey = "PUBACCESS.contenttype"
+
+var (
+	// maxArchiveFiles is the maximum number of files that are extracted from
+	// an archive upload.
+	maxArchiveFiles = build.Select(build.Var{
+		Dev:      1000,
+		Standard: 10000,
+		Testing:  10,
+	}).(int)
+
+	// maxArchiveSize is the maximum number of bytes that are extracted from
+	// an archive upload. It also limits the size of zip archives, which are
+	// spooled before they are extracted.
+	maxArchiveSize = build.Select(build.Var{
+		Dev:      uint64(1 << 30), // 1 GiB
+		Standard: uint64(1 << 34), // 16 GiB
+		Testing:  uint64(1 << 16), // 64 KiB
+	}).(uint64)
+)
+
+var (
+	// errArchiveTooManyFiles is returned if an archive upload contains more
+	// than maxArchiveFiles files.
+	errArchiveTooManyFiles = errors.New("archive contains too many files")
+
+	// errArchiveTooLarge is returned if an archive upload exceeds
+	// maxArchiveSize.
+	errArchiveTooLarge = errors.New("archive exceeds the maximum size")
+)
+
+// archiveSpool collects the files of an archive upload. The data of the files
+// is written to a temporary file one after another, which turns the archive
+// into the data of a multi-file pubfile.
+type archiveSpool struct {
+	file     *os.File
+	offset   uint64
+	subfiles modules.SkyfileSubfiles
+}
+
+// skyfileArchiveFormat returns the archive format of an upload with the given
+// media type, and false if the media type is not an archive.
+func skyfileArchiveFormat(mediaType string) (modules.PubfileFormat, bool) {
+	switch mediaType {
+	case "application/x-tar":
+		return modules.SkyfileFormatTar, true
+	case "application/gzip", "application/x-gzip", "application/x-compressed-tar":
+		return modules.SkyfileFormatTarGz, true
+	case "application/zip", "application/x-zip-compressed":
+		return modules.SkyfileFormatZip, true
+	default:
+		return modules.SkyfileFormatNotSpecified, false
+	}
+}
+
+// skyfileParseArchiveRequest expands a tar, tar.gz or zip archive into the
+// subfiles of a pubfile. The data of the subfiles is spooled to a file in the
+// given directory, which has to be closed and removed by the caller using
+// removeArchiveSpool.
+func skyfileParseArchiveRequest(body io.Reader, format modules.PubfileFormat, dir string) (_ modules.SkyfileSubfiles, _ *os.File, err error) {
+	file, err := ioutil.TempFile(dir, "archive-")
+	if err != nil {
+		return nil, nil, errors.AddContext(err, "unable to create temporary file")
+	}
+	spool := &archiveSpool{
+		file:     file,
+		subfiles: make(modules.SkyfileSubfiles),
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Compose(err, removeArchiveSpool(file))
+		}
+	}()
+
+	switch format {
+	case modules.SkyfileFormatTar:
+		err = spool.addTar(body)
+	case modules.SkyfileFormatTarGz:
+		var gzr *gzip.Reader
+		gzr, err = gzip.NewReader(body)
+		if err != nil {
+			return nil, nil, errors.AddContext(err, "unable to open gzip archive")
+		}
+		err = errors.Compose(spool.addTar(gzr), gzr.Close())
+	case modules.SkyfileFormatZip:
+		err = spool.addZip(body, dir)
+	default:
+		err = errors.New("unsupported archive format")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(spool.subfiles) == 0 {
+		return nil, nil, errors.New("archive does not contain any files")
+	}
+
+	// Rewind the file so it can be read from the start.
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, nil, errors.AddContext(err, "unable to seek to the start of the temporary file")
+	}
+	return spool.subfiles, file, nil
+}
+
+// removeArchiveSpool closes and removes the temporary file of an archive
+// upload.
+func removeArchiveSpool(file *os.File) error {
+	return errors.Compose(file.Close(), os.Remove(file.Name()))
+}
+
+// addFile adds a file of the archive to the spool. Paths that are absolute or
+// point outside of the archive are rejected.
+func (as *archiveSpool) addFile(name string, mode os.FileMode, contentType string, r io.Reader) error {
+	cleanName := path.Clean(name)
+	if path.IsAbs(cleanName) || cleanName == "." || cleanName == ".." || strings.HasPrefix(cleanName, "../") {
+		return errors.New("archive contains invalid path " + name)
+	}
+	name = cleanName
+	if _, exists := as.subfiles[name]; exists {
+		return errors.New("archive contains duplicate file " + name)
+	}
+	if len(as.subfiles) >= maxArchiveFiles {
+		return errArchiveTooManyFiles
+	}
+	// Read at most one byte more than the remaining size to detect archives
+	// that exceed it.
+	remaining := maxArchiveSize - as.offset
+	n, err := io.Copy(as.file, io.LimitReader(r, int64(remaining+1)))
+	if err != nil {
+		return errors.AddContext(err, "unable to extract "+name)
+	}
+	if uint64(n) > remaining {
+		return errArchiveTooLarge
+	}
+	as.subfiles[name] = modules.PubfileSubfileMetadata{
+		FileMode:    mode,
+		Filename:    name,
+		ContentType: contentType,
+		Offset:      as.offset,
+		Len:         uint64(n),
+	}
+	as.offset += uint64(n)
+	return nil
+}
+
+// addTar adds the files of a tar archive to the spool.
+func (as *archiveSpool) addTar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.AddContext(err, "unable to read tar archive")
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg, tar.TypeRegA:
+		default:
+			return errors.New("tar archive contains unsupported file type at " + header.Name)
+		}
+		err = as.addFile(header.Name, header.FileInfo().Mode(), header.PAXRecords[paxContentTypeKey], tr)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// addZip adds the files of a zip archive to the spool. Since the index of a
+// zip archive is located at its end, the archive is written to a temporary
+// file in the given directory first.
+func (as *archiveSpool) addZip(r io.Reader, dir string) (err error) {
+	archive, err := ioutil.TempFile(dir, "zip-")
+	if err != nil {
+		return errors.AddContext(err, "unable to create temporary file")
+	}
+	defer func() {
+		err = errors.Compose(err, removeArchiveSpool(archive))
+	}()
+	size, err := io.Copy(archive, io.LimitReader(r, int64(maxArchiveSize+1)))
+	if err != nil {
+		return errors.AddContext(err, "unable to read zip archive")
+	}
+	if uint64(size) > maxArchiveSize {
+		return errArchiveTooLarge
+	}
+	zr, err := zip.NewReader(archive, size)
+	if err != nil {
+		return errors.AddContext(err, "unable to open zip archive")
+	}
+
+	for _, f := range zr.File {
+		mode := f.Mode()
+		if mode.IsDir() {
+			continue
+		}
+		if !mode.IsRegular() {
+			return errors.New("zip archive contains unsupported file type at " + f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return errors.AddContext(err, "unable to open "+f.Name)
+		}
+		err = errors.Compose(as.addFile(f.Name, mode, f.Comment, rc), rc.Close())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"github.com/EvilRedHorse/pubaccess-node/build"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+)
+
+// newArchiveTestDir creates the spool directory of an archive test.
+func newArchiveTestDir(t *testing.T) string {
+	dir := build.TempDir("api", t.Name())
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// testArchiveFile is a file of an archive created by newTestArchive.
+type testArchiveFile struct {
+	name string
+	data []byte
+}
+
+// newTestArchive creates a tar or zip archive of the files.
+func newTestArchive(t *testing.T, format modules.PubfileFormat, files []testArchiveFile) []byte {
+	buf := new(bytes.Buffer)
+	switch format {
+	case modules.SkyfileFormatTar:
+		tw := tar.NewWriter(buf)
+		for _, f := range files {
+			err := tw.WriteHeader(&tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(f.data))})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tw.Write(f.data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	case modules.SkyfileFormatZip:
+		zw := zip.NewWriter(buf)
+		for _, f := range files {
+			w, err := zw.Create(f.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write(f.data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		t.Fatal("unsupported test archive format", format)
+	}
+	return buf.Bytes()
+}
+
+// TestSkyfileParseArchiveRequest verifies that archives served by the
+// /pubaccess/publink endpoint are expanded into the same subfiles and data.
+func TestSkyfileParseArchiveRequest(t *testing.T) {
+	dir := newArchiveTestDir(t)
+	data := fastrand.Bytes(300)
+	md := modules.PubfileMetadata{
+		Filename: "folder",
+		Subfiles: modules.SkyfileSubfiles{
+			"index.html":   {FileMode: 0640, Filename: "index.html", ContentType: "text/html", Offset: 0, Len: 100},
+			"dir/file.bin": {FileMode: 0600, Filename: "dir/file.bin", Offset: 100, Len: 150},
+			"dir/empty":    {FileMode: 0644, Filename: "dir/empty", ContentType: "text/plain", Offset: 300, Len: 0},
+			"z.json":       {FileMode: 0400, Filename: "z.json", ContentType: "application/json", Offset: 250, Len: 50},
+		},
+	}
+
+	// archive creates an archive of the metadata and data in the given format.
+	archive := func(format modules.PubfileFormat) []byte {
+		buf := new(bytes.Buffer)
+		var err error
+		switch format {
+		case modules.SkyfileFormatTar:
+			err = serveArchive(buf, bytes.NewReader(data), md, serveTar)
+		case modules.SkyfileFormatTarGz:
+			gzw := gzip.NewWriter(buf)
+			err = serveArchive(gzw, bytes.NewReader(data), md, serveTar)
+			if err == nil {
+				err = gzw.Close()
+			}
+		case modules.SkyfileFormatZip:
+			err = serveArchive(buf, bytes.NewReader(data), md, serveZip)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	for _, format := range []modules.PubfileFormat{modules.SkyfileFormatTar, modules.SkyfileFormatTarGz, modules.SkyfileFormatZip} {
+		subfiles, spool, err := skyfileParseArchiveRequest(bytes.NewReader(archive(format)), format, dir)
+		if err != nil {
+			t.Fatal(format, err)
+		}
+		spoolData, err := ioutil.ReadAll(spool)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := removeArchiveSpool(spool); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(subfiles, md.Subfiles) {
+			t.Fatalf("%v: subfiles don't match\n%v\n%v", format, subfiles, md.Subfiles)
+		}
+		if !bytes.Equal(spoolData, data) {
+			t.Fatalf("%v: data doesn't match", format)
+		}
+	}
+
+	// An archive without files is rejected.
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := skyfileParseArchiveRequest(buf, modules.SkyfileFormatTar, dir)
+	if err == nil || !strings.Contains(err.Error(), "does not contain any files") {
+		t.Fatal("expected empty archive to be rejected but got:", err)
+	}
+
+	// A corrupt archive is rejected.
+	_, _, err = skyfileParseArchiveRequest(bytes.NewReader(fastrand.Bytes(100)), modules.SkyfileFormatZip, dir)
+	if err == nil {
+		t.Fatal("expected corrupt archive to be rejected")
+	}
+}
+
+// TestSkyfileParseArchiveRequestInvalid verifies that archives with paths
+// outside of the archive, too many files or too much data are rejected without
+// leaving any spooled data behind.
+func TestSkyfileParseArchiveRequestInvalid(t *testing.T) {
+	dir := newArchiveTestDir(t)
+
+	// Create the files of an archive that exceeds the file limit and one that
+	// exceeds the size limit.
+	var tooManyFiles []testArchiveFile
+	for i := 0; i <= maxArchiveFiles; i++ {
+		tooManyFiles = append(tooManyFiles, testArchiveFile{name: fmt.Sprintf("file%v", i), data: []byte{byte(i)}})
+	}
+	tooLarge := []testArchiveFile{
+		{name: "a", data: fastrand.Bytes(int(maxArchiveSize / 2))},
+		{name: "b", data: fastrand.Bytes(int(maxArchiveSize/2) + 1)},
+	}
+
+	tests := []struct {
+		files []testArchiveFile
+		err   string
+	}{
+		{[]testArchiveFile{{name: "ok", data: []byte("ok")}, {name: "../../etc/passwd", data: []byte("x")}}, "invalid path"},
+		{[]testArchiveFile{{name: "dir/../../escape", data: []byte("x")}}, "invalid path"},
+		{[]testArchiveFile{{name: "/etc/passwd", data: []byte("x")}}, "invalid path"},
+		{[]testArchiveFile{{name: "..", data: []byte("x")}}, "invalid path"},
+		{[]testArchiveFile{{name: "a/./b", data: []byte("x")}, {name: "a/b", data: []byte("y")}}, "duplicate file"},
+		{tooManyFiles, errArchiveTooManyFiles.Error()},
+		{tooLarge, errArchiveTooLarge.Error()},
+	}
+	for _, format := range []modules.PubfileFormat{modules.SkyfileFormatTar, modules.SkyfileFormatZip} {
+		for i, test := range tests {
+			archive := newTestArchive(t, format, test.files)
+			_, _, err := skyfileParseArchiveRequest(bytes.NewReader(archive), format, dir)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("%v %v: expected error '%v' but got: %v", format, i, test.err, err)
+			}
+		}
+	}
+
+	// The spool directory should be empty.
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 0 {
+		t.Fatalf("expected empty spool directory, found %v files", len(fis))
+	}
+
+	// Paths are cleaned.
+	archive := newTestArchive(t, modules.SkyfileFormatTar, []testArchiveFile{{name: "./dir//a/../file", data: []byte("x")}})
+	subfiles, spool, err := skyfileParseArchiveRequest(bytes.NewReader(archive), modules.SkyfileFormatTar, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := removeArchiveSpool(spool); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := subfiles["dir/file"]; !exists || len(subfiles) != 1 {
+		t.Fatal("path wasn't cleaned", subfiles)
+	}
+}
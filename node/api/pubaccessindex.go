@@ -0,0 +1,191 @@
+package api
+
+import (
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+)
+
+const (
+	// maxContentTypeSniffs is the maximum number of subfiles of a pubfile for
+	// which the content type is sniffed from the data. Every sniff requires
+	// data to be fetched, so this bounds the work done for a single request.
+	maxContentTypeSniffs = 32
+
+	// sniffLen is the number of bytes that http.DetectContentType considers.
+	sniffLen = 512
+)
+
+type (
+	// SkynetDirectoryIndex is the directory index of a path within a pubfile
+	// which is returned by the /pubaccess/publink GET endpoint if the format
+	// is set to 'index'.
+	SkynetDirectoryIndex struct {
+		Publink string                 `json:"publink"`
+		Path    string                 `json:"path"`
+		Entries []SkynetDirectoryEntry `json:"entries"`
+	}
+
+	// SkynetDirectoryEntry is a single file or directory within a
+	// SkynetDirectoryIndex.
+	SkynetDirectoryEntry struct {
+		Name        string `json:"name"`
+		Path        string `json:"path"`
+		IsDir       bool   `json:"isdir"`
+		Size        uint64 `json:"size"`
+		ContentType string `json:"contenttype,omitempty"`
+	}
+)
+
+// skynetDirectoryIndexTemplate is the template used to render the HTML version
+// of a SkynetDirectoryIndex.
+var skynetDirectoryIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Index of {{.Path}}</title>
+</head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<table>
+<tr><th>Name</th><th>Size</th><th>Type</th></tr>
+{{- $publink := .Publink}}
+{{- range .Entries}}
+<tr>
+{{- if .IsDir}}
+<td><a href="/pubaccess/publink/{{$publink}}{{.Path}}?format=index">{{.Name}}/</a></td><td>{{.Size}}</td><td>directory</td>
+{{- else}}
+<td><a href="/pubaccess/publink/{{$publink}}{{.Path}}">{{.Name}}</a></td><td>{{.Size}}</td><td>{{.ContentType}}</td>
+{{- end}}
+</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// newSkynetDirectoryIndex builds the directory index of the given path from
+// the metadata of the path. The index contains the files and directories
+// directly within the path, directories are listed first.
+func newSkynetDirectoryIndex(publink modules.Publink, path string, metadata modules.PubfileMetadata) SkynetDirectoryIndex {
+	dir := strings.TrimSuffix(modules.EnsurePrefix(path, "/"), "/")
+	index := SkynetDirectoryIndex{
+		Publink: publink.String(),
+		Path:    dir + "/",
+		Entries: []SkynetDirectoryEntry{},
+	}
+
+	// A pubfile without subfiles consists of a single file, which is served
+	// at the root of the publink.
+	if len(metadata.Subfiles) == 0 && metadata.Filename != "" {
+		index.Entries = append(index.Entries, SkynetDirectoryEntry{
+			Name: filepath.Base(metadata.Filename),
+			Path: "/",
+			Size: metadata.Length,
+		})
+		return index
+	}
+
+	dirs := make(map[string]*SkynetDirectoryEntry)
+	for _, sf := range metadata.Subfiles {
+		name := strings.TrimPrefix(modules.EnsurePrefix(sf.Filename, "/"), dir+"/")
+		// If the subfile is in a subdirectory, only the subdirectory is listed.
+		if i := strings.Index(name, "/"); i >= 0 {
+			name = name[:i]
+			entry, exists := dirs[name]
+			if !exists {
+				entry = &SkynetDirectoryEntry{
+					Name:  name,
+					Path:  dir + "/" + name,
+					IsDir: true,
+				}
+				dirs[name] = entry
+			}
+			entry.Size += sf.Len
+			continue
+		}
+		index.Entries = append(index.Entries, SkynetDirectoryEntry{
+			Name:        name,
+			Path:        dir + "/" + name,
+			Size:        sf.Len,
+			ContentType: sf.ContentType,
+		})
+	}
+	for _, entry := range dirs {
+		index.Entries = append(index.Entries, *entry)
+	}
+
+	sort.Slice(index.Entries, func(i, j int) bool {
+		if index.Entries[i].IsDir != index.Entries[j].IsDir {
+			return index.Entries[i].IsDir
+		}
+		return index.Entries[i].Name < index.Entries[j].Name
+	})
+	return index
+}
+
+// serveDirectoryIndex writes the directory index to the response. The index is
+// rendered as HTML unless the request accepts JSON.
+func serveDirectoryIndex(w http.ResponseWriter, req *http.Request, index SkynetDirectoryIndex) {
+	if strings.Contains(req.Header.Get("Accept"), "application/json") {
+		WriteJSON(w, index)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodHead {
+		return
+	}
+	_ = skynetDirectoryIndexTemplate.Execute(w, index)
+}
+
+// sniffContentTypes returns a copy of the metadata in which the subfiles
+// without a content type have one. The content type is derived from the file
+// extension if possible, otherwise it is sniffed from the first bytes of the
+// subfile's data. Subfiles whose data can't be read keep an empty content
+// type. The streamer has to be seeked before it is read again.
+func sniffContentTypes(metadata modules.PubfileMetadata, streamer modules.Streamer) modules.PubfileMetadata {
+	// The subfiles are copied since the metadata might be shared with other
+	// streams of the same pubfile.
+	subfiles := make(modules.SkyfileSubfiles, len(metadata.Subfiles))
+	sniffs := 0
+	for name, sf := range metadata.Subfiles {
+		if sf.ContentType == "" {
+			sf.ContentType = mime.TypeByExtension(filepath.Ext(sf.Filename))
+		}
+		if sf.ContentType == "" && sniffs < maxContentTypeSniffs {
+			sniffs++
+			contentType, err := sniffContentType(streamer, sf.Offset, sf.Len)
+			if err == nil {
+				sf.ContentType = contentType
+			}
+		}
+		subfiles[name] = sf
+	}
+	metadata.Subfiles = subfiles
+	return metadata
+}
+
+// sniffContentType detects the content type of the data at the given offset
+// of the streamer.
+func sniffContentType(streamer modules.Streamer, offset, length uint64) (string, error) {
+	if length > sniffLen {
+		length = sniffLen
+	}
+	_, err := streamer.Seek(int64(offset), io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, length)
+	_, err = io.ReadFull(streamer, buf)
+	if err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buf), nil
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/EvilRedHorse/pubaccess-node/crypto"
+	"github.com/EvilRedHorse/pubaccess-node/modules"
+)
+
+// TestNewSkynetDirectoryIndex verifies the directory index built from the
+// metadata of a pubfile.
+func TestNewSkynetDirectoryIndex(t *testing.T) {
+	publink, err := modules.NewPublinkV1(crypto.HashObject("index"), 0, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata := modules.PubfileMetadata{
+		Filename: "folder",
+		Subfiles: modules.SkyfileSubfiles{
+			"index.html":     {Filename: "index.html", ContentType: "text/html", Offset: 0, Len: 10},
+			"b.txt":          {Filename: "b.txt", ContentType: "text/plain", Offset: 10, Len: 5},
+			"dir/c.txt":      {Filename: "dir/c.txt", Offset: 15, Len: 3},
+			"dir/sub/d.txt":  {Filename: "dir/sub/d.txt", Offset: 18, Len: 4},
+			"assets/e.css":   {Filename: "assets/e.css", Offset: 22, Len: 1},
+			"dir/sub/f.json": {Filename: "dir/sub/f.json", Offset: 23, Len: 2},
+		},
+	}
+
+	// Check the index of the root.
+	index := newSkynetDirectoryIndex(publink, "/", metadata)
+	expected := SkynetDirectoryIndex{
+		Publink: publink.String(),
+		Path:    "/",
+		Entries: []SkynetDirectoryEntry{
+			{Name: "assets", Path: "/assets", IsDir: true, Size: 1},
+			{Name: "dir", Path: "/dir", IsDir: true, Size: 9},
+			{Name: "b.txt", Path: "/b.txt", Size: 5, ContentType: "text/plain"},
+			{Name: "index.html", Path: "/index.html", Size: 10, ContentType: "text/html"},
+		},
+	}
+	if !reflect.DeepEqual(index, expected) {
+		t.Fatalf("unexpected index\n%+v\n%+v", index, expected)
+	}
+
+	// Check the index of a subdirectory.
+	dirMetadata, _, _, _ := metadata.ForPath("/dir")
+	index = newSkynetDirectoryIndex(publink, "/dir", dirMetadata)
+	expected = SkynetDirectoryIndex{
+		Publink: publink.String(),
+		Path:    "/dir/",
+		Entries: []SkynetDirectoryEntry{
+			{Name: "sub", Path: "/dir/sub", IsDir: true, Size: 6},
+			{Name: "c.txt", Path: "/dir/c.txt", Size: 3},
+		},
+	}
+	if !reflect.DeepEqual(index, expected) {
+		t.Fatalf("unexpected index\n%+v\n%+v", index, expected)
+	}
+
+	// Check the index of a pubfile without subfiles.
+	index = newSkynetDirectoryIndex(publink, "/", modules.PubfileMetadata{Filename: "file", Length: 7})
+	expected = SkynetDirectoryIndex{
+		Publink: publink.String(),
+		Path:    "/",
+		Entries: []SkynetDirectoryEntry{
+			{Name: "file", Path: "/", Size: 7},
+		},
+	}
+	if !reflect.DeepEqual(index, expected) {
+		t.Fatalf("unexpected index\n%+v\n%+v", index, expected)
+	}
+}
+
+// TestSniffContentTypes verifies that the content types of subfiles are
+// derived from their extension or their data.
+func TestSniffContentTypes(t *testing.T) {
+	html := []byte("<html><body>hello</body></html>")
+	png := []byte("\x89PNG\x0D\x0A\x1A\x0A")
+	text := []byte("plain text")
+	data := append(append(append([]byte{}, html...), png...), text...)
+	metadata := modules.PubfileMetadata{
+		Subfiles: modules.SkyfileSubfiles{
+			"page":      {Filename: "page", Offset: 0, Len: uint64(len(html))},
+			"image":     {Filename: "image", Offset: uint64(len(html)), Len: uint64(len(png))},
+			"style.css": {Filename: "style.css", Offset: uint64(len(html) + len(png)), Len: uint64(len(text))},
+			"set":       {Filename: "set", ContentType: "application/custom", Offset: uint64(len(html) + len(png)), Len: uint64(len(text))},
+		},
+	}
+
+	sniffed := sniffContentTypes(metadata, streamerFromSlice(data))
+	expected := map[string]string{
+		"page":      "text/html",
+		"image":     "image/png",
+		"style.css": "text/css",
+		"set":       "application/custom",
+	}
+	for name, contentType := range expected {
+		if !strings.HasPrefix(sniffed.Subfiles[name].ContentType, contentType) {
+			t.Errorf("expected content type %v for %v but got %v", contentType, name, sniffed.Subfiles[name].ContentType)
+		}
+	}
+
+	// The original metadata is unchanged.
+	if metadata.Subfiles["page"].ContentType != "" {
+		t.Fatal("metadata was modified")
+	}
+
+	// Subfiles whose data can't be read keep an empty content type.
+	metadata.Subfiles["page"] = modules.PubfileSubfileMetadata{Filename: "page", Offset: uint64(len(data)), Len: 10}
+	sniffed = sniffContentTypes(metadata, streamerFromSlice(data))
+	if sniffed.Subfiles["page"].ContentType != "" || !strings.HasPrefix(sniffed.Subfiles["image"].ContentType, "image/png") {
+		t.Fatal("unexpected content types", sniffed.Subfiles)
+	}
+}
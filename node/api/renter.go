@@ -876,6 +876,15 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 		}
 		settings.MaxUploadSpeed = uploadSpeed
 	}
+	// Scan the resumable upload limit. (optional parameter)
+	if m := req.FormValue("maxresumableuploadbytes"); m != "" {
+		var maxResumableUploadBytes uint64
+		if _, err := fmt.Sscan(m, &maxResumableUploadBytes); err != nil {
+			WriteError(w, Error{"unable to parse maxresumableuploadbytes: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.MaxResumableUploadBytes = maxResumableUploadBytes
+	}
 
 	// Scan the checkforipviolation flag.
 	// NOTE: checkforipviolation is deprecated, use iprestriction
@@ -59,6 +59,13 @@ func (api *API) buildHTTPRoutes() {
 		router.GET("/explorer/hashes/:hash", api.explorerHashHandler)
 	}
 
+	// Index API Calls
+	if api.index != nil {
+		router.GET("/index", api.indexHandler)
+		router.GET("/index/address/:addr/balance", api.indexAddressBalanceHandler)
+		router.GET("/index/address/:addr/outputs", api.indexAddressOutputsHandler)
+	}
+
 	// Gateway API Calls
 	if api.gateway != nil {
 		router.GET("/gateway", api.gatewayHandlerGET)
@@ -83,11 +90,18 @@ func (api *API) buildHTTPRoutes() {
 		router.GET("/host/contracts", api.hostContractInfoHandler)                                // Get info about contracts.
 		router.GET("/host/contracts/:contractID", api.hostContractGetHandler)                     // Get info about a contract.
 		router.GET("/host/estimatescore", api.hostEstimateScoreGET)
+		router.GET("/host/financials", api.hostFinancialsHandlerGET)
+		router.GET("/host/pricing", api.hostPricingHandlerGET)
+		router.POST("/host/pricing", RequirePassword(api.hostPricingHandlerPOST, requiredPassword))
+		router.GET("/host/pricing/preview", api.hostPricingPreviewHandlerGET)
 		router.GET("/host/bandwidth", api.hostBandwidthHandlerGET)
+		router.POST("/host/tokens/compact", RequirePassword(api.hostTokensCompactHandlerPOST, requiredPassword)) // Compact the events log of the token storage.
+		router.GET("/host/tokens/verify", RequirePassword(api.hostTokensVerifyHandlerGET, requiredPassword))     // Verify the snapshot of the token storage.
 
 		// Calls pertaining to the storage manager that the host uses.
 		router.GET("/host/storage", api.storageHandler)
 		router.POST("/host/storage/folders/add", RequirePassword(api.storageFoldersAddHandler, requiredPassword))
+		router.POST("/host/storage/folders/migrate", RequirePassword(api.storageFoldersMigrateHandler, requiredPassword))
 		router.POST("/host/storage/folders/remove", RequirePassword(api.storageFoldersRemoveHandler, requiredPassword))
 		router.POST("/host/storage/folders/resize", RequirePassword(api.storageFoldersResizeHandler, requiredPassword))
 		router.POST("/host/storage/sectors/delete/:merkleroot", RequirePassword(api.storageSectorsDeleteHandler, requiredPassword))
@@ -106,12 +120,15 @@ func (api *API) buildHTTPRoutes() {
 	// Mining pool API Calls
 	if api.pool != nil {
 		router.GET("/pool", api.poolHandler)
-		// router.GET("/pool/clients", api.poolGetClientsInfo)
-		// router.GET("/pool/client", api.poolGetClientInfo)
+		router.GET("/pool/clients", api.poolClientsHandler)
+		router.GET("/pool/client", api.poolClientHandler)
 		router.POST("/pool/config", RequirePassword(api.poolConfigHandlerPOST, requiredPassword)) // Change the settings of the host.
 		router.GET("/pool/config", RequirePassword(api.poolConfigHandler, requiredPassword))
-		// router.GET("/pool/blocks", api.poolGetBlocksInfo)
-		// router.GET("/pool/block", api.poolGetBlockInfo)
+		router.GET("/pool/payouts", api.poolPayoutsHandler)
+		router.GET("/pool/blocks", api.poolBlocksHandler)
+		router.GET("/pool/block", api.poolBlockHandler)
+		router.POST("/pool/reconnect", RequirePassword(api.poolReconnectHandlerPOST, requiredPassword))
+		router.POST("/pool/message", RequirePassword(api.poolMessageHandlerPOST, requiredPassword))
 	}
 
 	// Renter API Calls
@@ -155,7 +172,10 @@ func (api *API) buildHTTPRoutes() {
 
 		// Pubaccess endpoints
 		router.GET("/pubaccess/blacklist", api.skynetBlacklistHandlerGET)
+		router.POST("/pubaccess/batch", RequirePassword(api.skynetSkyfileBatchHandlerPOST, requiredPassword))
 		router.POST("/pubaccess/blacklist", RequirePassword(api.skynetBlacklistHandlerPOST, requiredPassword))
+		router.GET("/pubaccess/blacklist/subscriptions", api.skynetBlacklistSubscriptionsHandlerGET)
+		router.POST("/pubaccess/blacklist/subscriptions", RequirePassword(api.skynetBlacklistSubscriptionsHandlerPOST, requiredPassword))
 		router.POST("/pubaccess/pin/:publink", RequirePassword(api.skynetPublinkPinHandlerPOST, requiredPassword))
 		router.GET("/pubaccess/portals", api.skynetPortalsHandlerGET)
 		router.POST("/pubaccess/portals", RequirePassword(api.skynetPortalsHandlerPOST, requiredPassword))
@@ -163,11 +183,18 @@ func (api *API) buildHTTPRoutes() {
 		router.HEAD("/pubaccess/publink/*publink", api.skynetPublinkHandlerGET)
 		router.POST("/pubaccess/pubfile/*siapath", RequirePassword(api.skynetSkyfileHandlerPOST, requiredPassword))
 		router.GET("/pubaccess/stats", api.skynetStatsHandlerGET)
+		router.POST("/pubaccess/unpin/:publink", RequirePassword(api.skynetPublinkUnpinHandlerPOST, requiredPassword))
+		router.GET("/pubaccess/uploads", RequirePassword(api.skynetUploadsHandlerGET, requiredPassword))
+		router.GET("/pubaccess/uploads/:uploadid", RequirePassword(api.skynetUploadHandlerGET, requiredPassword))
+		router.POST("/pubaccess/uploads/:uploadid", RequirePassword(api.skynetUploadHandlerPOST, requiredPassword))
+		router.POST("/pubaccess/uploads/:uploadid/abort", RequirePassword(api.skynetUploadAbortHandlerPOST, requiredPassword))
 		router.GET("/pubaccess/pubaccesskey", RequirePassword(api.skykeyHandlerGET, requiredPassword))
 		router.POST("/pubaccess/createpubaccesskey", RequirePassword(api.skykeyCreateKeyHandlerPOST, requiredPassword))
 		router.POST("/pubaccess/addpubaccesskey", RequirePassword(api.skykeyAddKeyHandlerPOST, requiredPassword))
 		router.POST("/pubaccess/deletepubaccesskey", RequirePassword(api.skykeyDeleteHandlerPOST, requiredPassword))
 		router.GET("/pubaccess/pubaccesskeys", RequirePassword(api.skykeysHandlerGET, requiredPassword))
+		router.POST("/pubaccess/rotatepubaccesskey", RequirePassword(api.skykeyRotateHandlerPOST, requiredPassword))
+		router.GET("/pubaccess/pubaccesskeyrotations", RequirePassword(api.skykeyRotationsHandlerGET, requiredPassword))
 
 		// Directory endpoints
 		router.POST("/renter/dir/*siapath", RequirePassword(api.renterDirHandlerPOST, requiredPassword))
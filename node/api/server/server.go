@@ -236,7 +236,7 @@ func NewAsync(APIaddr string, requiredUserAgent string, requiredPassword string,
 
 		// Server wasn't shut down. Add node and replace modules.
 		srv.node = n
-		api.SetModules(n.ConsensusSet, n.Explorer, n.Gateway, n.Host, n.Miner, n.Renter, n.TransactionPool, n.Wallet, n.MiningPool, n.StratumMiner)
+		api.SetModules(n.ConsensusSet, n.Explorer, n.Gateway, n.Host, n.Miner, n.Renter, n.TransactionPool, n.Wallet, n.MiningPool, n.StratumMiner, n.Index)
 		return srv, nil
 	}()
 	if err != nil {
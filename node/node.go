@@ -26,6 +26,7 @@ import (
 	"github.com/EvilRedHorse/pubaccess-node/modules/explorer"
 	"github.com/EvilRedHorse/pubaccess-node/modules/gateway"
 	"github.com/EvilRedHorse/pubaccess-node/modules/host"
+	"github.com/EvilRedHorse/pubaccess-node/modules/index"
 	"github.com/EvilRedHorse/pubaccess-node/modules/miner"
 	pool "github.com/EvilRedHorse/pubaccess-node/modules/miningpool"
 	"github.com/EvilRedHorse/pubaccess-node/modules/renter"
@@ -67,6 +68,7 @@ type NodeParams struct {
 	CreateExplorer        bool
 	CreateGateway         bool
 	CreateHost            bool
+	CreateIndex           bool
 	CreateMiner           bool
 	CreateMiningPool      bool
 	CreateStratumMiner    bool
@@ -82,6 +84,7 @@ type NodeParams struct {
 	Explorer        modules.Explorer
 	Gateway         modules.Gateway
 	Host            modules.Host
+	Index           modules.Index
 	Miner           modules.TestMiner
 	MiningPool      modules.Pool
 	StratumMiner    modules.StratumMiner
@@ -130,6 +133,9 @@ type NodeParams struct {
 	// Configuration settings for the Mining pool.
 	PoolConfig config.MiningPoolConfig
 
+	// Configuration settings for the index.
+	IndexConfig config.IndexConfig
+
 	HostAPIAddr                   string
 	CheckTokenExpirationFrequency time.Duration
 }
@@ -144,6 +150,7 @@ type Node struct {
 	Explorer        modules.Explorer
 	Gateway         modules.Gateway
 	Host            modules.Host
+	Index           modules.Index
 	Miner           modules.TestMiner
 	MiningPool      modules.Pool
 	StratumMiner    modules.StratumMiner
@@ -189,6 +196,9 @@ func (np NodeParams) NumModules() (n int) {
 	if np.CreateStratumMiner || np.StratumMiner != nil {
 		n++
 	}
+	if np.CreateIndex || np.Index != nil {
+		n++
+	}
 	return
 }
 
@@ -211,6 +221,10 @@ func printfRelease(format string, a ...interface{}) (int, error) {
 // Close will call close on every module within the node, combining and
 // returning the errors.
 func (n *Node) Close() (err error) {
+	if n.Index != nil {
+		printlnRelease("Closing index...")
+		err = errors.Compose(n.Index.Close())
+	}
 	if n.MiningPool != nil {
 		printlnRelease("Closing mining pool...")
 		err = errors.Compose(n.MiningPool.Close())
@@ -585,6 +599,11 @@ func New(params NodeParams, loadStartTime time.Time) (*Node, <-chan error) {
 	if r != nil {
 		printlnRelease(" done in ", time.Since(loadStart).Seconds(), "seconds.")
 	}
+	// The pricing policy of the host samples the prices of the network from
+	// the hostdb of the renter.
+	if hh, ok := h.(*host.Host); ok && r != nil {
+		hh.SetPricingHostDB(r)
+	}
 
 	// Mining Pool.
 	loadStart = time.Now()
@@ -642,6 +661,34 @@ func New(params NodeParams, loadStartTime time.Time) (*Node, <-chan error) {
 	if sm != nil {
 		printlnRelease(" done in ", time.Since(loadStart).Seconds(), "seconds.")
 	}
+	loadStart = time.Now()
+
+	// Index.
+	idx, err := func() (modules.Index, error) {
+		if params.CreateIndex && params.Index != nil {
+			return nil, errors.New("cannot create index and also use custom index")
+		}
+		if params.Index != nil {
+			return params.Index, nil
+		}
+		if !params.CreateIndex {
+			return nil, nil
+		}
+		i++
+		printfRelease("(%d/%d) Loading index...", i, numModules)
+		idx, err := index.New(cs, tp, g, w, filepath.Join(dir, modules.IndexDir), params.IndexConfig)
+		if err != nil {
+			return nil, err
+		}
+		return idx, nil
+	}()
+	if err != nil {
+		errChan <- errors.Extend(err, errors.New("unable to create index"))
+		return nil, errChan
+	}
+	if idx != nil {
+		printlnRelease(" done in ", time.Since(loadStart).Seconds(), "seconds.")
+	}
 
 	printfRelease("API is now available, module loading completed in %.3f seconds\n", time.Since(loadStartTime).Seconds())
 	go func() {
@@ -656,6 +703,7 @@ func New(params NodeParams, loadStartTime time.Time) (*Node, <-chan error) {
 		Explorer:        e,
 		Gateway:         g,
 		Host:            h,
+		Index:           idx,
 		Miner:           m,
 		MiningPool:      p,
 		StratumMiner:    sm,
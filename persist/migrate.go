@@ -0,0 +1,160 @@
+package persist
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+
+	"gitlab.com/NebulousLabs/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// bucketMetadata is the bucket that contains the metadata of a bolt
+	// database.
+	bucketMetadata = []byte("Metadata")
+
+	// keySchemaVersion is the key within the metadata bucket that contains the
+	// version of the most recent migration that was applied to a bolt
+	// database.
+	keySchemaVersion = []byte("SchemaVersion")
+
+	// errUnorderedMigrations is returned if the versions of a list of
+	// migrations are not strictly increasing.
+	errUnorderedMigrations = errors.New("migration versions need to be strictly increasing")
+)
+
+type (
+	// BoltMigration is a change to the schema of a bolt database. Migrations
+	// are applied in the order of their versions, and every migration is only
+	// applied once.
+	BoltMigration struct {
+		Version uint64
+		Migrate func(*bolt.Tx) error
+	}
+
+	// SQLMigration is a change to the schema of a SQL database. Migrations are
+	// applied in the order of their versions, and every migration is only
+	// applied once. Migrate is optional and runs after the statements, it is
+	// used for changes that depend on the current schema of the database.
+	SQLMigration struct {
+		Version    uint64
+		Statements []string
+		Migrate    func(*sql.Tx) error
+	}
+)
+
+// SchemaVersion returns the version of the most recent migration that was
+// applied to the database.
+func (db *BoltDatabase) SchemaVersion() (version uint64, err error) {
+	err = db.View(func(tx *bolt.Tx) error {
+		version = boltSchemaVersion(tx)
+		return nil
+	})
+	return
+}
+
+// Migrate applies all of the migrations that weren't applied to the database
+// yet. Every migration is applied in its own transaction together with the
+// update of the schema version.
+func (db *BoltDatabase) Migrate(migrations []BoltMigration) error {
+	for i, m := range migrations {
+		if i > 0 && m.Version <= migrations[i-1].Version {
+			return errUnorderedMigrations
+		}
+		err := db.Update(func(tx *bolt.Tx) error {
+			if m.Version <= boltSchemaVersion(tx) {
+				return nil
+			}
+			if err := m.Migrate(tx); err != nil {
+				return err
+			}
+			bucket, err := tx.CreateBucketIfNotExists(bucketMetadata)
+			if err != nil {
+				return err
+			}
+			version := make([]byte, 8)
+			binary.LittleEndian.PutUint64(version, m.Version)
+			return bucket.Put(keySchemaVersion, version)
+		})
+		if err != nil {
+			return errors.AddContext(err, fmt.Sprintf("migration to version %v failed", m.Version))
+		}
+	}
+	return nil
+}
+
+// boltSchemaVersion returns the schema version that is stored within the
+// metadata bucket of a bolt database.
+func boltSchemaVersion(tx *bolt.Tx) uint64 {
+	bucket := tx.Bucket(bucketMetadata)
+	if bucket == nil {
+		return 0
+	}
+	version := bucket.Get(keySchemaVersion)
+	if len(version) != 8 {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(version)
+}
+
+// MigrateSQL applies all of the migrations that weren't applied to a SQL
+// database yet. The version of the most recent migration is tracked in the
+// provided table, which allows multiple modules to share a database.
+func MigrateSQL(db *sql.DB, versionTable string, migrations []SQLMigration) error {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS " + versionTable + " (version bigint unsigned NOT NULL)")
+	if err != nil {
+		return errors.AddContext(err, "unable to create schema version table")
+	}
+	var version sql.NullInt64
+	err = db.QueryRow("SELECT MAX(version) FROM " + versionTable).Scan(&version)
+	if err != nil {
+		return errors.AddContext(err, "unable to read schema version")
+	}
+
+	for i, m := range migrations {
+		if i > 0 && m.Version <= migrations[i-1].Version {
+			return errUnorderedMigrations
+		}
+		if m.Version <= uint64(version.Int64) {
+			continue
+		}
+		err = migrateSQL(db, versionTable, m)
+		if err != nil {
+			return errors.AddContext(err, fmt.Sprintf("migration to version %v failed", m.Version))
+		}
+	}
+	return nil
+}
+
+// migrateSQL applies a single migration to a SQL database. Note that some
+// databases, like MySQL, commit schema changes implicitly, which is why the
+// statements of a migration should be safe to run more than once.
+func migrateSQL(db *sql.DB, versionTable string, m SQLMigration) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Compose(err, tx.Rollback())
+		}
+	}()
+	for _, stmt := range m.Statements {
+		_, err = tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+	if m.Migrate != nil {
+		err = m.Migrate(tx)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("INSERT INTO "+versionTable+"(version) VALUES(?)", m.Version)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
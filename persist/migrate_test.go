@@ -0,0 +1,98 @@
+package persist
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/EvilRedHorse/pubaccess-node/build"
+)
+
+// TestBoltDatabaseMigrate checks that migrations of a bolt database are
+// applied in order and only once.
+func TestBoltDatabaseMigrate(t *testing.T) {
+	testDir := build.TempDir(persistDir, t.Name())
+	err := os.MkdirAll(testDir, defaultDirPermissions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := Metadata{"Test Migrate", "1.0"}
+	db, err := OpenDatabase(md, filepath.Join(testDir, "migrate.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// A new database doesn't have a schema version.
+	version, err := db.SchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 0 {
+		t.Fatal("expected version 0, got", version)
+	}
+
+	// Apply two migrations.
+	var applied []uint64
+	migration := func(v uint64) BoltMigration {
+		return BoltMigration{
+			Version: v,
+			Migrate: func(tx *bolt.Tx) error {
+				applied = append(applied, v)
+				return nil
+			},
+		}
+	}
+	migrations := []BoltMigration{migration(1), migration(2)}
+	err = db.Migrate(migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 || applied[0] != 1 || applied[1] != 2 {
+		t.Fatal("migrations weren't applied in order", applied)
+	}
+
+	// Adding a migration should only apply the new one.
+	applied = nil
+	migrations = append(migrations, migration(3))
+	err = db.Migrate(migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 1 || applied[0] != 3 {
+		t.Fatal("expected only the new migration to be applied", applied)
+	}
+	version, err = db.SchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 3 {
+		t.Fatal("expected version 3, got", version)
+	}
+
+	// A failing migration shouldn't update the version.
+	errFail := errors.New("migration failed")
+	err = db.Migrate(append(migrations, BoltMigration{
+		Version: 4,
+		Migrate: func(tx *bolt.Tx) error { return errFail },
+	}))
+	if err == nil {
+		t.Fatal("expected migration to fail")
+	}
+	version, err = db.SchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 3 {
+		t.Fatal("expected version 3 after failed migration, got", version)
+	}
+
+	// Unordered migrations are rejected.
+	err = db.Migrate([]BoltMigration{migration(5), migration(5)})
+	if err != errUnorderedMigrations {
+		t.Fatal("expected errUnorderedMigrations, got", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/url"
 
 	"github.com/aead/chacha20/chacha"
+	"golang.org/x/crypto/argon2"
 
 	"gitlab.com/NebulousLabs/encoding"
 	"gitlab.com/NebulousLabs/errors"
@@ -27,12 +28,22 @@ const (
 	// MaxKeyNameLen is the maximum length of a pubaccesskey's name.
 	MaxKeyNameLen = 128
 
+	// MaxPasswordLen is the maximum length of the passphrase of a
+	// TypePassword pubaccesskey.
+	MaxPasswordLen = 128
+
+	// passwordIDEntropySize is the size of the random value that precedes the
+	// passphrase in the entropy of a TypePassword pubaccesskey. The ID of the
+	// key is derived from it, so that the ID can't be used to guess the
+	// passphrase.
+	passwordIDEntropySize = 32
+
 	// maxEntropyLen is used in unmarshalDataOnly as a cap for the entropy. It
 	// should only ever go up between releases. The cap prevents over-allocating
 	// when reading the length of a deleted pubaccesskey.
 	// It must be at most MaxKeyNameLen plus the max entropy size for any
 	// cipher-type.
-	maxEntropyLen = 256
+	maxEntropyLen = 512
 
 	// Define PubaccesskeyTypes. Constants stated explicitly (instead of
 	// `PubaccesskeyType(iota)`) to avoid re-ordering mistakes in the future.
@@ -50,6 +61,26 @@ const (
 	// successfully decrypted with the correct pubaccesskey.
 	TypePrivateID = PubaccesskeyType(0x02)
 
+	// TypePassword is a Pubaccesskey that derives an XChaCha20 key for every
+	// pubfile from a passphrase using the memory-hard Argon2id KDF. The salt
+	// of the KDF is stored in the header of the pubfile in place of the
+	// nonce. Like TypePrivateID pubaccesskeys, it doesn't reveal its
+	// pubaccesskey ID when encrypting Skyfiles. Its ID is derived from a
+	// random value that is stored with the passphrase.
+	TypePassword = PubaccesskeyType(0x03)
+
+	// TypeX25519 is a Pubaccesskey that holds the secret half of an X25519
+	// key pair. Skyfiles are encrypted to its public key by deriving the
+	// XChaCha20 key from the shared secret with an ephemeral key pair, whose
+	// public key is stored in the header of the pubfile. Only the holder of
+	// the secret key can decrypt the pubfile.
+	TypeX25519 = PubaccesskeyType(0x04)
+
+	// TypeX25519Public is a Pubaccesskey that holds the public half of the
+	// X25519 key pair of a TypeX25519 pubaccesskey. It can be shared freely
+	// and only be used to encrypt Skyfiles to the owner of the key pair.
+	TypeX25519Public = PubaccesskeyType(0x05)
+
 	// typeDeletedSkykey is used internally to mark a key as deleted in the pubaccesskey
 	// manager. It is different from TypeInvalid because TypeInvalid can be used
 	// to catch other kinds of errors, i.e. accidentally using a Pubaccesskey{} with
@@ -63,6 +94,26 @@ var (
 	SkykeySpecifier               = types.NewSpecifier("Pubaccesskey")
 	skyfileEncryptionIDSpecifier  = types.NewSpecifier("PubfileEncID")
 	skyfileEncryptionIDDerivation = types.NewSpecifier("PFEncIDDerivPath")
+	x25519KeyDerivation           = types.NewSpecifier("PubfileX25519Key")
+
+	// passwordKDFTime, passwordKDFMemory and passwordKDFThreads are the
+	// Argon2id parameters used to derive the file-specific keys of
+	// TypePassword pubaccesskeys. The memory is given in KiB.
+	passwordKDFTime = build.Select(build.Var{
+		Standard: uint32(3),
+		Dev:      uint32(1),
+		Testing:  uint32(1),
+	}).(uint32)
+	passwordKDFMemory = build.Select(build.Var{
+		Standard: uint32(64 * 1024),
+		Dev:      uint32(8 * 1024),
+		Testing:  uint32(64),
+	}).(uint32)
+	passwordKDFThreads = build.Select(build.Var{
+		Standard: uint8(4),
+		Dev:      uint8(1),
+		Testing:  uint8(1),
+	}).(uint8)
 
 	errUnsupportedPubaccesskeyType            = errors.New("Unsupported Pubaccesskey type")
 	errUnmarshalDataErr                       = errors.New("Unable to unmarshal Pubaccesskey data")
@@ -72,6 +123,9 @@ var (
 
 	errInvalidIDorNonceLength = errors.New("Invalid length for encryptionID or nonce in MatchesPubfileEncryptionID")
 
+	errInvalidHeaderDataLength = errors.New("Invalid length of pubfile header data")
+	errInvalidPasswordLength   = errors.New("Invalid pubaccesskey password length")
+
 	// ErrInvalidPubaccesskeyType is returned when an invalid PubaccesskeyType is being used.
 	ErrInvalidPubaccesskeyType = errors.New("Invalid pubaccesskey type")
 )
@@ -105,6 +159,12 @@ func (t PubaccesskeyType) ToString() string {
 		return "public-id"
 	case TypePrivateID:
 		return "private-id"
+	case TypePassword:
+		return "password"
+	case TypeX25519:
+		return "x25519"
+	case TypeX25519Public:
+		return "x25519-public"
 	default:
 		return "invalid"
 	}
@@ -117,6 +177,12 @@ func (t *PubaccesskeyType) FromString(s string) error {
 		*t = TypePublicID
 	case "private-id":
 		*t = TypePrivateID
+	case "password":
+		*t = TypePassword
+	case "x25519":
+		*t = TypeX25519
+	case "x25519-public":
+		*t = TypeX25519Public
 	default:
 		return ErrInvalidPubaccesskeyType
 	}
@@ -201,7 +267,7 @@ func (sk *Pubaccesskey) unmarshalAndConvertFromOldFormat(r io.Reader) error {
 // CipherType returns the crypto.CipherType used by this Pubaccesskey.
 func (t PubaccesskeyType) CipherType() crypto.CipherType {
 	switch t {
-	case TypePublicID, TypePrivateID:
+	case TypePublicID, TypePrivateID, TypePassword, TypeX25519, TypeX25519Public:
 		return crypto.TypeXChaCha20
 	default:
 		return crypto.TypeInvalid
@@ -223,6 +289,13 @@ func (sk *Pubaccesskey) unmarshalDataOnly(r io.Reader) error {
 	switch sk.Type {
 	case TypePublicID, TypePrivateID:
 		entropyLen = chacha.KeySize + chacha.XNonceSize
+	case TypePassword:
+		entropyLen = d.NextUint64()
+		if !validPasswordEntropyLen(entropyLen) {
+			return errInvalidPasswordLength
+		}
+	case TypeX25519, TypeX25519Public:
+		entropyLen = uint64(len(crypto.X25519SecretKey{}))
 	case TypeInvalid:
 		return errCannotMarshalTypeInvalidSkykey
 	case typeDeletedSkykey:
@@ -271,6 +344,13 @@ func (sk Pubaccesskey) marshalDataOnly(w io.Writer) error {
 	switch sk.Type {
 	case TypePublicID, TypePrivateID:
 		entropyLen = chacha.KeySize + chacha.XNonceSize
+	case TypePassword:
+		entropyLen = len(sk.Entropy)
+		if !validPasswordEntropyLen(uint64(entropyLen)) {
+			return errInvalidPasswordLength
+		}
+	case TypeX25519, TypeX25519Public:
+		entropyLen = len(crypto.X25519SecretKey{})
 	case TypeInvalid:
 		return errCannotMarshalTypeInvalidSkykey
 	default:
@@ -282,6 +362,9 @@ func (sk Pubaccesskey) marshalDataOnly(w io.Writer) error {
 	}
 
 	e.WriteByte(byte(sk.Type))
+	if sk.Type == TypePassword {
+		e.WriteUint64(uint64(entropyLen))
+	}
 	e.Write(sk.Entropy[:entropyLen])
 	return e.Err()
 }
@@ -368,6 +451,16 @@ func (sk Pubaccesskey) ID() (keyID PubaccesskeyID) {
 	case TypePublicID, TypePrivateID:
 		entropy = sk.Entropy[:chacha.KeySize]
 
+	// Only use the random value of password keys, hashing the passphrase
+	// would allow it to be guessed from the ID.
+	case TypePassword:
+		if len(entropy) > passwordIDEntropySize {
+			entropy = entropy[:passwordIDEntropySize]
+		}
+
+	// These keys are identified by all of their entropy.
+	case TypeX25519, TypeX25519Public:
+
 	default:
 		build.Critical("Computing ID with pubaccesskey of unknown type: ", sk.Type)
 	}
@@ -410,13 +503,140 @@ func (sk *Pubaccesskey) equalData(otherKey Pubaccesskey) bool {
 // being uploaded/downloaded. Skykeys can only be used once with a
 // given nonce, so this method is used to generate keys with new nonces when a
 // new file is uploaded.
+//
+// Password and X25519 pubaccesskeys derive a new key for every file instead.
+// Their file-specific subkeys are TypePrivateID keys, since skyfiles are
+// encrypted with them the same way as with a private-id key.
 func (sk *Pubaccesskey) GenerateFileSpecificSubkey() (Pubaccesskey, error) {
+	switch sk.Type {
+	case TypeX25519, TypeX25519Public:
+		// Derive the key from the shared secret of an ephemeral key pair
+		// and the recipient's public key.
+		xpk, err := sk.x25519PublicKey()
+		if err != nil {
+			return Pubaccesskey{}, err
+		}
+		esk, epk := crypto.GenerateX25519KeyPair()
+		return sk.sealedBoxSubkey(crypto.DeriveSharedSecret(esk, xpk), epk), nil
+	case TypePassword:
+		// The nonce is used as the salt of the KDF.
+		salt := fastrand.Bytes(chacha.XNonceSize)
+		return sk.SubkeyWithHeaderData(salt)
+	}
+
 	// Generate a new random nonce.
 	nonce := make([]byte, chacha.XNonceSize)
 	fastrand.Read(nonce[:])
 	return sk.SubkeyWithNonce(nonce)
 }
 
+// SubkeyWithHeaderData returns the file-specific subkey of a pubfile given the
+// data that follows the key identifier in its header. See HeaderData.
+func (sk *Pubaccesskey) SubkeyWithHeaderData(headerData []byte) (Pubaccesskey, error) {
+	if len(headerData) < chacha.XNonceSize {
+		return Pubaccesskey{}, errInvalidHeaderDataLength
+	}
+
+	switch sk.Type {
+	case TypePublicID, TypePrivateID:
+		return sk.SubkeyWithNonce(headerData[:chacha.XNonceSize])
+
+	case TypePassword:
+		salt := headerData[:chacha.XNonceSize]
+		if !validPasswordEntropyLen(uint64(len(sk.Entropy))) {
+			return Pubaccesskey{}, errInvalidPasswordLength
+		}
+		password := sk.Entropy[passwordIDEntropySize:]
+		key := argon2.IDKey(password, salt, passwordKDFTime, passwordKDFMemory, passwordKDFThreads, chacha.KeySize)
+		entropy := make([]byte, chacha.KeySize+chacha.XNonceSize)
+		copy(entropy[:chacha.KeySize], key)
+		copy(entropy[chacha.KeySize:], salt)
+		return Pubaccesskey{sk.Name, TypePrivateID, entropy}, nil
+
+	case TypeX25519:
+		var epk crypto.X25519PublicKey
+		if len(headerData) < len(epk) {
+			return Pubaccesskey{}, errInvalidHeaderDataLength
+		}
+		copy(epk[:], headerData)
+		var xsk crypto.X25519SecretKey
+		copy(xsk[:], sk.Entropy)
+		return sk.sealedBoxSubkey(crypto.DeriveSharedSecret(xsk, epk), epk), nil
+
+	default:
+		return Pubaccesskey{}, errPubaccesskeyTypeDoesNotSupportFunction
+	}
+}
+
+// HeaderData returns the data of a file-specific Pubaccesskey that is stored
+// in plaintext in the header of the pubfile after the key identifier. It
+// consists of the nonce, followed by the remainder of the ephemeral public key
+// for subkeys of X25519 pubaccesskeys.
+func (sk *Pubaccesskey) HeaderData() []byte {
+	return append([]byte(nil), sk.Entropy[chacha.KeySize:]...)
+}
+
+// sealedBoxSubkey returns the file-specific subkey of an X25519 pubaccesskey
+// for the shared secret with the ephemeral public key. The nonce of the subkey
+// is the start of the ephemeral public key, the remainder of the key follows
+// the nonce in the entropy so that the full key is stored in the pubfile
+// header.
+func (sk *Pubaccesskey) sealedBoxSubkey(secret [32]byte, epk crypto.X25519PublicKey) Pubaccesskey {
+	key := crypto.HashAll(x25519KeyDerivation, secret, epk)
+	entropy := make([]byte, chacha.KeySize+len(epk))
+	copy(entropy[:chacha.KeySize], key[:])
+	copy(entropy[chacha.KeySize:], epk[:])
+	return Pubaccesskey{sk.Name, TypePrivateID, entropy}
+}
+
+// x25519PublicKey returns the X25519 public key of the pubaccesskey.
+func (sk *Pubaccesskey) x25519PublicKey() (xpk crypto.X25519PublicKey, err error) {
+	switch sk.Type {
+	case TypeX25519:
+		var xsk crypto.X25519SecretKey
+		copy(xsk[:], sk.Entropy)
+		return xsk.PublicKey(), nil
+	case TypeX25519Public:
+		copy(xpk[:], sk.Entropy)
+		return xpk, nil
+	default:
+		return xpk, errPubaccesskeyTypeDoesNotSupportFunction
+	}
+}
+
+// PublicKey returns the TypeX25519Public Pubaccesskey with the public key of a
+// TypeX25519 Pubaccesskey. It can be shared with others to allow them to
+// encrypt skyfiles which only the owner of the TypeX25519 key can decrypt.
+func (sk *Pubaccesskey) PublicKey() (Pubaccesskey, error) {
+	if sk.Type != TypeX25519 {
+		return Pubaccesskey{}, errPubaccesskeyTypeDoesNotSupportFunction
+	}
+	xpk, err := sk.x25519PublicKey()
+	if err != nil {
+		return Pubaccesskey{}, err
+	}
+	return Pubaccesskey{sk.Name, TypeX25519Public, xpk[:]}, nil
+}
+
+// NewPasswordSkykey returns a TypePassword Pubaccesskey with the given name
+// and passphrase. Keys created from the same passphrase have different IDs
+// but can decrypt each other's skyfiles.
+func NewPasswordSkykey(name, password string) (Pubaccesskey, error) {
+	sk := Pubaccesskey{
+		Name:    name,
+		Type:    TypePassword,
+		Entropy: append(fastrand.Bytes(passwordIDEntropySize), password...),
+	}
+	return sk, sk.IsValid()
+}
+
+// validPasswordEntropyLen returns whether the entropy length of a
+// TypePassword pubaccesskey fits the random value and a passphrase of a valid
+// length.
+func validPasswordEntropyLen(entropyLen uint64) bool {
+	return entropyLen > passwordIDEntropySize && entropyLen <= passwordIDEntropySize+MaxPasswordLen
+}
+
 // DeriveSubkey is used to create Skykeys with the same key, but with a
 // different nonce. This is used to create file-specific keys, and separate keys
 // for Pubfile baseSector uploads and fanout uploads.
@@ -482,18 +702,20 @@ func (sk *Pubaccesskey) GenerateSkyfileEncryptionID() ([SkykeyIDLen]byte, error)
 }
 
 // MatchesSkyfileEncryptionID returns true if and only if the pubaccesskey was the one
-// used with this nonce to create the encryptionID.
-func (sk *Pubaccesskey) MatchesSkyfileEncryptionID(encryptionID, nonce []byte) (bool, error) {
-	if len(encryptionID) != SkykeyIDLen || len(nonce) != chacha.XNonceSize {
+// used with this header data to create the encryptionID. The header data starts
+// with the nonce, see HeaderData.
+func (sk *Pubaccesskey) MatchesSkyfileEncryptionID(encryptionID, headerData []byte) (bool, error) {
+	if len(encryptionID) != SkykeyIDLen || len(headerData) < chacha.XNonceSize {
 		return false, errInvalidIDorNonceLength
 	}
-	// This only applies to TypePrivateID keys.
-	if sk.Type != TypePrivateID {
+	// This only applies to keys which can decrypt skyfiles without revealing
+	// their ID.
+	if sk.Type != TypePrivateID && sk.Type != TypePassword && sk.Type != TypeX25519 {
 		return false, nil
 	}
 
 	// Create the subkey for the encryption ID.
-	fileSkykey, err := sk.SubkeyWithNonce(nonce)
+	fileSkykey, err := sk.SubkeyWithHeaderData(headerData)
 	if err != nil {
 		return false, err
 	}
@@ -519,7 +741,13 @@ func (sk *Pubaccesskey) MatchesSkyfileEncryptionID(encryptionID, nonce []byte) (
 
 // CipherKey returns the crypto.CipherKey equivalent of this Pubaccesskey.
 func (sk *Pubaccesskey) CipherKey() (crypto.CipherKey, error) {
-	return crypto.NewSiaKey(sk.CipherType(), sk.Entropy)
+	// File-specific subkeys of X25519 pubaccesskeys carry the remainder of the
+	// ephemeral public key after the nonce, which isn't part of the key.
+	entropy := sk.Entropy
+	if sk.Type == TypePrivateID && len(entropy) > chacha.KeySize+chacha.XNonceSize {
+		entropy = entropy[:chacha.KeySize+chacha.XNonceSize]
+	}
+	return crypto.NewSiaKey(sk.CipherType(), entropy)
 }
 
 // Nonce returns the nonce of this Pubaccesskey.
@@ -540,14 +768,23 @@ func (sk *Pubaccesskey) IsValid() error {
 		if len(sk.Entropy) != chacha.KeySize+chacha.XNonceSize {
 			return errInvalidEntropyLength
 		}
+		_, err := crypto.NewSiaKey(sk.CipherType(), sk.Entropy)
+		if err != nil {
+			return err
+		}
+
+	case TypePassword:
+		if !validPasswordEntropyLen(uint64(len(sk.Entropy))) {
+			return errInvalidPasswordLength
+		}
+
+	case TypeX25519, TypeX25519Public:
+		if len(sk.Entropy) != len(crypto.X25519SecretKey{}) {
+			return errInvalidEntropyLength
+		}
 
 	default:
 		return errUnsupportedPubaccesskeyType
 	}
-
-	_, err := crypto.NewSiaKey(sk.CipherType(), sk.Entropy)
-	if err != nil {
-		return err
-	}
 	return nil
 }
@@ -660,6 +660,16 @@ func TestPubaccesskeyTypeStrings(t *testing.T) {
 	if st != TypePrivateID {
 		t.Fatal("Wrong PubaccesskeyType", st)
 	}
+
+	for _, skykeyType := range []PubaccesskeyType{TypePassword, TypeX25519, TypeX25519Public} {
+		err = st.FromString(skykeyType.ToString())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st != skykeyType {
+			t.Fatal("Wrong PubaccesskeyType", st, skykeyType)
+		}
+	}
 }
 
 // TestSkyfileEncryptionIDs tests the generation and verification of pubfile
@@ -776,6 +786,204 @@ func TestSkyfileEncryptionIDs(t *testing.T) {
 	}
 }
 
+// TestPasswordSkykeys tests deriving file-specific keys from password
+// pubaccesskeys.
+func TestPasswordSkykeys(t *testing.T) {
+	// Invalid passwords are rejected.
+	_, err := NewPasswordSkykey("empty", "")
+	if !errors.Contains(err, errInvalidPasswordLength) {
+		t.Fatal("expected password length error", err)
+	}
+	_, err = NewPasswordSkykey("long", strings.Repeat("a", MaxPasswordLen+1))
+	if !errors.Contains(err, errInvalidPasswordLength) {
+		t.Fatal("expected password length error", err)
+	}
+
+	sk, err := NewPasswordSkykey("password", "correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSk, err := NewPasswordSkykey("password", "correct horse battery stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sk.ID() == otherSk.ID() {
+		t.Fatal("different passwords should have different IDs")
+	}
+
+	// The ID is derived from the random value, not from the passphrase.
+	sameSk, err := NewPasswordSkykey("password", "correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sk.ID() == sameSk.ID() {
+		t.Fatal("keys created from the same password should have different IDs")
+	}
+	h := crypto.HashAll(SkykeySpecifier, sk.Type, []byte("correct horse battery staple"))
+	if id := sk.ID(); bytes.Equal(h[:SkykeyIDLen], id[:]) {
+		t.Fatal("ID shouldn't be derived from the raw password")
+	}
+
+	// The key survives encoding.
+	skStr, err := sk.ToString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decodedSk Pubaccesskey
+	err = decodedSk.FromString(skStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decodedSk.equals(sk) {
+		t.Fatal("decoded key doesn't match", decodedSk, sk)
+	}
+
+	// The file-specific key is derived again from its header data.
+	fsKey, err := sk.GenerateFileSpecificSubkey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fsKey.Type != TypePrivateID || len(fsKey.HeaderData()) != chacha.XNonceSize {
+		t.Fatal("unexpected file-specific key", fsKey.Type, len(fsKey.HeaderData()))
+	}
+	derivedKey, err := decodedSk.SubkeyWithHeaderData(fsKey.HeaderData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !derivedKey.equals(fsKey) {
+		t.Fatal("derived key doesn't match file-specific key")
+	}
+	fsKey2, err := sk.GenerateFileSpecificSubkey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(fsKey.Entropy, fsKey2.Entropy) {
+		t.Fatal("file-specific keys should use different salts")
+	}
+
+	// Only the right password matches the encryption ID.
+	encID, err := fsKey.GenerateSkyfileEncryptionID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, err := sk.MatchesSkyfileEncryptionID(encID[:], fsKey.HeaderData())
+	if err != nil || !matches {
+		t.Fatal("password key should match", matches, err)
+	}
+	matches, err = otherSk.MatchesSkyfileEncryptionID(encID[:], fsKey.HeaderData())
+	if err != nil || matches {
+		t.Fatal("other password key shouldn't match", matches, err)
+	}
+	matches, err = sameSk.MatchesSkyfileEncryptionID(encID[:], fsKey.HeaderData())
+	if err != nil || !matches {
+		t.Fatal("key with the same password should match", matches, err)
+	}
+}
+
+// TestX25519Skykeys tests encrypting to the public key of an X25519
+// pubaccesskey.
+func TestX25519Skykeys(t *testing.T) {
+	persistDir := build.TempDir("pubaccesskey", t.Name())
+	keyMan, err := NewSkykeyManager(persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, err := keyMan.CreateKey("x25519", TypeX25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSk, err := keyMan.CreateKey("other-x25519", TypeX25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Password and public keys can't be created.
+	_, err = keyMan.CreateKey("password", TypePassword)
+	if !errors.Contains(err, errUnsupportedPubaccesskeyType) {
+		t.Fatal("expected unsupported type error", err)
+	}
+	_, err = keyMan.CreateKey("public", TypeX25519Public)
+	if !errors.Contains(err, errUnsupportedPubaccesskeyType) {
+		t.Fatal("expected unsupported type error", err)
+	}
+
+	// Get the public key and add it to another key manager.
+	pk, err := sk.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pk.Type != TypeX25519Public || pk.ID() == sk.ID() {
+		t.Fatal("unexpected public key", pk.Type)
+	}
+	_, err = pk.PublicKey()
+	if !errors.Contains(err, errPubaccesskeyTypeDoesNotSupportFunction) {
+		t.Fatal("public key shouldn't have a public key", err)
+	}
+	senderDir := build.TempDir("pubaccesskey", t.Name(), "sender")
+	senderMan, err := NewSkykeyManager(senderDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = senderMan.AddKey(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	senderMan, err = NewSkykeyManager(senderDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, err = senderMan.KeyByName("x25519")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Encrypt to the public key.
+	fsKey, err := pk.GenerateFileSpecificSubkey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	headerData := fsKey.HeaderData()
+	if fsKey.Type != TypePrivateID || len(headerData) != len(crypto.X25519PublicKey{}) {
+		t.Fatal("unexpected file-specific key", fsKey.Type, len(headerData))
+	}
+	if _, err := fsKey.CipherKey(); err != nil {
+		t.Fatal(err)
+	}
+	encID, err := fsKey.GenerateSkyfileEncryptionID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the secret key can derive the file-specific key.
+	_, err = pk.SubkeyWithHeaderData(headerData)
+	if !errors.Contains(err, errPubaccesskeyTypeDoesNotSupportFunction) {
+		t.Fatal("public key shouldn't derive the file-specific key", err)
+	}
+	_, err = sk.SubkeyWithHeaderData(headerData[:chacha.XNonceSize])
+	if !errors.Contains(err, errInvalidHeaderDataLength) {
+		t.Fatal("expected header data length error", err)
+	}
+	derivedKey, err := sk.SubkeyWithHeaderData(headerData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !derivedKey.equals(fsKey) {
+		t.Fatal("derived key doesn't match file-specific key")
+	}
+	matches, err := sk.MatchesSkyfileEncryptionID(encID[:], headerData)
+	if err != nil || !matches {
+		t.Fatal("secret key should match", matches, err)
+	}
+	matches, err = otherSk.MatchesSkyfileEncryptionID(encID[:], headerData)
+	if err != nil || matches {
+		t.Fatal("other key shouldn't match", matches, err)
+	}
+	matches, err = pk.MatchesSkyfileEncryptionID(encID[:], headerData)
+	if err != nil || matches {
+		t.Fatal("public key shouldn't match", matches, err)
+	}
+}
+
 // TestSkykeyDelete tests the Delete methods for the pubaccesskey manager.
 func TestSkykeyDelete(t *testing.T) {
 	// Create a key manager.
@@ -223,8 +223,14 @@ func (sm *SkykeyManager) CreateKey(name string, skykeyType PubaccesskeyType) (Pu
 	}
 
 	// Generate the new key.
-	cipherKey := crypto.GenerateSiaKey(skykeyType.CipherType())
-	pubaccesskey := Pubaccesskey{name, skykeyType, cipherKey.Key()}
+	var entropy []byte
+	if skykeyType == TypeX25519 {
+		xsk, _ := crypto.GenerateX25519KeyPair()
+		entropy = xsk[:]
+	} else {
+		entropy = crypto.GenerateSiaKey(skykeyType.CipherType()).Key()
+	}
+	pubaccesskey := Pubaccesskey{name, skykeyType, entropy}
 
 	err := sm.saveKey(pubaccesskey)
 	if err != nil {
@@ -308,10 +314,11 @@ func (sm *SkykeyManager) Skykeys() []Pubaccesskey {
 }
 
 // SupportsPubaccesskeyType returns true if and only if the SkykeyManager supports
-// skykeys with the given type.
+// creating skykeys with the given type. TypePassword and TypeX25519Public
+// pubaccesskeys can't be generated, they have to be added instead.
 func (sm *SkykeyManager) SupportsPubaccesskeyType(skykeyType PubaccesskeyType) bool {
 	switch skykeyType {
-	case TypePublicID, TypePrivateID:
+	case TypePublicID, TypePrivateID, TypeX25519:
 		return true
 	default:
 		return false
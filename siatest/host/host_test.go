@@ -3,6 +3,7 @@ package host
 import (
 	"bytes"
 	"fmt"
+	"net/url"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -337,6 +338,30 @@ func TestHostContract(t *testing.T) {
 		t.Fatal("contract should have 0 datasize")
 	}
 
+	// The contract should be found by querying for its renter and status.
+	if len(hcg.Contract.RenterPublicKey.Key) == 0 {
+		t.Fatal("contract should have a renter public key")
+	}
+	values := url.Values{}
+	values.Set("renterkey", hcg.Contract.RenterPublicKey.String())
+	values.Set("status", "unresolved")
+	values.Set("limit", "1")
+	queried, err := hostNode.HostContractInfoQueryGet(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queried.Contracts) != 1 || queried.Contracts[0].ObligationId != contractID || queried.Summary.Count != 1 {
+		t.Fatal("contract wasn't returned by the query", queried)
+	}
+	values.Set("status", "succeeded")
+	queried, err = hostNode.HostContractInfoQueryGet(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queried.Contracts) != 0 || queried.Summary.Count != 0 {
+		t.Fatal("query should not match the contract", queried)
+	}
+
 	prevValidPayout := hcg.Contract.ValidProofOutputs[1].Value
 	prevMissPayout := hcg.Contract.MissedProofOutputs[1].Value
 	_, _, err = renterNode.UploadNewFileBlocking(int(modules.SectorSize), 1, 1, true)
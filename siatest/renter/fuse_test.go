@@ -732,9 +732,94 @@ func TestFuse(t *testing.T) {
 		t.Fatal("should not be able to make a directory in a read-only fuse system")
 	}
 
-	// TODO: When read-write fuse is supported, switch over to read-write mode
-	// here and begin testing the write features. Extend the concurrency test to
-	// probe write features as well, probably by adding more phases.
+	// Mount the root in read-write mode and check that files can be created,
+	// written, renamed and deleted.
+	rwMount := filepath.Join(testDir, "rwMount")
+	err = os.MkdirAll(rwMount, persist.DefaultDiskPermissionsTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.RenterFuseMount(rwMount, modules.RootSiaPath(), modules.MountOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rwDir := filepath.Join(rwMount, "rwdir")
+	err = os.Mkdir(rwDir, persist.DefaultDiskPermissionsTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rwData := fastrand.Bytes(100)
+	rwFilePath := filepath.Join(rwDir, "rwfile")
+	err = ioutil.WriteFile(rwFilePath, rwData, persist.DefaultDiskPermissionsTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rwSiaPath, err := modules.NewSiaPath("rwdir/rwfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamData, err := r.RenterStreamGet(rwSiaPath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(streamData, rwData) {
+		t.Fatal("data uploaded through fuse does not match the written data")
+	}
+	// Overwrite the file. The new version replaces the old one without leaving
+	// the temporary upload behind.
+	rwData = fastrand.Bytes(200)
+	err = ioutil.WriteFile(rwFilePath, rwData, persist.DefaultDiskPermissionsTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamData, err = r.RenterStreamGet(rwSiaPath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(streamData, rwData) {
+		t.Fatal("data of the overwritten file does not match the written data")
+	}
+	rwDirSiaPath, err := rwSiaPath.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rd, err := r.RenterDirGet(rwDirSiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rd.Files) != 1 {
+		t.Fatal("expected only the overwritten file in the directory, got", len(rd.Files))
+	}
+	// Rename the file and read it back through fuse.
+	rwRenamedPath := rwFilePath + "-renamed"
+	err = os.Rename(rwFilePath, rwRenamedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamedData, err := ioutil.ReadFile(rwRenamedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(renamedData, rwData) {
+		t.Fatal("data mismatch after renaming a file in a read-write mount")
+	}
+	// Removing a non-empty directory should fail, after removing the file it
+	// should succeed.
+	if err := os.Remove(rwDir); err == nil {
+		t.Fatal("should not be able to remove a non-empty directory")
+	}
+	err = os.Remove(rwRenamedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Remove(rwDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.RenterFuseUnmount(rwMount)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// Inode check. Mount the root siafile to a special inode mountpoint then
 	// open several files and directoriesk. Grab their inodes. Keep the folder
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"fmt"
 	"net/url"
+	"strings"
 	"testing"
 
 	"gitlab.com/NebulousLabs/fastrand"
 	"github.com/EvilRedHorse/pubaccess-node/modules"
+	"github.com/EvilRedHorse/pubaccess-node/modules/renter"
 	"github.com/EvilRedHorse/pubaccess-node/node/api"
 	"github.com/EvilRedHorse/pubaccess-node/node/api/client"
 	"github.com/EvilRedHorse/pubaccess-node/persist"
@@ -38,7 +40,10 @@ func TestSkykey(t *testing.T) {
 		{Name: "EncryptionTypePrivateID", Test: testSkynetEncryptionWithType(pubaccesskey.TypePrivateID)},
 		{Name: "EncryptionTypePublicID", Test: testSkynetEncryptionWithType(pubaccesskey.TypePublicID)},
 		{Name: "LargeFilePrivateID", Test: testSkynetEncryptionLargeFileWithType(pubaccesskey.TypePrivateID)},
-		{Name: "LargeFilePublicID", Test: testSkynetEncryptionLargeFileWithType(pubaccesskey.TypePublicID)},
+		{Name: "EncryptionTypeX25519", Test: testSkynetEncryptionWithType(pubaccesskey.TypeX25519)},
+		{Name: "PasswordSkykey", Test: testPasswordSkykey},
+		{Name: "PublicKeyEncryption", Test: testPublicKeyEncryption},
+		{Name: "RotateSkykey", Test: testRotateSkykey},
 		{Name: "UnsafeClient", Test: testUnsafeClient},
 	}
 
@@ -496,6 +501,210 @@ func testUnsafeClient(t *testing.T, tg *siatest.TestGroup) {
 	}
 }
 
+// testPasswordSkykey tests that pubfiles encrypted with a password pubaccesskey
+// can be decrypted with a pubaccesskey created from the same password.
+func testPasswordSkykey(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	password := "a password for " + t.Name()
+	_, err := r.SkykeyCreatePasswordKeyPost("password-key", password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.SkykeyCreatePasswordKeyPost("empty-password-key", "")
+	if err == nil {
+		t.Fatal("expected error for empty password")
+	}
+
+	// Upload a pubfile with the password key.
+	data := fastrand.Bytes(100 + siatest.Fuzz())
+	sup := modules.PubfileUploadParameters{
+		SiaPath:             modules.RandomSiaPath(),
+		BaseChunkRedundancy: 2,
+		FileMetadata: modules.PubfileMetadata{
+			Filename: "testPasswordSkykey",
+			Mode:     0640,
+		},
+		Reader:     bytes.NewReader(data),
+		SkykeyName: "password-key",
+	}
+	publink, _, err := r.SkynetSkyfilePost(sup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace the key with a key derived from the same password under a
+	// different name, the pubfile should still be decrypted.
+	err = r.SkykeyDeleteByNamePost("password-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.SkykeyCreatePasswordKeyPost("same-password-key", password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetchedData, _, err := r.SkynetPublinkGet(publink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fetchedData, data) {
+		t.Fatal("upload and download don't match")
+	}
+}
+
+// testPublicKeyEncryption tests uploading pubfiles encrypted to the public key
+// of a x25519 pubaccesskey.
+func testPublicKeyEncryption(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	sk, err := r.SkykeyCreateKeyPost("x25519-recipient", pubaccesskey.TypeX25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, err := sk.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk.Name = "x25519-public"
+
+	// Only keep the public key.
+	err = r.SkykeyDeleteByIDPost(sk.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.SkykeyAddKeyPost(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Upload a pubfile encrypted to the public key.
+	data := fastrand.Bytes(100 + siatest.Fuzz())
+	sup := modules.PubfileUploadParameters{
+		SiaPath:             modules.RandomSiaPath(),
+		BaseChunkRedundancy: 2,
+		FileMetadata: modules.PubfileMetadata{
+			Filename: "testPublicKeyEncryption",
+			Mode:     0640,
+		},
+		Reader:     bytes.NewReader(data),
+		SkykeyName: pk.Name,
+	}
+	publink, _, err := r.SkynetSkyfilePost(sup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The pubfile can't be decrypted with the public key.
+	_, _, err = r.SkynetPublinkGet(publink)
+	if err == nil {
+		t.Fatal("pubfile shouldn't be decrypted with the public key")
+	}
+
+	// Add the secret key again, the pubfile should be decrypted.
+	err = r.SkykeyAddKeyPost(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetchedData, _, err := r.SkynetPublinkGet(publink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fetchedData, data) {
+		t.Fatal("upload and download don't match")
+	}
+}
+
+// testRotateSkykey tests rotating a pubaccesskey and re-encrypting a pubfile
+// with its successor.
+func testRotateSkykey(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	keyName := "rotation-test-key"
+	successorName := keyName + "-successor"
+	sk, err := r.SkykeyCreateKeyPost(keyName, pubaccesskey.TypePrivateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Upload an encrypted pubfile and an unencrypted one.
+	data := fastrand.Bytes(100 + siatest.Fuzz())
+	filename := "testRotateSkykey"
+	uploadSiaPath, err := modules.NewSiaPath(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sup := modules.PubfileUploadParameters{
+		SiaPath:             uploadSiaPath,
+		BaseChunkRedundancy: 2,
+		FileMetadata: modules.PubfileMetadata{
+			Filename: filename,
+			Mode:     0640,
+		},
+		Reader:     bytes.NewReader(data),
+		SkykeyName: keyName,
+	}
+	publink, _, err := r.SkynetSkyfilePost(sup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainLink, _, _, err := r.UploadNewSkyfileBlocking("testRotateSkykeyPlain", 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotating a publink that isn't encrypted with the key fails, but the
+	// successor is created nonetheless.
+	_, err = r.SkykeyRotateByNamePost(keyName, successorName, []string{plainLink})
+	if err == nil || !strings.Contains(err.Error(), renter.ErrPublinkNotEncryptedWithSkykey.Error()) {
+		t.Fatal("expected error for unencrypted publink", err)
+	}
+	rotations, err := r.SkykeyRotationsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotations.Rotations) != 1 || len(rotations.Rotations[0].Publinks) != 0 {
+		t.Fatal("expected one rotation without publinks", rotations)
+	}
+
+	// Rotate the encrypted pubfile.
+	rotation, err := r.SkykeyRotateByIDPost(sk.ID(), "", []string{publink})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotation.KeyID != sk.ID().ToString() || rotation.Successor.Name != successorName {
+		t.Fatal("unexpected rotation", rotation)
+	}
+	if len(rotation.Publinks) != 1 || rotation.Publinks[0].Publink != publink {
+		t.Fatal("expected publink to be rotated", rotation.Publinks)
+	}
+	newPublink := rotation.Publinks[0].Successor
+	if newPublink == publink {
+		t.Fatal("rotated publink should change")
+	}
+
+	// Rotating the publink again is a no-op.
+	rotation2, err := r.SkykeyRotateByNamePost(keyName, "", []string{publink})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotation2.Publinks) != 1 || rotation2.Publinks[0].Successor != newPublink {
+		t.Fatal("publink shouldn't be rotated twice", rotation2.Publinks)
+	}
+
+	// Delete the rotated key, the new publink should still be downloadable.
+	err = r.SkykeyDeleteByNamePost(keyName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetchedData, metadata, err := r.SkynetPublinkGet(newPublink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fetchedData, data) {
+		t.Fatal("rotated pubfile doesn't match the upload")
+	}
+	if metadata.Filename != filename || metadata.Mode != 0640 {
+		t.Fatal("unexpected metadata", metadata)
+	}
+}
+
 // testSkynetEncryptionWithType returns the encryption test with the given
 // skykeyType set.
 func testSkynetEncryptionWithType(skykeyType pubaccesskey.PubaccesskeyType) func(t *testing.T, tg *siatest.TestGroup) {
@@ -9,8 +9,11 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"net/textproto"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -26,11 +29,13 @@ import (
 	"github.com/EvilRedHorse/pubaccess-node/modules"
 	"github.com/EvilRedHorse/pubaccess-node/modules/renter"
 	"github.com/EvilRedHorse/pubaccess-node/modules/renter/filesystem"
+	"github.com/EvilRedHorse/pubaccess-node/modules/renter/pubaccessblacklist"
 	"github.com/EvilRedHorse/pubaccess-node/node"
 	"github.com/EvilRedHorse/pubaccess-node/node/api"
 	"github.com/EvilRedHorse/pubaccess-node/persist"
 	"github.com/EvilRedHorse/pubaccess-node/siatest"
 	"github.com/EvilRedHorse/pubaccess-node/siatest/dependencies"
+	"github.com/EvilRedHorse/pubaccess-node/types"
 )
 
 // TestPubAccess verifies the functionality of Pubaccess, a decentralized CDN and
@@ -59,7 +64,13 @@ func TestPubAccess(t *testing.T) {
 		{Name: "TestPubaccessSubDirDownload", Test: testPubaccessSubDirDownload},
 		{Name: "TestPubaccessDisableForce", Test: testPubaccessDisableForce},
 		{Name: "TestPubaccessBlacklist", Test: testPubaccessBlacklist},
+		{Name: "TestPubaccessBlacklistFeeds", Test: testPubaccessBlacklistFeeds},
+		{Name: "TestPubaccessUnpin", Test: testPubaccessUnpin},
+		{Name: "TestPubaccessMultiRange", Test: testPubaccessMultiRange},
+		{Name: "TestPubaccessDirectoryIndex", Test: testPubaccessDirectoryIndex},
+		{Name: "TestPubaccessArchiveUpload", Test: testPubaccessArchiveUpload},
 		{Name: "TestPubaccessPortals", Test: testPubaccessPortals},
+		{Name: "TestPubaccessPortalHealth", Test: testPubaccessPortalHealth},
 		{Name: "TestPubaccessHeadRequest", Test: testPubaccessHeadRequest},
 		{Name: "TestPubaccessStats", Test: testPubaccessStats},
 		{Name: "TestPubaccessRequestTimeout", Test: testPubaccessRequestTimeout},
@@ -71,6 +82,8 @@ func TestPubAccess(t *testing.T) {
 		{Name: "TestPubaccessDefaultPath_TableTest", Test: testPubaccessDefaultPath_TableTest},
 		{Name: "TestPubaccessSingleFileNoSubfiles", Test: testPubaccessSingleFileNoSubfiles},
 		{Name: "TestPubaccessDownloadFormats", Test: testPubaccessDownloadFormats},
+		{Name: "TestPubaccessBatchUpload", Test: testPubaccessBatchUpload},
+		{Name: "TestPubaccessResumableUpload", Test: testPubaccessResumableUpload},
 	}
 
 	// Run tests
@@ -79,6 +92,199 @@ func TestPubAccess(t *testing.T) {
 	}
 }
 
+// testPubaccessBatchUpload verifies that files uploaded in a batch can be
+// downloaded through their own publinks.
+func testPubaccessBatchUpload(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	siaPath, err := modules.NewSiaPath("testBatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bup := modules.PubfileBatchUploadParameters{
+		SiaPath:             siaPath,
+		BaseChunkRedundancy: 2,
+	}
+	for i := 0; i < 3; i++ {
+		bup.Files = append(bup.Files, modules.PubfileBatchFile{
+			Metadata: modules.PubfileMetadata{
+				Filename: fmt.Sprintf("batchfile%v", i),
+			},
+			Data: fastrand.Bytes(100 + siatest.Fuzz()),
+		})
+	}
+	resp, err := r.SkynetSkyfileBatchPost(bup, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Pubfiles) != len(bup.Files) {
+		t.Fatalf("expected %v publinks, got %v", len(bup.Files), len(resp.Pubfiles))
+	}
+	for i, pf := range resp.Pubfiles {
+		if pf.Filename != bup.Files[i].Metadata.Filename {
+			t.Fatal("filename mismatch", pf.Filename, bup.Files[i].Metadata.Filename)
+		}
+		data, md, err := r.SkynetPublinkGet(pf.Publink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, bup.Files[i].Data) {
+			t.Fatal("data mismatch for batch file", i)
+		}
+		if md.Filename != pf.Filename {
+			t.Fatal("metadata mismatch for batch file", i)
+		}
+	}
+
+	// A batch upload without files should fail.
+	bup.Files = nil
+	_, err = r.SkynetSkyfileBatchPost(bup, false)
+	if err == nil {
+		t.Fatal("expected an empty batch upload to fail")
+	}
+
+	// A regular pubfile can be uploaded to the 'batch' siapath.
+	batchPath, err := modules.NewSiaPath("batch/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = r.SkynetSkyfilePost(modules.PubfileUploadParameters{
+		SiaPath:             batchPath,
+		BaseChunkRedundancy: 2,
+		FileMetadata:        modules.PubfileMetadata{Filename: "file"},
+		Reader:              bytes.NewReader(fastrand.Bytes(100)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.RenterSkyfileGet(batchPath, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testPubaccessResumableUpload tests uploading a pubfile in chunks using a
+// resumable upload.
+func testPubaccessResumableUpload(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	siaPath, err := modules.NewSiaPath("testResumable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sup := modules.PubfileUploadParameters{
+		SiaPath:             siaPath,
+		BaseChunkRedundancy: 2,
+		FileMetadata: modules.PubfileMetadata{
+			Filename: "resumable",
+			Mode:     0640,
+		},
+	}
+
+	// Create the upload. The data spans multiple sectors so that the pubfile
+	// has a fanout.
+	data := fastrand.Bytes(int(modules.SectorSize)*2 + siatest.Fuzz() + 1)
+	status, err := r.SkynetResumableUploadPost(sup, uint64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.UploadID == "" || status.Length != uint64(len(data)) || status.Uploaded != 0 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	// Append the first chunk and check the progress.
+	half := uint64(len(data) / 2)
+	status, err = r.SkynetUploadAppendPost(status.UploadID, 0, bytes.NewReader(data[:half]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Uploaded != half || status.Publink != "" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	status, err = r.SkynetUploadGet(status.UploadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Uploaded != half {
+		t.Fatal("unexpected progress", status.Uploaded)
+	}
+	uploads, err := r.SkynetUploadsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads.Uploads) != 1 || uploads.Uploads[0].UploadID != status.UploadID {
+		t.Fatalf("unexpected uploads %+v", uploads.Uploads)
+	}
+
+	// Appending at the wrong offset fails.
+	_, err = r.SkynetUploadAppendPost(status.UploadID, 0, bytes.NewReader(data[:half]))
+	if err == nil || !strings.Contains(err.Error(), renter.ErrResumableUploadOffset.Error()) {
+		t.Fatal("expected offset error but got", err)
+	}
+
+	// Append the remaining data, which uploads the pubfile.
+	status, err = r.SkynetUploadAppendPost(status.UploadID, half, bytes.NewReader(data[half:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Uploaded != uint64(len(data)) || status.Publink == "" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	downloaded, md, err := r.SkynetPublinkGet(status.Publink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Fatal("downloaded data doesn't match")
+	}
+	if md.Filename != sup.FileMetadata.Filename || md.Mode != sup.FileMetadata.Mode {
+		t.Fatalf("unexpected metadata %+v", md)
+	}
+
+	// The finished upload is no longer listed.
+	if _, err := r.SkynetUploadGet(status.UploadID); err == nil {
+		t.Fatal("expected finished upload to be removed")
+	}
+
+	// Abort an upload.
+	sup.SiaPath, err = modules.NewSiaPath("testResumableAbort")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err = r.SkynetResumableUploadPost(sup, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SkynetUploadAbortPost(status.UploadID); err != nil {
+		t.Fatal(err)
+	}
+	uploads, err = r.SkynetUploadsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads.Uploads) != 0 {
+		t.Fatalf("expected no uploads but got %+v", uploads.Uploads)
+	}
+
+	// Uploads that exceed the staging limit are rejected.
+	if err := r.RenterMaxResumableUploadBytesPost(100); err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.SkynetResumableUploadPost(sup, 101)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrResumableUploadsLimit.Error()) {
+		t.Fatal("expected staging limit error but got", err)
+	}
+	if err := r.RenterMaxResumableUploadBytesPost(0); err != nil {
+		t.Fatal(err)
+	}
+	rg, err := r.RenterGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rg.Settings.MaxResumableUploadBytes != renter.DefaultMaxResumableUploadBytes {
+		t.Fatal("limit wasn't reset to the default", rg.Settings.MaxResumableUploadBytes)
+	}
+}
+
 // testPubaccessBasic provides basic end-to-end testing for uploading pubfiles and
 // downloading the resulting publinks.
 func testPubaccessBasic(t *testing.T, tg *siatest.TestGroup) {
@@ -1741,6 +1947,492 @@ func testPubaccessBlacklist(t *testing.T, tg *siatest.TestGroup) {
 	}
 }
 
+// testPubaccessBlacklistFeeds tests blacklisting publinks by their hash and
+// through subscribed remote blacklist feeds.
+func testPubaccessBlacklistFeeds(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a pubfile.
+	publink, _, sshp, err := r.UploadNewSkyfileBlocking(t.Name(), 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := crypto.HashObject(sshp.MerkleRoot)
+
+	// Serve a feed which blacklists the pubfile.
+	sk, pk := crypto.GenerateKeyPair()
+	feed := pubaccessblacklist.NewFeed(1, []crypto.Hash{hash}, sk)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(feed)
+	}))
+	defer server.Close()
+
+	// A subscription with an invalid url is rejected.
+	sub := modules.SkynetBlacklistSubscription{
+		URL:       server.URL,
+		PublicKey: types.Ed25519PublicKey(pk),
+	}
+	invalid := sub
+	invalid.URL = "invalid"
+	err = r.SkynetBlacklistSubscriptionsPost([]modules.SkynetBlacklistSubscription{invalid}, nil)
+	if err == nil {
+		t.Fatal("expected invalid subscription to be rejected")
+	}
+
+	// Subscribe to the feed and wait for it to be applied.
+	err = r.SkynetBlacklistSubscriptionsPost([]modules.SkynetBlacklistSubscription{sub}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		sbsg, err := r.SkynetBlacklistSubscriptionsGet()
+		if err != nil {
+			return err
+		}
+		if len(sbsg.Subscriptions) != 1 || sbsg.Subscriptions[0].Revision != 1 {
+			return fmt.Errorf("feed not applied yet: %+v", sbsg.Subscriptions)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = r.SkynetPublinkGet(publink)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrPublinkBlacklisted.Error()) {
+		t.Fatalf("Expected error %v but got %v", renter.ErrPublinkBlacklisted, err)
+	}
+
+	// Blacklist the hash locally as well, the entry should have both sources.
+	err = r.SkynetBlacklistHashPost([]string{hash.String()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sbg, err := r.SkynetBlacklistGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []modules.SkynetBlacklistEntry{{Hash: hash, Sources: []string{modules.SkynetBlacklistSourceLocal, server.URL}}}
+	if !reflect.DeepEqual(sbg.Entries, expected) {
+		t.Fatalf("unexpected entries\n%v\n%v", sbg.Entries, expected)
+	}
+
+	// Removing the local entry keeps the publink blacklisted by the feed.
+	err = r.SkynetBlacklistHashPost(nil, []string{hash.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = r.SkynetPublinkGet(publink)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrPublinkBlacklisted.Error()) {
+		t.Fatalf("Expected error %v but got %v", renter.ErrPublinkBlacklisted, err)
+	}
+
+	// Unsubscribing removes the entries of the feed.
+	err = r.SkynetBlacklistSubscriptionsPost(nil, []string{server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sbg, err = r.SkynetBlacklistGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sbg.Blacklist) != 0 || len(sbg.Entries) != 0 {
+		t.Fatalf("expected empty blacklist but got %v", sbg.Entries)
+	}
+	_, _, err = r.SkynetPublinkGet(publink)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testPubaccessUnpin tests unpinning pubfiles by their publink.
+func testPubaccessUnpin(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a small and a large pubfile.
+	smallLink, smallSup, _, err := r.UploadNewSkyfileBlocking(t.Name()+"_small", 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := modules.SectorSize + uint64(100+siatest.Fuzz())
+	largeLink, largeSup, _, err := r.UploadNewSkyfileBlocking(t.Name()+"_large", size, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smallPath, err := modules.SkynetFolder.Join(smallSup.SiaPath.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	largePath, err := modules.SkynetFolder.Join(largeSup.SiaPath.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	largePathExtended, err := modules.NewSiaPath(largePath.String() + renter.ExtendedSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// filesize returns the total size of the siafiles.
+	filesize := func(siaPaths ...modules.SiaPath) (size uint64) {
+		for _, sp := range siaPaths {
+			rf, err := r.RenterFileRootGet(sp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			size += rf.File.Filesize
+		}
+		return size
+	}
+
+	// Unpin the small pubfile.
+	smallSize := filesize(smallPath)
+	sup, err := r.SkynetPublinkUnpinPost(smallLink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sup.SiaPaths) != 1 || !sup.SiaPaths[0].Equals(smallPath) {
+		t.Fatal("unexpected siapaths", sup.SiaPaths)
+	}
+	if sup.FreedBytes != smallSize {
+		t.Fatalf("expected %v freed bytes but got %v", smallSize, sup.FreedBytes)
+	}
+	_, err = r.RenterFileRootGet(smallPath)
+	if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
+		t.Fatalf("Expected error %v but got %v", filesystem.ErrNotExist, err)
+	}
+
+	// Unpin the large pubfile, the extended siafile should be deleted as well
+	// and count towards the freed bytes.
+	largeSize := filesize(largePath, largePathExtended)
+	sup, err = r.SkynetPublinkUnpinPost(largeLink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sup.SiaPaths) != 2 {
+		t.Fatal("unexpected siapaths", sup.SiaPaths)
+	}
+	if sup.FreedBytes != largeSize || largeSize < size {
+		t.Fatalf("expected %v freed bytes but got %v", largeSize, sup.FreedBytes)
+	}
+	for _, sp := range []modules.SiaPath{largePath, largePathExtended} {
+		_, err = r.RenterFileRootGet(sp)
+		if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
+			t.Fatalf("Expected error %v but got %v", filesystem.ErrNotExist, err)
+		}
+	}
+
+	// Unpinning the publink again should fail.
+	_, err = r.SkynetPublinkUnpinPost(largeLink)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrPublinkNotPinned.Error()) {
+		t.Fatalf("Expected error %v but got %v", renter.ErrPublinkNotPinned, err)
+	}
+}
+
+// testPubaccessMultiRange tests downloading large pubfiles with a prefetch and
+// with multi-range requests.
+func testPubaccessMultiRange(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a pubfile which spans multiple fanout chunks.
+	size := 3*modules.SectorSize + uint64(100+siatest.Fuzz())
+	data := fastrand.Bytes(int(size))
+	publink, _, _, err := r.UploadNewSkyfileWithDataBlocking(t.Name(), data, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Download the pubfile with a prefetch.
+	downloaded, _, err := r.SkynetPublinkGetWithPrefetch(publink, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Fatal("downloaded data doesn't match")
+	}
+
+	// A prefetch above the maximum is rejected.
+	_, _, err = r.SkynetPublinkGetWithPrefetch(publink, api.MaxSkynetPrefetch+1)
+	if err == nil || !strings.Contains(err.Error(), "'prefetch' parameter too high") {
+		t.Fatal("expected prefetch to be rejected but got:", err)
+	}
+
+	// Request multiple ranges which are located in different fanout chunks.
+	ranges := [][2]uint64{
+		{0, 9},
+		{modules.SectorSize + 5, modules.SectorSize + 20},
+		{size - 10, size - 1},
+	}
+	var specs []string
+	for _, rng := range ranges {
+		specs = append(specs, fmt.Sprintf("%d-%d", rng[0], rng[1]))
+	}
+	req, err := r.NewRequest("GET", "/pubaccess/publink/"+publink, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Range", "bytes="+strings.Join(specs, ","))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusPartialContent {
+		t.Fatal("unexpected status code", res.StatusCode)
+	}
+	mediaType, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != "multipart/byteranges" {
+		t.Fatal("unexpected content type", mediaType)
+	}
+
+	// Verify the data of every range.
+	mr := multipart.NewReader(res.Body, params["boundary"])
+	for i, rng := range ranges {
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		partData, err := ioutil.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(partData, data[rng[0]:rng[1]+1]) {
+			t.Fatalf("data of range %v doesn't match", i)
+		}
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Fatal("expected no more parts but got:", err)
+	}
+}
+
+// testPubaccessDirectoryIndex tests the directory index of multi-file pubfiles
+// and the content types that are sniffed for subfiles which lack one.
+func testPubaccessDirectoryIndex(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Create a multipart upload in which only one of the files has a content
+	// type.
+	files := []struct {
+		name        string
+		data        []byte
+		contentType string
+	}{
+		{"page", []byte("<html><body>page</body></html>"), ""},
+		{"notes.txt", []byte("some notes"), ""},
+		{"data.bin", fastrand.Bytes(100), "application/x-custom"},
+	}
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	for _, f := range files {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files[]"; filename="%s"`, f.name))
+		if f.contentType != "" {
+			h.Set("Content-Type", f.contentType)
+		}
+		part, err := writer.CreatePart(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := part.Write(f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	siaPath, err := modules.NewSiaPath(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	publink, _, err := r.SkynetSkyfileMultiPartPost(modules.SkyfileMultipartUploadParameters{
+		SiaPath:             siaPath,
+		BaseChunkRedundancy: 2,
+		Reader:              bytes.NewReader(body.Bytes()),
+		ContentType:         writer.FormDataContentType(),
+		Filename:            t.Name(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fetch the index as JSON.
+	index, err := r.SkynetPublinkIndexGet(publink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.Publink != publink || index.Path != "/" {
+		t.Fatal("unexpected index", index)
+	}
+	expected := []api.SkynetDirectoryEntry{
+		{Name: "data.bin", Path: "/data.bin", Size: 100, ContentType: "application/x-custom"},
+		{Name: "notes.txt", Path: "/notes.txt", Size: 10, ContentType: "text/plain; charset=utf-8"},
+		{Name: "page", Path: "/page", Size: uint64(len(files[0].data)), ContentType: "text/html; charset=utf-8"},
+	}
+	if !reflect.DeepEqual(index.Entries, expected) {
+		t.Fatalf("unexpected entries\n%+v\n%+v", index.Entries, expected)
+	}
+
+	// Fetch the index as HTML.
+	req, err := r.NewRequest("GET", "/pubaccess/publink/"+publink+"?format=index", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := res.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Fatal("unexpected content type", ct)
+	}
+	for _, f := range files {
+		link := fmt.Sprintf(`href="/pubaccess/publink/%s/%s"`, publink, f.name)
+		if !strings.Contains(string(data), link) {
+			t.Fatalf("index doesn't contain link %v:\n%v", link, string(data))
+		}
+	}
+
+	// The sniffed content type is used to serve the subfile.
+	_, header, err := r.SkynetPublinkHead(publink + "/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := header.Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Fatal("unexpected content type", ct)
+	}
+
+	// The index of a file is rejected.
+	_, err = r.SkynetPublinkIndexGet(publink + "/page")
+	if err == nil || !strings.Contains(err.Error(), "requires a directory") {
+		t.Fatal("expected index of a file to fail but got:", err)
+	}
+}
+
+// testPubaccessArchiveUpload tests that downloaded archives can be uploaded
+// as the same pubfile.
+func testPubaccessArchiveUpload(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a multi-file pubfile.
+	files := []siatest.TestFile{
+		{Name: "index.html", Data: []byte("<html>index</html>")},
+		{Name: "file1", Data: fastrand.Bytes(100)},
+		{Name: "file2.txt", Data: []byte("some text")},
+	}
+	filename := t.Name()
+	publink, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, expectedMetadata, err := r.SkynetPublinkGet(publink + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Download the pubfile in every archive format and upload it again.
+	formats := []struct {
+		contentType string
+		get         func(string) (http.Header, io.ReadCloser, error)
+	}{
+		{"application/x-tar", r.SkynetPublinkTarReaderGet},
+		{"application/gzip", r.SkynetPublinkTarGzReaderGet},
+		{"application/zip", r.SkynetPublinkZipReaderGet},
+	}
+	for i, format := range formats {
+		// Download the pubfile as an archive.
+		_, reader, err := format.get(publink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		archive, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := reader.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Upload the archive, this should result in the same publink.
+		siaPath, err := modules.NewSiaPath(fmt.Sprintf("%v_%v", t.Name(), i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		archivePublink, _, err := r.SkynetSkyfileMultiPartPost(modules.SkyfileMultipartUploadParameters{
+			SiaPath:             siaPath,
+			BaseChunkRedundancy: 2,
+			Reader:              bytes.NewReader(archive),
+			ContentType:         format.contentType,
+			Filename:            filename,
+			ExtractArchive:      true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if archivePublink != publink {
+			_, metadata, _ := r.SkynetPublinkGet(archivePublink + "/")
+			t.Fatalf("%v: publinks don't match\n%+v\n%+v", format.contentType, metadata, expectedMetadata)
+		}
+	}
+
+	// Without the extract parameter the archive is uploaded as a single file.
+	_, reader, err := r.SkynetPublinkTarReaderGet(publink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatal(err)
+	}
+	siaPath, err := modules.NewSiaPath(t.Name() + "_single")
+	if err != nil {
+		t.Fatal(err)
+	}
+	singlePublink, _, err := r.SkynetSkyfileMultiPartPost(modules.SkyfileMultipartUploadParameters{
+		SiaPath:             siaPath,
+		BaseChunkRedundancy: 2,
+		Reader:              bytes.NewReader(archive),
+		ContentType:         "application/x-tar",
+		Filename:            filename,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, metadata, err := r.SkynetPublinkGet(singlePublink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metadata.Subfiles) != 0 || !bytes.Equal(data, archive) {
+		t.Fatal("archive should be uploaded as a single file", metadata)
+	}
+
+	// A corrupt archive is rejected.
+	siaPath, err = modules.NewSiaPath(t.Name() + "_corrupt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = r.SkynetSkyfileMultiPartPost(modules.SkyfileMultipartUploadParameters{
+		SiaPath:             siaPath,
+		BaseChunkRedundancy: 2,
+		Reader:              bytes.NewReader(fastrand.Bytes(100)),
+		ContentType:         "application/zip",
+		Filename:            filename,
+		ExtractArchive:      true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed parsing archive request") {
+		t.Fatal("expected corrupt archive to be rejected but got:", err)
+	}
+}
+
 // testPubaccessPortals tests the pubaccess portals module.
 func testPubaccessPortals(t *testing.T, tg *siatest.TestGroup) {
 	r := tg.Renters()[0]
@@ -1776,7 +2468,7 @@ func testPubaccessPortals(t *testing.T, tg *siatest.TestGroup) {
 	if len(spg.Portals) != 1 {
 		t.Fatalf("Incorrect number of portals, expected %v got %v", 1, len(spg.Portals))
 	}
-	if !reflect.DeepEqual(spg.Portals[0], portal1) {
+	if spg.Portals[0].Address != portal1.Address || spg.Portals[0].Public != portal1.Public {
 		t.Fatalf("Portals don't match, expected %v got %v", portal1, spg.Portals[0])
 	}
 
@@ -1889,6 +2581,144 @@ func testPubaccessPortals(t *testing.T, tg *siatest.TestGroup) {
 	}
 }
 
+// testPubaccessPortalHealth tests that portals are probed and that downloads
+// the renter can't find fall back to the healthiest portal.
+func testPubaccessPortalHealth(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Get the publink of a pubfile without uploading it, so the renter can't
+	// find it.
+	data := fastrand.Bytes(100)
+	siaPath, err := modules.NewSiaPath(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	publink, _, err := r.SkynetSkyfilePost(modules.PubfileUploadParameters{
+		SiaPath:             siaPath,
+		BaseChunkRedundancy: 2,
+		FileMetadata:        modules.PubfileMetadata{Filename: t.Name()},
+		Reader:              bytes.NewReader(data),
+		DryRun:              true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Upload a pubfile and blacklist it.
+	blacklisted, _, _, err := r.UploadNewSkyfileBlocking(t.Name()+"_blacklisted", 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.SkynetBlacklistPost([]string{blacklisted}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := r.SkynetBlacklistPost(nil, []string{blacklisted}); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create a portal which serves both pubfiles.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/pubaccess/stats":
+			_ = json.NewEncoder(w).Encode(api.SkynetStatsGET{VersionInfo: api.SkynetVersion{Version: "fake"}})
+		case "/pubaccess/publink/" + publink, "/pubaccess/publink/" + blacklisted:
+			_, _ = w.Write(data)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer server.Close()
+
+	// Add the renter itself, the fake portal and an unreachable portal.
+	self := modules.SkynetPortal{Address: modules.NetAddress(r.Address), Public: true}
+	fake := modules.SkynetPortal{Address: modules.NetAddress(strings.TrimPrefix(server.URL, "http://")), Public: true}
+	unreachable := modules.SkynetPortal{Address: "localhost:1", Public: true}
+	add := []modules.SkynetPortal{self, fake, unreachable}
+	err = r.SkynetPortalsPost(add, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := r.SkynetPortalsPost(nil, []modules.NetAddress{fake.Address, unreachable.Address}); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Wait for the portals to be probed.
+	var portals []modules.SkynetPortal
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		spg, err := r.SkynetPortalsGet()
+		if err != nil {
+			return err
+		}
+		for _, portal := range spg.Portals {
+			if portal.Health == nil {
+				return fmt.Errorf("portal %v not probed yet", portal.Address)
+			}
+		}
+		portals = spg.Portals
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, portal := range portals {
+		switch portal.Address {
+		case self.Address:
+			if !portal.Health.Healthy || !strings.HasPrefix(portal.Health.Version, build.Version) || portal.Health.URL != "http://"+r.Address {
+				t.Fatalf("unexpected health of renter %+v", portal.Health)
+			}
+		case fake.Address:
+			if !portal.Health.Healthy || portal.Health.Version != "fake" {
+				t.Fatalf("unexpected health of fake portal %+v", portal.Health)
+			}
+		case unreachable.Address:
+			if portal.Health.Healthy || portal.Health.LastError == "" || portal.Health.ConsecutiveFailures == 0 {
+				t.Fatalf("unexpected health of unreachable portal %+v", portal.Health)
+			}
+		}
+	}
+
+	// Remove the renter from the portals so the fake portal is the healthiest.
+	err = r.SkynetPortalsPost(nil, []modules.NetAddress{self.Address})
+	if err != nil {
+		t.Fatal(err)
+	}
+	portal, err := r.SkynetHealthiestPortal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if portal.Address != fake.Address {
+		t.Fatalf("expected %v to be the healthiest portal but got %v", fake.Address, portal.Address)
+	}
+
+	// Downloading the blacklisted publink doesn't fall back to the portal.
+	_, err = r.SkynetPublinkReaderGetWithPortalFallback(blacklisted)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrPublinkBlacklisted.Error()) {
+		t.Fatal("expected blacklisted publink to fail without fallback but got:", err)
+	}
+
+	// Downloading the publink the renter can't find falls back to the fake
+	// portal.
+	reader, err := r.SkynetPublinkReaderGetWithPortalFallback(publink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fetched, data) {
+		t.Fatal("data fetched from the portal doesn't match")
+	}
+}
+
 // testPubaccessHeadRequest verifies the functionality of sending a HEAD request to
 // the publink GET route.
 func testPubaccessHeadRequest(t *testing.T, tg *siatest.TestGroup) {